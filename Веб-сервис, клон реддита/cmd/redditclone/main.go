@@ -11,45 +11,62 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"redditclone/configs"
+	"redditclone/internal/collections"
+	"redditclone/internal/grpcapi"
 	"redditclone/internal/handlers"
+	"redditclone/internal/media"
 	"redditclone/internal/middleware"
+	"redditclone/internal/moderation"
+	"redditclone/internal/notifications"
+	"redditclone/internal/postcache"
 	"redditclone/internal/posts"
+	"redditclone/internal/ratelimit"
+	"redditclone/internal/realtime"
 	"redditclone/internal/sessions"
+	"redditclone/internal/subscriptions"
+	"redditclone/internal/tracing"
 	"redditclone/internal/user"
+	"redditclone/internal/views"
+	"redditclone/static"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
+	"google.golang.org/grpc"
 )
 
-func homeHandler(w http.ResponseWriter, r *http.Request) {
-
-	html, err := os.ReadFile("../../static/html/index.html")
+func main() {
+	config, err := configs.LoadConfig()
 	if err != nil {
-		// Обработка ошибки, если файл не найден
-		http.Error(w, "File not found", 404)
-		return
+		log.Fatalf("Error loading config: %v", err)
 	}
 
-	// Установка Content-Type
-	w.Header().Set("Content-Type", "text/html")
-
-	// Отправка содержимого файла
-	_, err = w.Write(html)
-	if err != nil {
-		log.Println(err.Error())
-	}
-}
+	ctx := context.Background()
 
-func main() {
-	config, err := configs.LoadConfig()
+	// Настраиваем трейсинг.
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		ServiceName: config.Tracing.ServiceName,
+		Exporter:    config.Tracing.Exporter,
+		Endpoint:    config.Tracing.Endpoint,
+		Insecure:    config.Tracing.Insecure,
+		SampleRatio: config.Tracing.SampleRatio,
+	})
 	if err != nil {
-		log.Fatalf("Error loading config: %v", err)
+		log.Fatalf("Error setting up tracing: %v", err)
 	}
+	defer func() {
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Failed to shutdown tracing: %v", err)
+		}
+	}()
 
 	// Настраиваем подключение к mongodb.
-	ctx := context.Background()
 	mongoAddr := fmt.Sprintf("mongodb://%s", config.MongoDB.Host)
 	mongoDB, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoAddr))
 	if err != nil {
@@ -68,6 +85,11 @@ func main() {
 	}
 	log.Println("Успешное подключение к MongoDB!")
 	collection := mongoDB.Database("golang").Collection("posts")
+	listsCollection := mongoDB.Database("golang").Collection("collections")
+	subsCollection := mongoDB.Database("golang").Collection("subscriptions")
+	notificationsCollection := mongoDB.Database("golang").Collection("notifications")
+	reportsCollection := mongoDB.Database("golang").Collection("reports")
+	auditCollection := mongoDB.Database("golang").Collection("moderation_log")
 
 	// Настраиваем подключение к mysql.
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
@@ -91,12 +113,19 @@ func main() {
 	// Настраиваем подключение к redis
 	redisAddr := fmt.Sprintf("redis://%s:@%s:%d/0", config.Redis.User, config.Redis.Host, config.Redis.Port)
 	addr := flag.String("addr", redisAddr, "help message for flagname")
-	redisConn, err := redis.DialURL(*addr)
-	if err != nil {
-		log.Printf("Error connecting to redis: %v", err)
+	// Один пул на сессии, лимиты, кеш и просмотры: соединение берётся на каждую операцию.
+	redisPool := &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 5 * time.Minute,
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			return redis.DialURLContext(ctx, *addr)
+		},
 	}
+	defer redisPool.Close()
 
-	sessManager := sessions.NewSessionManager(redisConn)
+	redisSessions := sessions.NewSessionManager(redisPool)
+	redisSessions.Timeout = config.Timeouts.Redis
+	sessManager := tracing.NewSessionManager(redisSessions)
 
 	zapLogger, err := zap.NewProduction()
 	if err != nil {
@@ -104,13 +133,95 @@ func main() {
 	}
 	defer func() {
 		if err = zapLogger.Sync(); err != nil {
-			log.Fatalf("Failed to sync logger: %v", err)
+			log.Printf("Failed to sync logger: %v", err)
 		}
 	}()
 	logger := zapLogger.Sugar()
 
-	userRepo := user.NewMysqlRepo(mysql)
-	postsRepo := posts.NewMongoRepo(collection)
+	mysqlRepo := user.NewMysqlRepo(mysql)
+	mysqlRepo.Timeout = config.Timeouts.MySQL
+	userRepo := tracing.NewUserRepo(mysqlRepo)
+
+	mongoRepo := posts.NewMongoRepo(collection)
+	mongoRepo.ReadTimeout = config.Timeouts.MongoRead
+	mongoRepo.WriteTimeout = config.Timeouts.MongoWrite
+	if err = mongoRepo.EnsureIndexes(ctx); err != nil {
+		log.Printf("Error creating posts indexes: %v", err)
+	}
+	var postsRepo posts.PostRepo = tracing.NewPostRepo(mongoRepo)
+
+	mongoCollections := collections.NewMongoRepo(listsCollection)
+	mongoCollections.ReadTimeout = config.Timeouts.MongoRead
+	mongoCollections.WriteTimeout = config.Timeouts.MongoWrite
+	if err = mongoCollections.EnsureIndexes(ctx); err != nil {
+		log.Printf("Error creating collections indexes: %v", err)
+	}
+	collectionsRepo := tracing.NewCollectionRepo(mongoCollections)
+
+	mongoSubscriptions := subscriptions.NewMongoRepo(subsCollection)
+	mongoSubscriptions.ReadTimeout = config.Timeouts.MongoRead
+	mongoSubscriptions.WriteTimeout = config.Timeouts.MongoWrite
+	if err = mongoSubscriptions.EnsureIndexes(ctx); err != nil {
+		log.Printf("Error creating subscriptions indexes: %v", err)
+	}
+	subscriptionsRepo := tracing.NewSubscriptionRepo(mongoSubscriptions)
+
+	mongoNotifications := notifications.NewMongoRepo(notificationsCollection)
+	mongoNotifications.ReadTimeout = config.Timeouts.MongoRead
+	mongoNotifications.WriteTimeout = config.Timeouts.MongoWrite
+	if err = mongoNotifications.EnsureIndexes(ctx); err != nil {
+		log.Printf("Error creating notifications indexes: %v", err)
+	}
+	notificationsRepo := tracing.NewNotificationRepo(mongoNotifications)
+
+	mongoReports := moderation.NewReportRepo(reportsCollection)
+	mongoReports.ReadTimeout = config.Timeouts.MongoRead
+	mongoReports.WriteTimeout = config.Timeouts.MongoWrite
+	if err = mongoReports.EnsureIndexes(ctx); err != nil {
+		log.Printf("Error creating reports indexes: %v", err)
+	}
+	reportsRepo := tracing.NewReportRepo(mongoReports)
+
+	mongoAudit := moderation.NewAuditRepo(auditCollection)
+	mongoAudit.ReadTimeout = config.Timeouts.MongoRead
+	mongoAudit.WriteTimeout = config.Timeouts.MongoWrite
+	if err = mongoAudit.EnsureIndexes(ctx); err != nil {
+		log.Printf("Error creating moderation log indexes: %v", err)
+	}
+	auditLog := tracing.NewAuditLog(mongoAudit)
+
+	// Картинки постов лежат в каталоге на диске или в S3-совместимом хранилище.
+	var blobStore media.BlobStore
+	switch config.Media.Store {
+	case "s3":
+		s3Store, err := media.NewS3Store(media.S3Config{
+			Endpoint:  config.Media.S3.Endpoint,
+			Region:    config.Media.S3.Region,
+			Bucket:    config.Media.S3.Bucket,
+			AccessKey: config.Media.S3.AccessKey,
+			SecretKey: config.Media.S3.SecretKey,
+			UseSSL:    config.Media.S3.UseSSL,
+		})
+		if err != nil {
+			log.Fatalf("Error connecting to S3: %v", err)
+		}
+		if err = s3Store.EnsureBucket(ctx); err != nil {
+			log.Printf("Error creating media bucket: %v", err)
+		}
+		blobStore = s3Store
+	default:
+		localStore, err := media.NewLocalStore(config.Media.Dir)
+		if err != nil {
+			log.Fatalf("Error creating media directory: %v", err)
+		}
+		blobStore = localStore
+	}
+	blobStore = tracing.NewBlobStore(blobStore)
+	uploader := media.NewUploader(blobStore, media.Limits{
+		MaxBytes:  int64(config.Media.MaxBytes),
+		MaxPixels: config.Media.MaxPixels,
+		ThumbSize: config.Media.ThumbSize,
+	})
 
 	userHandler := &handlers.UserHandler{
 		UserRepo: userRepo,
@@ -118,42 +229,190 @@ func main() {
 		Sessions: sessManager,
 	}
 
-	postsHandler := &handlers.PostsHandler{
-		PostsRepo: postsRepo,
-		Logger:    logger,
-		Sessions:  sessManager,
+	// Посты и списки читаются через кеш, записи его сбрасывают.
+	switch config.PostsCache.Store {
+	case "redis":
+		postsRepo = postcache.NewRepo(postsRepo, postcache.NewRedisStore(redisPool), config.PostsCache.TTL, logger)
+	case "memory":
+		postsRepo = postcache.NewRepo(postsRepo, postcache.NewMemoryStore(config.PostsCache.Size), config.PostsCache.TTL, logger)
 	}
 
-	r := mux.NewRouter()
+	// Лимиты на запись хранятся либо в памяти процесса, либо в redis (общие для всех инстансов).
+	limiter := &ratelimit.Limiter{Budgets: config.RateLimit.Budgets}
+	duplicates := &ratelimit.DuplicateDetector{
+		MaxRepeats: config.RateLimit.DuplicateLimit,
+		Window:     config.RateLimit.DuplicateWindow,
+	}
+	switch config.RateLimit.Store {
+	case "redis":
+		limiter.Store = ratelimit.NewRedisStore(redisPool)
+		duplicates.Counter = ratelimit.NewRedisCounter(redisPool)
+	default:
+		limiter.Store = ratelimit.NewMemoryStore()
+		duplicates.Counter = ratelimit.NewMemoryCounter()
+	}
 
-	r.HandleFunc("/", homeHandler)
-	r.PathPrefix("/a/").HandlerFunc(homeHandler)
+	// Просмотры считаются отдельно от чтения поста и сбрасываются в монгу пачками.
+	var viewsDeduper views.Deduper
+	switch config.Views.Store {
+	case "redis":
+		viewsDeduper = views.NewRedisDeduper(redisPool, config.Views.Window)
+	default:
+		viewsDeduper = views.NewMemoryDeduper(config.Views.Window)
+	}
+	viewsRecorder := views.NewRecorder(viewsDeduper, postsRepo, logger)
+	viewsCtx, stopViews := context.WithCancel(ctx)
+	viewsDone := make(chan struct{})
+	go func() {
+		defer close(viewsDone)
+		viewsRecorder.Run(viewsCtx, config.Views.FlushInterval)
+	}()
 
-	staticHandler := http.StripPrefix("/static/", http.FileServer(http.Dir("../../static/")))
-	r.PathPrefix("/static/").Handler(staticHandler)
+	// Удалённые посты и комментарии стираются окончательно по истечении срока хранения.
+	purger := posts.NewPurger(postsRepo, config.Retention.Period, config.Retention.PurgeInterval, logger)
+	purgeCtx, stopPurge := context.WithCancel(ctx)
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		purger.Run(purgeCtx)
+	}()
 
-	r.HandleFunc("/api/login", userHandler.Login).Methods("POST")
-	r.HandleFunc("/api/register", userHandler.Register).Methods("POST")
+	// Уведомления создаются в фоне: обработчики только кладут события в очередь.
+	dispatcher := notifications.NewDispatcher(notificationsRepo, userRepo, logger, config.Notifications.Buffer)
+	dispatcher.Timeout = config.Timeouts.MongoWrite
+	notificationsCtx, stopNotifications := context.WithCancel(ctx)
+	var notificationsDone sync.WaitGroup
+	for i := 0; i < config.Notifications.Workers; i++ {
+		notificationsDone.Add(1)
+		go func() {
+			defer notificationsDone.Done()
+			dispatcher.Run(notificationsCtx)
+		}()
+	}
 
-	r.HandleFunc("/api/posts/", postsHandler.GetAllPosts).Methods("GET")
-	r.HandleFunc("/api/posts/{CATEGORY_NAME}", postsHandler.GetCategoryPosts).Methods("GET")
-	r.HandleFunc("/api/user/{USER_LOGIN}", postsHandler.GetUserPosts).Methods("GET")
-	r.HandleFunc("/api/post/{POST_ID}", postsHandler.GetPost).Methods("GET")
-	r.HandleFunc("/api/post/{POST_ID}/upvote", postsHandler.UpVotePost).Methods("GET")
-	r.HandleFunc("/api/post/{POST_ID}/downvote", postsHandler.DownVotePost).Methods("GET")
-	r.HandleFunc("/api/post/{POST_ID}/unvote", postsHandler.UnVotePost).Methods("GET")
+	// События об изменениях постов для открытых потоков SSE.
+	var hub realtime.Hub
+	var closeHub func()
+	realtimeCtx, stopRealtime := context.WithCancel(ctx)
+	defer stopRealtime()
+	switch config.Realtime.Store {
+	case "redis":
+		redisHub := realtime.NewRedisHub(redisPool, logger)
+		go redisHub.Run(realtimeCtx)
+		hub, closeHub = redisHub, redisHub.Close
+	default:
+		memoryHub := realtime.NewMemoryHub()
+		hub, closeHub = memoryHub, memoryHub.Close
+	}
 
-	r.HandleFunc("/api/posts", postsHandler.MakePost).Methods("POST")
-	r.HandleFunc("/api/post/{POST_ID}", postsHandler.DeletePost).Methods("DELETE")
+	postsHandler := &handlers.PostsHandler{
+		PostsRepo:           postsRepo,
+		Logger:              logger,
+		Sessions:            sessManager,
+		Users:               userRepo,
+		Limiter:             limiter,
+		Duplicates:          duplicates,
+		TrustProxy:          config.RateLimit.TrustProxy,
+		Views:               viewsRecorder,
+		Collections:         collectionsRepo,
+		Subscriptions:       subscriptionsRepo,
+		Notifier:            dispatcher,
+		Notifications:       notificationsRepo,
+		Realtime:            hub,
+		Moderators:          moderation.NewModerators(config.Moderators),
+		Reports:             reportsRepo,
+		AuditLog:            auditLog,
+		Media:               uploader,
+		DuplicateLinkWindow: config.DuplicateLinkWindow,
+	}
 
-	r.HandleFunc("/api/post/{POST_ID}", postsHandler.MakeComment).Methods("POST")
-	r.HandleFunc("/api/post/{POST_ID}/{COMMENT_ID}", postsHandler.DeleteComment).Methods("DELETE")
+	r := mux.NewRouter()
+	r.Use(tracing.Middleware)
 
-	middleWares := middleware.AccessLog(logger, r)
+	// Фронтенд встроен в бинарник. STATIC_DIR подменяет его каталогом на диске для разработки.
+	frontend, err := static.NewServer(static.FS(config.StaticDir), config.StaticMaxAge, config.StaticDir == "")
+	if err != nil {
+		log.Fatalf("Error loading static files: %v", err)
+	}
+	r.Handle("/", frontend.App()).Methods("GET", "HEAD")
+	r.PathPrefix("/a/").Handler(frontend.App()).Methods("GET", "HEAD")
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", frontend.Assets())).Methods("GET", "HEAD")
+	r.PathPrefix(media.PathPrefix).Handler(media.FileServer(blobStore)).Methods("GET", "HEAD")
 
-	log.Println("starting server at :8080")
-	err = http.ListenAndServe(":8080", middleWares)
+	graphQLHandler, err := handlers.NewGraphQLHandler(postsHandler, userRepo, config.GraphQL.MaxComplexity)
 	if err != nil {
-		panic(err)
+		log.Fatalf("Error building GraphQL schema: %v", err)
 	}
+
+	// Маршруты API описаны одной таблицей: по ней же строится спецификация OpenAPI,
+	// по которой проверяются запросы.
+	routes := handlers.Routes(userHandler, postsHandler, graphQLHandler)
+	spec, err := handlers.NewSpec(routes)
+	if err != nil {
+		log.Fatalf("Error building OpenAPI spec: %v", err)
+	}
+	handlers.Register(r, spec, routes, logger)
+
+	middleWares := middleware.Compress(r)
+	middleWares = middleware.AccessLog(logger, middleWares)
+	middleWares = middleware.RequestID(logger, middleWares)
+
+	server := &http.Server{Addr: ":8080", Handler: middleWares}
+	// Потоки событий живут, пока их не закроют, поэтому при остановке закрываем их сами.
+	server.RegisterOnShutdown(closeHub)
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Println("starting server at :8080")
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// gRPC API для других сервисов слушает свой порт рядом с HTTP.
+	var grpcServer *grpc.Server
+	grpcErr := make(chan error, 1)
+	if config.GRPC.Addr != "" {
+		grpcServer, err = grpcapi.NewGRPCServer(grpcapi.NewServer(postsRepo, userRepo, logger), grpcapi.Config{
+			Tokens:       config.GRPC.Tokens,
+			CertFile:     config.GRPC.CertFile,
+			KeyFile:      config.GRPC.KeyFile,
+			ClientCAFile: config.GRPC.ClientCAFile,
+		}, logger)
+		if err != nil {
+			log.Fatalf("Error creating gRPC server: %v", err)
+		}
+		listener, err := net.Listen("tcp", config.GRPC.Addr)
+		if err != nil {
+			log.Fatalf("Error listening for gRPC: %v", err)
+		}
+		go func() {
+			log.Printf("starting gRPC server at %s", config.GRPC.Addr)
+			grpcErr <- grpcServer.Serve(listener)
+		}()
+	}
+
+	// Останавливаемся по сигналу: дожидаемся текущих запросов, сбрасываем накопленные просмотры
+	// и разбираем очередь уведомлений.
+	stop, cancelSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancelSignals()
+	select {
+	case err = <-serverErr:
+		log.Printf("Server stopped: %v", err)
+	case err = <-grpcErr:
+		log.Printf("gRPC server stopped: %v", err)
+	case <-stop.Done():
+		shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		if err = server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shutdown server: %v", err)
+		}
+		cancel()
+	}
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+
+	stopViews()
+	stopNotifications()
+	stopPurge()
+	<-viewsDone
+	notificationsDone.Wait()
+	<-purgeDone
 }
@@ -0,0 +1,283 @@
+// redditctl - консоль администратора: пользователи, сессии, посты и статистика хранилищ.
+//
+//	redditctl user create -name ivan [-password ...]
+//	redditctl user ban|unban -name ivan
+//	redditctl user reset-password -name ivan [-password ...]
+//	redditctl sessions list -user ivan
+//	redditctl sessions kill -user ivan [-id ...]
+//	redditctl posts delete [-author ivan] [-category music]
+//	redditctl posts recount
+//	redditctl stats
+//
+// У каждой команды есть -dry-run (ничего не менять, показать, что было бы сделано) и -json.
+// Подключения берутся из того же окружения и .env, что и у сервера.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"redditclone/configs"
+	"redditclone/internal/admin"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"redditclone/internal/user"
+	"sort"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gomodule/redigo/redis"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// store - хранилища, которые нужны команде. Открываются только они.
+type store int
+
+const (
+	storeMySQL store = 1 << iota
+	storeRedis
+	storeMongo
+)
+
+// collections - коллекции базы golang, по которым печатается статистика.
+var collections = []string{"posts", "collections", "subscriptions", "notifications", "reports", "moderation_log"}
+
+type runFunc func(ctx context.Context, a *admin.Admin) (fmt.Stringer, error)
+
+type command struct {
+	needs store
+	// required - обязательные флаги, проверяются до подключения к хранилищам.
+	required []string
+	// setup объявляет флаги команды и возвращает, что выполнить после их разбора.
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+var commands = map[string]command{
+	"user create": {storeMySQL, []string{"name"}, func(fs *flag.FlagSet) runFunc {
+		name := fs.String("name", "", "login (required)")
+		password := fs.String("password", "", "password; generated and printed if empty")
+		return func(ctx context.Context, a *admin.Admin) (fmt.Stringer, error) {
+			return a.CreateUser(ctx, *name, *password)
+		}
+	}},
+	"user ban":   {storeMySQL | storeRedis, []string{"name"}, setBanned(true)},
+	"user unban": {storeMySQL | storeRedis, []string{"name"}, setBanned(false)},
+	"user reset-password": {storeMySQL | storeRedis, []string{"name"}, func(fs *flag.FlagSet) runFunc {
+		name := fs.String("name", "", "login (required)")
+		password := fs.String("password", "", "new password; generated and printed if empty")
+		return func(ctx context.Context, a *admin.Admin) (fmt.Stringer, error) {
+			return a.ResetPassword(ctx, *name, *password)
+		}
+	}},
+	"sessions list": {storeMySQL | storeRedis, []string{"user"}, func(fs *flag.FlagSet) runFunc {
+		name := fs.String("user", "", "login (required)")
+		return func(ctx context.Context, a *admin.Admin) (fmt.Stringer, error) {
+			return a.ListSessions(ctx, *name)
+		}
+	}},
+	"sessions kill": {storeMySQL | storeRedis, []string{"user"}, func(fs *flag.FlagSet) runFunc {
+		name := fs.String("user", "", "login (required)")
+		id := fs.String("id", "", "session id; all sessions of the user if empty")
+		return func(ctx context.Context, a *admin.Admin) (fmt.Stringer, error) {
+			return a.KillSessions(ctx, *name, *id)
+		}
+	}},
+	"posts delete": {storeMongo, nil, func(fs *flag.FlagSet) runFunc {
+		// Без обоих флагов Admin откажет с ErrEmptyScope.
+		var scope posts.Scope
+		fs.StringVar(&scope.Author, "author", "", "author login")
+		fs.StringVar(&scope.Category, "category", "", "category")
+		return func(ctx context.Context, a *admin.Admin) (fmt.Stringer, error) {
+			return a.DeletePosts(ctx, scope)
+		}
+	}},
+	"posts recount": {storeMongo, nil, func(fs *flag.FlagSet) runFunc {
+		return func(ctx context.Context, a *admin.Admin) (fmt.Stringer, error) {
+			return a.RecountVotes(ctx)
+		}
+	}},
+	"stats": {storeMongo, nil, func(fs *flag.FlagSet) runFunc {
+		return func(ctx context.Context, a *admin.Admin) (fmt.Stringer, error) {
+			return a.CollectionStats(ctx)
+		}
+	}},
+}
+
+func setBanned(banned bool) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		name := fs.String("name", "", "login (required)")
+		return func(ctx context.Context, a *admin.Admin) (fmt.Stringer, error) {
+			return a.SetBanned(ctx, *name, banned)
+		}
+	}
+}
+
+// checkRequired печатает подсказку, если не задан обязательный флаг.
+func checkRequired(fs *flag.FlagSet, names []string) bool {
+	for _, name := range names {
+		if fs.Lookup(name).Value.String() == "" {
+			fmt.Fprintf(fs.Output(), "-%s is required\n", name)
+			fs.Usage()
+			return false
+		}
+	}
+	return true
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	name, args := commandName(args)
+	cmd, ok := commands[name]
+	if !ok {
+		usage()
+		return 2
+	}
+
+	fs := flag.NewFlagSet("redditctl "+name, flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "show what would be done without changing anything")
+	jsonOut := fs.Bool("json", false, "print the result as JSON")
+	runCmd := cmd.setup(fs)
+	if err := fs.Parse(args); err != nil || !checkRequired(fs, cmd.required) {
+		return 2
+	}
+
+	config, err := configs.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "redditctl: loading config:", err)
+		return 1
+	}
+	ctx := context.Background()
+	a, closeStores, err := connect(ctx, config, cmd.needs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "redditctl:", err)
+		return 1
+	}
+	defer closeStores()
+	a.DryRun = *dryRun
+
+	res, err := runCmd(ctx, a)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "redditctl:", err)
+		return 1
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(res)
+	} else {
+		_, err = fmt.Println(res)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "redditctl:", err)
+		return 1
+	}
+	return 0
+}
+
+// commandName отделяет имя команды из одного или двух слов от её флагов.
+func commandName(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	if _, ok := commands[args[0]]; ok {
+		return args[0], args[1:]
+	}
+	if len(args) > 1 {
+		return args[0] + " " + args[1], args[2:]
+	}
+	return args[0], nil
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "usage: redditctl <command> [-dry-run] [-json] [flags]\n\ncommands:\n  %s\n\n"+
+		"run redditctl <command> -h for command flags\n", strings.Join(names, "\n  "))
+}
+
+// connect открывает нужные команде хранилища. Остальные поля Admin остаются пустыми.
+func connect(ctx context.Context, config configs.Config, needs store) (*admin.Admin, func(), error) {
+	a := &admin.Admin{}
+	var closers []func()
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	if needs&storeMySQL != 0 {
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
+			config.MySQL.User,
+			config.MySQL.Password,
+			config.MySQL.Host,
+			config.MySQL.Port,
+			config.MySQL.Name)
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("connecting to mysql: %w", err)
+		}
+		closers = append(closers, func() { db.Close() })
+		if err = db.PingContext(ctx); err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("connecting to mysql: %w", err)
+		}
+		users := user.NewMysqlRepo(db)
+		users.Timeout = config.Timeouts.MySQL
+		a.Users = users
+	}
+
+	if needs&storeRedis != 0 {
+		redisAddr := fmt.Sprintf("redis://%s:@%s:%d/0", config.Redis.User, config.Redis.Host, config.Redis.Port)
+		pool := &redis.Pool{
+			DialContext: func(ctx context.Context) (redis.Conn, error) {
+				return redis.DialURLContext(ctx, redisAddr)
+			},
+		}
+		closers = append(closers, func() { pool.Close() })
+		conn, err := pool.GetContext(ctx)
+		if err == nil {
+			_, err = redis.DoContext(conn, ctx, "PING")
+			conn.Close()
+		}
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("connecting to redis: %w", err)
+		}
+		sessManager := sessions.NewSessionManager(pool)
+		sessManager.Timeout = config.Timeouts.Redis
+		a.Sessions = sessManager
+	}
+
+	if needs&storeMongo != 0 {
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://"+config.MongoDB.Host))
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("connecting to mongodb: %w", err)
+		}
+		closers = append(closers, func() { client.Disconnect(ctx) })
+		if err = client.Ping(ctx, nil); err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("connecting to mongodb: %w", err)
+		}
+		db := client.Database("golang")
+		postsRepo := posts.NewMongoRepo(db.Collection("posts"))
+		postsRepo.ReadTimeout = config.Timeouts.MongoRead
+		postsRepo.WriteTimeout = config.Timeouts.MongoWrite
+		a.Posts = postsRepo
+		a.Stats = &admin.MongoStats{DB: db, Collections: collections}
+	}
+
+	return a, closeAll, nil
+}
@@ -0,0 +1,202 @@
+// redditload наполняет базу тестовыми данными и нагружает запущенный сервер.
+//
+//	redditload seed [-users 100] [-posts 1000] [-comments 5] [-votes 20]
+//	redditload run -url http://localhost:8080 [-mix browse=80,vote=15,comment=5] [-workers 20] [-duration 1m]
+//
+// seed пишет в MySQL и MongoDB из того же окружения и .env, что и сервер. run входит под
+// пользователями seed, поэтому -users, -prefix и -password у команд должны совпадать.
+// Для прогона записей лимиты частоты на сервере стоит поднять (RATELIMIT_*), иначе в отчёте будут 429.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"redditclone/configs"
+	"redditclone/internal/loadtest"
+	"redditclone/internal/posts"
+	"redditclone/internal/user"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	// Ctrl+C останавливает прогон, а отчёт по уже сделанным запросам всё равно печатается.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var code int
+	switch os.Args[1] {
+	case "seed":
+		code = seed(ctx, os.Args[2:])
+	case "run":
+		code = run(ctx, os.Args[2:])
+	default:
+		usage()
+		code = 2
+	}
+	os.Exit(code)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: redditload seed|run [flags]\n\nrun redditload <command> -h for command flags")
+}
+
+// userFlags - флаги, общие для seed и run: под какими пользователями работать.
+func userFlags(fs *flag.FlagSet) (users *int, prefix, password *string) {
+	users = fs.Int("users", 100, "number of seeded users")
+	prefix = fs.String("prefix", "load", "seeded users are named <prefix>1..<prefix>N")
+	password = fs.String("password", "loadtest", "password of seeded users")
+	return users, prefix, password
+}
+
+func seed(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("redditload seed", flag.ContinueOnError)
+	cfg := loadtest.SeedConfig{}
+	users, prefix, password := userFlags(fs)
+	fs.IntVar(&cfg.Posts, "posts", 1000, "number of posts")
+	fs.Float64Var(&cfg.CommentsPerPost, "comments", 5, "mean comments per post")
+	fs.Float64Var(&cfg.VotesPerPost, "votes", 20, "mean votes per post")
+	categories := fs.String("categories", strings.Join(loadtest.Categories, ","), "comma-separated categories")
+	fs.IntVar(&cfg.Workers, "workers", 8, "parallel writers")
+	fs.Int64Var(&cfg.Seed, "seed", time.Now().UnixNano(), "random seed, the same seed gives the same data shape")
+	jsonOut := fs.Bool("json", false, "print the result as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg.Users, cfg.UserPrefix, cfg.Password = *users, *prefix, *password
+	cfg.Categories = strings.Split(*categories, ",")
+
+	config, err := configs.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "redditload: loading config:", err)
+		return 1
+	}
+	seeder, closeStores, err := connect(ctx, config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "redditload:", err)
+		return 1
+	}
+	defer closeStores()
+	seeder.Config = cfg
+
+	res, err := seeder.Run(ctx)
+	if res != nil {
+		printResult(res, *jsonOut)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "redditload:", err)
+		return 1
+	}
+	return 0
+}
+
+func run(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("redditload run", flag.ContinueOnError)
+	cfg := loadtest.LoadConfig{}
+	users, prefix, password := userFlags(fs)
+	fs.StringVar(&cfg.BaseURL, "url", "http://localhost:8080", "server address")
+	mix := fs.String("mix", "browse=80,vote=15,comment=5", "scenario weights")
+	fs.IntVar(&cfg.Workers, "workers", 20, "concurrent virtual users")
+	fs.DurationVar(&cfg.Duration, "duration", time.Minute, "how long to run, 0 - until -iterations are done")
+	fs.IntVar(&cfg.Iterations, "iterations", 0, "how many scenario steps to run, 0 - until -duration passes")
+	fs.Int64Var(&cfg.Seed, "seed", time.Now().UnixNano(), "random seed")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of one request")
+	maxErrorRate := fs.Float64("max-error-rate", 0.01, "exit with status 1 if the error rate is higher, e.g. to fail CI")
+	jsonOut := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg.Users, cfg.UserPrefix, cfg.Password = *users, *prefix, *password
+	var err error
+	if cfg.Mix, err = loadtest.ParseMix(*mix); err != nil {
+		fmt.Fprintln(os.Stderr, "redditload:", err)
+		return 2
+	}
+
+	runner := &loadtest.Runner{Client: newClient(*timeout, cfg.Workers), Config: cfg}
+	report, err := runner.Run(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "redditload:", err)
+		return 1
+	}
+	printResult(report, *jsonOut)
+	if report.Total.ErrorRate > *maxErrorRate {
+		fmt.Fprintf(os.Stderr, "redditload: error rate %.2f%% is above %.2f%%\n", report.Total.ErrorRate*100, *maxErrorRate*100)
+		return 1
+	}
+	return 0
+}
+
+func printResult(v fmt.Stringer, jsonOut bool) {
+	if !jsonOut {
+		fmt.Println(v)
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, "redditload:", err)
+	}
+}
+
+// newClient держит открытыми соединения всех воркеров: по умолчанию на хост остаётся два,
+// и остальные воркеры мерили бы ещё и установку соединения.
+func newClient(timeout time.Duration, workers int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = workers
+	transport.MaxIdleConnsPerHost = workers
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// connect открывает MySQL и MongoDB для посева.
+func connect(ctx context.Context, config configs.Config) (*loadtest.Seeder, func(), error) {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
+		config.MySQL.User,
+		config.MySQL.Password,
+		config.MySQL.Host,
+		config.MySQL.Port,
+		config.MySQL.Name)
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to mysql: %w", err)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("connecting to mysql: %w", err)
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://"+config.MongoDB.Host))
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("connecting to mongodb: %w", err)
+	}
+	closeAll := func() {
+		client.Disconnect(context.Background())
+		db.Close()
+	}
+	if err = client.Ping(ctx, nil); err != nil {
+		closeAll()
+		return nil, nil, fmt.Errorf("connecting to mongodb: %w", err)
+	}
+
+	users := user.NewMysqlRepo(db)
+	users.Timeout = config.Timeouts.MySQL
+	postsRepo := posts.NewMongoRepo(client.Database("golang").Collection("posts"))
+	postsRepo.ReadTimeout = config.Timeouts.MongoRead
+	postsRepo.WriteTimeout = config.Timeouts.MongoWrite
+	return &loadtest.Seeder{Users: users, Posts: postsRepo}, closeAll, nil
+}
@@ -3,7 +3,10 @@ package configs
 import (
 	"log"
 	"os"
+	"redditclone/internal/ratelimit"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -24,6 +27,95 @@ type Config struct {
 	MongoDB struct {
 		Host string
 	}
+	// Таймауты на одну операцию с хранилищами.
+	Timeouts struct {
+		MongoRead  time.Duration
+		MongoWrite time.Duration
+		MySQL      time.Duration
+		Redis      time.Duration
+	}
+	RateLimit struct {
+		// Store - где хранить корзины: memory или redis.
+		Store      string
+		TrustProxy bool
+		Budgets    map[string]ratelimit.Budget
+		// Сколько раз подряд можно отправить один и тот же комментарий за окно.
+		DuplicateLimit  int
+		DuplicateWindow time.Duration
+	}
+	Views struct {
+		// Store - где помнить зрителей: memory или redis (HyperLogLog).
+		Store string
+		// Один зритель засчитывается один раз за окно.
+		Window        time.Duration
+		FlushInterval time.Duration
+	}
+	PostsCache struct {
+		// Store - где кешировать посты и списки: none, memory (LRU на Size записей, свой у каждого инстанса) или redis.
+		Store string
+		Size  int
+		// TTL - сколько живёт запись, если её не сбросила запись в пост.
+		TTL time.Duration
+	}
+	Retention struct {
+		// Period - сколько удалённые посты и комментарии можно восстановить до окончательного удаления.
+		Period        time.Duration
+		PurgeInterval time.Duration
+	}
+	Media struct {
+		// Store - где хранить картинки: local (каталог Dir) или s3.
+		Store     string
+		Dir       string
+		MaxBytes  int
+		MaxPixels int
+		ThumbSize int
+		S3        struct {
+			Endpoint  string
+			Region    string
+			Bucket    string
+			AccessKey string
+			SecretKey string
+			UseSSL    bool
+		}
+	}
+	// DuplicateLinkWindow - за какой срок искать прошлые публикации ссылки, 0 - не искать.
+	DuplicateLinkWindow time.Duration
+	// StaticMaxAge - сколько браузер кеширует файлы из /static/ без хеша в имени.
+	StaticMaxAge time.Duration
+	// StaticDir - каталог фронтенда на диске вместо встроенного в бинарник, для разработки.
+	StaticDir string
+	// Moderators - логины модераторов через запятую.
+	Moderators []string
+	Realtime   struct {
+		// Store - как рассылать события: memory (один инстанс) или redis (pub/sub между инстансами).
+		Store string
+	}
+	GraphQL struct {
+		// MaxComplexity - предельная стоимость запроса к /api/graphql, 0 - без ограничения.
+		MaxComplexity int
+	}
+	GRPC struct {
+		// Addr - где слушать gRPC API для других сервисов, например :9090. Пусто - не запускать.
+		Addr string
+		// Tokens - общие токены сервисов через запятую.
+		Tokens []string
+		// Сертификат сервера и CA клиентских сертификатов для mTLS.
+		CertFile     string
+		KeyFile      string
+		ClientCAFile string
+	}
+	Notifications struct {
+		// Buffer - длина очереди событий. При переполнении события теряются.
+		Buffer  int
+		Workers int
+	}
+	Tracing struct {
+		ServiceName string
+		Exporter    string
+		Endpoint    string
+		Insecure    bool
+		SampleRatio float64
+	}
 }
 
 func LoadConfig() (Config, error) {
@@ -45,9 +137,94 @@ func LoadConfig() (Config, error) {
 
 	config.MongoDB.Host = os.Getenv("MONGODB_HOST")
 
+	config.Timeouts.MongoRead = getEnvAsDuration("MONGODB_READ_TIMEOUT", 3*time.Second)
+	config.Timeouts.MongoWrite = getEnvAsDuration("MONGODB_WRITE_TIMEOUT", 5*time.Second)
+	config.Timeouts.MySQL = getEnvAsDuration("MYSQL_TIMEOUT", 3*time.Second)
+	config.Timeouts.Redis = getEnvAsDuration("REDIS_TIMEOUT", time.Second)
+
+	config.RateLimit.Store = getEnv("RATELIMIT_STORE", "memory")
+	config.RateLimit.TrustProxy = getEnvAsBool("RATELIMIT_TRUST_PROXY", false)
+	config.RateLimit.DuplicateLimit = getEnvAsInt("DUPLICATE_COMMENT_LIMIT", 3)
+	config.RateLimit.DuplicateWindow = getEnvAsDuration("DUPLICATE_COMMENT_WINDOW", 10*time.Minute)
+	config.RateLimit.Budgets = map[string]ratelimit.Budget{}
+	defaultBudgets := map[string][2]string{
+		ratelimit.RoutePost:    {"5/1m", "20/1m"},
+		ratelimit.RouteComment: {"10/1m", "40/1m"},
+		ratelimit.RouteVote:    {"60/1m", "200/1m"},
+		ratelimit.RouteReport:  {"10/1h", "30/1h"},
+	}
+	for route, defaults := range defaultBudgets {
+		env := "RATELIMIT_" + strings.ToUpper(route)
+		userLimit, err := ratelimit.ParseLimit(getEnv(env+"_USER", defaults[0]))
+		if err != nil {
+			return config, err
+		}
+		ipLimit, err := ratelimit.ParseLimit(getEnv(env+"_IP", defaults[1]))
+		if err != nil {
+			return config, err
+		}
+		config.RateLimit.Budgets[route] = ratelimit.Budget{User: userLimit, IP: ipLimit}
+	}
+
+	config.Views.Store = getEnv("VIEWS_STORE", "memory")
+	config.Views.Window = getEnvAsDuration("VIEWS_WINDOW", time.Hour)
+	config.Views.FlushInterval = getEnvAsDuration("VIEWS_FLUSH_INTERVAL", 10*time.Second)
+
+	config.PostsCache.Store = getEnv("POSTS_CACHE_STORE", "memory")
+	config.PostsCache.Size = getEnvAsInt("POSTS_CACHE_SIZE", 1000)
+	config.PostsCache.TTL = getEnvAsDuration("POSTS_CACHE_TTL", time.Minute)
+
+	config.Retention.Period = getEnvAsDuration("DELETED_RETENTION", 30*24*time.Hour)
+	config.Retention.PurgeInterval = getEnvAsDuration("PURGE_INTERVAL", time.Hour)
+
+	config.Media.Store = getEnv("MEDIA_STORE", "local")
+	config.Media.Dir = getEnv("MEDIA_DIR", "../../uploads")
+	config.Media.MaxBytes = getEnvAsInt("MEDIA_MAX_BYTES", 10<<20)
+	config.Media.MaxPixels = getEnvAsInt("MEDIA_MAX_PIXELS", 40_000_000)
+	config.Media.ThumbSize = getEnvAsInt("MEDIA_THUMB_SIZE", 320)
+	config.Media.S3.Endpoint = os.Getenv("S3_ENDPOINT")
+	config.Media.S3.Region = getEnv("S3_REGION", "us-east-1")
+	config.Media.S3.Bucket = getEnv("S3_BUCKET", "redditclone-media")
+	config.Media.S3.AccessKey = os.Getenv("S3_ACCESS_KEY")
+	config.Media.S3.SecretKey = os.Getenv("S3_SECRET_KEY")
+	config.Media.S3.UseSSL = getEnvAsBool("S3_USE_SSL", true)
+
+	config.DuplicateLinkWindow = getEnvAsDuration("DUPLICATE_LINK_WINDOW", 30*24*time.Hour)
+	config.StaticMaxAge = getEnvAsDuration("STATIC_MAX_AGE", time.Hour)
+	config.StaticDir = os.Getenv("STATIC_DIR")
+
+	config.Moderators = strings.Split(os.Getenv("MODERATORS"), ",")
+
+	config.Realtime.Store = getEnv("REALTIME_STORE", "memory")
+
+	config.GraphQL.MaxComplexity = getEnvAsInt("GRAPHQL_MAX_COMPLEXITY", 1000)
+
+	config.GRPC.Addr = os.Getenv("GRPC_ADDR")
+	config.GRPC.Tokens = strings.Split(os.Getenv("GRPC_TOKENS"), ",")
+	config.GRPC.CertFile = os.Getenv("GRPC_TLS_CERT")
+	config.GRPC.KeyFile = os.Getenv("GRPC_TLS_KEY")
+	config.GRPC.ClientCAFile = os.Getenv("GRPC_CLIENT_CA")
+
+	config.Notifications.Buffer = getEnvAsInt("NOTIFICATIONS_BUFFER", 1024)
+	config.Notifications.Workers = getEnvAsInt("NOTIFICATIONS_WORKERS", 2)
+
+	config.Tracing.ServiceName = getEnv("OTEL_SERVICE_NAME", "redditclone")
+	config.Tracing.Exporter = getEnv("TRACING_EXPORTER", "none")
+	config.Tracing.Endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	config.Tracing.Insecure = getEnvAsBool("OTEL_EXPORTER_OTLP_INSECURE", true)
+	config.Tracing.SampleRatio = getEnvAsFloat("TRACING_SAMPLE_RATIO", 1)
+
 	return config, nil
 }
 
+// getEnv возвращает значение переменной окружения или значение по умолчанию, если она не задана.
+func getEnv(key, defaultVal string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return defaultVal
+}
+
 // getEnvAsInt преобразует переменную окружения в int.
 // Возвращает значение по умолчанию, если переменная не установлена или не может быть преобразована в int.
 func getEnvAsInt(key string, defaultVal int) int {
@@ -57,3 +234,30 @@ func getEnvAsInt(key string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+// getEnvAsFloat преобразует переменную окружения в float64.
+func getEnvAsFloat(key string, defaultVal float64) float64 {
+	valueStr := os.Getenv(key)
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultVal
+}
+
+// getEnvAsBool преобразует переменную окружения в bool.
+func getEnvAsBool(key string, defaultVal bool) bool {
+	valueStr := os.Getenv(key)
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultVal
+}
+
+// getEnvAsDuration преобразует переменную окружения вида "1.5s" или "300ms" в time.Duration.
+func getEnvAsDuration(key string, defaultVal time.Duration) time.Duration {
+	valueStr := os.Getenv(key)
+	if value, err := time.ParseDuration(valueStr); err == nil {
+		return value
+	}
+	return defaultVal
+}
@@ -2,7 +2,9 @@ DROP TABLE IF EXISTS `users`;
 CREATE TABLE `users` (
                          `id` int(11) AUTO_INCREMENT PRIMARY KEY,
                          `username` varchar(200) NOT NULL,
-                         `password` varchar(200) NOT NULL
+                         `password` varchar(200) NOT NULL,
+                         `banned` tinyint(1) NOT NULL DEFAULT 0,
+                         UNIQUE KEY `users_username` (`username`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
 INSERT INTO `users` (`username`, `password`) VALUES
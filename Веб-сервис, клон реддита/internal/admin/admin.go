@@ -0,0 +1,273 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"redditclone/internal/user"
+)
+
+// Операции для redditctl. В режиме DryRun ничего не пишется: только читается то,
+// что нужно для отчёта о том, что было бы сделано.
+
+// generatedPasswordBytes - случайных байт в пароле, если администратор его не задал.
+const generatedPasswordBytes = 12
+
+var ErrNoSession = errors.New("no such session")
+
+//go:generate mockgen -source=admin.go -destination=admin_mock.go -package=admin
+type SessionStore interface {
+	List(ctx context.Context, userID int64) ([]*sessions.Info, error)
+	Revoke(ctx context.Context, userID int64, sessionID string) (bool, error)
+	RevokeAll(ctx context.Context, userID int64) (int, error)
+}
+
+type PostStore interface {
+	CountPosts(ctx context.Context, scope posts.Scope) (int64, error)
+	DeletePosts(ctx context.Context, scope posts.Scope) (int64, error)
+	RecountVotes(ctx context.Context, apply bool) ([]*posts.CounterDrift, error)
+}
+
+type StatsStore interface {
+	Stats(ctx context.Context) (StatsReport, error)
+}
+
+type Admin struct {
+	Users    user.UserRepo
+	Sessions SessionStore
+	Posts    PostStore
+	Stats    StatsStore
+	DryRun   bool
+}
+
+// UserResult - итог операции над пользователем.
+type UserResult struct {
+	User   *user.User `json:"user"`
+	Action string     `json:"action"`
+	// Password - новый пароль, если его сгенерировали.
+	Password        string `json:"password,omitempty"`
+	SessionsRevoked int    `json:"sessionsRevoked"`
+	DryRun          bool   `json:"dryRun"`
+}
+
+func (r *UserResult) String() string {
+	s := fmt.Sprintf("%s %s (id %d)", r.Action, r.User.Username, r.User.ID)
+	if r.Password != "" {
+		s += ", password: " + r.Password
+	}
+	if r.SessionsRevoked > 0 {
+		s += fmt.Sprintf(", sessions revoked: %d", r.SessionsRevoked)
+	}
+	return s + dryRunNote(r.DryRun)
+}
+
+// SessionsResult - итог завершения сессий.
+type SessionsResult struct {
+	User    *user.User `json:"user"`
+	Revoked int        `json:"revoked"`
+	DryRun  bool       `json:"dryRun"`
+}
+
+func (r *SessionsResult) String() string {
+	return fmt.Sprintf("revoked %d sessions of %s", r.Revoked, r.User.Username) + dryRunNote(r.DryRun)
+}
+
+// PostsResult - итог удаления постов.
+type PostsResult struct {
+	Scope   posts.Scope `json:"scope"`
+	Deleted int64       `json:"deleted"`
+	DryRun  bool        `json:"dryRun"`
+}
+
+func (r *PostsResult) String() string {
+	return fmt.Sprintf("deleted %d posts (author %q, category %q)", r.Deleted, r.Scope.Author, r.Scope.Category) +
+		dryRunNote(r.DryRun)
+}
+
+func dryRunNote(dryRun bool) string {
+	if dryRun {
+		return " [dry run]"
+	}
+	return ""
+}
+
+func (a *Admin) CreateUser(ctx context.Context, username, password string) (*UserResult, error) {
+	res := &UserResult{Action: "created", DryRun: a.DryRun}
+	if a.DryRun {
+		_, err := a.Users.GetUser(ctx, username)
+		switch {
+		case err == nil:
+			return nil, fmt.Errorf("user %s: %w", username, user.ErrExists)
+		case !errors.Is(err, user.ErrNoUser):
+			return nil, err
+		}
+		res.User = &user.User{Username: username}
+		return res, nil
+	}
+
+	if password == "" {
+		var err error
+		if password, err = generatePassword(); err != nil {
+			return nil, err
+		}
+		res.Password = password
+	}
+	u, err := a.Users.MakeUser(ctx, username, password)
+	if err != nil {
+		return nil, fmt.Errorf("user %s: %w", username, err)
+	}
+	res.User = &user.User{ID: u.ID, Username: u.Username}
+	return res, nil
+}
+
+// SetBanned блокирует или разблокирует пользователя. Заблокированный сразу теряет все сессии.
+func (a *Admin) SetBanned(ctx context.Context, username string, banned bool) (*UserResult, error) {
+	u, err := a.getUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	res := &UserResult{User: u, Action: "unbanned", DryRun: a.DryRun}
+	if banned {
+		res.Action = "banned"
+	}
+	if a.DryRun {
+		if banned {
+			res.SessionsRevoked, err = a.countSessions(ctx, u.ID)
+		}
+		return res, err
+	}
+
+	if err = a.Users.SetBanned(ctx, username, banned); err != nil {
+		return nil, err
+	}
+	if banned {
+		res.SessionsRevoked, err = a.Sessions.RevokeAll(ctx, u.ID)
+	}
+	return res, err
+}
+
+// ResetPassword задаёт пароль, а если он пустой - генерирует. Старые сессии завершаются:
+// пароль обычно сбрасывают, когда к аккаунту получил доступ кто-то чужой.
+func (a *Admin) ResetPassword(ctx context.Context, username, password string) (*UserResult, error) {
+	u, err := a.getUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	res := &UserResult{User: u, Action: "reset password of", DryRun: a.DryRun}
+	if a.DryRun {
+		res.SessionsRevoked, err = a.countSessions(ctx, u.ID)
+		return res, err
+	}
+
+	if password == "" {
+		if password, err = generatePassword(); err != nil {
+			return nil, err
+		}
+		res.Password = password
+	}
+	if err = a.Users.SetPassword(ctx, username, password); err != nil {
+		return nil, err
+	}
+	res.SessionsRevoked, err = a.Sessions.RevokeAll(ctx, u.ID)
+	return res, err
+}
+
+func (a *Admin) ListSessions(ctx context.Context, username string) (SessionList, error) {
+	u, err := a.getUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return a.Sessions.List(ctx, u.ID)
+}
+
+// KillSessions завершает одну сессию пользователя, а с пустым sessionID - все.
+func (a *Admin) KillSessions(ctx context.Context, username, sessionID string) (*SessionsResult, error) {
+	u, err := a.getUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	res := &SessionsResult{User: u, DryRun: a.DryRun}
+
+	if a.DryRun {
+		list, err := a.Sessions.List(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, sess := range list {
+			if sessionID == "" || sess.ID == sessionID {
+				res.Revoked++
+			}
+		}
+		if sessionID != "" && res.Revoked == 0 {
+			return nil, fmt.Errorf("session %s: %w", sessionID, ErrNoSession)
+		}
+		return res, nil
+	}
+
+	if sessionID == "" {
+		res.Revoked, err = a.Sessions.RevokeAll(ctx, u.ID)
+		return res, err
+	}
+	revoked, err := a.Sessions.Revoke(ctx, u.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		return nil, fmt.Errorf("session %s: %w", sessionID, ErrNoSession)
+	}
+	res.Revoked = 1
+	return res, nil
+}
+
+// DeletePosts удаляет посты автора и/или категории так же, как их удаляет автор: до окончательного
+// удаления их можно восстановить. Кеш постов на серверах сбросится по TTL.
+func (a *Admin) DeletePosts(ctx context.Context, scope posts.Scope) (*PostsResult, error) {
+	if scope.Author == "" && scope.Category == "" {
+		return nil, posts.ErrEmptyScope
+	}
+	res := &PostsResult{Scope: scope, DryRun: a.DryRun}
+	var err error
+	if a.DryRun {
+		res.Deleted, err = a.Posts.CountPosts(ctx, scope)
+	} else {
+		res.Deleted, err = a.Posts.DeletePosts(ctx, scope)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RecountVotes находит посты, у которых Score и остальные счётчики разошлись с Votes, и без DryRun исправляет их.
+func (a *Admin) RecountVotes(ctx context.Context) (DriftReport, error) {
+	return a.Posts.RecountVotes(ctx, !a.DryRun)
+}
+
+func (a *Admin) CollectionStats(ctx context.Context) (StatsReport, error) {
+	return a.Stats.Stats(ctx)
+}
+
+func (a *Admin) getUser(ctx context.Context, username string) (*user.User, error) {
+	u, err := a.Users.GetUser(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("user %s: %w", username, err)
+	}
+	return u, nil
+}
+
+func (a *Admin) countSessions(ctx context.Context, userID int64) (int, error) {
+	list, err := a.Sessions.List(ctx, userID)
+	return len(list), err
+}
+
+func generatePassword() (string, error) {
+	buf := make([]byte, generatedPasswordBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
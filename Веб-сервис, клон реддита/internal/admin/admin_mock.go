@@ -0,0 +1,188 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: admin.go
+
+// Package admin is a generated GoMock package.
+package admin
+
+import (
+	context "context"
+	posts "redditclone/internal/posts"
+	sessions "redditclone/internal/sessions"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockSessionStore is a mock of SessionStore interface.
+type MockSessionStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockSessionStoreMockRecorder
+}
+
+// MockSessionStoreMockRecorder is the mock recorder for MockSessionStore.
+type MockSessionStoreMockRecorder struct {
+	mock *MockSessionStore
+}
+
+// NewMockSessionStore creates a new mock instance.
+func NewMockSessionStore(ctrl *gomock.Controller) *MockSessionStore {
+	mock := &MockSessionStore{ctrl: ctrl}
+	mock.recorder = &MockSessionStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSessionStore) EXPECT() *MockSessionStoreMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *MockSessionStore) List(ctx context.Context, userID int64) ([]*sessions.Info, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, userID)
+	ret0, _ := ret[0].([]*sessions.Info)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockSessionStoreMockRecorder) List(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSessionStore)(nil).List), ctx, userID)
+}
+
+// Revoke mocks base method.
+func (m *MockSessionStore) Revoke(ctx context.Context, userID int64, sessionID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, userID, sessionID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockSessionStoreMockRecorder) Revoke(ctx, userID, sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockSessionStore)(nil).Revoke), ctx, userID, sessionID)
+}
+
+// RevokeAll mocks base method.
+func (m *MockSessionStore) RevokeAll(ctx context.Context, userID int64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAll", ctx, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAll indicates an expected call of RevokeAll.
+func (mr *MockSessionStoreMockRecorder) RevokeAll(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAll", reflect.TypeOf((*MockSessionStore)(nil).RevokeAll), ctx, userID)
+}
+
+// MockPostStore is a mock of PostStore interface.
+type MockPostStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockPostStoreMockRecorder
+}
+
+// MockPostStoreMockRecorder is the mock recorder for MockPostStore.
+type MockPostStoreMockRecorder struct {
+	mock *MockPostStore
+}
+
+// NewMockPostStore creates a new mock instance.
+func NewMockPostStore(ctrl *gomock.Controller) *MockPostStore {
+	mock := &MockPostStore{ctrl: ctrl}
+	mock.recorder = &MockPostStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPostStore) EXPECT() *MockPostStoreMockRecorder {
+	return m.recorder
+}
+
+// CountPosts mocks base method.
+func (m *MockPostStore) CountPosts(ctx context.Context, scope posts.Scope) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountPosts", ctx, scope)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountPosts indicates an expected call of CountPosts.
+func (mr *MockPostStoreMockRecorder) CountPosts(ctx, scope interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountPosts", reflect.TypeOf((*MockPostStore)(nil).CountPosts), ctx, scope)
+}
+
+// DeletePosts mocks base method.
+func (m *MockPostStore) DeletePosts(ctx context.Context, scope posts.Scope) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePosts", ctx, scope)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeletePosts indicates an expected call of DeletePosts.
+func (mr *MockPostStoreMockRecorder) DeletePosts(ctx, scope interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePosts", reflect.TypeOf((*MockPostStore)(nil).DeletePosts), ctx, scope)
+}
+
+// RecountVotes mocks base method.
+func (m *MockPostStore) RecountVotes(ctx context.Context, apply bool) ([]*posts.CounterDrift, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecountVotes", ctx, apply)
+	ret0, _ := ret[0].([]*posts.CounterDrift)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecountVotes indicates an expected call of RecountVotes.
+func (mr *MockPostStoreMockRecorder) RecountVotes(ctx, apply interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecountVotes", reflect.TypeOf((*MockPostStore)(nil).RecountVotes), ctx, apply)
+}
+
+// MockStatsStore is a mock of StatsStore interface.
+type MockStatsStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockStatsStoreMockRecorder
+}
+
+// MockStatsStoreMockRecorder is the mock recorder for MockStatsStore.
+type MockStatsStoreMockRecorder struct {
+	mock *MockStatsStore
+}
+
+// NewMockStatsStore creates a new mock instance.
+func NewMockStatsStore(ctrl *gomock.Controller) *MockStatsStore {
+	mock := &MockStatsStore{ctrl: ctrl}
+	mock.recorder = &MockStatsStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStatsStore) EXPECT() *MockStatsStoreMockRecorder {
+	return m.recorder
+}
+
+// Stats mocks base method.
+func (m *MockStatsStore) Stats(ctx context.Context) (StatsReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", ctx)
+	ret0, _ := ret[0].(StatsReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockStatsStoreMockRecorder) Stats(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockStatsStore)(nil).Stats), ctx)
+}
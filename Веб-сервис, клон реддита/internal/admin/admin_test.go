@@ -0,0 +1,307 @@
+package admin
+
+import (
+	"context"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"redditclone/internal/user"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+var rvasily = &user.User{ID: 1, Username: "rvasily"}
+
+type mocks struct {
+	users    *user.MockUserRepo
+	sessions *MockSessionStore
+	posts    *MockPostStore
+}
+
+func newAdmin(t *testing.T, dryRun bool) (*Admin, mocks) {
+	ctrl := gomock.NewController(t)
+	m := mocks{
+		users:    user.NewMockUserRepo(ctrl),
+		sessions: NewMockSessionStore(ctrl),
+		posts:    NewMockPostStore(ctrl),
+	}
+	return &Admin{Users: m.users, Sessions: m.sessions, Posts: m.posts, DryRun: dryRun}, m
+}
+
+func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Пароль генерируется", func(t *testing.T) {
+		a, m := newAdmin(t, false)
+		var generated string
+		m.users.EXPECT().MakeUser(ctx, "ivan", gomock.Any()).DoAndReturn(
+			func(_ context.Context, username, pass string) (*user.User, error) {
+				generated = pass
+				return &user.User{ID: 7, Username: username, Password: "hash"}, nil
+			})
+
+		res, err := a.CreateUser(ctx, "ivan", "")
+		require.NoError(t, err)
+		assert.Len(t, generated, 16)
+		assert.Equal(t, generated, res.Password)
+		assert.Equal(t, &user.User{ID: 7, Username: "ivan"}, res.User, "hash is not printed")
+	})
+
+	t.Run("Заданный пароль не печатается", func(t *testing.T) {
+		a, m := newAdmin(t, false)
+		m.users.EXPECT().MakeUser(ctx, "ivan", "secret").Return(&user.User{ID: 7, Username: "ivan"}, nil)
+
+		res, err := a.CreateUser(ctx, "ivan", "secret")
+		require.NoError(t, err)
+		assert.Empty(t, res.Password)
+	})
+
+	t.Run("Dry run: логин свободен", func(t *testing.T) {
+		a, m := newAdmin(t, true)
+		m.users.EXPECT().GetUser(ctx, "ivan").Return(nil, user.ErrNoUser)
+
+		res, err := a.CreateUser(ctx, "ivan", "")
+		require.NoError(t, err)
+		assert.True(t, res.DryRun)
+		assert.Empty(t, res.Password)
+	})
+
+	t.Run("Dry run: логин занят", func(t *testing.T) {
+		a, m := newAdmin(t, true)
+		m.users.EXPECT().GetUser(ctx, "rvasily").Return(rvasily, nil)
+
+		_, err := a.CreateUser(ctx, "rvasily", "")
+		assert.ErrorIs(t, err, user.ErrExists)
+	})
+}
+
+func TestSetBanned(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Бан завершает сессии", func(t *testing.T) {
+		a, m := newAdmin(t, false)
+		gomock.InOrder(
+			m.users.EXPECT().GetUser(ctx, "rvasily").Return(rvasily, nil),
+			m.users.EXPECT().SetBanned(ctx, "rvasily", true).Return(nil),
+			m.sessions.EXPECT().RevokeAll(ctx, rvasily.ID).Return(2, nil),
+		)
+
+		res, err := a.SetBanned(ctx, "rvasily", true)
+		require.NoError(t, err)
+		assert.Equal(t, &UserResult{User: rvasily, Action: "banned", SessionsRevoked: 2}, res)
+	})
+
+	t.Run("Разбан сессии не трогает", func(t *testing.T) {
+		a, m := newAdmin(t, false)
+		m.users.EXPECT().GetUser(ctx, "rvasily").Return(rvasily, nil)
+		m.users.EXPECT().SetBanned(ctx, "rvasily", false).Return(nil)
+
+		res, err := a.SetBanned(ctx, "rvasily", false)
+		require.NoError(t, err)
+		assert.Equal(t, "unbanned", res.Action)
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		a, m := newAdmin(t, true)
+		m.users.EXPECT().GetUser(ctx, "rvasily").Return(rvasily, nil)
+		m.sessions.EXPECT().List(ctx, rvasily.ID).Return([]*sessions.Info{{ID: "a"}, {ID: "b"}, {ID: "c"}}, nil)
+
+		res, err := a.SetBanned(ctx, "rvasily", true)
+		require.NoError(t, err)
+		assert.Equal(t, 3, res.SessionsRevoked)
+		assert.Equal(t, "banned rvasily (id 1), sessions revoked: 3 [dry run]", res.String())
+	})
+
+	t.Run("Нет пользователя", func(t *testing.T) {
+		a, m := newAdmin(t, false)
+		m.users.EXPECT().GetUser(ctx, "nobody").Return(nil, user.ErrNoUser)
+
+		_, err := a.SetBanned(ctx, "nobody", true)
+		assert.ErrorIs(t, err, user.ErrNoUser)
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Новый пароль, старые сессии завершены", func(t *testing.T) {
+		a, m := newAdmin(t, false)
+		m.users.EXPECT().GetUser(ctx, "rvasily").Return(rvasily, nil)
+		m.users.EXPECT().SetPassword(ctx, "rvasily", gomock.Any()).Return(nil)
+		m.sessions.EXPECT().RevokeAll(ctx, rvasily.ID).Return(1, nil)
+
+		res, err := a.ResetPassword(ctx, "rvasily", "")
+		require.NoError(t, err)
+		assert.NotEmpty(t, res.Password)
+		assert.Equal(t, 1, res.SessionsRevoked)
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		a, m := newAdmin(t, true)
+		m.users.EXPECT().GetUser(ctx, "rvasily").Return(rvasily, nil)
+		m.sessions.EXPECT().List(ctx, rvasily.ID).Return(nil, nil)
+
+		res, err := a.ResetPassword(ctx, "rvasily", "")
+		require.NoError(t, err)
+		assert.Empty(t, res.Password)
+	})
+}
+
+func TestKillSessions(t *testing.T) {
+	ctx := context.Background()
+	live := []*sessions.Info{{ID: "a"}, {ID: "b"}}
+
+	testCases := []struct {
+		name      string
+		dryRun    bool
+		sessionID string
+		setup     func(m mocks)
+		want      int
+		wantErr   error
+	}{
+		{
+			name: "Все сессии",
+			setup: func(m mocks) {
+				m.sessions.EXPECT().RevokeAll(ctx, rvasily.ID).Return(2, nil)
+			},
+			want: 2,
+		},
+		{
+			name:      "Одна сессия",
+			sessionID: "a",
+			setup: func(m mocks) {
+				m.sessions.EXPECT().Revoke(ctx, rvasily.ID, "a").Return(true, nil)
+			},
+			want: 1,
+		},
+		{
+			name:      "Чужая или протухшая сессия",
+			sessionID: "z",
+			setup: func(m mocks) {
+				m.sessions.EXPECT().Revoke(ctx, rvasily.ID, "z").Return(false, nil)
+			},
+			wantErr: ErrNoSession,
+		},
+		{
+			name:   "Dry run: все",
+			dryRun: true,
+			setup: func(m mocks) {
+				m.sessions.EXPECT().List(ctx, rvasily.ID).Return(live, nil)
+			},
+			want: 2,
+		},
+		{
+			name:      "Dry run: нет такой сессии",
+			dryRun:    true,
+			sessionID: "z",
+			setup: func(m mocks) {
+				m.sessions.EXPECT().List(ctx, rvasily.ID).Return(live, nil)
+			},
+			wantErr: ErrNoSession,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, m := newAdmin(t, tc.dryRun)
+			m.users.EXPECT().GetUser(ctx, "rvasily").Return(rvasily, nil)
+			tc.setup(m)
+
+			res, err := a.KillSessions(ctx, "rvasily", tc.sessionID)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, res.Revoked)
+		})
+	}
+}
+
+func TestDeletePosts(t *testing.T) {
+	ctx := context.Background()
+	scope := posts.Scope{Author: "spammer"}
+
+	t.Run("Удаление", func(t *testing.T) {
+		a, m := newAdmin(t, false)
+		m.posts.EXPECT().DeletePosts(ctx, scope).Return(int64(5), nil)
+
+		res, err := a.DeletePosts(ctx, scope)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), res.Deleted)
+	})
+
+	t.Run("Dry run только считает", func(t *testing.T) {
+		a, m := newAdmin(t, true)
+		m.posts.EXPECT().CountPosts(ctx, scope).Return(int64(5), nil)
+
+		res, err := a.DeletePosts(ctx, scope)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), res.Deleted)
+		assert.True(t, res.DryRun)
+	})
+
+	t.Run("Пустая область", func(t *testing.T) {
+		a, _ := newAdmin(t, false)
+
+		_, err := a.DeletePosts(ctx, posts.Scope{})
+		assert.ErrorIs(t, err, posts.ErrEmptyScope)
+	})
+}
+
+func TestRecountVotes(t *testing.T) {
+	ctx := context.Background()
+
+	for _, dryRun := range []bool{false, true} {
+		a, m := newAdmin(t, dryRun)
+		m.posts.EXPECT().RecountVotes(ctx, !dryRun).Return([]*posts.CounterDrift{}, nil)
+
+		_, err := a.RecountVotes(ctx)
+		require.NoError(t, err)
+	}
+}
+
+func TestMongoStats(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Статистика коллекций", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(
+				bson.E{Key: "count", Value: 10}, bson.E{Key: "size", Value: int64(2048)},
+				bson.E{Key: "storageSize", Value: 4096}, bson.E{Key: "nindexes", Value: 3},
+				bson.E{Key: "totalIndexSize", Value: 1024},
+			),
+			mtest.CreateSuccessResponse(bson.E{Key: "count", Value: 0}),
+		)
+		stats := &MongoStats{DB: mt.DB, Collections: []string{"posts", "reports"}}
+
+		report, err := stats.Stats(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, StatsReport{
+			{Name: "posts", Count: 10, Size: 2048, StorageSize: 4096, Indexes: 3, IndexSize: 1024},
+			{Name: "reports"},
+		}, report)
+		assert.Equal(t, "posts", mt.GetStartedEvent().Command.Lookup("collStats").StringValue())
+	})
+
+	mt.Run("Ошибка", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+		stats := &MongoStats{DB: mt.DB, Collections: []string{"posts"}}
+
+		_, err := stats.Stats(context.Background())
+		assert.ErrorContains(t, err, "collection posts")
+	})
+}
+
+func TestStatsReportString(t *testing.T) {
+	report := StatsReport{{Name: "posts", Count: 10, Size: 2048}}
+
+	assert.Equal(t,
+		"COLLECTION  DOCUMENTS  SIZE  STORAGE  INDEXES  INDEX SIZE\n"+
+			"posts       10         2048  0        0        0",
+		report.String())
+}
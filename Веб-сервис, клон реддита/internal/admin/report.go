@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Списки в текстовом выводе печатаются таблицей.
+
+type SessionList []*sessions.Info
+
+func (l SessionList) String() string {
+	return table([]string{"ID", "LOGIN", "USERAGENT", "EXPIRES"}, len(l), func(i int) []interface{} {
+		return []interface{}{l[i].ID, l[i].Login, l[i].Useragent, l[i].Expires.Format(time.RFC3339)}
+	})
+}
+
+type DriftReport []*posts.CounterDrift
+
+func (r DriftReport) String() string {
+	return table([]string{"POST", "SCORE", "VOTES", "UPVOTE%", "FIXED", "TITLE"}, len(r), func(i int) []interface{} {
+		d := r[i]
+		return []interface{}{
+			d.PostID.Hex(),
+			fmt.Sprintf("%d -> %d", d.Stored.Score, d.Actual.Score),
+			fmt.Sprintf("%d -> %d", d.Stored.VoteCount, d.Actual.VoteCount),
+			fmt.Sprintf("%d -> %d", d.Stored.UpvotePercentage, d.Actual.UpvotePercentage),
+			d.Fixed,
+			d.Title,
+		}
+	})
+}
+
+// CollectionStats - размеры коллекции по команде collStats, в байтах.
+type CollectionStats struct {
+	Name        string `json:"name"`
+	Count       int64  `json:"count"`
+	Size        int64  `json:"size"`
+	StorageSize int64  `json:"storageSize"`
+	Indexes     int64  `json:"indexes"`
+	IndexSize   int64  `json:"indexSize"`
+}
+
+type StatsReport []*CollectionStats
+
+func (r StatsReport) String() string {
+	return table([]string{"COLLECTION", "DOCUMENTS", "SIZE", "STORAGE", "INDEXES", "INDEX SIZE"}, len(r), func(i int) []interface{} {
+		s := r[i]
+		return []interface{}{s.Name, s.Count, s.Size, s.StorageSize, s.Indexes, s.IndexSize}
+	})
+}
+
+func table(header []string, rows int, row func(i int) []interface{}) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for i := 0; i < rows; i++ {
+		cells := row(i)
+		for j, cell := range cells {
+			if j > 0 {
+				fmt.Fprint(w, "\t")
+			}
+			fmt.Fprint(w, cell)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// MongoStats собирает статистику коллекций базы.
+type MongoStats struct {
+	DB          *mongo.Database
+	Collections []string
+}
+
+func (s *MongoStats) Stats(ctx context.Context) (StatsReport, error) {
+	report := StatsReport{}
+	for _, name := range s.Collections {
+		var raw struct {
+			Count          int64 `bson:"count"`
+			Size           int64 `bson:"size"`
+			StorageSize    int64 `bson:"storageSize"`
+			NIndexes       int64 `bson:"nindexes"`
+			TotalIndexSize int64 `bson:"totalIndexSize"`
+		}
+		err := s.DB.RunCommand(ctx, bson.D{{Key: "collStats", Value: name}}).Decode(&raw)
+		if err != nil {
+			return nil, fmt.Errorf("collection %s: %w", name, err)
+		}
+		report = append(report, &CollectionStats{
+			Name:        name,
+			Count:       raw.Count,
+			Size:        raw.Size,
+			StorageSize: raw.StorageSize,
+			Indexes:     raw.NIndexes,
+			IndexSize:   raw.TotalIndexSize,
+		})
+	}
+	return report, nil
+}
@@ -0,0 +1,242 @@
+package apispec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+// Operation - один маршрут API. Таблица операций - единственное описание маршрутов:
+// по ней обработчики регистрируются в роутере и по ней же строится спецификация.
+type Operation struct {
+	Method string
+	// Path - путь в синтаксисе mux: {NAME} или {NAME:a|b}, варианты становятся enum параметра.
+	Path    string
+	ID      string
+	Summary string
+	Tag     string
+	// Auth - нужен токен в заголовке Authorization.
+	Auth  bool
+	Query []Param
+	// Body - значение типа тела запроса, схема строится по типу и тегам validate. nil - без тела.
+	Body interface{}
+	// OptionalBody - тело можно не передавать.
+	OptionalBody bool
+	// Multipart - тело может прийти и как multipart/form-data, его проверяет сам обработчик.
+	Multipart bool
+	Status    int
+	// Response - значение типа ответа. nil вместе со Stream - поток text/event-stream.
+	Response interface{}
+	// Files - двоичные типы, которыми может прийти тот же ответ вместо JSON, например application/zip.
+	Files  []string
+	Stream bool
+	// Conditional - ответ отдаётся с ETag, а на совпавший If-None-Match - 304 без тела.
+	Conditional bool
+	Handler     http.HandlerFunc
+}
+
+// Param - параметр строки запроса.
+type Param struct {
+	Name        string
+	Description string
+	Schema      *openapi3.Schema
+}
+
+func IntParam(name, description string, min float64) Param {
+	return Param{Name: name, Description: description, Schema: openapi3.NewIntegerSchema().WithMin(min)}
+}
+
+func BoolParam(name, description string) Param {
+	return Param{Name: name, Description: description, Schema: openapi3.NewBoolSchema()}
+}
+
+func StringParam(name, description string, enum ...string) Param {
+	schema := openapi3.NewStringSchema()
+	for _, v := range enum {
+		schema.Enum = append(schema.Enum, v)
+	}
+	return Param{Name: name, Description: description, Schema: schema}
+}
+
+// ListParam - параметр, который можно повторить: ?post=1&post=2.
+func ListParam(name, description string) Param {
+	return Param{Name: name, Description: description, Schema: openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema())}
+}
+
+// Spec - спецификация OpenAPI 3, собранная из таблицы операций.
+type Spec struct {
+	Doc    *openapi3.T
+	routes map[string]*routers.Route
+	json   []byte
+}
+
+// muxVarRe находит переменные пути mux вместе с необязательным регулярным выражением.
+var muxVarRe = regexp.MustCompile(`\{([A-Za-z_]+)(?::([^}]*))?\}`)
+
+// New собирает спецификацию. errorBody - значение типа тела ответа с ошибкой, общего для всех операций.
+func New(title, version string, errorBody interface{}, ops []Operation) (*Spec, error) {
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info:    &openapi3.Info{Title: title, Version: version},
+		Paths:   openapi3.NewPaths(),
+		Components: &openapi3.Components{
+			Schemas: openapi3.Schemas{},
+			SecuritySchemes: openapi3.SecuritySchemes{
+				"bearerAuth": &openapi3.SecuritySchemeRef{Value: openapi3.NewJWTSecurityScheme()},
+			},
+		},
+	}
+
+	schemas := newSchemas(doc.Components.Schemas)
+	errorRef, err := schemas.schemaFor(errorBody)
+	if err != nil {
+		return nil, fmt.Errorf("error schema: %w", err)
+	}
+
+	spec := &Spec{Doc: doc, routes: make(map[string]*routers.Route, len(ops))}
+	for i := range ops {
+		op := &ops[i]
+		path, pathParams := openAPIPath(op.Path)
+		operation, err := newOperation(op, pathParams, schemas, errorRef)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Method, op.Path, err)
+		}
+
+		item := doc.Paths.Value(path)
+		if item == nil {
+			item = &openapi3.PathItem{}
+			doc.Paths.Set(path, item)
+		}
+		if item.GetOperation(op.Method) != nil {
+			return nil, fmt.Errorf("%s %s: duplicate operation", op.Method, op.Path)
+		}
+		item.SetOperation(op.Method, operation)
+		spec.routes[routeKey(op.Method, op.Path)] = &routers.Route{
+			Spec:      doc,
+			Path:      path,
+			PathItem:  item,
+			Method:    op.Method,
+			Operation: operation,
+		}
+	}
+
+	if err := doc.Validate(openapi3.NewLoader().Context); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	if spec.json, err = json.Marshal(doc); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// ServeHTTP отдаёт спецификацию в JSON.
+func (s *Spec) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(s.json)
+}
+
+// Route возвращает маршрут спецификации для операции с таким методом и путём mux.
+func (s *Spec) Route(method, muxPath string) *routers.Route {
+	return s.routes[routeKey(method, muxPath)]
+}
+
+func routeKey(method, muxPath string) string {
+	return method + " " + muxPath
+}
+
+// openAPIPath переводит путь mux в путь OpenAPI и описывает его переменные.
+// Регулярное выражение вида a|b|c превращается в enum.
+func openAPIPath(muxPath string) (string, openapi3.Parameters) {
+	var params openapi3.Parameters
+	path := muxVarRe.ReplaceAllStringFunc(muxPath, func(v string) string {
+		m := muxVarRe.FindStringSubmatch(v)
+		schema := openapi3.NewStringSchema()
+		if m[2] != "" {
+			for _, option := range strings.Split(m[2], "|") {
+				schema.Enum = append(schema.Enum, option)
+			}
+		}
+		params = append(params, &openapi3.ParameterRef{Value: openapi3.NewPathParameter(m[1]).WithSchema(schema)})
+		return "{" + m[1] + "}"
+	})
+	return path, params
+}
+
+func newOperation(op *Operation, pathParams openapi3.Parameters, schemas *schemas, errorRef *openapi3.SchemaRef) (*openapi3.Operation, error) {
+	operation := openapi3.NewOperation()
+	operation.OperationID = op.ID
+	operation.Summary = op.Summary
+	if op.Tag != "" {
+		operation.Tags = []string{op.Tag}
+	}
+	if op.Auth {
+		operation.Security = &openapi3.SecurityRequirements{openapi3.NewSecurityRequirement().Authenticate("bearerAuth")}
+	}
+
+	operation.Parameters = append(operation.Parameters, pathParams...)
+	for _, p := range op.Query {
+		param := openapi3.NewQueryParameter(p.Name).WithSchema(p.Schema)
+		param.Description = p.Description
+		operation.Parameters = append(operation.Parameters, &openapi3.ParameterRef{Value: param})
+	}
+
+	if op.Body != nil {
+		schema, err := schemas.schemaFor(op.Body)
+		if err != nil {
+			return nil, err
+		}
+		body := openapi3.NewRequestBody().WithJSONSchemaRef(schema).WithRequired(!op.OptionalBody)
+		if op.Multipart {
+			body.Content["multipart/form-data"] = openapi3.NewMediaType().WithSchemaRef(multipartSchema(schema))
+		}
+		operation.RequestBody = &openapi3.RequestBodyRef{Value: body}
+	}
+
+	response := openapi3.NewResponse().WithDescription(http.StatusText(op.Status))
+	switch {
+	case op.Stream:
+		response.WithContent(openapi3.Content{"text/event-stream": openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema())})
+	case op.Response != nil:
+		schema, err := schemas.schemaFor(op.Response)
+		if err != nil {
+			return nil, err
+		}
+		response.WithJSONSchemaRef(schema)
+		for _, mediaType := range op.Files {
+			response.Content[mediaType] = openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema().WithFormat("binary"))
+		}
+	}
+	operation.AddResponse(op.Status, response)
+	if op.Conditional {
+		param := openapi3.NewHeaderParameter("If-None-Match").WithSchema(openapi3.NewStringSchema())
+		param.Description = "ETag уже полученной версии"
+		operation.Parameters = append(operation.Parameters, &openapi3.ParameterRef{Value: param})
+		response.Headers = openapi3.Headers{"ETag": &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+			Description: "версия ответа",
+			Schema:      openapi3.NewStringSchema().NewRef(),
+		}}}}
+		operation.AddResponse(http.StatusNotModified, openapi3.NewResponse().WithDescription(http.StatusText(http.StatusNotModified)))
+	}
+	operation.Responses.Set("default", &openapi3.ResponseRef{Value: openapi3.NewResponse().
+		WithDescription("Error").
+		WithJSONSchemaRef(errorRef)})
+	return operation, nil
+}
+
+// multipartSchema - те же поля, что в JSON, но строками, плюс файл image.
+func multipartSchema(jsonSchema *openapi3.SchemaRef) *openapi3.SchemaRef {
+	schema := openapi3.NewObjectSchema()
+	for name, prop := range jsonSchema.Value.Properties {
+		if prop.Value.Type.Is(openapi3.TypeString) {
+			schema.WithProperty(name, openapi3.NewStringSchema())
+		}
+	}
+	schema.WithProperty("image", openapi3.NewStringSchema().WithFormat("binary"))
+	schema.Required = jsonSchema.Value.Required
+	return schema.NewRef()
+}
@@ -0,0 +1,211 @@
+package apispec
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type testForm struct {
+	Title string   `json:"title" validate:"required,max=10"`
+	Kind  string   `json:"kind,omitempty" validate:"oneof=a b"`
+	Count *int     `json:"count" validate:"required,min=1"`
+	Tags  []string `json:"tags" validate:"max=2"`
+	Skip  string   `json:"-" validate:"required"`
+}
+
+type testItem struct {
+	ID    primitive.ObjectID `json:"id"`
+	Title string             `json:"title"`
+	Child *testItemChild     `json:"child,omitempty"`
+}
+
+type testItemChild struct {
+	Name string `json:"name"`
+}
+
+type testError struct {
+	Message string `json:"message"`
+}
+
+func testSpec(t *testing.T) *Spec {
+	spec, err := New("test", "1", testError{}, []Operation{
+		{Method: "POST", Path: "/items/{KIND:new|old}", ID: "makeItem", Auth: true,
+			Query: []Param{IntParam("limit", "", 1)},
+			Body:  testForm{}, Multipart: true, Status: http.StatusCreated, Response: testItem{}},
+		{Method: "GET", Path: "/items/{ID}", ID: "getItem", Status: http.StatusOK, Response: []*testItem{}, Conditional: true},
+		{Method: "GET", Path: "/export", ID: "export", Status: http.StatusOK, Response: testItem{}, Files: []string{"application/zip"}},
+	})
+	require.NoError(t, err)
+	return spec
+}
+
+func TestNew(t *testing.T) {
+	spec := testSpec(t)
+
+	item := spec.Doc.Paths.Value("/items/{KIND}")
+	require.NotNil(t, item)
+	require.NotNil(t, item.Post)
+	kind := item.Post.Parameters.GetByInAndName(openapi3.ParameterInPath, "KIND")
+	require.NotNil(t, kind)
+	assert.Equal(t, []interface{}{"new", "old"}, kind.Schema.Value.Enum)
+	assert.NotNil(t, item.Post.Security)
+
+	form := item.Post.RequestBody.Value.Content.Get("application/json").Schema
+	assert.Equal(t, "#/components/schemas/TestForm", form.Ref)
+	assert.Equal(t, []string{"title", "count"}, form.Value.Required)
+	props := form.Value.Properties
+	assert.Equal(t, uint64(1), props["title"].Value.MinLength)
+	assert.Equal(t, uint64(10), *props["title"].Value.MaxLength)
+	assert.Equal(t, []interface{}{"a", "b"}, props["kind"].Value.Enum)
+	assert.Equal(t, float64(1), *props["count"].Value.Min)
+	assert.False(t, props["count"].Value.Nullable)
+	assert.Equal(t, uint64(2), *props["tags"].Value.MaxItems)
+	assert.True(t, props["tags"].Value.Nullable)
+	assert.NotContains(t, props, "Skip")
+
+	multipart := item.Post.RequestBody.Value.Content.Get("multipart/form-data").Schema.Value
+	assert.Contains(t, multipart.Properties, "image")
+	assert.NotContains(t, multipart.Properties, "count")
+
+	schemas := spec.Doc.Components.Schemas
+	assert.Contains(t, schemas, "TestItem")
+	assert.Contains(t, schemas, "TestItemChild")
+	assert.Contains(t, schemas, "TestError")
+	assert.Equal(t, "^[0-9a-f]{24}$", schemas["TestItem"].Value.Properties["id"].Value.Pattern)
+	assert.False(t, schemas["TestItemChild"].Value.Nullable)
+	list := spec.Doc.Paths.Value("/items/{ID}").Get.Responses.Value("200").Value.Content.Get("application/json").Schema
+	assert.Equal(t, "#/components/schemas/TestItem", list.Value.Items.Ref)
+
+	getItem := spec.Doc.Paths.Value("/items/{ID}").Get
+	assert.NotNil(t, getItem.Parameters.GetByInAndName(openapi3.ParameterInHeader, "If-None-Match"))
+	assert.Contains(t, getItem.Responses.Value("200").Value.Headers, "ETag")
+	assert.NotNil(t, getItem.Responses.Value("304"))
+	assert.Nil(t, item.Post.Responses.Value("304"))
+
+	export := spec.Doc.Paths.Value("/export").Get.Responses.Value("200").Value.Content
+	assert.NotNil(t, export.Get("application/json"))
+	assert.Equal(t, "binary", export.Get("application/zip").Schema.Value.Format)
+
+	_, err := New("test", "1", testError{}, []Operation{
+		{Method: "GET", Path: "/a", Status: http.StatusOK},
+		{Method: "GET", Path: "/a", Status: http.StatusOK},
+	})
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	spec := testSpec(t)
+
+	tests := []struct {
+		name        string
+		route       string
+		contentType string
+		body        string
+		pathParams  map[string]string
+		wantErrors  []map[string]string
+		wantErr     bool
+	}{
+		{
+			name:       "Корректный запрос",
+			route:      "/items/new?limit=5",
+			body:       `{"title":"заголовок","count":2}`,
+			pathParams: map[string]string{"KIND": "new"},
+		},
+		{
+			name:       "Ошибки в полях и параметрах",
+			route:      "/items/all?limit=0",
+			body:       `{"title":"","kind":"c","count":null,"tags":["a","b","c"]}`,
+			pathParams: map[string]string{"KIND": "all"},
+			wantErrors: []map[string]string{
+				{"location": "path", "param": "KIND", "msg": "must be one of new, old"},
+				{"location": "query", "param": "limit", "msg": "must be at least 1"},
+				{"location": "body", "param": "title", "msg": "is required"},
+				{"location": "body", "param": "kind", "msg": "must be one of a, b"},
+				{"location": "body", "param": "count", "msg": "must not be null"},
+				{"location": "body", "param": "tags", "msg": "must have at most 2 items"},
+			},
+		},
+		{
+			name:       "Нет поля и неверный тип",
+			route:      "/items/new?limit=many",
+			body:       `{"count":"two","title":"заголовок, который длиннее десяти символов"}`,
+			pathParams: map[string]string{"KIND": "new"},
+			wantErrors: []map[string]string{
+				{"location": "query", "param": "limit", "msg": "must be an integer"},
+				{"location": "body", "param": "count", "msg": "must be an integer"},
+				{"location": "body", "param": "title", "msg": "must be at most 10 characters"},
+			},
+		},
+		{
+			name:       "Битый JSON",
+			route:      "/items/new",
+			body:       `{"title":`,
+			pathParams: map[string]string{"KIND": "new"},
+			wantErr:    true,
+		},
+		{
+			name:       "Нет тела",
+			route:      "/items/new",
+			pathParams: map[string]string{"KIND": "new"},
+			wantErr:    true,
+		},
+		{
+			name:        "Тело multipart проверяет обработчик",
+			route:       "/items/new",
+			contentType: "multipart/form-data; boundary=x",
+			body:        "--x--\r\n",
+			pathParams:  map[string]string{"KIND": "new"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tc.route, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			fields, err := spec.Validate(req, "POST", "/items/{KIND:new|old}", tc.pathParams)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.wantErrors, fields)
+
+			// Обработчик после проверки читает то же тело.
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, string(body))
+		})
+	}
+
+	fields, err := spec.Validate(httptest.NewRequest("GET", "/other", nil), "GET", "/other", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, fields)
+}
+
+func TestValidateResponse(t *testing.T) {
+	spec := testSpec(t)
+	req := httptest.NewRequest("GET", "/items/1", nil)
+	header := http.Header{"Content-Type": {"application/json"}}
+	params := map[string]string{"ID": "1"}
+
+	ok := `[{"id":"65f1a2b3c4d5e6f708192a3b","title":"заголовок","child":{"name":"имя"}}]`
+	assert.NoError(t, spec.ValidateResponse(req, "GET", "/items/{ID}", params, http.StatusOK, header, []byte(ok)))
+	assert.NoError(t, spec.ValidateResponse(req, "GET", "/items/{ID}", params, http.StatusNotFound, header, []byte(`{"message":"not found"}`)))
+
+	badID := `[{"id":"1","title":"заголовок"}]`
+	assert.Error(t, spec.ValidateResponse(req, "GET", "/items/{ID}", params, http.StatusOK, header, []byte(badID)))
+	badType := `[{"id":"65f1a2b3c4d5e6f708192a3b","title":5}]`
+	assert.Error(t, spec.ValidateResponse(req, "GET", "/items/{ID}", params, http.StatusOK, header, []byte(badType)))
+	assert.Error(t, spec.ValidateResponse(req, "GET", "/other", nil, http.StatusOK, header, []byte(ok)))
+}
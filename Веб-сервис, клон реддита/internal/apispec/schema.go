@@ -0,0 +1,183 @@
+package apispec
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var objectIDType = reflect.TypeOf(primitive.ObjectID{})
+
+// schemas строит схемы по типам Go. Ограничения берутся из тегов validate - тех же, по которым
+// обработчики проверяют формы, поэтому спецификация не расходится с кодом. Именованные структуры
+// выносятся в components: иначе Post повторялся бы в каждой операции.
+type schemas struct {
+	components openapi3.Schemas
+	// types - тип Go каждой построенной схемы, по нему схема получает имя в components.
+	types map[*openapi3.Schema]reflect.Type
+	named map[string]reflect.Type
+}
+
+func newSchemas(components openapi3.Schemas) *schemas {
+	return &schemas{components: components, types: map[*openapi3.Schema]reflect.Type{}, named: map[string]reflect.Type{}}
+}
+
+func (s *schemas) schemaFor(value interface{}) (*openapi3.SchemaRef, error) {
+	ref, err := openapi3gen.NewSchemaRefForValue(value, nil, openapi3gen.SchemaCustomizer(s.customize))
+	if err != nil {
+		return nil, err
+	}
+	s.extract(ref)
+	return ref, nil
+}
+
+// extract заменяет схемы именованных структур ссылками на components. Value у ссылки остаётся,
+// поэтому для проверки запросов разрешать ссылки не нужно.
+func (s *schemas) extract(ref *openapi3.SchemaRef) {
+	if ref == nil || ref.Value == nil || ref.Ref != "" {
+		return
+	}
+	schema := ref.Value
+	for _, prop := range schema.Properties {
+		s.extract(prop)
+	}
+	s.extract(schema.Items)
+	s.extract(schema.AdditionalProperties.Schema)
+
+	t := s.types[schema]
+	if t == nil || t.Kind() != reflect.Struct || t.Name() == "" || schema.Properties == nil {
+		return
+	}
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if named, ok := s.named[name]; ok && named != t {
+		// Одноимённые типы из разных пакетов оставляем на месте.
+		return
+	}
+	if _, ok := s.components[name]; !ok {
+		component := *schema
+		component.Nullable = false
+		s.components[name] = &openapi3.SchemaRef{Value: &component}
+		s.named[name] = t
+	}
+	ref.Ref = "#/components/schemas/" + name
+}
+
+func (s *schemas) customize(_ string, t reflect.Type, tag reflect.StructTag, schema *openapi3.Schema) error {
+	s.types[schema] = t
+	switch {
+	case t == objectIDType:
+		nullable := schema.Nullable
+		*schema = *openapi3.NewStringSchema().WithPattern("^[0-9a-f]{24}$")
+		schema.Nullable = nullable
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Map:
+		// Пустые срезы и словари encoding/json пишет как null.
+		schema.Nullable = true
+	case t.Kind() == reflect.Struct:
+		schema.Required = requiredFields(t)
+	}
+
+	for _, rule := range strings.Split(tag.Get("validate"), ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		applyRule(name, arg, t, schema)
+	}
+	return nil
+}
+
+// applyRule переносит одно правило validator в схему. Незнакомые правила пропускаются.
+func applyRule(name, arg string, t reflect.Type, schema *openapi3.Schema) {
+	n, _ := strconv.ParseUint(arg, 10, 64)
+	switch name {
+	case "required":
+		schema.Nullable = false
+		// Для validator пустая строка - то же, что отсутствующее поле.
+		if t.Kind() == reflect.String && schema.MinLength == 0 {
+			schema.MinLength = 1
+		}
+	case "min":
+		setMin(t, schema, n)
+	case "max":
+		setMax(t, schema, n)
+	case "len":
+		setMin(t, schema, n)
+		setMax(t, schema, n)
+	case "oneof":
+		schema.Enum = enumValues(strings.Fields(arg), t.Kind() != reflect.String)
+	case "url":
+		schema.Format = "uri"
+	}
+}
+
+// setMin и setMax - как у validator: для строк это длина, для списков - число элементов, для чисел - значение.
+func setMin(t reflect.Type, schema *openapi3.Schema, n uint64) {
+	switch t.Kind() {
+	case reflect.String:
+		schema.MinLength = n
+	case reflect.Slice, reflect.Map:
+		schema.MinItems = n
+	default:
+		schema.Min = openapi3.Float64Ptr(float64(n))
+	}
+}
+
+func setMax(t reflect.Type, schema *openapi3.Schema, n uint64) {
+	switch t.Kind() {
+	case reflect.String:
+		schema.MaxLength = openapi3.Uint64Ptr(n)
+	case reflect.Slice, reflect.Map:
+		schema.MaxItems = openapi3.Uint64Ptr(n)
+	default:
+		schema.Max = openapi3.Float64Ptr(float64(n))
+	}
+}
+
+// enumValues переводит варианты из тега oneof в значения типа поля.
+func enumValues(options []string, integer bool) []interface{} {
+	values := make([]interface{}, 0, len(options))
+	for _, option := range options {
+		if integer {
+			if n, err := strconv.Atoi(option); err == nil {
+				values = append(values, n)
+				continue
+			}
+		}
+		values = append(values, option)
+	}
+	return values
+}
+
+// requiredFields - JSON-имена полей структуры с правилом required.
+func requiredFields(t reflect.Type) []string {
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		rules := strings.Split(field.Tag.Get("validate"), ",")
+		if !contains(rules, "required") {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		required = append(required, name)
+	}
+	return required
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package apispec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+)
+
+// Validate проверяет запрос к операции method + muxPath по спецификации. Ошибки в параметрах и полях тела
+// возвращаются списком {"location", "param", "msg"}, как у ошибок валидации форм. err - запрос не разобрать
+// вовсе: битый JSON или нет обязательного тела.
+// Тело multipart/form-data не проверяется: его разбирает сам обработчик.
+func (s *Spec) Validate(r *http.Request, method, muxPath string, pathParams map[string]string) ([]map[string]string, error) {
+	route := s.Route(method, muxPath)
+	if route == nil {
+		return nil, nil
+	}
+
+	// Обработчики читают тело как JSON независимо от заголовка, поэтому и проверяем его так же.
+	in := r.Clone(r.Context())
+	if in.Header.Get("Content-Type") == "" {
+		in.Header.Set("Content-Type", "application/json")
+	}
+	mediaType, _, _ := mime.ParseMediaType(in.Header.Get("Content-Type"))
+
+	err := openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+		Request:    in,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			ExcludeRequestBody:  mediaType == "multipart/form-data",
+			MultiError:          true,
+			SkipSettingDefaults: true,
+			// Токен проверяют обработчики: им нужна сессия, а не только подпись.
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	})
+	// ValidateRequest вычитывает тело и кладёт на его место копию - отдаём её обработчику.
+	r.Body = in.Body
+	if err == nil {
+		return nil, nil
+	}
+
+	var fields []map[string]string
+	if err = collect(err, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// ValidateResponse сверяет ответ операции со спецификацией: статус должен быть описан, тело - подходить под схему.
+func (s *Spec) ValidateResponse(r *http.Request, method, muxPath string, pathParams map[string]string, status int, header http.Header, body []byte) error {
+	route := s.Route(method, muxPath)
+	if route == nil {
+		return fmt.Errorf("%s %s is not in the spec", method, muxPath)
+	}
+	return openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{Request: r, PathParams: pathParams, Route: route},
+		Status:                 status,
+		Header:                 header,
+		Body:                   io.NopCloser(bytes.NewReader(body)),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			MultiError:            true,
+			// Поток событий бесконечен, его тело не сверить со схемой.
+			ExcludeResponseBody: strings.HasPrefix(header.Get("Content-Type"), "text/event-stream"),
+		},
+	})
+}
+
+// collect раскладывает ошибки openapi3filter по полям. Возвращает ошибку, если запрос не разобрать.
+func collect(err error, out *[]map[string]string) error {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, sub := range e {
+			if err := collect(sub, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *openapi3filter.RequestError:
+		if p := e.Parameter; p != nil {
+			if !schemaErrors(e.Err, p.In, p.Name, out) {
+				*out = append(*out, fieldError(p.In, p.Name, parameterMessage(p, e.Err)))
+			}
+			return nil
+		}
+		if e.RequestBody != nil && schemaErrors(e.Err, "body", "", out) {
+			return nil
+		}
+	}
+	return err
+}
+
+// schemaErrors добавляет в out ошибки схемы. false - среди ошибок есть другие.
+func schemaErrors(err error, location, prefix string, out *[]map[string]string) bool {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		ok := true
+		for _, sub := range e {
+			ok = schemaErrors(sub, location, prefix, out) && ok
+		}
+		return ok
+	case *openapi3.SchemaError:
+		param := strings.Join(e.JSONPointer(), ".")
+		if prefix != "" {
+			param = strings.TrimSuffix(prefix+"."+param, ".")
+		}
+		*out = append(*out, fieldError(location, param, schemaMessage(e)))
+		return true
+	}
+	return false
+}
+
+func fieldError(location, param, msg string) map[string]string {
+	return map[string]string{"location": location, "param": param, "msg": msg}
+}
+
+// schemaMessage - короткое сообщение в духе "is required" вместо полного текста kin-openapi.
+func schemaMessage(e *openapi3.SchemaError) string {
+	s := e.Schema
+	switch e.SchemaField {
+	case "required":
+		return "is required"
+	case "nullable":
+		return "must not be null"
+	case "minLength":
+		if s.MinLength == 1 {
+			return "is required"
+		}
+		return fmt.Sprintf("must be at least %d characters", s.MinLength)
+	case "maxLength":
+		return fmt.Sprintf("must be at most %d characters", *s.MaxLength)
+	case "minItems":
+		return fmt.Sprintf("must have at least %d items", s.MinItems)
+	case "maxItems":
+		return fmt.Sprintf("must have at most %d items", *s.MaxItems)
+	case "minimum":
+		return fmt.Sprintf("must be at least %g", *s.Min)
+	case "maximum":
+		return fmt.Sprintf("must be at most %g", *s.Max)
+	case "enum":
+		options := make([]string, 0, len(s.Enum))
+		for _, v := range s.Enum {
+			options = append(options, fmt.Sprint(v))
+		}
+		return "must be one of " + strings.Join(options, ", ")
+	case "type":
+		return "must be " + typeName(s)
+	case "pattern", "format":
+		return "has invalid format"
+	}
+	return e.Reason
+}
+
+// parameterMessage - сообщение для параметра, который не удалось даже разобрать.
+func parameterMessage(p *openapi3.Parameter, err error) string {
+	if err == openapi3filter.ErrInvalidRequired {
+		return "is required"
+	}
+	if p.Schema != nil && p.Schema.Value != nil {
+		return "must be " + typeName(p.Schema.Value)
+	}
+	return "has invalid format"
+}
+
+func typeName(s *openapi3.Schema) string {
+	switch {
+	case s.Type.Is(openapi3.TypeInteger):
+		return "an integer"
+	case s.Type.Is(openapi3.TypeArray):
+		return "an array"
+	case s.Type.Is(openapi3.TypeObject):
+		return "an object"
+	case s.Type != nil && len(*s.Type) == 1:
+		return "a " + (*s.Type)[0]
+	}
+	return "valid"
+}
@@ -0,0 +1,47 @@
+package collections
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kind - личный список пользователя.
+type Kind string
+
+const (
+	KindSaved  Kind = "saved"
+	KindHidden Kind = "hidden"
+)
+
+// ItemType - что лежит в списке: пост целиком или отдельный комментарий.
+type ItemType string
+
+const (
+	ItemPost    ItemType = "post"
+	ItemComment ItemType = "comment"
+)
+
+// Item - одна запись в списке. У поста CommentID равен primitive.NilObjectID.
+type Item struct {
+	UserID    int64              `bson:"userId"`
+	Kind      Kind               `bson:"kind"`
+	Type      ItemType           `bson:"type"`
+	PostID    primitive.ObjectID `bson:"postId"`
+	CommentID primitive.ObjectID `bson:"commentId"`
+	Created   time.Time          `bson:"created"`
+}
+
+//go:generate mockgen -source=collections.go -destination=repo_mock.go -package=collections CollectionRepo
+type CollectionRepo interface {
+	// Add и Remove идемпотентны: повторное добавление или удаление отсутствующего не ошибка.
+	Add(ctx context.Context, item *Item) error
+	Remove(ctx context.Context, item *Item) error
+	// List отдаёт записи списка от новых к старым.
+	List(ctx context.Context, userID int64, kind Kind, offset, limit int) ([]*Item, error)
+	// Hidden отдаёт ID скрытых постов (ItemPost) или комментариев (ItemComment).
+	Hidden(ctx context.Context, userID int64, itemType ItemType) (map[primitive.ObjectID]bool, error)
+	// DeleteUser удаляет все списки пользователя и возвращает число удалённых записей.
+	DeleteUser(ctx context.Context, userID int64) (int64, error)
+}
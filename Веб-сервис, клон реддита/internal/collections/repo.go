@@ -0,0 +1,152 @@
+package collections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"redditclone/internal/logging"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrFailedConvert = errors.New("failed to convert values")
+	// ErrStorage оборачивает ошибки монги, исходная ошибка доступна через errors.Is/As.
+	ErrStorage = errors.New("storage error")
+)
+
+// storageError пишет ошибку монги в лог запроса и оборачивает её в ErrStorage.
+func storageError(ctx context.Context, op string, err error) error {
+	logging.FromContext(ctx, nil).Errorw("storage error", "op", op, "error", err)
+	return fmt.Errorf("%w: %w", ErrStorage, err)
+}
+
+type CollectionMongoRepository struct {
+	DB *mongo.Collection
+	// Таймауты на одну операцию чтения/записи в монгу. 0 - без таймаута, только контекст запроса.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+func NewMongoRepo(db *mongo.Collection) *CollectionMongoRepository {
+	return &CollectionMongoRepository{DB: db}
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// EnsureIndexes создаёт уникальный индекс, на который опирается идемпотентность Add.
+func (repo *CollectionMongoRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "kind", Value: 1}, {Key: "postId", Value: 1}, {Key: "commentId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "kind", Value: 1}, {Key: "created", Value: -1}},
+		},
+	})
+	if err != nil {
+		return storageError(ctx, "collections.EnsureIndexes", err)
+	}
+	return nil
+}
+
+func itemFilter(item *Item) bson.M {
+	return bson.M{
+		"userId":    item.UserID,
+		"kind":      item.Kind,
+		"postId":    item.PostID,
+		"commentId": item.CommentID,
+	}
+}
+
+func (repo *CollectionMongoRepository) Add(ctx context.Context, item *Item) error {
+	created := item.Created
+	if created.IsZero() {
+		created = time.Now().UTC()
+	}
+
+	update := bson.M{"$setOnInsert": bson.M{"type": item.Type, "created": created}}
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.UpdateOne(ctx, itemFilter(item), update, options.Update().SetUpsert(true))
+	if err != nil {
+		return storageError(ctx, "collections.Add", err)
+	}
+	return nil
+}
+
+func (repo *CollectionMongoRepository) Remove(ctx context.Context, item *Item) error {
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.DeleteOne(ctx, itemFilter(item))
+	if err != nil {
+		return storageError(ctx, "collections.Remove", err)
+	}
+	return nil
+}
+
+func (repo *CollectionMongoRepository) List(ctx context.Context, userID int64, kind Kind, offset, limit int) ([]*Item, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(ctx, bson.M{"userId": userID, "kind": kind}, opts)
+	if err != nil {
+		return nil, storageError(ctx, "collections.List", err)
+	}
+
+	items := []*Item{}
+	if err = c.All(ctx, &items); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return items, nil
+}
+
+func (repo *CollectionMongoRepository) Hidden(ctx context.Context, userID int64, itemType ItemType) (map[primitive.ObjectID]bool, error) {
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(ctx, bson.M{"userId": userID, "kind": KindHidden, "type": itemType})
+	if err != nil {
+		return nil, storageError(ctx, "collections.Hidden", err)
+	}
+
+	var items []*Item
+	if err = c.All(ctx, &items); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+
+	hidden := make(map[primitive.ObjectID]bool, len(items))
+	for _, item := range items {
+		if itemType == ItemComment {
+			hidden[item.CommentID] = true
+		} else {
+			hidden[item.PostID] = true
+		}
+	}
+	return hidden, nil
+}
+
+func (repo *CollectionMongoRepository) DeleteUser(ctx context.Context, userID int64) (int64, error) {
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result, err := repo.DB.DeleteMany(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return 0, storageError(ctx, "collections.DeleteUser", err)
+	}
+	return result.DeletedCount, nil
+}
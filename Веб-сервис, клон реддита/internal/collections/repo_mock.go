@@ -0,0 +1,109 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: collections.go
+
+// Package collections is a generated GoMock package.
+package collections
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	primitive "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockCollectionRepo is a mock of CollectionRepo interface.
+type MockCollectionRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockCollectionRepoMockRecorder
+}
+
+// MockCollectionRepoMockRecorder is the mock recorder for MockCollectionRepo.
+type MockCollectionRepoMockRecorder struct {
+	mock *MockCollectionRepo
+}
+
+// NewMockCollectionRepo creates a new mock instance.
+func NewMockCollectionRepo(ctrl *gomock.Controller) *MockCollectionRepo {
+	mock := &MockCollectionRepo{ctrl: ctrl}
+	mock.recorder = &MockCollectionRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCollectionRepo) EXPECT() *MockCollectionRepoMockRecorder {
+	return m.recorder
+}
+
+// Add mocks base method.
+func (m *MockCollectionRepo) Add(ctx context.Context, item *Item) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Add", ctx, item)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Add indicates an expected call of Add.
+func (mr *MockCollectionRepoMockRecorder) Add(ctx, item interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockCollectionRepo)(nil).Add), ctx, item)
+}
+
+// DeleteUser mocks base method.
+func (m *MockCollectionRepo) DeleteUser(ctx context.Context, userID int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, userID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockCollectionRepoMockRecorder) DeleteUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockCollectionRepo)(nil).DeleteUser), ctx, userID)
+}
+
+// Hidden mocks base method.
+func (m *MockCollectionRepo) Hidden(ctx context.Context, userID int64, itemType ItemType) (map[primitive.ObjectID]bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Hidden", ctx, userID, itemType)
+	ret0, _ := ret[0].(map[primitive.ObjectID]bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Hidden indicates an expected call of Hidden.
+func (mr *MockCollectionRepoMockRecorder) Hidden(ctx, userID, itemType interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hidden", reflect.TypeOf((*MockCollectionRepo)(nil).Hidden), ctx, userID, itemType)
+}
+
+// List mocks base method.
+func (m *MockCollectionRepo) List(ctx context.Context, userID int64, kind Kind, offset, limit int) ([]*Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, userID, kind, offset, limit)
+	ret0, _ := ret[0].([]*Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockCollectionRepoMockRecorder) List(ctx, userID, kind, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockCollectionRepo)(nil).List), ctx, userID, kind, offset, limit)
+}
+
+// Remove mocks base method.
+func (m *MockCollectionRepo) Remove(ctx context.Context, item *Item) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Remove", ctx, item)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockCollectionRepoMockRecorder) Remove(ctx, item interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockCollectionRepo)(nil).Remove), ctx, item)
+}
@@ -0,0 +1,241 @@
+package collections
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"redditclone/internal/logging"
+	"testing"
+	"time"
+)
+
+func TestAddRemove(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	item := &Item{UserID: 1, Kind: KindSaved, Type: ItemPost, PostID: primitive.NewObjectID()}
+
+	var tests = []struct {
+		name         string
+		add          bool
+		mockResponse []bson.D
+		wantErr      error
+	}{
+		{
+			name:         "Проверка на успешное добавление",
+			add:          true,
+			mockResponse: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 0})},
+		},
+		{
+			name:         "Проверка на ошибку при добавлении",
+			add:          true,
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+		{
+			name:         "Проверка на успешное удаление",
+			mockResponse: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1})},
+		},
+		{
+			name:         "Проверка, что удаление отсутствующей записи не ошибка",
+			mockResponse: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0})},
+		},
+		{
+			name:         "Проверка на ошибку при удалении",
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			var err error
+			if tc.add {
+				err = repo.Add(context.Background(), item)
+			} else {
+				err = repo.Remove(context.Background(), item)
+			}
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID := primitive.NewObjectID()
+	created := time.Date(2024, 5, 7, 20, 38, 17, 0, time.UTC)
+
+	var tests = []struct {
+		name         string
+		mockResponse []bson.D
+		wantItems    []*Item
+		wantErr      error
+	}{
+		{
+			name: "Проверка на успешное получение списка",
+			mockResponse: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+					{Key: "userId", Value: int64(1)},
+					{Key: "kind", Value: "saved"},
+					{Key: "type", Value: "post"},
+					{Key: "postId", Value: postID},
+					{Key: "commentId", Value: primitive.NilObjectID},
+					{Key: "created", Value: created},
+				}),
+			},
+			wantItems: []*Item{{UserID: 1, Kind: KindSaved, Type: ItemPost, PostID: postID, Created: created}},
+		},
+		{
+			name:         "Проверка на пустой список",
+			mockResponse: []bson.D{mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch)},
+			wantItems:    []*Item{},
+		},
+		{
+			name:         "Проверка на ошибку при запросе",
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+		{
+			name: "Проверка на обработку сломанного bson",
+			mockResponse: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+					{Key: "postId", Value: "не ObjectId, а строка"},
+				}),
+			},
+			wantErr: ErrFailedConvert,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			items, err := repo.List(context.Background(), 1, KindSaved, 0, 10)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.wantItems, items)
+			}
+		})
+	}
+}
+
+func TestHidden(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID, commentID := primitive.NewObjectID(), primitive.NewObjectID()
+	hiddenComment := bson.D{
+		{Key: "userId", Value: int64(1)},
+		{Key: "kind", Value: "hidden"},
+		{Key: "type", Value: "comment"},
+		{Key: "postId", Value: postID},
+		{Key: "commentId", Value: commentID},
+	}
+	hiddenPost := bson.D{
+		{Key: "userId", Value: int64(1)},
+		{Key: "kind", Value: "hidden"},
+		{Key: "type", Value: "post"},
+		{Key: "postId", Value: postID},
+		{Key: "commentId", Value: primitive.NilObjectID},
+	}
+
+	var tests = []struct {
+		name         string
+		itemType     ItemType
+		mockResponse []bson.D
+		want         map[primitive.ObjectID]bool
+		wantErr      error
+	}{
+		{
+			name:         "Проверка на скрытые посты",
+			itemType:     ItemPost,
+			mockResponse: []bson.D{mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, hiddenPost)},
+			want:         map[primitive.ObjectID]bool{postID: true},
+		},
+		{
+			name:         "Проверка на скрытые комментарии",
+			itemType:     ItemComment,
+			mockResponse: []bson.D{mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, hiddenComment)},
+			want:         map[primitive.ObjectID]bool{commentID: true},
+		},
+		{
+			name:         "Проверка на ошибку при запросе",
+			itemType:     ItemPost,
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			hidden, err := repo.Hidden(context.Background(), 1, tc.itemType)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, hidden)
+			}
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Проверка на успешное удаление", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 3}))
+
+		n, err := repo.DeleteUser(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), n)
+
+		del := mt.GetStartedEvent().Command.Lookup("deletes").Array().Index(0).Value().Document()
+		assert.Equal(t, int64(1), del.Lookup("q", "userId").Int64())
+	})
+
+	mt.Run("Проверка на ошибку при удалении", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.DeleteUser(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestStorageErrorLogged(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Ошибка монги пишется в лог запроса", func(mt *mtest.T) {
+		core, logs := observer.New(zap.ErrorLevel)
+		ctx := logging.NewContext(context.Background(), zap.New(core).Sugar().With("request_id", "req-1"))
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		repo := NewMongoRepo(mt.Coll)
+		_, err := repo.List(ctx, 1, KindSaved, 0, 10)
+		assert.ErrorIs(t, err, ErrStorage)
+
+		entries := logs.AllUntimed()
+		if assert.Len(t, entries, 1) {
+			fields := entries[0].ContextMap()
+			assert.Equal(t, "collections.List", fields["op"])
+			assert.Equal(t, "req-1", fields["request_id"])
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package dataloader
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchFunc загружает значения сразу для всех ключей. Ключа, которого нет в ответе, не существует.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Loader собирает ключи, запрошенные до первого обращения к результату, и загружает их одним вызовом BatchFunc.
+// Так список из N объектов, каждому из которых нужна связанная запись, обходится одним запросом вместо N.
+// Результаты запоминаются на всё время жизни Loader, поэтому он создаётся на один запрос клиента.
+type Loader[K comparable, V any] struct {
+	fetch BatchFunc[K, V]
+
+	mu      sync.Mutex
+	pending *batch[K, V]
+	batches map[K]*batch[K, V]
+}
+
+type batch[K comparable, V any] struct {
+	keys   []K
+	once   sync.Once
+	values map[K]V
+	err    error
+}
+
+func New[K comparable, V any](fetch BatchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, batches: map[K]*batch[K, V]{}}
+}
+
+// Load ставит ключ в очередь и возвращает функцию, которая отдаст значение. Первый вызов такой функции
+// загружает всю накопленную пачку. ok == false - значения для ключа нет.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) func() (V, bool, error) {
+	l.mu.Lock()
+	b, ok := l.batches[key]
+	if !ok {
+		if l.pending == nil {
+			l.pending = &batch[K, V]{}
+		}
+		b = l.pending
+		b.keys = append(b.keys, key)
+		l.batches[key] = b
+	}
+	l.mu.Unlock()
+
+	return func() (V, bool, error) {
+		b.once.Do(func() {
+			l.mu.Lock()
+			if l.pending == b {
+				l.pending = nil
+			}
+			l.mu.Unlock()
+			b.values, b.err = l.fetch(ctx, b.keys)
+		})
+		value, ok := b.values[key]
+		return value, ok, b.err
+	}
+}
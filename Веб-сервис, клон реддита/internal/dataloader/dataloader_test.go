@@ -0,0 +1,63 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoader(t *testing.T) {
+	var calls [][]int
+	loader := New(func(_ context.Context, keys []int) (map[int]string, error) {
+		calls = append(calls, keys)
+		values := map[int]string{}
+		for _, k := range keys {
+			if k > 0 {
+				values[k] = string(rune('a' + k))
+			}
+		}
+		return values, nil
+	})
+	ctx := context.Background()
+
+	// Ключи, запрошенные до первого обращения к результату, уходят одной пачкой, повторы не дублируются.
+	one, two, again, missing := loader.Load(ctx, 1), loader.Load(ctx, 2), loader.Load(ctx, 1), loader.Load(ctx, -1)
+	value, ok, err := two()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "c", value)
+	value, _, _ = one()
+	assert.Equal(t, "b", value)
+	value, _, _ = again()
+	assert.Equal(t, "b", value)
+	_, ok, err = missing()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, [][]int{{1, 2, -1}}, calls)
+
+	// Загруженное запоминается, новые ключи идут следующей пачкой.
+	cached, three := loader.Load(ctx, 2), loader.Load(ctx, 3)
+	value, _, _ = three()
+	assert.Equal(t, "d", value)
+	value, _, _ = cached()
+	assert.Equal(t, "c", value)
+	assert.Equal(t, [][]int{{1, 2, -1}, {3}}, calls)
+}
+
+func TestLoaderError(t *testing.T) {
+	calls := 0
+	loader := New(func(_ context.Context, keys []string) (map[string]int, error) {
+		calls++
+		return nil, errors.New("storage error")
+	})
+
+	first, second := loader.Load(context.Background(), "a"), loader.Load(context.Background(), "b")
+	_, ok, err := first()
+	assert.EqualError(t, err, "storage error")
+	assert.False(t, ok)
+	_, _, err = second()
+	assert.EqualError(t, err, "storage error")
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,143 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"redditclone/internal/grpcapi/pb"
+	"redditclone/internal/logging"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNoAuth - не задан ни один способ проверить клиента. Открытым такой сервер не запускаем.
+var ErrNoAuth = errors.New("grpc: neither tokens nor client CA configured")
+
+// Config - как слушать и кого пускать.
+type Config struct {
+	// Tokens - общие токены сервисов. Клиент передаёт один из них в metadata authorization: Bearer <token>.
+	Tokens []string
+	// CertFile и KeyFile - сертификат сервера. Без них сервер работает без TLS.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile - CA клиентских сертификатов. Если задан, без подписанного им сертификата не пустят (mTLS).
+	ClientCAFile string
+}
+
+// NewGRPCServer собирает grpc.Server с проверкой клиентов и журналом запросов и регистрирует на нём srv.
+func NewGRPCServer(srv *Server, config Config, logger *zap.SugaredLogger) (*grpc.Server, error) {
+	var tokens []string
+	for _, token := range config.Tokens {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 && config.ClientCAFile == "" {
+		return nil, ErrNoAuth
+	}
+
+	unary := []grpc.UnaryServerInterceptor{AccessLog(logger)}
+	stream := []grpc.StreamServerInterceptor{}
+	if len(tokens) > 0 {
+		auth := &TokenAuth{Tokens: tokens}
+		unary = append(unary, auth.Unary)
+		stream = append(stream, auth.Stream)
+	}
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+
+	if config.CertFile != "" || config.ClientCAFile != "" {
+		tlsConfig, err := serverTLS(config)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	server := grpc.NewServer(opts...)
+	pb.RegisterRedditServer(server, srv)
+	return server, nil
+}
+
+func serverTLS(config Config) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("grpc: load server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if config.ClientCAFile != "" {
+		pem, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("grpc: read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("grpc: no certificates in %s", config.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// TokenAuth пускает только запросы с одним из общих токенов.
+type TokenAuth struct {
+	Tokens []string
+}
+
+func (a *TokenAuth) Unary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := a.check(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *TokenAuth) Stream(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.check(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (a *TokenAuth) check(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if !ok {
+			continue
+		}
+		for _, allowed := range a.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(allowed)) == 1 {
+				return nil
+			}
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthenticated")
+}
+
+// AccessLog пишет в лог каждый вызов и кладёт в контекст логгер с именем метода.
+func AccessLog(logger *zap.SugaredLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = logging.NewContext(ctx, logger.With("grpc_method", info.FullMethod))
+		resp, err := handler(ctx, req)
+		logging.FromContext(ctx, logger).Infow("New gRPC request",
+			"code", status.Code(err).String(),
+			"time", time.Since(start),
+		)
+		return resp, err
+	}
+}
@@ -0,0 +1,302 @@
+package grpcapi
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pb/redditclone.proto
+
+import (
+	"context"
+	"errors"
+	"redditclone/internal/grpcapi/pb"
+	"redditclone/internal/logging"
+	"redditclone/internal/posts"
+	"redditclone/internal/user"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server - реализация pb.RedditServer прямо поверх репозиториев, без лимитов и событий REST API:
+// его клиенты - наши же сервисы.
+type Server struct {
+	pb.UnimplementedRedditServer
+
+	Posts  posts.PostRepo
+	Users  user.UserRepo
+	Logger *zap.SugaredLogger
+}
+
+func NewServer(postsRepo posts.PostRepo, users user.UserRepo, logger *zap.SugaredLogger) *Server {
+	return &Server{Posts: postsRepo, Users: users, Logger: logger}
+}
+
+// formValidator называет поля так же, как в proto.
+var formValidator = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return strings.ToLower(field.Name)
+		}
+		return name
+	})
+	return v
+}()
+
+func (s *Server) GetPost(ctx context.Context, req *pb.GetPostRequest) (*pb.Post, error) {
+	postID, err := parseID("id", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.Posts.GetPost(ctx, postID)
+	if err != nil {
+		return nil, s.statusError(ctx, "GetPost", err)
+	}
+	return toPost(post), nil
+}
+
+func (s *Server) ListPosts(ctx context.Context, req *pb.ListPostsRequest) (*pb.ListPostsResponse, error) {
+	var filters []func(*posts.Post) bool
+	if req.GetCategory() != "" {
+		filters = append(filters, posts.FilterByCategory(req.GetCategory()))
+	}
+	if req.GetAuthor() != "" {
+		filters = append(filters, posts.FilterByUser(req.GetAuthor()))
+	}
+	if len(req.GetIds()) > 0 {
+		ids := make([]primitive.ObjectID, 0, len(req.GetIds()))
+		for _, hex := range req.GetIds() {
+			id, err := parseID("ids", hex)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		filters = append(filters, posts.FilterByIDs(ids))
+	}
+
+	found, err := s.Posts.GetPosts(ctx, func(p *posts.Post) bool {
+		for _, filter := range filters {
+			if !filter(p) {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, s.statusError(ctx, "ListPosts", err)
+	}
+	resp := &pb.ListPostsResponse{Posts: make([]*pb.Post, 0, len(found))}
+	for _, post := range found {
+		resp.Posts = append(resp.Posts, toPost(post))
+	}
+	return resp, nil
+}
+
+func (s *Server) CreatePost(ctx context.Context, req *pb.CreatePostRequest) (*pb.Post, error) {
+	// Картинки и опросы загружаются только через REST API.
+	if req.GetType() != posts.TypeText && req.GetType() != posts.TypeLink {
+		return nil, invalidArgument("type", "must be one of text, link")
+	}
+	form := &posts.PostForm{
+		Type:     req.GetType(),
+		Title:    req.GetTitle(),
+		Category: req.GetCategory(),
+		Text:     req.GetText(),
+		URL:      req.GetUrl(),
+	}
+	if err := validateForm(form, nil); err != nil {
+		return nil, err
+	}
+	author, err := s.user(ctx, "author", req.GetAuthor())
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.Posts.MakePost(ctx, form, author.Username, author.ID)
+	if err != nil {
+		return nil, s.statusError(ctx, "CreatePost", err)
+	}
+	return toPost(post), nil
+}
+
+func (s *Server) AddComment(ctx context.Context, req *pb.AddCommentRequest) (*pb.Post, error) {
+	postID, err := parseID("post_id", req.GetPostId())
+	if err != nil {
+		return nil, err
+	}
+	parentID := primitive.NilObjectID
+	if req.GetParentId() != "" {
+		if parentID, err = parseID("parent_id", req.GetParentId()); err != nil {
+			return nil, err
+		}
+	}
+	if err = validateForm(&posts.CommentForm{Body: req.GetBody()}, map[string]string{"comment": "body"}); err != nil {
+		return nil, err
+	}
+	author, err := s.user(ctx, "author", req.GetAuthor())
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.Posts.MakeComment(ctx, postID, parentID, req.GetBody(), author.Username, author.ID)
+	if err != nil {
+		return nil, s.statusError(ctx, "AddComment", err)
+	}
+	return toPost(post), nil
+}
+
+func (s *Server) Vote(ctx context.Context, req *pb.VoteRequest) (*pb.Post, error) {
+	postID, err := parseID("post_id", req.GetPostId())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetVote() < -1 || req.GetVote() > 1 {
+		return nil, invalidArgument("vote", posts.ErrBadVote.Error())
+	}
+	voter, err := s.user(ctx, "user", req.GetUser())
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.Posts.VotePost(ctx, postID, voter.ID, int(req.GetVote()))
+	if err != nil {
+		return nil, s.statusError(ctx, "Vote", err)
+	}
+	return toPost(post), nil
+}
+
+func (s *Server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	u, err := s.user(ctx, "username", req.GetUsername())
+	if err != nil {
+		return nil, err
+	}
+	return toUser(u), nil
+}
+
+// user находит пользователя, от имени которого действует сервис.
+func (s *Server) user(ctx context.Context, field, username string) (*user.User, error) {
+	if username == "" {
+		return nil, invalidArgument(field, "is required")
+	}
+	u, err := s.Users.GetUser(ctx, username)
+	if err != nil {
+		return nil, s.statusError(ctx, "GetUser", err)
+	}
+	return u, nil
+}
+
+// statusError сопоставляет ошибку репозитория с кодом gRPC. Непредвиденные ошибки пишутся в лог,
+// клиенту уходит только код Internal.
+func (s *Server) statusError(ctx context.Context, method string, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "request timed out")
+	case errors.Is(err, posts.ErrPostNotFound),
+		errors.Is(err, posts.ErrCommentNotFound),
+		errors.Is(err, user.ErrNoUser):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, posts.ErrPostLocked):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, posts.ErrBadVote),
+		errors.Is(err, posts.ErrBadPostType),
+		errors.Is(err, posts.ErrBadURL):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	logging.FromContext(ctx, s.Logger).Errorw("gRPC request failed", "method", method, "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func parseID(field, hex string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return id, invalidArgument(field, "invalid id")
+	}
+	return id, nil
+}
+
+// invalidArgument возвращает InvalidArgument с описанием поля в деталях, как принято в google.rpc.
+func invalidArgument(field, msg string) error {
+	return badRequest([]*errdetails.BadRequest_FieldViolation{{Field: field, Description: msg}})
+}
+
+func badRequest(violations []*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(codes.InvalidArgument, "validation failed")
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// validateForm проверяет форму REST API по тем же тегам validate. rename - поля, которые в proto называются иначе.
+func validateForm(form interface{}, rename map[string]string) error {
+	err := formValidator.Struct(form)
+	if err == nil {
+		return nil
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, fe := range err.(validator.ValidationErrors) {
+		msg := "is invalid"
+		switch fe.Tag() {
+		case "required":
+			msg = "is required"
+		case "max":
+			msg = "must be at most " + fe.Param() + " characters"
+		}
+		field := fe.Field()
+		if name, ok := rename[field]; ok {
+			field = name
+		}
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: field, Description: msg})
+	}
+	return badRequest(violations)
+}
+
+func toUser(u *user.User) *pb.User {
+	if u == nil {
+		return nil
+	}
+	return &pb.User{Id: u.ID, Username: u.Username}
+}
+
+func toPost(post *posts.Post) *pb.Post {
+	out := &pb.Post{
+		Id:               post.ID.Hex(),
+		Type:             post.Type,
+		Title:            post.Title,
+		Category:         post.Category,
+		Text:             post.Text,
+		Html:             post.HTML,
+		Url:              post.URL,
+		Author:           toUser(post.Author),
+		Created:          post.Created,
+		Score:            int32(post.Score),
+		Views:            int32(post.Views),
+		UpvotePercentage: int32(post.UpvotePercentage),
+		VoteCount:        int32(post.VoteCount),
+		Removed:          post.Removed,
+		Locked:           post.Locked,
+		Sticky:           post.Sticky,
+	}
+	for _, vote := range post.Votes {
+		out.Votes = append(out.Votes, &pb.Vote{UserId: vote.UserID, Vote: int32(vote.Vote)})
+	}
+	for _, comment := range post.Comments {
+		c := &pb.Comment{
+			Id:      comment.ID.Hex(),
+			Author:  toUser(comment.Author),
+			Body:    comment.Body,
+			Html:    comment.HTML,
+			Created: comment.Created,
+			Removed: comment.Removed,
+		}
+		if comment.ParentID != nil {
+			c.ParentId = comment.ParentID.Hex()
+		}
+		out.Comments = append(out.Comments, c)
+	}
+	return out
+}
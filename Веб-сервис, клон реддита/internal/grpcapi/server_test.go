@@ -0,0 +1,414 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"redditclone/internal/grpcapi/pb"
+	"redditclone/internal/posts"
+	"redditclone/internal/user"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testToken = "service-token"
+
+// startServer поднимает сервер на bufconn и возвращает соединение к нему.
+func startServer(t *testing.T, srv *Server, config Config, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	server, err := NewGRPCServer(srv, config, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1 << 20)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	if len(opts) == 0 {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}))
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func fieldViolations(t *testing.T, err error) map[string]string {
+	t.Helper()
+	violations := map[string]string{}
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range badRequest.GetFieldViolations() {
+				violations[v.GetField()] = v.GetDescription()
+			}
+		}
+	}
+	return violations
+}
+
+func TestNewGRPCServer(t *testing.T) {
+	_, err := NewGRPCServer(&Server{}, Config{Tokens: []string{"", " "}}, zap.NewNop().Sugar())
+	assert.ErrorIs(t, err, ErrNoAuth)
+
+	_, err = NewGRPCServer(&Server{}, Config{ClientCAFile: "missing.pem"}, zap.NewNop().Sugar())
+	assert.Error(t, err)
+}
+
+func TestTokenAuth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	users := user.NewMockUserRepo(ctrl)
+	users.EXPECT().GetUser(gomock.Any(), "rvasily").Return(&user.User{ID: 1, Username: "rvasily"}, nil)
+
+	conn := startServer(t, NewServer(nil, users, zap.NewNop().Sugar()), Config{Tokens: []string{"other", testToken}})
+	client := pb.NewRedditClient(conn)
+
+	testCases := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{name: "без токена", ctx: context.Background(), code: codes.Unauthenticated},
+		{name: "чужой токен", ctx: withToken("wrong"), code: codes.Unauthenticated},
+		{name: "токен без Bearer", ctx: metadata.AppendToOutgoingContext(context.Background(), "authorization", testToken), code: codes.Unauthenticated},
+		{name: "верный токен", ctx: withToken(testToken), code: codes.OK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := client.GetUser(tc.ctx, &pb.GetUserRequest{Username: "rvasily"})
+			assert.Equal(t, tc.code, status.Code(err))
+			if tc.code == codes.OK {
+				assert.Equal(t, int64(1), resp.GetId())
+			}
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	postsRepo := posts.NewMockPostRepo(ctrl)
+	users := user.NewMockUserRepo(ctrl)
+	conn := startServer(t, NewServer(postsRepo, users, zap.NewNop().Sugar()), Config{Tokens: []string{testToken}})
+	client := pb.NewRedditClient(conn)
+	ctx := withToken(testToken)
+
+	author := &user.User{ID: 1, Username: "rvasily"}
+	postID, commentID, parentID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	post := &posts.Post{
+		ID:       postID,
+		Type:     posts.TypeText,
+		Title:    "title",
+		Category: "music",
+		Text:     "**text**",
+		HTML:     "<p><strong>text</strong></p>\n",
+		Author:   author,
+		Score:    2,
+		Votes:    []*posts.Vote{{UserID: 1, Vote: 1}, {UserID: 2, Vote: 1}},
+		Comments: []*posts.Comment{{ID: commentID, Author: author, Body: "hi", ParentID: &parentID}},
+	}
+	users.EXPECT().GetUser(gomock.Any(), "rvasily").Return(author, nil).AnyTimes()
+	users.EXPECT().GetUser(gomock.Any(), "nobody").Return(nil, user.ErrNoUser).AnyTimes()
+
+	t.Run("пост", func(t *testing.T) {
+		postsRepo.EXPECT().GetPost(gomock.Any(), postID).Return(post, nil)
+		resp, err := client.GetPost(ctx, &pb.GetPostRequest{Id: postID.Hex()})
+		require.NoError(t, err)
+		assert.Equal(t, postID.Hex(), resp.GetId())
+		assert.Equal(t, int32(2), resp.GetScore())
+		assert.Equal(t, "rvasily", resp.GetAuthor().GetUsername())
+		assert.Len(t, resp.GetVotes(), 2)
+		require.Len(t, resp.GetComments(), 1)
+		assert.Equal(t, parentID.Hex(), resp.GetComments()[0].GetParentId())
+	})
+
+	t.Run("список по нескольким условиям", func(t *testing.T) {
+		other := &posts.Post{ID: primitive.NewObjectID(), Category: "music", Author: &user.User{Username: "other"}}
+		postsRepo.EXPECT().GetPosts(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, filter func(*posts.Post) bool) ([]*posts.Post, error) {
+				var found []*posts.Post
+				for _, p := range []*posts.Post{post, other} {
+					if filter(p) {
+						found = append(found, p)
+					}
+				}
+				return found, nil
+			})
+		resp, err := client.ListPosts(ctx, &pb.ListPostsRequest{Category: "music", Author: "rvasily", Ids: []string{postID.Hex(), other.ID.Hex()}})
+		require.NoError(t, err)
+		require.Len(t, resp.GetPosts(), 1)
+		assert.Equal(t, postID.Hex(), resp.GetPosts()[0].GetId())
+	})
+
+	t.Run("публикация", func(t *testing.T) {
+		form := &posts.PostForm{Type: posts.TypeText, Title: "title", Category: "music", Text: "**text**"}
+		postsRepo.EXPECT().MakePost(gomock.Any(), form, "rvasily", int64(1)).Return(post, nil)
+		resp, err := client.CreatePost(ctx, &pb.CreatePostRequest{Author: "rvasily", Type: posts.TypeText, Title: "title", Category: "music", Text: "**text**"})
+		require.NoError(t, err)
+		assert.Equal(t, "<p><strong>text</strong></p>\n", resp.GetHtml())
+	})
+
+	t.Run("комментарий", func(t *testing.T) {
+		postsRepo.EXPECT().MakeComment(gomock.Any(), postID, commentID, "reply", "rvasily", int64(1)).Return(post, nil)
+		_, err := client.AddComment(ctx, &pb.AddCommentRequest{PostId: postID.Hex(), ParentId: commentID.Hex(), Author: "rvasily", Body: "reply"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("голос", func(t *testing.T) {
+		postsRepo.EXPECT().VotePost(gomock.Any(), postID, int64(1), -1).Return(post, nil)
+		_, err := client.Vote(ctx, &pb.VoteRequest{PostId: postID.Hex(), User: "rvasily", Vote: -1})
+		assert.NoError(t, err)
+	})
+
+	errorCases := []struct {
+		name       string
+		prepare    func()
+		call       func() error
+		code       codes.Code
+		violations map[string]string
+	}{
+		{
+			name: "невалидный id",
+			call: func() error {
+				_, err := client.GetPost(ctx, &pb.GetPostRequest{Id: "bad"})
+				return err
+			},
+			code:       codes.InvalidArgument,
+			violations: map[string]string{"id": "invalid id"},
+		},
+		{
+			name: "пост не найден",
+			prepare: func() {
+				postsRepo.EXPECT().GetPost(gomock.Any(), postID).Return(nil, posts.ErrPostNotFound)
+			},
+			call: func() error {
+				_, err := client.GetPost(ctx, &pb.GetPostRequest{Id: postID.Hex()})
+				return err
+			},
+			code: codes.NotFound,
+		},
+		{
+			name: "ошибка хранилища",
+			prepare: func() {
+				postsRepo.EXPECT().GetPosts(gomock.Any(), gomock.Any()).Return(nil, posts.ErrStorage)
+			},
+			call: func() error {
+				_, err := client.ListPosts(ctx, &pb.ListPostsRequest{})
+				return err
+			},
+			code: codes.Internal,
+		},
+		{
+			name: "пустая форма поста",
+			call: func() error {
+				_, err := client.CreatePost(ctx, &pb.CreatePostRequest{Author: "rvasily", Type: posts.TypeText})
+				return err
+			},
+			code:       codes.InvalidArgument,
+			violations: map[string]string{"title": "is required", "category": "is required"},
+		},
+		{
+			name: "опрос через gRPC",
+			call: func() error {
+				_, err := client.CreatePost(ctx, &pb.CreatePostRequest{Author: "rvasily", Type: posts.TypePoll, Title: "t", Category: "music"})
+				return err
+			},
+			code:       codes.InvalidArgument,
+			violations: map[string]string{"type": "must be one of text, link"},
+		},
+		{
+			name: "неизвестный автор",
+			call: func() error {
+				_, err := client.CreatePost(ctx, &pb.CreatePostRequest{Author: "nobody", Type: posts.TypeText, Title: "t", Category: "music"})
+				return err
+			},
+			code: codes.NotFound,
+		},
+		{
+			name: "пустой комментарий",
+			call: func() error {
+				_, err := client.AddComment(ctx, &pb.AddCommentRequest{PostId: postID.Hex(), Author: "rvasily"})
+				return err
+			},
+			code:       codes.InvalidArgument,
+			violations: map[string]string{"body": "is required"},
+		},
+		{
+			name: "закрытый пост",
+			prepare: func() {
+				postsRepo.EXPECT().MakeComment(gomock.Any(), postID, primitive.NilObjectID, "hi", "rvasily", int64(1)).
+					Return(nil, posts.ErrPostLocked)
+			},
+			call: func() error {
+				_, err := client.AddComment(ctx, &pb.AddCommentRequest{PostId: postID.Hex(), Author: "rvasily", Body: "hi"})
+				return err
+			},
+			code: codes.FailedPrecondition,
+		},
+		{
+			name: "неверный голос",
+			call: func() error {
+				_, err := client.Vote(ctx, &pb.VoteRequest{PostId: postID.Hex(), User: "rvasily", Vote: 2})
+				return err
+			},
+			code:       codes.InvalidArgument,
+			violations: map[string]string{"vote": posts.ErrBadVote.Error()},
+		},
+		{
+			name: "голос без пользователя",
+			call: func() error {
+				_, err := client.Vote(ctx, &pb.VoteRequest{PostId: postID.Hex(), Vote: 1})
+				return err
+			},
+			code:       codes.InvalidArgument,
+			violations: map[string]string{"user": "is required"},
+		},
+		{
+			name: "отменённый запрос",
+			prepare: func() {
+				postsRepo.EXPECT().GetPost(gomock.Any(), postID).Return(nil, errors.Join(posts.ErrStorage, context.Canceled))
+			},
+			call: func() error {
+				_, err := client.GetPost(ctx, &pb.GetPostRequest{Id: postID.Hex()})
+				return err
+			},
+			code: codes.Canceled,
+		},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.prepare != nil {
+				tc.prepare()
+			}
+			err := tc.call()
+			assert.Equal(t, tc.code, status.Code(err))
+			if tc.violations != nil {
+				assert.Equal(t, tc.violations, fieldViolations(t, err))
+			}
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	certs := newTestCerts(t)
+	ctrl := gomock.NewController(t)
+	users := user.NewMockUserRepo(ctrl)
+	users.EXPECT().GetUser(gomock.Any(), "rvasily").Return(&user.User{ID: 1, Username: "rvasily"}, nil)
+	srv := NewServer(nil, users, zap.NewNop().Sugar())
+	config := Config{CertFile: certs.serverCert, KeyFile: certs.serverKey, ClientCAFile: certs.caFile}
+
+	testCases := []struct {
+		name   string
+		client credentials.TransportCredentials
+		code   codes.Code
+	}{
+		{name: "без клиентского сертификата", client: certs.clientCreds(t, false), code: codes.Unavailable},
+		{name: "с клиентским сертификатом", client: certs.clientCreds(t, true), code: codes.OK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := startServer(t, srv, config, grpc.WithTransportCredentials(tc.client))
+			_, err := pb.NewRedditClient(conn).GetUser(context.Background(), &pb.GetUserRequest{Username: "rvasily"})
+			assert.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
+
+type testCerts struct {
+	dir                           string
+	caFile, serverCert, serverKey string
+	clientCert, clientKey         string
+	caPool                        *x509.CertPool
+}
+
+// newTestCerts выпускает во временном каталоге CA, сертификат сервера на bufnet и клиентский сертификат.
+func newTestCerts(t *testing.T) *testCerts {
+	t.Helper()
+	certs := &testCerts{dir: t.TempDir(), caPool: x509.NewCertPool()}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+	certs.caPool.AddCert(caCert)
+	certs.caFile = certs.write(t, "ca.pem", "CERTIFICATE", caDER)
+
+	issue := func(name string, serial int64, usage x509.ExtKeyUsage) (string, string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		return certs.write(t, name+".pem", "CERTIFICATE", der), certs.write(t, name+"-key.pem", "EC PRIVATE KEY", keyDER)
+	}
+	certs.serverCert, certs.serverKey = issue("bufnet", 2, x509.ExtKeyUsageServerAuth)
+	certs.clientCert, certs.clientKey = issue("client", 3, x509.ExtKeyUsageClientAuth)
+	return certs
+}
+
+func (c *testCerts) write(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(c.dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+func (c *testCerts) clientCreds(t *testing.T, withCert bool) credentials.TransportCredentials {
+	config := &tls.Config{RootCAs: c.caPool, ServerName: "bufnet", MinVersion: tls.VersionTLS12}
+	if withCert {
+		cert, err := tls.LoadX509KeyPair(c.clientCert, c.clientKey)
+		require.NoError(t, err)
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(config)
+}
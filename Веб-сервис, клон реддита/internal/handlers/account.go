@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"redditclone/internal/collections"
+	"redditclone/internal/moderation"
+	"redditclone/internal/notifications"
+	"redditclone/internal/posts"
+	"redditclone/internal/subscriptions"
+	"redditclone/internal/user"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// accountExport - всё, что сервис хранит о пользователе, включая удалённые им, но ещё не стёртые посты
+// и комментарии. Чужие комментарии и голоса в его постах в выгрузку не попадают: это данные других пользователей.
+type accountExport struct {
+	Profile       *user.User                    `json:"profile"`
+	ExportedAt    time.Time                     `json:"exportedAt"`
+	Posts         []*posts.Post                 `json:"posts"`
+	Comments      []exportComment               `json:"comments"`
+	Votes         []exportVote                  `json:"votes"`
+	PollVotes     []exportPollVote              `json:"pollVotes"`
+	Saved         []exportItem                  `json:"saved"`
+	Hidden        []exportItem                  `json:"hidden"`
+	Subscriptions []*subscriptions.Subscription `json:"subscriptions"`
+	Notifications []*notifications.Notification `json:"notifications"`
+	Reports       []*moderation.Report          `json:"reports"`
+}
+
+type exportComment struct {
+	PostID    primitive.ObjectID `json:"postId"`
+	PostTitle string             `json:"postTitle"`
+	*posts.Comment
+}
+
+type exportVote struct {
+	PostID    primitive.ObjectID `json:"postId"`
+	PostTitle string             `json:"postTitle"`
+	Vote      int                `json:"vote"`
+}
+
+type exportPollVote struct {
+	PostID    primitive.ObjectID `json:"postId"`
+	PostTitle string             `json:"postTitle"`
+	Option    int                `json:"option"`
+}
+
+type exportItem struct {
+	Type      collections.ItemType `json:"type"`
+	PostID    primitive.ObjectID   `json:"postId"`
+	CommentID *primitive.ObjectID  `json:"commentId,omitempty"`
+	Created   time.Time            `json:"created"`
+}
+
+func newExportItems(items []*collections.Item) []exportItem {
+	list := make([]exportItem, 0, len(items))
+	for _, item := range items {
+		exported := exportItem{Type: item.Type, PostID: item.PostID, Created: item.Created}
+		if item.Type == collections.ItemComment {
+			commentID := item.CommentID
+			exported.CommentID = &commentID
+		}
+		list = append(list, exported)
+	}
+	return list
+}
+
+// newAccountExport раскладывает посты, в которых участвовал пользователь, по разделам выгрузки.
+func newAccountExport(profile *user.User, list []*posts.Post, now time.Time) *accountExport {
+	export := &accountExport{
+		Profile:       profile,
+		ExportedAt:    now,
+		Posts:         []*posts.Post{},
+		Comments:      []exportComment{},
+		Votes:         []exportVote{},
+		PollVotes:     []exportPollVote{},
+		Saved:         []exportItem{},
+		Hidden:        []exportItem{},
+		Subscriptions: []*subscriptions.Subscription{},
+		Notifications: []*notifications.Notification{},
+		Reports:       []*moderation.Report{},
+	}
+	for _, post := range list {
+		if post.Author != nil && post.Author.ID == profile.ID {
+			own := *post
+			own.Comments, own.Votes = nil, nil
+			export.Posts = append(export.Posts, &own)
+		}
+		for _, comment := range post.Comments {
+			if comment.Author != nil && comment.Author.ID == profile.ID {
+				export.Comments = append(export.Comments, exportComment{PostID: post.ID, PostTitle: post.Title, Comment: comment})
+			}
+		}
+		for _, vote := range post.Votes {
+			if vote.UserID == profile.ID {
+				export.Votes = append(export.Votes, exportVote{PostID: post.ID, PostTitle: post.Title, Vote: vote.Vote})
+			}
+		}
+		if post.Poll == nil {
+			continue
+		}
+		for _, voter := range post.Poll.Voters {
+			if voter.UserID == profile.ID {
+				export.PollVotes = append(export.PollVotes, exportPollVote{PostID: post.ID, PostTitle: post.Title, Option: voter.Option})
+			}
+		}
+	}
+	return export
+}
+
+// addPersonalData дополняет выгрузку списками, подписками, уведомлениями и жалобами пользователя.
+// Хранилища, которые сервису не подключены, пропускаются.
+func (h *PostsHandler) addPersonalData(ctx context.Context, export *accountExport) error {
+	userID := export.Profile.ID
+	if h.Collections != nil {
+		saved, err := h.Collections.List(ctx, userID, collections.KindSaved, 0, 0)
+		if err != nil {
+			return err
+		}
+		hidden, err := h.Collections.List(ctx, userID, collections.KindHidden, 0, 0)
+		if err != nil {
+			return err
+		}
+		export.Saved, export.Hidden = newExportItems(saved), newExportItems(hidden)
+	}
+	if h.Subscriptions != nil {
+		subs, err := h.Subscriptions.List(ctx, userID)
+		if err != nil {
+			return err
+		}
+		export.Subscriptions = subs
+	}
+	if h.Notifications != nil {
+		items, err := h.Notifications.List(ctx, userID, false, 0, 0)
+		if err != nil {
+			return err
+		}
+		export.Notifications = items
+	}
+	if h.Reports != nil {
+		reports, err := h.Reports.ReportsBy(ctx, userID)
+		if err != nil {
+			return err
+		}
+		export.Reports = reports
+	}
+	return nil
+}
+
+// deletePersonalData стирает личные списки, подписки, уведомления и жалобы пользователя.
+// Все шаги идемпотентны, поэтому после сбоя удаление можно повторить целиком.
+func (h *PostsHandler) deletePersonalData(ctx context.Context, userID int64, username string) (map[string]int64, error) {
+	deleted := map[string]int64{}
+	var err error
+	if h.Collections != nil {
+		if deleted["collection_items"], err = h.Collections.DeleteUser(ctx, userID); err != nil {
+			return deleted, err
+		}
+	}
+	if h.Subscriptions != nil {
+		if deleted["subscriptions"], err = h.Subscriptions.DeleteUser(ctx, userID, username); err != nil {
+			return deleted, err
+		}
+	}
+	if h.Notifications != nil {
+		if deleted["notifications"], err = h.Notifications.DeleteUser(ctx, userID, username); err != nil {
+			return deleted, err
+		}
+	}
+	if h.Reports != nil {
+		if deleted["reports"], err = h.Reports.DeleteReportsBy(ctx, userID); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// zipArchive складывает разделы выгрузки в отдельные JSON файлы.
+func (e *accountExport) zipArchive() ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"profile.json", e.Profile},
+		{"posts.json", e.Posts},
+		{"comments.json", e.Comments},
+		{"votes.json", e.Votes},
+		{"poll_votes.json", e.PollVotes},
+		{"saved.json", e.Saved},
+		{"hidden.json", e.Hidden},
+		{"subscriptions.json", e.Subscriptions},
+		{"notifications.json", e.Notifications},
+		{"reports.json", e.Reports},
+	}
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		fw, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: e.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		if _, err = fw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportAccount отдаёт выгрузку данных пользователя: JSON, а с ?format=zip - архив из JSON файлов.
+func (h *PostsHandler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger)
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "zip" {
+		writeError(w, r, logger, ErrBadRequest)
+		return
+	}
+
+	userID, username, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	profile, err := h.Users.GetUser(r.Context(), username)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	list, err := h.PostsRepo.UserContent(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	export := newAccountExport(profile, list, time.Now().UTC())
+	if err = h.addPersonalData(r.Context(), export); err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	logger.Infow("account exported", "format", format,
+		"posts", len(export.Posts), "comments", len(export.Comments), "votes", len(export.Votes),
+		"poll_votes", len(export.PollVotes), "saved", len(export.Saved), "hidden", len(export.Hidden),
+		"subscriptions", len(export.Subscriptions), "notifications", len(export.Notifications), "reports", len(export.Reports))
+	name := "redditclone-" + username + "-" + export.ExportedAt.Format("20060102")
+	if format != "zip" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".json"))
+		writeJSON(w, logger, http.StatusOK, export)
+		return
+	}
+
+	archive, err := export.zipArchive()
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".zip"))
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(archive); err != nil {
+		logger.Errorw("failed to write response", "error", err)
+	}
+}
+
+// DeleteAccount удаляет учётную запись. Посты и комментарии остаются под автором [deleted],
+// голоса снимаются, голоса в опросах обезличиваются, личные списки, подписки, уведомления и жалобы
+// стираются, все сессии завершаются. Шаги идут в таком порядке, чтобы после сбоя на любом из них
+// запрос можно было повторить тем же токеном.
+func (h *PostsHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger)
+
+	userID, username, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	res, err := h.PostsRepo.AnonymizeUser(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	deleted, err := h.deletePersonalData(r.Context(), userID, username)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	// Пользователя уже нет - значит, прошлый запрос упал после удаления, доделываем остальное.
+	if err = h.Users.DeleteUser(r.Context(), userID); err != nil && !errors.Is(err, user.ErrNoUser) {
+		writeError(w, r, logger, err)
+		return
+	}
+	revoked, err := h.Sessions.RevokeAll(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	logger.Infow("account deleted", "posts", res.Posts, "posts_with_comments", res.Comments,
+		"votes", res.Votes, "poll_votes", res.PollVotes, "deleted", deleted, "sessions", revoked)
+	writeJSON(w, logger, http.StatusOK, SuccessResponse)
+}
@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/collections"
+	"redditclone/internal/moderation"
+	"redditclone/internal/notifications"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"redditclone/internal/subscriptions"
+	"redditclone/internal/user"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func TestNewAccountExport(t *testing.T) {
+	me := &user.User{ID: newUser.ID, Username: newUser.Username}
+	other := &user.User{ID: newUser.ID + 1, Username: "ivan"}
+	deletedAt := time.Now().Add(-time.Hour)
+	mine := &posts.Post{
+		ID:       primitive.NewObjectID(),
+		Title:    "мой пост",
+		Author:   me,
+		Votes:    []*posts.Vote{{UserID: me.ID, Vote: 1}, {UserID: other.ID, Vote: -1}},
+		Comments: []*posts.Comment{{Author: other, Body: "чужой"}, {Author: me, Body: "свой"}},
+	}
+	removed := &posts.Post{
+		ID:       primitive.NewObjectID(),
+		Title:    "удалённый пост",
+		Author:   me,
+		Deleted:  &deletedAt,
+		Comments: []*posts.Comment{},
+	}
+	theirs := &posts.Post{
+		ID:       primitive.NewObjectID(),
+		Title:    "чужой пост",
+		Author:   other,
+		Votes:    []*posts.Vote{{UserID: me.ID, Vote: -1}},
+		Comments: []*posts.Comment{{Author: me, Body: "удалённый", Deleted: &deletedAt}},
+		Poll: &posts.Poll{
+			Options: []*posts.PollOption{{ID: 1, Text: "да"}, {ID: 2, Text: "нет"}},
+			Voters:  []posts.PollVoter{{UserID: other.ID, Option: 1}, {UserID: me.ID, Option: 2}},
+		},
+	}
+
+	export := newAccountExport(me, []*posts.Post{mine, removed, theirs}, time.Now())
+
+	require.Len(t, export.Posts, 2, "soft-deleted posts are exported too")
+	assert.Equal(t, mine.ID, export.Posts[0].ID)
+	assert.Equal(t, &deletedAt, export.Posts[1].Deleted)
+	assert.Nil(t, export.Posts[0].Comments, "other users' comments are not exported")
+	assert.Nil(t, export.Posts[0].Votes, "other users' votes are not exported")
+	assert.Len(t, mine.Comments, 2, "source post is not modified")
+
+	require.Len(t, export.Comments, 2)
+	assert.Equal(t, "свой", export.Comments[0].Body)
+	assert.Equal(t, mine.ID, export.Comments[0].PostID)
+	assert.Equal(t, &deletedAt, export.Comments[1].Deleted, "soft-deleted comments are exported too")
+
+	assert.Equal(t, []exportVote{
+		{PostID: mine.ID, PostTitle: "мой пост", Vote: 1},
+		{PostID: theirs.ID, PostTitle: "чужой пост", Vote: -1},
+	}, export.Votes)
+	assert.Equal(t, []exportPollVote{{PostID: theirs.ID, PostTitle: "чужой пост", Option: 2}}, export.PollVotes)
+}
+
+func TestExportAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	postsRepo := posts.NewMockPostRepo(ctrl)
+	userRepo := user.NewMockUserRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	collectionRepo := collections.NewMockCollectionRepo(ctrl)
+	subscriptionRepo := subscriptions.NewMockSubscriptionRepo(ctrl)
+	notificationRepo := notifications.NewMockNotificationRepo(ctrl)
+	reportRepo := moderation.NewMockReportRepo(ctrl)
+	service := &PostsHandler{
+		PostsRepo:     postsRepo,
+		Users:         userRepo,
+		Logger:        zap.NewNop().Sugar(),
+		Sessions:      mockSessions,
+		Collections:   collectionRepo,
+		Subscriptions: subscriptionRepo,
+		Notifications: notificationRepo,
+		Reports:       reportRepo,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/me/export", service.ExportAccount).Methods("GET")
+
+	profile := &user.User{ID: newUser.ID, Username: newUser.Username}
+	post := &posts.Post{ID: primitive.NewObjectID(), Title: "пост", Author: profile, Votes: []*posts.Vote{{UserID: profile.ID, Vote: 1}}}
+	commentID := primitive.NewObjectID()
+	saved := []*collections.Item{
+		{UserID: profile.ID, Kind: collections.KindSaved, Type: collections.ItemPost, PostID: post.ID},
+		{UserID: profile.ID, Kind: collections.KindSaved, Type: collections.ItemComment, PostID: post.ID, CommentID: commentID},
+	}
+	subs := []*subscriptions.Subscription{{UserID: profile.ID, Type: subscriptions.TypeUser, Target: "ivan"}}
+	items := []*notifications.Notification{{ID: primitive.NewObjectID(), UserID: profile.ID, Actor: "ivan", PostID: post.ID}}
+	reports := []*moderation.Report{{ID: primitive.NewObjectID(), Target: moderation.Target{PostID: post.ID}, ReporterID: profile.ID, Reason: moderation.ReasonSpam}}
+	personalData := func() {
+		collectionRepo.EXPECT().List(gomock.Any(), profile.ID, collections.KindSaved, 0, 0).Return(saved, nil)
+		collectionRepo.EXPECT().List(gomock.Any(), profile.ID, collections.KindHidden, 0, 0).Return(nil, nil)
+		subscriptionRepo.EXPECT().List(gomock.Any(), profile.ID).Return(subs, nil)
+		notificationRepo.EXPECT().List(gomock.Any(), profile.ID, false, 0, 0).Return(items, nil)
+		reportRepo.EXPECT().ReportsBy(gomock.Any(), profile.ID).Return(reports, nil)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		token      string
+		setupMocks func()
+		wantStatus int
+		wantType   string
+		checkBody  func(t *testing.T, body []byte)
+	}{
+		{
+			name:  "JSON",
+			token: jwtToken,
+			setupMocks: func() {
+				userRepo.EXPECT().GetUser(gomock.Any(), newUser.Username).Return(profile, nil)
+				postsRepo.EXPECT().UserContent(gomock.Any(), profile.ID).Return([]*posts.Post{post}, nil)
+				personalData()
+			},
+			wantStatus: http.StatusOK,
+			wantType:   "application/json; charset=utf-8",
+			checkBody: func(t *testing.T, body []byte) {
+				var export accountExport
+				require.NoError(t, json.Unmarshal(body, &export))
+				assert.Equal(t, profile, export.Profile)
+				assert.Len(t, export.Posts, 1)
+				assert.Len(t, export.Votes, 1)
+				assert.Empty(t, export.Comments)
+				require.Len(t, export.Saved, 2)
+				assert.Nil(t, export.Saved[0].CommentID)
+				assert.Equal(t, &commentID, export.Saved[1].CommentID)
+				assert.NotNil(t, export.Hidden)
+				assert.Empty(t, export.Hidden)
+				assert.Equal(t, "ivan", export.Subscriptions[0].Target)
+				assert.Len(t, export.Notifications, 1)
+				assert.Len(t, export.Reports, 1)
+			},
+		},
+		{
+			name:  "ZIP",
+			query: "?format=zip",
+			token: jwtToken,
+			setupMocks: func() {
+				userRepo.EXPECT().GetUser(gomock.Any(), newUser.Username).Return(profile, nil)
+				postsRepo.EXPECT().UserContent(gomock.Any(), profile.ID).Return([]*posts.Post{post}, nil)
+				personalData()
+			},
+			wantStatus: http.StatusOK,
+			wantType:   "application/zip",
+			checkBody: func(t *testing.T, body []byte) {
+				archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+				require.NoError(t, err)
+				names := []string{}
+				for _, f := range archive.File {
+					names = append(names, f.Name)
+				}
+				assert.Equal(t, []string{
+					"profile.json", "posts.json", "comments.json", "votes.json", "poll_votes.json",
+					"saved.json", "hidden.json", "subscriptions.json", "notifications.json", "reports.json",
+				}, names)
+
+				f, err := archive.Open("profile.json")
+				require.NoError(t, err)
+				data, err := io.ReadAll(f)
+				require.NoError(t, err)
+				assert.JSONEq(t, `{"id":1,"username":"rvasily"}`, string(data))
+			},
+		},
+		{
+			name:       "Неизвестный формат",
+			query:      "?format=xml",
+			token:      jwtToken,
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Ошибка хранилища",
+			token: jwtToken,
+			setupMocks: func() {
+				userRepo.EXPECT().GetUser(gomock.Any(), newUser.Username).Return(profile, nil)
+				postsRepo.EXPECT().UserContent(gomock.Any(), profile.ID).Return(nil, posts.ErrStorage)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:  "Ошибка хранилища жалоб",
+			token: jwtToken,
+			setupMocks: func() {
+				userRepo.EXPECT().GetUser(gomock.Any(), newUser.Username).Return(profile, nil)
+				postsRepo.EXPECT().UserContent(gomock.Any(), profile.ID).Return([]*posts.Post{post}, nil)
+				collectionRepo.EXPECT().List(gomock.Any(), profile.ID, gomock.Any(), 0, 0).Return(nil, nil).Times(2)
+				subscriptionRepo.EXPECT().List(gomock.Any(), profile.ID).Return(nil, nil)
+				notificationRepo.EXPECT().List(gomock.Any(), profile.ID, false, 0, 0).Return(nil, nil)
+				reportRepo.EXPECT().ReportsBy(gomock.Any(), profile.ID).Return(nil, moderation.ErrStorage)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "Без авторизации",
+			setupMocks: func() {},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+
+			req := httptest.NewRequest("GET", "/api/me/export"+tc.query, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tc.wantType, w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+			tc.checkBody(t, w.Body.Bytes())
+		})
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	postsRepo := posts.NewMockPostRepo(ctrl)
+	userRepo := user.NewMockUserRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	collectionRepo := collections.NewMockCollectionRepo(ctrl)
+	subscriptionRepo := subscriptions.NewMockSubscriptionRepo(ctrl)
+	notificationRepo := notifications.NewMockNotificationRepo(ctrl)
+	reportRepo := moderation.NewMockReportRepo(ctrl)
+	service := &PostsHandler{
+		PostsRepo:     postsRepo,
+		Users:         userRepo,
+		Logger:        zap.NewNop().Sugar(),
+		Sessions:      mockSessions,
+		Collections:   collectionRepo,
+		Subscriptions: subscriptionRepo,
+		Notifications: notificationRepo,
+		Reports:       reportRepo,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/me", service.DeleteAccount).Methods("DELETE")
+
+	anonymized := posts.AnonymizeResult{Posts: 2, Comments: 3, Votes: 4, PollVotes: 1}
+	personalData := func() {
+		gomock.InOrder(
+			collectionRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID).Return(int64(3), nil),
+			subscriptionRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID, newUser.Username).Return(int64(2), nil),
+			notificationRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID, newUser.Username).Return(int64(5), nil),
+			reportRepo.EXPECT().DeleteReportsBy(gomock.Any(), newUser.ID).Return(int64(1), nil),
+		)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name:  "Аккаунт удаляется",
+			token: jwtToken,
+			setupMocks: func() {
+				gomock.InOrder(
+					postsRepo.EXPECT().AnonymizeUser(gomock.Any(), newUser.ID).Return(anonymized, nil),
+					collectionRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID).Return(int64(3), nil),
+					subscriptionRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID, newUser.Username).Return(int64(2), nil),
+					notificationRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID, newUser.Username).Return(int64(5), nil),
+					reportRepo.EXPECT().DeleteReportsBy(gomock.Any(), newUser.ID).Return(int64(1), nil),
+					userRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID).Return(nil),
+					mockSessions.EXPECT().RevokeAll(gomock.Any(), newUser.ID).Return(2, nil),
+				)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Повтор после сбоя: пользователь уже удалён",
+			token: jwtToken,
+			setupMocks: func() {
+				postsRepo.EXPECT().AnonymizeUser(gomock.Any(), newUser.ID).Return(posts.AnonymizeResult{}, nil)
+				personalData()
+				userRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID).Return(user.ErrNoUser)
+				mockSessions.EXPECT().RevokeAll(gomock.Any(), newUser.ID).Return(1, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Посты не обезличены - аккаунт не трогаем",
+			token: jwtToken,
+			setupMocks: func() {
+				postsRepo.EXPECT().AnonymizeUser(gomock.Any(), newUser.ID).Return(posts.AnonymizeResult{}, posts.ErrStorage)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:  "Подписки не удалены - аккаунт не трогаем",
+			token: jwtToken,
+			setupMocks: func() {
+				postsRepo.EXPECT().AnonymizeUser(gomock.Any(), newUser.ID).Return(anonymized, nil)
+				collectionRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID).Return(int64(0), nil)
+				subscriptionRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID, newUser.Username).Return(int64(0), subscriptions.ErrStorage)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:  "Ошибка mysql",
+			token: jwtToken,
+			setupMocks: func() {
+				postsRepo.EXPECT().AnonymizeUser(gomock.Any(), newUser.ID).Return(anonymized, nil)
+				personalData()
+				userRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID).Return(user.ErrStorage)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:  "Ошибка redis",
+			token: jwtToken,
+			setupMocks: func() {
+				postsRepo.EXPECT().AnonymizeUser(gomock.Any(), newUser.ID).Return(anonymized, nil)
+				personalData()
+				userRepo.EXPECT().DeleteUser(gomock.Any(), newUser.ID).Return(nil)
+				mockSessions.EXPECT().RevokeAll(gomock.Any(), newUser.ID).Return(0, errors.New("redis is down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "Без авторизации",
+			setupMocks: func() {},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+
+			req := httptest.NewRequest("DELETE", "/api/me", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
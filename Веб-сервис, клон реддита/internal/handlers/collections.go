@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"net/http"
+	"redditclone/internal/collections"
+	"redditclone/internal/posts"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPageLimit = 25
+	maxPageLimit     = 100
+)
+
+var ErrBadPagination = &HTTPError{Status: http.StatusBadRequest, Code: "bad_pagination", Message: "page and limit must be positive numbers"}
+
+// collectionEntry - запись личного списка вместе с самим постом или комментарием.
+type collectionEntry struct {
+	Type    collections.ItemType `json:"type"`
+	Created time.Time            `json:"created"`
+	PostID  primitive.ObjectID   `json:"postId"`
+	Post    *posts.Post          `json:"post,omitempty"`
+	Comment *posts.Comment       `json:"comment,omitempty"`
+}
+
+type collectionPage struct {
+	Items   []collectionEntry `json:"items"`
+	Page    int               `json:"page"`
+	Limit   int               `json:"limit"`
+	HasMore bool              `json:"hasMore"`
+}
+
+func (h *PostsHandler) SaveItem(w http.ResponseWriter, r *http.Request) {
+	collectionItemHandler(w, r, h, collections.KindSaved, true)
+}
+
+func (h *PostsHandler) UnsaveItem(w http.ResponseWriter, r *http.Request) {
+	collectionItemHandler(w, r, h, collections.KindSaved, false)
+}
+
+func (h *PostsHandler) HideItem(w http.ResponseWriter, r *http.Request) {
+	collectionItemHandler(w, r, h, collections.KindHidden, true)
+}
+
+func (h *PostsHandler) UnhideItem(w http.ResponseWriter, r *http.Request) {
+	collectionItemHandler(w, r, h, collections.KindHidden, false)
+}
+
+func (h *PostsHandler) GetSaved(w http.ResponseWriter, r *http.Request) {
+	collectionListHandler(w, r, h, collections.KindSaved)
+}
+
+func (h *PostsHandler) GetHidden(w http.ResponseWriter, r *http.Request) {
+	collectionListHandler(w, r, h, collections.KindHidden)
+}
+
+// collectionItemHandler добавляет пост или комментарий в личный список или убирает из него.
+// Комментарий адресуется маршрутом с COMMENT_ID, пост - без него.
+func collectionItemHandler(w http.ResponseWriter, r *http.Request, h *PostsHandler, kind collections.Kind, add bool) {
+	vars := mux.Vars(r)
+	logger := requestLogger(r, h.Logger).With("post_id", vars["POST_ID"], "kind", kind)
+
+	postID, err := primitive.ObjectIDFromHex(vars["POST_ID"])
+	if err != nil {
+		writeError(w, r, logger, ErrInvalidID)
+		return
+	}
+
+	item := &collections.Item{Kind: kind, Type: collections.ItemPost, PostID: postID}
+	if rawCommentID, ok := vars["COMMENT_ID"]; ok {
+		item.Type = collections.ItemComment
+		item.CommentID, err = primitive.ObjectIDFromHex(rawCommentID)
+		if err != nil {
+			writeError(w, r, logger, ErrInvalidID)
+			return
+		}
+		logger = logger.With("comment_id", rawCommentID)
+	}
+
+	item.UserID, _, err = authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", item.UserID)
+
+	if add {
+		// Добавлять можно только то, что существует. Убирать - что угодно, в том числе удалённые посты.
+		if err = h.checkItemExists(r, item); err != nil {
+			writeError(w, r, logger, err)
+			return
+		}
+		err = h.Collections.Add(r.Context(), item)
+	} else {
+		err = h.Collections.Remove(r.Context(), item)
+	}
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	logger.Infow("collection updated", "type", item.Type, "add", add)
+	writeJSON(w, logger, http.StatusOK, SuccessResponse)
+}
+
+func (h *PostsHandler) checkItemExists(r *http.Request, item *collections.Item) error {
+	post, err := h.PostsRepo.GetPost(r.Context(), item.PostID)
+	if err != nil {
+		return err
+	}
+	if item.Type == collections.ItemPost {
+		return nil
+	}
+	for _, comment := range post.Comments {
+		if comment.ID == item.CommentID {
+			return nil
+		}
+	}
+	return posts.ErrCommentNotFound
+}
+
+// collectionListHandler отдаёт страницу личного списка, от новых записей к старым.
+func collectionListHandler(w http.ResponseWriter, r *http.Request, h *PostsHandler, kind collections.Kind) {
+	logger := requestLogger(r, h.Logger).With("kind", kind)
+
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	userID, _, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	// Берём на одну запись больше, чтобы понять, есть ли следующая страница.
+	items, err := h.Collections.List(r.Context(), userID, kind, (page-1)*limit, limit+1)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	resp := collectionPage{Items: []collectionEntry{}, Page: page, Limit: limit}
+	if len(items) > limit {
+		items = items[:limit]
+		resp.HasMore = true
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.PostID)
+	}
+	found, err := h.PostsRepo.GetPostsByIDs(r.Context(), ids)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	byID := make(map[primitive.ObjectID]*posts.Post, len(found))
+	for _, post := range found {
+		if post.Removed {
+			continue
+		}
+		posts.VisibleComments(post)
+		byID[post.ID] = post
+	}
+
+	for _, item := range items {
+		// Удалённые и снятые модераторами посты и комментарии молча пропускаем.
+		if entry, ok := makeCollectionEntry(item, byID[item.PostID]); ok {
+			resp.Items = append(resp.Items, entry)
+		}
+	}
+
+	logger.Infow("collection received", "count", len(resp.Items), "page", page)
+	writeJSON(w, logger, http.StatusOK, resp)
+}
+
+func makeCollectionEntry(item *collections.Item, post *posts.Post) (collectionEntry, bool) {
+	if post == nil {
+		return collectionEntry{}, false
+	}
+
+	entry := collectionEntry{Type: item.Type, Created: item.Created, PostID: item.PostID}
+	if item.Type == collections.ItemPost {
+		entry.Post = post
+		return entry, true
+	}
+	for _, comment := range post.Comments {
+		if comment.ID == item.CommentID {
+			entry.Comment = comment
+			return entry, true
+		}
+	}
+	return collectionEntry{}, false
+}
+
+func parsePagination(r *http.Request) (int, int, error) {
+	page := 1
+	if raw := r.URL.Query().Get("page"); raw != "" {
+		var err error
+		if page, err = strconv.Atoi(raw); err != nil || page < 1 {
+			return 0, 0, ErrBadPagination
+		}
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return page, limit, nil
+}
+
+// parseLimit читает размер страницы из ?limit=, по умолчанию defaultPageLimit, не больше maxPageLimit.
+func parseLimit(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 {
+		return 0, ErrBadPagination
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return limit, nil
+}
+
+// callerID возвращает пользователя, если запрос пришёл с действующим токеном. Для анонимов - false.
+func (h *PostsHandler) callerID(r *http.Request) (int64, bool) {
+	userID, _, err := authUser(r, h)
+	return userID, err == nil
+}
+
+// withoutHidden убирает из выдачи посты, которые вызывающий скрыл.
+// Анонимы и ошибки хранилища списков получают выдачу без фильтра.
+func (h *PostsHandler) withoutHidden(r *http.Request, logger *zap.SugaredLogger, filter func(*posts.Post) bool) func(*posts.Post) bool {
+	if h.Collections == nil {
+		return filter
+	}
+	userID, ok := h.callerID(r)
+	if !ok {
+		return filter
+	}
+
+	hidden, err := h.Collections.Hidden(r.Context(), userID, collections.ItemPost)
+	if err != nil {
+		logger.Warnw("failed to load hidden posts", "user_id", userID, "error", err)
+		return filter
+	}
+	return posts.ExcludeIDs(filter, hidden)
+}
+
+// hideComments убирает из поста комментарии, которые вызывающий скрыл.
+func (h *PostsHandler) hideComments(r *http.Request, logger *zap.SugaredLogger, post *posts.Post) {
+	if h.Collections == nil || len(post.Comments) == 0 {
+		return
+	}
+	userID, ok := h.callerID(r)
+	if !ok {
+		return
+	}
+
+	hidden, err := h.Collections.Hidden(r.Context(), userID, collections.ItemComment)
+	if err != nil {
+		logger.Warnw("failed to load hidden comments", "user_id", userID, "error", err)
+		return
+	}
+	if len(hidden) == 0 {
+		return
+	}
+
+	visible := make([]*posts.Comment, 0, len(post.Comments))
+	for _, comment := range post.Comments {
+		if !hidden[comment.ID] {
+			visible = append(visible, comment)
+		}
+	}
+	post.Comments = visible
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/collections"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func newCollectionsService(ctrl *gomock.Controller) (*PostsHandler, *posts.MockPostRepo, *collections.MockCollectionRepo, *sessions.MockSessionManagerInterface) {
+	st := posts.NewMockPostRepo(ctrl)
+	lists := collections.NewMockCollectionRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{
+		PostsRepo:   st,
+		Logger:      zap.NewNop().Sugar(),
+		Sessions:    mockSessions,
+		Collections: lists,
+	}
+	return service, st, lists, mockSessions
+}
+
+func collectionsRouter(service *PostsHandler) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/posts/", service.GetAllPosts).Methods("GET")
+	router.HandleFunc("/api/me/saved", service.GetSaved).Methods("GET")
+	router.HandleFunc("/api/post/{POST_ID}/save", service.SaveItem).Methods("POST")
+	router.HandleFunc("/api/post/{POST_ID}/hide", service.UnhideItem).Methods("DELETE")
+	router.HandleFunc("/api/post/{POST_ID}/{COMMENT_ID}/save", service.SaveItem).Methods("POST")
+	return router
+}
+
+func TestCollectionItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, st, lists, mockSessions := newCollectionsService(ctrl)
+	router := collectionsRouter(service)
+
+	postID, commentID := primitive.NewObjectID(), primitive.NewObjectID()
+	post := &posts.Post{ID: postID, Comments: []*posts.Comment{{ID: commentID, Body: "comment"}}}
+
+	tests := []struct {
+		name       string
+		method     string
+		route      string
+		token      string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name:   "Сохранение поста",
+			method: "POST",
+			route:  fmt.Sprintf("/api/post/%s/save", postID.Hex()),
+			token:  jwtToken,
+			setupMocks: func() {
+				st.EXPECT().GetPost(gomock.Any(), postID).Return(post, nil)
+				lists.EXPECT().Add(gomock.Any(), &collections.Item{
+					UserID: newUser.ID, Kind: collections.KindSaved, Type: collections.ItemPost, PostID: postID,
+				}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "Сохранение комментария",
+			method: "POST",
+			route:  fmt.Sprintf("/api/post/%s/%s/save", postID.Hex(), commentID.Hex()),
+			token:  jwtToken,
+			setupMocks: func() {
+				st.EXPECT().GetPost(gomock.Any(), postID).Return(post, nil)
+				lists.EXPECT().Add(gomock.Any(), &collections.Item{
+					UserID: newUser.ID, Kind: collections.KindSaved, Type: collections.ItemComment, PostID: postID, CommentID: commentID,
+				}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "Сохранение несуществующего поста",
+			method: "POST",
+			route:  fmt.Sprintf("/api/post/%s/save", postID.Hex()),
+			token:  jwtToken,
+			setupMocks: func() {
+				st.EXPECT().GetPost(gomock.Any(), postID).Return(nil, posts.ErrPostNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "Сохранение несуществующего комментария",
+			method: "POST",
+			route:  fmt.Sprintf("/api/post/%s/%s/save", postID.Hex(), primitive.NewObjectID().Hex()),
+			token:  jwtToken,
+			setupMocks: func() {
+				st.EXPECT().GetPost(gomock.Any(), postID).Return(post, nil)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "Снятие скрытия не проверяет, что пост существует",
+			method: "DELETE",
+			route:  fmt.Sprintf("/api/post/%s/hide", postID.Hex()),
+			token:  jwtToken,
+			setupMocks: func() {
+				lists.EXPECT().Remove(gomock.Any(), &collections.Item{
+					UserID: newUser.ID, Kind: collections.KindHidden, Type: collections.ItemPost, PostID: postID,
+				}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Сохранение без авторизации",
+			method:     "POST",
+			route:      fmt.Sprintf("/api/post/%s/save", postID.Hex()),
+			setupMocks: func() {},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Сохранение с неверным ID",
+			method:     "POST",
+			route:      "/api/post/invalid-id/save",
+			token:      jwtToken,
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+
+			req := httptest.NewRequest(tc.method, tc.route, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestGetSaved(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, st, lists, mockSessions := newCollectionsService(ctrl)
+	router := collectionsRouter(service)
+
+	first, second, deleted := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	commentID := primitive.NewObjectID()
+	created := time.Date(2024, 5, 7, 20, 38, 17, 0, time.UTC)
+	removed := primitive.NewObjectID()
+	stored := []*posts.Post{
+		{ID: first, Title: "first"},
+		{ID: second, Title: "second", Comments: []*posts.Comment{{ID: commentID, Body: "saved comment"}}},
+		{ID: removed, Title: "removed", Removed: true},
+	}
+
+	t.Run("Вторая страница с удалённым и снятым постами и следующей страницей", func(t *testing.T) {
+		mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+		lists.EXPECT().List(gomock.Any(), newUser.ID, collections.KindSaved, 4, 5).Return([]*collections.Item{
+			{Type: collections.ItemPost, PostID: first, Created: created},
+			{Type: collections.ItemPost, PostID: deleted, Created: created},
+			{Type: collections.ItemPost, PostID: removed, Created: created},
+			{Type: collections.ItemComment, PostID: second, CommentID: commentID, Created: created},
+			{Type: collections.ItemPost, PostID: second, Created: created},
+		}, nil)
+		st.EXPECT().GetPostsByIDs(gomock.Any(), []primitive.ObjectID{first, deleted, removed, second}).DoAndReturn(
+			func(_ context.Context, ids []primitive.ObjectID) ([]*posts.Post, error) {
+				var found []*posts.Post
+				for _, p := range stored {
+					if posts.FilterByIDs(ids)(p) {
+						found = append(found, p)
+					}
+				}
+				return found, nil
+			})
+
+		req := httptest.NewRequest("GET", "/api/me/saved?page=2&limit=4", nil)
+		req.Header.Set("Authorization", "Bearer "+jwtToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		resp := collectionPage{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 2, resp.Page)
+		assert.Equal(t, 4, resp.Limit)
+		assert.True(t, resp.HasMore)
+		if assert.Len(t, resp.Items, 2) {
+			assert.Equal(t, "first", resp.Items[0].Post.Title)
+			assert.Equal(t, collections.ItemComment, resp.Items[1].Type)
+			assert.Equal(t, "saved comment", resp.Items[1].Comment.Body)
+			assert.Equal(t, second, resp.Items[1].PostID)
+		}
+	})
+
+	for _, query := range []string{"page=0", "limit=-1", "page=abc"} {
+		t.Run("Неверная пагинация "+query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/me/saved?"+query, nil)
+			req.Header.Set("Authorization", "Bearer "+jwtToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestGetAllPostsWithoutHidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, st, lists, mockSessions := newCollectionsService(ctrl)
+	router := collectionsRouter(service)
+
+	visible, hidden := primitive.NewObjectID(), primitive.NewObjectID()
+	stored := []*posts.Post{{ID: visible}, {ID: hidden}}
+
+	tests := []struct {
+		name      string
+		token     string
+		wantCount int
+	}{
+		{name: "Аноним видит все посты", wantCount: 2},
+		{name: "Пользователь не видит скрытые посты", token: jwtToken, wantCount: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/posts/", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+				lists.EXPECT().Hidden(gomock.Any(), newUser.ID, collections.ItemPost).Return(map[primitive.ObjectID]bool{hidden: true}, nil)
+			}
+			st.EXPECT().ListPosts(gomock.Any(), posts.Scope{}).Return(stored, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			var got []*posts.Post
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Len(t, got, tc.wantCount)
+			if tc.token != "" {
+				for _, p := range got {
+					assert.NotEqual(t, hidden, p.ID)
+				}
+			}
+		})
+	}
+}
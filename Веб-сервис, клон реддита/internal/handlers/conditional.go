@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// writeConditionalJSON отдаёт ответ 200 с ETag, а если у клиента уже есть эта версия (If-None-Match) - 304 без тела.
+// Ответ зависит от читателя (скрытое, модерация, голоса в опросах), поэтому его можно хранить только
+// в браузере и только с проверкой перед каждым использованием.
+func writeConditionalJSON(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		writeMarshalError(w, logger, err)
+		return
+	}
+
+	// ETag слабый: сжатый и несжатый ответы побайтно различаются, но по смыслу одинаковы.
+	sum := sha256.Sum256(resp)
+	etag := `W/"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Add("Vary", "Authorization")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeBody(w, logger, http.StatusOK, resp)
+}
+
+// etagMatches сравнивает ETag со списком из If-None-Match без учёта слабости, как требует RFC 9110.
+func etagMatches(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"redditclone/internal/notifications"
+	"redditclone/internal/posts"
+	"redditclone/internal/ratelimit"
+	"redditclone/internal/realtime"
+	"redditclone/internal/user"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// maxLinkCandidates - сколько прошлых публикаций ссылки показывать.
+const maxLinkCandidates = 5
+
+// linkCandidate - уже опубликованный пост с той же ссылкой.
+type linkCandidate struct {
+	ID       primitive.ObjectID `json:"id"`
+	Title    string             `json:"title"`
+	Category string             `json:"category"`
+	Score    int                `json:"score"`
+	Created  string             `json:"created"`
+	Author   *user.User         `json:"author"`
+}
+
+// alreadySubmitted - ответ на повторную ссылку. Повторить запрос с "resubmit": true - опубликовать всё равно.
+func alreadySubmitted(candidates []*posts.Post) *HTTPError {
+	details := make([]linkCandidate, 0, len(candidates))
+	for _, p := range candidates {
+		details = append(details, linkCandidate{ID: p.ID, Title: p.Title, Category: p.Category, Score: p.Score, Created: p.Created, Author: p.Author})
+	}
+	return &HTTPError{Status: http.StatusConflict, Code: "already_submitted", Message: "link was already submitted", Details: details}
+}
+
+// duplicateLinks ищет недавние публикации той же ссылки. Поиск повторов - подсказка, а не защита,
+// поэтому ошибка хранилища только логируется и пост публикуется.
+func (h *PostsHandler) duplicateLinks(r *http.Request, logger *zap.SugaredLogger, fd *posts.PostForm) []*posts.Post {
+	if fd.Type != posts.TypeLink || fd.Resubmit || h.DuplicateLinkWindow <= 0 {
+		return nil
+	}
+	normalized, err := posts.NormalizeURL(fd.URL)
+	if err != nil {
+		return nil
+	}
+	found, err := h.PostsRepo.FindByURL(r.Context(), normalized, time.Now().Add(-h.DuplicateLinkWindow), maxLinkCandidates)
+	if err != nil {
+		logger.Warnw("failed to look up duplicate links", "error", err)
+		return nil
+	}
+	return found
+}
+
+// Crosspost переопубликовывает пост в другой категории: POST /api/post/{POST_ID}/crosspost
+// с телом {"category": "...", "title": "..."}.
+func (h *PostsHandler) Crosspost(w http.ResponseWriter, r *http.Request) {
+	objID := mux.Vars(r)["POST_ID"]
+	logger := requestLogger(r, h.Logger).With("post_id", objID)
+
+	postID, err := primitive.ObjectIDFromHex(objID)
+	if err != nil {
+		writeError(w, r, logger, ErrInvalidID)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, logger, ErrReading)
+		return
+	}
+	r.Body.Close()
+
+	fd := &posts.CrosspostForm{}
+	if err = json.Unmarshal(body, fd); err != nil {
+		writeError(w, r, logger, ErrBadRequest)
+		return
+	}
+	if errors := dataValidation(fd); errors != nil {
+		writeError(w, r, logger, validationError(errors))
+		return
+	}
+
+	userID, username, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	if !h.allowRequest(w, r, logger, ratelimit.RoutePost, userID) {
+		return
+	}
+
+	post, err := h.PostsRepo.Crosspost(r.Context(), postID, fd, username, userID)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	h.notify(notifications.Event{Kind: notifications.EventPost, Actor: user.User{ID: userID, Username: username}, Post: post})
+	h.broadcast(r, logger, realtime.EventPostCreated, post)
+
+	logger.Infow("post crossposted", "crosspost_id", post.ID.Hex(), "category", post.Category)
+	writeJSON(w, logger, http.StatusCreated, post)
+}
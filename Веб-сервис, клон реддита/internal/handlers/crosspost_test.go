@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func TestCrosspost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := posts.NewMockPostRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{PostsRepo: st, Logger: zap.NewNop().Sugar(), Sessions: mockSessions}
+	router := mux.NewRouter()
+	router.HandleFunc("/api/post/{POST_ID}/crosspost", service.Crosspost).Methods("POST")
+
+	originalID := primitive.NewObjectID()
+	route := fmt.Sprintf("/api/post/%s/crosspost", originalID.Hex())
+
+	tests := []struct {
+		name       string
+		route      string
+		body       string
+		token      string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name:  "Кросспост",
+			route: route,
+			body:  `{"category":"news"}`,
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().Crosspost(gomock.Any(), originalID, &posts.CrosspostForm{Category: "news"}, newUser.Username, newUser.ID).
+					Return(&posts.Post{ID: primitive.NewObjectID(), Category: "news", Crosspost: &posts.CrosspostRef{PostID: originalID, Score: 10, Available: true}}, nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:  "В ту же категорию",
+			route: route,
+			body:  `{"category":"music"}`,
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().Crosspost(gomock.Any(), originalID, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, posts.ErrSameCategory)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Оригинал не найден",
+			route: route,
+			body:  `{"category":"news"}`,
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().Crosspost(gomock.Any(), originalID, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, posts.ErrPostNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Без категории",
+			route:      route,
+			body:       `{"title":"заголовок"}`,
+			token:      jwtToken,
+			setupMocks: func() {},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Без авторизации",
+			route:      route,
+			body:       `{"category":"news"}`,
+			setupMocks: func() {},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+
+			req := httptest.NewRequest("POST", tc.route, strings.NewReader(tc.body))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestMakePostDuplicateLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := posts.NewMockPostRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{PostsRepo: st, Logger: zap.NewNop().Sugar(), Sessions: mockSessions, DuplicateLinkWindow: 24 * time.Hour}
+
+	earlier := &posts.Post{ID: primitive.NewObjectID(), Title: "Было", Category: "music", Score: 12, URL: "https://example.com/a"}
+
+	tests := []struct {
+		name       string
+		body       string
+		setupMocks func()
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name: "Ссылку уже публиковали",
+			body: `{"type":"link","title":"Ссылка","category":"news","url":"https://www.example.com/a/?utm_source=x"}`,
+			setupMocks: func() {
+				st.EXPECT().FindByURL(gomock.Any(), "example.com/a", gomock.Any(), maxLinkCandidates).Return([]*posts.Post{earlier}, nil)
+			},
+			wantStatus: http.StatusConflict,
+			wantCode:   "already_submitted",
+		},
+		{
+			name: "Публикация поверх повтора",
+			body: `{"type":"link","title":"Ссылка","category":"news","url":"https://example.com/a","resubmit":true}`,
+			setupMocks: func() {
+				st.EXPECT().MakePost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&posts.Post{ID: primitive.NewObjectID()}, nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "Новая ссылка",
+			body: `{"type":"link","title":"Ссылка","category":"news","url":"https://example.com/b"}`,
+			setupMocks: func() {
+				st.EXPECT().FindByURL(gomock.Any(), "example.com/b", gomock.Any(), gomock.Any()).Return([]*posts.Post{}, nil)
+				st.EXPECT().MakePost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&posts.Post{ID: primitive.NewObjectID()}, nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "Поиск повторов не работает - пост всё равно публикуется",
+			body: `{"type":"link","title":"Ссылка","category":"news","url":"https://example.com/c"}`,
+			setupMocks: func() {
+				st.EXPECT().FindByURL(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, posts.ErrStorage)
+				st.EXPECT().MakePost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&posts.Post{ID: primitive.NewObjectID()}, nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+			tc.setupMocks()
+
+			req := httptest.NewRequest("POST", "/api/posts", strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+jwtToken)
+			w := httptest.NewRecorder()
+
+			service.MakePost(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantCode != "" {
+				var resp struct {
+					Code    string          `json:"code"`
+					Details []linkCandidate `json:"details"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, tc.wantCode, resp.Code)
+				if assert.Len(t, resp.Details, 1) {
+					assert.Equal(t, earlier.ID, resp.Details[0].ID)
+					assert.Equal(t, 12, resp.Details[0].Score)
+				}
+			}
+		})
+	}
+}
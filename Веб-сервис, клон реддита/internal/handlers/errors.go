@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"redditclone/internal/logging"
+	"redditclone/internal/media"
+	"redditclone/internal/posts"
+	"redditclone/internal/user"
+
+	"go.uber.org/zap"
+)
+
+// StatusClientClosedRequest - нестандартный статус (как в nginx) для запросов, которые клиент отменил сам.
+const StatusClientClosedRequest = 499
+
+// HTTPError - ошибка уровня хендлеров, для которой заранее известны статус и код ответа.
+type HTTPError struct {
+	Status  int
+	Code    string
+	Message string
+	Details interface{}
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// Ошибки, которые возникают в самих хендлерах.
+var (
+	ErrReading       = &HTTPError{Status: http.StatusBadRequest, Code: "bad_request", Message: "error reading request"}
+	ErrBadRequest    = &HTTPError{Status: http.StatusBadRequest, Code: "bad_request", Message: "bad request"}
+	ErrInvalidID     = &HTTPError{Status: http.StatusBadRequest, Code: "invalid_id", Message: "invalid id"}
+	ErrUnauthorized  = &HTTPError{Status: http.StatusUnauthorized, Code: "unauthorized", Message: "unauthorized"}
+	ErrNoPayload     = errors.New("no payload")
+	ErrBadSignMethod = errors.New("bad sign method")
+)
+
+// errorResponse - единый формат тела ответа с ошибкой.
+// Ошибки полей формы идут только в errors (его ждёт фронтенд в ответах 422), в details - остальные подробности.
+type errorResponse struct {
+	Code      string              `json:"code"`
+	Message   string              `json:"message"`
+	Details   interface{}         `json:"details,omitempty"`
+	Errors    []map[string]string `json:"errors,omitempty"`
+	RequestID string              `json:"requestId,omitempty"`
+}
+
+// validationError оборачивает ошибки валидации формы в ответ 422.
+func validationError(fieldErrors []map[string]string) *HTTPError {
+	return &HTTPError{
+		Status:  http.StatusUnprocessableEntity,
+		Code:    "validation_failed",
+		Message: "validation failed",
+		Details: fieldErrors,
+	}
+}
+
+// toHTTPError сопоставляет ошибку доменного слоя с HTTP статусом и кодом.
+func toHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	switch {
+	case errors.As(err, &httpErr):
+		return httpErr
+	case errors.Is(err, posts.ErrPostNotFound):
+		return &HTTPError{Status: http.StatusNotFound, Code: "post_not_found", Message: "post not found"}
+	case errors.Is(err, posts.ErrCommentNotFound):
+		return &HTTPError{Status: http.StatusNotFound, Code: "comment_not_found", Message: "comment not found"}
+	case errors.Is(err, posts.ErrPostLocked):
+		return &HTTPError{Status: http.StatusForbidden, Code: "post_locked", Message: "post is locked"}
+	case errors.Is(err, posts.ErrBadPostType):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "bad_post_type", Message: "unknown post type"}
+	case errors.Is(err, posts.ErrNoImage):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "image_required", Message: "image post needs an image file"}
+	case errors.Is(err, posts.ErrSameCategory):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "same_category", Message: "post is already in this category"}
+	case errors.Is(err, posts.ErrBadURL):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "bad_url", Message: "url must be an absolute http or https link"}
+	case errors.Is(err, posts.ErrEmptyScope):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "empty_scope", Message: "scope needs a category or an author"}
+	case errors.Is(err, posts.ErrNotCrosspostable):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "not_crosspostable", Message: "post can not be crossposted"}
+	case errors.Is(err, posts.ErrBadPoll):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "bad_poll", Message: "poll needs 2-10 non-empty options and a close time in the future"}
+	case errors.Is(err, posts.ErrNotPoll):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "not_a_poll", Message: "post is not a poll"}
+	case errors.Is(err, posts.ErrBadPollOption):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "bad_poll_option", Message: "unknown poll option"}
+	case errors.Is(err, posts.ErrPollClosed):
+		return &HTTPError{Status: http.StatusConflict, Code: "poll_closed", Message: "poll is closed"}
+	case errors.Is(err, posts.ErrAlreadyVoted):
+		return &HTTPError{Status: http.StatusConflict, Code: "already_voted", Message: "already voted in this poll"}
+	case errors.Is(err, media.ErrTooLarge):
+		return ErrUploadTooLarge
+	case errors.Is(err, media.ErrUnsupportedType):
+		return &HTTPError{Status: http.StatusUnsupportedMediaType, Code: "unsupported_media_type", Message: "image must be jpeg, png, gif or webp"}
+	case errors.Is(err, media.ErrBadImage):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "bad_image", Message: "malformed image"}
+	case errors.Is(err, posts.ErrBadSort):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "bad_sort", Message: "sort must be one of hot, new, top"}
+	case errors.Is(err, posts.ErrBadCursor):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "bad_cursor", Message: "bad cursor"}
+	case errors.Is(err, posts.ErrBadVote):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "bad_vote", Message: "vote must be -1, 0 or 1"}
+	case errors.Is(err, posts.ErrFailedUpdate):
+		return &HTTPError{Status: http.StatusConflict, Code: "update_conflict", Message: "failed to update post"}
+	case errors.Is(err, user.ErrNoUser):
+		return &HTTPError{Status: http.StatusUnauthorized, Code: "user_not_found", Message: "user not found"}
+	case errors.Is(err, user.ErrBadPass):
+		return &HTTPError{Status: http.StatusUnauthorized, Code: "invalid_password", Message: "invalid password"}
+	case errors.Is(err, user.ErrBanned):
+		return &HTTPError{Status: http.StatusForbidden, Code: "user_banned", Message: "user is banned"}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &HTTPError{Status: http.StatusGatewayTimeout, Code: "timeout", Message: "request timed out"}
+	case errors.Is(err, context.Canceled):
+		return &HTTPError{Status: StatusClientClosedRequest, Code: "canceled", Message: "request canceled"}
+	default:
+		return &HTTPError{Status: http.StatusInternalServerError, Code: "internal_error", Message: "internal server error"}
+	}
+}
+
+// writeError пишет ошибку в едином JSON формате и логирует её.
+func writeError(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, err error) {
+	httpErr := toHTTPError(err)
+
+	if httpErr.Status >= http.StatusInternalServerError {
+		logger.Errorw("request failed", "status", httpErr.Status, "code", httpErr.Code, "error", err)
+	} else {
+		logger.Infow("request rejected", "status", httpErr.Status, "code", httpErr.Code, "error", err)
+	}
+
+	resp := errorResponse{
+		Code:      httpErr.Code,
+		Message:   httpErr.Message,
+		RequestID: logging.RequestID(r.Context()),
+	}
+	if fieldErrors, ok := httpErr.Details.([]map[string]string); ok {
+		resp.Errors = fieldErrors
+	} else {
+		resp.Details = httpErr.Details
+	}
+
+	writeJSON(w, logger, httpErr.Status, resp)
+}
+
+// writeJSON сериализует ответ и отправляет его с правильным Content-Type.
+func writeJSON(w http.ResponseWriter, logger *zap.SugaredLogger, status int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		writeMarshalError(w, logger, err)
+		return
+	}
+	writeBody(w, logger, status, resp)
+}
+
+func writeMarshalError(w http.ResponseWriter, logger *zap.SugaredLogger, err error) {
+	logger.Errorw("failed to marshal response", "error", err)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = w.Write([]byte(`{"code":"internal_error","message":"internal server error"}`))
+}
+
+func writeBody(w http.ResponseWriter, logger *zap.SugaredLogger, status int, resp []byte) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write(resp); err != nil {
+		logger.Errorw("failed to write response", "error", err)
+	}
+}
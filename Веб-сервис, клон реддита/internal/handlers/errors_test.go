@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/logging"
+	"redditclone/internal/posts"
+	"redditclone/internal/user"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantErrors bool
+	}{
+		{name: "Пост не найден", err: posts.ErrPostNotFound, wantStatus: http.StatusNotFound, wantCode: "post_not_found"},
+		{name: "Обёрнутая ошибка хранилища", err: fmt.Errorf("%w: %w", posts.ErrStorage, fmt.Errorf("boom")), wantStatus: http.StatusInternalServerError, wantCode: "internal_error"},
+		{name: "Таймаут хранилища", err: fmt.Errorf("%w: %w", posts.ErrStorage, context.DeadlineExceeded), wantStatus: http.StatusGatewayTimeout, wantCode: "timeout"},
+		{name: "Неизвестный тип поста", err: posts.ErrBadPostType, wantStatus: http.StatusBadRequest, wantCode: "bad_post_type"},
+		{name: "Недопустимый голос", err: posts.ErrBadVote, wantStatus: http.StatusBadRequest, wantCode: "bad_vote"},
+		{name: "Неизвестная сортировка ленты", err: posts.ErrBadSort, wantStatus: http.StatusBadRequest, wantCode: "bad_sort"},
+		{name: "Битый курсор ленты", err: posts.ErrBadCursor, wantStatus: http.StatusBadRequest, wantCode: "bad_cursor"},
+		{name: "Неверный пароль", err: user.ErrBadPass, wantStatus: http.StatusUnauthorized, wantCode: "invalid_password"},
+		{name: "Битая ссылка", err: fmt.Errorf("normalize: %w", posts.ErrBadURL), wantStatus: http.StatusBadRequest, wantCode: "bad_url"},
+		{name: "Пустая область удаления", err: posts.ErrEmptyScope, wantStatus: http.StatusBadRequest, wantCode: "empty_scope"},
+		{name: "Пост закрыт", err: posts.ErrPostLocked, wantStatus: http.StatusForbidden, wantCode: "post_locked"},
+		{name: "Нет авторизации", err: ErrUnauthorized, wantStatus: http.StatusUnauthorized, wantCode: "unauthorized"},
+		{
+			name:       "Ошибка валидации",
+			err:        validationError([]map[string]string{{"location": "body", "param": "title", "msg": "is required"}}),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "validation_failed",
+			wantErrors: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req = req.WithContext(logging.WithRequestID(req.Context(), "req-42"))
+			w := httptest.NewRecorder()
+
+			writeError(w, req, zap.NewNop().Sugar(), tc.err)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+			var body errorResponse
+			if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body)) {
+				assert.Equal(t, tc.wantCode, body.Code)
+				assert.NotEmpty(t, body.Message)
+				assert.Equal(t, "req-42", body.RequestID)
+				assert.Equal(t, tc.wantErrors, len(body.Errors) > 0)
+				assert.Nil(t, body.Details, "field errors are not duplicated in details")
+			}
+		})
+	}
+}
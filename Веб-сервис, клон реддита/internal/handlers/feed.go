@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+	"redditclone/internal/collections"
+	"redditclone/internal/posts"
+	"redditclone/internal/subscriptions"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultFeedSort - ранжирование ленты, если sort не передан.
+const defaultFeedSort = posts.SortHot
+
+var ErrBadSubscription = &HTTPError{Status: http.StatusBadRequest, Code: "bad_subscription", Message: "subscription target is required"}
+
+func (h *PostsHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	subscriptionHandler(w, r, h, true)
+}
+
+func (h *PostsHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	subscriptionHandler(w, r, h, false)
+}
+
+// subscriptionHandler подписывает на категорию или автора. Маршрут: /api/subscriptions/{TYPE}/{TARGET}.
+func subscriptionHandler(w http.ResponseWriter, r *http.Request, h *PostsHandler, subscribe bool) {
+	vars := mux.Vars(r)
+	logger := requestLogger(r, h.Logger).With("type", vars["TYPE"], "target", vars["TARGET"])
+
+	sub := &subscriptions.Subscription{Type: subscriptions.Type(vars["TYPE"]), Target: vars["TARGET"]}
+	if sub.Target == "" || (sub.Type != subscriptions.TypeCategory && sub.Type != subscriptions.TypeUser) {
+		writeError(w, r, logger, ErrBadSubscription)
+		return
+	}
+
+	userID, _, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	sub.UserID = userID
+	logger = logger.With("user_id", userID)
+
+	if subscribe {
+		err = h.Subscriptions.Subscribe(r.Context(), sub)
+	} else {
+		err = h.Subscriptions.Unsubscribe(r.Context(), sub)
+	}
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	logger.Infow("subscriptions updated", "subscribe", subscribe)
+	writeJSON(w, logger, http.StatusOK, SuccessResponse)
+}
+
+func (h *PostsHandler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger)
+
+	userID, _, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	subs, err := h.Subscriptions.List(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	logger.Infow("subscriptions received", "count", len(subs))
+	writeJSON(w, logger, http.StatusOK, subs)
+}
+
+// GetFeed отдаёт посты из подписок пользователя: ?sort=hot|new|top&limit=N&cursor=...
+// Скрытые пользователем посты в ленту не попадают.
+func (h *PostsHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger)
+
+	query := posts.FeedQuery{Sort: r.URL.Query().Get("sort")}
+	if query.Sort == "" {
+		query.Sort = defaultFeedSort
+	}
+	if !posts.ValidSort(query.Sort) {
+		writeError(w, r, logger, posts.ErrBadSort)
+		return
+	}
+	logger = logger.With("sort", query.Sort)
+
+	var err error
+	if query.Limit, err = parseLimit(r); err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	if raw := r.URL.Query().Get("cursor"); raw != "" {
+		if query.After, err = posts.DecodeCursor(query.Sort, raw); err != nil {
+			writeError(w, r, logger, err)
+			return
+		}
+	}
+
+	userID, _, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	subs, err := h.Subscriptions.List(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	query.Categories, query.Authors = subscriptions.Split(subs)
+
+	if h.Collections != nil {
+		hidden, err := h.Collections.Hidden(r.Context(), userID, collections.ItemPost)
+		if err != nil {
+			logger.Warnw("failed to load hidden posts", "error", err)
+		}
+		for id := range hidden {
+			query.Exclude = append(query.Exclude, id)
+		}
+	}
+
+	page, err := h.PostsRepo.GetFeed(r.Context(), query)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	h.showPolls(r, page.Posts)
+
+	logger.Infow("feed received", "count", len(page.Posts), "subscriptions", len(subs))
+	writeJSON(w, logger, http.StatusOK, page)
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/collections"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"redditclone/internal/subscriptions"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func TestGetFeed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := posts.NewMockPostRepo(ctrl)
+	lists := collections.NewMockCollectionRepo(ctrl)
+	subs := subscriptions.NewMockSubscriptionRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{
+		PostsRepo:     st,
+		Logger:        zap.NewNop().Sugar(),
+		Sessions:      mockSessions,
+		Collections:   lists,
+		Subscriptions: subs,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/feed", service.GetFeed).Methods("GET")
+
+	hidden, lastID := primitive.NewObjectID(), primitive.NewObjectID()
+	cursor := &posts.FeedCursor{Sort: posts.SortTop, Rank: 4, ID: lastID}
+
+	tests := []struct {
+		name       string
+		query      string
+		token      string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name:  "Лента по умолчанию: hot, подписки и скрытые посты",
+			query: "",
+			token: jwtToken,
+			setupMocks: func() {
+				subs.EXPECT().List(gomock.Any(), newUser.ID).Return([]*subscriptions.Subscription{
+					{Type: subscriptions.TypeCategory, Target: "music"},
+					{Type: subscriptions.TypeUser, Target: "ivan"},
+				}, nil)
+				lists.EXPECT().Hidden(gomock.Any(), newUser.ID, collections.ItemPost).Return(map[primitive.ObjectID]bool{hidden: true}, nil)
+				st.EXPECT().GetFeed(gomock.Any(), posts.FeedQuery{
+					Categories: []string{"music"},
+					Authors:    []string{"ivan"},
+					Exclude:    []primitive.ObjectID{hidden},
+					Sort:       posts.SortHot,
+					Limit:      defaultPageLimit,
+				}).Return(&posts.FeedPage{Posts: []*posts.Post{{ID: lastID}}, NextCursor: "next"}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Следующая страница top с курсором",
+			query: "?sort=top&limit=2&cursor=" + cursor.Encode(),
+			token: jwtToken,
+			setupMocks: func() {
+				subs.EXPECT().List(gomock.Any(), newUser.ID).Return([]*subscriptions.Subscription{
+					{Type: subscriptions.TypeCategory, Target: "music"},
+				}, nil)
+				lists.EXPECT().Hidden(gomock.Any(), newUser.ID, collections.ItemPost).Return(nil, nil)
+				st.EXPECT().GetFeed(gomock.Any(), posts.FeedQuery{
+					Categories: []string{"music"},
+					Sort:       posts.SortTop,
+					Limit:      2,
+					After:      cursor,
+				}).Return(&posts.FeedPage{Posts: []*posts.Post{}}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Неизвестная сортировка",
+			query:      "?sort=best",
+			token:      jwtToken,
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Курсор от другой сортировки",
+			query:      "?sort=new&cursor=" + cursor.Encode(),
+			token:      jwtToken,
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Лента без авторизации",
+			setupMocks: func() {},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+
+			req := httptest.NewRequest("GET", "/api/feed"+tc.query, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantStatus == http.StatusOK {
+				page := &posts.FeedPage{}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page))
+				assert.NotNil(t, page.Posts)
+			}
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	subs := subscriptions.NewMockSubscriptionRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{
+		Logger:        zap.NewNop().Sugar(),
+		Sessions:      mockSessions,
+		Subscriptions: subs,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/subscriptions/{TYPE:category|user}/{TARGET}", service.Subscribe).Methods("POST")
+	router.HandleFunc("/api/subscriptions/{TYPE:category|user}/{TARGET}", service.Unsubscribe).Methods("DELETE")
+
+	tests := []struct {
+		name       string
+		method     string
+		route      string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name:   "Подписка на категорию",
+			method: "POST",
+			route:  "/api/subscriptions/category/music",
+			setupMocks: func() {
+				subs.EXPECT().Subscribe(gomock.Any(), &subscriptions.Subscription{UserID: newUser.ID, Type: subscriptions.TypeCategory, Target: "music"}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "Отписка от автора",
+			method: "DELETE",
+			route:  "/api/subscriptions/user/ivan",
+			setupMocks: func() {
+				subs.EXPECT().Unsubscribe(gomock.Any(), &subscriptions.Subscription{UserID: newUser.ID, Type: subscriptions.TypeUser, Target: "ivan"}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Неизвестный тип подписки",
+			method:     "POST",
+			route:      "/api/subscriptions/tag/go",
+			setupMocks: func() {},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+			mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+
+			req := httptest.NewRequest(tc.method, tc.route, nil)
+			req.Header.Set("Authorization", "Bearer "+jwtToken)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"redditclone/internal/collections"
+	"redditclone/internal/dataloader"
+	"redditclone/internal/posts"
+	"redditclone/internal/user"
+	"strconv"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// Размер страницы в полях-списках GraphQL: аргумент first.
+const (
+	defaultFirst = 25
+	maxFirst     = 100
+)
+
+var ErrBadFirst = &HTTPError{Status: http.StatusBadRequest, Code: "bad_first", Message: fmt.Sprintf("first must be between 1 and %d", maxFirst)}
+
+// GraphQLHandler отвечает на запросы к /api/graphql поверх тех же репозиториев, что и REST.
+type GraphQLHandler struct {
+	Posts *PostsHandler
+	Users user.UserRepo
+	// MaxComplexity - предельная стоимость запроса (см. queryComplexity), 0 - без ограничения.
+	MaxComplexity int
+
+	schema graphql.Schema
+}
+
+// GraphQLRequest - тело запроса к /api/graphql.
+type GraphQLRequest struct {
+	Query         string                 `json:"query" validate:"required"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   interface{}                `json:"data"`
+	Errors []gqlerrors.FormattedError `json:"errors,omitempty"`
+}
+
+func NewGraphQLHandler(h *PostsHandler, users user.UserRepo, maxComplexity int) (*GraphQLHandler, error) {
+	g := &GraphQLHandler{Posts: h, Users: users, MaxComplexity: maxComplexity}
+	schema, err := g.newSchema()
+	if err != nil {
+		return nil, err
+	}
+	g.schema = schema
+	return g, nil
+}
+
+// GraphQL выполняет запрос. Ошибки разбора, проверки и резолверов по правилам GraphQL приходят
+// в поле errors с кодом 200, код ошибки - в extensions.code.
+func (g *GraphQLHandler) GraphQL(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, g.Posts.Logger)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, logger, ErrReading)
+		return
+	}
+	r.Body.Close()
+
+	fd := &GraphQLRequest{}
+	if err = json.Unmarshal(body, fd); err != nil {
+		writeError(w, r, logger, ErrBadRequest)
+		return
+	}
+	if errors := dataValidation(fd); errors != nil {
+		writeError(w, r, logger, validationError(errors))
+		return
+	}
+
+	gr := g.newRequest(r, logger)
+	result, cost := g.execute(context.WithValue(r.Context(), graphQLRequestKey{}, gr), fd)
+
+	logger.Infow("graphql query executed", "operation", fd.OperationName, "complexity", cost, "errors", len(result.Errors))
+	writeJSON(w, logger, http.StatusOK, graphQLResponse{Data: result.Data, Errors: result.Errors})
+}
+
+// execute разбирает и проверяет запрос, отсекает слишком дорогие и только потом выполняет.
+func (g *GraphQLHandler) execute(ctx context.Context, fd *GraphQLRequest) (*graphql.Result, int) {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(fd.Query), Name: "GraphQL request"})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}, 0
+	}
+	if validation := graphql.ValidateDocument(&g.schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}, 0
+	}
+
+	cost := queryComplexity(g.schema, doc, fd.OperationName, fd.Variables)
+	if g.MaxComplexity > 0 && cost > g.MaxComplexity {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+			Message:    fmt.Sprintf("query complexity %d exceeds the limit of %d", cost, g.MaxComplexity),
+			Extensions: map[string]interface{}{"code": "query_too_complex"},
+		}}}, cost
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        g.schema,
+		AST:           doc,
+		OperationName: fd.OperationName,
+		Args:          fd.Variables,
+		Context:       ctx,
+	}), cost
+}
+
+type graphQLRequestKey struct{}
+
+// graphQLRequest - состояние одного запроса: кто спрашивает и загрузчики, которые собирают
+// одинаковые обращения к хранилищам со всего ответа в один запрос.
+type graphQLRequest struct {
+	h      *PostsHandler
+	r      *http.Request
+	logger *zap.SugaredLogger
+
+	userID     int64
+	username   string
+	authorized bool
+	moderator  bool
+
+	users         *dataloader.Loader[int64, *user.User]
+	postsByID     *dataloader.Loader[primitive.ObjectID, *posts.Post]
+	postsByAuthor *dataloader.Loader[string, []*posts.Post]
+
+	hiddenOnce     sync.Once
+	hiddenPosts    map[primitive.ObjectID]bool
+	hiddenComments map[primitive.ObjectID]bool
+}
+
+func requestFrom(ctx context.Context) *graphQLRequest {
+	return ctx.Value(graphQLRequestKey{}).(*graphQLRequest)
+}
+
+// newRequest проверяет токен так же, как REST: без токена запрос анонимный, а мутации требуют авторизации.
+func (g *GraphQLHandler) newRequest(r *http.Request, logger *zap.SugaredLogger) *graphQLRequest {
+	gr := &graphQLRequest{h: g.Posts, r: r, logger: logger}
+	if userID, username, err := authUser(r, g.Posts); err == nil {
+		gr.userID, gr.username, gr.authorized = userID, username, true
+		gr.moderator = g.Posts.Moderators.Is(username)
+		gr.logger = logger.With("user_id", userID)
+	}
+
+	gr.users = dataloader.New(func(ctx context.Context, ids []int64) (map[int64]*user.User, error) {
+		list, err := g.Users.GetUsersByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		found := make(map[int64]*user.User, len(list))
+		for _, u := range list {
+			found[u.ID] = u
+		}
+		return found, nil
+	})
+	gr.postsByID = dataloader.New(func(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*posts.Post, error) {
+		list, err := g.Posts.PostsRepo.GetPostsByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		found := make(map[primitive.ObjectID]*posts.Post, len(list))
+		for _, post := range list {
+			found[post.ID] = post
+		}
+		return found, nil
+	})
+	gr.postsByAuthor = dataloader.New(func(ctx context.Context, usernames []string) (map[string][]*posts.Post, error) {
+		list, err := g.Posts.PostsRepo.GetPostsByAuthors(ctx, usernames)
+		if err != nil {
+			return nil, err
+		}
+		found := make(map[string][]*posts.Post, len(usernames))
+		for _, post := range posts.Select(list, gr.listFilter(posts.FilterAll())) {
+			found[post.Author.Username] = append(found[post.Author.Username], gr.visible(post))
+		}
+		return found, nil
+	})
+	return gr
+}
+
+// loadHidden один раз за запрос читает, что пользователь скрыл. Ошибка хранилища не мешает ответу.
+func (gr *graphQLRequest) loadHidden() {
+	gr.hiddenOnce.Do(func() {
+		if gr.h.Collections == nil || !gr.authorized {
+			return
+		}
+		var err error
+		if gr.hiddenPosts, err = gr.h.Collections.Hidden(gr.r.Context(), gr.userID, collections.ItemPost); err != nil {
+			gr.logger.Warnw("failed to load hidden posts", "error", err)
+		}
+		if gr.hiddenComments, err = gr.h.Collections.Hidden(gr.r.Context(), gr.userID, collections.ItemComment); err != nil {
+			gr.logger.Warnw("failed to load hidden comments", "error", err)
+		}
+	})
+}
+
+// listFilter убирает из списков снятые посты (кроме как для модераторов) и скрытые пользователем.
+func (gr *graphQLRequest) listFilter(filter func(*posts.Post) bool) func(*posts.Post) bool {
+	if !gr.moderator {
+		filter = posts.WithoutRemoved(filter)
+	}
+	gr.loadHidden()
+	return posts.ExcludeIDs(filter, gr.hiddenPosts)
+}
+
+// visible готовит пост к показу так же, как GetPost: снятый пост виден только модераторам,
+// снятые и скрытые пользователем комментарии убираются. nil - пост показывать нельзя.
+func (gr *graphQLRequest) visible(post *posts.Post) *posts.Post {
+	if post.Removed && !gr.moderator {
+		return nil
+	}
+	if !gr.moderator && hasRemovedComments(post) {
+		posts.VisibleComments(post)
+	}
+
+	gr.loadHidden()
+	if len(gr.hiddenComments) > 0 {
+		comments := make([]*posts.Comment, 0, len(post.Comments))
+		for _, comment := range post.Comments {
+			if !gr.hiddenComments[comment.ID] {
+				comments = append(comments, comment)
+			}
+		}
+		post.Comments = comments
+	}
+	return post
+}
+
+// graphQLError передаёт клиенту код ошибки в extensions.code - так же, как поле code в ответах REST.
+type graphQLError struct {
+	*HTTPError
+}
+
+func (e graphQLError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.Code}
+	if e.Details != nil {
+		ext["details"] = e.Details
+	}
+	return ext
+}
+
+// fail переводит ошибку резолвера в ошибку GraphQL и логирует её, как writeError.
+func (gr *graphQLRequest) fail(err error) error {
+	httpErr := toHTTPError(err)
+	if httpErr.Status >= http.StatusInternalServerError {
+		gr.logger.Errorw("graphql resolver failed", "code", httpErr.Code, "error", err)
+	} else {
+		gr.logger.Infow("graphql resolver rejected", "code", httpErr.Code, "error", err)
+	}
+	return graphQLError{httpErr}
+}
+
+// queryComplexity оценивает стоимость операции до её выполнения. Каждое поле стоит 1, а поле-список
+// умножает стоимость своих полей на first: вложенные списки дорожают так же, как растёт работа сервера.
+func queryComplexity(schema graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}) int {
+	fragments := map[string]*ast.FragmentDefinition{}
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		}
+	}
+	if operation == nil {
+		return 0
+	}
+
+	root := schema.QueryType()
+	if operation.Operation == ast.OperationTypeMutation {
+		root = schema.MutationType()
+	}
+	c := &complexity{fragments: fragments, variables: variables}
+	return c.selectionSet(root, operation.SelectionSet)
+}
+
+type complexity struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+func (c *complexity) selectionSet(parent *graphql.Object, set *ast.SelectionSet) int {
+	if parent == nil || set == nil {
+		return 0
+	}
+
+	cost := 0
+	for _, selection := range set.Selections {
+		switch sel := selection.(type) {
+		case *ast.Field:
+			cost += c.field(parent, sel)
+		case *ast.InlineFragment:
+			cost += c.selectionSet(parent, sel.SelectionSet)
+		case *ast.FragmentSpread:
+			// Циклы во фрагментах отсекает проверка документа, она идёт раньше.
+			if fragment := c.fragments[sel.Name.Value]; fragment != nil {
+				cost += c.selectionSet(parent, fragment.SelectionSet)
+			}
+		}
+	}
+	return cost
+}
+
+func (c *complexity) field(parent *graphql.Object, field *ast.Field) int {
+	def := parent.Fields()[field.Name.Value]
+	if def == nil {
+		// __typename и поля интроспекции.
+		return 1
+	}
+
+	multiplier := 1
+	typ := def.Type
+	if nonNull, ok := typ.(*graphql.NonNull); ok {
+		typ = nonNull.OfType
+	}
+	if list, ok := typ.(*graphql.List); ok {
+		multiplier = c.first(def, field)
+		typ = list.OfType
+		if nonNull, ok := typ.(*graphql.NonNull); ok {
+			typ = nonNull.OfType
+		}
+	}
+
+	object, _ := typ.(*graphql.Object)
+	return 1 + multiplier*c.selectionSet(object, field.SelectionSet)
+}
+
+// first - размер страницы поля-списка: из аргумента, переменной или значения по умолчанию.
+func (c *complexity) first(def *graphql.FieldDefinition, field *ast.Field) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		var value interface{} = arg.Value.GetValue()
+		if variable, ok := arg.Value.(*ast.Variable); ok {
+			value = c.variables[variable.Name.Value]
+		}
+		switch v := value.(type) {
+		case string:
+			if n, err := strconv.Atoi(v); err == nil {
+				return n
+			}
+		case float64:
+			return int(v)
+		case int:
+			return v
+		}
+	}
+	for _, arg := range def.Args {
+		if n, ok := arg.DefaultValue.(int); ok && arg.PrivateName == "first" {
+			return n
+		}
+	}
+	return defaultFirst
+}
@@ -0,0 +1,463 @@
+package handlers
+
+import (
+	"errors"
+	"redditclone/internal/posts"
+	"redditclone/internal/ratelimit"
+	"redditclone/internal/user"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// commentNode - комментарий вместе с постом: по посту ищутся ответы на комментарий.
+type commentNode struct {
+	comment *posts.Comment
+	post    *posts.Post
+}
+
+// firstArg - аргумент first для полей-списков.
+var firstArg = graphql.FieldConfigArgument{
+	"first": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultFirst, Description: "Размер страницы, не больше 100."},
+}
+
+func (g *GraphQLHandler) newSchema() (graphql.Schema, error) {
+	var postType, commentType, userType *graphql.Object
+
+	userType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return strconv.FormatInt(p.Source.(*user.User).ID, 10), nil
+				}},
+				"username": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*user.User).Username, nil
+				}},
+				"posts": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType))),
+					Args: firstArg,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						gr := requestFrom(p.Context)
+						first, err := pageSize(p)
+						if err != nil {
+							return nil, gr.fail(err)
+						}
+						load := gr.postsByAuthor.Load(p.Context, p.Source.(*user.User).Username)
+						return func() (interface{}, error) {
+							list, _, err := load()
+							if err != nil {
+								return nil, gr.fail(err)
+							}
+							return page(list, first), nil
+						}, nil
+					},
+				},
+				"karma": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.Int),
+					Description: "Сумма рейтингов постов пользователя.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						gr := requestFrom(p.Context)
+						load := gr.postsByAuthor.Load(p.Context, p.Source.(*user.User).Username)
+						return func() (interface{}, error) {
+							list, _, err := load()
+							if err != nil {
+								return nil, gr.fail(err)
+							}
+							karma := 0
+							for _, post := range list {
+								karma += post.Score
+							}
+							return karma, nil
+						}, nil
+					},
+				},
+			}
+		}),
+	})
+
+	voteType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Vote",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{Type: userType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				gr := requestFrom(p.Context)
+				load := gr.users.Load(p.Context, p.Source.(*posts.Vote).UserID)
+				return func() (interface{}, error) {
+					u, ok, err := load()
+					if err != nil {
+						return nil, gr.fail(err)
+					}
+					if !ok {
+						return nil, nil
+					}
+					return u, nil
+				}, nil
+			}},
+			"vote": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*posts.Vote).Vote, nil
+			}},
+		},
+	})
+
+	commentType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Comment",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*commentNode).comment.ID.Hex(), nil
+				}},
+				"body": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*commentNode).comment.Body, nil
+				}},
+				"html": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return optional(p.Source.(*commentNode).comment.HTML), nil
+				}},
+				"created": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*commentNode).comment.Created, nil
+				}},
+				"author": &graphql.Field{Type: graphql.NewNonNull(userType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*commentNode).comment.Author, nil
+				}},
+				"parentId": &graphql.Field{Type: graphql.ID, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if parent := p.Source.(*commentNode).comment.ParentID; parent != nil {
+						return parent.Hex(), nil
+					}
+					return nil, nil
+				}},
+				"replies": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentType))),
+					Args: firstArg,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						first, err := pageSize(p)
+						if err != nil {
+							return nil, requestFrom(p.Context).fail(err)
+						}
+						node := p.Source.(*commentNode)
+						return replies(node.post, node.comment.ID, first), nil
+					},
+				},
+			}
+		}),
+	})
+
+	postType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Post",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*posts.Post).ID.Hex(), nil
+				}},
+				"type":             postField(graphql.NewNonNull(graphql.String), func(post *posts.Post) interface{} { return post.Type }),
+				"title":            postField(graphql.NewNonNull(graphql.String), func(post *posts.Post) interface{} { return post.Title }),
+				"category":         postField(graphql.NewNonNull(graphql.String), func(post *posts.Post) interface{} { return post.Category }),
+				"text":             postField(graphql.String, func(post *posts.Post) interface{} { return optional(post.Text) }),
+				"html":             postField(graphql.String, func(post *posts.Post) interface{} { return optional(post.HTML) }),
+				"url":              postField(graphql.String, func(post *posts.Post) interface{} { return optional(post.URL) }),
+				"created":          postField(graphql.NewNonNull(graphql.String), func(post *posts.Post) interface{} { return post.Created }),
+				"score":            postField(graphql.NewNonNull(graphql.Int), func(post *posts.Post) interface{} { return post.Score }),
+				"views":            postField(graphql.NewNonNull(graphql.Int), func(post *posts.Post) interface{} { return post.Views }),
+				"upvotePercentage": postField(graphql.NewNonNull(graphql.Int), func(post *posts.Post) interface{} { return post.UpvotePercentage }),
+				"voteCount":        postField(graphql.NewNonNull(graphql.Int), func(post *posts.Post) interface{} { return post.VoteCount }),
+				"commentCount":     postField(graphql.NewNonNull(graphql.Int), func(post *posts.Post) interface{} { return len(post.Comments) }),
+				"locked":           postField(graphql.NewNonNull(graphql.Boolean), func(post *posts.Post) interface{} { return post.Locked }),
+				"sticky":           postField(graphql.NewNonNull(graphql.Boolean), func(post *posts.Post) interface{} { return post.Sticky }),
+				"author":           postField(graphql.NewNonNull(userType), func(post *posts.Post) interface{} { return post.Author }),
+				"votes": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(voteType))),
+					Args: firstArg,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						first, err := pageSize(p)
+						if err != nil {
+							return nil, requestFrom(p.Context).fail(err)
+						}
+						return page(p.Source.(*posts.Post).Votes, first), nil
+					},
+				},
+				"myVote": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.Int),
+					Description: "Голос вызывающего: -1, 0 или 1. У анонимов всегда 0.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						gr := requestFrom(p.Context)
+						if gr.authorized {
+							for _, v := range p.Source.(*posts.Post).Votes {
+								if v.UserID == gr.userID {
+									return v.Vote, nil
+								}
+							}
+						}
+						return 0, nil
+					},
+				},
+				"comments": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentType))),
+					Description: "Комментарии к самому посту, от старых к новым. Ответы на них - в Comment.replies.",
+					Args:        firstArg,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						first, err := pageSize(p)
+						if err != nil {
+							return nil, requestFrom(p.Context).fail(err)
+						}
+						return replies(p.Source.(*posts.Post), primitive.NilObjectID, first), nil
+					},
+				},
+				"crosspostOf": &graphql.Field{
+					Type:        postType,
+					Description: "Оригинал кросспоста, null - если его удалили или сняли.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						ref := p.Source.(*posts.Post).Crosspost
+						if ref == nil {
+							return nil, nil
+						}
+						gr := requestFrom(p.Context)
+						load := gr.postsByID.Load(p.Context, ref.PostID)
+						return func() (interface{}, error) {
+							original, ok, err := load()
+							if err != nil {
+								return nil, gr.fail(err)
+							}
+							if !ok || gr.visible(original) == nil {
+								return nil, nil
+							}
+							return original, nil
+						}, nil
+					},
+				},
+			}
+		}),
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"post": &graphql.Field{
+				Type: postType,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					gr := requestFrom(p.Context)
+					postID, err := primitive.ObjectIDFromHex(p.Args["id"].(string))
+					if err != nil {
+						return nil, gr.fail(ErrInvalidID)
+					}
+					post, err := g.Posts.PostsRepo.GetPost(p.Context, postID)
+					if errors.Is(err, posts.ErrPostNotFound) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, gr.fail(err)
+					}
+					if gr.visible(post) == nil {
+						return nil, nil
+					}
+					g.Posts.recordView(gr.r, gr.logger, post)
+					return post, nil
+				},
+			},
+			"posts": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType))),
+				Args: graphql.FieldConfigArgument{
+					"category": &graphql.ArgumentConfig{Type: graphql.String},
+					"first":    firstArg["first"],
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					gr := requestFrom(p.Context)
+					first, err := pageSize(p)
+					if err != nil {
+						return nil, gr.fail(err)
+					}
+					var scope posts.Scope
+					if category, ok := p.Args["category"].(string); ok {
+						scope.Category = category
+					}
+					list, err := g.Posts.PostsRepo.ListPosts(p.Context, scope)
+					if err != nil {
+						return nil, gr.fail(err)
+					}
+					list = posts.Select(list, gr.listFilter(posts.FilterAll()))
+					posts.StickyFirst(list)
+					list = page(list, first)
+					for _, post := range list {
+						gr.visible(post)
+					}
+					return list, nil
+				},
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{"username": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					u, err := g.Users.GetUser(p.Context, p.Args["username"].(string))
+					if errors.Is(err, user.ErrNoUser) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, requestFrom(p.Context).fail(err)
+					}
+					return u, nil
+				},
+			},
+			"me": &graphql.Field{
+				Type:        userType,
+				Description: "Вызывающий пользователь, у анонимов - null.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					gr := requestFrom(p.Context)
+					if !gr.authorized {
+						return nil, nil
+					}
+					return &user.User{ID: gr.userID, Username: gr.username}, nil
+				},
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"vote": &graphql.Field{
+				Type:        graphql.NewNonNull(postType),
+				Description: "Голос за пост: 1, -1 или 0, чтобы снять голос.",
+				Args: graphql.FieldConfigArgument{
+					"postId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"vote":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					gr := requestFrom(p.Context)
+					post, err := gr.vote(p.Args["postId"].(string), p.Args["vote"].(int))
+					if err != nil {
+						return nil, gr.fail(err)
+					}
+					if gr.visible(post) == nil {
+						return nil, gr.fail(posts.ErrPostNotFound)
+					}
+					return post, nil
+				},
+			},
+			"comment": &graphql.Field{
+				Type: graphql.NewNonNull(commentType),
+				Args: graphql.FieldConfigArgument{
+					"postId":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"body":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"parentId": &graphql.ArgumentConfig{Type: graphql.ID},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					gr := requestFrom(p.Context)
+					parentID, _ := p.Args["parentId"].(string)
+					node, err := gr.comment(p.Args["postId"].(string), parentID, p.Args["body"].(string))
+					if err != nil {
+						return nil, gr.fail(err)
+					}
+					return node, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+// vote - мутация vote: те же проверки, лимиты и события, что у голосования через REST.
+func (gr *graphQLRequest) vote(rawID string, vote int) (*posts.Post, error) {
+	if !gr.authorized {
+		return nil, ErrUnauthorized
+	}
+	postID, err := primitive.ObjectIDFromHex(rawID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	if !posts.ValidVote(vote) {
+		return nil, posts.ErrBadVote
+	}
+	if _, err = gr.h.checkRequest(gr.r, gr.logger, ratelimit.RouteVote, gr.userID); err != nil {
+		return nil, err
+	}
+
+	post, err := gr.h.vote(gr.r, gr.logger, postID, gr.userID, gr.username, vote)
+	if err != nil {
+		return nil, err
+	}
+	gr.logger.Infow("post voted", "post_id", rawID, "vote", vote, "score", post.Score)
+	return post, nil
+}
+
+// comment - мутация comment: те же проверки, лимиты и уведомления, что у комментария через REST.
+func (gr *graphQLRequest) comment(rawPostID, rawParentID, body string) (*commentNode, error) {
+	if !gr.authorized {
+		return nil, ErrUnauthorized
+	}
+	postID, err := primitive.ObjectIDFromHex(rawPostID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	parentID := primitive.NilObjectID
+	if rawParentID != "" {
+		if parentID, err = primitive.ObjectIDFromHex(rawParentID); err != nil {
+			return nil, ErrInvalidID
+		}
+	}
+	if errors := dataValidation(&posts.CommentForm{Body: body}); errors != nil {
+		return nil, validationError(errors)
+	}
+	if _, err = gr.h.checkRequest(gr.r, gr.logger, ratelimit.RouteComment, gr.userID); err != nil {
+		return nil, err
+	}
+	if err = gr.h.checkContent(gr.r, gr.logger, gr.userID, body); err != nil {
+		return nil, err
+	}
+
+	post, err := gr.h.comment(gr.r, gr.logger, postID, parentID, body, gr.userID, gr.username)
+	if err != nil {
+		return nil, err
+	}
+	if len(post.Comments) == 0 {
+		return nil, posts.ErrCommentNotFound
+	}
+	gr.logger.Infow("comment made", "post_id", rawPostID)
+	return &commentNode{comment: post.Comments[len(post.Comments)-1], post: post}, nil
+}
+
+func postField(typ graphql.Output, get func(*posts.Post) interface{}) *graphql.Field {
+	return &graphql.Field{Type: typ, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(*posts.Post)), nil
+	}}
+}
+
+// optional - пустая строка в GraphQL становится null.
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func pageSize(p graphql.ResolveParams) (int, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 1 || first > maxFirst {
+		return 0, ErrBadFirst
+	}
+	return first, nil
+}
+
+func page[T any](list []T, first int) []T {
+	if len(list) > first {
+		return list[:first]
+	}
+	return list
+}
+
+// replies - прямые ответы на комментарий parentID, NilObjectID - комментарии к самому посту.
+func replies(post *posts.Post, parentID primitive.ObjectID, first int) []*commentNode {
+	var nodes []*commentNode
+	for _, comment := range post.Comments {
+		if len(nodes) == first {
+			break
+		}
+		parent := primitive.NilObjectID
+		if comment.ParentID != nil {
+			parent = *comment.ParentID
+		}
+		if parent == parentID {
+			nodes = append(nodes, &commentNode{comment: comment, post: post})
+		}
+	}
+	return nodes
+}
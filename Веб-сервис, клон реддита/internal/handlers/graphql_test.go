@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"redditclone/internal/user"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type graphQLTestResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func newGraphQLTest(t *testing.T, maxComplexity int) (*GraphQLHandler, *posts.MockPostRepo, *user.MockUserRepo, *sessions.MockSessionManagerInterface) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	postsRepo := posts.NewMockPostRepo(ctrl)
+	userRepo := user.NewMockUserRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	h := &PostsHandler{PostsRepo: postsRepo, Logger: zap.NewNop().Sugar(), Sessions: mockSessions}
+
+	gql, err := NewGraphQLHandler(h, userRepo, maxComplexity)
+	require.NoError(t, err)
+	return gql, postsRepo, userRepo, mockSessions
+}
+
+func doGraphQL(t *testing.T, gql *GraphQLHandler, token, body string) (*httptest.ResponseRecorder, graphQLTestResponse) {
+	req := httptest.NewRequest("POST", "/api/graphql", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	gql.GraphQL(w, req)
+
+	var resp graphQLTestResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	}
+	return w, resp
+}
+
+func graphQLBody(query string, variables map[string]interface{}) string {
+	body, _ := json.Marshal(GraphQLRequest{Query: query, Variables: variables})
+	return string(body)
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	author := &user.User{ID: 1, Username: "rvasily"}
+	commentID, replyID := primitive.NewObjectID(), primitive.NewObjectID()
+	post := &posts.Post{
+		ID:       primitive.NewObjectID(),
+		Type:     posts.TypeText,
+		Title:    "Заголовок",
+		Category: "music",
+		Author:   author,
+		Score:    2,
+		Votes:    []*posts.Vote{{UserID: 2, Vote: 1}, {UserID: 3, Vote: 1}},
+		Comments: []*posts.Comment{
+			{ID: commentID, Body: "первый", Author: author},
+			{ID: primitive.NewObjectID(), Body: "снятый", Author: author, Removed: true},
+			{ID: replyID, Body: "ответ", Author: author, ParentID: &commentID},
+		},
+	}
+	postQuery := `query($id: ID!) { post(id: $id) {
+		title author { username } votes { vote user { username } }
+		comments(first: 5) { body replies { body } } commentCount myVote
+	} }`
+
+	tests := []struct {
+		name       string
+		body       string
+		setupMocks func(*posts.MockPostRepo, *user.MockUserRepo)
+		wantData   string
+		wantCode   string
+	}{
+		{
+			name: "Пост, автор, комментарии и голоса одним запросом",
+			body: graphQLBody(postQuery, map[string]interface{}{"id": post.ID.Hex()}),
+			setupMocks: func(st *posts.MockPostRepo, users *user.MockUserRepo) {
+				copied := *post
+				copied.Comments = append([]*posts.Comment(nil), post.Comments...)
+				st.EXPECT().GetPost(gomock.Any(), post.ID).Return(&copied, nil)
+				// Пользователи всех голосов загружаются одним запросом.
+				users.EXPECT().GetUsersByIDs(gomock.Any(), []int64{2, 3}).
+					Return([]*user.User{{ID: 2, Username: "ivan"}, {ID: 3, Username: "dima"}}, nil)
+			},
+			wantData: `{"post":{"title":"Заголовок","author":{"username":"rvasily"},
+				"votes":[{"vote":1,"user":{"username":"ivan"}},{"vote":1,"user":{"username":"dima"}}],
+				"comments":[{"body":"первый","replies":[{"body":"ответ"}]}],"commentCount":2,"myVote":0}}`,
+		},
+		{
+			name: "Снятый пост не виден",
+			body: graphQLBody(`{ post(id: "`+post.ID.Hex()+`") { title } }`, nil),
+			setupMocks: func(st *posts.MockPostRepo, _ *user.MockUserRepo) {
+				st.EXPECT().GetPost(gomock.Any(), post.ID).Return(&posts.Post{ID: post.ID, Author: author, Removed: true}, nil)
+			},
+			wantData: `{"post":null}`,
+		},
+		{
+			name: "Пост не найден",
+			body: graphQLBody(`{ post(id: "`+post.ID.Hex()+`") { title } }`, nil),
+			setupMocks: func(st *posts.MockPostRepo, _ *user.MockUserRepo) {
+				st.EXPECT().GetPost(gomock.Any(), post.ID).Return(nil, posts.ErrPostNotFound)
+			},
+			wantData: `{"post":null}`,
+		},
+		{
+			name:       "Неверный id",
+			body:       graphQLBody(`{ post(id: "123") { title } }`, nil),
+			setupMocks: func(*posts.MockPostRepo, *user.MockUserRepo) {},
+			wantCode:   "invalid_id",
+		},
+		{
+			name: "Посты авторов загружаются одной пачкой",
+			body: graphQLBody(`{ posts(category: "music") { title author { username karma posts { title } } } }`, nil),
+			setupMocks: func(st *posts.MockPostRepo, _ *user.MockUserRepo) {
+				first := &posts.Post{ID: primitive.NewObjectID(), Title: "первый", Category: "music", Author: author, Score: 3}
+				second := &posts.Post{ID: primitive.NewObjectID(), Title: "второй", Category: "music", Author: &user.User{ID: 2, Username: "ivan"}, Score: 1}
+				removed := &posts.Post{ID: primitive.NewObjectID(), Title: "снятый", Category: "news", Author: author, Removed: true}
+				gomock.InOrder(
+					st.EXPECT().ListPosts(gomock.Any(), posts.Scope{Category: "music"}).Return([]*posts.Post{first, second}, nil),
+					st.EXPECT().GetPostsByAuthors(gomock.Any(), []string{"rvasily", "ivan"}).Return([]*posts.Post{first, removed, second}, nil),
+				)
+			},
+			wantData: `{"posts":[
+				{"title":"первый","author":{"username":"rvasily","karma":3,"posts":[{"title":"первый"}]}},
+				{"title":"второй","author":{"username":"ivan","karma":1,"posts":[{"title":"второй"}]}}]}`,
+		},
+		{
+			name: "Ошибка хранилища",
+			body: graphQLBody(`{ posts { title } }`, nil),
+			setupMocks: func(st *posts.MockPostRepo, _ *user.MockUserRepo) {
+				st.EXPECT().ListPosts(gomock.Any(), posts.Scope{}).Return(nil, errors.New("db error"))
+			},
+			wantCode: "internal_error",
+		},
+		{
+			name:       "first вне диапазона",
+			body:       graphQLBody(`{ posts(first: 500) { title } }`, nil),
+			setupMocks: func(*posts.MockPostRepo, *user.MockUserRepo) {},
+			wantCode:   "bad_first",
+		},
+		{
+			name:       "Слишком сложный запрос",
+			body:       graphQLBody(`{ posts(first: 100) { comments(first: 100) { body } } }`, nil),
+			setupMocks: func(*posts.MockPostRepo, *user.MockUserRepo) {},
+			wantCode:   "query_too_complex",
+		},
+		{
+			name:       "Неизвестное поле",
+			body:       graphQLBody(`{ posts { password } }`, nil),
+			setupMocks: func(*posts.MockPostRepo, *user.MockUserRepo) {},
+			wantCode:   "",
+		},
+		{
+			name: "Пользователь по логину",
+			body: graphQLBody(`{ user(username: "nobody") { id } me { id } }`, nil),
+			setupMocks: func(_ *posts.MockPostRepo, users *user.MockUserRepo) {
+				users.EXPECT().GetUser(gomock.Any(), "nobody").Return(nil, user.ErrNoUser)
+			},
+			wantData: `{"user":null,"me":null}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gql, st, users, _ := newGraphQLTest(t, 1000)
+			tc.setupMocks(st, users)
+
+			w, resp := doGraphQL(t, gql, "", tc.body)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			if tc.wantData != "" {
+				require.Empty(t, resp.Errors, w.Body.String())
+				assert.JSONEq(t, tc.wantData, string(resp.Data))
+				return
+			}
+			require.NotEmpty(t, resp.Errors, w.Body.String())
+			if tc.wantCode != "" {
+				assert.Equal(t, tc.wantCode, resp.Errors[0].Extensions["code"], w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	postID := primitive.NewObjectID()
+	voted := &posts.Post{ID: postID, Title: "Заголовок", Author: &newUser, Score: 5, Votes: []*posts.Vote{{UserID: newUser.ID, Vote: 1}}}
+	voteQuery := `mutation($post: ID!, $vote: Int!) { vote(postId: $post, vote: $vote) { score myVote } }`
+	commentQuery := `mutation($post: ID!, $body: String!) { comment(postId: $post, body: $body) { body author { username } } }`
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		setupMocks func(*posts.MockPostRepo)
+		wantData   string
+		wantCode   string
+	}{
+		{
+			name:  "Голос",
+			token: jwtToken,
+			body:  graphQLBody(voteQuery, map[string]interface{}{"post": postID.Hex(), "vote": 1}),
+			setupMocks: func(st *posts.MockPostRepo) {
+				st.EXPECT().VotePost(gomock.Any(), postID, newUser.ID, 1).Return(voted, nil)
+			},
+			wantData: `{"vote":{"score":5,"myVote":1}}`,
+		},
+		{
+			name:       "Голос без авторизации",
+			body:       graphQLBody(voteQuery, map[string]interface{}{"post": postID.Hex(), "vote": 1}),
+			setupMocks: func(*posts.MockPostRepo) {},
+			wantCode:   "unauthorized",
+		},
+		{
+			name:       "Неверный голос",
+			token:      jwtToken,
+			body:       graphQLBody(voteQuery, map[string]interface{}{"post": postID.Hex(), "vote": 2}),
+			setupMocks: func(*posts.MockPostRepo) {},
+			wantCode:   "bad_vote",
+		},
+		{
+			name:  "Комментарий",
+			token: jwtToken,
+			body:  graphQLBody(commentQuery, map[string]interface{}{"post": postID.Hex(), "body": "комментарий"}),
+			setupMocks: func(st *posts.MockPostRepo) {
+				st.EXPECT().MakeComment(gomock.Any(), postID, primitive.NilObjectID, "комментарий", newUser.Username, newUser.ID).
+					Return(&posts.Post{ID: postID, Comments: []*posts.Comment{{ID: primitive.NewObjectID(), Body: "комментарий", Author: &newUser}}}, nil)
+			},
+			wantData: `{"comment":{"body":"комментарий","author":{"username":"rvasily"}}}`,
+		},
+		{
+			name:       "Пустой комментарий",
+			token:      jwtToken,
+			body:       graphQLBody(commentQuery, map[string]interface{}{"post": postID.Hex(), "body": ""}),
+			setupMocks: func(*posts.MockPostRepo) {},
+			wantCode:   "validation_failed",
+		},
+		{
+			name:  "Пост закрыт",
+			token: jwtToken,
+			body:  graphQLBody(commentQuery, map[string]interface{}{"post": postID.Hex(), "body": "комментарий"}),
+			setupMocks: func(st *posts.MockPostRepo) {
+				st.EXPECT().MakeComment(gomock.Any(), postID, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, posts.ErrPostLocked)
+			},
+			wantCode: "post_locked",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gql, st, _, mockSessions := newGraphQLTest(t, 1000)
+			mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).AnyTimes()
+			tc.setupMocks(st)
+
+			w, resp := doGraphQL(t, gql, tc.token, tc.body)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			if tc.wantData != "" {
+				require.Empty(t, resp.Errors, w.Body.String())
+				assert.JSONEq(t, tc.wantData, string(resp.Data))
+				return
+			}
+			require.NotEmpty(t, resp.Errors, w.Body.String())
+			assert.Equal(t, tc.wantCode, resp.Errors[0].Extensions["code"], w.Body.String())
+		})
+	}
+}
+
+func TestGraphQLBadRequest(t *testing.T) {
+	gql, _, _, _ := newGraphQLTest(t, 0)
+
+	w, _ := doGraphQL(t, gql, "", `{"query":`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = doGraphQL(t, gql, "", `{"variables":{}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestQueryComplexity(t *testing.T) {
+	gql, _, _, _ := newGraphQLTest(t, 0)
+
+	tests := []struct {
+		name      string
+		query     string
+		operation string
+		variables map[string]interface{}
+		want      int
+	}{
+		{
+			name:  "Одно поле",
+			query: `{ me { username } }`,
+			want:  2,
+		},
+		{
+			name:  "Список умножает вложенные поля",
+			query: `{ posts(first: 10) { title author { username } } }`,
+			want:  1 + 10*(1+2),
+		},
+		{
+			name:  "Без first берётся значение по умолчанию",
+			query: `{ posts { title } }`,
+			want:  1 + defaultFirst,
+		},
+		{
+			name:      "first из переменной",
+			query:     `query($n: Int) { posts(first: $n) { title comments(first: 2) { body } } }`,
+			variables: map[string]interface{}{"n": float64(3)},
+			want:      1 + 3*(1+1+2*1),
+		},
+		{
+			name:  "Фрагменты",
+			query: `{ posts(first: 2) { ...p ... on Post { id } } } fragment p on Post { title score }`,
+			want:  1 + 2*3,
+		},
+		{
+			name:      "Выбранная операция",
+			query:     `query a { me { id } } mutation b { vote(postId: "1", vote: 1) { score votes(first: 4) { vote } } }`,
+			operation: "b",
+			want:      1 + 1 + (1 + 4),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := parser.Parse(parser.ParseParams{Source: tc.query})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, queryComplexity(gql.schema, doc, tc.operation, tc.variables))
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"redditclone/internal/media"
+	"redditclone/internal/posts"
+)
+
+const (
+	// multipartOverhead - запас к размеру картинки на текстовые поля и разметку multipart.
+	multipartOverhead = 1 << 20
+	// multipartMemory - сколько формы держать в памяти, остальное ParseMultipartForm пишет во временные файлы.
+	multipartMemory = 1 << 20
+)
+
+var (
+	ErrUploadsDisabled = &HTTPError{Status: http.StatusBadRequest, Code: "uploads_disabled", Message: "image uploads are disabled"}
+	ErrUploadTooLarge  = &HTTPError{Status: http.StatusRequestEntityTooLarge, Code: "upload_too_large", Message: "upload is too large"}
+)
+
+// readPostForm читает форму поста: из JSON или, для постов с картинкой, из multipart/form-data
+// с полями формы и файлом в поле image. Файла может не быть, тогда file == nil.
+func (h *PostsHandler) readPostForm(w http.ResponseWriter, r *http.Request) (*posts.PostForm, multipart.File, error) {
+	fd := &posts.PostForm{}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, nil, ErrReading
+		}
+		r.Body.Close()
+		if err = json.Unmarshal(body, fd); err != nil {
+			return nil, nil, ErrBadRequest
+		}
+		return fd, nil, nil
+	}
+
+	if h.Media == nil {
+		return nil, nil, ErrUploadsDisabled
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.Media.Limits.MaxBytes+multipartOverhead)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, nil, ErrUploadTooLarge
+		}
+		return nil, nil, ErrBadRequest
+	}
+	fd.Type = r.FormValue("type")
+	fd.Title = r.FormValue("title")
+	fd.Category = r.FormValue("category")
+	fd.Text = r.FormValue("text")
+	fd.URL = r.FormValue("url")
+
+	file, _, err := r.FormFile("image")
+	if errors.Is(err, http.ErrMissingFile) {
+		return fd, nil, nil
+	}
+	if err != nil {
+		return nil, nil, ErrBadRequest
+	}
+	return fd, file, nil
+}
+
+// uploadImage сохраняет картинку поста. Если пост потом не создастся, файлы останутся в хранилище,
+// но ключи у них по содержимому, так что повторная загрузка их переиспользует.
+func (h *PostsHandler) uploadImage(ctx context.Context, file multipart.File) (*posts.Image, error) {
+	if h.Media == nil {
+		return nil, ErrUploadsDisabled
+	}
+	if file == nil {
+		return nil, posts.ErrNoImage
+	}
+	upload, err := h.Media.Upload(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	return &posts.Image{
+		Key:          upload.Key,
+		ThumbnailKey: upload.ThumbnailKey,
+		URL:          media.URL(upload.Key),
+		ThumbnailURL: media.URL(upload.ThumbnailKey),
+		ContentType:  upload.ContentType,
+		Width:        upload.Width,
+		Height:       upload.Height,
+	}, nil
+}
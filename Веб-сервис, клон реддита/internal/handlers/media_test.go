@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/media"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// imageForm собирает multipart-форму поста. file == nil - без файла.
+func imageForm(t *testing.T, fields map[string]string, file []byte) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		require.NoError(t, mw.WriteField(k, v))
+	}
+	if file != nil {
+		part, err := mw.CreateFormFile("image", "photo.png")
+		require.NoError(t, err)
+		_, err = part.Write(file)
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+	return &body, mw.FormDataContentType()
+}
+
+func TestMakeImagePost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := posts.NewMockPostRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	store, err := media.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	uploader := media.NewUploader(store, media.Limits{MaxBytes: 64 << 10, MaxPixels: 1 << 20, ThumbSize: 16})
+
+	var pic bytes.Buffer
+	require.NoError(t, png.Encode(&pic, image.NewRGBA(image.Rect(0, 0, 40, 20))))
+	fields := map[string]string{"type": posts.TypeImage, "title": "Котик", "category": "funny"}
+	session := &sessions.Session{ID: newUser.ID, Login: newUser.Username}
+
+	tests := []struct {
+		name       string
+		uploader   *media.Uploader
+		fields     map[string]string
+		file       []byte
+		setupMocks func()
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:     "Пост с картинкой",
+			uploader: uploader,
+			fields:   fields,
+			file:     pic.Bytes(),
+			setupMocks: func() {
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(session)
+				st.EXPECT().MakePost(gomock.Any(), gomock.Any(), newUser.Username, newUser.ID).
+					DoAndReturn(func(_ context.Context, form *posts.PostForm, _ string, _ int64) (*posts.Post, error) {
+						assert.Equal(t, "Котик", form.Title)
+						if assert.NotNil(t, form.Image) {
+							assert.Equal(t, media.URL(form.Image.Key), form.Image.URL)
+							assert.Equal(t, media.URL(form.Image.ThumbnailKey), form.Image.ThumbnailURL)
+							assert.Equal(t, [2]int{40, 20}, [2]int{form.Image.Width, form.Image.Height})
+							_, _, err := store.Get(context.Background(), form.Image.ThumbnailKey)
+							assert.NoError(t, err, "thumbnail is not stored")
+						}
+						return &posts.Post{ID: resultPost[0].ID, Type: form.Type, Image: form.Image}, nil
+					})
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:     "Не картинка",
+			uploader: uploader,
+			fields:   fields,
+			file:     []byte("#!/bin/sh\nrm -rf /\n"),
+			setupMocks: func() {
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(session)
+			},
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantCode:   "unsupported_media_type",
+		},
+		{
+			name:     "Нет файла",
+			uploader: uploader,
+			fields:   fields,
+			setupMocks: func() {
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(session)
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "image_required",
+		},
+		{
+			name:       "Файл больше лимита",
+			uploader:   uploader,
+			fields:     fields,
+			file:       bytes.Repeat([]byte{0}, 2<<20),
+			setupMocks: func() {},
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   "upload_too_large",
+		},
+		{
+			name:       "Загрузки выключены",
+			fields:     fields,
+			file:       pic.Bytes(),
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "uploads_disabled",
+		},
+		{
+			name:     "Текстовый пост формой",
+			uploader: uploader,
+			fields:   map[string]string{"type": posts.TypeText, "title": "Привет", "category": "news", "text": "текст"},
+			setupMocks: func() {
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(session)
+				st.EXPECT().MakePost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, form *posts.PostForm, _ string, _ int64) (*posts.Post, error) {
+						assert.Nil(t, form.Image)
+						assert.Equal(t, "текст", form.Text)
+						return &posts.Post{ID: resultPost[0].ID, Type: form.Type}, nil
+					})
+			},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			service := &PostsHandler{
+				PostsRepo: st,
+				Logger:    zap.NewNop().Sugar(),
+				Sessions:  mockSessions,
+				Media:     tc.uploader,
+			}
+			tc.setupMocks()
+
+			body, contentType := imageForm(t, tc.fields, tc.file)
+			req := httptest.NewRequest("POST", "/api/posts", body)
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", jwtToken))
+			w := httptest.NewRecorder()
+
+			service.MakePost(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantCode != "" {
+				assert.True(t, strings.Contains(w.Body.String(), `"code":"`+tc.wantCode+`"`), w.Body.String())
+			}
+		})
+	}
+}
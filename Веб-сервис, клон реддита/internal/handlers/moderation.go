@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"redditclone/internal/moderation"
+	"redditclone/internal/posts"
+	"redditclone/internal/ratelimit"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrForbidden = &HTTPError{Status: http.StatusForbidden, Code: "forbidden", Message: "moderator rights required"}
+	ErrBadReason = &HTTPError{Status: http.StatusBadRequest, Code: "bad_reason", Message: "reason must be one of spam, harassment, hate, violence, misinformation, other"}
+	ErrBadAction = &HTTPError{Status: http.StatusBadRequest, Code: "bad_action", Message: "action is not allowed for this target"}
+)
+
+type ReportForm struct {
+	Reason  string `json:"reason"  validate:"required"`
+	Details string `json:"details,omitempty"  validate:"max=1000"`
+}
+
+// ModerationForm - необязательное тело действия модератора, причина попадает в журнал.
+type ModerationForm struct {
+	Reason string `json:"reason,omitempty"  validate:"max=1000"`
+}
+
+// queueEntry - цель из очереди жалоб вместе с самим постом или комментарием.
+type queueEntry struct {
+	*moderation.QueueItem
+	Post    *posts.Post    `json:"post,omitempty"`
+	Comment *posts.Comment `json:"comment,omitempty"`
+}
+
+type queuePage struct {
+	Items   []queueEntry `json:"items"`
+	Page    int          `json:"page"`
+	Limit   int          `json:"limit"`
+	HasMore bool         `json:"hasMore"`
+}
+
+type auditPage struct {
+	Items   []*moderation.AuditEntry `json:"items"`
+	Page    int                      `json:"page"`
+	Limit   int                      `json:"limit"`
+	HasMore bool                     `json:"hasMore"`
+}
+
+// isModerator сообщает, что запрос пришёл от модератора. Без списка модераторов токен даже не проверяется.
+func (h *PostsHandler) isModerator(r *http.Request) bool {
+	if len(h.Moderators) == 0 {
+		return false
+	}
+	_, username, err := authUser(r, h)
+	return err == nil && h.Moderators.Is(username)
+}
+
+// moderator авторизует запрос и требует прав модератора.
+func (h *PostsHandler) moderator(r *http.Request) (int64, string, error) {
+	userID, username, err := authUser(r, h)
+	if err != nil {
+		return 0, "", err
+	}
+	if !h.Moderators.Is(username) {
+		return 0, "", ErrForbidden
+	}
+	return userID, username, nil
+}
+
+// withoutRemoved убирает снятые посты из выдачи для всех, кроме модераторов.
+func (h *PostsHandler) withoutRemoved(r *http.Request, filter func(*posts.Post) bool) func(*posts.Post) bool {
+	if h.isModerator(r) {
+		return filter
+	}
+	return posts.WithoutRemoved(filter)
+}
+
+// moderatedView прячет снятый пост и снятые комментарии от всех, кроме модераторов.
+func (h *PostsHandler) moderatedView(r *http.Request, post *posts.Post) error {
+	if !post.Removed && !hasRemovedComments(post) {
+		return nil
+	}
+	if h.isModerator(r) {
+		return nil
+	}
+	if post.Removed {
+		return posts.ErrPostNotFound
+	}
+	posts.VisibleComments(post)
+	return nil
+}
+
+func hasRemovedComments(post *posts.Post) bool {
+	for _, comment := range post.Comments {
+		if comment.Removed {
+			return true
+		}
+	}
+	return false
+}
+
+// moderationTarget читает цель из маршрута: пост или, если есть COMMENT_ID, его комментарий.
+func moderationTarget(r *http.Request) (moderation.Target, error) {
+	vars := mux.Vars(r)
+	postID, err := primitive.ObjectIDFromHex(vars["POST_ID"])
+	if err != nil {
+		return moderation.Target{}, ErrInvalidID
+	}
+	target := moderation.Target{PostID: postID}
+	if rawCommentID, ok := vars["COMMENT_ID"]; ok {
+		commentID, err := primitive.ObjectIDFromHex(rawCommentID)
+		if err != nil {
+			return moderation.Target{}, ErrInvalidID
+		}
+		target.CommentID = &commentID
+	}
+	return target, nil
+}
+
+// findTarget проверяет, что цель существует и видна обычным пользователям.
+func (h *PostsHandler) findTarget(r *http.Request, target moderation.Target) error {
+	post, err := h.PostsRepo.GetPost(r.Context(), target.PostID)
+	if err != nil {
+		return err
+	}
+	if post.Removed {
+		return posts.ErrPostNotFound
+	}
+	if target.CommentID == nil {
+		return nil
+	}
+	for _, comment := range post.Comments {
+		if comment.ID == *target.CommentID && !comment.Removed {
+			return nil
+		}
+	}
+	return posts.ErrCommentNotFound
+}
+
+// ReportItem принимает жалобу на пост или комментарий: {"reason": "spam", "details": "..."}.
+func (h *PostsHandler) ReportItem(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger).With("post_id", mux.Vars(r)["POST_ID"])
+
+	target, err := moderationTarget(r)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, logger, ErrReading)
+		return
+	}
+	r.Body.Close()
+
+	fd := &ReportForm{}
+	if err = json.Unmarshal(body, fd); err != nil {
+		writeError(w, r, logger, ErrBadRequest)
+		return
+	}
+	if errors := dataValidation(fd); errors != nil {
+		writeError(w, r, logger, validationError(errors))
+		return
+	}
+	if !moderation.ValidReason(moderation.Reason(fd.Reason)) {
+		writeError(w, r, logger, ErrBadReason)
+		return
+	}
+
+	userID, _, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	if !h.allowRequest(w, r, logger, ratelimit.RouteReport, userID) {
+		return
+	}
+
+	if err = h.findTarget(r, target); err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	report := &moderation.Report{Target: target, ReporterID: userID, Reason: moderation.Reason(fd.Reason), Details: fd.Details}
+	if err = h.Reports.Report(r.Context(), report); err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	logger.Infow("item reported", "reason", fd.Reason, "comment", target.CommentID != nil)
+	writeJSON(w, logger, http.StatusOK, SuccessResponse)
+}
+
+// GetModQueue отдаёт модератору цели с открытыми жалобами, сначала самые обжалованные.
+func (h *PostsHandler) GetModQueue(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger)
+
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	userID, _, err := h.moderator(r)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	items, err := h.Reports.Queue(r.Context(), (page-1)*limit, limit+1)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	resp := queuePage{Items: []queueEntry{}, Page: page, Limit: limit}
+	if len(items) > limit {
+		items = items[:limit]
+		resp.HasMore = true
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.Target.PostID)
+	}
+	found, err := h.PostsRepo.GetPostsByIDs(r.Context(), ids)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	byID := make(map[primitive.ObjectID]*posts.Post, len(found))
+	for _, post := range found {
+		byID[post.ID] = post
+	}
+
+	// Удалённые авторами посты и комментарии остаются в очереди без содержимого,
+	// чтобы модератор мог закрыть жалобы на них.
+	for _, item := range items {
+		entry := queueEntry{QueueItem: item, Post: byID[item.Target.PostID]}
+		if entry.Post != nil && item.Target.CommentID != nil {
+			for _, comment := range entry.Post.Comments {
+				if comment.ID == *item.Target.CommentID {
+					entry.Comment = comment
+					break
+				}
+			}
+		}
+		resp.Items = append(resp.Items, entry)
+	}
+
+	logger.Infow("moderation queue received", "count", len(resp.Items), "page", page)
+	writeJSON(w, logger, http.StatusOK, resp)
+}
+
+// GetModLog отдаёт журнал модерации от новых записей к старым.
+func (h *PostsHandler) GetModLog(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger)
+
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	userID, _, err := h.moderator(r)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	entries, err := h.AuditLog.List(r.Context(), (page-1)*limit, limit+1)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	resp := auditPage{Items: entries, Page: page, Limit: limit}
+	if len(entries) > limit {
+		resp.Items = entries[:limit]
+		resp.HasMore = true
+	}
+
+	logger.Infow("moderation log received", "count", len(resp.Items), "page", page)
+	writeJSON(w, logger, http.StatusOK, resp)
+}
+
+// Moderate выполняет действие модератора над постом или комментарием и пишет его в журнал.
+// Маршрут: /api/mod/post/{POST_ID}[/{COMMENT_ID}]/{ACTION}.
+func (h *PostsHandler) Moderate(w http.ResponseWriter, r *http.Request) {
+	action := moderation.Action(mux.Vars(r)["ACTION"])
+	logger := requestLogger(r, h.Logger).With("post_id", mux.Vars(r)["POST_ID"], "action", action)
+
+	target, err := moderationTarget(r)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, logger, ErrReading)
+		return
+	}
+	r.Body.Close()
+
+	fd := &ModerationForm{}
+	if len(body) > 0 {
+		if err = json.Unmarshal(body, fd); err != nil {
+			writeError(w, r, logger, ErrBadRequest)
+			return
+		}
+	}
+
+	userID, username, err := h.moderator(r)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	post, err := h.applyModeration(r, target, action)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	// Снятие и одобрение закрывают жалобы на цель.
+	status := map[moderation.Action]moderation.Status{
+		moderation.ActionRemove:  moderation.StatusRemoved,
+		moderation.ActionApprove: moderation.StatusApproved,
+	}[action]
+	if status != "" {
+		resolved, err := h.Reports.Resolve(r.Context(), target, status)
+		if err != nil {
+			writeError(w, r, logger, err)
+			return
+		}
+		logger = logger.With("resolved_reports", resolved)
+	}
+
+	entry := &moderation.AuditEntry{ActorID: userID, Actor: username, Action: action, Target: target, Reason: fd.Reason}
+	if err = h.AuditLog.Append(r.Context(), entry); err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	logger.Infow("moderation action applied", "comment", target.CommentID != nil)
+	writeJSON(w, logger, http.StatusOK, post)
+}
+
+func (h *PostsHandler) applyModeration(r *http.Request, target moderation.Target, action moderation.Action) (*posts.Post, error) {
+	if target.CommentID != nil {
+		switch action {
+		case moderation.ActionRemove:
+			return h.PostsRepo.SetCommentRemoved(r.Context(), target.PostID, *target.CommentID, true)
+		case moderation.ActionApprove:
+			return h.PostsRepo.SetCommentRemoved(r.Context(), target.PostID, *target.CommentID, false)
+		case moderation.ActionRestore:
+			return h.PostsRepo.RestoreComment(r.Context(), target.PostID, *target.CommentID, 0)
+		}
+		return nil, ErrBadAction
+	}
+
+	if action == moderation.ActionRestore {
+		return h.PostsRepo.RestorePost(r.Context(), target.PostID, 0)
+	}
+
+	yes, no := true, false
+	var flags posts.PostFlags
+	switch action {
+	case moderation.ActionRemove:
+		flags.Removed = &yes
+	case moderation.ActionApprove:
+		flags.Removed = &no
+	case moderation.ActionLock:
+		flags.Locked = &yes
+	case moderation.ActionUnlock:
+		flags.Locked = &no
+	case moderation.ActionSticky:
+		flags.Sticky = &yes
+	case moderation.ActionUnsticky:
+		flags.Sticky = &no
+	default:
+		return nil, ErrBadAction
+	}
+	return h.PostsRepo.SetPostFlags(r.Context(), target.PostID, flags)
+}
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/moderation"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func newModerationService(ctrl *gomock.Controller, moderators ...string) (*PostsHandler, *posts.MockPostRepo, *moderation.MockReportRepo, *moderation.MockAuditLog, *sessions.MockSessionManagerInterface) {
+	st := posts.NewMockPostRepo(ctrl)
+	reports := moderation.NewMockReportRepo(ctrl)
+	audit := moderation.NewMockAuditLog(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{
+		PostsRepo:  st,
+		Logger:     zap.NewNop().Sugar(),
+		Sessions:   mockSessions,
+		Moderators: moderation.NewModerators(moderators),
+		Reports:    reports,
+		AuditLog:   audit,
+	}
+	return service, st, reports, audit, mockSessions
+}
+
+func moderationRouter(service *PostsHandler) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/mod/queue", service.GetModQueue).Methods("GET")
+	router.HandleFunc("/api/mod/log", service.GetModLog).Methods("GET")
+	router.HandleFunc("/api/mod/post/{POST_ID}/{ACTION:remove|approve|lock|unlock|sticky|unsticky|restore}", service.Moderate).Methods("POST")
+	router.HandleFunc("/api/mod/post/{POST_ID}/{COMMENT_ID}/{ACTION:remove|approve|restore}", service.Moderate).Methods("POST")
+	router.HandleFunc("/api/post/{POST_ID}/report", service.ReportItem).Methods("POST")
+	router.HandleFunc("/api/post/{POST_ID}/{COMMENT_ID}/report", service.ReportItem).Methods("POST")
+	router.HandleFunc("/api/post/{POST_ID}", service.GetPost).Methods("GET")
+	return router
+}
+
+func TestReportItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, st, reports, _, mockSessions := newModerationService(ctrl)
+	router := moderationRouter(service)
+
+	postID, commentID := primitive.NewObjectID(), primitive.NewObjectID()
+	post := &posts.Post{ID: postID, Comments: []*posts.Comment{{ID: commentID}}}
+
+	tests := []struct {
+		name       string
+		route      string
+		body       string
+		token      string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name:  "Жалоба на пост",
+			route: fmt.Sprintf("/api/post/%s/report", postID.Hex()),
+			body:  `{"reason":"spam","details":"реклама"}`,
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().GetPost(gomock.Any(), postID).Return(post, nil)
+				reports.EXPECT().Report(gomock.Any(), &moderation.Report{
+					Target: moderation.Target{PostID: postID}, ReporterID: newUser.ID, Reason: moderation.ReasonSpam, Details: "реклама",
+				}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Жалоба на комментарий",
+			route: fmt.Sprintf("/api/post/%s/%s/report", postID.Hex(), commentID.Hex()),
+			body:  `{"reason":"harassment"}`,
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().GetPost(gomock.Any(), postID).Return(post, nil)
+				reports.EXPECT().Report(gomock.Any(), &moderation.Report{
+					Target: moderation.Target{PostID: postID, CommentID: &commentID}, ReporterID: newUser.ID, Reason: moderation.ReasonHarassment,
+				}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Жалоба на снятый пост",
+			route: fmt.Sprintf("/api/post/%s/report", postID.Hex()),
+			body:  `{"reason":"spam"}`,
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().GetPost(gomock.Any(), postID).Return(&posts.Post{ID: postID, Removed: true}, nil)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Неизвестная причина",
+			route:      fmt.Sprintf("/api/post/%s/report", postID.Hex()),
+			body:       `{"reason":"boring"}`,
+			token:      jwtToken,
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Без причины",
+			route:      fmt.Sprintf("/api/post/%s/report", postID.Hex()),
+			body:       `{}`,
+			token:      jwtToken,
+			setupMocks: func() {},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Без авторизации",
+			route:      fmt.Sprintf("/api/post/%s/report", postID.Hex()),
+			body:       `{"reason":"spam"}`,
+			setupMocks: func() {},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+
+			req := httptest.NewRequest("POST", tc.route, strings.NewReader(tc.body))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestModerate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, st, reports, audit, mockSessions := newModerationService(ctrl, newUser.Username)
+	router := moderationRouter(service)
+
+	postID, commentID := primitive.NewObjectID(), primitive.NewObjectID()
+	post := &posts.Post{ID: postID}
+	yes, no := true, false
+
+	tests := []struct {
+		name       string
+		route      string
+		body       string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name:  "Снятие поста закрывает жалобы и пишет журнал",
+			route: fmt.Sprintf("/api/mod/post/%s/remove", postID.Hex()),
+			body:  `{"reason":"spam"}`,
+			setupMocks: func() {
+				target := moderation.Target{PostID: postID}
+				st.EXPECT().SetPostFlags(gomock.Any(), postID, posts.PostFlags{Removed: &yes}).Return(post, nil)
+				reports.EXPECT().Resolve(gomock.Any(), target, moderation.StatusRemoved).Return(int64(3), nil)
+				audit.EXPECT().Append(gomock.Any(), &moderation.AuditEntry{
+					ActorID: newUser.ID, Actor: newUser.Username, Action: moderation.ActionRemove, Target: target, Reason: "spam",
+				}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Закрытие поста не трогает жалобы",
+			route: fmt.Sprintf("/api/mod/post/%s/lock", postID.Hex()),
+			setupMocks: func() {
+				st.EXPECT().SetPostFlags(gomock.Any(), postID, posts.PostFlags{Locked: &yes}).Return(post, nil)
+				audit.EXPECT().Append(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Открепление поста",
+			route: fmt.Sprintf("/api/mod/post/%s/unsticky", postID.Hex()),
+			setupMocks: func() {
+				st.EXPECT().SetPostFlags(gomock.Any(), postID, posts.PostFlags{Sticky: &no}).Return(post, nil)
+				audit.EXPECT().Append(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Одобрение комментария",
+			route: fmt.Sprintf("/api/mod/post/%s/%s/approve", postID.Hex(), commentID.Hex()),
+			setupMocks: func() {
+				st.EXPECT().SetCommentRemoved(gomock.Any(), postID, commentID, false).Return(post, nil)
+				reports.EXPECT().Resolve(gomock.Any(), moderation.Target{PostID: postID, CommentID: &commentID}, moderation.StatusApproved).Return(int64(1), nil)
+				audit.EXPECT().Append(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Восстановление удалённого автором поста",
+			route: fmt.Sprintf("/api/mod/post/%s/restore", postID.Hex()),
+			setupMocks: func() {
+				st.EXPECT().RestorePost(gomock.Any(), postID, int64(0)).Return(post, nil)
+				audit.EXPECT().Append(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Восстановление удалённого комментария",
+			route: fmt.Sprintf("/api/mod/post/%s/%s/restore", postID.Hex(), commentID.Hex()),
+			setupMocks: func() {
+				st.EXPECT().RestoreComment(gomock.Any(), postID, commentID, int64(0)).Return(post, nil)
+				audit.EXPECT().Append(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Пост не найден - журнал не пишется",
+			route: fmt.Sprintf("/api/mod/post/%s/sticky", postID.Hex()),
+			setupMocks: func() {
+				st.EXPECT().SetPostFlags(gomock.Any(), postID, posts.PostFlags{Sticky: &yes}).Return(nil, posts.ErrPostNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Битое тело",
+			route:      fmt.Sprintf("/api/mod/post/%s/remove", postID.Hex()),
+			body:       `{`,
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+
+			req := httptest.NewRequest("POST", tc.route, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+jwtToken)
+			mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestModerationForbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, _, _, _, mockSessions := newModerationService(ctrl, "admin")
+	router := moderationRouter(service)
+
+	for _, route := range []string{"/api/mod/queue", "/api/mod/log"} {
+		mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+
+		req := httptest.NewRequest("GET", route, nil)
+		req.Header.Set("Authorization", "Bearer "+jwtToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code, route)
+	}
+
+	mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/mod/post/%s/remove", primitive.NewObjectID().Hex()), nil)
+	req.Header.Set("Authorization", "Bearer "+jwtToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestGetModQueue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, st, reports, _, mockSessions := newModerationService(ctrl, newUser.Username)
+	router := moderationRouter(service)
+
+	postID, commentID := primitive.NewObjectID(), primitive.NewObjectID()
+	items := []*moderation.QueueItem{
+		{Target: moderation.Target{PostID: postID, CommentID: &commentID}, Reports: 4, Reasons: []moderation.Reason{moderation.ReasonSpam}},
+		{Target: moderation.Target{PostID: postID}, Reports: 2},
+		// Пост удалён автором - остаётся в очереди без содержимого.
+		{Target: moderation.Target{PostID: primitive.NewObjectID()}, Reports: 1},
+	}
+	post := &posts.Post{ID: postID, Title: "t", Comments: []*posts.Comment{{ID: commentID, Body: "c"}}}
+
+	mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+	reports.EXPECT().Queue(gomock.Any(), 0, 3).Return(items, nil)
+	st.EXPECT().GetPostsByIDs(gomock.Any(), []primitive.ObjectID{postID, postID}).Return([]*posts.Post{post}, nil)
+
+	req := httptest.NewRequest("GET", "/api/mod/queue?limit=2", nil)
+	req.Header.Set("Authorization", "Bearer "+jwtToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp := struct {
+		Items []struct {
+			Target  moderation.Target `json:"target"`
+			Reports int               `json:"reports"`
+			Post    *posts.Post       `json:"post"`
+			Comment *posts.Comment    `json:"comment"`
+		} `json:"items"`
+		HasMore bool `json:"hasMore"`
+	}{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.HasMore)
+	if assert.Len(t, resp.Items, 2) {
+		assert.Equal(t, 4, resp.Items[0].Reports)
+		assert.Equal(t, "c", resp.Items[0].Comment.Body)
+		assert.Equal(t, "t", resp.Items[1].Post.Title)
+		assert.Nil(t, resp.Items[1].Comment)
+	}
+}
+
+func TestGetPostModerated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, st, _, _, mockSessions := newModerationService(ctrl, newUser.Username)
+	router := moderationRouter(service)
+
+	postID := primitive.NewObjectID()
+	removed := func() *posts.Post { return &posts.Post{ID: postID, Removed: true} }
+	withComments := func() *posts.Post {
+		return &posts.Post{ID: postID, Comments: []*posts.Comment{{ID: primitive.NewObjectID()}, {ID: primitive.NewObjectID(), Removed: true}}}
+	}
+
+	tests := []struct {
+		name         string
+		post         func() *posts.Post
+		token        string
+		wantStatus   int
+		wantComments int
+	}{
+		{name: "Снятый пост не виден пользователю", post: removed, wantStatus: http.StatusNotFound},
+		{name: "Снятый пост виден модератору", post: removed, token: jwtToken, wantStatus: http.StatusOK},
+		{name: "Снятые комментарии скрыты", post: withComments, wantStatus: http.StatusOK, wantComments: 1},
+		{name: "Модератор видит снятые комментарии", post: withComments, token: jwtToken, wantStatus: http.StatusOK, wantComments: 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			st.EXPECT().GetPost(gomock.Any(), postID).Return(tc.post(), nil)
+
+			req := httptest.NewRequest("GET", "/api/post/"+postID.Hex(), nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantStatus == http.StatusOK {
+				got := posts.Post{}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+				assert.Len(t, got.Comments, tc.wantComments)
+			}
+		})
+	}
+}
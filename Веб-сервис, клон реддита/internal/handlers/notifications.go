@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"redditclone/internal/notifications"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type notificationPage struct {
+	Items []*notifications.Notification `json:"items"`
+	// Unread - сколько всего непрочитанных, для счётчика в шапке.
+	Unread  int64 `json:"unread"`
+	Page    int   `json:"page"`
+	Limit   int   `json:"limit"`
+	HasMore bool  `json:"hasMore"`
+}
+
+// MarkReadForm - тело запроса на прочтение. Пустой список или пустое тело - прочитать всё.
+type MarkReadForm struct {
+	IDs []string `json:"ids"`
+}
+
+// notify передаёт событие в уведомления, если они подключены. Не блокирует запрос.
+func (h *PostsHandler) notify(ev notifications.Event) {
+	if h.Notifier != nil {
+		h.Notifier.Publish(ev)
+	}
+}
+
+// GetNotifications отдаёт уведомления пользователя от новых к старым: ?unread=true&page=N&limit=N.
+func (h *PostsHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger)
+
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	unreadOnly := false
+	if raw := r.URL.Query().Get("unread"); raw != "" {
+		if unreadOnly, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, r, logger, ErrBadRequest)
+			return
+		}
+	}
+
+	userID, _, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	// Берём на одну запись больше, чтобы понять, есть ли следующая страница.
+	items, err := h.Notifications.List(r.Context(), userID, unreadOnly, (page-1)*limit, limit+1)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	unread, err := h.Notifications.UnreadCount(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	resp := notificationPage{Items: items, Unread: unread, Page: page, Limit: limit}
+	if len(items) > limit {
+		resp.Items = items[:limit]
+		resp.HasMore = true
+	}
+
+	logger.Infow("notifications received", "count", len(resp.Items), "unread", unread, "page", page)
+	writeJSON(w, logger, http.StatusOK, resp)
+}
+
+func (h *PostsHandler) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, logger, ErrReading)
+		return
+	}
+	r.Body.Close()
+
+	fd := &MarkReadForm{}
+	if len(body) > 0 {
+		if err = json.Unmarshal(body, fd); err != nil {
+			writeError(w, r, logger, ErrBadRequest)
+			return
+		}
+	}
+	ids := make([]primitive.ObjectID, 0, len(fd.IDs))
+	for _, raw := range fd.IDs {
+		id, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			writeError(w, r, logger, ErrInvalidID)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	userID, _, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	updated, err := h.Notifications.MarkRead(r.Context(), userID, ids)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	logger.Infow("notifications read", "ids", len(ids), "updated", updated)
+	writeJSON(w, logger, http.StatusOK, map[string]int64{"updated": updated})
+}
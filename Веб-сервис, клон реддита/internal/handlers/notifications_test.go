@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/notifications"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"redditclone/internal/user"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// recordingPublisher запоминает события вместо очереди.
+type recordingPublisher struct {
+	events []notifications.Event
+}
+
+func (p *recordingPublisher) Publish(ev notifications.Event) bool {
+	p.events = append(p.events, ev)
+	return true
+}
+
+func TestGetNotifications(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := notifications.NewMockNotificationRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{
+		Logger:        zap.NewNop().Sugar(),
+		Sessions:      mockSessions,
+		Notifications: repo,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/notifications", service.GetNotifications).Methods("GET")
+
+	items := []*notifications.Notification{
+		{ID: primitive.NewObjectID(), Type: notifications.TypeReply},
+		{ID: primitive.NewObjectID(), Type: notifications.TypeMention},
+		{ID: primitive.NewObjectID(), Type: notifications.TypeMilestone},
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		token       string
+		setupMocks  func()
+		wantStatus  int
+		wantCount   int
+		wantHasMore bool
+	}{
+		{
+			name:  "Первая страница с признаком продолжения",
+			query: "?limit=2",
+			token: jwtToken,
+			setupMocks: func() {
+				repo.EXPECT().List(gomock.Any(), newUser.ID, false, 0, 3).Return(items, nil)
+				repo.EXPECT().UnreadCount(gomock.Any(), newUser.ID).Return(int64(5), nil)
+			},
+			wantStatus:  http.StatusOK,
+			wantCount:   2,
+			wantHasMore: true,
+		},
+		{
+			name:  "Только непрочитанные, вторая страница",
+			query: "?unread=true&page=2&limit=10",
+			token: jwtToken,
+			setupMocks: func() {
+				repo.EXPECT().List(gomock.Any(), newUser.ID, true, 10, 11).Return(items[:1], nil)
+				repo.EXPECT().UnreadCount(gomock.Any(), newUser.ID).Return(int64(11), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantCount:  1,
+		},
+		{
+			name:       "Некорректный фильтр",
+			query:      "?unread=maybe",
+			token:      jwtToken,
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Ошибка хранилища",
+			token: jwtToken,
+			setupMocks: func() {
+				repo.EXPECT().List(gomock.Any(), newUser.ID, false, 0, defaultPageLimit+1).Return(nil, notifications.ErrStorage)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "Без авторизации",
+			setupMocks: func() {},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+
+			req := httptest.NewRequest("GET", "/api/notifications"+tc.query, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantStatus == http.StatusOK {
+				page := &notificationPage{}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page))
+				assert.Len(t, page.Items, tc.wantCount)
+				assert.Equal(t, tc.wantHasMore, page.HasMore)
+				assert.NotZero(t, page.Unread)
+			}
+		})
+	}
+}
+
+func TestMarkNotificationsRead(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := notifications.NewMockNotificationRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{
+		Logger:        zap.NewNop().Sugar(),
+		Sessions:      mockSessions,
+		Notifications: repo,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/notifications/read", service.MarkNotificationsRead).Methods("POST")
+
+	id := primitive.NewObjectID()
+
+	tests := []struct {
+		name       string
+		body       string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name: "Прочитать выбранные",
+			body: fmt.Sprintf(`{"ids":["%s"]}`, id.Hex()),
+			setupMocks: func() {
+				repo.EXPECT().MarkRead(gomock.Any(), newUser.ID, []primitive.ObjectID{id}).Return(int64(1), nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Пустое тело - прочитать все",
+			setupMocks: func() {
+				repo.EXPECT().MarkRead(gomock.Any(), newUser.ID, []primitive.ObjectID{}).Return(int64(4), nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Некорректный id",
+			body:       `{"ids":["nope"]}`,
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Сломанный JSON",
+			body:       `{"ids":`,
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+			mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+
+			req := httptest.NewRequest("POST", "/api/notifications/read", strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+jwtToken)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestNotificationEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := posts.NewMockPostRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	publisher := &recordingPublisher{}
+	service := &PostsHandler{
+		PostsRepo: st,
+		Logger:    zap.NewNop().Sugar(),
+		Sessions:  mockSessions,
+		Notifier:  publisher,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/post/{POST_ID}", service.MakeComment).Methods("POST")
+	router.HandleFunc("/api/post/{POST_ID}/vote", service.VotePost).Methods("POST")
+
+	postID := primitive.NewObjectID()
+	comment := &posts.Comment{ID: primitive.NewObjectID(), Body: "@ivan привет", Author: &user.User{ID: newUser.ID, Username: newUser.Username}}
+
+	tests := []struct {
+		name       string
+		route      string
+		body       string
+		setupMocks func()
+		wantKind   notifications.EventKind
+	}{
+		{
+			name:  "Комментарий порождает событие с новым комментарием",
+			route: "/api/post/" + postID.Hex(),
+			body:  `{"comment":"@ivan привет"}`,
+			setupMocks: func() {
+				st.EXPECT().MakeComment(gomock.Any(), postID, primitive.NilObjectID, comment.Body, newUser.Username, newUser.ID).
+					Return(&posts.Post{ID: postID, Comments: []*posts.Comment{{ID: primitive.NewObjectID()}, comment}}, nil)
+			},
+			wantKind: notifications.EventComment,
+		},
+		{
+			name:  "Голос, переваливший за рубеж",
+			route: "/api/post/" + postID.Hex() + "/vote",
+			body:  `{"vote":1}`,
+			setupMocks: func() {
+				st.EXPECT().VotePost(gomock.Any(), postID, newUser.ID, 1).Return(&posts.Post{ID: postID, Score: 10}, nil)
+			},
+			wantKind: notifications.EventVote,
+		},
+		{
+			name:  "Голос вдали от рубежа не создаёт событий",
+			route: "/api/post/" + postID.Hex() + "/vote",
+			body:  `{"vote":1}`,
+			setupMocks: func() {
+				st.EXPECT().VotePost(gomock.Any(), postID, newUser.ID, 1).Return(&posts.Post{ID: postID, Score: 7}, nil)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			publisher.events = nil
+			tc.setupMocks()
+			mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+
+			req := httptest.NewRequest("POST", tc.route, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+jwtToken)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Less(t, w.Code, 300, w.Body.String())
+			if tc.wantKind == 0 {
+				assert.Empty(t, publisher.events)
+				return
+			}
+			if assert.Len(t, publisher.events, 1) {
+				ev := publisher.events[0]
+				assert.Equal(t, tc.wantKind, ev.Kind)
+				assert.Equal(t, user.User{ID: newUser.ID, Username: newUser.Username}, ev.Actor)
+				if tc.wantKind == notifications.EventComment {
+					assert.Equal(t, comment, ev.Comment)
+				}
+			}
+		})
+	}
+}
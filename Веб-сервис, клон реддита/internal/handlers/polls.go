@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"redditclone/internal/posts"
+	"redditclone/internal/ratelimit"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// VotePoll - голос в опросе: POST /api/post/{POST_ID}/poll с телом {"option": N}.
+func (h *PostsHandler) VotePoll(w http.ResponseWriter, r *http.Request) {
+	objID := mux.Vars(r)["POST_ID"]
+	logger := requestLogger(r, h.Logger).With("post_id", objID)
+
+	postID, err := primitive.ObjectIDFromHex(objID)
+	if err != nil {
+		writeError(w, r, logger, ErrInvalidID)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, logger, ErrReading)
+		return
+	}
+	r.Body.Close()
+
+	fd := &posts.PollVoteForm{}
+	if err = json.Unmarshal(body, fd); err != nil {
+		writeError(w, r, logger, ErrBadRequest)
+		return
+	}
+	if errors := dataValidation(fd); errors != nil {
+		writeError(w, r, logger, validationError(errors))
+		return
+	}
+
+	userID, _, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID, "option", *fd.Option)
+
+	if !h.allowRequest(w, r, logger, ratelimit.RouteVote, userID) {
+		return
+	}
+
+	post, err := h.PostsRepo.VotePoll(r.Context(), postID, userID, *fd.Option)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	logger.Infow("poll voted")
+	writeJSON(w, logger, http.StatusOK, posts.ShowPoll(post, userID, time.Now().UTC()))
+}
+
+// showPolls готовит опросы в постах для вызывающего. Сессию проверяем, только если опросы есть.
+func (h *PostsHandler) showPolls(r *http.Request, list []*posts.Post) {
+	var userID int64
+	checked := false
+	now := time.Now().UTC()
+	for i, post := range list {
+		if post.Poll == nil {
+			continue
+		}
+		if !checked {
+			userID, _ = h.callerID(r)
+			checked = true
+		}
+		list[i] = posts.ShowPoll(post, userID, now)
+	}
+}
+
+func (h *PostsHandler) showPoll(r *http.Request, post *posts.Post) *posts.Post {
+	list := []*posts.Post{post}
+	h.showPolls(r, list)
+	return list[0]
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func pollPost(postID primitive.ObjectID, voters ...posts.PollVoter) *posts.Post {
+	return &posts.Post{ID: postID, Type: posts.TypePoll, Poll: &posts.Poll{
+		Options: []*posts.PollOption{{ID: 0, Text: "да", Votes: len(voters)}, {ID: 1, Text: "нет"}},
+		Voters:  voters,
+	}}
+}
+
+func TestVotePoll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := posts.NewMockPostRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{PostsRepo: st, Logger: zap.NewNop().Sugar(), Sessions: mockSessions}
+	router := mux.NewRouter()
+	router.HandleFunc("/api/post/{POST_ID}/poll", service.VotePoll).Methods("POST")
+
+	postID := primitive.NewObjectID()
+	route := fmt.Sprintf("/api/post/%s/poll", postID.Hex())
+
+	tests := []struct {
+		name        string
+		route       string
+		body        string
+		token       string
+		setupMocks  func()
+		wantStatus  int
+		wantResults bool
+	}{
+		{
+			name:  "Голос засчитан, итоги открыты",
+			route: route,
+			body:  `{"option":0}`,
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().VotePoll(gomock.Any(), postID, newUser.ID, 0).
+					Return(pollPost(postID, posts.PollVoter{UserID: newUser.ID, Option: 0}), nil)
+			},
+			wantStatus:  http.StatusOK,
+			wantResults: true,
+		},
+		{
+			name:  "Повторный голос",
+			route: route,
+			body:  `{"option":1}`,
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().VotePoll(gomock.Any(), postID, newUser.ID, 1).Return(nil, posts.ErrAlreadyVoted)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:  "Опрос закрыт",
+			route: route,
+			body:  `{"option":1}`,
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().VotePoll(gomock.Any(), postID, newUser.ID, 1).Return(nil, posts.ErrPollClosed)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "Без варианта",
+			route:      route,
+			body:       `{}`,
+			token:      jwtToken,
+			setupMocks: func() {},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Без авторизации",
+			route:      route,
+			body:       `{"option":0}`,
+			setupMocks: func() {},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Неверный ID",
+			route:      "/api/post/123/poll",
+			body:       `{"option":0}`,
+			token:      jwtToken,
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+
+			req := httptest.NewRequest("POST", tc.route, strings.NewReader(tc.body))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantResults {
+				var post posts.Post
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &post))
+				require.NotNil(t, post.Poll.Results)
+				assert.Equal(t, []int{1, 0}, post.Poll.Results.Votes)
+				assert.Equal(t, 0, *post.Poll.Voted)
+				assert.NotContains(t, w.Body.String(), "voters")
+			}
+		})
+	}
+}
+
+func TestGetPostPoll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := posts.NewMockPostRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{PostsRepo: st, Logger: zap.NewNop().Sugar(), Sessions: mockSessions}
+	router := mux.NewRouter()
+	router.HandleFunc("/api/post/{POST_ID}", service.GetPost).Methods("GET")
+
+	postID := primitive.NewObjectID()
+	other := posts.PollVoter{UserID: newUser.ID + 1, Option: 0}
+
+	tests := []struct {
+		name        string
+		post        *posts.Post
+		token       string
+		wantResults bool
+	}{
+		{name: "Аноним не видит итоги", post: pollPost(postID, other)},
+		{name: "Не голосовавший не видит итоги", post: pollPost(postID, other), token: jwtToken},
+		{name: "Голосовавший видит итоги", post: pollPost(postID, other, posts.PollVoter{UserID: newUser.ID, Option: 0}), token: jwtToken, wantResults: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			st.EXPECT().GetPost(gomock.Any(), postID).Return(tc.post, nil)
+			req := httptest.NewRequest("GET", "/api/post/"+postID.Hex(), nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.wantResults, strings.Contains(w.Body.String(), `"results"`), w.Body.String())
+		})
+	}
+}
@@ -7,14 +7,49 @@ import (
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"redditclone/internal/collections"
+	"redditclone/internal/media"
+	"redditclone/internal/moderation"
+	"redditclone/internal/notifications"
 	"redditclone/internal/posts"
+	"redditclone/internal/ratelimit"
+	"redditclone/internal/realtime"
 	"redditclone/internal/sessions"
+	"redditclone/internal/subscriptions"
+	"redditclone/internal/user"
+	"redditclone/internal/views"
+	"time"
 )
 
 type PostsHandler struct {
 	PostsRepo posts.PostRepo
 	Logger    *zap.SugaredLogger
 	Sessions  sessions.SessionManagerInterface
+	// Users нужен для выгрузки и удаления аккаунта.
+	Users user.UserRepo
+	// Limiter и Duplicates необязательны, без них запись не ограничивается.
+	Limiter    *ratelimit.Limiter
+	Duplicates *ratelimit.DuplicateDetector
+	// TrustProxy разрешает брать IP клиента из X-Forwarded-For.
+	TrustProxy bool
+	// Views считает уникальные просмотры, без него просмотры не учитываются.
+	Views *views.Recorder
+	// Collections хранит сохранённое и скрытое пользователями.
+	Collections   collections.CollectionRepo
+	Subscriptions subscriptions.SubscriptionRepo
+	// Notifier получает события для уведомлений, без него уведомления не создаются.
+	Notifier      notifications.Publisher
+	Notifications notifications.NotificationRepo
+	// Realtime рассылает изменения постов открытым потокам событий.
+	Realtime realtime.Hub
+	// Moderators - кто может снимать, закрывать и закреплять посты. Жалобы и журнал действий - в Reports и AuditLog.
+	Moderators moderation.Moderators
+	Reports    moderation.ReportRepo
+	AuditLog   moderation.AuditLog
+	// Media принимает картинки для постов типа image, без него такие посты не создаются.
+	Media *media.Uploader
+	// DuplicateLinkWindow - за какой срок искать прошлые публикации ссылки, 0 - не искать.
+	DuplicateLinkWindow time.Duration
 }
 
 type PostTextForm struct {
@@ -31,355 +66,384 @@ type PostURLForm struct {
 	Type     string `json:"type"  validate:"required"`
 }
 
-func (h *PostsHandler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
-
-	h.Logger.Infoln("Start getting posts")
-
-	allPosts, err := h.PostsRepo.GetPosts(posts.FilterAll())
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+// visiblePosts убирает из списка посты, которые читателю не показывают: снятые модераторами и скрытые им самим.
+func (h *PostsHandler) visiblePosts(r *http.Request, logger *zap.SugaredLogger, list []*posts.Post) []*posts.Post {
+	return posts.Select(list, h.withoutHidden(r, logger, h.withoutRemoved(r, posts.FilterAll())))
+}
 
-	h.Logger.Infoln("Posts received")
+func (h *PostsHandler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger)
 
-	resp, err := json.Marshal(allPosts)
+	allPosts, err := h.PostsRepo.ListPosts(r.Context(), posts.Scope{})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, err)
 		return
 	}
+	allPosts = h.visiblePosts(r, logger, allPosts)
+	posts.StickyFirst(allPosts)
+	h.showPolls(r, allPosts)
 
-	h.Logger.Infoln("Posts marshaled")
-
-	_, err = w.Write(resp)
-	if err != nil {
-		h.Logger.Errorln(err.Error())
-	}
+	logger.Infow("posts received", "count", len(allPosts))
+	writeConditionalJSON(w, r, logger, allPosts)
 }
 
 func (h *PostsHandler) GetCategoryPosts(w http.ResponseWriter, r *http.Request) {
-
-	h.Logger.Infoln("Start getting category posts")
-
 	category := mux.Vars(r)["CATEGORY_NAME"]
-	catPosts, err := h.PostsRepo.GetPosts(posts.FilterByCategory(category))
+	logger := requestLogger(r, h.Logger).With("category", category)
 
+	catPosts, err := h.PostsRepo.ListPosts(r.Context(), posts.Scope{Category: category})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, err)
 		return
 	}
+	catPosts = h.visiblePosts(r, logger, catPosts)
+	posts.StickyFirst(catPosts)
+	h.showPolls(r, catPosts)
 
-	h.Logger.Infoln("Posts received")
-
-	resp, err := json.Marshal(catPosts)
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	h.Logger.Infoln("Posts marshaled")
-
-	_, err = w.Write(resp)
-	if err != nil {
-		h.Logger.Errorln(err.Error())
-	}
+	logger.Infow("category posts received", "count", len(catPosts))
+	writeConditionalJSON(w, r, logger, catPosts)
 }
 
 func (h *PostsHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
-	h.Logger.Infoln("Start getting user posts")
-
 	user := mux.Vars(r)["USER_LOGIN"]
+	logger := requestLogger(r, h.Logger).With("user_login", user)
 
-	userPosts, err := h.PostsRepo.GetPosts(posts.FilterByUser(user))
-
+	userPosts, err := h.PostsRepo.ListPosts(r.Context(), posts.Scope{Author: user})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, err)
 		return
 	}
+	userPosts = h.visiblePosts(r, logger, userPosts)
 
-	h.Logger.Infoln("Posts received")
+	h.showPolls(r, userPosts)
 
-	resp, err := json.Marshal(userPosts)
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	h.Logger.Infoln("Posts marshaled")
-
-	_, err = w.Write(resp)
-	if err != nil {
-		h.Logger.Errorln(err.Error())
-	}
+	logger.Infow("user posts received", "count", len(userPosts))
+	writeConditionalJSON(w, r, logger, userPosts)
 }
 
 func (h *PostsHandler) GetPost(w http.ResponseWriter, r *http.Request) {
-	h.Logger.Infoln("Start getting post")
-
 	postID := mux.Vars(r)["POST_ID"]
+	logger := requestLogger(r, h.Logger).With("post_id", postID)
+
 	objID, err := primitive.ObjectIDFromHex(postID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, ErrInvalidID)
 		return
 	}
 
-	post, err := h.PostsRepo.GetPost(objID)
+	post, err := h.PostsRepo.GetPost(r.Context(), objID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, logger, err)
+		return
+	}
+	if err = h.moderatedView(r, post); err != nil {
+		writeError(w, r, logger, err)
 		return
 	}
+	h.recordView(r, logger, post)
+	h.hideComments(r, logger, post)
+	post = h.showPoll(r, post)
 
-	h.Logger.Infoln("Post received")
+	logger.Infow("post received")
+	writeConditionalJSON(w, r, logger, post)
+}
 
-	resp, err := json.Marshal(post)
+// VotePost выставляет голос из тела запроса {"vote": -1|0|1}.
+func (h *PostsHandler) VotePost(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger).With("post_id", mux.Vars(r)["POST_ID"])
 
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, ErrReading)
 		return
 	}
+	r.Body.Close()
 
-	h.Logger.Infoln("Post marshaled")
+	fd := &posts.VoteForm{}
+	if err = json.Unmarshal(body, fd); err != nil {
+		writeError(w, r, logger, ErrBadRequest)
+		return
+	}
 
-	_, err = w.Write(resp)
-	if err != nil {
-		h.Logger.Errorln(err.Error())
+	if errors := dataValidation(fd); errors != nil {
+		writeError(w, r, logger, validationError(errors))
+		return
+	}
+	if !posts.ValidVote(*fd.Vote) {
+		writeError(w, r, logger, posts.ErrBadVote)
+		return
 	}
+
+	votePostHandler(w, r, h, *fd.Vote)
 }
 
+// UpVotePost, DownVotePost и UnVotePost - старые маршруты без тела, оставлены для совместимости.
 func (h *PostsHandler) UpVotePost(w http.ResponseWriter, r *http.Request) {
 	votePostHandler(w, r, h, 1)
-
-	h.Logger.Infoln("Posts upvoted")
 }
 
 func (h *PostsHandler) DownVotePost(w http.ResponseWriter, r *http.Request) {
 	votePostHandler(w, r, h, -1)
-
-	h.Logger.Infoln("Posts downvoted")
 }
 
 func (h *PostsHandler) UnVotePost(w http.ResponseWriter, r *http.Request) {
 	votePostHandler(w, r, h, 0)
-
-	h.Logger.Infoln("Posts unvoted")
 }
 
 func (h *PostsHandler) MakePost(w http.ResponseWriter, r *http.Request) {
-	h.Logger.Infoln("Start making post")
+	logger := requestLogger(r, h.Logger)
 
-	body, err := io.ReadAll(r.Body)
+	fd, file, err := h.readPostForm(w, r)
 	if err != nil {
-		http.Error(w, ErrReading, http.StatusBadRequest)
+		writeError(w, r, logger, err)
 		return
 	}
-	r.Body.Close()
-
-	fd := &posts.PostForm{}
-	if err = json.Unmarshal(body, fd); err != nil {
-		http.Error(w, ErrBadRequest, http.StatusBadRequest)
-		return
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
 	}
 
-	h.Logger.Infoln("User data unmarshalled")
-
 	// Валидация предоставленных данных
-	errors := dataValidation(fd)
-	if errors != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		err = json.NewEncoder(w).Encode(map[string][]map[string]string{errConst: errors})
-		if err != nil {
-			h.Logger.Errorln(err.Error())
-		}
+	if errors := dataValidation(fd); errors != nil {
+		writeError(w, r, logger, validationError(errors))
 		return
 	}
 
-	h.Logger.Infoln("User data validated")
-
 	userID, username, err := authUser(r, h)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, r, logger, err)
 		return
 	}
+	logger = logger.With("user_id", userID)
 
-	h.Logger.Infoln("User authenticated")
-
-	post, err := h.PostsRepo.MakePost(fd, username, userID)
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if !h.allowRequest(w, r, logger, ratelimit.RoutePost, userID) {
 		return
 	}
 
-	h.Logger.Infoln("Post made")
-
-	resp, err := json.Marshal(post)
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if candidates := h.duplicateLinks(r, logger, fd); len(candidates) > 0 {
+		writeError(w, r, logger, alreadySubmitted(candidates))
 		return
 	}
 
-	h.Logger.Infoln("Post marshaled")
+	// Картинку обрабатываем только после авторизации и лимитов: это самая дорогая часть запроса.
+	if fd.Type == posts.TypeImage {
+		if fd.Image, err = h.uploadImage(r.Context(), file); err != nil {
+			writeError(w, r, logger, err)
+			return
+		}
+	}
 
-	w.WriteHeader(http.StatusCreated)
-	_, err = w.Write(resp)
+	post, err := h.PostsRepo.MakePost(r.Context(), fd, username, userID)
 	if err != nil {
-		h.Logger.Errorln(err.Error())
+		writeError(w, r, logger, err)
+		return
 	}
 
+	h.notify(notifications.Event{Kind: notifications.EventPost, Actor: user.User{ID: userID, Username: username}, Post: post})
+	h.broadcast(r, logger, realtime.EventPostCreated, post)
+
+	logger.Infow("post made", "post_id", post.ID.Hex(), "type", post.Type, "category", post.Category)
+	writeJSON(w, logger, http.StatusCreated, posts.ShowPoll(post, userID, time.Now().UTC()))
 }
 
 func (h *PostsHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
-	h.Logger.Infoln("Start deleting post")
-
 	objID := mux.Vars(r)["POST_ID"]
+	logger := requestLogger(r, h.Logger).With("post_id", objID)
+
 	postID, err := primitive.ObjectIDFromHex(objID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, ErrInvalidID)
 		return
 	}
 
 	userID, _, err := authUser(r, h)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, r, logger, err)
 		return
 	}
+	logger = logger.With("user_id", userID)
 
-	h.Logger.Infoln("User authenticated")
-
-	_, err = h.PostsRepo.DeletePost(postID, userID)
+	_, err = h.PostsRepo.DeletePost(r.Context(), postID, userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, logger, err)
 		return
 	}
 
-	h.Logger.Infoln("Post deleted")
-
-	_, err = w.Write([]byte(SuccessResponse))
-	if err != nil {
-		h.Logger.Errorln(err.Error())
-	}
-
+	logger.Infow("post deleted")
+	writeJSON(w, logger, http.StatusOK, SuccessResponse)
 }
 
 func (h *PostsHandler) MakeComment(w http.ResponseWriter, r *http.Request) {
-	h.Logger.Infoln("Start making comment")
-
 	objID := mux.Vars(r)["POST_ID"]
+	logger := requestLogger(r, h.Logger).With("post_id", objID)
+
 	postID, err := primitive.ObjectIDFromHex(objID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, logger, ErrInvalidID)
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, ErrReading, http.StatusBadRequest)
+		writeError(w, r, logger, ErrReading)
 		return
 	}
 	r.Body.Close()
 
 	fd := &posts.CommentForm{}
 	if err = json.Unmarshal(body, fd); err != nil {
-		http.Error(w, ErrBadRequest, http.StatusBadRequest)
+		writeError(w, r, logger, ErrBadRequest)
 		return
 	}
 
-	h.Logger.Infoln("User data unmarshalled")
-
 	// Валидация предоставленных данных
-	errors := dataValidation(fd)
-	if errors != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		err = json.NewEncoder(w).Encode(map[string][]map[string]string{errConst: errors})
-		if err != nil {
-			h.Logger.Errorln(err.Error())
-		}
+	if errors := dataValidation(fd); errors != nil {
+		writeError(w, r, logger, validationError(errors))
 		return
 	}
 
-	h.Logger.Infoln("User data validated")
+	parentID := primitive.NilObjectID
+	if fd.ParentID != "" {
+		if parentID, err = primitive.ObjectIDFromHex(fd.ParentID); err != nil {
+			writeError(w, r, logger, ErrInvalidID)
+			return
+		}
+		logger = logger.With("parent_id", fd.ParentID)
+	}
 
 	userID, username, err := authUser(r, h)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, r, logger, err)
 		return
 	}
+	logger = logger.With("user_id", userID)
 
-	h.Logger.Infoln("User authenticated")
-
-	post, err := h.PostsRepo.MakeComment(postID, fd.Body, username, userID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if !h.allowRequest(w, r, logger, ratelimit.RouteComment, userID) || !h.allowContent(w, r, logger, userID, fd.Body) {
 		return
 	}
 
-	h.Logger.Infoln("Comment made")
-
-	resp, err := json.Marshal(post)
-
+	post, err := h.comment(r, logger, postID, parentID, fd.Body, userID, username)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, err)
 		return
 	}
 
-	h.Logger.Infoln("Comment marshaled")
+	logger.Infow("comment made")
+	writeJSON(w, logger, http.StatusCreated, post)
+}
 
-	w.WriteHeader(http.StatusCreated)
-	_, err = w.Write(resp)
+// comment добавляет комментарий и рассылает о нём уведомления и события. Общая часть REST и GraphQL.
+func (h *PostsHandler) comment(r *http.Request, logger *zap.SugaredLogger, postID, parentID primitive.ObjectID, body string, userID int64, username string) (*posts.Post, error) {
+	post, err := h.PostsRepo.MakeComment(r.Context(), postID, parentID, body, username, userID)
 	if err != nil {
-		h.Logger.Errorln(err.Error())
+		return nil, err
 	}
 
+	// $push добавляет комментарий в конец, так что новый - последний.
+	if len(post.Comments) > 0 {
+		h.notify(notifications.Event{
+			Kind:    notifications.EventComment,
+			Actor:   user.User{ID: userID, Username: username},
+			Post:    post,
+			Comment: post.Comments[len(post.Comments)-1],
+		})
+	}
+	h.broadcast(r, logger, realtime.EventCommentAdded, post)
+	return post, nil
 }
 
 func (h *PostsHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
-	h.Logger.Infoln("Start deleting comment")
-
 	objID := mux.Vars(r)["POST_ID"]
+	commentID := mux.Vars(r)["COMMENT_ID"]
+	logger := requestLogger(r, h.Logger).With("post_id", objID, "comment_id", commentID)
+
 	postID, err := primitive.ObjectIDFromHex(objID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, ErrInvalidID)
 		return
 	}
 
-	commentID := mux.Vars(r)["COMMENT_ID"]
 	objectID, err := primitive.ObjectIDFromHex(commentID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, ErrInvalidID)
 		return
 	}
 
 	userID, _, err := authUser(r, h)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, r, logger, err)
 		return
 	}
+	logger = logger.With("user_id", userID)
 
-	h.Logger.Infoln("User authenticated")
+	post, err := h.PostsRepo.DeleteComment(r.Context(), postID, objectID, userID)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+
+	logger.Infow("comment deleted")
+	writeJSON(w, logger, http.StatusOK, post)
+}
+
+// RestorePost отменяет удаление поста автором, пока пост не стёрт окончательно.
+func (h *PostsHandler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	objID := mux.Vars(r)["POST_ID"]
+	logger := requestLogger(r, h.Logger).With("post_id", objID)
+
+	postID, err := primitive.ObjectIDFromHex(objID)
+	if err != nil {
+		writeError(w, r, logger, ErrInvalidID)
+		return
+	}
+
+	userID, _, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
 
-	post, err := h.PostsRepo.DeleteComment(postID, objectID, userID)
+	post, err := h.PostsRepo.RestorePost(r.Context(), postID, userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, logger, err)
 		return
 	}
 
-	h.Logger.Infoln("Comment deleted")
+	logger.Infow("post restored")
+	writeJSON(w, logger, http.StatusOK, post)
+}
 
-	resp, err := json.Marshal(post)
+// RestoreComment отменяет удаление комментария автором.
+func (h *PostsHandler) RestoreComment(w http.ResponseWriter, r *http.Request) {
+	objID := mux.Vars(r)["POST_ID"]
+	commentID := mux.Vars(r)["COMMENT_ID"]
+	logger := requestLogger(r, h.Logger).With("post_id", objID, "comment_id", commentID)
 
+	postID, err := primitive.ObjectIDFromHex(objID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, ErrInvalidID)
 		return
 	}
 
-	h.Logger.Infoln("Post marshaled")
+	objectID, err := primitive.ObjectIDFromHex(commentID)
+	if err != nil {
+		writeError(w, r, logger, ErrInvalidID)
+		return
+	}
 
-	_, err = w.Write(resp)
+	userID, _, err := authUser(r, h)
 	if err != nil {
-		h.Logger.Errorln(err.Error())
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID)
+
+	post, err := h.PostsRepo.RestoreComment(r.Context(), postID, objectID, userID)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
 	}
 
+	logger.Infow("comment restored")
+	writeJSON(w, logger, http.StatusOK, post)
 }
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/golang/mock/gomock"
@@ -15,6 +16,7 @@ import (
 	"redditclone/internal/posts"
 	"redditclone/internal/sessions"
 	"redditclone/internal/user"
+	"redditclone/internal/views"
 	"strings"
 	"testing"
 	"time"
@@ -78,7 +80,7 @@ func TestPostsHandler(t *testing.T) {
 			route:  "/api/posts/",
 			method: "GET",
 			setupMocks: func() {
-				mockRepo.EXPECT().GetPosts(gomock.Any()).Return(resultPost, nil)
+				mockRepo.EXPECT().ListPosts(gomock.Any(), posts.Scope{}).Return(resultPost, nil)
 			},
 			wantStatus: http.StatusOK,
 			wantBody:   title,
@@ -88,7 +90,7 @@ func TestPostsHandler(t *testing.T) {
 			route:  "/api/posts/",
 			method: "GET",
 			setupMocks: func() {
-				mockRepo.EXPECT().GetPosts(gomock.Any()).Return(nil, fmt.Errorf("failed to fetch posts"))
+				mockRepo.EXPECT().ListPosts(gomock.Any(), posts.Scope{}).Return(nil, fmt.Errorf("failed to fetch posts"))
 			},
 			wantStatus: http.StatusInternalServerError,
 		},
@@ -97,7 +99,7 @@ func TestPostsHandler(t *testing.T) {
 			route:  "/api/posts/music",
 			method: "GET",
 			setupMocks: func() {
-				mockRepo.EXPECT().GetPosts(gomock.Any()).Return(resultPost, nil)
+				mockRepo.EXPECT().ListPosts(gomock.Any(), posts.Scope{Category: "music"}).Return(resultPost, nil)
 			},
 			wantStatus: http.StatusOK,
 			wantBody:   title,
@@ -107,7 +109,7 @@ func TestPostsHandler(t *testing.T) {
 			route:  "/api/posts/music",
 			method: "GET",
 			setupMocks: func() {
-				mockRepo.EXPECT().GetPosts(gomock.Any()).Return(nil, fmt.Errorf("failed to fetch posts"))
+				mockRepo.EXPECT().ListPosts(gomock.Any(), posts.Scope{Category: "music"}).Return(nil, fmt.Errorf("failed to fetch posts"))
 			},
 			wantStatus: http.StatusInternalServerError,
 		},
@@ -116,7 +118,7 @@ func TestPostsHandler(t *testing.T) {
 			route:  "/api/user/rvasily",
 			method: "GET",
 			setupMocks: func() {
-				mockRepo.EXPECT().GetPosts(gomock.Any()).Return(resultPost, nil)
+				mockRepo.EXPECT().ListPosts(gomock.Any(), posts.Scope{Author: "rvasily"}).Return(resultPost, nil)
 			},
 			wantStatus: http.StatusOK,
 			wantBody:   title,
@@ -126,7 +128,7 @@ func TestPostsHandler(t *testing.T) {
 			route:  "/api/user/rvasily",
 			method: "GET",
 			setupMocks: func() {
-				mockRepo.EXPECT().GetPosts(gomock.Any()).Return(nil, fmt.Errorf("failed to fetch posts"))
+				mockRepo.EXPECT().ListPosts(gomock.Any(), posts.Scope{Author: "rvasily"}).Return(nil, fmt.Errorf("failed to fetch posts"))
 			},
 			wantStatus: http.StatusInternalServerError,
 		},
@@ -155,6 +157,59 @@ func TestPostsHandler(t *testing.T) {
 	}
 }
 
+func TestConditionalGet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	st := posts.NewMockPostRepo(ctrl)
+	service := &PostsHandler{PostsRepo: st, Logger: zap.NewNop().Sugar()}
+	router := mux.NewRouter()
+	router.HandleFunc("/api/posts/", service.GetAllPosts)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/posts/", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	st.EXPECT().ListPosts(gomock.Any(), posts.Scope{}).Return(resultPost, nil).Times(4)
+	first := get("")
+	etag := first.Header().Get("ETag")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.True(t, strings.HasPrefix(etag, `W/"`), etag)
+	assert.Equal(t, "private, no-cache", first.Header().Get("Cache-Control"))
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{name: "Та же версия", ifNoneMatch: etag, wantStatus: http.StatusNotModified},
+		{name: "Версия в списке", ifNoneMatch: `"other", ` + strings.TrimPrefix(etag, "W/"), wantStatus: http.StatusNotModified},
+		{name: "Другая версия", ifNoneMatch: `W/"other"`, wantStatus: http.StatusOK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := get(tc.ifNoneMatch)
+			assert.Equal(t, tc.wantStatus, w.Code)
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			if tc.wantStatus == http.StatusNotModified {
+				assert.Empty(t, w.Body.Bytes())
+			}
+		})
+	}
+
+	// Пост изменился - меняется и ETag.
+	changed := *resultPost[0]
+	changed.Score++
+	st.EXPECT().ListPosts(gomock.Any(), posts.Scope{}).Return([]*posts.Post{&changed}, nil)
+	w := get(etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
 func TestGetPost(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -185,7 +240,7 @@ func TestGetPost(t *testing.T) {
 			name:   "Успешное получение поста",
 			postID: resultPost[0].ID.Hex(),
 			setupMocks: func() {
-				st.EXPECT().GetPost(resultPost[0].ID).Return(resultPost[0], nil)
+				st.EXPECT().GetPost(gomock.Any(), resultPost[0].ID).Return(resultPost[0], nil)
 			},
 			expectStatus: http.StatusOK,
 			expectBody:   true,
@@ -194,16 +249,24 @@ func TestGetPost(t *testing.T) {
 			name:         "Ошибка при получении поста - неверный формат ID",
 			postID:       "invalid-object-id",
 			setupMocks:   func() {},
-			expectStatus: http.StatusInternalServerError,
+			expectStatus: http.StatusBadRequest,
 		},
 		{
 			name:   "Ошибка при получении поста - пост не найден",
 			postID: resultPost[0].ID.Hex(),
 			setupMocks: func() {
-				st.EXPECT().GetPost(gomock.Any()).Return(nil, fmt.Errorf("failed to fetch posts"))
+				st.EXPECT().GetPost(gomock.Any(), gomock.Any()).Return(nil, posts.ErrPostNotFound)
 			},
 			expectStatus: http.StatusNotFound,
 		},
+		{
+			name:   "Ошибка при получении поста - ошибка хранилища",
+			postID: resultPost[0].ID.Hex(),
+			setupMocks: func() {
+				st.EXPECT().GetPost(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("failed to fetch posts"))
+			},
+			expectStatus: http.StatusInternalServerError,
+		},
 	}
 
 	for _, tc := range tests {
@@ -233,6 +296,59 @@ func TestGetPost(t *testing.T) {
 	}
 }
 
+func TestGetPostViews(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := posts.NewMockPostRepo(ctrl)
+	service := &PostsHandler{
+		PostsRepo: st,
+		Logger:    zap.NewNop().Sugar(),
+		Views:     views.NewRecorder(views.NewMemoryDeduper(time.Hour), st, zap.NewNop().Sugar()),
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/post/{POST_ID}", service.GetPost)
+
+	objID := primitive.NewObjectID()
+	st.EXPECT().GetPost(gomock.Any(), objID).DoAndReturn(func(_ context.Context, id primitive.ObjectID) (*posts.Post, error) {
+		return &posts.Post{ID: id, Views: 10}, nil
+	}).AnyTimes()
+
+	tests := []struct {
+		name      string
+		token     string
+		userAgent string
+		wantViews int
+	}{
+		{name: "Первый просмотр анонима", userAgent: "firefox", wantViews: 11},
+		{name: "Повторный просмотр анонима не считается", userAgent: "firefox", wantViews: 11},
+		{name: "Другой браузер с того же IP считается", userAgent: "chrome", wantViews: 12},
+		{name: "Просмотр пользователя", token: jwtToken, userAgent: "firefox", wantViews: 13},
+		{name: "Повторный просмотр пользователя не считается", token: jwtToken, userAgent: "chrome", wantViews: 13},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/post/"+objID.Hex(), nil)
+			req.Header.Set("User-Agent", tc.userAgent)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			fd := &posts.Post{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), fd))
+			assert.Equal(t, tc.wantViews, fd.Views)
+		})
+	}
+
+	st.EXPECT().AddViews(gomock.Any(), map[primitive.ObjectID]int{objID: 3}).Return(nil)
+	assert.NoError(t, service.Views.Flush(context.Background()))
+}
+
 func TestVotePost(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -282,28 +398,24 @@ func TestVotePost(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			// Setup mock expectations
-			if tc.vote == 0 {
-				st.EXPECT().UnVotePost(objID, newUser.ID).Return(&posts.Post{
-					ID:    objID,
-					Score: tc.score,
-					Votes: []*posts.Vote{},
-				}, nil)
-			} else {
-				st.EXPECT().VotePost(objID, newUser.ID, tc.vote).Return(&posts.Post{
-					ID:    objID,
-					Score: tc.score,
-					Votes: []*posts.Vote{{UserID: newUser.ID, Vote: tc.vote}},
-				}, nil)
+			votes := []*posts.Vote{}
+			if tc.vote != 0 {
+				votes = append(votes, &posts.Vote{UserID: newUser.ID, Vote: tc.vote})
 			}
-			mockSessions.EXPECT().Check(gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"}).Times(1)
+			st.EXPECT().VotePost(gomock.Any(), objID, newUser.ID, tc.vote).Return(&posts.Post{
+				ID:    objID,
+				Score: tc.score,
+				Votes: votes,
+			}, nil)
+			mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"}).Times(1)
 
 			// Setup the HTTP request
 			router := mux.NewRouter()
-			router.HandleFunc("/api/post/{POST_ID}/upvote", service.UpVotePost)
-			router.HandleFunc("/api/post/{POST_ID}/downvote", service.DownVotePost)
-			router.HandleFunc("/api/post/{POST_ID}/unvote", service.UnVotePost)
+			router.HandleFunc("/api/post/{POST_ID}/upvote", service.UpVotePost).Methods("POST")
+			router.HandleFunc("/api/post/{POST_ID}/downvote", service.DownVotePost).Methods("POST")
+			router.HandleFunc("/api/post/{POST_ID}/unvote", service.UnVotePost).Methods("POST")
 
-			req := httptest.NewRequest("GET", tc.route, nil)
+			req := httptest.NewRequest("POST", tc.route, nil)
 			tokenString := fmt.Sprintf("Bearer %s", jwtToken)
 			req.Header.Set("Authorization", tokenString)
 			w := httptest.NewRecorder()
@@ -334,6 +446,101 @@ func TestVotePost(t *testing.T) {
 	}
 }
 
+func TestVotePostBody(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := posts.NewMockPostRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{
+		PostsRepo: st,
+		Logger:    zap.NewNop().Sugar(),
+		Sessions:  mockSessions,
+	}
+
+	objID := primitive.NewObjectID()
+	route := fmt.Sprintf("/api/post/%s/vote", objID.Hex())
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		vote       int
+		repoCalled bool
+		repoErr    error
+		wantStatus int
+	}{
+		{
+			name:       "Проверка на голос через PUT",
+			method:     "PUT",
+			body:       `{"vote": -1}`,
+			vote:       -1,
+			repoCalled: true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Проверка на снятие голоса через POST",
+			method:     "POST",
+			body:       `{"vote": 0}`,
+			vote:       0,
+			repoCalled: true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Проверка на голос за несуществующий пост",
+			method:     "PUT",
+			body:       `{"vote": 1}`,
+			vote:       1,
+			repoCalled: true,
+			repoErr:    posts.ErrPostNotFound,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Проверка на отсутствие поля vote",
+			method:     "PUT",
+			body:       `{}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Проверка на недопустимое значение голоса",
+			method:     "PUT",
+			body:       `{"vote": 5}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Проверка на невалидный JSON",
+			method:     "PUT",
+			body:       `{"vote":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Проверка, что GET не голосует",
+			method:     "GET",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.repoCalled {
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+				st.EXPECT().VotePost(gomock.Any(), objID, newUser.ID, tc.vote).Return(&posts.Post{ID: objID, Score: tc.vote}, tc.repoErr)
+			}
+
+			router := mux.NewRouter()
+			router.HandleFunc("/api/post/{POST_ID}/vote", service.VotePost).Methods("POST", "PUT")
+
+			req := httptest.NewRequest(tc.method, route, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", jwtToken))
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
 func TestMakePost(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -366,8 +573,8 @@ func TestMakePost(t *testing.T) {
 		{
 			name: "Проверка на успешное создание поста",
 			setupMocks: func(req *http.Request) {
-				st.EXPECT().MakePost(gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil)
-				mockSessions.EXPECT().Check(gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
+				st.EXPECT().MakePost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
 			},
 			postData: map[string]string{
 				"category": post.Category,
@@ -381,7 +588,7 @@ func TestMakePost(t *testing.T) {
 		{
 			name: "Проверка на обработку ошибки при отсутствии сессии",
 			setupMocks: func(req *http.Request) {
-				mockSessions.EXPECT().Check(gomock.Any()).Return(nil)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(nil)
 			},
 			postData: map[string]string{
 				"category": post.Category,
@@ -394,8 +601,8 @@ func TestMakePost(t *testing.T) {
 		{
 			name: "Проверка на обработку ошибки при создании поста",
 			setupMocks: func(req *http.Request) {
-				st.EXPECT().MakePost(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("db error"))
-				mockSessions.EXPECT().Check(gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
+				st.EXPECT().MakePost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("db error"))
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
 			},
 			postData: map[string]string{
 				"category": post.Category,
@@ -503,8 +710,8 @@ func TestDeletePost(t *testing.T) {
 		{
 			name: "Проверка на успешное удаление поста",
 			setupMocks: func(objID primitive.ObjectID) {
-				st.EXPECT().DeletePost(objID, int64(1)).Return(true, nil)
-				mockSessions.EXPECT().Check(gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
+				st.EXPECT().DeletePost(gomock.Any(), objID, int64(1)).Return(true, nil)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
 			},
 			requestURL:     "/api/post/%s",
 			expectedStatus: http.StatusOK,
@@ -514,7 +721,7 @@ func TestDeletePost(t *testing.T) {
 		{
 			name: "Проверка на обработку ошибки при отсутствии сессии",
 			setupMocks: func(objID primitive.ObjectID) {
-				mockSessions.EXPECT().Check(gomock.Any()).Return(nil)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(nil)
 			},
 			requestURL:     "/api/post/%s",
 			expectedStatus: http.StatusUnauthorized,
@@ -523,8 +730,8 @@ func TestDeletePost(t *testing.T) {
 		{
 			name: "Проверка обработки ошибки при удалении поста",
 			setupMocks: func(objID primitive.ObjectID) {
-				st.EXPECT().DeletePost(objID, int64(1)).Return(false, fmt.Errorf("delete error"))
-				mockSessions.EXPECT().Check(gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
+				st.EXPECT().DeletePost(gomock.Any(), objID, int64(1)).Return(false, posts.ErrPostNotFound)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
 			},
 			requestURL:     "/api/post/%s",
 			expectedStatus: http.StatusNotFound,
@@ -627,8 +834,8 @@ func TestMakeComment(t *testing.T) {
 		{
 			name: "Проверка на успешное создание коммента",
 			setupMocks: func(req *http.Request) {
-				st.EXPECT().MakeComment(objID, post.Comments[0].Body, newUser.Username, newUser.ID).Return(&post, nil)
-				mockSessions.EXPECT().Check(gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username,
+				st.EXPECT().MakeComment(gomock.Any(), objID, primitive.NilObjectID, post.Comments[0].Body, newUser.Username, newUser.ID).Return(&post, nil)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username,
 					Useragent: "some-user-agent"})
 			},
 			postData: map[string]string{
@@ -638,10 +845,35 @@ func TestMakeComment(t *testing.T) {
 			expectBody: true,
 			uri:        fmt.Sprintf("/api/post/%s", objID.Hex()),
 		},
+		{
+			name: "Ответ на комментарий передаёт родителя в репозиторий",
+			setupMocks: func(req *http.Request) {
+				st.EXPECT().MakeComment(gomock.Any(), objID, post.Comments[0].ID, "reply", newUser.Username, newUser.ID).Return(&post, nil)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username,
+					Useragent: "some-user-agent"})
+			},
+			postData: map[string]string{
+				"comment": "reply",
+				"parent":  post.Comments[0].ID.Hex(),
+			},
+			wantStatus: http.StatusCreated,
+			expectBody: true,
+			uri:        fmt.Sprintf("/api/post/%s", objID.Hex()),
+		},
+		{
+			name:       "Некорректный id родительского комментария",
+			setupMocks: func(req *http.Request) {},
+			postData: map[string]string{
+				"comment": "reply",
+				"parent":  "not-an-id",
+			},
+			wantStatus: http.StatusBadRequest,
+			uri:        fmt.Sprintf("/api/post/%s", objID.Hex()),
+		},
 		{
 			name: "Проверка на обработку ошибки при отсутствии сессии",
 			setupMocks: func(req *http.Request) {
-				mockSessions.EXPECT().Check(gomock.Any()).Return(nil)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(nil)
 			},
 			postData: map[string]string{
 				"comment": post.Comments[0].Body,
@@ -652,8 +884,8 @@ func TestMakeComment(t *testing.T) {
 		{
 			name: "Проверка на обработку ошибки при создании коммента",
 			setupMocks: func(req *http.Request) {
-				st.EXPECT().MakeComment(objID, post.Comments[0].Body, newUser.Username, newUser.ID).Return(nil, fmt.Errorf("db error"))
-				mockSessions.EXPECT().Check(gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username,
+				st.EXPECT().MakeComment(gomock.Any(), objID, primitive.NilObjectID, post.Comments[0].Body, newUser.Username, newUser.ID).Return(nil, fmt.Errorf("db error"))
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username,
 					Useragent: "some-user-agent"})
 			},
 			postData: map[string]string{
@@ -789,8 +1021,8 @@ func TestDeleteComment(t *testing.T) {
 		{
 			name: "Проверка на успешное удаление коммента",
 			setupMocks: func(objID primitive.ObjectID) {
-				st.EXPECT().DeleteComment(objID, commentID, int64(1)).Return(&post, nil)
-				mockSessions.EXPECT().Check(gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
+				st.EXPECT().DeleteComment(gomock.Any(), objID, commentID, int64(1)).Return(&post, nil)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
 			},
 			requestURL:     fmt.Sprintf("/api/post/%s/%s", objID.Hex(), commentID.Hex()),
 			expectedStatus: http.StatusOK,
@@ -800,7 +1032,7 @@ func TestDeleteComment(t *testing.T) {
 		{
 			name: "Проверка на обработку ошибки при отсутствии сессии",
 			setupMocks: func(objID primitive.ObjectID) {
-				mockSessions.EXPECT().Check(gomock.Any()).Return(nil)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(nil)
 			},
 			requestURL:     fmt.Sprintf("/api/post/%s/%s", objID.Hex(), commentID.Hex()),
 			expectedStatus: http.StatusUnauthorized,
@@ -809,8 +1041,8 @@ func TestDeleteComment(t *testing.T) {
 		{
 			name: "Проверка обработки ошибки при удалении коммента",
 			setupMocks: func(objID primitive.ObjectID) {
-				st.EXPECT().DeleteComment(objID, commentID, int64(1)).Return(nil, fmt.Errorf("delete error"))
-				mockSessions.EXPECT().Check(gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
+				st.EXPECT().DeleteComment(gomock.Any(), objID, commentID, int64(1)).Return(nil, posts.ErrCommentNotFound)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username, Useragent: "some-user-agent"})
 			},
 			requestURL:     fmt.Sprintf("/api/post/%s/%s", objID.Hex(), commentID.Hex()),
 			expectedStatus: http.StatusNotFound,
@@ -839,3 +1071,89 @@ func TestDeleteComment(t *testing.T) {
 		})
 	}
 }
+
+func TestRestore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := posts.NewMockPostRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	service := &PostsHandler{
+		PostsRepo: st,
+		Logger:    zap.NewNop().Sugar(),
+		Sessions:  mockSessions,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/post/{POST_ID}/restore", service.RestorePost).Methods("POST")
+	router.HandleFunc("/api/post/{POST_ID}/{COMMENT_ID}/restore", service.RestoreComment).Methods("POST")
+
+	postID, commentID := primitive.NewObjectID(), primitive.NewObjectID()
+	post := &posts.Post{ID: postID, Title: title}
+
+	tests := []struct {
+		name       string
+		route      string
+		token      string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name:  "Восстановление поста автором",
+			route: fmt.Sprintf("/api/post/%s/restore", postID.Hex()),
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().RestorePost(gomock.Any(), postID, newUser.ID).Return(post, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "Пост уже стёрт или чужой",
+			route: fmt.Sprintf("/api/post/%s/restore", postID.Hex()),
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().RestorePost(gomock.Any(), postID, newUser.ID).Return(nil, posts.ErrPostNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:  "Восстановление комментария автором",
+			route: fmt.Sprintf("/api/post/%s/%s/restore", postID.Hex(), commentID.Hex()),
+			token: jwtToken,
+			setupMocks: func() {
+				st.EXPECT().RestoreComment(gomock.Any(), postID, commentID, newUser.ID).Return(post, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Неверный ID комментария",
+			route:      fmt.Sprintf("/api/post/%s/nope/restore", postID.Hex()),
+			token:      jwtToken,
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Без авторизации",
+			route:      fmt.Sprintf("/api/post/%s/restore", postID.Hex()),
+			setupMocks: func() {},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+
+			req := httptest.NewRequest("POST", tc.route, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).MaxTimes(1)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"redditclone/internal/ratelimit"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrRateLimited      = &HTTPError{Status: http.StatusTooManyRequests, Code: "rate_limited", Message: "too many requests"}
+	ErrDuplicateContent = &HTTPError{Status: http.StatusTooManyRequests, Code: "duplicate_content", Message: "the same text was posted too many times"}
+)
+
+// allowRequest проверяет бюджет маршрута для пользователя и его IP.
+// Если бюджет исчерпан, отвечает 429 и возвращает false.
+func (h *PostsHandler) allowRequest(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, route string, userID int64) bool {
+	res, err := h.checkRequest(r, logger, route, userID)
+	if res != nil {
+		ratelimit.SetHeaders(w, *res)
+	}
+	if err != nil {
+		writeError(w, r, logger, err)
+		return false
+	}
+	return true
+}
+
+// checkRequest - проверка allowRequest без записи ответа. res == nil - лимиты не применялись.
+func (h *PostsHandler) checkRequest(r *http.Request, logger *zap.SugaredLogger, route string, userID int64) (*ratelimit.Result, error) {
+	if h.Limiter == nil {
+		return nil, nil
+	}
+
+	res, err := h.Limiter.Allow(r.Context(), route, userID, ratelimit.ClientIP(r, h.TrustProxy))
+	if err != nil {
+		// Недоступный лимитер не должен ломать запись, поэтому пропускаем запрос.
+		logger.Errorw("rate limiter failed", "route", route, "error", err)
+		return nil, nil
+	}
+
+	if !res.Allowed {
+		return &res, ErrRateLimited
+	}
+	return &res, nil
+}
+
+// allowContent отсекает один и тот же текст, отправленный пользователем слишком много раз.
+func (h *PostsHandler) allowContent(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, userID int64, body string) bool {
+	if err := h.checkContent(r, logger, userID, body); err != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(h.Duplicates.Window.Seconds())))
+		writeError(w, r, logger, err)
+		return false
+	}
+	return true
+}
+
+// checkContent - проверка allowContent без записи ответа.
+func (h *PostsHandler) checkContent(r *http.Request, logger *zap.SugaredLogger, userID int64, body string) error {
+	if h.Duplicates == nil {
+		return nil
+	}
+
+	duplicate, err := h.Duplicates.IsDuplicate(r.Context(), userID, body)
+	if err != nil {
+		logger.Errorw("duplicate detector failed", "error", err)
+		return nil
+	}
+
+	if duplicate {
+		return ErrDuplicateContent
+	}
+	return nil
+}
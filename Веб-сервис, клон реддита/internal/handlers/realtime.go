@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"redditclone/internal/posts"
+	"redditclone/internal/realtime"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// sseHeartbeat - как часто слать комментарий-пинг, чтобы прокси не закрывали простаивающий поток.
+const sseHeartbeat = 25 * time.Second
+
+var ErrStreamingUnsupported = &HTTPError{Status: http.StatusInternalServerError, Code: "streaming_unsupported", Message: "streaming unsupported"}
+
+// broadcast публикует изменение поста подписчикам. Ошибка хаба не должна ломать сам запрос, поэтому только логируется.
+func (h *PostsHandler) broadcast(r *http.Request, logger *zap.SugaredLogger, typ realtime.EventType, post *posts.Post) {
+	if h.Realtime == nil {
+		return
+	}
+	if err := h.Realtime.Publish(r.Context(), realtime.NewEvent(typ, post)); err != nil {
+		logger.Errorw("failed to publish realtime event", "type", typ, "error", err)
+	}
+}
+
+// Events - поток Server-Sent Events: /api/events?post=ID&category=NAME, обоих параметров может быть несколько.
+// Без параметров приходят события обо всех постах. EventSource в браузере не умеет слать заголовки,
+// поэтому токен можно передать и параметром access_token.
+func (h *PostsHandler) Events(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, h.Logger)
+
+	query := r.URL.Query()
+	var topics []string
+	for _, rawID := range query["post"] {
+		if _, err := primitive.ObjectIDFromHex(rawID); err != nil {
+			writeError(w, r, logger, ErrInvalidID)
+			return
+		}
+		topics = append(topics, realtime.PostTopic(rawID))
+	}
+	for _, category := range query["category"] {
+		topics = append(topics, realtime.CategoryTopic(category))
+	}
+	if len(topics) == 0 {
+		topics = []string{realtime.TopicAll}
+	}
+
+	if token := query.Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	userID, _, err := authUser(r, h)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	logger = logger.With("user_id", userID, "topics", topics)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, logger, ErrStreamingUnsupported)
+		return
+	}
+
+	sub := h.Realtime.Subscribe(topics)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Отключаем буферизацию в nginx, иначе события будут приходить пачками.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+	logger.Infow("event stream opened")
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				// Хаб закрыт - сервер останавливается.
+				logger.Infow("event stream closed by server")
+				return
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				logger.Errorw("failed to marshal realtime event", "error", err)
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			logger.Infow("event stream closed by client")
+			return
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/posts"
+	"redditclone/internal/realtime"
+	"redditclone/internal/sessions"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// readEvent читает из потока SSE одно событие: строки до пустой, комментарии пропускаются.
+func readEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+	var name, data string
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && data != "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	hub := realtime.NewMemoryHub()
+	service := &PostsHandler{
+		Logger:   zap.NewNop().Sugar(),
+		Sessions: mockSessions,
+		Realtime: hub,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/events", service.Events).Methods("GET")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	postID := primitive.NewObjectID()
+
+	t.Run("Поток событий по посту с токеном в параметре", func(t *testing.T) {
+		mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/events?post="+postID.Hex()+"&access_token="+jwtToken, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		topic := realtime.PostTopic(postID.Hex())
+		require.Eventually(t, func() bool { return hub.Subscribers(topic) == 1 }, time.Second, 10*time.Millisecond)
+
+		// Событие о другом посте в поток не попадает.
+		assert.NoError(t, hub.Publish(ctx, realtime.NewEvent(realtime.EventVoteChanged, &posts.Post{ID: primitive.NewObjectID(), Category: "music"})))
+		assert.NoError(t, hub.Publish(ctx, realtime.NewEvent(realtime.EventCommentAdded, &posts.Post{ID: postID, Category: "music"})))
+
+		name, data := readEvent(t, bufio.NewReader(resp.Body))
+		assert.Equal(t, string(realtime.EventCommentAdded), name)
+		ev := realtime.Event{}
+		assert.NoError(t, json.Unmarshal([]byte(data), &ev))
+		assert.Equal(t, postID.Hex(), ev.PostID)
+
+		// Клиент ушёл - подписка снимается.
+		cancel()
+		assert.Eventually(t, func() bool { return hub.Subscribers(topic) == 0 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Без токена", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/api/events")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Некорректный id поста", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/api/events?post=nope&access_token=" + jwtToken)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Остановка хаба закрывает поток", func(t *testing.T) {
+		mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+
+		req := httptest.NewRequest("GET", "/api/events?category=music", nil)
+		req.Header.Set("Authorization", "Bearer "+jwtToken)
+		w := httptest.NewRecorder()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			router.ServeHTTP(w, req)
+		}()
+		require.Eventually(t, func() bool { return hub.Subscribers(realtime.CategoryTopic("music")) == 1 }, time.Second, 10*time.Millisecond)
+
+		hub.Close()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("stream was not closed")
+		}
+	})
+}
+
+func TestBroadcast(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st := posts.NewMockPostRepo(ctrl)
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	hub := realtime.NewMemoryHub()
+	service := &PostsHandler{
+		PostsRepo: st,
+		Logger:    zap.NewNop().Sugar(),
+		Sessions:  mockSessions,
+		Realtime:  hub,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/posts", service.MakePost).Methods("POST")
+	router.HandleFunc("/api/post/{POST_ID}", service.MakeComment).Methods("POST")
+	router.HandleFunc("/api/post/{POST_ID}/vote", service.VotePost).Methods("POST")
+
+	post := &posts.Post{ID: primitive.NewObjectID(), Category: "music", Comments: []*posts.Comment{{ID: primitive.NewObjectID()}}}
+	sub := hub.Subscribe([]string{realtime.CategoryTopic("music")})
+	defer sub.Close()
+
+	tests := []struct {
+		name       string
+		route      string
+		body       string
+		setupMocks func()
+		want       realtime.EventType
+	}{
+		{
+			name:  "Новый пост",
+			route: "/api/posts",
+			body:  `{"type":"text","title":"t","category":"music","text":"x"}`,
+			setupMocks: func() {
+				st.EXPECT().MakePost(gomock.Any(), gomock.Any(), newUser.Username, newUser.ID).Return(post, nil)
+			},
+			want: realtime.EventPostCreated,
+		},
+		{
+			name:  "Новый комментарий",
+			route: "/api/post/" + post.ID.Hex(),
+			body:  `{"comment":"c"}`,
+			setupMocks: func() {
+				st.EXPECT().MakeComment(gomock.Any(), post.ID, primitive.NilObjectID, "c", newUser.Username, newUser.ID).Return(post, nil)
+			},
+			want: realtime.EventCommentAdded,
+		},
+		{
+			name:  "Голос",
+			route: "/api/post/" + post.ID.Hex() + "/vote",
+			body:  `{"vote":-1}`,
+			setupMocks: func() {
+				st.EXPECT().VotePost(gomock.Any(), post.ID, newUser.ID, -1).Return(post, nil)
+			},
+			want: realtime.EventVoteChanged,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+			mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username})
+
+			req := httptest.NewRequest("POST", tc.route, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+jwtToken)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Less(t, w.Code, 300, w.Body.String())
+			select {
+			case ev := <-sub.C:
+				assert.Equal(t, tc.want, ev.Type)
+				assert.Equal(t, post.ID.Hex(), ev.PostID)
+			default:
+				t.Fatal("no event published")
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"redditclone/internal/apispec"
+	"redditclone/internal/posts"
+	"redditclone/internal/subscriptions"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// SpecPath - адрес спецификации OpenAPI.
+const SpecPath = "/api/openapi.json"
+
+var (
+	pageParam  = apispec.IntParam("page", "номер страницы, с 1", 1)
+	limitParam = apispec.IntParam("limit", "размер страницы, больше 100 урезается до 100", 1)
+)
+
+// Routes - все маршруты API в порядке регистрации: mux берёт первый подходящий маршрут,
+// поэтому /save, /hide и т.п. идут раньше маршрутов с {COMMENT_ID}.
+func Routes(users *UserHandler, h *PostsHandler, gql *GraphQLHandler) []apispec.Operation {
+	var (
+		post  = &posts.Post{}
+		list  = []*posts.Post{}
+		okMsg = messageResponse{}
+	)
+	return []apispec.Operation{
+		{Method: "POST", Path: "/api/login", ID: "login", Tag: "auth", Summary: "Войти",
+			Body: AuthForm{}, Status: http.StatusOK, Response: TokenResponse{}, Handler: users.Login},
+		{Method: "POST", Path: "/api/register", ID: "register", Tag: "auth", Summary: "Зарегистрироваться",
+			Body: AuthForm{}, Status: http.StatusOK, Response: TokenResponse{}, Handler: users.Register},
+		{Method: "POST", Path: "/api/graphql", ID: "graphql", Tag: "graphql", Summary: "Запрос GraphQL: посты, комментарии, пользователи и голоса",
+			Body: GraphQLRequest{}, Status: http.StatusOK, Response: graphQLResponse{}, Handler: gql.GraphQL},
+
+		{Method: "GET", Path: "/api/posts/", ID: "getAllPosts", Tag: "posts", Summary: "Все посты",
+			Status: http.StatusOK, Response: list, Conditional: true, Handler: h.GetAllPosts},
+		{Method: "GET", Path: "/api/posts/{CATEGORY_NAME}", ID: "getCategoryPosts", Tag: "posts", Summary: "Посты категории",
+			Status: http.StatusOK, Response: list, Conditional: true, Handler: h.GetCategoryPosts},
+		{Method: "GET", Path: "/api/user/{USER_LOGIN}", ID: "getUserPosts", Tag: "posts", Summary: "Посты автора",
+			Status: http.StatusOK, Response: list, Conditional: true, Handler: h.GetUserPosts},
+		{Method: "GET", Path: "/api/post/{POST_ID}", ID: "getPost", Tag: "posts", Summary: "Пост с комментариями",
+			Status: http.StatusOK, Response: post, Conditional: true, Handler: h.GetPost},
+		{Method: "POST", Path: "/api/post/{POST_ID}/vote", ID: "votePost", Tag: "votes", Summary: "Проголосовать за пост", Auth: true,
+			Body: posts.VoteForm{}, Status: http.StatusOK, Response: post, Handler: h.VotePost},
+		{Method: "PUT", Path: "/api/post/{POST_ID}/vote", ID: "putVotePost", Tag: "votes", Summary: "Проголосовать за пост", Auth: true,
+			Body: posts.VoteForm{}, Status: http.StatusOK, Response: post, Handler: h.VotePost},
+		{Method: "POST", Path: "/api/post/{POST_ID}/poll", ID: "votePoll", Tag: "votes", Summary: "Проголосовать в опросе", Auth: true,
+			Body: posts.PollVoteForm{}, Status: http.StatusOK, Response: post, Handler: h.VotePoll},
+		{Method: "POST", Path: "/api/post/{POST_ID}/upvote", ID: "upvotePost", Tag: "votes", Summary: "Голос +1", Auth: true,
+			Status: http.StatusOK, Response: post, Handler: h.UpVotePost},
+		{Method: "POST", Path: "/api/post/{POST_ID}/downvote", ID: "downvotePost", Tag: "votes", Summary: "Голос -1", Auth: true,
+			Status: http.StatusOK, Response: post, Handler: h.DownVotePost},
+		{Method: "POST", Path: "/api/post/{POST_ID}/unvote", ID: "unvotePost", Tag: "votes", Summary: "Снять голос", Auth: true,
+			Status: http.StatusOK, Response: post, Handler: h.UnVotePost},
+
+		{Method: "GET", Path: "/api/feed", ID: "getFeed", Tag: "feed", Summary: "Лента подписок", Auth: true,
+			Query: []apispec.Param{
+				apispec.StringParam("sort", "порядок постов, по умолчанию "+defaultFeedSort, posts.SortHot, posts.SortNew, posts.SortTop),
+				limitParam,
+				apispec.StringParam("cursor", "nextCursor предыдущей страницы"),
+			},
+			Status: http.StatusOK, Response: &posts.FeedPage{}, Handler: h.GetFeed},
+		{Method: "GET", Path: "/api/events", ID: "events", Tag: "feed", Summary: "Поток изменений постов (SSE)", Auth: true,
+			Query: []apispec.Param{
+				apispec.ListParam("post", "ID поста, за которым следить"),
+				apispec.ListParam("category", "категория, за которой следить"),
+				apispec.StringParam("access_token", "токен для EventSource, который не умеет заголовки"),
+			},
+			Status: http.StatusOK, Stream: true, Handler: h.Events},
+		{Method: "GET", Path: "/api/notifications", ID: "getNotifications", Tag: "notifications", Summary: "Уведомления", Auth: true,
+			Query:  []apispec.Param{apispec.BoolParam("unread", "только непрочитанные"), pageParam, limitParam},
+			Status: http.StatusOK, Response: notificationPage{}, Handler: h.GetNotifications},
+		{Method: "POST", Path: "/api/notifications/read", ID: "markNotificationsRead", Tag: "notifications", Summary: "Отметить прочитанными", Auth: true,
+			Body: MarkReadForm{}, OptionalBody: true, Status: http.StatusOK, Response: map[string]int64{}, Handler: h.MarkNotificationsRead},
+		{Method: "GET", Path: "/api/me/subscriptions", ID: "getSubscriptions", Tag: "feed", Summary: "Мои подписки", Auth: true,
+			Status: http.StatusOK, Response: []*subscriptions.Subscription{}, Handler: h.GetSubscriptions},
+		{Method: "POST", Path: "/api/subscriptions/{TYPE:category|user}/{TARGET}", ID: "subscribe", Tag: "feed", Summary: "Подписаться", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.Subscribe},
+		{Method: "DELETE", Path: "/api/subscriptions/{TYPE:category|user}/{TARGET}", ID: "unsubscribe", Tag: "feed", Summary: "Отписаться", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.Unsubscribe},
+
+		{Method: "GET", Path: "/api/mod/queue", ID: "getModQueue", Tag: "moderation", Summary: "Очередь жалоб", Auth: true,
+			Query: []apispec.Param{pageParam, limitParam}, Status: http.StatusOK, Response: queuePage{}, Handler: h.GetModQueue},
+		{Method: "GET", Path: "/api/mod/log", ID: "getModLog", Tag: "moderation", Summary: "Журнал модерации", Auth: true,
+			Query: []apispec.Param{pageParam, limitParam}, Status: http.StatusOK, Response: auditPage{}, Handler: h.GetModLog},
+		{Method: "POST", Path: "/api/mod/post/{POST_ID}/{ACTION:remove|approve|lock|unlock|sticky|unsticky|restore}", ID: "moderatePost", Tag: "moderation", Summary: "Действие модератора над постом", Auth: true,
+			Body: ModerationForm{}, OptionalBody: true, Status: http.StatusOK, Response: post, Handler: h.Moderate},
+		{Method: "POST", Path: "/api/mod/post/{POST_ID}/{COMMENT_ID}/{ACTION:remove|approve|restore}", ID: "moderateComment", Tag: "moderation", Summary: "Действие модератора над комментарием", Auth: true,
+			Body: ModerationForm{}, OptionalBody: true, Status: http.StatusOK, Response: post, Handler: h.Moderate},
+
+		{Method: "GET", Path: "/api/me/export", ID: "exportAccount", Tag: "account", Summary: "Выгрузить свои данные: профиль, посты, комментарии, голоса, списки, подписки, уведомления и жалобы", Auth: true,
+			Query:  []apispec.Param{apispec.StringParam("format", "json (по умолчанию) или zip-архив из JSON файлов", "json", "zip")},
+			Status: http.StatusOK, Response: accountExport{}, Files: []string{"application/zip"}, Handler: h.ExportAccount},
+		{Method: "DELETE", Path: "/api/me", ID: "deleteAccount", Tag: "account", Summary: "Удалить аккаунт: посты и комментарии остаются под [deleted], голоса снимаются, личные данные стираются", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.DeleteAccount},
+
+		{Method: "GET", Path: "/api/me/saved", ID: "getSaved", Tag: "collections", Summary: "Сохранённое", Auth: true,
+			Query: []apispec.Param{pageParam, limitParam}, Status: http.StatusOK, Response: collectionPage{}, Handler: h.GetSaved},
+		{Method: "GET", Path: "/api/me/hidden", ID: "getHidden", Tag: "collections", Summary: "Скрытое", Auth: true,
+			Query: []apispec.Param{pageParam, limitParam}, Status: http.StatusOK, Response: collectionPage{}, Handler: h.GetHidden},
+		{Method: "POST", Path: "/api/post/{POST_ID}/crosspost", ID: "crosspost", Tag: "posts", Summary: "Кросспост в другую категорию", Auth: true,
+			Body: posts.CrosspostForm{}, Status: http.StatusCreated, Response: post, Handler: h.Crosspost},
+		{Method: "POST", Path: "/api/post/{POST_ID}/save", ID: "savePost", Tag: "collections", Summary: "Сохранить пост", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.SaveItem},
+		{Method: "DELETE", Path: "/api/post/{POST_ID}/save", ID: "unsavePost", Tag: "collections", Summary: "Убрать пост из сохранённого", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.UnsaveItem},
+		{Method: "POST", Path: "/api/post/{POST_ID}/hide", ID: "hidePost", Tag: "collections", Summary: "Скрыть пост", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.HideItem},
+		{Method: "DELETE", Path: "/api/post/{POST_ID}/hide", ID: "unhidePost", Tag: "collections", Summary: "Вернуть скрытый пост", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.UnhideItem},
+		{Method: "POST", Path: "/api/post/{POST_ID}/{COMMENT_ID}/save", ID: "saveComment", Tag: "collections", Summary: "Сохранить комментарий", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.SaveItem},
+		{Method: "DELETE", Path: "/api/post/{POST_ID}/{COMMENT_ID}/save", ID: "unsaveComment", Tag: "collections", Summary: "Убрать комментарий из сохранённого", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.UnsaveItem},
+		{Method: "POST", Path: "/api/post/{POST_ID}/{COMMENT_ID}/hide", ID: "hideComment", Tag: "collections", Summary: "Скрыть комментарий", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.HideItem},
+		{Method: "DELETE", Path: "/api/post/{POST_ID}/{COMMENT_ID}/hide", ID: "unhideComment", Tag: "collections", Summary: "Вернуть скрытый комментарий", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.UnhideItem},
+		{Method: "POST", Path: "/api/post/{POST_ID}/report", ID: "reportPost", Tag: "moderation", Summary: "Пожаловаться на пост", Auth: true,
+			Body: ReportForm{}, Status: http.StatusOK, Response: okMsg, Handler: h.ReportItem},
+		{Method: "POST", Path: "/api/post/{POST_ID}/{COMMENT_ID}/report", ID: "reportComment", Tag: "moderation", Summary: "Пожаловаться на комментарий", Auth: true,
+			Body: ReportForm{}, Status: http.StatusOK, Response: okMsg, Handler: h.ReportItem},
+		{Method: "POST", Path: "/api/post/{POST_ID}/restore", ID: "restorePost", Tag: "posts", Summary: "Восстановить удалённый пост", Auth: true,
+			Status: http.StatusOK, Response: post, Handler: h.RestorePost},
+		{Method: "POST", Path: "/api/post/{POST_ID}/{COMMENT_ID}/restore", ID: "restoreComment", Tag: "posts", Summary: "Восстановить удалённый комментарий", Auth: true,
+			Status: http.StatusOK, Response: post, Handler: h.RestoreComment},
+
+		{Method: "POST", Path: "/api/posts", ID: "makePost", Tag: "posts", Summary: "Опубликовать пост (картинка - через multipart/form-data)", Auth: true,
+			Body: posts.PostForm{}, Multipart: true, Status: http.StatusCreated, Response: post, Handler: h.MakePost},
+		{Method: "DELETE", Path: "/api/post/{POST_ID}", ID: "deletePost", Tag: "posts", Summary: "Удалить пост", Auth: true,
+			Status: http.StatusOK, Response: okMsg, Handler: h.DeletePost},
+
+		{Method: "POST", Path: "/api/post/{POST_ID}", ID: "makeComment", Tag: "posts", Summary: "Оставить комментарий", Auth: true,
+			Body: posts.CommentForm{}, Status: http.StatusCreated, Response: post, Handler: h.MakeComment},
+		{Method: "DELETE", Path: "/api/post/{POST_ID}/{COMMENT_ID}", ID: "deleteComment", Tag: "posts", Summary: "Удалить комментарий", Auth: true,
+			Status: http.StatusOK, Response: post, Handler: h.DeleteComment},
+	}
+}
+
+// NewSpec собирает спецификацию OpenAPI по таблице маршрутов.
+func NewSpec(ops []apispec.Operation) (*apispec.Spec, error) {
+	return apispec.New("redditclone API", "1.0.0", errorResponse{}, ops)
+}
+
+// Register регистрирует маршруты API и саму спецификацию. Каждый запрос до обработчика
+// проверяется по спецификации, ошибки в полях возвращаются как 422 в формате форм.
+func Register(r *mux.Router, spec *apispec.Spec, ops []apispec.Operation, logger *zap.SugaredLogger) {
+	r.Handle(SpecPath, spec).Methods("GET")
+	for _, op := range ops {
+		r.HandleFunc(op.Path, validateRequest(spec, op, logger)).Methods(op.Method)
+	}
+}
+
+func validateRequest(spec *apispec.Spec, op apispec.Operation, logger *zap.SugaredLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fieldErrors, err := spec.Validate(r, op.Method, op.Path, mux.Vars(r))
+		if err != nil {
+			writeError(w, r, requestLogger(r, logger), fmt.Errorf("%w: %w", ErrBadRequest, err))
+			return
+		}
+		if fieldErrors != nil {
+			writeError(w, r, requestLogger(r, logger), validationError(fieldErrors))
+			return
+		}
+		op.Handler(w, r)
+	}
+}
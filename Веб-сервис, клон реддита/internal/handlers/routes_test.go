@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/apispec"
+	"redditclone/internal/collections"
+	"redditclone/internal/moderation"
+	"redditclone/internal/notifications"
+	"redditclone/internal/posts"
+	"redditclone/internal/realtime"
+	"redditclone/internal/sessions"
+	"redditclone/internal/subscriptions"
+	"redditclone/internal/user"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// contractBodies - тела запросов для контрактного теста, по operationId.
+var contractBodies = map[string]string{
+	"login":           `{"username":"rvasily","password":"love"}`,
+	"register":        `{"username":"rvasily","password":"love"}`,
+	"votePost":        `{"vote":1}`,
+	"putVotePost":     `{"vote":-1}`,
+	"votePoll":        `{"option":0}`,
+	"crosspost":       `{"category":"news"}`,
+	"reportPost":      `{"reason":"spam"}`,
+	"reportComment":   `{"reason":"spam","details":"реклама"}`,
+	"makePost":        `{"type":"text","title":"Заголовок","category":"music","text":"текст"}`,
+	"makeComment":     `{"comment":"комментарий"}`,
+	"moderatePost":    `{"reason":"правила"}`,
+	"moderateComment": ``,
+	"graphql":         `{"query":"{ posts(first: 5) { id title author { username karma } votes { user { username } } } }"}`,
+}
+
+// newContractRouter собирает роутер как в main: все маршруты из Routes, репозитории - моки,
+// которые на любой вызов отвечают успехом.
+func newContractRouter(t *testing.T, post *posts.Post) (*mux.Router, *apispec.Spec, []apispec.Operation) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	postsRepo := posts.NewMockPostRepo(ctrl)
+	postsRepo.EXPECT().GetPost(gomock.Any(), gomock.Any()).Return(post, nil).AnyTimes()
+	postsRepo.EXPECT().GetPosts(gomock.Any(), gomock.Any()).Return([]*posts.Post{post}, nil).AnyTimes()
+	postsRepo.EXPECT().GetPostsByIDs(gomock.Any(), gomock.Any()).Return([]*posts.Post{post}, nil).AnyTimes()
+	postsRepo.EXPECT().GetPostsByAuthors(gomock.Any(), gomock.Any()).Return([]*posts.Post{post}, nil).AnyTimes()
+	postsRepo.EXPECT().ListPosts(gomock.Any(), gomock.Any()).Return([]*posts.Post{post}, nil).AnyTimes()
+	postsRepo.EXPECT().VotePost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil).AnyTimes()
+	postsRepo.EXPECT().MakePost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil).AnyTimes()
+	postsRepo.EXPECT().DeletePost(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+	postsRepo.EXPECT().MakeComment(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil).AnyTimes()
+	postsRepo.EXPECT().DeleteComment(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil).AnyTimes()
+	postsRepo.EXPECT().RestorePost(gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil).AnyTimes()
+	postsRepo.EXPECT().RestoreComment(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil).AnyTimes()
+	postsRepo.EXPECT().GetFeed(gomock.Any(), gomock.Any()).Return(&posts.FeedPage{Posts: []*posts.Post{post}}, nil).AnyTimes()
+	postsRepo.EXPECT().SetPostFlags(gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil).AnyTimes()
+	postsRepo.EXPECT().SetCommentRemoved(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil).AnyTimes()
+	postsRepo.EXPECT().VotePoll(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil).AnyTimes()
+	postsRepo.EXPECT().Crosspost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(post, nil).AnyTimes()
+	postsRepo.EXPECT().AnonymizeUser(gomock.Any(), gomock.Any()).Return(posts.AnonymizeResult{Posts: 1}, nil).AnyTimes()
+	postsRepo.EXPECT().UserContent(gomock.Any(), gomock.Any()).Return([]*posts.Post{post}, nil).AnyTimes()
+
+	userRepo := user.NewMockUserRepo(ctrl)
+	userRepo.EXPECT().Authorize(gomock.Any(), gomock.Any(), gomock.Any()).Return(&newUser, nil).AnyTimes()
+	userRepo.EXPECT().MakeUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(&newUser, nil).AnyTimes()
+	userRepo.EXPECT().GetUsersByIDs(gomock.Any(), gomock.Any()).Return([]*user.User{{ID: newUser.ID, Username: newUser.Username}}, nil).AnyTimes()
+	userRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(&user.User{ID: newUser.ID, Username: newUser.Username}, nil).AnyTimes()
+	userRepo.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	mockSessions := sessions.NewMockSessionManagerInterface(ctrl)
+	mockSessions.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&sessions.SessionID{ID: "session"}, nil).AnyTimes()
+	mockSessions.EXPECT().Check(gomock.Any(), gomock.Any()).Return(&sessions.Session{ID: newUser.ID, Login: newUser.Username}).AnyTimes()
+	mockSessions.EXPECT().RevokeAll(gomock.Any(), gomock.Any()).Return(1, nil).AnyTimes()
+
+	collectionsRepo := collections.NewMockCollectionRepo(ctrl)
+	collectionsRepo.EXPECT().Add(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	collectionsRepo.EXPECT().Remove(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	collectionsRepo.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]*collections.Item{{Type: collections.ItemPost, PostID: post.ID, Created: time.Now()}}, nil).AnyTimes()
+	collectionsRepo.EXPECT().Hidden(gomock.Any(), gomock.Any(), gomock.Any()).Return(map[primitive.ObjectID]bool{}, nil).AnyTimes()
+	collectionsRepo.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(int64(1), nil).AnyTimes()
+
+	subscriptionsRepo := subscriptions.NewMockSubscriptionRepo(ctrl)
+	subscriptionsRepo.EXPECT().Subscribe(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	subscriptionsRepo.EXPECT().Unsubscribe(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	subscriptionsRepo.EXPECT().List(gomock.Any(), gomock.Any()).
+		Return([]*subscriptions.Subscription{{Type: subscriptions.TypeCategory, Target: "music", Created: time.Now()}}, nil).AnyTimes()
+	subscriptionsRepo.EXPECT().DeleteUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(1), nil).AnyTimes()
+
+	notificationsRepo := notifications.NewMockNotificationRepo(ctrl)
+	notificationsRepo.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]*notifications.Notification{{ID: primitive.NewObjectID(), Type: notifications.TypeReply, PostID: post.ID}}, nil).AnyTimes()
+	notificationsRepo.EXPECT().UnreadCount(gomock.Any(), gomock.Any()).Return(int64(1), nil).AnyTimes()
+	notificationsRepo.EXPECT().MarkRead(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(1), nil).AnyTimes()
+	notificationsRepo.EXPECT().DeleteUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(1), nil).AnyTimes()
+
+	reportsRepo := moderation.NewMockReportRepo(ctrl)
+	reportsRepo.EXPECT().Report(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	reportsRepo.EXPECT().Queue(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]*moderation.QueueItem{{Target: moderation.Target{PostID: post.ID}, Reports: 1, Reasons: []moderation.Reason{moderation.ReasonSpam}}}, nil).AnyTimes()
+	reportsRepo.EXPECT().Resolve(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(1), nil).AnyTimes()
+	reportsRepo.EXPECT().ReportsBy(gomock.Any(), gomock.Any()).
+		Return([]*moderation.Report{{ID: primitive.NewObjectID(), Target: moderation.Target{PostID: post.ID}, Reason: moderation.ReasonSpam}}, nil).AnyTimes()
+	reportsRepo.EXPECT().DeleteReportsBy(gomock.Any(), gomock.Any()).Return(int64(1), nil).AnyTimes()
+
+	auditLog := moderation.NewMockAuditLog(ctrl)
+	auditLog.EXPECT().Append(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	auditLog.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]*moderation.AuditEntry{{ID: primitive.NewObjectID(), Actor: newUser.Username, Action: moderation.ActionRemove, Target: moderation.Target{PostID: post.ID}}}, nil).AnyTimes()
+
+	hub := realtime.NewMemoryHub()
+	t.Cleanup(hub.Close)
+
+	logger := zap.NewNop().Sugar()
+	users := &UserHandler{UserRepo: userRepo, Logger: logger, Sessions: mockSessions}
+	h := &PostsHandler{
+		PostsRepo:     postsRepo,
+		Logger:        logger,
+		Sessions:      mockSessions,
+		Users:         userRepo,
+		Collections:   collectionsRepo,
+		Subscriptions: subscriptionsRepo,
+		Notifications: notificationsRepo,
+		Realtime:      hub,
+		Moderators:    moderation.NewModerators([]string{newUser.Username}),
+		Reports:       reportsRepo,
+		AuditLog:      auditLog,
+	}
+
+	gql, err := NewGraphQLHandler(h, userRepo, 0)
+	require.NoError(t, err)
+
+	routes := Routes(users, h, gql)
+	spec, err := NewSpec(routes)
+	require.NoError(t, err)
+	router := mux.NewRouter()
+	Register(router, spec, routes, logger)
+	return router, spec, routes
+}
+
+func contractPost() *posts.Post {
+	commentID := primitive.NewObjectID()
+	return &posts.Post{
+		ID:       primitive.NewObjectID(),
+		Type:     posts.TypeText,
+		Title:    "Заголовок",
+		Category: "music",
+		Text:     "текст",
+		Author:   &user.User{ID: newUser.ID, Username: newUser.Username},
+		Votes:    []*posts.Vote{{UserID: newUser.ID, Vote: 1}},
+		Comments: []*posts.Comment{{ID: commentID, Body: "комментарий", Author: &user.User{ID: newUser.ID, Username: newUser.Username}}},
+		Created:  time.Now().UTC().Format(time.RFC3339),
+		Score:    1,
+	}
+}
+
+// TestContract проходит по всем операциям спецификации через настоящий роутер
+// и сверяет каждый успешный ответ со схемой.
+func TestContract(t *testing.T) {
+	post := contractPost()
+	router, spec, routes := newContractRouter(t, post)
+
+	vars := map[string]string{
+		"POST_ID":       post.ID.Hex(),
+		"COMMENT_ID":    post.Comments[0].ID.Hex(),
+		"CATEGORY_NAME": "music",
+		"USER_LOGIN":    newUser.Username,
+		"TARGET":        "music",
+	}
+
+	for _, op := range routes {
+		op := op
+		t.Run(op.ID, func(t *testing.T) {
+			route := spec.Route(op.Method, op.Path)
+			require.NotNil(t, route)
+
+			pathParams := map[string]string{}
+			path := route.Path
+			for _, p := range route.Operation.Parameters {
+				if p.Value.In != openapi3.ParameterInPath {
+					continue
+				}
+				value := vars[p.Value.Name]
+				if enum := p.Value.Schema.Value.Enum; len(enum) > 0 {
+					value = enum[0].(string)
+				}
+				pathParams[p.Value.Name] = value
+				path = strings.Replace(path, "{"+p.Value.Name+"}", value, 1)
+			}
+
+			ctx := context.Background()
+			if op.Stream {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, 50*time.Millisecond)
+				defer cancel()
+			}
+			req := httptest.NewRequest(op.Method, path, strings.NewReader(contractBodies[op.ID])).WithContext(ctx)
+			req.Header.Set("Authorization", "Bearer "+jwtToken)
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, op.Status, w.Code, w.Body.String())
+			err := spec.ValidateResponse(req, op.Method, op.Path, pathParams, w.Code, w.Header(), w.Body.Bytes())
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSpecEndpoint(t *testing.T) {
+	router, _, _ := newContractRouter(t, contractPost())
+
+	req := httptest.NewRequest("GET", SpecPath, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	doc, err := openapi3.NewLoader().LoadFromData(w.Body.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+	assert.NotNil(t, doc.Paths.Find("/api/post/{POST_ID}/{COMMENT_ID}/report"))
+	assert.NotNil(t, doc.Components.Schemas["Post"])
+}
+
+func TestRequestValidation(t *testing.T) {
+	post := contractPost()
+	router, _, _ := newContractRouter(t, post)
+
+	tests := []struct {
+		name       string
+		method     string
+		route      string
+		body       string
+		wantStatus int
+		wantErrors []map[string]string
+	}{
+		{
+			name:       "Пустой заголовок и слишком длинный текст",
+			method:     "POST",
+			route:      "/api/posts",
+			body:       `{"type":"text","title":"","category":"music","text":"` + strings.Repeat("a", 40001) + `"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrors: []map[string]string{
+				{"location": "body", "param": "text", "msg": "must be at most 40000 characters"},
+				{"location": "body", "param": "title", "msg": "is required"},
+			},
+		},
+		{
+			name:       "Нет обязательных полей",
+			method:     "POST",
+			route:      "/api/login",
+			body:       `{"username":"rvasily"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrors: []map[string]string{
+				{"location": "body", "param": "password", "msg": "is required"},
+			},
+		},
+		{
+			name:       "Неверный тип поля",
+			method:     "POST",
+			route:      "/api/post/" + post.ID.Hex() + "/vote",
+			body:       `{"vote":"up"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrors: []map[string]string{
+				{"location": "body", "param": "vote", "msg": "must be an integer"},
+			},
+		},
+		{
+			name:       "Неизвестная сортировка и лимит не числом",
+			method:     "GET",
+			route:      "/api/feed?sort=best&limit=ten",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrors: []map[string]string{
+				{"location": "query", "param": "sort", "msg": "must be one of hot, new, top"},
+				{"location": "query", "param": "limit", "msg": "must be an integer"},
+			},
+		},
+		{
+			name:       "Битый JSON",
+			method:     "POST",
+			route:      "/api/posts",
+			body:       `{"type":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Корректный запрос доходит до обработчика",
+			method:     "POST",
+			route:      "/api/post/" + post.ID.Hex(),
+			body:       `{"comment":"комментарий"}`,
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var body io.Reader = http.NoBody
+			if tc.body != "" {
+				body = strings.NewReader(tc.body)
+			}
+			req := httptest.NewRequest(tc.method, tc.route, body)
+			req.Header.Set("Authorization", "Bearer "+jwtToken)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantErrors != nil {
+				var resp errorResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, "validation_failed", resp.Code)
+				assert.ElementsMatch(t, tc.wantErrors, resp.Errors)
+			}
+		})
+	}
+}
@@ -2,9 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"go.uber.org/zap"
 	"io"
-	"log"
 	"net/http"
 	"redditclone/internal/sessions"
 	"redditclone/internal/user"
@@ -30,176 +30,125 @@ var (
 )
 
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-
-	h.Logger.Infoln("Start logging")
+	logger := requestLogger(r, h.Logger)
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, ErrReading, http.StatusBadRequest)
+		writeError(w, r, logger, ErrReading)
 		return
 	}
 	r.Body.Close()
 
 	fd := &AuthForm{}
 	if err = json.Unmarshal(body, fd); err != nil {
-		http.Error(w, ErrBadRequest, http.StatusBadRequest)
+		writeError(w, r, logger, ErrBadRequest)
 		return
 	}
-
-	h.Logger.Infoln("User data unmarshalled")
+	logger = logger.With("username", fd.Username)
 
 	// Валидация предоставленных данных
-	errors := dataValidation(fd)
-	if errors != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		err = json.NewEncoder(w).Encode(map[string][]map[string]string{errConst: errors})
-		if err != nil {
-			h.Logger.Errorln(err.Error())
-		}
+	if errors := dataValidation(fd); errors != nil {
+		writeError(w, r, logger, validationError(errors))
 		return
 	}
 
-	h.Logger.Infoln("User data validated")
-
 	// Авторизация пользователя по предоставленным данным
-	u, err := h.UserRepo.Authorize(fd.Username, fd.Password)
-
-	if err == user.ErrNoUser {
-		http.Error(w, ErrUserNotFound, http.StatusUnauthorized)
-		return
-	}
-	if err == user.ErrBadPass {
-		http.Error(w, ErrInvalidPass, http.StatusUnauthorized)
+	u, err := h.UserRepo.Authorize(r.Context(), fd.Username, fd.Password)
+	if err != nil {
+		writeError(w, r, logger, err)
 		return
 	}
-
-	h.Logger.Infoln("User authorized")
+	logger = logger.With("user_id", u.ID)
 
 	// Сохранение сессии в redis.
-	sess, err := h.Sessions.Create(&sessions.Session{
+	sess, err := h.Sessions.Create(r.Context(), &sessions.Session{
 		ID:        u.ID,
 		Login:     fd.Username,
 		Useragent: r.UserAgent(),
 	})
 	if err != nil {
-		log.Println("cant create session:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, logger, err)
 		return
 	}
 
 	// Создание и отправка jwt
 	tokenString, err := makeJWT(u, sess)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, err)
 		return
 	}
 
-	h.Logger.Infoln("JWT token made")
-
-	resp, err := json.Marshal(map[string]interface{}{
-		tokenConst: tokenString,
-	})
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	_, err = w.Write(resp)
-	if err != nil {
-		h.Logger.Errorln(err.Error())
-	}
-
+	logger.Infow("user logged in")
+	writeJSON(w, logger, http.StatusOK, TokenResponse{Token: tokenString})
 }
 
 func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
-
-	h.Logger.Infoln("Start registering")
+	logger := requestLogger(r, h.Logger)
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, ErrReading, http.StatusBadRequest)
+		writeError(w, r, logger, ErrReading)
 		return
 	}
 	r.Body.Close()
 
 	fd := &AuthForm{}
 	if err = json.Unmarshal(body, fd); err != nil {
-		http.Error(w, ErrBadRequest, http.StatusBadRequest)
+		writeError(w, r, logger, ErrBadRequest)
 		return
 	}
-
-	h.Logger.Infoln("User data unmarshalled")
+	logger = logger.With("username", fd.Username)
 
 	// Валидация предоставленных данных.
-	errors := dataValidation(fd)
-	if len(errors) == 2 || fd.Username == "" {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		err = json.NewEncoder(w).Encode(map[string][]map[string]string{errConst: errors})
-		if err != nil {
-			h.Logger.Errorln(err.Error())
-		}
+	if fieldErrors := dataValidation(fd); fieldErrors != nil {
+		writeError(w, r, logger, validationError(fieldErrors))
 		return
 	}
 
-	h.Logger.Infoln("User data validated")
-
 	// Создание пользователя по предоставленным данным.
-	u, err := h.UserRepo.MakeUser(fd.Username, fd.Password)
-
-	// обработка ошибки, что юзер уже есть.
-	if err == user.ErrExists {
-		newError := map[string]string{
+	u, err := h.UserRepo.MakeUser(r.Context(), fd.Username, fd.Password)
+	if errors.Is(err, user.ErrExists) {
+		// обработка ошибки, что юзер уже есть.
+		writeError(w, r, logger, validationError([]map[string]string{{
 			"location": "body",
 			"param":    fd.Username,
 			"msg":      "already exists",
-		}
-		errors = append(errors, newError)
+		}}))
+		return
 	}
-
-	if errors != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		err = json.NewEncoder(w).Encode(map[string][]map[string]string{errConst: errors})
-		if err != nil {
-			h.Logger.Errorln(err.Error())
-		}
+	if errors.Is(err, user.ErrReserved) {
+		writeError(w, r, logger, validationError([]map[string]string{{
+			"location": "body",
+			"param":    "username",
+			"msg":      "is reserved",
+		}}))
+		return
+	}
+	if err != nil {
+		writeError(w, r, logger, err)
 		return
 	}
 
-	h.Logger.Infoln("User made")
+	logger = logger.With("user_id", u.ID)
+	logger.Infow("user registered")
 
 	// Сохранение сессии в redis.
-	sess, err := h.Sessions.Create(&sessions.Session{
+	sess, err := h.Sessions.Create(r.Context(), &sessions.Session{
 		ID:        u.ID,
 		Login:     fd.Username,
 		Useragent: r.UserAgent(),
 	})
 	if err != nil {
-		log.Println("cant create session:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, logger, err)
 		return
 	}
 
 	// Создание и отправка jwt.
 	tokenString, err := makeJWT(u, sess)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, err)
 		return
 	}
 
-	h.Logger.Infoln("JWT token made")
-
-	resp, err := json.Marshal(map[string]interface{}{
-		tokenConst: tokenString,
-	})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	_, err = w.Write(resp)
-	if err != nil {
-		h.Logger.Errorln(err.Error())
-	}
-
+	writeJSON(w, logger, http.StatusOK, TokenResponse{Token: tokenString})
 }
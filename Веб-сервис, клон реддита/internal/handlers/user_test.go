@@ -60,8 +60,8 @@ func TestLoginHandler(t *testing.T) {
 		{
 			name: "Успешный login",
 			setupMocks: func() {
-				mockRepo.EXPECT().Authorize("validUser", "validPass").Return(&user.User{}, nil)
-				mockSessions.EXPECT().Create(gomock.Any()).Return(&sessions.SessionID{ID: "session-id"}, nil)
+				mockRepo.EXPECT().Authorize(gomock.Any(), "validUser", "validPass").Return(&user.User{}, nil)
+				mockSessions.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&sessions.SessionID{ID: "session-id"}, nil)
 			},
 			requestBody: map[string]string{"username": "validUser", "password": "validPass"},
 			wantStatus:  http.StatusOK,
@@ -85,7 +85,7 @@ func TestLoginHandler(t *testing.T) {
 		{
 			name: "Проверка обработки ошибки при авторизации, что юзер не найден",
 			setupMocks: func() {
-				mockRepo.EXPECT().Authorize("invalidUser", "invalidPass").Return(nil, user.ErrNoUser)
+				mockRepo.EXPECT().Authorize(gomock.Any(), "invalidUser", "invalidPass").Return(nil, user.ErrNoUser)
 			},
 			requestBody: map[string]string{"username": "invalidUser", "password": "invalidPass"},
 			wantStatus:  http.StatusUnauthorized,
@@ -94,7 +94,7 @@ func TestLoginHandler(t *testing.T) {
 		{
 			name: "Проверка обработки ошибки при авторизации, что пароль неправильный",
 			setupMocks: func() {
-				mockRepo.EXPECT().Authorize("someUser", "badPass").Return(nil, user.ErrBadPass)
+				mockRepo.EXPECT().Authorize(gomock.Any(), "someUser", "badPass").Return(nil, user.ErrBadPass)
 			},
 			requestBody: map[string]string{"username": "someUser", "password": "badPass"},
 			wantStatus:  http.StatusUnauthorized,
@@ -103,8 +103,8 @@ func TestLoginHandler(t *testing.T) {
 		{
 			name: "Обработка ошибки при создании сессии",
 			setupMocks: func() {
-				mockRepo.EXPECT().Authorize("validUser", "validPass").Return(&user.User{}, nil)
-				mockSessions.EXPECT().Create(gomock.Any()).Return(nil, fmt.Errorf("session creation failed"))
+				mockRepo.EXPECT().Authorize(gomock.Any(), "validUser", "validPass").Return(&user.User{}, nil)
+				mockSessions.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("session creation failed"))
 			},
 			requestBody: map[string]string{"username": "validUser", "password": "validPass"},
 			wantStatus:  http.StatusInternalServerError,
@@ -113,8 +113,8 @@ func TestLoginHandler(t *testing.T) {
 		{
 			name: "Обработка ошибки при создании ответа",
 			setupMocks: func() {
-				mockRepo.EXPECT().Authorize("validUser", "validPass").Return(&user.User{}, nil)
-				mockSessions.EXPECT().Create(gomock.Any()).Return(&sessions.SessionID{ID: "session-id"}, nil)
+				mockRepo.EXPECT().Authorize(gomock.Any(), "validUser", "validPass").Return(&user.User{}, nil)
+				mockSessions.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&sessions.SessionID{ID: "session-id"}, nil)
 			},
 			requestBody:  map[string]string{"username": "validUser", "password": "validPass"},
 			expectError:  true,
@@ -191,8 +191,8 @@ func TestRegisterHandler(t *testing.T) {
 		{
 			name: "Успешный register",
 			setupMocks: func() {
-				mockRepo.EXPECT().MakeUser("validUser", "validPass").Return(&user.User{}, nil)
-				mockSessions.EXPECT().Create(gomock.Any()).Return(&sessions.SessionID{ID: "session-id"}, nil)
+				mockRepo.EXPECT().MakeUser(gomock.Any(), "validUser", "validPass").Return(&user.User{}, nil)
+				mockSessions.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&sessions.SessionID{ID: "session-id"}, nil)
 			},
 			requestBody: map[string]string{"username": "validUser", "password": "validPass"},
 			wantStatus:  http.StatusOK,
@@ -216,17 +216,26 @@ func TestRegisterHandler(t *testing.T) {
 		{
 			name: "Проверка обработки ошибки при авторизации, что юзер уже есть",
 			setupMocks: func() {
-				mockRepo.EXPECT().MakeUser("invalidUser", "invalidPass").Return(nil, user.ErrExists)
+				mockRepo.EXPECT().MakeUser(gomock.Any(), "invalidUser", "invalidPass").Return(nil, user.ErrExists)
 			},
 			requestBody: map[string]string{"username": "invalidUser", "password": "invalidPass"},
 			wantStatus:  http.StatusUnprocessableEntity,
 			expectError: true,
 		},
+		{
+			name: "Проверка, что служебный логин [deleted] занят",
+			setupMocks: func() {
+				mockRepo.EXPECT().MakeUser(gomock.Any(), "[deleted]", "validPass").Return(nil, user.ErrReserved)
+			},
+			requestBody: map[string]string{"username": "[deleted]", "password": "validPass"},
+			wantStatus:  http.StatusUnprocessableEntity,
+			expectError: true,
+		},
 		{
 			name: "Обработка ошибки при создании сессии",
 			setupMocks: func() {
-				mockRepo.EXPECT().MakeUser("validUser", "validPass").Return(&user.User{}, nil)
-				mockSessions.EXPECT().Create(gomock.Any()).Return(nil, fmt.Errorf("session creation failed"))
+				mockRepo.EXPECT().MakeUser(gomock.Any(), "validUser", "validPass").Return(&user.User{}, nil)
+				mockSessions.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("session creation failed"))
 			},
 			requestBody: map[string]string{"username": "validUser", "password": "validPass"},
 			wantStatus:  http.StatusInternalServerError,
@@ -235,8 +244,8 @@ func TestRegisterHandler(t *testing.T) {
 		{
 			name: "Обработка ошибки при создании ответа",
 			setupMocks: func() {
-				mockRepo.EXPECT().MakeUser("validUser", "validPass").Return(&user.User{}, nil)
-				mockSessions.EXPECT().Create(gomock.Any()).Return(&sessions.SessionID{ID: "session-id"}, nil)
+				mockRepo.EXPECT().MakeUser(gomock.Any(), "validUser", "validPass").Return(&user.User{}, nil)
+				mockSessions.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&sessions.SessionID{ID: "session-id"}, nil)
 			},
 			requestBody:  map[string]string{"username": "validUser", "password": "validPass"},
 			expectError:  true,
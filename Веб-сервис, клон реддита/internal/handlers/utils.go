@@ -1,41 +1,64 @@
 package handlers
 
 import (
-	"encoding/json"
-	"errors"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
 	"net/http"
+	"redditclone/internal/logging"
+	"redditclone/internal/notifications"
 	"redditclone/internal/posts"
+	"redditclone/internal/ratelimit"
+	"redditclone/internal/realtime"
 	"redditclone/internal/sessions"
 	"redditclone/internal/user"
+	"reflect"
 	"strings"
 	"time"
 )
 
 const (
-	ErrReading       = `{"message": "error reading request"}`
-	ErrUserNotFound  = `{"message":"user not found"}`
-	ErrInvalidPass   = `{"message":"invalid password"}`
-	ErrBadRequest    = `{"message": "bad request"}`
-	errConst         = "errors"
-	tokenConst       = "token"
-	ErrNoPayload     = `{"message": "no payload"}`
-	ErrUnauthorized  = `{"message": "unauthorized"}`
-	ErrBadSignMethod = `{"message": "bad sign method"}`
-	SuccessResponse  = `{"message": "success"}`
+	tokenConst = "token"
+	tracerName = "redditclone/internal/handlers"
 )
 
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+var SuccessResponse = messageResponse{Message: "success"}
+
+// requestLogger возвращает логгер текущего запроса (с request_id), а если его нет - логгер хендлера.
+func requestLogger(r *http.Request, fallback *zap.SugaredLogger) *zap.SugaredLogger {
+	return logging.FromContext(r.Context(), fallback)
+}
+
+// formValidator называет поля по тегу json, как их видит клиент.
+var formValidator = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return strings.ToLower(field.Name)
+		}
+		return name
+	})
+	return v
+}()
+
+// dataValidation проверяет форму по тегам validate. Сообщения те же, что у проверки по спецификации OpenAPI.
 func dataValidation(fd interface{}) []map[string]string {
-	if err := validator.New().Struct(fd); err != nil {
+	if err := formValidator.Struct(fd); err != nil {
 		var newErrors []map[string]string
 		for _, someErr := range err.(validator.ValidationErrors) {
 			newError := map[string]string{
 				"location": "body",
-				"param":    strings.ToLower(someErr.StructField()),
-				"msg":      "is required",
+				"param":    someErr.Field(),
+				"msg":      validationMessage(someErr),
 			}
 			newErrors = append(newErrors, newError)
 		}
@@ -45,6 +68,24 @@ func dataValidation(fd interface{}) []map[string]string {
 	return nil
 }
 
+func validationMessage(fe validator.FieldError) string {
+	unit := ""
+	if fe.Kind() == reflect.String {
+		unit = " characters"
+	}
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fe.Param() + unit
+	case "max":
+		return "must be at most " + fe.Param() + unit
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	}
+	return "is invalid"
+}
+
 func makeJWT(u *user.User, sess *sessions.SessionID) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user": user.User{
@@ -69,7 +110,7 @@ func getPayloadFromJWT(token string) (jwt.MapClaims, error) {
 
 		method, ok := token.Method.(*jwt.SigningMethodHMAC)
 		if !ok || method.Alg() != "HS256" {
-			return nil, errors.New(ErrBadSignMethod)
+			return nil, ErrBadSignMethod
 		}
 		return ExampleTokenSecret, nil
 	}
@@ -80,7 +121,7 @@ func getPayloadFromJWT(token string) (jwt.MapClaims, error) {
 
 	payload, ok := inToken.Claims.(jwt.MapClaims)
 	if !ok {
-		return nil, errors.New(ErrNoPayload)
+		return nil, ErrNoPayload
 	}
 
 	return payload, nil
@@ -89,82 +130,94 @@ func getPayloadFromJWT(token string) (jwt.MapClaims, error) {
 func authUser(r *http.Request, h *PostsHandler) (int64, string, error) {
 	token := r.Header.Get("Authorization")
 	if !strings.HasPrefix(token, "Bearer ") {
-		return 0, "", errors.New(ErrUnauthorized)
+		return 0, "", ErrUnauthorized
 	}
 
+	ctx, span := otel.Tracer(tracerName).Start(r.Context(), "authUser")
+	defer span.End()
+
+	_, jwtSpan := otel.Tracer(tracerName).Start(ctx, "jwt.Verify")
 	payload, err := getPayloadFromJWT(token[7:])
 	if err != nil {
-		return 0, "", errors.New(ErrUnauthorized)
+		jwtSpan.SetStatus(codes.Error, "invalid token")
+		jwtSpan.End()
+		return 0, "", ErrUnauthorized
 	}
+	jwtSpan.End()
 
 	session, ok := payload["session"].(string)
 	if !ok {
-		return 0, "", errors.New(ErrUnauthorized)
+		return 0, "", ErrUnauthorized
 	}
 
 	userInfo, ok := payload["user"].(map[string]interface{})
 	if !ok {
-		return 0, "", errors.New(ErrUnauthorized)
+		return 0, "", ErrUnauthorized
 	}
 
 	username, ok := userInfo["username"].(string)
 	if !ok {
-		return 0, "", errors.New(ErrUnauthorized)
+		return 0, "", ErrUnauthorized
 	}
 	floatID, ok := userInfo["id"].(float64)
 	if !ok {
-		return 0, "", errors.New(ErrUnauthorized)
+		return 0, "", ErrUnauthorized
 	}
 	id := int64(floatID)
 
-	sess := h.Sessions.Check(&sessions.SessionID{ID: session})
+	sess := h.Sessions.Check(ctx, &sessions.SessionID{ID: session})
 	if sess == nil {
-		return 0, "", errors.New(ErrUnauthorized)
+		return 0, "", ErrUnauthorized
 	}
 
 	if sess.ID == id && sess.Login == username {
 		return id, username, nil
 	}
 
-	return 0, "", errors.New(ErrUnauthorized)
+	return 0, "", ErrUnauthorized
 }
 
 func votePostHandler(w http.ResponseWriter, r *http.Request, h *PostsHandler, vote int) {
-
 	objID := mux.Vars(r)["POST_ID"]
+	logger := requestLogger(r, h.Logger).With("post_id", objID, "vote", vote)
+
 	postID, err := primitive.ObjectIDFromHex(objID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, logger, ErrInvalidID)
 		return
 	}
 
-	userID, _, err := authUser(r, h)
+	userID, username, err := authUser(r, h)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, r, logger, err)
 		return
 	}
+	logger = logger.With("user_id", userID)
 
-	var post *posts.Post
-
-	if vote == 0 {
-		post, err = h.PostsRepo.UnVotePost(postID, userID)
-	} else {
-		post, err = h.PostsRepo.VotePost(postID, userID, vote)
-	}
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	if !h.allowRequest(w, r, logger, ratelimit.RouteVote, userID) {
 		return
 	}
 
-	resp, err := json.Marshal(post)
+	post, err := h.vote(r, logger, postID, userID, username, vote)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, logger, err)
 		return
 	}
 
-	_, err = w.Write(resp)
+	logger.Infow("post voted", "score", post.Score)
+	writeJSON(w, logger, http.StatusOK, post)
+}
+
+// vote записывает голос и рассылает о нём события. Общая часть голосования через REST и GraphQL.
+func (h *PostsHandler) vote(r *http.Request, logger *zap.SugaredLogger, postID primitive.ObjectID, userID int64, username string, vote int) (*posts.Post, error) {
+	post, err := h.PostsRepo.VotePost(r.Context(), postID, userID, vote)
 	if err != nil {
-		h.Logger.Errorln(err.Error())
+		return nil, err
 	}
 
+	if vote > 0 && notifications.MilestoneCandidate(post.Score) {
+		h.notify(notifications.Event{Kind: notifications.EventVote, Actor: user.User{ID: userID, Username: username}, Post: post})
+	}
+	h.broadcast(r, logger, realtime.EventVoteChanged, post)
+	return post, nil
 }
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"redditclone/internal/posts"
+	"redditclone/internal/ratelimit"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// recordView засчитывает просмотр поста и добавляет к ответу ещё не сброшенные в базу просмотры.
+// Ошибки учёта только логируются - из-за них пост не должен перестать открываться.
+func (h *PostsHandler) recordView(r *http.Request, logger *zap.SugaredLogger, post *posts.Post) {
+	if h.Views == nil {
+		return
+	}
+
+	if _, err := h.Views.Record(r.Context(), post.ID, h.viewerKey(r)); err != nil {
+		logger.Warnw("failed to record view", "error", err)
+	}
+	post.Views += h.Views.Pending(post.ID)
+}
+
+// viewerKey определяет зрителя: пользователя из токена, а для анонимов - IP вместе с User-Agent.
+// Сессию в redis здесь не проверяем, для подсчёта просмотров хватает подписи токена.
+func (h *PostsHandler) viewerKey(r *http.Request) string {
+	token := r.Header.Get("Authorization")
+	if strings.HasPrefix(token, "Bearer ") {
+		if payload, err := getPayloadFromJWT(token[7:]); err == nil {
+			if userInfo, ok := payload["user"].(map[string]interface{}); ok {
+				if id, ok := userInfo["id"].(float64); ok {
+					return "user:" + strconv.FormatInt(int64(id), 10)
+				}
+			}
+		}
+	}
+
+	sum := sha256.Sum256([]byte(ratelimit.ClientIP(r, h.TrustProxy) + "|" + r.UserAgent()))
+	return "anon:" + hex.EncodeToString(sum[:8])
+}
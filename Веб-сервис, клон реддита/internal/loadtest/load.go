@@ -0,0 +1,300 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scenario - что делает виртуальный пользователь за один шаг.
+type Scenario string
+
+const (
+	// ScenarioBrowse - открыть ленту: все посты, категорию или автора, а затем один пост.
+	ScenarioBrowse Scenario = "browse"
+	// ScenarioVote - проголосовать за пост.
+	ScenarioVote Scenario = "vote"
+	// ScenarioComment - прокомментировать пост.
+	ScenarioComment Scenario = "comment"
+)
+
+// Mix - веса сценариев.
+type Mix map[Scenario]int
+
+// ParseMix читает веса вида "browse=80,vote=15,comment=5".
+func ParseMix(s string) (Mix, error) {
+	mix := Mix{}
+	for _, part := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("scenario %q: want name=weight", part)
+		}
+		scenario := Scenario(name)
+		switch scenario {
+		case ScenarioBrowse, ScenarioVote, ScenarioComment:
+		default:
+			return nil, fmt.Errorf("unknown scenario %q", name)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("scenario %s: bad weight %q", name, weight)
+		}
+		mix[scenario] = w
+	}
+	return mix, nil
+}
+
+// pick выбирает сценарий пропорционально весам.
+func (m Mix) pick(rnd *rand.Rand) Scenario {
+	// Порядок обхода map случаен, а выбор должен зависеть только от rnd.
+	names := make([]Scenario, 0, len(m))
+	total := 0
+	for name, w := range m {
+		names = append(names, name)
+		total += w
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	n := rnd.Intn(total)
+	for _, name := range names {
+		if n < m[name] {
+			return name
+		}
+		n -= m[name]
+	}
+	return names[len(names)-1]
+}
+
+func (m Mix) total() int {
+	total := 0
+	for _, w := range m {
+		total += w
+	}
+	return total
+}
+
+type LoadConfig struct {
+	// BaseURL - адрес сервера, например http://localhost:8080.
+	BaseURL string
+	Mix     Mix
+	// Workers - сколько виртуальных пользователей шлют запросы параллельно, каждый под своим логином.
+	Workers int
+	// Duration и Iterations ограничивают прогон: что наступит раньше. 0 - без ограничения.
+	// Итерация - один шаг сценария, в browse это два запроса.
+	Duration   time.Duration
+	Iterations int
+	// Под какими пользователями входить: сгенерированные сидером UserPrefix1..UserPrefixN.
+	Users      int
+	UserPrefix string
+	Password   string
+	Seed       int64
+}
+
+// Runner гоняет сценарии через публичный API. Лимиты частоты на сервере для прогона
+// стоит поднять, иначе большая часть записей упрётся в 429.
+type Runner struct {
+	Client *http.Client
+	Config LoadConfig
+}
+
+// target - пост, над которым работают сценарии.
+type target struct {
+	id       string
+	category string
+	author   string
+}
+
+// worker - виртуальный пользователь.
+type worker struct {
+	r     *Runner
+	rnd   *rand.Rand
+	token string
+	rec   *recorder
+}
+
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	cfg := r.Config
+	if cfg.Mix.total() == 0 {
+		return nil, errors.New("scenario mix is empty")
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Users <= 0 {
+		return nil, errors.New("load test needs at least one seeded user")
+	}
+	if cfg.Duration == 0 && cfg.Iterations == 0 {
+		return nil, errors.New("load test needs a duration or an iteration limit")
+	}
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	rec := newRecorder()
+	setup := &worker{r: r, rnd: rand.New(rand.NewSource(cfg.Seed)), rec: newRecorder()}
+	targets, err := setup.targets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing posts: %w", err)
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no posts to work with, seed the database first")
+	}
+
+	workers := make([]*worker, cfg.Workers)
+	for i := range workers {
+		w := &worker{r: r, rnd: rand.New(rand.NewSource(cfg.Seed + int64(i) + 1)), rec: rec}
+		username := Username(cfg.UserPrefix, i%cfg.Users+1)
+		if w.token, err = setup.login(ctx, username, cfg.Password); err != nil {
+			return nil, fmt.Errorf("login %s: %w", username, err)
+		}
+		workers[i] = w
+	}
+
+	start := time.Now()
+	var (
+		wg      sync.WaitGroup
+		started int64
+	)
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if cfg.Iterations > 0 && atomic.AddInt64(&started, 1) > int64(cfg.Iterations) {
+					return
+				}
+				w.step(ctx, cfg.Mix.pick(w.rnd), targets)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	return rec.report(time.Since(start)), nil
+}
+
+func (w *worker) step(ctx context.Context, scenario Scenario, targets []target) {
+	t := targets[w.rnd.Intn(len(targets))]
+	switch scenario {
+	case ScenarioBrowse:
+		switch w.rnd.Intn(3) {
+		case 0:
+			w.do(ctx, "GET /api/posts/", "GET", "/api/posts/", nil)
+		case 1:
+			w.do(ctx, "GET /api/posts/{category}", "GET", "/api/posts/"+t.category, nil)
+		default:
+			w.do(ctx, "GET /api/user/{login}", "GET", "/api/user/"+t.author, nil)
+		}
+		w.do(ctx, "GET /api/post/{id}", "GET", "/api/post/"+t.id, nil)
+	case ScenarioVote:
+		action := "upvote"
+		if w.rnd.Float64() >= upvoteShare {
+			action = "downvote"
+		}
+		w.do(ctx, "POST /api/post/{id}/"+action, "POST", "/api/post/"+t.id+"/"+action, nil)
+	case ScenarioComment:
+		// Текст с номером, чтобы не сработала защита от одинаковых комментариев.
+		body := map[string]string{"comment": "load test comment " + strconv.FormatInt(w.rnd.Int63(), 36)}
+		w.do(ctx, "POST /api/post/{id}", "POST", "/api/post/"+t.id, body)
+	}
+}
+
+// do отправляет запрос и записывает его задержку под именем op. Возвращает тело успешного ответа.
+func (w *worker) do(ctx context.Context, op, method, path string, body interface{}) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(w.r.Config.BaseURL, "/")+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	start := time.Now()
+	resp, err := w.r.client().Do(req)
+	if err != nil {
+		// Запросы, оборванные концом прогона, не считаются.
+		if ctx.Err() == nil {
+			w.rec.record(op, time.Since(start), 0)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	w.rec.record(op, time.Since(start), resp.StatusCode)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	return data, nil
+}
+
+func (r *Runner) client() *http.Client {
+	if r.Client != nil {
+		return r.Client
+	}
+	return http.DefaultClient
+}
+
+func (w *worker) login(ctx context.Context, username, password string) (string, error) {
+	data, err := w.do(ctx, "POST /api/login", "POST", "/api/login", map[string]string{"username": username, "password": password})
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return "", err
+	}
+	return resp.Token, nil
+}
+
+// targets читает все посты, чтобы сценарии ходили по настоящим id, категориям и авторам.
+func (w *worker) targets(ctx context.Context) ([]target, error) {
+	data, err := w.do(ctx, "GET /api/posts/", "GET", "/api/posts/", nil)
+	if err != nil {
+		return nil, err
+	}
+	var list []struct {
+		ID       string `json:"id"`
+		Category string `json:"category"`
+		Author   struct {
+			Username string `json:"username"`
+		} `json:"author"`
+	}
+	if err = json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	targets := make([]target, 0, len(list))
+	for _, p := range list {
+		targets = append(targets, target{id: p.ID, category: p.Category, author: p.Author.Username})
+	}
+	return targets, nil
+}
@@ -0,0 +1,287 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/posts"
+	"redditclone/internal/user"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseMix(t *testing.T) {
+	testCases := []struct {
+		name    string
+		in      string
+		want    Mix
+		wantErr string
+	}{
+		{name: "Все сценарии", in: "browse=80, vote=15,comment=5", want: Mix{ScenarioBrowse: 80, ScenarioVote: 15, ScenarioComment: 5}},
+		{name: "Один сценарий", in: "vote=1", want: Mix{ScenarioVote: 1}},
+		{name: "Без веса", in: "browse", wantErr: "want name=weight"},
+		{name: "Неизвестный сценарий", in: "delete=1", wantErr: "unknown scenario"},
+		{name: "Отрицательный вес", in: "vote=-1", wantErr: "bad weight"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mix, err := ParseMix(tc.in)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, mix)
+		})
+	}
+}
+
+func TestMixPick(t *testing.T) {
+	mix := Mix{ScenarioBrowse: 3, ScenarioVote: 1, ScenarioComment: 0}
+	rnd := rand.New(rand.NewSource(1))
+
+	picked := map[Scenario]int{}
+	for i := 0; i < 4000; i++ {
+		picked[mix.pick(rnd)]++
+	}
+	assert.Zero(t, picked[ScenarioComment])
+	assert.InDelta(t, 3000, picked[ScenarioBrowse], 150)
+	assert.InDelta(t, 1000, picked[ScenarioVote], 150)
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	assert.Equal(t, 50*time.Millisecond, percentile(sorted, 50))
+	assert.Equal(t, 99*time.Millisecond, percentile(sorted, 99))
+	assert.Equal(t, 100*time.Millisecond, percentile(sorted, 100))
+	assert.Equal(t, time.Millisecond, percentile(sorted[:1], 99))
+	assert.Zero(t, percentile(nil, 50))
+}
+
+func TestRecorderReport(t *testing.T) {
+	rec := newRecorder()
+	rec.record("GET /api/posts/", 10*time.Millisecond, http.StatusOK)
+	rec.record("GET /api/posts/", 30*time.Millisecond, http.StatusInternalServerError)
+	rec.record("POST /api/post/{id}", 20*time.Millisecond, http.StatusTooManyRequests)
+	rec.record("POST /api/post/{id}", 40*time.Millisecond, 0)
+
+	report := rec.report(2 * time.Second)
+
+	require.Len(t, report.Ops, 2)
+	assert.Equal(t, &OpStats{Op: "GET /api/posts/", Requests: 2, Errors: 1, ErrorRate: 0.5,
+		P50: 10 * time.Millisecond, P90: 30 * time.Millisecond, P99: 30 * time.Millisecond, Max: 30 * time.Millisecond}, report.Ops[0])
+	assert.Equal(t, 1, report.Ops[1].Throttled, "429 is not an error")
+	assert.Equal(t, 1, report.Ops[1].Errors, "no response is an error")
+	assert.Equal(t, 4, report.Total.Requests)
+	assert.Equal(t, 2, report.Total.Errors)
+	assert.Equal(t, 20*time.Millisecond, report.Total.P50)
+	assert.Equal(t, 2.0, report.RPS)
+	assert.Contains(t, report.String(), "4 requests in 2s, 2.0 req/s")
+}
+
+// fakeAPI отвечает на запросы сценариев как сервер и запоминает, что к нему приходило.
+type fakeAPI struct {
+	mu       sync.Mutex
+	requests map[string]int
+	tokens   map[string]bool
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := r.Method + " " + r.URL.Path
+	f.requests[key]++
+
+	switch {
+	case key == "POST /api/login":
+		var form map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&form)
+		if form["password"] != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"token":"token-` + form["username"] + `"}`))
+		return
+	case key == "GET /api/posts/":
+		_, _ = w.Write([]byte(`[{"id":"p1","category":"music","author":{"username":"load1"}}]`))
+		return
+	}
+
+	f.tokens[r.Header.Get("Authorization")] = true
+	switch key {
+	case "GET /api/posts/music", "GET /api/user/load1", "GET /api/post/p1":
+		_, _ = w.Write([]byte(`{}`))
+	case "POST /api/post/p1/upvote", "POST /api/post/p1/downvote":
+		w.WriteHeader(http.StatusInternalServerError)
+	case "POST /api/post/p1":
+		w.WriteHeader(http.StatusTooManyRequests)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	api := &fakeAPI{requests: map[string]int{}, tokens: map[string]bool{}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	runner := &Runner{
+		Client: server.Client(),
+		Config: LoadConfig{
+			BaseURL:    server.URL + "/",
+			Mix:        Mix{ScenarioBrowse: 2, ScenarioVote: 1, ScenarioComment: 1},
+			Workers:    3,
+			Iterations: 200,
+			Users:      2,
+			UserPrefix: "load",
+			Password:   "secret",
+			Seed:       1,
+		},
+	}
+
+	report, err := runner.Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, api.requests["POST /api/login"], "each worker logs in")
+	assert.Equal(t, map[string]bool{"Bearer token-load1": true, "Bearer token-load2": true}, api.tokens)
+
+	ops := map[string]*OpStats{}
+	for _, s := range report.Ops {
+		ops[s.Op] = s
+	}
+	// Каждая итерация - один запрос, кроме browse: он ещё открывает пост.
+	assert.Equal(t, 200, report.Total.Requests-ops["GET /api/post/{id}"].Requests)
+	assert.NotContains(t, ops, "POST /api/login", "setup requests are not measured")
+	assert.Equal(t, ops["POST /api/post/{id}"].Requests, ops["POST /api/post/{id}"].Throttled)
+	assert.Equal(t, 1.0, ops["POST /api/post/{id}/upvote"].ErrorRate)
+	assert.Zero(t, ops["GET /api/post/{id}"].Errors)
+}
+
+func TestRunnerRunErrors(t *testing.T) {
+	api := &fakeAPI{requests: map[string]int{}, tokens: map[string]bool{}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	base := LoadConfig{BaseURL: server.URL, Mix: Mix{ScenarioBrowse: 1}, Users: 1, UserPrefix: "load", Password: "secret", Iterations: 1}
+	testCases := []struct {
+		name    string
+		change  func(cfg *LoadConfig)
+		wantErr string
+	}{
+		{name: "Пустая смесь", change: func(cfg *LoadConfig) { cfg.Mix = Mix{ScenarioVote: 0} }, wantErr: "mix is empty"},
+		{name: "Без пользователей", change: func(cfg *LoadConfig) { cfg.Users = 0 }, wantErr: "seeded user"},
+		{name: "Без ограничения", change: func(cfg *LoadConfig) { cfg.Iterations = 0 }, wantErr: "duration or an iteration limit"},
+		{name: "Неверный пароль", change: func(cfg *LoadConfig) { cfg.Password = "wrong" }, wantErr: "login load1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := base
+			tc.change(&cfg)
+			_, err := (&Runner{Client: server.Client(), Config: cfg}).Run(context.Background())
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestSeederRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	users := user.NewMockUserRepo(ctrl)
+	postsRepo := posts.NewMockPostRepo(ctrl)
+
+	users.EXPECT().MakeUser(gomock.Any(), "seed1", "secret").Return(&user.User{ID: 1, Username: "seed1", Password: "hash"}, nil)
+	users.EXPECT().MakeUser(gomock.Any(), "seed2", "secret").Return(nil, user.ErrExists)
+	users.EXPECT().GetUser(gomock.Any(), "seed2").Return(&user.User{ID: 2, Username: "seed2"}, nil)
+	users.EXPECT().MakeUser(gomock.Any(), "seed3", "secret").Return(&user.User{ID: 3, Username: "seed3"}, nil)
+
+	var (
+		mu       sync.Mutex
+		made     int
+		comments int
+		voters   = map[primitive.ObjectID]map[int64]bool{}
+	)
+	postsRepo.EXPECT().MakePost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, form *posts.PostForm, username string, userID int64) (*posts.Post, error) {
+			assert.Contains(t, []string{"music", "news"}, form.Category)
+			assert.Contains(t, []string{posts.TypeText, posts.TypeLink}, form.Type)
+			assert.Equal(t, "seed", username[:4])
+			mu.Lock()
+			defer mu.Unlock()
+			made++
+			return &posts.Post{ID: primitive.NewObjectID()}, nil
+		}).Times(20)
+	postsRepo.EXPECT().MakeComment(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, postID, parentID primitive.ObjectID, body, username string, userID int64) (*posts.Post, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			comments++
+			return &posts.Post{ID: postID, Comments: []*posts.Comment{{ID: primitive.NewObjectID()}}}, nil
+		}).AnyTimes()
+	postsRepo.EXPECT().VotePost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, postID primitive.ObjectID, userID int64, vote int) (*posts.Post, error) {
+			assert.Contains(t, []int{-1, 1}, vote)
+			mu.Lock()
+			defer mu.Unlock()
+			if voters[postID] == nil {
+				voters[postID] = map[int64]bool{}
+			}
+			assert.False(t, voters[postID][userID], "one vote per user and post")
+			voters[postID][userID] = true
+			return &posts.Post{ID: postID}, nil
+		}).AnyTimes()
+
+	seeder := &Seeder{Users: users, Posts: postsRepo, Config: SeedConfig{
+		Users:           3,
+		Posts:           20,
+		CommentsPerPost: 2,
+		VotesPerPost:    2,
+		Categories:      []string{"music", "news"},
+		UserPrefix:      "seed",
+		Password:        "secret",
+		Workers:         4,
+		Seed:            1,
+	}}
+
+	res, err := seeder.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Users)
+	assert.Equal(t, 20, res.Posts)
+	assert.Equal(t, comments, res.Comments)
+	votes := 0
+	for _, v := range voters {
+		votes += len(v)
+	}
+	assert.Equal(t, votes, res.Votes)
+	assert.Equal(t, 20, made)
+}
+
+func TestSeederRunStopsOnError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	users := user.NewMockUserRepo(ctrl)
+	postsRepo := posts.NewMockPostRepo(ctrl)
+	users.EXPECT().MakeUser(gomock.Any(), "seed1", "secret").Return(&user.User{ID: 1, Username: "seed1"}, nil)
+	postsRepo.EXPECT().MakePost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, posts.ErrStorage).MinTimes(1)
+
+	seeder := &Seeder{Users: users, Posts: postsRepo, Config: SeedConfig{Users: 1, Posts: 100, UserPrefix: "seed", Password: "secret", Workers: 2}}
+
+	res, err := seeder.Run(context.Background())
+	assert.ErrorIs(t, err, posts.ErrStorage)
+	assert.Zero(t, res.Posts)
+}
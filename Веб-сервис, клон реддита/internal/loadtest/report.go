@@ -0,0 +1,139 @@
+package loadtest
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// recorder копит задержки и коды ответов по операциям.
+type recorder struct {
+	mu  sync.Mutex
+	ops map[string]*opSamples
+}
+
+type opSamples struct {
+	latencies []time.Duration
+	errors    int
+	throttled int
+}
+
+func newRecorder() *recorder {
+	return &recorder{ops: map[string]*opSamples{}}
+}
+
+// record учитывает один запрос. status 0 - ответа не было.
+func (r *recorder) record(op string, latency time.Duration, status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.ops[op]
+	if !ok {
+		s = &opSamples{}
+		r.ops[op] = s
+	}
+	s.latencies = append(s.latencies, latency)
+	switch {
+	case status == http.StatusTooManyRequests:
+		// Упёрлись в лимит частоты - это настройка сервера, а не сбой.
+		s.throttled++
+	case status == 0 || status >= http.StatusBadRequest:
+		s.errors++
+	}
+}
+
+// OpStats - задержки одной операции. Ошибки - всё, кроме 2xx/3xx и 429.
+type OpStats struct {
+	Op        string        `json:"op"`
+	Requests  int           `json:"requests"`
+	Errors    int           `json:"errors"`
+	Throttled int           `json:"throttled"`
+	ErrorRate float64       `json:"errorRate"`
+	P50       time.Duration `json:"p50"`
+	P90       time.Duration `json:"p90"`
+	P99       time.Duration `json:"p99"`
+	Max       time.Duration `json:"max"`
+}
+
+type Report struct {
+	Elapsed time.Duration `json:"elapsed"`
+	// RPS - запросов в секунду по всем операциям.
+	RPS   float64    `json:"rps"`
+	Total OpStats    `json:"total"`
+	Ops   []*OpStats `json:"ops"`
+}
+
+func (r *recorder) report(elapsed time.Duration) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{Elapsed: elapsed, Ops: []*OpStats{}}
+	all := &opSamples{}
+	for op, s := range r.ops {
+		report.Ops = append(report.Ops, s.stats(op))
+		all.latencies = append(all.latencies, s.latencies...)
+		all.errors += s.errors
+		all.throttled += s.throttled
+	}
+	sort.Slice(report.Ops, func(i, j int) bool { return report.Ops[i].Op < report.Ops[j].Op })
+	report.Total = *all.stats("total")
+	if elapsed > 0 {
+		report.RPS = float64(report.Total.Requests) / elapsed.Seconds()
+	}
+	return report
+}
+
+func (s *opSamples) stats(op string) *OpStats {
+	sorted := append([]time.Duration{}, s.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	stats := &OpStats{
+		Op:        op,
+		Requests:  len(sorted),
+		Errors:    s.errors,
+		Throttled: s.throttled,
+		P50:       percentile(sorted, 50),
+		P90:       percentile(sorted, 90),
+		P99:       percentile(sorted, 99),
+		Max:       percentile(sorted, 100),
+	}
+	if len(sorted) > 0 {
+		stats.ErrorRate = float64(s.errors) / float64(len(sorted))
+	}
+	return stats
+}
+
+// percentile по ближайшему рангу: наименьшее значение, не меньше которого p% выборки.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "OP\tREQUESTS\tERRORS\tTHROTTLED\tP50\tP90\tP99\tMAX")
+	for _, s := range append(append([]*OpStats{}, r.Ops...), &r.Total) {
+		fmt.Fprintf(w, "%s\t%d\t%.2f%%\t%d\t%s\t%s\t%s\t%s\n", s.Op, s.Requests, s.ErrorRate*100, s.Throttled,
+			round(s.P50), round(s.P90), round(s.P99), round(s.Max))
+	}
+	w.Flush()
+	fmt.Fprintf(&b, "%d requests in %s, %.1f req/s", r.Total.Requests, r.Elapsed.Round(time.Millisecond), r.RPS)
+	return b.String()
+}
+
+func round(d time.Duration) time.Duration {
+	if d > time.Millisecond {
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
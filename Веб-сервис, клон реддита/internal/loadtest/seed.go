@@ -0,0 +1,245 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"redditclone/internal/posts"
+	"redditclone/internal/user"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Categories - категории фронтенда.
+var Categories = []string{"music", "funny", "videos", "programming", "news", "fashion"}
+
+// upvoteShare - доля голосов +1: на реальных площадках минусуют заметно реже.
+const upvoteShare = 0.75
+
+// replyShare - доля комментариев, которые отвечают на другой комментарий, а не на пост.
+const replyShare = 0.3
+
+type SeedConfig struct {
+	Users int
+	Posts int
+	// Среднее число комментариев и голосов на пост. Распределение экспоненциальное:
+	// у большинства постов мало активности, у немногих - очень много.
+	CommentsPerPost float64
+	VotesPerPost    float64
+	Categories      []string
+	// Пользователи называются UserPrefix1..UserPrefixN, у всех пароль Password,
+	// чтобы генератор нагрузки мог войти под ними.
+	UserPrefix string
+	Password   string
+	Workers    int
+	// Seed - зерно случайных чисел: одно и то же зерно даёт одно и то же распределение.
+	Seed int64
+}
+
+type SeedResult struct {
+	Users    int           `json:"users"`
+	Posts    int           `json:"posts"`
+	Comments int           `json:"comments"`
+	Votes    int           `json:"votes"`
+	Elapsed  time.Duration `json:"elapsed"`
+}
+
+func (r *SeedResult) String() string {
+	return fmt.Sprintf("seeded %d users, %d posts, %d comments, %d votes in %s",
+		r.Users, r.Posts, r.Comments, r.Votes, r.Elapsed.Round(time.Millisecond))
+}
+
+// Seeder наполняет базу напрямую через репозитории: через API мешали бы лимиты частоты.
+// Записи в обход сервера не сбрасывают его кеш постов, новые посты появятся в списках через POSTS_CACHE_TTL.
+type Seeder struct {
+	Users  user.UserRepo
+	Posts  posts.PostRepo
+	Config SeedConfig
+}
+
+// Username - логин i-го сгенерированного пользователя, i с единицы.
+func Username(prefix string, i int) string {
+	return prefix + strconv.Itoa(i)
+}
+
+func (s *Seeder) Run(ctx context.Context) (*SeedResult, error) {
+	cfg := s.Config
+	if cfg.Users <= 0 {
+		return nil, errors.New("seed needs at least one user")
+	}
+	if len(cfg.Categories) == 0 {
+		cfg.Categories = Categories
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	start := time.Now()
+
+	users, err := s.makeUsers(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	res := &SeedResult{Users: len(users)}
+
+	var (
+		comments, votes int64
+		made            int64
+		next            int64 = -1
+		wg              sync.WaitGroup
+		errOnce         sync.Once
+		firstErr        error
+	)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for w := 0; w < cfg.Workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// rand.Rand не потокобезопасен, у каждого воркера свой.
+			g := newGenerator(cfg, users, cfg.Seed+int64(w))
+			for atomic.AddInt64(&next, 1) < int64(cfg.Posts) {
+				c, v, err := s.makePost(ctx, g)
+				if err != nil {
+					errOnce.Do(func() { firstErr = err; cancel() })
+					return
+				}
+				atomic.AddInt64(&made, 1)
+				atomic.AddInt64(&comments, int64(c))
+				atomic.AddInt64(&votes, int64(v))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	res.Posts, res.Comments, res.Votes = int(made), int(comments), int(votes)
+	res.Elapsed = time.Since(start)
+	return res, firstErr
+}
+
+// makeUsers создаёт пользователей. Уже существующие берутся как есть, так что посев можно повторять.
+func (s *Seeder) makeUsers(ctx context.Context, cfg SeedConfig) ([]*user.User, error) {
+	users := make([]*user.User, 0, cfg.Users)
+	for i := 1; i <= cfg.Users; i++ {
+		name := Username(cfg.UserPrefix, i)
+		u, err := s.Users.MakeUser(ctx, name, cfg.Password)
+		if errors.Is(err, user.ErrExists) {
+			u, err = s.Users.GetUser(ctx, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", name, err)
+		}
+		users = append(users, &user.User{ID: u.ID, Username: u.Username})
+	}
+	return users, nil
+}
+
+// makePost публикует пост со случайными комментариями и голосами.
+func (s *Seeder) makePost(ctx context.Context, g *generator) (comments, votes int, err error) {
+	author := g.author()
+	post, err := s.Posts.MakePost(ctx, g.postForm(), author.Username, author.ID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("post: %w", err)
+	}
+
+	var commentIDs []primitive.ObjectID
+	for n := g.count(g.cfg.CommentsPerPost, math.MaxInt32); comments < n; comments++ {
+		parentID := primitive.NilObjectID
+		if len(commentIDs) > 0 && g.rnd.Float64() < replyShare {
+			parentID = commentIDs[g.rnd.Intn(len(commentIDs))]
+		}
+		commenter := g.author()
+		updated, err := s.Posts.MakeComment(ctx, post.ID, parentID, g.sentence(4, 30), commenter.Username, commenter.ID)
+		if err != nil {
+			return comments, votes, fmt.Errorf("comment: %w", err)
+		}
+		commentIDs = append(commentIDs, updated.Comments[len(updated.Comments)-1].ID)
+	}
+
+	// Один пользователь голосует за пост один раз, поэтому голосующие - без повторов.
+	n := g.count(g.cfg.VotesPerPost, len(g.users))
+	for _, i := range g.rnd.Perm(len(g.users))[:n] {
+		vote := -1
+		if g.rnd.Float64() < upvoteShare {
+			vote = 1
+		}
+		if _, err := s.Posts.VotePost(ctx, post.ID, g.users[i].ID, vote); err != nil {
+			return comments, votes, fmt.Errorf("vote: %w", err)
+		}
+		votes++
+	}
+	return comments, votes, nil
+}
+
+// generator выдаёт случайное содержимое. Авторы и категории распределены по Ципфу:
+// немногие пользователи пишут большую часть постов, и немногие категории популярнее остальных.
+type generator struct {
+	cfg        SeedConfig
+	users      []*user.User
+	rnd        *rand.Rand
+	authors    *rand.Zipf
+	categories *rand.Zipf
+}
+
+func newGenerator(cfg SeedConfig, users []*user.User, seed int64) *generator {
+	rnd := rand.New(rand.NewSource(seed))
+	return &generator{
+		cfg:        cfg,
+		users:      users,
+		rnd:        rnd,
+		authors:    rand.NewZipf(rnd, 1.1, 1, uint64(len(users)-1)),
+		categories: rand.NewZipf(rnd, 1.1, 1, uint64(len(cfg.Categories)-1)),
+	}
+}
+
+func (g *generator) author() *user.User {
+	return g.users[g.authors.Uint64()]
+}
+
+// count - случайное число с экспоненциальным распределением и средним mean, не больше limit.
+func (g *generator) count(mean float64, limit int) int {
+	if mean <= 0 {
+		return 0
+	}
+	n := int(g.rnd.ExpFloat64() * mean)
+	if n > limit {
+		n = limit
+	}
+	return n
+}
+
+func (g *generator) postForm() *posts.PostForm {
+	form := &posts.PostForm{
+		Title:    g.sentence(3, 12),
+		Category: g.cfg.Categories[g.categories.Uint64()],
+	}
+	if g.rnd.Float64() < 0.2 {
+		form.Type = posts.TypeLink
+		form.URL = "https://example.com/" + strings.ReplaceAll(g.sentence(2, 5), " ", "-")
+		return form
+	}
+	form.Type = posts.TypeText
+	form.Text = g.sentence(10, 120)
+	return form
+}
+
+var words = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor
+incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation ullamco laboris
+nisi aliquip ex ea commodo consequat duis aute irure in reprehenderit voluptate velit esse cillum fugiat
+nulla pariatur excepteur sint occaecat cupidatat non proident sunt culpa qui officia deserunt mollit anim id est`)
+
+// sentence - от min до max случайных слов.
+func (g *generator) sentence(min, max int) string {
+	n := min + g.rnd.Intn(max-min+1)
+	out := make([]string, n)
+	for i := range out {
+		out[i] = words[g.rnd.Intn(len(words))]
+	}
+	return strings.Join(out, " ")
+}
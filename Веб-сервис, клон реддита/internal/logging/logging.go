@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type ctxKey int
+
+const (
+	loggerKey ctxKey = iota
+	requestIDKey
+)
+
+// NewContext кладёт логгер запроса в контекст.
+func NewContext(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext достаёт логгер запроса из контекста.
+// Если логгера в контексте нет, возвращается fallback, а если нет и его - пустой логгер.
+func FromContext(ctx context.Context, fallback *zap.SugaredLogger) *zap.SugaredLogger {
+	if logger, ok := ctx.Value(loggerKey).(*zap.SugaredLogger); ok && logger != nil {
+		return logger
+	}
+	if fallback != nil {
+		return fallback
+	}
+	return zap.NewNop().Sugar()
+}
+
+// WithRequestID сохраняет идентификатор запроса в контексте.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID возвращает идентификатор запроса или пустую строку.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
@@ -0,0 +1,133 @@
+package markup
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// LinkRel - rel всех ссылок в пользовательском тексте.
+const LinkRel = "nofollow ugc"
+
+// mentionRe находит u/логин и r/категория (можно с ведущим слэшем) в начале текста или после символа,
+// который не может быть частью имени. Группы: 1 - упоминание целиком, 2 - u или r, 3 - имя.
+var mentionRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_/@.-])(/?([ur])/([\p{L}\p{N}_-]+))`)
+
+// mentionPaths - куда ведут упоминания: маршруты фронтенда для пользователя и категории.
+var mentionPaths = map[string]string{"u": "/u/", "r": "/a/"}
+
+// Renderer превращает Markdown в безопасный HTML: goldmark рендерит без сырого HTML,
+// а bluemonday оставляет только теги и атрибуты из списка.
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+}
+
+func New() *Renderer {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.Strikethrough, extension.Table, extension.Linkify),
+		goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(linkTransformer{}, 100))),
+	)
+	return &Renderer{md: md, policy: Policy()}
+}
+
+// Policy - список разрешённого HTML. Картинок нет намеренно: чужие картинки следят за читателями.
+func Policy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "em", "strong", "del", "blockquote", "pre", "code",
+		"ul", "ol", "li", "h1", "h2", "h3", "h4", "h5", "h6", "table", "thead", "tbody", "tr", "th", "td")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("rel").Matching(regexp.MustCompile(`^` + LinkRel + `$`)).OnElements("a")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowStandardURLs()
+	p.RequireNoFollowOnLinks(true)
+	return p
+}
+
+// Render возвращает HTML для исходника. Пустой исходник - пустой HTML.
+func (r *Renderer) Render(source string) string {
+	if source == "" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := r.md.Convert([]byte(source), &buf); err != nil {
+		// goldmark ошибается только на записи в буфер, но экранированный текст лучше пустоты.
+		return r.policy.Sanitize("<p>" + source + "</p>")
+	}
+	return r.policy.Sanitize(buf.String())
+}
+
+var defaultRenderer = New()
+
+// Render рендерит исходник общим рендерером. Безопасен для одновременного использования.
+func Render(source string) string {
+	return defaultRenderer.Render(source)
+}
+
+// linkTransformer ставит rel ссылкам и превращает упоминания в ссылки. Код и текст ссылок не трогает.
+type linkTransformer struct{}
+
+func (linkTransformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	source := reader.Source()
+	var texts []*ast.Text
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Link, *ast.AutoLink:
+			n.SetAttributeString("rel", []byte(LinkRel))
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeSpan, *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			texts = append(texts, n)
+		}
+		return ast.WalkContinue, nil
+	})
+	// Дерево меняем после обхода, чтобы не сбить Walk.
+	for _, t := range texts {
+		linkMentions(t, source)
+	}
+}
+
+// linkMentions режет текстовый узел на куски текста и ссылки на упоминания.
+func linkMentions(t *ast.Text, source []byte) {
+	seg := t.Segment
+	if seg.Padding != 0 {
+		return
+	}
+	value := seg.Value(source)
+	matches := mentionRe.FindAllSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return
+	}
+
+	parent := t.Parent()
+	pos := 0
+	for _, m := range matches {
+		start, end := m[2], m[3]
+		kind, name := string(value[m[4]:m[5]]), string(value[m[6]:m[7]])
+
+		if start > pos {
+			parent.InsertBefore(parent, t, ast.NewTextSegment(text.NewSegment(seg.Start+pos, seg.Start+start)))
+		}
+		link := ast.NewLink()
+		link.Destination = []byte(mentionPaths[kind] + url.PathEscape(name))
+		link.SetAttributeString("rel", []byte(LinkRel))
+		link.AppendChild(link, ast.NewTextSegment(text.NewSegment(seg.Start+start, seg.Start+end)))
+		parent.InsertBefore(parent, t, link)
+		pos = end
+	}
+	// Хвост остаётся в исходном узле, чтобы сохранить его переносы строк.
+	t.Segment = text.NewSegment(seg.Start+pos, seg.Stop)
+}
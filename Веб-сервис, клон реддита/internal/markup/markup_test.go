@@ -0,0 +1,82 @@
+package markup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantNot []string
+	}{
+		{name: "Пустой исходник", source: "", want: ""},
+		{name: "Абзац и выделение", source: "hello **world**", want: "<p>hello <strong>world</strong></p>\n"},
+		{
+			name:   "Ссылка получает rel",
+			source: "[go](https://go.dev)",
+			want:   `<p><a href="https://go.dev" rel="nofollow ugc">go</a></p>` + "\n",
+		},
+		{
+			name:   "Голый адрес превращается в ссылку",
+			source: "see https://go.dev",
+			want:   `<p>see <a href="https://go.dev" rel="nofollow ugc">https://go.dev</a></p>` + "\n",
+		},
+		{
+			name:   "Упоминания пользователя и категории",
+			source: "hi u/rvasily, read /r/music",
+			want:   `<p>hi <a href="/u/rvasily" rel="nofollow ugc">u/rvasily</a>, read <a href="/a/music" rel="nofollow ugc">/r/music</a></p>` + "\n",
+		},
+		{
+			name:   "Упоминание внутри выделения и после переноса строки",
+			source: "*u/bob*\nr/news",
+			want:   `<p><em><a href="/u/bob" rel="nofollow ugc">u/bob</a></em>` + "\n" + `<a href="/a/news" rel="nofollow ugc">r/news</a></p>` + "\n",
+		},
+		{
+			name:    "В коде и в адресах упоминания не ищутся",
+			source:  "`u/code` and https://example.com/u/bob and mail@u/x",
+			wantNot: []string{`href="/u/code"`, `href="/u/bob"`, `href="/u/x"`},
+		},
+		{
+			name:   "Язык блока кода сохраняется",
+			source: "```go\nfmt.Println(1)\n```",
+			want:   `<pre><code class="language-go">fmt.Println(1)` + "\n</code></pre>\n",
+		},
+		{
+			name:    "Сырой HTML не проходит",
+			source:  "text <script>alert(1)</script> <img src=x onerror=alert(1)>",
+			wantNot: []string{"<script", "<img", "onerror"},
+		},
+		{
+			name:    "javascript: в ссылке вырезается",
+			source:  "[click](javascript:alert(1))",
+			wantNot: []string{"javascript:"},
+		},
+		{
+			name:    "Картинки вырезаются",
+			source:  "![pixel](https://tracker.example/p.gif)",
+			wantNot: []string{"<img", "tracker.example"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Render(tc.source)
+			if tc.want != "" || tc.source == "" {
+				assert.Equal(t, tc.want, got)
+			}
+			for _, bad := range tc.wantNot {
+				assert.False(t, strings.Contains(got, bad), "%q contains %q", got, bad)
+			}
+		})
+	}
+}
+
+func TestPolicyForcesNofollow(t *testing.T) {
+	got := Policy().Sanitize(`<a href="https://go.dev" rel="me" onclick="x()">go</a>`)
+	assert.Equal(t, `<a href="https://go.dev" rel="nofollow">go</a>`, got)
+}
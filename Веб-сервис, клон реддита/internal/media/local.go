@@ -0,0 +1,89 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore хранит блобы файлами в одном каталоге.
+type LocalStore struct {
+	Dir string
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	return &LocalStore{Dir: dir}, nil
+}
+
+// Put пишет во временный файл и переименовывает его, так что читатели не видят недописанный блоб.
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, _ int64, _ string) error {
+	if !validKey(key) {
+		return fmt.Errorf("%w: bad key %q", ErrStorage, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(s.Dir, key))
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(_ context.Context, key string) (io.ReadCloser, BlobInfo, error) {
+	if !validKey(key) {
+		return nil, BlobInfo{}, ErrNotFound
+	}
+	f, err := os.Open(filepath.Join(s.Dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, BlobInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, BlobInfo{}, fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, BlobInfo{}, fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	return f, BlobInfo{
+		ContentType: mime.TypeByExtension(filepath.Ext(key)),
+		Size:        stat.Size(),
+		ModTime:     stat.ModTime(),
+	}, nil
+}
+
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	if !validKey(key) {
+		return nil
+	}
+	err := os.Remove(filepath.Join(s.Dir, key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	return nil
+}
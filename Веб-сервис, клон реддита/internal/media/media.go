@@ -0,0 +1,102 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrTooLarge        = errors.New("upload is too large")
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrBadImage        = errors.New("malformed image")
+	ErrNotFound        = errors.New("blob not found")
+	// ErrStorage оборачивает ошибки хранилища, исходная ошибка доступна через errors.Is/As.
+	ErrStorage = errors.New("blob storage error")
+)
+
+// PathPrefix - под каким путём сервер отдаёт блобы.
+const PathPrefix = "/media/"
+
+// CacheControl - ключи блобов содержат хеш содержимого, поэтому блоб по ключу никогда не меняется.
+const CacheControl = "public, max-age=31536000, immutable"
+
+// BlobInfo - метаданные блоба.
+type BlobInfo struct {
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// BlobStore хранит файлы по ключу. Реализации: LocalStore (каталог на диске) и S3Store.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get возвращает содержимое блоба, его надо закрыть. Если блоба нет - ErrNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, BlobInfo, error)
+	// Delete удаляет блоб. Удаление отсутствующего блоба - не ошибка.
+	Delete(ctx context.Context, key string) error
+}
+
+// keyRe - допустимые ключи: одно имя файла без каталогов и ведущей точки.
+var keyRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+func validKey(key string) bool {
+	return keyRe.MatchString(key)
+}
+
+// URL - адрес, по которому блоб отдаёт FileServer.
+func URL(key string) string {
+	return PathPrefix + key
+}
+
+// Upload - сохранённая картинка и её превью.
+type Upload struct {
+	Key          string
+	ThumbnailKey string
+	ContentType  string
+	Width        int
+	Height       int
+}
+
+// Uploader проверяет и обрабатывает картинки и складывает результат в Store.
+type Uploader struct {
+	Store  BlobStore
+	Limits Limits
+}
+
+func NewUploader(store BlobStore, limits Limits) *Uploader {
+	return &Uploader{Store: store, Limits: limits}
+}
+
+// Upload обрабатывает картинку и сохраняет её вместе с превью. Ключи считаются от обработанного
+// содержимого, так что одинаковые картинки хранятся один раз.
+func (u *Uploader) Upload(ctx context.Context, r io.Reader) (*Upload, error) {
+	img, err := Process(r, u.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(img.Image)
+	name := hex.EncodeToString(sum[:])
+	ext := extensions[img.ContentType]
+	upload := &Upload{
+		Key:          name + ext,
+		ThumbnailKey: name + "_thumb" + ext,
+		ContentType:  img.ContentType,
+		Width:        img.Width,
+		Height:       img.Height,
+	}
+
+	if err := u.Store.Put(ctx, upload.Key, bytes.NewReader(img.Image), int64(len(img.Image)), img.ContentType); err != nil {
+		return nil, err
+	}
+	if err := u.Store.Put(ctx, upload.ThumbnailKey, bytes.NewReader(img.Thumbnail), int64(len(img.Thumbnail)), img.ContentType); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
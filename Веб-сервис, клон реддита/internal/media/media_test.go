@@ -0,0 +1,202 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLimits = Limits{MaxBytes: 1 << 20, MaxPixels: 1 << 20, ThumbSize: 32}
+
+// testImage - картинка w x h: левая половина красная, правая синяя.
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// jpegWithExif кодирует картинку в JPEG и вставляет после SOI сегмент APP1 с тегом Orientation
+// и строкой, которая не должна пережить обработку.
+func jpegWithExif(t *testing.T, img image.Image, orientation uint16) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}))
+	data := buf.Bytes()
+
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, "GPS 55.7558N 37.6173E"...)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func TestProcess(t *testing.T) {
+	var tests = []struct {
+		name      string
+		data      func(t *testing.T) []byte
+		limits    Limits
+		wantType  string
+		wantSize  [2]int
+		wantThumb [2]int
+		wantErr   error
+	}{
+		{
+			name:      "PNG с превью",
+			data:      func(t *testing.T) []byte { return encodePNG(t, testImage(64, 16)) },
+			limits:    testLimits,
+			wantType:  "image/png",
+			wantSize:  [2]int{64, 16},
+			wantThumb: [2]int{32, 8},
+		},
+		{
+			name:      "Маленькая картинка не растягивается",
+			data:      func(t *testing.T) []byte { return encodePNG(t, testImage(10, 20)) },
+			limits:    testLimits,
+			wantType:  "image/png",
+			wantSize:  [2]int{10, 20},
+			wantThumb: [2]int{10, 20},
+		},
+		{
+			name:      "JPEG повёрнут по EXIF",
+			data:      func(t *testing.T) []byte { return jpegWithExif(t, testImage(40, 20), 6) },
+			limits:    testLimits,
+			wantType:  "image/jpeg",
+			wantSize:  [2]int{20, 40},
+			wantThumb: [2]int{16, 32},
+		},
+		{
+			name:    "Слишком большой файл",
+			data:    func(t *testing.T) []byte { return encodePNG(t, testImage(64, 64)) },
+			limits:  Limits{MaxBytes: 10, MaxPixels: 1 << 20, ThumbSize: 32},
+			wantErr: ErrTooLarge,
+		},
+		{
+			name:    "Слишком много пикселей",
+			data:    func(t *testing.T) []byte { return encodePNG(t, testImage(64, 64)) },
+			limits:  Limits{MaxBytes: 1 << 20, MaxPixels: 100, ThumbSize: 32},
+			wantErr: ErrTooLarge,
+		},
+		{
+			name:    "Не картинка",
+			data:    func(t *testing.T) []byte { return []byte("<html><script>alert(1)</script></html>") },
+			limits:  testLimits,
+			wantErr: ErrUnsupportedType,
+		},
+		{
+			name: "Битая картинка",
+			data: func(t *testing.T) []byte {
+				return encodePNG(t, testImage(8, 8))[:40]
+			},
+			limits:  testLimits,
+			wantErr: ErrBadImage,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Process(bytes.NewReader(tc.data(t)), tc.limits)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantType, got.ContentType)
+			assert.Equal(t, tc.wantSize, [2]int{got.Width, got.Height})
+
+			full, _, err := image.Decode(bytes.NewReader(got.Image))
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantSize, [2]int{full.Bounds().Dx(), full.Bounds().Dy()})
+			thumb, _, err := image.Decode(bytes.NewReader(got.Thumbnail))
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantThumb, [2]int{thumb.Bounds().Dx(), thumb.Bounds().Dy()})
+
+			assert.NotContains(t, string(got.Image), "Exif")
+			assert.NotContains(t, string(got.Image), "GPS")
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// Красная половина слева. После поворота на 90 по часовой она сверху, против часовой - снизу.
+	src := testImage(4, 2)
+	red := color.RGBA{R: 255, A: 255}
+
+	cw := orient(src, 6)
+	assert.Equal(t, image.Rect(0, 0, 2, 4), cw.Bounds())
+	assert.Equal(t, red, cw.At(0, 0))
+	assert.Equal(t, red, cw.At(1, 1))
+
+	ccw := orient(src, 8)
+	assert.Equal(t, red, ccw.At(0, 3))
+	assert.Equal(t, red, ccw.At(1, 2))
+
+	flipped := orient(src, 2)
+	assert.Equal(t, red, flipped.At(3, 0))
+
+	assert.Same(t, src, orient(src, 1))
+}
+
+func TestUploader(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	uploader := NewUploader(store, testLimits)
+	data := encodePNG(t, testImage(64, 16))
+
+	upload, err := uploader.Upload(context.Background(), bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(upload.Key, ".png"), upload.Key)
+	assert.Equal(t, strings.TrimSuffix(upload.Key, ".png")+"_thumb.png", upload.ThumbnailKey)
+	assert.Equal(t, [2]int{64, 16}, [2]int{upload.Width, upload.Height})
+
+	for _, key := range []string{upload.Key, upload.ThumbnailKey} {
+		blob, info, err := store.Get(context.Background(), key)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(blob)
+		blob.Close()
+		assert.Equal(t, int64(len(body)), info.Size)
+		assert.Equal(t, "image/png", info.ContentType)
+	}
+
+	// Та же картинка ложится под тот же ключ.
+	again, err := uploader.Upload(context.Background(), bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, upload.Key, again.Key)
+
+	_, err = uploader.Upload(context.Background(), strings.NewReader("plain text"))
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
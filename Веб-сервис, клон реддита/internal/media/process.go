@@ -0,0 +1,232 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Limits - ограничения на загружаемые картинки.
+type Limits struct {
+	// MaxBytes - размер файла.
+	MaxBytes int64
+	// MaxPixels - ширина на высоту. Проверяется по заголовку до декодирования,
+	// чтобы маленький файл не развернулся в гигабайты памяти.
+	MaxPixels int
+	// ThumbSize - превью вписывается в квадрат с этой стороной.
+	ThumbSize int
+}
+
+// allowedTypes - что принимаем, определяется по содержимому, а не по заголовку запроса.
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// Processed - картинка после обработки.
+type Processed struct {
+	Image       []byte
+	Thumbnail   []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Process проверяет картинку и перекодирует её. После перекодирования от файла остаются
+// только пиксели: EXIF с координатами и моделью камеры пропадает. Поворот из EXIF
+// применяется к пикселям заранее. JPEG остаётся JPEG, остальное становится PNG
+// (у GIF остаётся первый кадр).
+func Process(r io.Reader, limits Limits) (*Processed, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limits.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadImage, err)
+	}
+	if int64(len(data)) > limits.MaxBytes {
+		return nil, ErrTooLarge
+	}
+	sniffed := http.DetectContentType(data)
+	if !allowedTypes[sniffed] {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadImage, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrBadImage
+	}
+	if cfg.Width*cfg.Height > limits.MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadImage, err)
+	}
+
+	contentType := "image/png"
+	if sniffed == "image/jpeg" {
+		contentType = sniffed
+		img = orient(img, jpegOrientation(data))
+	}
+
+	full, err := encode(img, contentType)
+	if err != nil {
+		return nil, err
+	}
+	thumb, err := encode(thumbnail(img, limits.ThumbSize), contentType)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	return &Processed{
+		Image:       full,
+		Thumbnail:   thumb,
+		ContentType: contentType,
+		Width:       b.Dx(),
+		Height:      b.Dy(),
+	}, nil
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadImage, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// thumbnail уменьшает картинку до size по большей стороне. Маленькие картинки не растягивает.
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	if w >= h {
+		w, h = size, max(1, h*size/w)
+	} else {
+		w, h = max(1, w*size/h), size
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// orient разворачивает картинку по тегу Orientation из EXIF (1-8).
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // отражение по горизонтали
+				sx, sy = w-1-x, y
+			case 3: // поворот на 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // отражение по вертикали
+				sx, sy = x, h-1-y
+			case 5: // отражение по главной диагонали
+				sx, sy = y, x
+			case 6: // поворот на 90 по часовой
+				sx, sy = y, h-1-x
+			case 7: // отражение по побочной диагонали
+				sx, sy = w-1-y, h-1-x
+			case 8: // поворот на 90 против часовой
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation достаёт тег Orientation из APP1 (EXIF). Если тега нет или он битый - 1.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// SOS: дальше идут сжатые данные, EXIF раньше.
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
@@ -0,0 +1,110 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config - подключение к S3-совместимому хранилищу (AWS, MinIO, Ceph и т.п.).
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// S3Store хранит блобы объектами в одном бакете.
+type S3Store struct {
+	Client *minio.Client
+	Bucket string
+}
+
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+		// Адресация через путь работает и с MinIO без DNS для бакетов.
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	return &S3Store{Client: client, Bucket: cfg.Bucket}, nil
+}
+
+// EnsureBucket создаёт бакет, если его нет.
+func (s *S3Store) EnsureBucket(ctx context.Context) error {
+	exists, err := s.Client.BucketExists(ctx, s.Bucket)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	if exists {
+		return nil
+	}
+	if err := s.Client.MakeBucket(ctx, s.Bucket, minio.MakeBucketOptions{}); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	return nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if !validKey(key) {
+		return fmt.Errorf("%w: bad key %q", ErrStorage, key)
+	}
+	_, err := s.Client.PutObject(ctx, s.Bucket, key, r, size, minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: CacheControl,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	return nil
+}
+
+// Get возвращает объект minio: он умеет Seek, поэтому FileServer отдаёт и Range-запросы.
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, BlobInfo, error) {
+	if !validKey(key) {
+		return nil, BlobInfo{}, ErrNotFound
+	}
+	obj, err := s.Client.GetObject(ctx, s.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, BlobInfo{}, s3Error(err)
+	}
+	stat, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, BlobInfo{}, s3Error(err)
+	}
+	return obj, BlobInfo{
+		ContentType: stat.ContentType,
+		Size:        stat.Size,
+		ModTime:     stat.LastModified,
+	}, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return nil
+	}
+	// S3 не считает ошибкой удаление отсутствующего объекта.
+	if err := s.Client.RemoveObject(ctx, s.Bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	return nil
+}
+
+func s3Error(err error) error {
+	resp := minio.ToErrorResponse(err)
+	if resp.Code == "NoSuchKey" || resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return fmt.Errorf("%w: %w", ErrStorage, err)
+}
@@ -0,0 +1,47 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// FileServer отдаёт блобы по пути PathPrefix + ключ. Содержимое по ключу не меняется,
+// поэтому ответ кешируется навсегда, а ETag - сам ключ.
+func FileServer(store BlobStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, PathPrefix)
+		blob, info, err := store.Get(r.Context(), key)
+		if errors.Is(err, ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		defer blob.Close()
+
+		h := w.Header()
+		h.Set("Cache-Control", CacheControl)
+		h.Set("ETag", `"`+key+`"`)
+		// Тип задаём сами: сниффинг браузера не должен превратить картинку в HTML.
+		h.Set("X-Content-Type-Options", "nosniff")
+		if info.ContentType != "" {
+			h.Set("Content-Type", info.ContentType)
+		} else {
+			h.Set("Content-Type", "application/octet-stream")
+		}
+
+		if rs, ok := blob.(io.ReadSeeker); ok {
+			http.ServeContent(w, r, key, info.ModTime, rs)
+			return
+		}
+		if match := r.Header.Get("If-None-Match"); match == `"`+key+`"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = io.Copy(w, blob)
+	})
+}
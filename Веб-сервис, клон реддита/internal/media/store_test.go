@@ -0,0 +1,220 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+// fakeS3 - минимальный S3 для тестов: один бакет, PUT/GET/HEAD/DELETE объектов. Подписи не проверяет.
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{bucket: bucket, objects: map[string]fakeObject{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return fake, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		s3ErrorResponse(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if key == "" {
+		// HEAD бакета - BucketExists.
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, err := readS3Body(r)
+		if err != nil {
+			s3ErrorResponse(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[key] = fakeObject{data: body, contentType: r.Header.Get("Content-Type"), modTime: time.Now().UTC()}
+		w.Header().Set("ETag", `"`+strconv.Itoa(len(body))+`"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			s3ErrorResponse(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("ETag", `"`+strconv.Itoa(len(obj.data))+`"`)
+		http.ServeContent(w, r, key, obj.modTime, bytes.NewReader(obj.data))
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// readS3Body читает тело PUT. Без TLS minio подписывает тело по кускам (aws-chunked):
+// "размер;chunk-signature=...\r\nданные\r\n", последний кусок нулевой.
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var out []byte
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return out, nil
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return nil, err
+		}
+		out = append(out, chunk[:size]...)
+	}
+}
+
+func s3ErrorResponse(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, "<Error><Code>"+code+"</Code><Message>"+code+"</Message></Error>")
+}
+
+func newTestS3Store(t *testing.T) *S3Store {
+	_, srv := newFakeS3(t, "media")
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	store, err := NewS3Store(S3Config{Endpoint: u.Host, Region: "us-east-1", Bucket: "media", AccessKey: "key", SecretKey: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, store.EnsureBucket(context.Background()))
+	return store
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) BlobStore{
+		"local": func(t *testing.T) BlobStore {
+			store, err := NewLocalStore(t.TempDir())
+			require.NoError(t, err)
+			return store
+		},
+		"s3": func(t *testing.T) BlobStore {
+			return newTestS3Store(t)
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			content := []byte("\x89PNG not really")
+
+			require.NoError(t, store.Put(ctx, "abc.png", bytes.NewReader(content), int64(len(content)), "image/png"))
+
+			blob, info, err := store.Get(ctx, "abc.png")
+			require.NoError(t, err)
+			got, err := io.ReadAll(blob)
+			blob.Close()
+			require.NoError(t, err)
+			assert.Equal(t, content, got)
+			assert.Equal(t, int64(len(content)), info.Size)
+			assert.Equal(t, "image/png", info.ContentType)
+
+			_, _, err = store.Get(ctx, "missing.png")
+			assert.ErrorIs(t, err, ErrNotFound, "missing key")
+			_, _, err = store.Get(ctx, "../secret")
+			assert.ErrorIs(t, err, ErrNotFound, "path traversal")
+			assert.ErrorIs(t, store.Put(ctx, "../evil", bytes.NewReader(content), int64(len(content)), "image/png"), ErrStorage)
+
+			require.NoError(t, store.Delete(ctx, "abc.png"))
+			_, _, err = store.Get(ctx, "abc.png")
+			assert.ErrorIs(t, err, ErrNotFound, "deleted key")
+			assert.NoError(t, store.Delete(ctx, "abc.png"), "second delete")
+		})
+	}
+}
+
+func TestFileServer(t *testing.T) {
+	stores := map[string]BlobStore{"s3": newTestS3Store(t)}
+	local, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	stores["local"] = local
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			content := []byte("0123456789")
+			require.NoError(t, store.Put(context.Background(), "abc.png", bytes.NewReader(content), int64(len(content)), "image/png"))
+			srv := FileServer(store)
+
+			var tests = []struct {
+				name       string
+				path       string
+				header     http.Header
+				wantStatus int
+				wantBody   string
+			}{
+				{name: "Файл", path: "/media/abc.png", wantStatus: http.StatusOK, wantBody: "0123456789"},
+				{name: "Кусок файла", path: "/media/abc.png", header: http.Header{"Range": {"bytes=2-4"}}, wantStatus: http.StatusPartialContent, wantBody: "234"},
+				{name: "Уже в кеше", path: "/media/abc.png", header: http.Header{"If-None-Match": {`"abc.png"`}}, wantStatus: http.StatusNotModified},
+				{name: "Нет файла", path: "/media/missing.png", wantStatus: http.StatusNotFound},
+			}
+
+			for _, tc := range tests {
+				t.Run(tc.name, func(t *testing.T) {
+					req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+					for k, v := range tc.header {
+						req.Header[k] = v
+					}
+					w := httptest.NewRecorder()
+					srv.ServeHTTP(w, req)
+
+					assert.Equal(t, tc.wantStatus, w.Code)
+					if tc.wantStatus == http.StatusNotFound {
+						return
+					}
+					assert.Equal(t, CacheControl, w.Header().Get("Cache-Control"))
+					assert.Equal(t, `"abc.png"`, w.Header().Get("ETag"))
+					if tc.wantBody != "" {
+						assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+						assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+						assert.Equal(t, tc.wantBody, w.Body.String())
+					}
+				})
+			}
+		})
+	}
+}
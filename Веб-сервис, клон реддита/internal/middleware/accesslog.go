@@ -1,22 +1,60 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
+	"redditclone/internal/logging"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+// responseRecorder запоминает статус и количество записанных байт ответа.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *responseRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
 func AccessLog(logger *zap.SugaredLogger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("access log middleware")
 		start := time.Now()
-		next.ServeHTTP(w, r)
-		logger.Infow("New request",
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		logging.FromContext(r.Context(), logger).Infow("New request",
 			"method", r.Method,
 			"remote_addr", r.RemoteAddr,
 			"url", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
 			"time", time.Since(start),
 		)
 	})
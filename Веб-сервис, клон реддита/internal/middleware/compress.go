@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// brotliLevel - компромисс между степенью и скоростью: ответы сжимаются на лету на каждый запрос.
+const brotliLevel = 5
+
+// compressor - поток сжатия, который можно сбросить посреди ответа и переиспользовать.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var compressors = map[string]*sync.Pool{
+	"br": {New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, brotliLevel)
+	}},
+	"gzip": {New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	}},
+}
+
+// Compress сжимает ответы brotli или gzip, если клиент их принимает (Accept-Encoding).
+// Сжимается только текст: картинки и так сжаты, а поток SSE должен уходить клиенту сразу.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := NegotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// NegotiateEncoding выбирает из Accept-Encoding поддерживаемое сжатие с наибольшим q, при равенстве - brotli.
+func NegotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := compressors[name]; !ok {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ || (q == bestQ && name == "br") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressible - стоит ли сжимать ответ с такими заголовками и статусом.
+func compressible(header http.Header, status int) bool {
+	switch {
+	case status < http.StatusOK, status == http.StatusNoContent, status == http.StatusNotModified,
+		status == http.StatusPartialContent:
+		return false
+	case header.Get("Content-Encoding") != "", header.Get("Content-Range") != "":
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "text/event-stream":
+		return false
+	case "application/json", "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/")
+}
+
+// compressWriter решает, сжимать ли ответ, когда становятся известны статус и Content-Type.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	enc      compressor
+	decided  bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.decided {
+		cw.decided = true
+		header := cw.Header()
+		if compressible(header, status) {
+			header.Set("Content-Encoding", cw.encoding)
+			header.Del("Content-Length")
+			cw.enc = compressors[cw.encoding].Get().(compressor)
+			cw.enc.Reset(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		// Без Content-Type net/http определил бы его по телу, но тело будет сжатым - определяем по исходному.
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.enc == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.enc.Write(b)
+}
+
+func (cw *compressWriter) Flush() {
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close дописывает конец сжатого потока и возвращает компрессор в пул.
+func (cw *compressWriter) Close() {
+	if cw.enc == nil {
+		return
+	}
+	_ = cw.enc.Close()
+	cw.enc.Reset(io.Discard)
+	compressors[cw.encoding].Put(cw.enc)
+	cw.enc = nil
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
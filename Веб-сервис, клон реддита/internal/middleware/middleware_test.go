@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/logging"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		keepInput bool
+	}{
+		{name: "Идентификатор берётся из заголовка", header: "abc-123", keepInput: true},
+		{name: "Идентификатор генерируется, если заголовка нет", header: ""},
+		{name: "Идентификатор генерируется, если в заголовке мусор", header: "bad id\n", keepInput: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var ctxID string
+			handler := RequestID(zap.NewNop().Sugar(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = logging.RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.header != "" {
+				req.Header.Set(RequestIDHeader, tc.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			respID := w.Header().Get(RequestIDHeader)
+			assert.NotEmpty(t, respID)
+			assert.Equal(t, respID, ctxID)
+			if tc.keepInput {
+				assert.Equal(t, tc.header, respID)
+			} else {
+				assert.NotEqual(t, tc.header, respID)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core).Sugar()
+
+	handler := RequestID(logger, AccessLog(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	})))
+
+	req := httptest.NewRequest("POST", "/api/posts", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.All()
+	if assert.Len(t, entries, 1) {
+		fields := entries[0].ContextMap()
+		assert.Equal(t, "req-1", fields["request_id"])
+		assert.EqualValues(t, http.StatusCreated, fields["status"])
+		assert.EqualValues(t, 5, fields["bytes"])
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"title":"post"}`, 100)
+	jsonHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = io.WriteString(w, body)
+	})
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		handler        http.Handler
+		wantEncoding   string
+	}{
+		{name: "brotli предпочтительнее", acceptEncoding: "gzip, deflate, br", handler: jsonHandler, wantEncoding: "br"},
+		{name: "gzip с большим q", acceptEncoding: "br;q=0.5, gzip", handler: jsonHandler, wantEncoding: "gzip"},
+		{name: "Только gzip", acceptEncoding: "gzip", handler: jsonHandler, wantEncoding: "gzip"},
+		{name: "Клиент не принимает сжатие", acceptEncoding: "", handler: jsonHandler},
+		{name: "Сжатие запрещено q=0", acceptEncoding: "gzip;q=0", handler: jsonHandler},
+		{
+			name:           "Картинки не сжимаются",
+			acceptEncoding: "gzip",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				_, _ = io.WriteString(w, body)
+			}),
+		},
+		{
+			name:           "Поток SSE не сжимается",
+			acceptEncoding: "gzip",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = io.WriteString(w, body)
+			}),
+		},
+		{
+			name:           "304 не сжимается",
+			acceptEncoding: "gzip",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotModified)
+			}),
+		},
+		{
+			name:           "Тип определяется по телу",
+			acceptEncoding: "gzip",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, "<html>"+body+"</html>")
+			}),
+			wantEncoding: "gzip",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/posts/", nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			w := httptest.NewRecorder()
+			Compress(tc.handler).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantEncoding, w.Header().Get("Content-Encoding"))
+			assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+			if tc.wantEncoding == "" {
+				return
+			}
+			assert.Empty(t, w.Header().Get("Content-Length"))
+			assert.NotEmpty(t, w.Header().Get("Content-Type"))
+
+			var reader io.Reader
+			switch tc.wantEncoding {
+			case "br":
+				reader = brotli.NewReader(w.Body)
+			case "gzip":
+				gz, err := gzip.NewReader(w.Body)
+				require.NoError(t, err)
+				reader = gz
+			}
+			decoded, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Contains(t, string(decoded), body)
+			assert.Less(t, w.Body.Len(), len(body))
+		})
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"a":1}`)
+		w.(http.Flusher).Flush()
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.True(t, w.Flushed)
+	gz, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, string(decoded))
+}
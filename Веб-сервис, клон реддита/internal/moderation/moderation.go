@@ -0,0 +1,127 @@
+package moderation
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reason - причина жалобы.
+type Reason string
+
+const (
+	ReasonSpam           Reason = "spam"
+	ReasonHarassment     Reason = "harassment"
+	ReasonHate           Reason = "hate"
+	ReasonViolence       Reason = "violence"
+	ReasonMisinformation Reason = "misinformation"
+	ReasonOther          Reason = "other"
+)
+
+func ValidReason(reason Reason) bool {
+	switch reason {
+	case ReasonSpam, ReasonHarassment, ReasonHate, ReasonViolence, ReasonMisinformation, ReasonOther:
+		return true
+	}
+	return false
+}
+
+// Status - состояние жалобы. Открытые жалобы попадают в очередь модераторов.
+type Status string
+
+const (
+	StatusOpen     Status = "open"
+	StatusRemoved  Status = "removed"
+	StatusApproved Status = "approved"
+)
+
+// Action - действие модератора.
+type Action string
+
+const (
+	ActionRemove   Action = "remove"
+	ActionApprove  Action = "approve"
+	ActionLock     Action = "lock"
+	ActionUnlock   Action = "unlock"
+	ActionSticky   Action = "sticky"
+	ActionUnsticky Action = "unsticky"
+	// ActionRestore отменяет удаление поста или комментария автором.
+	ActionRestore Action = "restore"
+)
+
+// Target - пост или комментарий. CommentID == nil означает сам пост; nil хранится в монге как null,
+// поэтому по нему можно и фильтровать, и группировать.
+type Target struct {
+	PostID    primitive.ObjectID  `json:"postId" bson:"postId"`
+	CommentID *primitive.ObjectID `json:"commentId,omitempty" bson:"commentId"`
+}
+
+type Report struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Target     `bson:",inline"`
+	ReporterID int64     `json:"-" bson:"reporterId"`
+	Reason     Reason    `json:"reason" bson:"reason"`
+	Details    string    `json:"details,omitempty" bson:"details,omitempty"`
+	Status     Status    `json:"status" bson:"status"`
+	Created    time.Time `json:"created" bson:"created"`
+}
+
+// QueueItem - все открытые жалобы на одну цель.
+type QueueItem struct {
+	Target  Target    `json:"target" bson:"_id"`
+	Reports int       `json:"reports" bson:"reports"`
+	Reasons []Reason  `json:"reasons" bson:"reasons"`
+	First   time.Time `json:"first" bson:"first"`
+	Last    time.Time `json:"last" bson:"last"`
+}
+
+// AuditEntry - запись журнала модерации. Журнал только пополняется.
+type AuditEntry struct {
+	ID      primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ActorID int64              `json:"actorId" bson:"actorId"`
+	Actor   string             `json:"actor" bson:"actor"`
+	Action  Action             `json:"action" bson:"action"`
+	Target  `bson:",inline"`
+	Reason  string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	Created time.Time `json:"created" bson:"created"`
+}
+
+//go:generate mockgen -source=moderation.go -destination=repo_mock.go -package=moderation ReportRepo,AuditLog
+type ReportRepo interface {
+	// Report идемпотентен: повторная жалоба того же пользователя обновляет причину и снова открывает жалобу.
+	Report(ctx context.Context, report *Report) error
+	// Queue возвращает цели с открытыми жалобами, сначала самые обжалованные.
+	Queue(ctx context.Context, offset, limit int) ([]*QueueItem, error)
+	// Resolve закрывает открытые жалобы на цель и возвращает их число.
+	Resolve(ctx context.Context, target Target, status Status) (int64, error)
+	// ReportsBy отдаёт жалобы, поданные пользователем, от новых к старым.
+	ReportsBy(ctx context.Context, reporterID int64) ([]*Report, error)
+	// DeleteReportsBy удаляет жалобы пользователя. Обезличить их нельзя: автор жалобы входит
+	// в уникальный индекс, и жалобы двух удалённых пользователей на одну цель столкнулись бы.
+	DeleteReportsBy(ctx context.Context, reporterID int64) (int64, error)
+}
+
+type AuditLog interface {
+	Append(ctx context.Context, entry *AuditEntry) error
+	List(ctx context.Context, offset, limit int) ([]*AuditEntry, error)
+}
+
+// Moderators - логины модераторов.
+type Moderators map[string]bool
+
+// NewModerators собирает список из логинов, пустые пропускает.
+func NewModerators(usernames []string) Moderators {
+	m := Moderators{}
+	for _, name := range usernames {
+		if name = strings.TrimSpace(name); name != "" {
+			m[name] = true
+		}
+	}
+	return m
+}
+
+func (m Moderators) Is(username string) bool {
+	return m[username]
+}
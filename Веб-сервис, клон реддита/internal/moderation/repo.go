@@ -0,0 +1,210 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"redditclone/internal/logging"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrFailedConvert = errors.New("failed to convert values")
+	// ErrStorage оборачивает ошибки монги, исходная ошибка доступна через errors.Is/As.
+	ErrStorage = errors.New("storage error")
+)
+
+// storageError пишет ошибку монги в лог запроса и оборачивает её в ErrStorage.
+func storageError(ctx context.Context, op string, err error) error {
+	logging.FromContext(ctx, nil).Errorw("storage error", "op", op, "error", err)
+	return fmt.Errorf("%w: %w", ErrStorage, err)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func targetFilter(target Target) bson.M {
+	return bson.M{"postId": target.PostID, "commentId": target.CommentID}
+}
+
+type ReportMongoRepository struct {
+	DB *mongo.Collection
+	// Таймауты на одну операцию чтения/записи в монгу. 0 - без таймаута, только контекст запроса.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+func NewReportRepo(db *mongo.Collection) *ReportMongoRepository {
+	return &ReportMongoRepository{DB: db}
+}
+
+// EnsureIndexes создаёт уникальный индекс, на который опирается идемпотентность Report, и индекс очереди.
+func (repo *ReportMongoRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "reporterId", Value: 1}, {Key: "postId", Value: 1}, {Key: "commentId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "postId", Value: 1}, {Key: "commentId", Value: 1}}},
+	})
+	if err != nil {
+		return storageError(ctx, "moderation.reports.EnsureIndexes", err)
+	}
+	return nil
+}
+
+func (repo *ReportMongoRepository) Report(ctx context.Context, report *Report) error {
+	created := report.Created
+	if created.IsZero() {
+		created = time.Now().UTC()
+	}
+
+	filter := targetFilter(report.Target)
+	filter["reporterId"] = report.ReporterID
+	update := bson.M{
+		"$set":         bson.M{"reason": report.Reason, "details": report.Details, "status": StatusOpen},
+		"$setOnInsert": bson.M{"created": created},
+	}
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return storageError(ctx, "moderation.reports.Report", err)
+	}
+	return nil
+}
+
+func (repo *ReportMongoRepository) Queue(ctx context.Context, offset, limit int) ([]*QueueItem, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": StatusOpen}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     bson.M{"postId": "$postId", "commentId": "$commentId"},
+			"reports": bson.M{"$sum": 1},
+			"reasons": bson.M{"$addToSet": "$reason"},
+			"first":   bson.M{"$min": "$created"},
+			"last":    bson.M{"$max": "$created"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "reports", Value: -1}, {Key: "last", Value: -1}}}},
+		{{Key: "$skip", Value: offset}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, storageError(ctx, "moderation.reports.Queue", err)
+	}
+
+	items := []*QueueItem{}
+	if err = c.All(ctx, &items); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return items, nil
+}
+
+func (repo *ReportMongoRepository) Resolve(ctx context.Context, target Target, status Status) (int64, error) {
+	filter := targetFilter(target)
+	filter["status"] = StatusOpen
+
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result, err := repo.DB.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"status": status}})
+	if err != nil {
+		return 0, storageError(ctx, "moderation.reports.Resolve", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+func (repo *ReportMongoRepository) ReportsBy(ctx context.Context, reporterID int64) ([]*Report, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created", Value: -1}, {Key: "_id", Value: -1}})
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(ctx, bson.M{"reporterId": reporterID}, opts)
+	if err != nil {
+		return nil, storageError(ctx, "moderation.reports.ReportsBy", err)
+	}
+
+	reports := []*Report{}
+	if err = c.All(ctx, &reports); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return reports, nil
+}
+
+func (repo *ReportMongoRepository) DeleteReportsBy(ctx context.Context, reporterID int64) (int64, error) {
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result, err := repo.DB.DeleteMany(ctx, bson.M{"reporterId": reporterID})
+	if err != nil {
+		return 0, storageError(ctx, "moderation.reports.DeleteReportsBy", err)
+	}
+	return result.DeletedCount, nil
+}
+
+// AuditMongoRepository - журнал модерации. Методов изменения и удаления записей у него нет намеренно.
+type AuditMongoRepository struct {
+	DB           *mongo.Collection
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+func NewAuditRepo(db *mongo.Collection) *AuditMongoRepository {
+	return &AuditMongoRepository{DB: db}
+}
+
+func (repo *AuditMongoRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "created", Value: -1}, {Key: "_id", Value: -1}},
+	})
+	if err != nil {
+		return storageError(ctx, "moderation.audit.EnsureIndexes", err)
+	}
+	return nil
+}
+
+func (repo *AuditMongoRepository) Append(ctx context.Context, entry *AuditEntry) error {
+	if entry.Created.IsZero() {
+		entry.Created = time.Now().UTC()
+	}
+
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.InsertOne(ctx, entry)
+	if err != nil {
+		return storageError(ctx, "moderation.audit.Append", err)
+	}
+	return nil
+}
+
+func (repo *AuditMongoRepository) List(ctx context.Context, offset, limit int) ([]*AuditEntry, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, storageError(ctx, "moderation.audit.List", err)
+	}
+
+	entries := []*AuditEntry{}
+	if err = c.All(ctx, &entries); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,161 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: moderation.go
+
+// Package moderation is a generated GoMock package.
+package moderation
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockReportRepo is a mock of ReportRepo interface.
+type MockReportRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockReportRepoMockRecorder
+}
+
+// MockReportRepoMockRecorder is the mock recorder for MockReportRepo.
+type MockReportRepoMockRecorder struct {
+	mock *MockReportRepo
+}
+
+// NewMockReportRepo creates a new mock instance.
+func NewMockReportRepo(ctrl *gomock.Controller) *MockReportRepo {
+	mock := &MockReportRepo{ctrl: ctrl}
+	mock.recorder = &MockReportRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReportRepo) EXPECT() *MockReportRepoMockRecorder {
+	return m.recorder
+}
+
+// DeleteReportsBy mocks base method.
+func (m *MockReportRepo) DeleteReportsBy(ctx context.Context, reporterID int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteReportsBy", ctx, reporterID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteReportsBy indicates an expected call of DeleteReportsBy.
+func (mr *MockReportRepoMockRecorder) DeleteReportsBy(ctx, reporterID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteReportsBy", reflect.TypeOf((*MockReportRepo)(nil).DeleteReportsBy), ctx, reporterID)
+}
+
+// Queue mocks base method.
+func (m *MockReportRepo) Queue(ctx context.Context, offset, limit int) ([]*QueueItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Queue", ctx, offset, limit)
+	ret0, _ := ret[0].([]*QueueItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Queue indicates an expected call of Queue.
+func (mr *MockReportRepoMockRecorder) Queue(ctx, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Queue", reflect.TypeOf((*MockReportRepo)(nil).Queue), ctx, offset, limit)
+}
+
+// Report mocks base method.
+func (m *MockReportRepo) Report(ctx context.Context, report *Report) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Report", ctx, report)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Report indicates an expected call of Report.
+func (mr *MockReportRepoMockRecorder) Report(ctx, report interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Report", reflect.TypeOf((*MockReportRepo)(nil).Report), ctx, report)
+}
+
+// ReportsBy mocks base method.
+func (m *MockReportRepo) ReportsBy(ctx context.Context, reporterID int64) ([]*Report, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportsBy", ctx, reporterID)
+	ret0, _ := ret[0].([]*Report)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReportsBy indicates an expected call of ReportsBy.
+func (mr *MockReportRepoMockRecorder) ReportsBy(ctx, reporterID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportsBy", reflect.TypeOf((*MockReportRepo)(nil).ReportsBy), ctx, reporterID)
+}
+
+// Resolve mocks base method.
+func (m *MockReportRepo) Resolve(ctx context.Context, target Target, status Status) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Resolve", ctx, target, status)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Resolve indicates an expected call of Resolve.
+func (mr *MockReportRepoMockRecorder) Resolve(ctx, target, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resolve", reflect.TypeOf((*MockReportRepo)(nil).Resolve), ctx, target, status)
+}
+
+// MockAuditLog is a mock of AuditLog interface.
+type MockAuditLog struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditLogMockRecorder
+}
+
+// MockAuditLogMockRecorder is the mock recorder for MockAuditLog.
+type MockAuditLogMockRecorder struct {
+	mock *MockAuditLog
+}
+
+// NewMockAuditLog creates a new mock instance.
+func NewMockAuditLog(ctrl *gomock.Controller) *MockAuditLog {
+	mock := &MockAuditLog{ctrl: ctrl}
+	mock.recorder = &MockAuditLogMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditLog) EXPECT() *MockAuditLogMockRecorder {
+	return m.recorder
+}
+
+// Append mocks base method.
+func (m *MockAuditLog) Append(ctx context.Context, entry *AuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Append", ctx, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Append indicates an expected call of Append.
+func (mr *MockAuditLogMockRecorder) Append(ctx, entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Append", reflect.TypeOf((*MockAuditLog)(nil).Append), ctx, entry)
+}
+
+// List mocks base method.
+func (m *MockAuditLog) List(ctx context.Context, offset, limit int) ([]*AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, offset, limit)
+	ret0, _ := ret[0].([]*AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAuditLogMockRecorder) List(ctx, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAuditLog)(nil).List), ctx, offset, limit)
+}
@@ -0,0 +1,253 @@
+package moderation
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"redditclone/internal/logging"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID, commentID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	var tests = []struct {
+		name         string
+		report       *Report
+		mockResponse []bson.D
+		wantErr      error
+	}{
+		{
+			name:         "Жалоба на пост",
+			report:       &Report{Target: Target{PostID: postID}, ReporterID: 1, Reason: ReasonSpam},
+			mockResponse: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1})},
+		},
+		{
+			name:         "Жалоба на комментарий",
+			report:       &Report{Target: Target{PostID: postID, CommentID: &commentID}, ReporterID: 1, Reason: ReasonHate},
+			mockResponse: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1})},
+		},
+		{
+			name:         "Проверка на ошибку при запросе",
+			report:       &Report{Target: Target{PostID: postID}, ReporterID: 1, Reason: ReasonSpam},
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewReportRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			err := repo.Report(context.Background(), tc.report)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			// Повторная жалоба того же пользователя на ту же цель попадает в ту же запись.
+			update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+			assert.True(t, update.Lookup("upsert").Boolean())
+			assert.Equal(t, int64(1), update.Lookup("q", "reporterId").Int64())
+			assert.Equal(t, postID, update.Lookup("q", "postId").ObjectID())
+			if tc.report.CommentID == nil {
+				assert.Equal(t, bson.TypeNull, update.Lookup("q", "commentId").Type)
+			} else {
+				assert.Equal(t, commentID, update.Lookup("q", "commentId").ObjectID())
+			}
+			assert.Equal(t, string(StatusOpen), update.Lookup("u", "$set", "status").StringValue())
+		})
+	}
+}
+
+func TestQueue(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID, commentID := primitive.NewObjectID(), primitive.NewObjectID()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	mt.Run("Очередь жалоб", func(mt *mtest.T) {
+		repo := NewReportRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+			bson.D{
+				{Key: "_id", Value: bson.D{{Key: "postId", Value: postID}, {Key: "commentId", Value: commentID}}},
+				{Key: "reports", Value: 3},
+				{Key: "reasons", Value: bson.A{"spam", "hate"}},
+				{Key: "first", Value: now},
+				{Key: "last", Value: now},
+			},
+			bson.D{
+				{Key: "_id", Value: bson.D{{Key: "postId", Value: postID}, {Key: "commentId", Value: nil}}},
+				{Key: "reports", Value: 1},
+				{Key: "reasons", Value: bson.A{"other"}},
+			},
+		))
+
+		items, err := repo.Queue(context.Background(), 0, 10)
+		assert.NoError(t, err)
+		if assert.Len(t, items, 2) {
+			assert.Equal(t, commentID, *items[0].Target.CommentID)
+			assert.Equal(t, 3, items[0].Reports)
+			assert.Equal(t, []Reason{ReasonSpam, ReasonHate}, items[0].Reasons)
+			assert.Equal(t, now, items[0].Last)
+			assert.Nil(t, items[1].Target.CommentID)
+		}
+	})
+
+	mt.Run("Проверка на ошибку при запросе", func(mt *mtest.T) {
+		repo := NewReportRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.Queue(context.Background(), 0, 10)
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestResolve(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID := primitive.NewObjectID()
+
+	mt.Run("Закрытие жалоб", func(mt *mtest.T) {
+		repo := NewReportRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}))
+
+		n, err := repo.Resolve(context.Background(), Target{PostID: postID}, StatusRemoved)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.True(t, update.Lookup("multi").Boolean())
+		assert.Equal(t, string(StatusOpen), update.Lookup("q", "status").StringValue())
+		assert.Equal(t, string(StatusRemoved), update.Lookup("u", "$set", "status").StringValue())
+	})
+
+	mt.Run("Проверка на ошибку при запросе", func(mt *mtest.T) {
+		repo := NewReportRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.Resolve(context.Background(), Target{PostID: postID}, StatusApproved)
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestReportsBy(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID := primitive.NewObjectID()
+
+	mt.Run("Жалобы пользователя", func(mt *mtest.T) {
+		repo := NewReportRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "postId", Value: postID}, {Key: "reporterId", Value: int64(1)}, {Key: "reason", Value: "spam"}},
+		))
+
+		reports, err := repo.ReportsBy(context.Background(), 1)
+		assert.NoError(t, err)
+		if assert.Len(t, reports, 1) {
+			assert.Equal(t, postID, reports[0].PostID)
+			assert.Equal(t, ReasonSpam, reports[0].Reason)
+		}
+		assert.Equal(t, int64(1), mt.GetStartedEvent().Command.Lookup("filter", "reporterId").Int64())
+	})
+
+	mt.Run("Удаление жалоб пользователя", func(mt *mtest.T) {
+		repo := NewReportRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+
+		n, err := repo.DeleteReportsBy(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+	})
+
+	mt.Run("Проверка на ошибку при запросе", func(mt *mtest.T) {
+		repo := NewReportRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}}, bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.ReportsBy(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrStorage)
+		_, err = repo.DeleteReportsBy(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID := primitive.NewObjectID()
+
+	mt.Run("Запись в журнал", func(mt *mtest.T) {
+		repo := NewAuditRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		entry := &AuditEntry{ActorID: 1, Actor: "admin", Action: ActionLock, Target: Target{PostID: postID}}
+		assert.NoError(t, repo.Append(context.Background(), entry))
+		assert.False(t, entry.Created.IsZero(), "created is not set")
+
+		doc := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		assert.Equal(t, string(ActionLock), doc.Lookup("action").StringValue())
+		assert.Equal(t, postID, doc.Lookup("postId").ObjectID())
+	})
+
+	mt.Run("Чтение журнала", func(mt *mtest.T) {
+		repo := NewAuditRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "actor", Value: "admin"}, {Key: "action", Value: "remove"}, {Key: "postId", Value: postID}},
+		))
+
+		entries, err := repo.List(context.Background(), 0, 10)
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, ActionRemove, entries[0].Action)
+			assert.Equal(t, postID, entries[0].PostID)
+		}
+	})
+
+	mt.Run("Проверка на ошибку при запросе", func(mt *mtest.T) {
+		repo := NewAuditRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.List(context.Background(), 0, 10)
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestModerators(t *testing.T) {
+	m := NewModerators([]string{" admin ", "", "rvasily"})
+
+	assert.True(t, m.Is("admin"))
+	assert.True(t, m.Is("rvasily"))
+	assert.False(t, m.Is(""))
+	assert.False(t, m.Is("ivan"))
+	assert.Len(t, m, 2)
+}
+
+func TestStorageErrorLogged(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Ошибка монги пишется в лог запроса", func(mt *mtest.T) {
+		core, logs := observer.New(zap.ErrorLevel)
+		ctx := logging.NewContext(context.Background(), zap.New(core).Sugar().With("request_id", "req-1"))
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		repo := NewReportRepo(mt.Coll)
+		_, err := repo.Queue(ctx, 0, 10)
+		assert.ErrorIs(t, err, ErrStorage)
+
+		entries := logs.AllUntimed()
+		if assert.Len(t, entries, 1) {
+			fields := entries[0].ContextMap()
+			assert.Equal(t, "moderation.reports.Queue", fields["op"])
+			assert.Equal(t, "req-1", fields["request_id"])
+		}
+	})
+}
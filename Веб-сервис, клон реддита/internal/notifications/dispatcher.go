@@ -0,0 +1,226 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"redditclone/internal/posts"
+	"redditclone/internal/user"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EventKind - что произошло в приложении.
+type EventKind int
+
+const (
+	// EventComment - новый комментарий, Event.Comment указывает на него.
+	EventComment EventKind = iota + 1
+	// EventPost - новый пост.
+	EventPost
+	// EventVote - голос за пост, Event.Post - пост после голосования.
+	EventVote
+)
+
+// Event - событие, из которого Dispatcher делает уведомления. Actor - кто его вызвал.
+type Event struct {
+	Kind    EventKind
+	Actor   user.User
+	Post    *posts.Post
+	Comment *posts.Comment
+}
+
+// Publisher принимает события от обработчиков запросов. Publish не должен блокироваться.
+type Publisher interface {
+	Publish(ev Event) bool
+}
+
+// UserResolver находит адресата упоминания по логину, в проде это user.UserRepo.
+type UserResolver interface {
+	GetUser(ctx context.Context, username string) (*user.User, error)
+}
+
+// Dispatcher превращает события в уведомления вне обработки запроса: Publish кладёт событие
+// в буферизованную очередь, а Run разбирает её и пишет уведомления в NotificationRepo.
+type Dispatcher struct {
+	Repo   NotificationRepo
+	Users  UserResolver
+	Logger *zap.SugaredLogger
+	// Timeout ограничивает обработку одного события. 0 - без таймаута.
+	Timeout time.Duration
+
+	events chan Event
+}
+
+func NewDispatcher(repo NotificationRepo, users UserResolver, logger *zap.SugaredLogger, buffer int) *Dispatcher {
+	return &Dispatcher{
+		Repo:   repo,
+		Users:  users,
+		Logger: logger,
+		events: make(chan Event, buffer),
+	}
+}
+
+// Publish ставит событие в очередь. Если очередь переполнена, событие теряется: уведомления
+// не стоят того, чтобы из-за них тормозили комментарии и голоса.
+func (d *Dispatcher) Publish(ev Event) bool {
+	select {
+	case d.events <- ev:
+		return true
+	default:
+		d.Logger.Warnw("notification queue is full, event dropped", "kind", ev.Kind, "actor", ev.Actor.ID)
+		return false
+	}
+}
+
+// Run обрабатывает события, пока не отменят ctx, и перед выходом разбирает то, что осталось в очереди.
+// Можно запускать в нескольких горутинах.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		select {
+		case ev := <-d.events:
+			d.handle(ctx, ev)
+		case <-ctx.Done():
+			d.drain(context.WithoutCancel(ctx))
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) drain(ctx context.Context) {
+	for {
+		select {
+		case ev := <-d.events:
+			d.handle(ctx, ev)
+		default:
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) handle(ctx context.Context, ev Event) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	if err := d.Process(ctx, ev); err != nil {
+		d.Logger.Errorw("failed to process notification event", "kind", ev.Kind, "actor", ev.Actor.ID, "error", err)
+	}
+}
+
+// Process синхронно строит и сохраняет уведомления одного события.
+func (d *Dispatcher) Process(ctx context.Context, ev Event) error {
+	items, err := d.build(ctx, ev)
+	if err != nil {
+		return err
+	}
+	return d.Repo.Create(ctx, items)
+}
+
+func (d *Dispatcher) build(ctx context.Context, ev Event) ([]*Notification, error) {
+	if ev.Post == nil {
+		return nil, nil
+	}
+	b := &builder{ev: ev, notified: map[int64]bool{ev.Actor.ID: true}}
+
+	switch ev.Kind {
+	case EventComment:
+		if ev.Comment == nil {
+			return nil, nil
+		}
+		if to := replyRecipient(ev.Post, ev.Comment); to != nil {
+			b.add(to.ID, TypeReply, ev.Comment.Body)
+		}
+		if err := d.mentions(ctx, b, ev.Comment.Body, ev.Comment.Body); err != nil {
+			return nil, err
+		}
+	case EventPost:
+		if err := d.mentions(ctx, b, ev.Post.Title, ev.Post.Title, ev.Post.Text); err != nil {
+			return nil, err
+		}
+	case EventVote:
+		milestone := Milestone(ev.Post.Score)
+		if milestone == 0 || notifiable(ev.Post.Author) == nil {
+			return nil, nil
+		}
+		// Рубеж засчитывается и за голос самого автора, поэтому notified здесь не проверяем.
+		b.items = append(b.items, &Notification{
+			UserID:    ev.Post.Author.ID,
+			Type:      TypeMilestone,
+			PostID:    ev.Post.ID,
+			Milestone: milestone,
+			Text:      excerpt(ev.Post.Title),
+			Key:       fmt.Sprintf("milestone:%s:%d", ev.Post.ID.Hex(), milestone),
+		})
+	}
+
+	return b.items, nil
+}
+
+// mentions добавляет уведомления упомянутым пользователям. Незнакомые логины пропускаются.
+func (d *Dispatcher) mentions(ctx context.Context, b *builder, text string, sources ...string) error {
+	for _, name := range ParseMentions(sources...) {
+		u, err := d.Users.GetUser(ctx, name)
+		if errors.Is(err, user.ErrNoUser) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		b.add(u.ID, TypeMention, text)
+	}
+	return nil
+}
+
+// replyRecipient - автор родительского комментария, а для ответа на пост - автор поста.
+// Удалённому пользователю уведомлять некого, для него nil.
+func replyRecipient(post *posts.Post, comment *posts.Comment) *user.User {
+	if comment.ParentID == nil {
+		return notifiable(post.Author)
+	}
+	for _, parent := range post.Comments {
+		if parent.ID == *comment.ParentID {
+			return notifiable(parent.Author)
+		}
+	}
+	return nil
+}
+
+// notifiable отсеивает автора удалённого пользователя: у posts.DeletedAuthor id 0,
+// и уведомления на него никто бы не прочитал.
+func notifiable(author *user.User) *user.User {
+	if author == nil || author.ID == 0 || author.Username == posts.DeletedUsername {
+		return nil
+	}
+	return author
+}
+
+// builder собирает уведомления одного события, не больше одного на адресата:
+// если вам ответили и в том же ответе упомянули, придёт только ответ.
+type builder struct {
+	ev       Event
+	notified map[int64]bool
+	items    []*Notification
+}
+
+func (b *builder) add(userID int64, typ Type, text string) {
+	if b.notified[userID] {
+		return
+	}
+	b.notified[userID] = true
+
+	item := &Notification{
+		UserID: userID,
+		Type:   typ,
+		Actor:  b.ev.Actor.Username,
+		PostID: b.ev.Post.ID,
+		Text:   excerpt(text),
+	}
+	if b.ev.Comment != nil {
+		commentID := b.ev.Comment.ID
+		item.CommentID = &commentID
+	}
+	b.items = append(b.items, item)
+}
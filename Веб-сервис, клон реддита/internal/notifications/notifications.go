@@ -0,0 +1,111 @@
+package notifications
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Type - повод уведомления.
+type Type string
+
+const (
+	// TypeReply - комментарий к вашему посту или ответ на ваш комментарий.
+	TypeReply Type = "reply"
+	// TypeMention - упоминание @логина в посте или комментарии.
+	TypeMention Type = "mention"
+	// TypeMilestone - рейтинг вашего поста достиг очередного рубежа.
+	TypeMilestone Type = "milestone"
+)
+
+// Milestones - рубежи рейтинга поста, о которых сообщаем автору.
+var Milestones = []int{10, 50, 100, 500, 1000, 5000, 10000}
+
+// maxMentions ограничивает число адресатов одного текста, чтобы упоминания не превращались в рассылку.
+const maxMentions = 10
+
+// excerptLen - сколько символов текста сохраняется в уведомлении.
+const excerptLen = 140
+
+type Notification struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID int64              `json:"-" bson:"userId"`
+	Type   Type               `json:"type" bson:"type"`
+	// Actor - логин того, кто вызвал уведомление. У рубежей не заполняется.
+	Actor     string              `json:"actor,omitempty" bson:"actor,omitempty"`
+	PostID    primitive.ObjectID  `json:"postId" bson:"postId"`
+	CommentID *primitive.ObjectID `json:"commentId,omitempty" bson:"commentId,omitempty"`
+	Milestone int                 `json:"milestone,omitempty" bson:"milestone,omitempty"`
+	// Text - начало комментария или заголовок поста.
+	Text    string    `json:"text" bson:"text"`
+	Read    bool      `json:"read" bson:"read"`
+	Created time.Time `json:"created" bson:"created"`
+	// Key - ключ идемпотентности: уведомление с тем же ключом второй раз не создаётся.
+	Key string `json:"-" bson:"key,omitempty"`
+}
+
+//go:generate mockgen -source=notifications.go -destination=repo_mock.go -package=notifications NotificationRepo
+type NotificationRepo interface {
+	// Create сохраняет уведомления. Уведомления с уже занятым Key молча пропускаются.
+	Create(ctx context.Context, items []*Notification) error
+	List(ctx context.Context, userID int64, unreadOnly bool, offset, limit int) ([]*Notification, error)
+	// MarkRead отмечает прочитанными уведомления с указанными id, а при пустом ids - все. Возвращает число изменённых.
+	MarkRead(ctx context.Context, userID int64, ids []primitive.ObjectID) (int64, error)
+	UnreadCount(ctx context.Context, userID int64) (int64, error)
+	// DeleteUser удаляет уведомления пользователя, а в чужих уведомлениях заменяет его логин
+	// на posts.DeletedUsername. Возвращает число удалённых уведомлений.
+	DeleteUser(ctx context.Context, userID int64, username string) (int64, error)
+}
+
+// mentionRe находит @логин в начале текста или после символа, который не может быть частью логина,
+// поэтому адреса почты вроде a@b.ru упоминаниями не считаются.
+var mentionRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.-])@([\p{L}\p{N}_-]+)`)
+
+// ParseMentions возвращает упомянутые логины без повторов, в порядке появления, не больше maxMentions.
+func ParseMentions(texts ...string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, text := range texts {
+		for _, match := range mentionRe.FindAllStringSubmatch(text, -1) {
+			name := match[1]
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+			if len(names) == maxMentions {
+				return names
+			}
+		}
+	}
+	return names
+}
+
+// Milestone возвращает наибольший рубеж, не превышающий score, или 0.
+func Milestone(score int) int {
+	reached := 0
+	for _, m := range Milestones {
+		if score >= m {
+			reached = m
+		}
+	}
+	return reached
+}
+
+// MilestoneCandidate сообщает, мог ли последний голос перевести пост через рубеж.
+// Один голос меняет рейтинг максимум на 2 (с -1 на 1), повторная проверка всё равно идемпотентна за счёт Key.
+func MilestoneCandidate(score int) bool {
+	return Milestone(score) > Milestone(score-2)
+}
+
+func excerpt(text string) string {
+	text = strings.TrimSpace(text)
+	runes := []rune(text)
+	if len(runes) <= excerptLen {
+		return text
+	}
+	return string(runes[:excerptLen-1]) + "…"
+}
@@ -0,0 +1,237 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"redditclone/internal/posts"
+	"redditclone/internal/user"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name  string
+		texts []string
+		want  []string
+	}{
+		{name: "Упоминание в начале и в середине", texts: []string{"@ivan глянь, и @rvasily тоже"}, want: []string{"ivan", "rvasily"}},
+		{name: "Повторы и несколько текстов", texts: []string{"@ivan", "снова @ivan и @petr_1"}, want: []string{"ivan", "petr_1"}},
+		{name: "Почта не упоминание", texts: []string{"пишите на mail@ivan.ru"}},
+		{name: "Знаки препинания после логина", texts: []string{"(@ivan), @petr!"}, want: []string{"ivan", "petr"}},
+		{name: "Кириллица", texts: []string{"привет, @иван"}, want: []string{"иван"}},
+		{name: "Двойная собака", texts: []string{"@@ivan"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ParseMentions(tc.texts...))
+		})
+	}
+
+	t.Run("Не больше maxMentions адресатов", func(t *testing.T) {
+		var text []string
+		for i := 0; i < maxMentions+5; i++ {
+			text = append(text, fmt.Sprintf("@user%d", i))
+		}
+		assert.Len(t, ParseMentions(strings.Join(text, " ")), maxMentions)
+	})
+}
+
+func TestMilestone(t *testing.T) {
+	tests := []struct {
+		score     int
+		milestone int
+		candidate bool
+	}{
+		{score: -5, milestone: 0},
+		{score: 9, milestone: 0},
+		{score: 10, milestone: 10, candidate: true},
+		{score: 11, milestone: 10, candidate: true},
+		{score: 12, milestone: 10},
+		{score: 99, milestone: 50},
+		{score: 100, milestone: 100, candidate: true},
+		{score: 20000, milestone: 10000},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("Рейтинг %d", tc.score), func(t *testing.T) {
+			assert.Equal(t, tc.milestone, Milestone(tc.score))
+			assert.Equal(t, tc.candidate, MilestoneCandidate(tc.score))
+		})
+	}
+}
+
+// fakeUsers - UserResolver поверх словаря логинов.
+type fakeUsers map[string]int64
+
+func (f fakeUsers) GetUser(ctx context.Context, username string) (*user.User, error) {
+	if username == "broken" {
+		return nil, user.ErrStorage
+	}
+	id, ok := f[username]
+	if !ok {
+		return nil, user.ErrNoUser
+	}
+	return &user.User{ID: id, Username: username}, nil
+}
+
+func TestDispatcherBuild(t *testing.T) {
+	users := fakeUsers{"ivan": 1, "petr": 2, "anna": 3}
+	actor := user.User{ID: 2, Username: "petr"}
+
+	parent := &posts.Comment{ID: primitive.NewObjectID(), Author: &user.User{ID: 3, Username: "anna"}, Body: "first"}
+	orphan := &posts.Comment{ID: primitive.NewObjectID(), Author: posts.DeletedAuthor(), Body: "second"}
+	post := &posts.Post{
+		ID:       primitive.NewObjectID(),
+		Title:    "Пост @anna",
+		Author:   &user.User{ID: 1, Username: "ivan"},
+		Comments: []*posts.Comment{parent, orphan},
+	}
+	orphanPost := &posts.Post{ID: post.ID, Author: posts.DeletedAuthor(), Comments: []*posts.Comment{}}
+	reply := func(body string, parentID *primitive.ObjectID) *posts.Comment {
+		return &posts.Comment{ID: primitive.NewObjectID(), Author: &actor, Body: body, ParentID: parentID}
+	}
+
+	type want struct {
+		userID int64
+		typ    Type
+	}
+	tests := []struct {
+		name    string
+		ev      Event
+		want    []want
+		wantErr error
+	}{
+		{
+			name: "Комментарий к посту уведомляет автора поста",
+			ev:   Event{Kind: EventComment, Actor: actor, Post: post, Comment: reply("спасибо", nil)},
+			want: []want{{1, TypeReply}},
+		},
+		{
+			name: "Ответ на комментарий уведомляет автора комментария",
+			ev:   Event{Kind: EventComment, Actor: actor, Post: post, Comment: reply("согласен", &parent.ID)},
+			want: []want{{3, TypeReply}},
+		},
+		{
+			name: "Ответ на пост удалённого пользователя никого не уведомляет",
+			ev:   Event{Kind: EventComment, Actor: actor, Post: orphanPost, Comment: reply("есть кто?", nil)},
+		},
+		{
+			name: "Ответ на комментарий удалённого пользователя никого не уведомляет",
+			ev:   Event{Kind: EventComment, Actor: actor, Post: post, Comment: reply("есть кто?", &orphan.ID)},
+		},
+		{
+			name: "Упоминания без повторов, себя и незнакомых",
+			ev:   Event{Kind: EventComment, Actor: actor, Post: post, Comment: reply("@ivan @anna @petr @nobody", nil)},
+			want: []want{{1, TypeReply}, {3, TypeMention}},
+		},
+		{
+			name: "Свой пост не уведомляет",
+			ev:   Event{Kind: EventComment, Actor: user.User{ID: 1, Username: "ivan"}, Post: post, Comment: reply("сам себе", nil)},
+		},
+		{
+			name: "Упоминание в новом посте",
+			ev:   Event{Kind: EventPost, Actor: actor, Post: post},
+			want: []want{{3, TypeMention}},
+		},
+		{
+			name: "Рейтинг ниже рубежа",
+			ev:   Event{Kind: EventVote, Actor: actor, Post: &posts.Post{ID: post.ID, Author: post.Author, Score: 9}},
+		},
+		{
+			name: "Рубеж рейтинга",
+			ev:   Event{Kind: EventVote, Actor: actor, Post: &posts.Post{ID: post.ID, Author: post.Author, Score: 11}},
+			want: []want{{1, TypeMilestone}},
+		},
+		{
+			name: "Рубеж поста удалённого пользователя",
+			ev:   Event{Kind: EventVote, Actor: actor, Post: &posts.Post{ID: post.ID, Author: posts.DeletedAuthor(), Score: 11}},
+		},
+		{
+			name:    "Ошибка поиска пользователя",
+			ev:      Event{Kind: EventComment, Actor: actor, Post: post, Comment: reply("@broken", nil)},
+			wantErr: user.ErrStorage,
+		},
+	}
+
+	d := NewDispatcher(nil, users, zap.NewNop().Sugar(), 1)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			items, err := d.build(context.Background(), tc.ev)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			var got []want
+			for _, item := range items {
+				got = append(got, want{item.UserID, item.Type})
+				assert.Equal(t, tc.ev.Post.ID, item.PostID)
+				switch item.Type {
+				case TypeMilestone:
+					assert.Equal(t, 10, item.Milestone)
+					assert.Equal(t, fmt.Sprintf("milestone:%s:10", post.ID.Hex()), item.Key)
+				default:
+					assert.Equal(t, actor.Username, item.Actor)
+					if tc.ev.Comment != nil {
+						assert.Equal(t, tc.ev.Comment.ID, *item.CommentID)
+					}
+				}
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDispatcherRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := NewMockNotificationRepo(ctrl)
+	d := NewDispatcher(repo, fakeUsers{}, zap.NewNop().Sugar(), 2)
+	post := &posts.Post{ID: primitive.NewObjectID(), Author: &user.User{ID: 1, Username: "ivan"}, Score: 10}
+	ev := Event{Kind: EventVote, Actor: user.User{ID: 2, Username: "petr"}, Post: post}
+
+	t.Run("Переполненная очередь теряет событие, а не блокирует", func(t *testing.T) {
+		assert.True(t, d.Publish(ev))
+		assert.True(t, d.Publish(ev))
+		assert.False(t, d.Publish(ev))
+	})
+
+	t.Run("Остановка разбирает очередь до конца", func(t *testing.T) {
+		done := make(chan struct{})
+		repo.EXPECT().Create(gomock.Any(), gomock.Len(1)).Return(nil).Times(2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		go func() {
+			defer close(done)
+			d.Run(ctx)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("dispatcher did not stop")
+		}
+	})
+
+	t.Run("Ошибка хранилища не останавливает обработку", func(t *testing.T) {
+		repo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(ErrStorage)
+		repo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+
+		assert.True(t, d.Publish(ev))
+		assert.True(t, d.Publish(ev))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		d.Run(ctx)
+	})
+}
@@ -0,0 +1,166 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"redditclone/internal/logging"
+	"redditclone/internal/posts"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrFailedConvert = errors.New("failed to convert values")
+	// ErrStorage оборачивает ошибки монги, исходная ошибка доступна через errors.Is/As.
+	ErrStorage = errors.New("storage error")
+)
+
+// mongoDuplicateKey - код ошибки монги при нарушении уникального индекса.
+const mongoDuplicateKey = 11000
+
+// storageError пишет ошибку монги в лог запроса и оборачивает её в ErrStorage.
+func storageError(ctx context.Context, op string, err error) error {
+	logging.FromContext(ctx, nil).Errorw("storage error", "op", op, "error", err)
+	return fmt.Errorf("%w: %w", ErrStorage, err)
+}
+
+type NotificationMongoRepository struct {
+	DB *mongo.Collection
+	// Таймауты на одну операцию чтения/записи в монгу. 0 - без таймаута, только контекст запроса.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+func NewMongoRepo(db *mongo.Collection) *NotificationMongoRepository {
+	return &NotificationMongoRepository{DB: db}
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// EnsureIndexes создаёт индекс под выдачу ленты уведомлений и уникальный индекс по Key.
+func (repo *NotificationMongoRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "created", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "read", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+	})
+	if err != nil {
+		return storageError(ctx, "notifications.EnsureIndexes", err)
+	}
+	return nil
+}
+
+func (repo *NotificationMongoRepository) Create(ctx context.Context, items []*Notification) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if item.Created.IsZero() {
+			item.Created = time.Now().UTC()
+		}
+		docs = append(docs, item)
+	}
+
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	// Неупорядоченная вставка: повтор одного уведомления не мешает сохранить остальные.
+	_, err := repo.DB.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err != nil && !onlyDuplicates(err) {
+		return storageError(ctx, "notifications.Create", err)
+	}
+	return nil
+}
+
+// onlyDuplicates сообщает, что вставка упала только на уже существующих ключах.
+func onlyDuplicates(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != mongoDuplicateKey {
+			return false
+		}
+	}
+	return true
+}
+
+func (repo *NotificationMongoRepository) List(ctx context.Context, userID int64, unreadOnly bool, offset, limit int) ([]*Notification, error) {
+	filter := bson.M{"userId": userID}
+	if unreadOnly {
+		filter["read"] = false
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, storageError(ctx, "notifications.List", err)
+	}
+
+	items := []*Notification{}
+	if err = c.All(ctx, &items); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return items, nil
+}
+
+func (repo *NotificationMongoRepository) MarkRead(ctx context.Context, userID int64, ids []primitive.ObjectID) (int64, error) {
+	filter := bson.M{"userId": userID, "read": false}
+	if len(ids) > 0 {
+		filter["_id"] = bson.M{"$in": ids}
+	}
+
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result, err := repo.DB.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"read": true}})
+	if err != nil {
+		return 0, storageError(ctx, "notifications.MarkRead", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+func (repo *NotificationMongoRepository) UnreadCount(ctx context.Context, userID int64) (int64, error) {
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	count, err := repo.DB.CountDocuments(ctx, bson.M{"userId": userID, "read": false})
+	if err != nil {
+		return 0, storageError(ctx, "notifications.UnreadCount", err)
+	}
+	return count, nil
+}
+
+func (repo *NotificationMongoRepository) DeleteUser(ctx context.Context, userID int64, username string) (int64, error) {
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result, err := repo.DB.DeleteMany(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return 0, storageError(ctx, "notifications.DeleteUser", err)
+	}
+	_, err = repo.DB.UpdateMany(ctx, bson.M{"actor": username}, bson.M{"$set": bson.M{"actor": posts.DeletedUsername}})
+	if err != nil {
+		return result.DeletedCount, storageError(ctx, "notifications.DeleteUser", err)
+	}
+	return result.DeletedCount, nil
+}
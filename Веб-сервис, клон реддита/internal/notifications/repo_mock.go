@@ -0,0 +1,110 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: notifications.go
+
+// Package notifications is a generated GoMock package.
+package notifications
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	primitive "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockNotificationRepo is a mock of NotificationRepo interface.
+type MockNotificationRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotificationRepoMockRecorder
+}
+
+// MockNotificationRepoMockRecorder is the mock recorder for MockNotificationRepo.
+type MockNotificationRepoMockRecorder struct {
+	mock *MockNotificationRepo
+}
+
+// NewMockNotificationRepo creates a new mock instance.
+func NewMockNotificationRepo(ctrl *gomock.Controller) *MockNotificationRepo {
+	mock := &MockNotificationRepo{ctrl: ctrl}
+	mock.recorder = &MockNotificationRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNotificationRepo) EXPECT() *MockNotificationRepoMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockNotificationRepo) Create(ctx context.Context, items []*Notification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, items)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockNotificationRepoMockRecorder) Create(ctx, items interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockNotificationRepo)(nil).Create), ctx, items)
+}
+
+// DeleteUser mocks base method.
+func (m *MockNotificationRepo) DeleteUser(ctx context.Context, userID int64, username string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, userID, username)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockNotificationRepoMockRecorder) DeleteUser(ctx, userID, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockNotificationRepo)(nil).DeleteUser), ctx, userID, username)
+}
+
+// List mocks base method.
+func (m *MockNotificationRepo) List(ctx context.Context, userID int64, unreadOnly bool, offset, limit int) ([]*Notification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, userID, unreadOnly, offset, limit)
+	ret0, _ := ret[0].([]*Notification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockNotificationRepoMockRecorder) List(ctx, userID, unreadOnly, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockNotificationRepo)(nil).List), ctx, userID, unreadOnly, offset, limit)
+}
+
+// MarkRead mocks base method.
+func (m *MockNotificationRepo) MarkRead(ctx context.Context, userID int64, ids []primitive.ObjectID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkRead", ctx, userID, ids)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkRead indicates an expected call of MarkRead.
+func (mr *MockNotificationRepoMockRecorder) MarkRead(ctx, userID, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkRead", reflect.TypeOf((*MockNotificationRepo)(nil).MarkRead), ctx, userID, ids)
+}
+
+// UnreadCount mocks base method.
+func (m *MockNotificationRepo) UnreadCount(ctx context.Context, userID int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnreadCount", ctx, userID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnreadCount indicates an expected call of UnreadCount.
+func (mr *MockNotificationRepoMockRecorder) UnreadCount(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnreadCount", reflect.TypeOf((*MockNotificationRepo)(nil).UnreadCount), ctx, userID)
+}
@@ -0,0 +1,291 @@
+package notifications
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"redditclone/internal/logging"
+	"testing"
+	"time"
+)
+
+func TestCreate(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	items := func() []*Notification {
+		return []*Notification{
+			{UserID: 1, Type: TypeReply, PostID: primitive.NewObjectID()},
+			{UserID: 2, Type: TypeMilestone, PostID: primitive.NewObjectID(), Milestone: 10, Key: "milestone:1:10"},
+		}
+	}
+
+	var tests = []struct {
+		name         string
+		items        []*Notification
+		mockResponse []bson.D
+		wantErr      error
+	}{
+		{
+			name:         "Проверка на успешное создание",
+			items:        items(),
+			mockResponse: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2})},
+		},
+		{
+			name:  "Повторное уведомление о рубеже не считается ошибкой",
+			items: items(),
+			mockResponse: []bson.D{mtest.CreateWriteErrorsResponse(
+				mtest.WriteError{Index: 1, Code: mongoDuplicateKey, Message: "duplicate key"},
+			)},
+		},
+		{
+			name:  "Проверка на прочую ошибку записи",
+			items: items(),
+			mockResponse: []bson.D{mtest.CreateWriteErrorsResponse(
+				mtest.WriteError{Index: 0, Code: 121, Message: "validation failed"},
+				mtest.WriteError{Index: 1, Code: mongoDuplicateKey, Message: "duplicate key"},
+			)},
+			wantErr: ErrStorage,
+		},
+		{
+			name:         "Проверка на ошибку при запросе",
+			items:        items(),
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+		{
+			name: "Пустой список не ходит в базу",
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			err := repo.Create(context.Background(), tc.items)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			for _, item := range tc.items {
+				assert.False(t, item.Created.IsZero(), "created is not set")
+			}
+			if tc.items == nil {
+				assert.Nil(t, mt.GetStartedEvent())
+			}
+		})
+	}
+}
+
+func TestListNotifications(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	created := time.Date(2024, 5, 7, 20, 38, 17, 0, time.UTC)
+	postID := primitive.NewObjectID()
+
+	var tests = []struct {
+		name         string
+		unreadOnly   bool
+		mockResponse []bson.D
+		wantFilter   bson.D
+		want         []*Notification
+		wantErr      error
+	}{
+		{
+			name: "Проверка на успешное получение",
+			mockResponse: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+					bson.D{{Key: "userId", Value: int64(1)}, {Key: "type", Value: "reply"}, {Key: "actor", Value: "ivan"}, {Key: "postId", Value: postID}, {Key: "text", Value: "hi"}, {Key: "created", Value: created}},
+				),
+			},
+			wantFilter: bson.D{{Key: "userId", Value: int64(1)}},
+			want: []*Notification{
+				{UserID: 1, Type: TypeReply, Actor: "ivan", PostID: postID, Text: "hi", Created: created},
+			},
+		},
+		{
+			name:       "Только непрочитанные",
+			unreadOnly: true,
+			mockResponse: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch),
+			},
+			wantFilter: bson.D{{Key: "read", Value: false}, {Key: "userId", Value: int64(1)}},
+			want:       []*Notification{},
+		},
+		{
+			name:         "Проверка на ошибку при запросе",
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+		{
+			name: "Проверка на обработку сломанного bson",
+			mockResponse: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+					bson.D{{Key: "userId", Value: "не число"}},
+				),
+			},
+			wantErr: ErrFailedConvert,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			items, err := repo.List(context.Background(), 1, tc.unreadOnly, 0, 10)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, items)
+
+			var cmd struct {
+				Filter bson.D `bson:"filter"`
+			}
+			assert.NoError(t, bson.Unmarshal(mt.GetStartedEvent().Command, &cmd))
+			assert.ElementsMatch(t, tc.wantFilter, cmd.Filter)
+		})
+	}
+}
+
+func TestMarkRead(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	id := primitive.NewObjectID()
+
+	var tests = []struct {
+		name         string
+		ids          []primitive.ObjectID
+		mockResponse []bson.D
+		wantIDFilter bool
+		want         int64
+		wantErr      error
+	}{
+		{
+			name:         "Прочитать выбранные",
+			ids:          []primitive.ObjectID{id},
+			mockResponse: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1})},
+			wantIDFilter: true,
+			want:         1,
+		},
+		{
+			name:         "Прочитать все",
+			mockResponse: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 3}, bson.E{Key: "nModified", Value: 3})},
+			want:         3,
+		},
+		{
+			name:         "Проверка на ошибку при запросе",
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			updated, err := repo.MarkRead(context.Background(), 1, tc.ids)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, updated)
+
+			var cmd struct {
+				Updates []struct {
+					Q bson.M `bson:"q"`
+				} `bson:"updates"`
+			}
+			assert.NoError(t, bson.Unmarshal(mt.GetStartedEvent().Command, &cmd))
+			if assert.Len(t, cmd.Updates, 1) {
+				_, hasID := cmd.Updates[0].Q["_id"]
+				assert.Equal(t, tc.wantIDFilter, hasID)
+				assert.Equal(t, false, cmd.Updates[0].Q["read"])
+			}
+		})
+	}
+}
+
+func TestUnreadCount(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Проверка на успешный подсчёт", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{{Key: "n", Value: int32(4)}}))
+
+		count, err := repo.UnreadCount(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+	})
+
+	mt.Run("Проверка на ошибку при запросе", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.UnreadCount(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestDeleteUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Свои уведомления удаляются, в чужих логин заменяется", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 5}, bson.E{Key: "nModified", Value: 5}),
+		)
+
+		n, err := repo.DeleteUser(context.Background(), 1, "rvasily")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+
+		events := mt.GetAllStartedEvents()
+		if assert.Len(t, events, 2) {
+			del := events[0].Command.Lookup("deletes").Array().Index(0).Value().Document()
+			assert.Equal(t, int64(1), del.Lookup("q", "userId").Int64())
+			update := events[1].Command.Lookup("updates").Array().Index(0).Value().Document()
+			assert.Equal(t, "rvasily", update.Lookup("q", "actor").StringValue())
+			assert.Equal(t, "[deleted]", update.Lookup("u", "$set", "actor").StringValue())
+		}
+	})
+
+	mt.Run("Проверка на ошибку при удалении", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.DeleteUser(context.Background(), 1, "rvasily")
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestStorageErrorLogged(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Ошибка монги пишется в лог запроса", func(mt *mtest.T) {
+		core, logs := observer.New(zap.ErrorLevel)
+		ctx := logging.NewContext(context.Background(), zap.New(core).Sugar().With("request_id", "req-1"))
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		repo := NewMongoRepo(mt.Coll)
+		_, err := repo.UnreadCount(ctx, 1)
+		assert.ErrorIs(t, err, ErrStorage)
+
+		entries := logs.AllUntimed()
+		if assert.Len(t, entries, 1) {
+			fields := entries[0].ContextMap()
+			assert.Equal(t, "notifications.UnreadCount", fields["op"])
+			assert.Equal(t, "req-1", fields["request_id"])
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package postcache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore - LRU в памяти процесса на Size записей. Подходит для одного инстанса:
+// записи на других инстансах его не сбросят.
+type MemoryStore struct {
+	Size int
+	// Now можно подменить в тестах.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewMemoryStore(size int) *MemoryStore {
+	return &MemoryStore{
+		Size:    size,
+		Now:     time.Now,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && !s.Now().Before(entry.expires) {
+		s.remove(elem)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+// Set запоминает значение на ttl, 0 - пока его не вытеснят. Самая давно читанная запись вытесняется,
+// когда записей больше Size.
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = s.Now().Add(ttl)
+	}
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*memoryEntry)
+		entry.value, entry.expires = value, expires
+		s.order.MoveToFront(elem)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for s.Size > 0 && s.order.Len() > s.Size {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := s.entries[key]; ok {
+			s.remove(elem)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,303 @@
+package postcache
+
+import (
+	"context"
+	"errors"
+	"redditclone/internal/logging"
+	"redditclone/internal/posts"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// Store хранит закешированные значения. Значения уже сериализованы, поэтому каждый читатель
+// получает свою копию и может менять её, не портя кеш.
+type Store interface {
+	// Get отдаёт значение ключа, ok == false - ключа нет или он протух.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Repo кеширует чтения posts.PostRepo: отдельные посты (GetPost) и списки по областям (ListPosts).
+// Записи сбрасывают кеш поста, его категории, его автора и общий список.
+// Между чтением из базы и записью в кеш пост может успеть измениться - такое значение проживёт не дольше TTL.
+// Просмотры (AddViews) сбрасывают только кеш постов: в списках число просмотров отстаёт на время до TTL.
+type Repo struct {
+	TTL time.Duration
+
+	next   posts.PostRepo
+	store  Store
+	logger *zap.SugaredLogger
+}
+
+func NewRepo(next posts.PostRepo, store Store, ttl time.Duration, logger *zap.SugaredLogger) *Repo {
+	return &Repo{TTL: ttl, next: next, store: store, logger: logger}
+}
+
+// cachedList - обёртка для списка: bson умеет сериализовать только документ.
+type cachedList struct {
+	Posts []*posts.Post `bson:"posts"`
+}
+
+func postKey(postID primitive.ObjectID) string {
+	return "posts:post:" + postID.Hex()
+}
+
+func listKey(scope posts.Scope) string {
+	switch {
+	case scope.Category != "" && scope.Author != "":
+		return "posts:list:category:" + scope.Category + ":author:" + scope.Author
+	case scope.Category != "":
+		return "posts:list:category:" + scope.Category
+	case scope.Author != "":
+		return "posts:list:author:" + scope.Author
+	}
+	return "posts:list:all"
+}
+
+// keysOf - ключи, которые устаревают при изменении поста.
+func keysOf(post *posts.Post) []string {
+	keys := []string{postKey(post.ID), listKey(posts.Scope{}), listKey(posts.Scope{Category: post.Category})}
+	if post.Author != nil {
+		keys = append(keys,
+			listKey(posts.Scope{Author: post.Author.Username}),
+			listKey(posts.Scope{Category: post.Category, Author: post.Author.Username}))
+	}
+	return keys
+}
+
+func (r *Repo) GetPost(ctx context.Context, postID primitive.ObjectID) (*posts.Post, error) {
+	var post *posts.Post
+	if r.get(ctx, postKey(postID), &post) {
+		return post, r.linkOriginals(ctx, []*posts.Post{post})
+	}
+	post, err := r.next.GetPost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	r.set(ctx, postKey(postID), post)
+	return post, nil
+}
+
+func (r *Repo) ListPosts(ctx context.Context, scope posts.Scope) ([]*posts.Post, error) {
+	var cached cachedList
+	if r.get(ctx, listKey(scope), &cached) {
+		return cached.Posts, r.linkOriginals(ctx, cached.Posts)
+	}
+	list, err := r.next.ListPosts(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	r.set(ctx, listKey(scope), cachedList{Posts: list})
+	return list, nil
+}
+
+// linkOriginals заново подставляет кросспостам рейтинг оригинала: в кеше он не хранится,
+// а сам оригинал берётся из кеша постов, который сбрасывается при каждом голосе.
+func (r *Repo) linkOriginals(ctx context.Context, list []*posts.Post) error {
+	for _, post := range list {
+		if post.Crosspost == nil {
+			continue
+		}
+		original, err := r.GetPost(ctx, post.Crosspost.PostID)
+		switch {
+		case errors.Is(err, posts.ErrPostNotFound):
+			post.Crosspost.Score, post.Crosspost.Available = 0, false
+		case err != nil:
+			return err
+		default:
+			post.Crosspost.Score, post.Crosspost.Available = original.Score, !original.Removed
+		}
+	}
+	return nil
+}
+
+// get читает значение из кеша. Ошибка кеша не ошибка запроса: пишем в лог и идём в базу.
+func (r *Repo) get(ctx context.Context, key string, v interface{}) bool {
+	data, ok, err := r.store.Get(ctx, key)
+	if err != nil {
+		logging.FromContext(ctx, r.logger).Warnw("posts cache read failed", "key", key, "error", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if err = bson.Unmarshal(data, v); err != nil {
+		logging.FromContext(ctx, r.logger).Warnw("posts cache entry is broken", "key", key, "error", err)
+		return false
+	}
+	return true
+}
+
+func (r *Repo) set(ctx context.Context, key string, v interface{}) {
+	data, err := bson.Marshal(v)
+	if err == nil {
+		err = r.store.Set(ctx, key, data, r.TTL)
+	}
+	if err != nil {
+		logging.FromContext(ctx, r.logger).Warnw("posts cache write failed", "key", key, "error", err)
+	}
+}
+
+// invalidate сбрасывает кеш изменённых постов. Если сбросить не удалось, устаревшие данные
+// проживут до TTL, поэтому запись всё равно считается успешной.
+func (r *Repo) invalidate(ctx context.Context, changed ...*posts.Post) {
+	var keys []string
+	for _, post := range changed {
+		if post != nil {
+			keys = append(keys, keysOf(post)...)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if err := r.store.Delete(ctx, keys...); err != nil {
+		logging.FromContext(ctx, r.logger).Errorw("posts cache invalidation failed", "keys", keys, "error", err)
+	}
+}
+
+// Чтения, которые не кешируются: фильтр GetPosts нельзя превратить в ключ, наборы id и авторов
+// у GetPostsByIDs и GetPostsByAuthors каждый раз свои, лента и поиск ссылок зависят от времени и курсора.
+
+func (r *Repo) GetPosts(ctx context.Context, filter func(*posts.Post) bool) ([]*posts.Post, error) {
+	return r.next.GetPosts(ctx, filter)
+}
+
+func (r *Repo) GetPostsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*posts.Post, error) {
+	return r.next.GetPostsByIDs(ctx, ids)
+}
+
+func (r *Repo) GetPostsByAuthors(ctx context.Context, usernames []string) ([]*posts.Post, error) {
+	return r.next.GetPostsByAuthors(ctx, usernames)
+}
+
+func (r *Repo) GetFeed(ctx context.Context, q posts.FeedQuery) (*posts.FeedPage, error) {
+	return r.next.GetFeed(ctx, q)
+}
+
+func (r *Repo) FindByURL(ctx context.Context, normalizedURL string, since time.Time, limit int) ([]*posts.Post, error) {
+	return r.next.FindByURL(ctx, normalizedURL, since, limit)
+}
+
+// Записи.
+
+func (r *Repo) VotePost(ctx context.Context, postID primitive.ObjectID, userID int64, voteVal int) (*posts.Post, error) {
+	post, err := r.next.VotePost(ctx, postID, userID, voteVal)
+	r.invalidate(ctx, post)
+	return post, err
+}
+
+func (r *Repo) MakePost(ctx context.Context, newPost *posts.PostForm, username string, userID int64) (*posts.Post, error) {
+	post, err := r.next.MakePost(ctx, newPost, username, userID)
+	r.invalidate(ctx, post)
+	return post, err
+}
+
+func (r *Repo) DeletePost(ctx context.Context, postID primitive.ObjectID, userID int64) (bool, error) {
+	// Категорию и автора удалённого поста уже не прочитать, поэтому узнаём их заранее.
+	post, err := r.GetPost(ctx, postID)
+	if err != nil {
+		return r.next.DeletePost(ctx, postID, userID)
+	}
+	deleted, err := r.next.DeletePost(ctx, postID, userID)
+	if deleted {
+		r.invalidate(ctx, post)
+	}
+	return deleted, err
+}
+
+func (r *Repo) MakeComment(ctx context.Context, postID, parentID primitive.ObjectID, comment, username string, userID int64) (*posts.Post, error) {
+	post, err := r.next.MakeComment(ctx, postID, parentID, comment, username, userID)
+	r.invalidate(ctx, post)
+	return post, err
+}
+
+func (r *Repo) DeleteComment(ctx context.Context, postID primitive.ObjectID, commentID primitive.ObjectID, userID int64) (*posts.Post, error) {
+	post, err := r.next.DeleteComment(ctx, postID, commentID, userID)
+	r.invalidate(ctx, post)
+	return post, err
+}
+
+func (r *Repo) RestorePost(ctx context.Context, postID primitive.ObjectID, userID int64) (*posts.Post, error) {
+	post, err := r.next.RestorePost(ctx, postID, userID)
+	r.invalidate(ctx, post)
+	return post, err
+}
+
+func (r *Repo) RestoreComment(ctx context.Context, postID, commentID primitive.ObjectID, userID int64) (*posts.Post, error) {
+	post, err := r.next.RestoreComment(ctx, postID, commentID, userID)
+	r.invalidate(ctx, post)
+	return post, err
+}
+
+// Purge стирает только то, что и так не видно читателям, поэтому кеш не трогает.
+func (r *Repo) Purge(ctx context.Context, before time.Time) (posts.PurgeResult, error) {
+	return r.next.Purge(ctx, before)
+}
+
+func (r *Repo) AddViews(ctx context.Context, views map[primitive.ObjectID]int) error {
+	err := r.next.AddViews(ctx, views)
+	keys := make([]string, 0, len(views))
+	for postID := range views {
+		keys = append(keys, postKey(postID))
+	}
+	if len(keys) > 0 {
+		if delErr := r.store.Delete(ctx, keys...); delErr != nil {
+			logging.FromContext(ctx, r.logger).Warnw("posts cache invalidation failed", "keys", len(keys), "error", delErr)
+		}
+	}
+	return err
+}
+
+func (r *Repo) SetPostFlags(ctx context.Context, postID primitive.ObjectID, flags posts.PostFlags) (*posts.Post, error) {
+	post, err := r.next.SetPostFlags(ctx, postID, flags)
+	r.invalidate(ctx, post)
+	return post, err
+}
+
+func (r *Repo) SetCommentRemoved(ctx context.Context, postID, commentID primitive.ObjectID, removed bool) (*posts.Post, error) {
+	post, err := r.next.SetCommentRemoved(ctx, postID, commentID, removed)
+	r.invalidate(ctx, post)
+	return post, err
+}
+
+func (r *Repo) VotePoll(ctx context.Context, postID primitive.ObjectID, userID int64, option int) (*posts.Post, error) {
+	post, err := r.next.VotePoll(ctx, postID, userID, option)
+	r.invalidate(ctx, post)
+	return post, err
+}
+
+func (r *Repo) Crosspost(ctx context.Context, originalID primitive.ObjectID, form *posts.CrosspostForm, username string, userID int64) (*posts.Post, error) {
+	post, err := r.next.Crosspost(ctx, originalID, form, username, userID)
+	r.invalidate(ctx, post)
+	return post, err
+}
+
+// AnonymizeUser меняет заранее неизвестный набор постов, поэтому сначала находит всё, в чём пользователь
+// участвовал, и после записи сбрасывает кеш этих постов. Удаление аккаунта редкое, полный проход допустим.
+func (r *Repo) AnonymizeUser(ctx context.Context, userID int64) (posts.AnonymizeResult, error) {
+	touched, err := r.next.GetPosts(ctx, posts.FilterByParticipant(userID))
+	if err != nil {
+		return posts.AnonymizeResult{}, err
+	}
+	res, err := r.next.AnonymizeUser(ctx, userID)
+	// Посты пользователя переезжают в списки автора [deleted].
+	changed := append([]*posts.Post{}, touched...)
+	for _, post := range touched {
+		if post.Author != nil && post.Author.ID == userID {
+			anonymized := *post
+			anonymized.Author = posts.DeletedAuthor()
+			changed = append(changed, &anonymized)
+		}
+	}
+	r.invalidate(ctx, changed...)
+	return res, err
+}
+
+// UserContent нужен только выгрузке и не кешируется.
+func (r *Repo) UserContent(ctx context.Context, userID int64) ([]*posts.Post, error) {
+	return r.next.UserContent(ctx, userID)
+}
@@ -0,0 +1,341 @@
+package postcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"redditclone/internal/posts"
+	"redditclone/internal/user"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func TestStores(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	stores := map[string]func(t *testing.T) (Store, func(time.Duration)){
+		"memory": func(t *testing.T) (Store, func(time.Duration)) {
+			s := NewMemoryStore(10)
+			s.Now = func() time.Time { return now }
+			return s, func(d time.Duration) { now = now.Add(d) }
+		},
+		"redis": func(t *testing.T) (Store, func(time.Duration)) {
+			srv := miniredis.RunT(t)
+			pool := &redis.Pool{
+				DialContext: func(ctx context.Context) (redis.Conn, error) {
+					return redis.DialContext(ctx, "tcp", srv.Addr())
+				},
+			}
+			t.Cleanup(func() { pool.Close() })
+			return NewRedisStore(pool), srv.FastForward
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s, wait := newStore(t)
+			ctx := context.Background()
+
+			_, ok, err := s.Get(ctx, "a")
+			assert.NoError(t, err)
+			assert.False(t, ok, "missing key")
+
+			assert.NoError(t, s.Set(ctx, "a", []byte("1"), time.Minute))
+			assert.NoError(t, s.Set(ctx, "b", []byte("2"), 0))
+			value, ok, err := s.Get(ctx, "a")
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, []byte("1"), value)
+
+			assert.NoError(t, s.Delete(ctx, "a", "missing"))
+			_, ok, _ = s.Get(ctx, "a")
+			assert.False(t, ok, "deleted key")
+
+			assert.NoError(t, s.Set(ctx, "a", []byte("3"), time.Minute))
+			wait(time.Minute)
+			_, ok, _ = s.Get(ctx, "a")
+			assert.False(t, ok, "expired key")
+			_, ok, _ = s.Get(ctx, "b")
+			assert.True(t, ok, "key without ttl")
+		})
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewMemoryStore(2)
+	ctx := context.Background()
+
+	_ = s.Set(ctx, "a", []byte("1"), 0)
+	_ = s.Set(ctx, "b", []byte("2"), 0)
+	_, _, _ = s.Get(ctx, "a")
+	_ = s.Set(ctx, "c", []byte("3"), 0)
+
+	_, ok, _ := s.Get(ctx, "b")
+	assert.False(t, ok, "b was read least recently")
+	_, ok, _ = s.Get(ctx, "a")
+	assert.True(t, ok)
+	_, ok, _ = s.Get(ctx, "c")
+	assert.True(t, ok)
+}
+
+// brokenStore - недоступный кеш.
+type brokenStore struct{}
+
+func (brokenStore) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("cache is down")
+}
+
+func (brokenStore) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("cache is down")
+}
+
+func (brokenStore) Delete(context.Context, ...string) error {
+	return errors.New("cache is down")
+}
+
+func newPost(category, author string) *posts.Post {
+	return &posts.Post{
+		ID:       primitive.NewObjectID(),
+		Type:     posts.TypeText,
+		Title:    "title",
+		Category: category,
+		Author:   &user.User{ID: 1, Username: author},
+		Votes:    []*posts.Vote{},
+		Comments: []*posts.Comment{},
+	}
+}
+
+func TestRepoGetPost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	next := posts.NewMockPostRepo(ctrl)
+	repo := NewRepo(next, NewMemoryStore(100), time.Minute, zap.NewNop().Sugar())
+	ctx := context.Background()
+	post := newPost("music", "rvasily")
+
+	next.EXPECT().GetPost(gomock.Any(), post.ID).Return(post, nil).Times(1)
+	first, err := repo.GetPost(ctx, post.ID)
+	require.NoError(t, err)
+	want, _ := json.Marshal(first)
+
+	// Читатель меняет свою копию (прячет комментарии, подставляет опрос) - кеш это не задевает.
+	first.Title = "changed"
+	cached, err := repo.GetPost(ctx, post.ID)
+	require.NoError(t, err)
+	got, _ := json.Marshal(cached)
+	assert.JSONEq(t, string(want), string(got))
+
+	// Голос сбрасывает кеш поста.
+	voted := *post
+	voted.Score = 1
+	next.EXPECT().VotePost(gomock.Any(), post.ID, int64(2), 1).Return(&voted, nil)
+	_, err = repo.VotePost(ctx, post.ID, 2, 1)
+	require.NoError(t, err)
+	next.EXPECT().GetPost(gomock.Any(), post.ID).Return(&voted, nil)
+	fresh, err := repo.GetPost(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fresh.Score)
+
+	// Ошибки не кешируются.
+	missing := primitive.NewObjectID()
+	next.EXPECT().GetPost(gomock.Any(), missing).Return(nil, posts.ErrPostNotFound).Times(2)
+	_, err = repo.GetPost(ctx, missing)
+	assert.ErrorIs(t, err, posts.ErrPostNotFound)
+	_, err = repo.GetPost(ctx, missing)
+	assert.ErrorIs(t, err, posts.ErrPostNotFound)
+}
+
+func TestRepoListInvalidation(t *testing.T) {
+	music, news := newPost("music", "rvasily"), newPost("news", "ivan")
+	all := posts.Scope{}
+	musicScope, newsScope := posts.Scope{Category: "music"}, posts.Scope{Category: "news"}
+	authorScope := posts.Scope{Author: "rvasily"}
+
+	testCases := []struct {
+		name string
+		// write меняет пост music.
+		write func(repo *Repo, next *posts.MockPostRepo) error
+		// stale - списки, которые должны прочитаться из базы заново.
+		stale []posts.Scope
+	}{
+		{
+			name: "голос",
+			write: func(repo *Repo, next *posts.MockPostRepo) error {
+				next.EXPECT().VotePost(gomock.Any(), music.ID, int64(2), 1).Return(music, nil)
+				_, err := repo.VotePost(context.Background(), music.ID, 2, 1)
+				return err
+			},
+			stale: []posts.Scope{all, musicScope, authorScope},
+		},
+		{
+			name: "новый пост",
+			write: func(repo *Repo, next *posts.MockPostRepo) error {
+				next.EXPECT().MakePost(gomock.Any(), gomock.Any(), "rvasily", int64(1)).Return(music, nil)
+				_, err := repo.MakePost(context.Background(), &posts.PostForm{}, "rvasily", 1)
+				return err
+			},
+			stale: []posts.Scope{all, musicScope, authorScope},
+		},
+		{
+			name: "удаление",
+			write: func(repo *Repo, next *posts.MockPostRepo) error {
+				next.EXPECT().GetPost(gomock.Any(), music.ID).Return(music, nil)
+				next.EXPECT().DeletePost(gomock.Any(), music.ID, int64(1)).Return(true, nil)
+				_, err := repo.DeletePost(context.Background(), music.ID, 1)
+				return err
+			},
+			stale: []posts.Scope{all, musicScope, authorScope},
+		},
+		{
+			name: "неудачная запись",
+			write: func(repo *Repo, next *posts.MockPostRepo) error {
+				next.EXPECT().MakeComment(gomock.Any(), music.ID, primitive.NilObjectID, "hi", "rvasily", int64(1)).
+					Return(nil, posts.ErrPostLocked)
+				_, err := repo.MakeComment(context.Background(), music.ID, primitive.NilObjectID, "hi", "rvasily", 1)
+				if errors.Is(err, posts.ErrPostLocked) {
+					return nil
+				}
+				return err
+			},
+		},
+		{
+			name: "просмотры не трогают списки",
+			write: func(repo *Repo, next *posts.MockPostRepo) error {
+				next.EXPECT().AddViews(gomock.Any(), map[primitive.ObjectID]int{music.ID: 3}).Return(nil)
+				return repo.AddViews(context.Background(), map[primitive.ObjectID]int{music.ID: 3})
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			next := posts.NewMockPostRepo(ctrl)
+			repo := NewRepo(next, NewMemoryStore(100), time.Minute, zap.NewNop().Sugar())
+			ctx := context.Background()
+
+			lists := map[posts.Scope][]*posts.Post{
+				all:         {music, news},
+				musicScope:  {music},
+				newsScope:   {news},
+				authorScope: {music},
+			}
+			for scope, list := range lists {
+				next.EXPECT().ListPosts(gomock.Any(), scope).Return(list, nil)
+				_, err := repo.ListPosts(ctx, scope)
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, tc.write(repo, next))
+
+			for _, scope := range tc.stale {
+				next.EXPECT().ListPosts(gomock.Any(), scope).Return(lists[scope], nil)
+			}
+			for scope, list := range lists {
+				got, err := repo.ListPosts(ctx, scope)
+				require.NoError(t, err)
+				assert.Len(t, got, len(list))
+			}
+		})
+	}
+}
+
+func TestRepoCrosspostScore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	next := posts.NewMockPostRepo(ctrl)
+	repo := NewRepo(next, NewMemoryStore(100), time.Minute, zap.NewNop().Sugar())
+	ctx := context.Background()
+
+	original := newPost("music", "rvasily")
+	original.Score = 5
+	crosspost := newPost("news", "ivan")
+	crosspost.Crosspost = &posts.CrosspostRef{PostID: original.ID, Category: "music", Score: 5, Available: true}
+	scope := posts.Scope{Category: "news"}
+
+	next.EXPECT().ListPosts(gomock.Any(), scope).Return([]*posts.Post{crosspost}, nil)
+	_, err := repo.ListPosts(ctx, scope)
+	require.NoError(t, err)
+
+	// Оригинал набрал голос: список кросспостов остаётся в кеше, а рейтинг берётся свежий.
+	voted := *original
+	voted.Score = 6
+	next.EXPECT().VotePost(gomock.Any(), original.ID, int64(2), 1).Return(&voted, nil)
+	_, err = repo.VotePost(ctx, original.ID, 2, 1)
+	require.NoError(t, err)
+	next.EXPECT().GetPost(gomock.Any(), original.ID).Return(&voted, nil)
+
+	list, err := repo.ListPosts(ctx, scope)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, 6, list[0].Crosspost.Score)
+	assert.True(t, list[0].Crosspost.Available)
+
+	// Оригинал удалён - кросспост остаётся, но без ссылки на него.
+	next.EXPECT().DeletePost(gomock.Any(), original.ID, int64(1)).Return(true, nil)
+	_, err = repo.DeletePost(ctx, original.ID, 1)
+	require.NoError(t, err)
+	next.EXPECT().GetPost(gomock.Any(), original.ID).Return(nil, posts.ErrPostNotFound)
+
+	list, err = repo.ListPosts(ctx, scope)
+	require.NoError(t, err)
+	assert.False(t, list[0].Crosspost.Available)
+}
+
+func TestRepoBrokenStore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	next := posts.NewMockPostRepo(ctrl)
+	repo := NewRepo(next, brokenStore{}, time.Minute, zap.NewNop().Sugar())
+	post := newPost("music", "rvasily")
+
+	next.EXPECT().ListPosts(gomock.Any(), posts.Scope{}).Return([]*posts.Post{post}, nil).Times(2)
+	for i := 0; i < 2; i++ {
+		list, err := repo.ListPosts(context.Background(), posts.Scope{})
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+	}
+
+	next.EXPECT().VotePost(gomock.Any(), post.ID, int64(1), 1).Return(post, nil)
+	_, err := repo.VotePost(context.Background(), post.ID, 1, 1)
+	assert.NoError(t, err, "failed invalidation does not fail the write")
+}
+
+func TestRepoAnonymizeUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	next := posts.NewMockPostRepo(ctrl)
+	repo := NewRepo(next, NewMemoryStore(100), time.Minute, zap.NewNop().Sugar())
+	ctx := context.Background()
+
+	own := newPost("music", "rvasily")
+	voted := newPost("news", "ivan")
+	voted.Votes = []*posts.Vote{{UserID: 1, Vote: 1}}
+	deletedScope := posts.Scope{Author: posts.DeletedUsername}
+	lists := map[posts.Scope][]*posts.Post{
+		{Author: "rvasily"}: {own},
+		{Category: "news"}:  {voted},
+		deletedScope:        {},
+	}
+	for scope, list := range lists {
+		next.EXPECT().ListPosts(gomock.Any(), scope).Return(list, nil)
+		_, err := repo.ListPosts(ctx, scope)
+		require.NoError(t, err)
+	}
+
+	next.EXPECT().GetPosts(gomock.Any(), gomock.Any()).Return([]*posts.Post{own, voted}, nil)
+	next.EXPECT().AnonymizeUser(gomock.Any(), int64(1)).Return(posts.AnonymizeResult{Posts: 1, Votes: 1}, nil)
+	res, err := repo.AnonymizeUser(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, posts.AnonymizeResult{Posts: 1, Votes: 1}, res)
+
+	// Все три списка читаются из базы заново, в том числе список автора [deleted].
+	for scope := range lists {
+		next.EXPECT().ListPosts(gomock.Any(), scope).Return(nil, nil)
+		_, err = repo.ListPosts(ctx, scope)
+		require.NoError(t, err)
+	}
+}
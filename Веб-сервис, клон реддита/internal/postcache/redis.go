@@ -0,0 +1,74 @@
+package postcache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ConnGetter выдаёт соединения с redis, например *redis.Pool.
+type ConnGetter interface {
+	GetContext(ctx context.Context) (redis.Conn, error)
+}
+
+// RedisStore хранит кеш в redis, общий для всех инстансов: запись на одном сбрасывает кеш у всех.
+type RedisStore struct {
+	pool   ConnGetter
+	prefix string
+}
+
+func NewRedisStore(pool ConnGetter) *RedisStore {
+	return &RedisStore{pool: pool, prefix: "cache:"}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	value, err := redis.Bytes(redis.DoContext(conn, ctx, "GET", s.prefix+key))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := []interface{}{s.prefix + key, value}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	_, err = redis.DoContext(conn, ctx, "SET", args...)
+	return err
+}
+
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		args = append(args, s.prefix+key)
+	}
+	_, err = redis.DoContext(conn, ctx, "DEL", args...)
+	return err
+}
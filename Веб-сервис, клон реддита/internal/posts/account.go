@@ -0,0 +1,180 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"redditclone/internal/user"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DeletedUsername - имя автора постов и комментариев удалённого пользователя.
+const DeletedUsername = user.DeletedUsername
+
+// AnonymizeResult - что затронуло удаление пользователя: его посты, посты с его комментариями,
+// посты, с которых сняты его голоса, и опросы, где он голосовал.
+type AnonymizeResult struct {
+	Posts     int64
+	Comments  int64
+	Votes     int64
+	PollVotes int64
+}
+
+// DeletedAuthor - автор, который остаётся у постов и комментариев удалённого пользователя.
+// id 0 не выдаётся ни одному пользователю, поэтому такие посты уже никто не удалит и не восстановит как автор.
+func DeletedAuthor() *user.User {
+	return &user.User{Username: DeletedUsername}
+}
+
+// FilterByParticipant оставляет посты, которые пользователь написал, комментировал или за которые голосовал.
+func FilterByParticipant(userID int64) func(*Post) bool {
+	return func(p *Post) bool {
+		if p.Author != nil && p.Author.ID == userID {
+			return true
+		}
+		if p.Poll != nil {
+			if _, ok := p.Poll.voteOf(userID); ok {
+				return true
+			}
+		}
+		for _, c := range p.Comments {
+			if c.Author != nil && c.Author.ID == userID {
+				return true
+			}
+		}
+		for _, v := range p.Votes {
+			if v.UserID == userID {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AnonymizeUser отвязывает от пользователя всё, что он оставил: посты и комментарии остаются,
+// но автором становится DeletedAuthor, а голоса снимаются с пересчётом счётчиков.
+// Удалённые, но ещё не стёртые посты тоже обезличиваются. Повторный вызов ничего не меняет.
+func (repo *PostMongoRepository) AnonymizeUser(ctx context.Context, userID int64) (AnonymizeResult, error) {
+	var res AnonymizeResult
+	author := DeletedAuthor()
+
+	writeCtx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	updated, err := repo.DB.UpdateMany(writeCtx,
+		bson.M{"author.id": userID},
+		bson.M{"$set": bson.M{"author": author}},
+	)
+	if err != nil {
+		return res, storageError(ctx, "posts.AnonymizeUser", err)
+	}
+	res.Posts = updated.ModifiedCount
+
+	updated, err = repo.DB.UpdateMany(writeCtx,
+		bson.M{"comments.author.id": userID},
+		bson.M{"$set": bson.M{"comments.$[c].author": author}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"c.author.id": userID}}}),
+	)
+	if err != nil {
+		return res, storageError(ctx, "posts.AnonymizeUser", err)
+	}
+	res.Comments = updated.ModifiedCount
+
+	// Кросспосты хранят копию автора оригинала.
+	_, err = repo.DB.UpdateMany(writeCtx,
+		bson.M{"crosspost.author.id": userID},
+		bson.M{"$set": bson.M{"crosspost.author": author}},
+	)
+	if err != nil {
+		return res, storageError(ctx, "posts.AnonymizeUser", err)
+	}
+
+	// Голос в опросе отозвать нельзя, и итоги закрытых опросов не должны меняться задним числом,
+	// поэтому голос остаётся, но без пользователя: id 0 никому не выдаётся, а ShowPoll его не узнаёт.
+	updated, err = repo.DB.UpdateMany(writeCtx,
+		bson.M{"poll.voters.userId": userID},
+		bson.M{"$set": bson.M{"poll.voters.$[v].userId": 0}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"v.userId": userID}}}),
+	)
+	if err != nil {
+		return res, storageError(ctx, "posts.AnonymizeUser", err)
+	}
+	res.PollVotes = updated.ModifiedCount
+
+	res.Votes, err = repo.removeVotes(ctx, userID)
+	return res, err
+}
+
+// removeVotes снимает голоса пользователя со всех постов и возвращает, со скольких постов они сняты.
+func (repo *PostMongoRepository) removeVotes(ctx context.Context, userID int64) (int64, error) {
+	readCtx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(readCtx, bson.M{"votes.userid": userID})
+	if err != nil {
+		return 0, storageError(ctx, "posts.removeVotes", err)
+	}
+	var voted []*Post
+	if err = c.All(readCtx, &voted); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+
+	var removed int64
+	for _, post := range voted {
+		changed := false
+		for attempt := 1; ; attempt++ {
+			changed, err = repo.writeVote(ctx, post, userID, 0)
+			if !errors.Is(err, ErrFailedUpdate) || attempt == maxVoteAttempts {
+				break
+			}
+			// Голоса поменялись между чтением и записью - перечитываем пост.
+			post, err = repo.findAnyPost(ctx, post.ID)
+			if err != nil {
+				break
+			}
+		}
+		switch {
+		case errors.Is(err, ErrPostNotFound):
+			// Пост успели стереть, снимать нечего.
+		case err != nil:
+			return removed, err
+		case changed:
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (repo *PostMongoRepository) UserContent(ctx context.Context, userID int64) ([]*Post, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"author.id": userID},
+		bson.M{"comments.author.id": userID},
+		bson.M{"votes.userid": userID},
+		bson.M{"poll.voters.userId": userID},
+	}}
+	opts := options.Find().SetSort(bson.D{{Key: "created", Value: 1}})
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, storageError(ctx, "posts.UserContent", err)
+	}
+
+	list := []*Post{}
+	if err = c.All(ctx, &list); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return list, nil
+}
+
+// findAnyPost читает пост как он есть в базе, в том числе удалённый.
+func (repo *PostMongoRepository) findAnyPost(ctx context.Context, postID primitive.ObjectID) (*Post, error) {
+	var post *Post
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	if err := repo.DB.FindOne(ctx, bson.M{"_id": postID}).Decode(&post); err != nil {
+		return nil, notFoundOr(ctx, "posts.findAnyPost", err, ErrPostNotFound)
+	}
+	return post, nil
+}
@@ -0,0 +1,172 @@
+package posts
+
+import (
+	"context"
+	"redditclone/internal/user"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestFilterByParticipant(t *testing.T) {
+	me := &user.User{ID: 1, Username: "rvasily"}
+	other := &user.User{ID: 2, Username: "ivan"}
+
+	testCases := []struct {
+		name string
+		post *Post
+		want bool
+	}{
+		{name: "автор", post: &Post{Author: me}, want: true},
+		{name: "комментарий", post: &Post{Author: other, Comments: []*Comment{{Author: other}, {Author: me}}}, want: true},
+		{name: "голос", post: &Post{Author: other, Votes: []*Vote{{UserID: 1, Vote: -1}}}, want: true},
+		{name: "голос в опросе", post: &Post{Author: other, Poll: &Poll{Voters: []PollVoter{{UserID: 1, Option: 2}}}}, want: true},
+		{name: "чужой пост", post: &Post{Author: other, Comments: []*Comment{{Author: other}}, Votes: []*Vote{{UserID: 2, Vote: 1}}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, FilterByParticipant(1)(tc.post))
+		})
+	}
+}
+
+func TestAnonymizeUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	votedID := primitive.NewObjectID()
+	voted := func(votes ...bson.D) bson.D {
+		list := bson.A{}
+		for _, v := range votes {
+			list = append(list, v)
+		}
+		return bson.D{
+			{Key: "_id", Value: votedID},
+			{Key: "score", Value: len(votes)},
+			{Key: "votes", Value: list},
+		}
+	}
+	vote := func(userID int64, value int) bson.D {
+		return bson.D{{Key: "userid", Value: userID}, {Key: "vote", Value: value}}
+	}
+	updated := func(n int) bson.D {
+		return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+	}
+
+	mt.Run("Автор обезличивается, голоса снимаются", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(
+			updated(2), // посты
+			updated(1), // комментарии
+			updated(0), // кросспосты
+			updated(1), // опросы
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, voted(vote(1, 1), vote(2, 1))),
+			updated(1),
+		)
+
+		res, err := repo.AnonymizeUser(context.Background(), 1)
+		require.NoError(t, err)
+		assert.Equal(t, AnonymizeResult{Posts: 2, Comments: 1, Votes: 1, PollVotes: 1}, res)
+
+		posts := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, int64(1), posts.Lookup("q", "author.id").Int64())
+		assert.Equal(t, DeletedUsername, posts.Lookup("u", "$set", "author", "username").StringValue())
+		assert.Equal(t, int64(0), posts.Lookup("u", "$set", "author", "id").Int64())
+
+		comments := mt.GetStartedEvent().Command
+		update := comments.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, DeletedUsername, update.Lookup("u", "$set", "comments.$[c].author", "username").StringValue())
+		assert.Equal(t, int64(1), update.Lookup("arrayFilters").Array().Index(0).Value().Document().Lookup("c.author.id").Int64())
+
+		mt.GetStartedEvent() // кросспосты
+		polls := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.EqualValues(t, 0, polls.Lookup("u", "$set", "poll.voters.$[v].userId").AsInt64(), "poll vote stays, the voter goes")
+		assert.Equal(t, int64(1), polls.Lookup("arrayFilters").Array().Index(0).Value().Document().Lookup("v.userId").Int64())
+		mt.GetStartedEvent() // поиск голосов
+		unvote := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		before, _ := unvote.Lookup("q", "votes").Array().Values()
+		assert.Len(t, before, 2, "write only if votes did not change")
+		set := unvote.Lookup("u", "$set").Document()
+		votes, _ := set.Lookup("votes").Array().Values()
+		require.Len(t, votes, 1)
+		assert.Equal(t, int64(2), votes[0].Document().Lookup("userid").Int64())
+		assert.EqualValues(t, 1, set.Lookup("score").AsInt64())
+		assert.EqualValues(t, 1, set.Lookup("votecount").AsInt64())
+		assert.EqualValues(t, 100, set.Lookup("upvotepercentage").AsInt64())
+	})
+
+	mt.Run("Голоса поменялись между чтением и записью", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(
+			updated(0), updated(0), updated(0), updated(0),
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, voted(vote(1, -1))),
+			updated(0),
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, voted(vote(1, -1), vote(3, 1))),
+			updated(1),
+		)
+
+		res, err := repo.AnonymizeUser(context.Background(), 1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), res.Votes)
+	})
+
+	mt.Run("Повторный вызов ничего не меняет", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(
+			updated(0), updated(0), updated(0), updated(0),
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch),
+		)
+
+		res, err := repo.AnonymizeUser(context.Background(), 1)
+		require.NoError(t, err)
+		assert.Equal(t, AnonymizeResult{}, res)
+	})
+
+	mt.Run("Ошибка хранилища", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.AnonymizeUser(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestUserContent(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Удалённые посты и комментарии попадают в выборку", func(mt *mtest.T) {
+		deletedID := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+			bson.D{
+				{Key: "_id", Value: deletedID},
+				{Key: "author", Value: bson.D{{Key: "id", Value: 1}, {Key: "username", Value: "rvasily"}}},
+				{Key: "deletedAt", Value: primitive.NewDateTimeFromTime(time.Now())},
+			},
+		))
+
+		repo := NewMongoRepo(mt.Coll)
+		list, err := repo.UserContent(context.Background(), 1)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, deletedID, list[0].ID)
+		assert.NotNil(t, list[0].Deleted)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter")
+		_, err = filter.Document().LookupErr("deletedAt")
+		assert.Error(t, err, "deleted posts are not filtered out")
+		clauses, _ := filter.Document().Lookup("$or").Array().Values()
+		assert.Len(t, clauses, 4, "author, comments, votes and poll votes")
+	})
+
+	mt.Run("Ошибка хранилища", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := NewMongoRepo(mt.Coll).UserContent(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
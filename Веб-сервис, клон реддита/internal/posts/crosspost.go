@@ -0,0 +1,220 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"redditclone/internal/user"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrSameCategory     = errors.New("crosspost to the category of the original")
+	ErrNotCrosspostable = errors.New("post can not be crossposted")
+	ErrBadURL           = errors.New("bad url")
+)
+
+// CrosspostRef - ссылка кросспоста на оригинал. Score и Available подставляются при чтении:
+// кросспост показывает текущий рейтинг оригинала, а не тот, что был при публикации.
+type CrosspostRef struct {
+	PostID    primitive.ObjectID `json:"postId" bson:"postId"`
+	Category  string             `json:"category" bson:"category"`
+	Title     string             `json:"title" bson:"title"`
+	Author    *user.User         `json:"author" bson:"author"`
+	Score     int                `json:"score" bson:"-"`
+	Available bool               `json:"available" bson:"-"`
+}
+
+// CrosspostForm - куда и под каким заголовком переопубликовать пост. Пустой Title - заголовок оригинала.
+type CrosspostForm struct {
+	Category string `json:"category" validate:"required"`
+	Title    string `json:"title" validate:"max=300"`
+}
+
+// Crosspost публикует пост originalID в другой категории. Кросспост кросспоста ссылается на первоисточник.
+// Содержимое копируется, чтобы кросспост читался как обычный пост того же типа.
+func (repo *PostMongoRepository) Crosspost(ctx context.Context, originalID primitive.ObjectID, form *CrosspostForm, username string, userID int64) (*Post, error) {
+	original, err := repo.findPost(ctx, originalID)
+	if err != nil {
+		return nil, err
+	}
+	if original.Removed {
+		return nil, ErrPostNotFound
+	}
+	if original.Poll != nil {
+		return nil, ErrNotCrosspostable
+	}
+
+	ref := &CrosspostRef{PostID: original.ID, Category: original.Category, Title: original.Title, Author: original.Author}
+	if original.Crosspost != nil {
+		ref = original.Crosspost
+	}
+	if form.Category == ref.Category {
+		return nil, ErrSameCategory
+	}
+	title := form.Title
+	if title == "" {
+		title = original.Title
+	}
+
+	post := &Post{
+		ID:        primitive.NewObjectID(),
+		Title:     title,
+		Category:  form.Category,
+		Type:      original.Type,
+		Text:      original.Text,
+		HTML:      original.HTML,
+		URL:       original.URL,
+		Image:     original.Image,
+		Crosspost: ref,
+		Created:   time.Now().UTC().Format(time.RFC3339),
+		Author: &user.User{
+			ID:       userID,
+			Username: username,
+		},
+		Votes: []*Vote{
+			{
+				UserID: userID,
+				Vote:   1,
+			},
+		},
+		Score:            1,
+		UpvotePercentage: 100,
+		Comments:         []*Comment{},
+	}
+
+	writeCtx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	if _, err = repo.DB.InsertOne(writeCtx, post); err != nil {
+		return nil, storageError(ctx, "posts.Crosspost", err)
+	}
+
+	if err = repo.linkOriginals(ctx, []*Post{post}); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// linkOriginals подставляет кросспостам текущий рейтинг оригиналов одним запросом.
+// Удалённый или снятый оригинал помечается как недоступный.
+func (repo *PostMongoRepository) linkOriginals(ctx context.Context, list []*Post) error {
+	var ids []primitive.ObjectID
+	for _, post := range list {
+		if post.Crosspost != nil {
+			ids = append(ids, post.Crosspost.PostID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	filter := bson.M{"_id": bson.M{"$in": ids}, "deletedAt": notDeleted(), "removed": bson.M{"$ne": true}}
+	opts := options.Find().SetProjection(bson.M{"score": 1})
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(ctx, filter, opts)
+	if err != nil {
+		return storageError(ctx, "posts.linkOriginals", err)
+	}
+	var originals []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Score int                `bson:"score"`
+	}
+	if err = c.All(ctx, &originals); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+
+	scores := make(map[primitive.ObjectID]int, len(originals))
+	for _, o := range originals {
+		scores[o.ID] = o.Score
+	}
+	for _, post := range list {
+		if post.Crosspost == nil {
+			continue
+		}
+		post.Crosspost.Score, post.Crosspost.Available = scores[post.Crosspost.PostID]
+	}
+	return nil
+}
+
+// trackingParams - параметры ссылок, которые не меняют страницу, а только метят переход.
+var trackingParams = map[string]bool{"fbclid": true, "gclid": true, "yclid": true, "igshid": true, "ref": true}
+
+// NormalizeURL приводит ссылку к виду, по которому ищутся повторы: схема и хост в нижнем регистре
+// без www и стандартного порта, без фрагмента, меток utm_* и завершающего слэша, параметры по алфавиту.
+func NormalizeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", ErrBadURL
+	}
+
+	host := strings.ToLower(u.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") || trackingParams[strings.ToLower(key)] {
+			query.Del(key)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, v := range values {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(key) + "=" + url.QueryEscape(v))
+		}
+	}
+
+	// Схему не сохраняем: http и https на одну страницу - тоже повтор.
+	normalized := host + strings.TrimRight(u.EscapedPath(), "/")
+	if b.Len() > 0 {
+		normalized += "?" + b.String()
+	}
+	return normalized, nil
+}
+
+// FindByURL возвращает посты со ссылкой normalizedURL, опубликованные не раньше since, новые первыми.
+func (repo *PostMongoRepository) FindByURL(ctx context.Context, normalizedURL string, since time.Time, limit int) ([]*Post, error) {
+	filter := bson.M{
+		"normalizedUrl": normalizedURL,
+		"created":       bson.M{"$gte": since.UTC().Format(time.RFC3339)},
+		"deletedAt":     notDeleted(),
+		"removed":       bson.M{"$ne": true},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created", Value: -1}}).SetLimit(int64(limit))
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, storageError(ctx, "posts.FindByURL", err)
+	}
+
+	found := []*Post{}
+	if err = c.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	for i, post := range found {
+		found[i] = readable(post)
+	}
+	return found, nil
+}
@@ -0,0 +1,210 @@
+package posts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	var tests = []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr error
+	}{
+		{name: "Простая ссылка", raw: "https://example.com/a/b", want: "example.com/a/b"},
+		{name: "Регистр, www и слэш", raw: "HTTP://WWW.Example.COM/a/b/", want: "example.com/a/b"},
+		{name: "Стандартный порт и фрагмент", raw: "https://example.com:443/a#comments", want: "example.com/a"},
+		{name: "Нестандартный порт остаётся", raw: "http://example.com:8080/a", want: "example.com:8080/a"},
+		{name: "Метки и порядок параметров", raw: "https://youtube.com/watch?v=abc&utm_source=tg&feature=share&fbclid=1", want: "youtube.com/watch?feature=share&v=abc"},
+		{name: "Регистр пути сохраняется", raw: "https://example.com/Path", want: "example.com/Path"},
+		{name: "Не http", raw: "javascript:alert(1)", wantErr: ErrBadURL},
+		{name: "Без хоста", raw: "/relative/path", wantErr: ErrBadURL},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NormalizeURL(tc.raw)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCrosspost(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	originalID, rootID := primitive.NewObjectID(), primitive.NewObjectID()
+	original := bson.D{
+		{Key: "_id", Value: originalID},
+		{Key: "title", Value: "Оригинал"},
+		{Key: "category", Value: "music"},
+		{Key: "type", Value: TypeLink},
+		{Key: "url", Value: "https://example.com"},
+		{Key: "score", Value: 10},
+		{Key: "author", Value: bson.D{{Key: "id", Value: int64(1)}, {Key: "username", Value: "rvasily"}}},
+	}
+	crosspostOf := bson.D{
+		{Key: "_id", Value: originalID},
+		{Key: "title", Value: "Кросспост"},
+		{Key: "category", Value: "funny"},
+		{Key: "type", Value: TypeText},
+		{Key: "crosspost", Value: bson.D{{Key: "postId", Value: rootID}, {Key: "category", Value: "music"}, {Key: "title", Value: "Корень"}}},
+	}
+	scores := func(id primitive.ObjectID, score int) bson.D {
+		return mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{{Key: "_id", Value: id}, {Key: "score", Value: score}})
+	}
+
+	var tests = []struct {
+		name         string
+		form         CrosspostForm
+		mockResponse []bson.D
+		wantRef      primitive.ObjectID
+		wantScore    int
+		wantTitle    string
+		wantErr      error
+	}{
+		{
+			name: "Кросспост в другую категорию",
+			form: CrosspostForm{Category: "news"},
+			mockResponse: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, original),
+				mtest.CreateSuccessResponse(),
+				scores(originalID, 10),
+			},
+			wantRef:   originalID,
+			wantScore: 10,
+			wantTitle: "Оригинал",
+		},
+		{
+			name: "Кросспост кросспоста ссылается на первоисточник",
+			form: CrosspostForm{Category: "news", Title: "Свой заголовок"},
+			mockResponse: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, crosspostOf),
+				mtest.CreateSuccessResponse(),
+				scores(rootID, 42),
+			},
+			wantRef:   rootID,
+			wantScore: 42,
+			wantTitle: "Свой заголовок",
+		},
+		{
+			name:         "В ту же категорию",
+			form:         CrosspostForm{Category: "music"},
+			mockResponse: []bson.D{mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, original)},
+			wantErr:      ErrSameCategory,
+		},
+		{
+			name: "Опрос нельзя",
+			form: CrosspostForm{Category: "news"},
+			mockResponse: []bson.D{mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: originalID}, {Key: "type", Value: TypePoll}, {Key: "poll", Value: bson.D{}}})},
+			wantErr: ErrNotCrosspostable,
+		},
+		{
+			name:         "Оригинал не найден",
+			form:         CrosspostForm{Category: "news"},
+			mockResponse: []bson.D{mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch)},
+			wantErr:      ErrPostNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			post, err := repo.Crosspost(context.Background(), originalID, &tc.form, "ivan", 3)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.form.Category, post.Category)
+			assert.Equal(t, tc.wantTitle, post.Title)
+			assert.Equal(t, tc.wantRef, post.Crosspost.PostID)
+			assert.Equal(t, tc.wantScore, post.Crosspost.Score)
+			assert.True(t, post.Crosspost.Available)
+			assert.Equal(t, int64(3), post.Author.ID)
+		})
+	}
+}
+
+func TestGetPostCrosspostScore(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID, originalID := primitive.NewObjectID(), primitive.NewObjectID()
+	crosspost := bson.D{
+		{Key: "_id", Value: postID},
+		{Key: "crosspost", Value: bson.D{{Key: "postId", Value: originalID}, {Key: "category", Value: "music"}}},
+	}
+
+	mt.Run("Текущий рейтинг оригинала", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, crosspost),
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{{Key: "_id", Value: originalID}, {Key: "score", Value: 77}}),
+		)
+
+		post, err := repo.GetPost(context.Background(), postID)
+		require.NoError(t, err)
+		assert.Equal(t, 77, post.Crosspost.Score)
+		assert.True(t, post.Crosspost.Available)
+	})
+
+	mt.Run("Оригинал удалён", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, crosspost),
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch),
+		)
+
+		post, err := repo.GetPost(context.Background(), postID)
+		require.NoError(t, err)
+		assert.False(t, post.Crosspost.Available)
+	})
+}
+
+func TestFindByURL(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID := primitive.NewObjectID()
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	mt.Run("Недавние публикации ссылки", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: postID}, {Key: "url", Value: "https://example.com/a"}},
+		))
+
+		found, err := repo.FindByURL(context.Background(), "example.com/a", since, 5)
+		require.NoError(t, err)
+		if assert.Len(t, found, 1) {
+			assert.Equal(t, postID, found[0].ID)
+		}
+
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(t, "example.com/a", cmd.Lookup("filter", "normalizedUrl").StringValue())
+		assert.Equal(t, "2024-05-01T00:00:00Z", cmd.Lookup("filter", "created", "$gte").StringValue())
+		assert.Equal(t, int64(5), cmd.Lookup("limit").AsInt64())
+	})
+
+	mt.Run("Проверка на ошибку при запросе", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.FindByURL(context.Background(), "example.com/a", since, 5)
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
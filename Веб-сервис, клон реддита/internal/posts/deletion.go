@@ -0,0 +1,152 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// notDeleted - условие на поле deletedAt: документ не удалён автором.
+func notDeleted() bson.M {
+	return bson.M{"$exists": false}
+}
+
+// liveComments убирает из поста удалённые авторами комментарии.
+func liveComments(post *Post) *Post {
+	for i, comment := range post.Comments {
+		if comment.Deleted == nil {
+			continue
+		}
+		live := append(make([]*Comment, 0, len(post.Comments)-1), post.Comments[:i]...)
+		for _, comment := range post.Comments[i+1:] {
+			if comment.Deleted == nil {
+				live = append(live, comment)
+			}
+		}
+		post.Comments = live
+		break
+	}
+	return post
+}
+
+// RestorePost возвращает удалённый пост, пока его не стёр Purger.
+// userID == 0 - восстанавливает модератор, автор не проверяется.
+func (repo *PostMongoRepository) RestorePost(ctx context.Context, postID primitive.ObjectID, userID int64) (*Post, error) {
+	filter := bson.M{"_id": postID, "deletedAt": bson.M{"$exists": true}}
+	if userID != 0 {
+		filter["author.id"] = userID
+	}
+	update := bson.M{"$unset": bson.M{"deletedAt": ""}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result := repo.DB.FindOneAndUpdate(ctx, filter, update, opts)
+	if result.Err() != nil {
+		// Пост не удалён, уже стёрт или принадлежит другому пользователю.
+		return nil, notFoundOr(ctx, "posts.RestorePost", result.Err(), ErrPostNotFound)
+	}
+
+	var post Post
+	if err := result.Decode(&post); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return readable(&post), nil
+}
+
+// RestoreComment возвращает удалённый комментарий. userID == 0 - восстанавливает модератор.
+func (repo *PostMongoRepository) RestoreComment(ctx context.Context, postID, commentID primitive.ObjectID, userID int64) (*Post, error) {
+	match := bson.M{"_id": commentID, "deletedAt": bson.M{"$exists": true}}
+	if userID != 0 {
+		match["author.id"] = userID
+	}
+	filter := bson.M{"_id": postID, "deletedAt": notDeleted(), "comments": bson.M{"$elemMatch": match}}
+	update := bson.M{"$unset": bson.M{"comments.$.deletedAt": ""}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result := repo.DB.FindOneAndUpdate(ctx, filter, update, opts)
+	if result.Err() != nil {
+		return nil, notFoundOr(ctx, "posts.RestoreComment", result.Err(), ErrCommentNotFound)
+	}
+
+	var post Post
+	if err := result.Decode(&post); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return readable(&post), nil
+}
+
+// PurgeResult - сколько постов и постов с комментариями стёрто за один проход.
+type PurgeResult struct {
+	Posts    int64
+	Comments int64
+}
+
+// Purge окончательно стирает посты и комментарии, удалённые раньше before.
+func (repo *PostMongoRepository) Purge(ctx context.Context, before time.Time) (PurgeResult, error) {
+	var res PurgeResult
+	expired := bson.M{"$lte": before}
+
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	deleted, err := repo.DB.DeleteMany(ctx, bson.M{"deletedAt": expired})
+	if err != nil {
+		return res, storageError(ctx, "posts.Purge", err)
+	}
+	res.Posts = deleted.DeletedCount
+
+	updated, err := repo.DB.UpdateMany(ctx,
+		bson.M{"comments.deletedAt": expired},
+		bson.M{"$pull": bson.M{"comments": bson.M{"deletedAt": expired}}},
+	)
+	if err != nil {
+		return res, storageError(ctx, "posts.Purge", err)
+	}
+	res.Comments = updated.ModifiedCount
+
+	return res, nil
+}
+
+// Purger раз в Interval стирает то, что удалено раньше, чем Retention назад.
+type Purger struct {
+	Repo      PostRepo
+	Retention time.Duration
+	Interval  time.Duration
+	Logger    *zap.SugaredLogger
+}
+
+func NewPurger(repo PostRepo, retention, interval time.Duration, logger *zap.SugaredLogger) *Purger {
+	return &Purger{Repo: repo, Retention: retention, Interval: interval, Logger: logger}
+}
+
+// PurgeOnce выполняет один проход.
+func (p *Purger) PurgeOnce(ctx context.Context) (PurgeResult, error) {
+	return p.Repo.Purge(ctx, time.Now().UTC().Add(-p.Retention))
+}
+
+// Run выполняет проход сразу и затем раз в Interval, пока не отменят ctx.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		res, err := p.PurgeOnce(ctx)
+		switch {
+		case err != nil:
+			p.Logger.Errorw("failed to purge deleted posts", "error", err)
+		case res.Posts > 0 || res.Comments > 0:
+			p.Logger.Infow("deleted posts purged", "posts", res.Posts, "posts_with_comments", res.Comments)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
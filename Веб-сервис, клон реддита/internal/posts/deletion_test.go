@@ -0,0 +1,202 @@
+package posts
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestLiveComments(t *testing.T) {
+	now := time.Now()
+	post := &Post{Comments: []*Comment{{Body: "a"}, {Body: "b", Deleted: &now}, {Body: "c"}, {Body: "d", Deleted: &now}}}
+	liveComments(post)
+
+	bodies := []string{}
+	for _, c := range post.Comments {
+		bodies = append(bodies, c.Body)
+	}
+	assert.Equal(t, []string{"a", "c"}, bodies)
+
+	// Без удалённых комментариев срез не пересобирается.
+	untouched := &Post{}
+	assert.Nil(t, liveComments(untouched).Comments)
+}
+
+func TestDeleteCommentSoft(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID, commentID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	mt.Run("Комментарий помечается удалённым и пропадает из ответа", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: bson.D{
+				{Key: "_id", Value: postID},
+				{Key: "comments", Value: bson.A{
+					bson.D{{Key: "_id", Value: commentID}, {Key: "deletedAt", Value: time.Now()}},
+					bson.D{{Key: "_id", Value: primitive.NewObjectID()}},
+				}},
+			}},
+		})
+
+		post, err := repo.DeleteComment(context.Background(), postID, commentID, 3)
+		assert.NoError(t, err)
+		assert.Len(t, post.Comments, 1)
+
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(t, bson.TypeDateTime, cmd.Lookup("update", "$set", "comments.$.deletedAt").Type)
+		assert.Equal(t, int64(3), cmd.Lookup("query", "comments", "$elemMatch", "author.id").Int64())
+	})
+}
+
+func TestRestorePost(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID := primitive.NewObjectID()
+	restored := bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "_id", Value: postID}}}}
+
+	var tests = []struct {
+		name         string
+		userID       int64
+		mockResponse bson.D
+		wantAuthor   bool
+		wantErr      error
+	}{
+		{name: "Восстановление автором", userID: 3, mockResponse: restored, wantAuthor: true},
+		{name: "Восстановление модератором", mockResponse: restored},
+		{
+			name:         "Пост не удалён или уже стёрт",
+			userID:       3,
+			mockResponse: bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			wantErr:      ErrPostNotFound,
+		},
+		{name: "Ошибка хранилища", userID: 3, mockResponse: bson.D{{Key: "ok", Value: 0}}, wantErr: ErrStorage},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse)
+
+			post, err := repo.RestorePost(context.Background(), postID, tc.userID)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, postID, post.ID)
+
+			cmd := mt.GetStartedEvent().Command
+			assert.True(t, cmd.Lookup("query", "deletedAt", "$exists").Boolean())
+			_, err = cmd.LookupErr("update", "$unset", "deletedAt")
+			assert.NoError(t, err)
+			_, err = cmd.LookupErr("query", "author.id")
+			assert.Equal(t, tc.wantAuthor, err == nil)
+		})
+	}
+}
+
+func TestRestoreComment(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID, commentID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	mt.Run("Восстановление комментария", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: bson.D{
+				{Key: "_id", Value: postID},
+				{Key: "comments", Value: bson.A{bson.D{{Key: "_id", Value: commentID}}}},
+			}},
+		})
+
+		post, err := repo.RestoreComment(context.Background(), postID, commentID, 3)
+		assert.NoError(t, err)
+		assert.Len(t, post.Comments, 1)
+
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(t, commentID, cmd.Lookup("query", "comments", "$elemMatch", "_id").ObjectID())
+		_, err = cmd.LookupErr("update", "$unset", "comments.$.deletedAt")
+		assert.NoError(t, err)
+	})
+
+	mt.Run("Комментарий не найден", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}})
+
+		_, err := repo.RestoreComment(context.Background(), postID, commentID, 3)
+		assert.ErrorIs(t, err, ErrCommentNotFound)
+	})
+}
+
+func TestPurge(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	before := time.Now().UTC().Truncate(time.Millisecond)
+
+	mt.Run("Стираются посты и комментарии", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		res, err := repo.Purge(context.Background(), before)
+		assert.NoError(t, err)
+		assert.Equal(t, PurgeResult{Posts: 2, Comments: 1}, res)
+
+		del := mt.GetStartedEvent().Command.Lookup("deletes").Array().Index(0).Value().Document()
+		assert.Equal(t, before, del.Lookup("q", "deletedAt", "$lte").Time().UTC())
+		upd := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, before, upd.Lookup("u", "$pull", "comments", "deletedAt", "$lte").Time().UTC())
+	})
+
+	mt.Run("Ошибка хранилища", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.Purge(context.Background(), before)
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestPurger(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := NewMockPostRepo(ctrl)
+	purger := NewPurger(repo, 24*time.Hour, time.Hour, zap.NewNop().Sugar())
+
+	repo.EXPECT().Purge(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, before time.Time) (PurgeResult, error) {
+		assert.WithinDuration(t, time.Now().Add(-24*time.Hour), before, time.Minute)
+		return PurgeResult{Posts: 1}, nil
+	})
+	res, err := purger.PurgeOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.Posts)
+
+	// Run делает проход сразу и выходит по отмене контекста.
+	ctx, cancel := context.WithCancel(context.Background())
+	repo.EXPECT().Purge(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, time.Time) (PurgeResult, error) {
+		cancel()
+		return PurgeResult{}, nil
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		purger.Run(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purger did not stop")
+	}
+}
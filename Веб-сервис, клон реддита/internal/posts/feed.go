@@ -0,0 +1,240 @@
+package posts
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Варианты ранжирования ленты.
+const (
+	SortNew = "new"
+	SortTop = "top"
+	SortHot = "hot"
+)
+
+// hotEpoch и hotPeriod - константы формулы hot из исходников reddit:
+// каждые 12.5 часов свежести весят столько же, сколько десятикратный рост рейтинга.
+const (
+	hotEpoch  = 1134028003
+	hotPeriod = 45000
+)
+
+var (
+	ErrBadSort   = errors.New("unknown sort")
+	ErrBadCursor = errors.New("bad cursor")
+)
+
+// FeedQuery - запрос ленты: посты из подписок на категории и авторов, кроме исключённых.
+type FeedQuery struct {
+	Categories []string
+	Authors    []string
+	Exclude    []primitive.ObjectID
+	Sort       string
+	Limit      int
+	// After - курсор, полученный со страницей раньше. nil - первая страница.
+	After *FeedCursor
+}
+
+type FeedPage struct {
+	Posts      []*Post `json:"posts"`
+	NextCursor string  `json:"nextCursor,omitempty"`
+}
+
+// FeedCursor - позиция последнего отданного поста. Rank - рейтинг для top и hot, Created - для new.
+type FeedCursor struct {
+	Sort    string             `json:"s"`
+	Rank    float64            `json:"r,omitempty"`
+	Created string             `json:"c,omitempty"`
+	ID      primitive.ObjectID `json:"id"`
+}
+
+func ValidSort(sort string) bool {
+	return sort == SortNew || sort == SortTop || sort == SortHot
+}
+
+func (c *FeedCursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor разбирает курсор и проверяет, что он выдан для той же сортировки.
+func DecodeCursor(sort, s string) (*FeedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+	cursor := &FeedCursor{}
+	if err = json.Unmarshal(raw, cursor); err != nil || cursor.Sort != sort || cursor.ID.IsZero() {
+		return nil, ErrBadCursor
+	}
+	return cursor, nil
+}
+
+// rankedPost - пост вместе с вычисленным в пайплайне рейтингом, из которого строится следующий курсор.
+type rankedPost struct {
+	Post `bson:",inline"`
+	Rank interface{} `bson:"_rank"`
+}
+
+// hotExpr считает рейтинг hot прямо в монге: sign(score)*log10(max(|score|, 1)) + (created - epoch)/period.
+func hotExpr() bson.M {
+	seconds := bson.M{"$divide": bson.A{bson.M{"$toLong": bson.M{"$dateFromString": bson.M{"dateString": "$created"}}}, 1000}}
+	return bson.M{"$add": bson.A{
+		bson.M{"$multiply": bson.A{
+			bson.M{"$cmp": bson.A{"$score", 0}},
+			bson.M{"$log10": bson.M{"$max": bson.A{bson.M{"$abs": "$score"}, 1}}},
+		}},
+		bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{seconds, hotEpoch}}, hotPeriod}},
+	}}
+}
+
+// afterCursor - условие "строго после курсора" при сортировке по убыванию (field, _id).
+func afterCursor(field string, value interface{}, id primitive.ObjectID) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{"$lt": value}},
+		bson.M{field: value, "_id": bson.M{"$lt": id}},
+	}}
+}
+
+// feedPipeline строит агрегацию ленты. Для new и top фильтр, сортировка и курсор идут по полям
+// с индексами (category|author.username, created|score), поэтому монга читает не больше limit+1 документов
+// на каждую подписку. Для hot рейтинг вычисляется, и сортировка идёт по всем постам из подписок.
+func feedPipeline(q FeedQuery) (mongo.Pipeline, error) {
+	var sources bson.A
+	if len(q.Categories) > 0 {
+		sources = append(sources, bson.M{"category": bson.M{"$in": q.Categories}})
+	}
+	if len(q.Authors) > 0 {
+		sources = append(sources, bson.M{"author.username": bson.M{"$in": q.Authors}})
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	match := bson.M{"$or": sources, "removed": bson.M{"$ne": true}, "deletedAt": notDeleted()}
+	if len(q.Exclude) > 0 {
+		match["_id"] = bson.M{"$nin": q.Exclude}
+	}
+
+	var field string
+	var after interface{}
+	switch q.Sort {
+	case SortNew:
+		field = "created"
+		if q.After != nil {
+			after = q.After.Created
+		}
+	case SortTop:
+		field = "score"
+		if q.After != nil {
+			after = q.After.Rank
+		}
+	case SortHot:
+		field = "_rank"
+		if q.After != nil {
+			after = q.After.Rank
+		}
+	default:
+		return nil, ErrBadSort
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+	if q.Sort == SortHot {
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{"_rank": hotExpr()}}})
+	}
+	if q.After != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: afterCursor(field, after, q.After.ID)}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: field, Value: -1}, {Key: "_id", Value: -1}}}},
+		// Берём на один пост больше, чтобы понять, есть ли следующая страница.
+		bson.D{{Key: "$limit", Value: q.Limit + 1}},
+	)
+	if q.Sort != SortHot {
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{"_rank": "$" + field}}})
+	}
+
+	return pipeline, nil
+}
+
+// EnsureIndexes создаёт индексы, по которым лента и очистка удалённого читаются без полного прохода по коллекции.
+func (repo *PostMongoRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "category", Value: 1}, {Key: "created", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "author.username", Value: 1}, {Key: "created", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "category", Value: 1}, {Key: "score", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "author.username", Value: 1}, {Key: "score", Value: -1}, {Key: "_id", Value: -1}}},
+		// По ним Purger находит то, что пора стереть.
+		{Keys: bson.D{{Key: "deletedAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "comments.deletedAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		// Поиск повторных ссылок.
+		{Keys: bson.D{{Key: "normalizedUrl", Value: 1}, {Key: "created", Value: -1}}, Options: options.Index().SetSparse(true)},
+	})
+	if err != nil {
+		return storageError(ctx, "posts.EnsureIndexes", err)
+	}
+	return nil
+}
+
+// GetFeed собирает ленту одним запросом к коллекции постов (fan-out on read).
+func (repo *PostMongoRepository) GetFeed(ctx context.Context, q FeedQuery) (*FeedPage, error) {
+	pipeline, err := feedPipeline(q)
+	if err != nil {
+		return nil, err
+	}
+	page := &FeedPage{Posts: []*Post{}}
+	if pipeline == nil {
+		return page, nil
+	}
+
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, storageError(ctx, "posts.GetFeed", err)
+	}
+
+	var ranked []*rankedPost
+	if err = c.All(ctx, &ranked); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+
+	hasMore := len(ranked) > q.Limit
+	if hasMore {
+		ranked = ranked[:q.Limit]
+	}
+	for _, p := range ranked {
+		post := p.Post
+		page.Posts = append(page.Posts, readable(&post))
+	}
+	if err = repo.linkOriginals(ctx, page.Posts); err != nil {
+		return nil, err
+	}
+
+	if hasMore {
+		last := ranked[len(ranked)-1]
+		cursor := &FeedCursor{Sort: q.Sort, ID: last.ID}
+		switch rank := last.Rank.(type) {
+		case string:
+			cursor.Created = rank
+		case int32:
+			cursor.Rank = float64(rank)
+		case int64:
+			cursor.Rank = float64(rank)
+		case float64:
+			cursor.Rank = rank
+		}
+		page.NextCursor = cursor.Encode()
+	}
+
+	return page, nil
+}
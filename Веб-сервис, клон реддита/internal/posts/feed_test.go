@@ -0,0 +1,212 @@
+package posts
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"testing"
+)
+
+func TestFeedCursor(t *testing.T) {
+	cursor := &FeedCursor{Sort: SortHot, Rank: 7123.4567891, ID: primitive.NewObjectID()}
+
+	got, err := DecodeCursor(SortHot, cursor.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, got)
+
+	var tests = []struct {
+		name string
+		sort string
+		raw  string
+	}{
+		{name: "Курсор другой сортировки", sort: SortNew, raw: cursor.Encode()},
+		{name: "Не base64", sort: SortHot, raw: "%%%"},
+		{name: "Не JSON", sort: SortHot, raw: "bm90IGpzb24"},
+		{name: "Курсор без ID", sort: SortHot, raw: (&FeedCursor{Sort: SortHot}).Encode()},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeCursor(tc.sort, tc.raw)
+			assert.ErrorIs(t, err, ErrBadCursor)
+		})
+	}
+}
+
+// stageNames возвращает названия стадий пайплайна по порядку.
+func stageNames(pipeline mongo.Pipeline) []string {
+	names := make([]string, 0, len(pipeline))
+	for _, stage := range pipeline {
+		names = append(names, stage[0].Key)
+	}
+	return names
+}
+
+func TestFeedPipeline(t *testing.T) {
+	lastID := primitive.NewObjectID()
+	hidden := primitive.NewObjectID()
+
+	var tests = []struct {
+		name        string
+		query       FeedQuery
+		wantStages  []string
+		wantSources int
+		wantSort    string
+		wantAfter   interface{}
+		wantErr     error
+	}{
+		{
+			name:        "Первая страница new",
+			query:       FeedQuery{Categories: []string{"music"}, Sort: SortNew, Limit: 10},
+			wantStages:  []string{"$match", "$sort", "$limit", "$addFields"},
+			wantSources: 1,
+			wantSort:    "created",
+		},
+		{
+			name: "Следующая страница top",
+			query: FeedQuery{Authors: []string{"ivan"}, Sort: SortTop, Limit: 10,
+				After: &FeedCursor{Sort: SortTop, Rank: 5, ID: lastID}},
+			wantStages:  []string{"$match", "$match", "$sort", "$limit", "$addFields"},
+			wantSources: 1,
+			wantSort:    "score",
+			wantAfter:   float64(5),
+		},
+		{
+			name: "Следующая страница hot считает рейтинг до курсора",
+			query: FeedQuery{Categories: []string{"music"}, Authors: []string{"ivan"}, Exclude: []primitive.ObjectID{hidden},
+				Sort: SortHot, Limit: 10, After: &FeedCursor{Sort: SortHot, Rank: 1.5, ID: lastID}},
+			wantStages:  []string{"$match", "$addFields", "$match", "$sort", "$limit"},
+			wantSources: 2,
+			wantSort:    "_rank",
+			wantAfter:   1.5,
+		},
+		{
+			name:    "Неизвестная сортировка",
+			query:   FeedQuery{Categories: []string{"music"}, Sort: "best", Limit: 10},
+			wantErr: ErrBadSort,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pipeline, err := feedPipeline(tc.query)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStages, stageNames(pipeline))
+
+			match := pipeline[0][0].Value.(bson.M)
+			assert.Len(t, match["$or"], tc.wantSources)
+			if len(tc.query.Exclude) > 0 {
+				assert.Equal(t, bson.M{"$nin": tc.query.Exclude}, match["_id"])
+			}
+
+			for i, stage := range pipeline {
+				switch stage[0].Key {
+				case "$sort":
+					assert.Equal(t, bson.D{{Key: tc.wantSort, Value: -1}, {Key: "_id", Value: -1}}, stage[0].Value)
+				case "$limit":
+					assert.Equal(t, tc.query.Limit+1, stage[0].Value)
+				case "$match":
+					// Второй $match - условие курсора.
+					if i > 0 {
+						assert.Equal(t, afterCursor(tc.wantSort, tc.wantAfter, lastID), stage[0].Value)
+					}
+				}
+			}
+		})
+	}
+
+	pipeline, err := feedPipeline(FeedQuery{Sort: SortHot, Limit: 10})
+	assert.NoError(t, err)
+	assert.Nil(t, pipeline, "feed without subscriptions does not query mongo")
+}
+
+func TestGetFeed(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+	doc := func(id primitive.ObjectID, rank interface{}) bson.D {
+		return bson.D{
+			{Key: "_id", Value: id},
+			{Key: "title", Value: "Post " + id.Hex()},
+			{Key: "category", Value: "music"},
+			{Key: "score", Value: 3},
+			{Key: "created", Value: "2024-05-07T20:38:17Z"},
+			{Key: "_rank", Value: rank},
+		}
+	}
+
+	var tests = []struct {
+		name         string
+		query        FeedQuery
+		mockResponse []bson.D
+		wantCount    int
+		wantCursor   *FeedCursor
+		wantErr      error
+	}{
+		{
+			name:  "Страница hot со следующей страницей",
+			query: FeedQuery{Categories: []string{"music"}, Sort: SortHot, Limit: 1},
+			mockResponse: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, doc(first, 7001.25), doc(second, 7000.5)),
+			},
+			wantCount:  1,
+			wantCursor: &FeedCursor{Sort: SortHot, Rank: 7001.25, ID: first},
+		},
+		{
+			name:  "Страница new с курсором по дате",
+			query: FeedQuery{Categories: []string{"music"}, Sort: SortNew, Limit: 1},
+			mockResponse: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, doc(first, "2024-05-07T20:38:17Z"), doc(second, "2024-05-06T20:38:17Z")),
+			},
+			wantCount:  1,
+			wantCursor: &FeedCursor{Sort: SortNew, Created: "2024-05-07T20:38:17Z", ID: first},
+		},
+		{
+			name:  "Последняя страница top",
+			query: FeedQuery{Authors: []string{"ivan"}, Sort: SortTop, Limit: 5},
+			mockResponse: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, doc(first, int32(3)), doc(second, int32(3))),
+			},
+			wantCount: 2,
+		},
+		{
+			name:      "Без подписок лента пустая",
+			query:     FeedQuery{Sort: SortHot, Limit: 5},
+			wantCount: 0,
+		},
+		{
+			name:         "Проверка на обработку ошибки при запросе",
+			query:        FeedQuery{Categories: []string{"music"}, Sort: SortHot, Limit: 5},
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			page, err := repo.GetFeed(context.Background(), tc.query)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, page.Posts, tc.wantCount)
+			if tc.wantCursor == nil {
+				assert.Empty(t, page.NextCursor)
+				return
+			}
+			cursor, err := DecodeCursor(tc.query.Sort, page.NextCursor)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantCursor, cursor)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Операции для администратора: их нет в PostRepo, сервер их не вызывает.
+
+var ErrEmptyScope = errors.New("scope needs a category or an author")
+
+// Counters - счётчики голосов поста. Источник правды - Votes, счётчики из него выводятся.
+type Counters struct {
+	Score            int `json:"score"`
+	UpvoteCount      int `json:"upvoteCount"`
+	VoteCount        int `json:"voteCount"`
+	UpvotePercentage int `json:"upvotePercentage"`
+}
+
+func countersOf(post *Post) Counters {
+	return Counters{
+		Score:            post.Score,
+		UpvoteCount:      post.UpvoteCount,
+		VoteCount:        post.VoteCount,
+		UpvotePercentage: post.UpvotePercentage,
+	}
+}
+
+// fields - счётчики под именами полей документа.
+func (c Counters) fields() bson.M {
+	return bson.M{
+		"score":            c.Score,
+		"upvotecount":      c.UpvoteCount,
+		"votecount":        c.VoteCount,
+		"upvotepercentage": c.UpvotePercentage,
+	}
+}
+
+// CounterDrift - пост, у которого сохранённые счётчики разошлись с голосами.
+type CounterDrift struct {
+	PostID primitive.ObjectID `json:"postId"`
+	Title  string             `json:"title"`
+	Stored Counters           `json:"stored"`
+	Actual Counters           `json:"actual"`
+	// Fixed - исправление записано. Пост, за который успели проголосовать, не трогаем:
+	// голосование само пересчитало счётчики.
+	Fixed bool `json:"fixed"`
+}
+
+// CountPosts считает неудалённые посты области, включая снятые модераторами.
+func (repo *PostMongoRepository) CountPosts(ctx context.Context, scope Scope) (int64, error) {
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	count, err := repo.DB.CountDocuments(ctx, scopeQuery(scope))
+	if err != nil {
+		return 0, storageError(ctx, "posts.CountPosts", err)
+	}
+	return count, nil
+}
+
+// DeletePosts помечает удалёнными все посты области, как если бы их удалили авторы:
+// до окончательного удаления Purger'ом их можно восстановить. Пустая область - ErrEmptyScope.
+func (repo *PostMongoRepository) DeletePosts(ctx context.Context, scope Scope) (int64, error) {
+	if scope.Category == "" && scope.Author == "" {
+		return 0, ErrEmptyScope
+	}
+	update := bson.M{"$set": bson.M{"deletedAt": time.Now().UTC()}}
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result, err := repo.DB.UpdateMany(ctx, scopeQuery(scope), update)
+	if err != nil {
+		return 0, storageError(ctx, "posts.DeletePosts", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// RecountVotes сверяет счётчики всех постов, в том числе удалённых, с их голосами и возвращает разошедшиеся.
+// apply - записать исправленные счётчики. Проход по всей коллекции не ограничен ReadTimeout.
+func (repo *PostMongoRepository) RecountVotes(ctx context.Context, apply bool) ([]*CounterDrift, error) {
+	projection := bson.M{"title": 1, "votes": 1, "score": 1, "upvotecount": 1, "votecount": 1, "upvotepercentage": 1}
+	c, err := repo.DB.Find(ctx, bson.M{}, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, storageError(ctx, "posts.RecountVotes", err)
+	}
+	defer c.Close(ctx)
+
+	drifted := []*CounterDrift{}
+	for c.Next(ctx) {
+		var post Post
+		if err = c.Decode(&post); err != nil {
+			return drifted, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+		}
+		stored := countersOf(&post)
+		recount(&post)
+		if countersOf(&post) == stored {
+			continue
+		}
+		drift := &CounterDrift{PostID: post.ID, Title: post.Title, Stored: stored, Actual: countersOf(&post)}
+		if apply {
+			if drift.Fixed, err = repo.writeCounters(ctx, &post); err != nil {
+				return drifted, err
+			}
+		}
+		drifted = append(drifted, drift)
+	}
+	if err = c.Err(); err != nil {
+		return drifted, storageError(ctx, "posts.RecountVotes", err)
+	}
+	return drifted, nil
+}
+
+// writeCounters записывает счётчики, если голоса поста не менялись с чтения.
+func (repo *PostMongoRepository) writeCounters(ctx context.Context, post *Post) (bool, error) {
+	// Пустые голоса остаются nil и совпадают и с отсутствующим полем, и с null.
+	filter := bson.M{"_id": post.ID, "votes": post.Votes}
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result, err := repo.DB.UpdateOne(ctx, filter, bson.M{"$set": countersOf(post).fields()})
+	if err != nil {
+		return false, storageError(ctx, "posts.writeCounters", err)
+	}
+	return result.MatchedCount > 0, nil
+}
@@ -0,0 +1,127 @@
+package posts
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestDeletePosts(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Посты автора в категории", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 3}, bson.E{Key: "nModified", Value: 3}))
+
+		deleted, err := repo.DeletePosts(context.Background(), Scope{Category: "music", Author: "spammer"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), deleted)
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "music", update.Lookup("q", "category").StringValue())
+		assert.Equal(t, "spammer", update.Lookup("q", "author.username").StringValue())
+		assert.True(t, update.Lookup("multi").Boolean())
+		_, err = update.LookupErr("u", "$set", "deletedAt")
+		assert.NoError(t, err, "posts are soft-deleted")
+	})
+
+	mt.Run("Пустая область", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+
+		_, err := repo.DeletePosts(context.Background(), Scope{})
+		assert.ErrorIs(t, err, ErrEmptyScope)
+	})
+
+	mt.Run("Ошибка хранилища", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.DeletePosts(context.Background(), Scope{Author: "spammer"})
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestRecountVotes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	goodID, driftedID := primitive.NewObjectID(), primitive.NewObjectID()
+	votes := bson.A{
+		bson.D{{Key: "userid", Value: int64(1)}, {Key: "vote", Value: 1}},
+		bson.D{{Key: "userid", Value: int64(2)}, {Key: "vote", Value: -1}},
+	}
+	good := bson.D{
+		{Key: "_id", Value: goodID}, {Key: "title", Value: "верный"}, {Key: "votes", Value: votes},
+		{Key: "score", Value: 0}, {Key: "upvotecount", Value: 1}, {Key: "votecount", Value: 2}, {Key: "upvotepercentage", Value: 50},
+	}
+	drifted := bson.D{
+		{Key: "_id", Value: driftedID}, {Key: "title", Value: "разошёлся"}, {Key: "votes", Value: votes},
+		{Key: "score", Value: 7}, {Key: "upvotecount", Value: 1}, {Key: "votecount", Value: 1}, {Key: "upvotepercentage", Value: 100},
+	}
+	want := &CounterDrift{
+		PostID: driftedID,
+		Title:  "разошёлся",
+		Stored: Counters{Score: 7, UpvoteCount: 1, VoteCount: 1, UpvotePercentage: 100},
+		Actual: Counters{Score: 0, UpvoteCount: 1, VoteCount: 2, UpvotePercentage: 50},
+	}
+	cursor := func() []bson.D {
+		return []bson.D{
+			mtest.CreateCursorResponse(1, "foo.bar", mtest.FirstBatch, good),
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.NextBatch, drifted),
+		}
+	}
+
+	mt.Run("Без записи", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(cursor()...)
+
+		got, err := repo.RecountVotes(context.Background(), false)
+		require.NoError(t, err)
+		assert.Equal(t, []*CounterDrift{want}, got)
+	})
+
+	mt.Run("С исправлением", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(append(cursor(), mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))...)
+
+		got, err := repo.RecountVotes(context.Background(), true)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.True(t, got[0].Fixed)
+
+		mt.GetStartedEvent() // find
+		mt.GetStartedEvent() // getMore
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, driftedID, update.Lookup("q", "_id").ObjectID())
+		filterVotes, _ := update.Lookup("q", "votes").Array().Values()
+		assert.Len(t, filterVotes, 2, "write only if votes did not change")
+		set := update.Lookup("u", "$set").Document()
+		assert.EqualValues(t, 0, set.Lookup("score").AsInt64())
+		assert.EqualValues(t, 2, set.Lookup("votecount").AsInt64())
+		assert.EqualValues(t, 50, set.Lookup("upvotepercentage").AsInt64())
+		_, err = set.LookupErr("votes")
+		assert.Error(t, err, "votes are not rewritten")
+	})
+
+	mt.Run("Пост успели изменить", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(append(cursor(), mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}))...)
+
+		got, err := repo.RecountVotes(context.Background(), true)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.False(t, got[0].Fixed)
+	})
+
+	mt.Run("Ошибка хранилища", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.RecountVotes(context.Background(), false)
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
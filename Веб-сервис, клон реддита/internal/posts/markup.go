@@ -0,0 +1,18 @@
+package posts
+
+import "redditclone/internal/markup"
+
+// readable готовит пост к выдаче: убирает удалённые комментарии и дорисовывает HTML документам,
+// сохранённым до появления рендеринга. Все чтения отдают пост только через неё.
+func readable(post *Post) *Post {
+	liveComments(post)
+	if post.HTML == "" && post.Text != "" {
+		post.HTML = markup.Render(post.Text)
+	}
+	for _, comment := range post.Comments {
+		if comment.HTML == "" && comment.Body != "" {
+			comment.HTML = markup.Render(comment.Body)
+		}
+	}
+	return post
+}
@@ -0,0 +1,55 @@
+package posts
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"testing"
+)
+
+func TestReadable(t *testing.T) {
+	post := readable(&Post{
+		Text: "**old**",
+		Comments: []*Comment{
+			{Body: "legacy u/bob"},
+			{Body: "fresh", HTML: "<p>stored</p>\n"},
+		},
+	})
+
+	assert.Equal(t, "<p><strong>old</strong></p>\n", post.HTML)
+	assert.Contains(t, post.Comments[0].HTML, `href="/u/bob"`)
+	// Сохранённый HTML не перерисовывается.
+	assert.Equal(t, "<p>stored</p>\n", post.Comments[1].HTML)
+
+	assert.Empty(t, readable(&Post{Type: "link"}).HTML)
+}
+
+func TestRenderOnWrite(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Пост сохраняется вместе с HTML", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		post, err := repo.MakePost(context.Background(), &PostForm{Type: "text", Title: "t", Category: "music", Text: "_hi_"}, "rvasily", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "<p><em>hi</em></p>\n", post.HTML)
+
+		doc := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		assert.Equal(t, post.HTML, doc.Lookup("html").StringValue())
+	})
+
+	mt.Run("Комментарий сохраняется вместе с HTML", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		postID := primitive.NewObjectID()
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "_id", Value: postID}}}})
+
+		_, err := repo.MakeComment(context.Background(), postID, primitive.NilObjectID, "`code`", "rvasily", 1)
+		assert.NoError(t, err)
+
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(t, "<p><code>code</code></p>\n", cmd.Lookup("update", "$push", "comments", "html").StringValue())
+	})
+}
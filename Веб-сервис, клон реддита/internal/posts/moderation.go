@@ -0,0 +1,107 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrPostLocked = errors.New("post is locked")
+
+// WithoutRemoved дополняет фильтр: снятые модераторами посты не попадают в выдачу.
+func WithoutRemoved(filter func(*Post) bool) func(*Post) bool {
+	return func(p *Post) bool {
+		return !p.Removed && filter(p)
+	}
+}
+
+// StickyFirst поднимает закреплённые посты наверх, сохраняя порядок остальных.
+func StickyFirst(list []*Post) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Sticky && !list[j].Sticky
+	})
+}
+
+// VisibleComments убирает из поста снятые модераторами комментарии.
+func VisibleComments(post *Post) {
+	visible := make([]*Comment, 0, len(post.Comments))
+	for _, comment := range post.Comments {
+		if !comment.Removed {
+			visible = append(visible, comment)
+		}
+	}
+	post.Comments = visible
+}
+
+func (repo *PostMongoRepository) SetPostFlags(ctx context.Context, postID primitive.ObjectID, flags PostFlags) (*Post, error) {
+	set := bson.M{}
+	for field, value := range map[string]*bool{"removed": flags.Removed, "locked": flags.Locked, "sticky": flags.Sticky} {
+		if value != nil {
+			set[field] = *value
+		}
+	}
+	if len(set) == 0 {
+		return repo.GetPost(ctx, postID)
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result := repo.DB.FindOneAndUpdate(ctx, bson.M{"_id": postID, "deletedAt": notDeleted()}, bson.M{"$set": set}, opts)
+	if result.Err() != nil {
+		return nil, notFoundOr(ctx, "posts.SetPostFlags", result.Err(), ErrPostNotFound)
+	}
+
+	var post Post
+	if err := result.Decode(&post); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return readable(&post), nil
+}
+
+func (repo *PostMongoRepository) SetCommentRemoved(ctx context.Context, postID, commentID primitive.ObjectID, removed bool) (*Post, error) {
+	filter := bson.M{"_id": postID, "deletedAt": notDeleted(), "comments": bson.M{"$elemMatch": bson.M{"_id": commentID, "deletedAt": notDeleted()}}}
+	update := bson.M{"$set": bson.M{"comments.$.removed": removed}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result := repo.DB.FindOneAndUpdate(ctx, filter, update, opts)
+	if result.Err() != nil {
+		return nil, notFoundOr(ctx, "posts.SetCommentRemoved", result.Err(), ErrCommentNotFound)
+	}
+
+	var post Post
+	if err := result.Decode(&post); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return readable(&post), nil
+}
+
+// commentRejection объясняет, почему MakeComment не нашёл пост: его нет, он закрыт или нет родительского комментария.
+func (repo *PostMongoRepository) commentRejection(ctx context.Context, postID, parentID primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+
+	var post Post
+	err := repo.DB.FindOne(ctx, bson.M{"_id": postID}).Decode(&post)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrPostNotFound
+	case err != nil:
+		return storageError(ctx, "posts.commentRejection", err)
+	case post.Removed, post.Deleted != nil:
+		return ErrPostNotFound
+	case post.Locked:
+		return ErrPostLocked
+	case !parentID.IsZero():
+		return ErrCommentNotFound
+	}
+	// Пост открыт и родитель на месте - значит, его изменили между запросами.
+	return ErrFailedUpdate
+}
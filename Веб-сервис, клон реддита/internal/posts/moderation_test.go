@@ -0,0 +1,140 @@
+package posts
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"testing"
+)
+
+func TestSetPostFlags(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID := primitive.NewObjectID()
+	yes, no := true, false
+
+	var tests = []struct {
+		name         string
+		flags        PostFlags
+		mockResponse []bson.D
+		wantSet      bson.M
+		wantErr      error
+	}{
+		{
+			name:  "Снятие и закрытие поста",
+			flags: PostFlags{Removed: &yes, Locked: &yes},
+			mockResponse: []bson.D{{
+				{Key: "ok", Value: 1},
+				{Key: "value", Value: bson.D{{Key: "_id", Value: postID}, {Key: "removed", Value: true}, {Key: "locked", Value: true}}},
+			}},
+			wantSet: bson.M{"locked": true, "removed": true},
+		},
+		{
+			name:  "Открепление поста",
+			flags: PostFlags{Sticky: &no},
+			mockResponse: []bson.D{{
+				{Key: "ok", Value: 1},
+				{Key: "value", Value: bson.D{{Key: "_id", Value: postID}}},
+			}},
+			wantSet: bson.M{"sticky": false},
+		},
+		{
+			name:         "Пост не найден",
+			flags:        PostFlags{Removed: &yes},
+			mockResponse: []bson.D{{{Key: "ok", Value: 1}, {Key: "value", Value: nil}}},
+			wantErr:      ErrPostNotFound,
+		},
+		{
+			name:         "Ошибка хранилища",
+			flags:        PostFlags{Removed: &yes},
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+		{
+			name:         "Без флагов пост просто читается",
+			mockResponse: []bson.D{mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{{Key: "_id", Value: postID}})},
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			post, err := repo.SetPostFlags(context.Background(), postID, tc.flags)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, postID, post.ID)
+
+			if tc.wantSet != nil {
+				cmd := mt.GetStartedEvent().Command
+				set := cmd.Lookup("update", "$set").Document()
+				elems, _ := set.Elements()
+				got := bson.M{}
+				for _, e := range elems {
+					got[e.Key()] = e.Value().Boolean()
+				}
+				assert.Equal(t, tc.wantSet, got)
+			}
+		})
+	}
+}
+
+func TestSetCommentRemoved(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID, commentID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	mt.Run("Снятие комментария", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: bson.D{
+				{Key: "_id", Value: postID},
+				{Key: "comments", Value: bson.A{bson.D{{Key: "_id", Value: commentID}, {Key: "removed", Value: true}}}},
+			}},
+		})
+
+		post, err := repo.SetCommentRemoved(context.Background(), postID, commentID, true)
+		assert.NoError(t, err)
+		if assert.Len(t, post.Comments, 1) {
+			assert.True(t, post.Comments[0].Removed)
+		}
+
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(t, commentID, cmd.Lookup("query", "comments", "$elemMatch", "_id").ObjectID())
+		assert.True(t, cmd.Lookup("update", "$set", "comments.$.removed").Boolean())
+	})
+
+	mt.Run("Комментарий не найден", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}})
+
+		_, err := repo.SetCommentRemoved(context.Background(), postID, commentID, false)
+		assert.ErrorIs(t, err, ErrCommentNotFound)
+	})
+}
+
+func TestStickyFirst(t *testing.T) {
+	list := []*Post{{Title: "a"}, {Title: "b", Sticky: true}, {Title: "c"}, {Title: "d", Sticky: true}}
+	StickyFirst(list)
+
+	titles := []string{}
+	for _, p := range list {
+		titles = append(titles, p.Title)
+	}
+	assert.Equal(t, []string{"b", "d", "a", "c"}, titles)
+}
+
+func TestWithoutRemoved(t *testing.T) {
+	filter := WithoutRemoved(func(p *Post) bool { return p.Category == "music" })
+
+	assert.True(t, filter(&Post{Category: "music"}))
+	assert.False(t, filter(&Post{Category: "music", Removed: true}))
+	assert.False(t, filter(&Post{Category: "news"}))
+}
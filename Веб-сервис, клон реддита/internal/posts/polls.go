@@ -0,0 +1,201 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	MinPollOptions = 2
+	MaxPollOptions = 10
+)
+
+var (
+	ErrBadPoll       = errors.New("poll needs 2-10 non-empty options and a close time in the future")
+	ErrNotPoll       = errors.New("post is not a poll")
+	ErrPollClosed    = errors.New("poll is closed")
+	ErrAlreadyVoted  = errors.New("already voted in this poll")
+	ErrBadPollOption = errors.New("unknown poll option")
+)
+
+// Poll - опрос. Голоса хранятся в самом посте: условие "этот пользователь ещё не голосовал"
+// проверяется и голос записывается одним обновлением документа.
+// Итоги в JSON попадают только через ShowPoll, по умолчанию они скрыты.
+type Poll struct {
+	Options  []*PollOption `json:"options" bson:"options"`
+	ClosesAt *time.Time    `json:"closesAt,omitempty" bson:"closesAt,omitempty"`
+	Voters   []PollVoter   `json:"-" bson:"voters"`
+
+	// Поля ниже ShowPoll заполняет для конкретного читателя, в базе их нет.
+	Closed  bool         `json:"closed" bson:"-"`
+	Voted   *int         `json:"voted,omitempty" bson:"-"`
+	Results *PollResults `json:"results,omitempty" bson:"-"`
+}
+
+type PollOption struct {
+	ID    int    `json:"id" bson:"id"`
+	Text  string `json:"text" bson:"text"`
+	Votes int    `json:"-" bson:"votes"`
+}
+
+type PollVoter struct {
+	UserID int64 `bson:"userId"`
+	Option int   `bson:"option"`
+}
+
+// PollResults - итоги опроса. Votes идут в порядке Options.
+type PollResults struct {
+	Total int   `json:"total"`
+	Votes []int `json:"votes"`
+}
+
+// PollForm - опрос в форме нового поста.
+type PollForm struct {
+	Options  []string   `json:"options"`
+	ClosesAt *time.Time `json:"closesAt,omitempty"`
+}
+
+// PollVoteForm - тело запроса на голос в опросе.
+type PollVoteForm struct {
+	Option *int `json:"option" validate:"required"`
+}
+
+// newPoll проверяет форму и собирает опрос. Варианты нумеруются с нуля.
+func newPoll(form *PollForm, now time.Time) (*Poll, error) {
+	if form == nil || len(form.Options) < MinPollOptions || len(form.Options) > MaxPollOptions {
+		return nil, ErrBadPoll
+	}
+	if form.ClosesAt != nil && !form.ClosesAt.After(now) {
+		return nil, ErrBadPoll
+	}
+
+	poll := &Poll{Options: make([]*PollOption, 0, len(form.Options)), Voters: []PollVoter{}}
+	for i, text := range form.Options {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return nil, ErrBadPoll
+		}
+		poll.Options = append(poll.Options, &PollOption{ID: i, Text: text})
+	}
+	if form.ClosesAt != nil {
+		closesAt := form.ClosesAt.UTC()
+		poll.ClosesAt = &closesAt
+	}
+	return poll, nil
+}
+
+func (p *Poll) closed(now time.Time) bool {
+	return p.ClosesAt != nil && !p.ClosesAt.After(now)
+}
+
+func (p *Poll) voteOf(userID int64) (int, bool) {
+	for _, v := range p.Voters {
+		if v.UserID == userID {
+			return v.Option, true
+		}
+	}
+	return 0, false
+}
+
+func (p *Poll) hasOption(option int) bool {
+	for _, o := range p.Options {
+		if o.ID == option {
+			return true
+		}
+	}
+	return false
+}
+
+// ShowPoll готовит пост для читателя userID (0 - аноним): отмечает, закрыт ли опрос и за что
+// голосовал читатель, и открывает итоги, если читатель уже голосовал или опрос закрыт.
+// Исходный пост не меняется: он может быть уже отдан другим читателям, например в событии SSE.
+func ShowPoll(post *Post, userID int64, now time.Time) *Post {
+	if post.Poll == nil {
+		return post
+	}
+	poll := *post.Poll
+	poll.Closed = poll.closed(now)
+	poll.Voted, poll.Results = nil, nil
+	if option, ok := poll.voteOf(userID); ok && userID != 0 {
+		poll.Voted = &option
+	}
+	if poll.Voted != nil || poll.Closed {
+		results := &PollResults{Votes: make([]int, len(poll.Options))}
+		for i, o := range poll.Options {
+			results.Votes[i] = o.Votes
+			results.Total += o.Votes
+		}
+		poll.Results = results
+	}
+
+	shown := *post
+	shown.Poll = &poll
+	return &shown
+}
+
+// VotePoll записывает голос userID за вариант option. Голос нельзя изменить или отозвать.
+func (repo *PostMongoRepository) VotePoll(ctx context.Context, postID primitive.ObjectID, userID int64, option int) (*Post, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id":                postID,
+		"type":               TypePoll,
+		"deletedAt":          notDeleted(),
+		"poll.voters.userId": bson.M{"$ne": userID},
+		"poll.options.id":    option,
+		"$or": bson.A{
+			bson.M{"poll.closesAt": bson.M{"$exists": false}},
+			bson.M{"poll.closesAt": bson.M{"$gt": now}},
+		},
+	}
+	update := bson.M{
+		"$push": bson.M{"poll.voters": PollVoter{UserID: userID, Option: option}},
+		"$inc":  bson.M{"poll.options.$[o].votes": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"o.id": option}}})
+
+	writeCtx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result := repo.DB.FindOneAndUpdate(writeCtx, filter, update, opts)
+	if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+		return nil, repo.pollRejection(ctx, postID, userID, option, now)
+	}
+	if result.Err() != nil {
+		return nil, storageError(ctx, "posts.VotePoll", result.Err())
+	}
+
+	var post Post
+	if err := result.Decode(&post); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return readable(&post), nil
+}
+
+// pollRejection объясняет, почему VotePoll не нашёл пост.
+func (repo *PostMongoRepository) pollRejection(ctx context.Context, postID primitive.ObjectID, userID int64, option int, now time.Time) error {
+	post, err := repo.findPost(ctx, postID)
+	switch {
+	case err != nil:
+		return err
+	case post.Type != TypePoll || post.Poll == nil:
+		return ErrNotPoll
+	case post.Poll.closed(now):
+		return ErrPollClosed
+	}
+	if _, voted := post.Poll.voteOf(userID); voted {
+		return ErrAlreadyVoted
+	}
+	if !post.Poll.hasOption(option) {
+		return ErrBadPollOption
+	}
+	return ErrFailedUpdate
+}
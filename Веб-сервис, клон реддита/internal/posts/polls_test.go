@@ -0,0 +1,201 @@
+package posts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestNewPoll(t *testing.T) {
+	now := time.Now().UTC()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	eleven := make([]string, 11)
+	for i := range eleven {
+		eleven[i] = "вариант"
+	}
+
+	var tests = []struct {
+		name    string
+		form    *PollForm
+		wantErr error
+	}{
+		{name: "Два варианта", form: &PollForm{Options: []string{" да ", "нет"}}},
+		{name: "С временем закрытия", form: &PollForm{Options: []string{"да", "нет"}, ClosesAt: &future}},
+		{name: "Нет формы", wantErr: ErrBadPoll},
+		{name: "Один вариант", form: &PollForm{Options: []string{"да"}}, wantErr: ErrBadPoll},
+		{name: "Одиннадцать вариантов", form: &PollForm{Options: eleven}, wantErr: ErrBadPoll},
+		{name: "Пустой вариант", form: &PollForm{Options: []string{"да", "  "}}, wantErr: ErrBadPoll},
+		{name: "Закрытие в прошлом", form: &PollForm{Options: []string{"да", "нет"}, ClosesAt: &past}, wantErr: ErrBadPoll},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			poll, err := newPoll(tc.form, now)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, poll.Options, len(tc.form.Options))
+			assert.Equal(t, 0, poll.Options[0].ID)
+			assert.Equal(t, "да", poll.Options[0].Text)
+			assert.NotNil(t, poll.Voters)
+		})
+	}
+}
+
+func TestShowPoll(t *testing.T) {
+	now := time.Now().UTC()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	newPost := func(closesAt *time.Time) *Post {
+		return &Post{Type: TypePoll, Poll: &Poll{
+			Options:  []*PollOption{{ID: 0, Text: "да", Votes: 2}, {ID: 1, Text: "нет", Votes: 1}},
+			ClosesAt: closesAt,
+			Voters:   []PollVoter{{UserID: 1, Option: 0}, {UserID: 2, Option: 0}, {UserID: 3, Option: 1}},
+		}}
+	}
+
+	var tests = []struct {
+		name        string
+		post        *Post
+		userID      int64
+		wantVoted   *int
+		wantResults *PollResults
+		wantClosed  bool
+	}{
+		{name: "Аноним не видит итоги открытого опроса", post: newPost(&future)},
+		{name: "Не голосовавший не видит итоги", post: newPost(nil), userID: 9},
+		{
+			name:        "Голосовавший видит итоги и свой выбор",
+			post:        newPost(nil),
+			userID:      3,
+			wantVoted:   func() *int { v := 1; return &v }(),
+			wantResults: &PollResults{Total: 3, Votes: []int{2, 1}},
+		},
+		{
+			name:        "Итоги закрытого опроса видят все",
+			post:        newPost(&past),
+			wantResults: &PollResults{Total: 3, Votes: []int{2, 1}},
+			wantClosed:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			shown := ShowPoll(tc.post, tc.userID, now)
+			assert.Equal(t, tc.wantVoted, shown.Poll.Voted)
+			assert.Equal(t, tc.wantResults, shown.Poll.Results)
+			assert.Equal(t, tc.wantClosed, shown.Poll.Closed)
+			// Исходный пост мог уйти другим читателям, его не трогаем.
+			assert.Nil(t, tc.post.Poll.Results)
+			assert.Nil(t, tc.post.Poll.Voted)
+		})
+	}
+
+	text := &Post{Type: TypeText}
+	assert.Same(t, text, ShowPoll(text, 1, now))
+}
+
+func TestVotePoll(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID := primitive.NewObjectID()
+	pollDoc := func(closesAt *time.Time, voters ...bson.D) bson.D {
+		poll := bson.D{
+			{Key: "options", Value: bson.A{
+				bson.D{{Key: "id", Value: 0}, {Key: "text", Value: "да"}, {Key: "votes", Value: len(voters)}},
+				bson.D{{Key: "id", Value: 1}, {Key: "text", Value: "нет"}, {Key: "votes", Value: 0}},
+			}},
+			{Key: "voters", Value: bson.A{}},
+		}
+		if len(voters) > 0 {
+			a := bson.A{}
+			for _, v := range voters {
+				a = append(a, v)
+			}
+			poll[1].Value = a
+		}
+		if closesAt != nil {
+			poll = append(poll, bson.E{Key: "closesAt", Value: *closesAt})
+		}
+		return bson.D{{Key: "_id", Value: postID}, {Key: "type", Value: TypePoll}, {Key: "poll", Value: poll}}
+	}
+	cursor := func(doc bson.D) bson.D {
+		return mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, doc)
+	}
+	notMatched := bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}}
+	past := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+	voter := bson.D{{Key: "userId", Value: int64(7)}, {Key: "option", Value: 0}}
+
+	var tests = []struct {
+		name         string
+		option       int
+		mockResponse []bson.D
+		wantErr      error
+	}{
+		{
+			name:         "Голос засчитан",
+			mockResponse: []bson.D{{{Key: "ok", Value: 1}, {Key: "value", Value: pollDoc(nil, voter)}}},
+		},
+		{
+			name:         "Повторный голос",
+			mockResponse: []bson.D{notMatched, cursor(pollDoc(nil, voter))},
+			wantErr:      ErrAlreadyVoted,
+		},
+		{
+			name:         "Опрос закрыт",
+			mockResponse: []bson.D{notMatched, cursor(pollDoc(&past))},
+			wantErr:      ErrPollClosed,
+		},
+		{
+			name:         "Нет такого варианта",
+			option:       5,
+			mockResponse: []bson.D{notMatched, cursor(pollDoc(nil))},
+			wantErr:      ErrBadPollOption,
+		},
+		{
+			name:         "Пост не опрос",
+			mockResponse: []bson.D{notMatched, cursor(bson.D{{Key: "_id", Value: postID}, {Key: "type", Value: TypeText}})},
+			wantErr:      ErrNotPoll,
+		},
+		{
+			name:         "Пост не найден",
+			mockResponse: []bson.D{notMatched, mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch)},
+			wantErr:      ErrPostNotFound,
+		},
+		{
+			name:         "Ошибка хранилища",
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			post, err := repo.VotePoll(context.Background(), postID, 7, tc.option)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, post.Poll.Options[0].Votes)
+
+			// Один голос на пользователя проверяется в самом обновлении.
+			cmd := mt.GetStartedEvent().Command
+			assert.Equal(t, int64(7), cmd.Lookup("query", "poll.voters.userId", "$ne").Int64())
+			assert.Equal(t, int64(7), cmd.Lookup("update", "$push", "poll.voters", "userId").Int64())
+			assert.Equal(t, int32(1), cmd.Lookup("update", "$inc", "poll.options.$[o].votes").Int32())
+			filters := cmd.Lookup("arrayFilters").Array().Index(0).Value().Document()
+			assert.Equal(t, int32(tc.option), filters.Lookup("o.id").Int32())
+		})
+	}
+}
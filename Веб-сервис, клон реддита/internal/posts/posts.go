@@ -1,15 +1,44 @@
 package posts
 
 import (
+	"context"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"redditclone/internal/user"
+	"time"
 )
 
 type Comment struct {
-	Created string             `json:"created"`
-	Author  *user.User         `json:"author"`
-	Body    string             `json:"body"`
-	ID      primitive.ObjectID `json:"id" bson:"_id"`
+	Created string     `json:"created"`
+	Author  *user.User `json:"author"`
+	Body    string     `json:"body"`
+	// HTML - Body, отрендеренный из Markdown и очищенный. Хранится вместе с исходником.
+	HTML string             `json:"html,omitempty" bson:"html,omitempty"`
+	ID   primitive.ObjectID `json:"id" bson:"_id"`
+	// ParentID - комментарий, на который это ответ. nil - ответ на сам пост.
+	ParentID *primitive.ObjectID `json:"parent,omitempty" bson:"parent,omitempty"`
+	// Removed - комментарий снят модератором. Он остаётся в базе, но виден только модераторам.
+	Removed bool `json:"removed,omitempty" bson:"removed,omitempty"`
+	// Deleted - когда автор удалил комментарий. До окончательного удаления его можно восстановить.
+	Deleted *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+}
+
+// Типы постов.
+const (
+	TypeText  = "text"
+	TypeLink  = "link"
+	TypeImage = "image"
+	TypePoll  = "poll"
+)
+
+// Image - картинка поста. Ключи указывают на файлы в media.BlobStore, клиенту отдаются только адреса.
+type Image struct {
+	Key          string `json:"-" bson:"key"`
+	ThumbnailKey string `json:"-" bson:"thumbnailKey"`
+	URL          string `json:"url" bson:"url"`
+	ThumbnailURL string `json:"thumbnail" bson:"thumbnail"`
+	ContentType  string `json:"contentType" bson:"contentType"`
+	Width        int    `json:"width" bson:"width"`
+	Height       int    `json:"height" bson:"height"`
 }
 
 type Vote struct {
@@ -18,43 +47,108 @@ type Vote struct {
 }
 
 type Post struct {
-	ID               primitive.ObjectID `json:"id" bson:"_id"`
-	Score            int                `json:"score"`
-	Views            int                `json:"views"`
-	Type             string             `json:"type"`
-	Title            string             `json:"title"`
-	Author           *user.User         `json:"author"`
-	Category         string             `json:"category"`
-	Text             string             `json:"text,omitempty" bson:"text,omitempty"`
-	Votes            []*Vote            `json:"votes"`
-	Comments         []*Comment         `json:"comments"`
-	Created          string             `json:"created"`
-	UpvotePercentage int                `json:"upvotePercentage"`
-	UpvoteCount      int                `json:"upvotecount"`
-	VoteCount        int                `json:"votecount"`
-	URL              string             `json:"url,omitempty" bson:"url,omitempty"`
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	Score    int                `json:"score"`
+	Views    int                `json:"views"`
+	Type     string             `json:"type"`
+	Title    string             `json:"title"`
+	Author   *user.User         `json:"author"`
+	Category string             `json:"category"`
+	Text     string             `json:"text,omitempty" bson:"text,omitempty"`
+	// HTML - Text, отрендеренный из Markdown и очищенный. Хранится вместе с исходником.
+	HTML             string     `json:"html,omitempty" bson:"html,omitempty"`
+	Votes            []*Vote    `json:"votes"`
+	Comments         []*Comment `json:"comments"`
+	Created          string     `json:"created"`
+	UpvotePercentage int        `json:"upvotePercentage"`
+	UpvoteCount      int        `json:"upvotecount"`
+	VoteCount        int        `json:"votecount"`
+	URL              string     `json:"url,omitempty" bson:"url,omitempty"`
+	// NormalizedURL - URL, приведённый NormalizeURL, по нему ищутся повторные ссылки.
+	NormalizedURL string        `json:"-" bson:"normalizedUrl,omitempty"`
+	Crosspost     *CrosspostRef `json:"crosspost,omitempty" bson:"crosspost,omitempty"`
+	Image         *Image        `json:"image,omitempty" bson:"image,omitempty"`
+	Poll          *Poll         `json:"poll,omitempty" bson:"poll,omitempty"`
+	// Флаги модерации: снятый пост виден только модераторам, в закрытом нельзя комментировать,
+	// закреплённый идёт первым в списках.
+	Removed bool `json:"removed,omitempty" bson:"removed,omitempty"`
+	Locked  bool `json:"locked,omitempty" bson:"locked,omitempty"`
+	Sticky  bool `json:"sticky,omitempty" bson:"sticky,omitempty"`
+	// Deleted - когда автор удалил пост. Удалённый пост не виден нигде, пока его не восстановят,
+	// а по истечении срока хранения Purger стирает его окончательно.
+	Deleted *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+}
+
+// PostFlags - флаги модерации для SetPostFlags. nil - флаг не меняется.
+type PostFlags struct {
+	Removed *bool
+	Locked  *bool
+	Sticky  *bool
 }
 
 type PostForm struct {
 	Type     string `json:"type"  validate:"required"`
-	Title    string `json:"title"  validate:"required"`
+	Title    string `json:"title"  validate:"required,max=300"`
 	Category string `json:"category"  validate:"required"`
-	Text     string `json:"text,omitempty"`
-	URL      string `json:"url,omitempty"`
+	Text     string `json:"text,omitempty"  validate:"max=40000"`
+	URL      string `json:"url,omitempty"  validate:"max=2048"`
+	// Image заполняет хендлер после загрузки файла, из JSON оно не читается.
+	Image *Image    `json:"-"`
+	Poll  *PollForm `json:"poll,omitempty"`
+	// Resubmit - опубликовать ссылку, даже если её недавно уже публиковали.
+	Resubmit bool `json:"resubmit,omitempty"`
+}
+
+// VoteForm - тело запроса на голосование. Указатель, чтобы отличить 0 (снять голос) от пропущенного поля.
+type VoteForm struct {
+	Vote *int `json:"vote"  validate:"required"`
 }
 
 type CommentForm struct {
-	Body string `json:"comment"  validate:"required"`
+	Body     string `json:"comment"  validate:"required,max=10000"`
+	ParentID string `json:"parent,omitempty"`
+}
+
+// Scope - какие посты отдаёт ListPosts: все, одной категории или одного автора.
+// В отличие от фильтра GetPosts область известна заранее, поэтому по ней списки можно кешировать.
+type Scope struct {
+	Category string
+	Author   string
 }
 
 //go:generate mockgen -source=posts.go -destination=repo_mock.go -package=posts PostRepo
 type PostRepo interface {
-	GetPost(postID primitive.ObjectID) (*Post, error)
-	GetPosts(filter func(*Post) bool) ([]*Post, error)
-	VotePost(postID primitive.ObjectID, user int64, voteVal int) (*Post, error)
-	UnVotePost(postID primitive.ObjectID, user int64) (*Post, error)
-	MakePost(newPost *PostForm, username string, userID int64) (*Post, error)
-	DeletePost(postID primitive.ObjectID, userID int64) (bool, error)
-	MakeComment(postID primitive.ObjectID, comment, username string, userID int64) (*Post, error)
-	DeleteComment(postID primitive.ObjectID, commentID primitive.ObjectID, userID int64) (*Post, error)
+	GetPost(ctx context.Context, postID primitive.ObjectID) (*Post, error)
+	GetPosts(ctx context.Context, filter func(*Post) bool) ([]*Post, error)
+	// ListPosts отдаёт все неудалённые посты области scope, включая снятые модераторами.
+	ListPosts(ctx context.Context, scope Scope) ([]*Post, error)
+	// GetPostsByIDs одним запросом находит неудалённые посты по id, включая снятые модераторами.
+	// Ненайденных в ответе просто нет.
+	GetPostsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*Post, error)
+	// GetPostsByAuthors - то же для постов нескольких авторов.
+	GetPostsByAuthors(ctx context.Context, usernames []string) ([]*Post, error)
+	VotePost(ctx context.Context, postID primitive.ObjectID, user int64, voteVal int) (*Post, error)
+	MakePost(ctx context.Context, newPost *PostForm, username string, userID int64) (*Post, error)
+	DeletePost(ctx context.Context, postID primitive.ObjectID, userID int64) (bool, error)
+	// MakeComment добавляет комментарий. parentID - комментарий, на который отвечают, NilObjectID - ответ на пост.
+	MakeComment(ctx context.Context, postID, parentID primitive.ObjectID, comment, username string, userID int64) (*Post, error)
+	DeleteComment(ctx context.Context, postID primitive.ObjectID, commentID primitive.ObjectID, userID int64) (*Post, error)
+	// RestorePost и RestoreComment отменяют удаление. userID == 0 - восстанавливает модератор.
+	RestorePost(ctx context.Context, postID primitive.ObjectID, userID int64) (*Post, error)
+	RestoreComment(ctx context.Context, postID, commentID primitive.ObjectID, userID int64) (*Post, error)
+	// Purge окончательно стирает посты и комментарии, удалённые раньше before.
+	Purge(ctx context.Context, before time.Time) (PurgeResult, error)
+	AddViews(ctx context.Context, views map[primitive.ObjectID]int) error
+	GetFeed(ctx context.Context, q FeedQuery) (*FeedPage, error)
+	SetPostFlags(ctx context.Context, postID primitive.ObjectID, flags PostFlags) (*Post, error)
+	SetCommentRemoved(ctx context.Context, postID, commentID primitive.ObjectID, removed bool) (*Post, error)
+	// VotePoll записывает голос в опросе, один на пользователя.
+	VotePoll(ctx context.Context, postID primitive.ObjectID, userID int64, option int) (*Post, error)
+	Crosspost(ctx context.Context, originalID primitive.ObjectID, form *CrosspostForm, username string, userID int64) (*Post, error)
+	// FindByURL ищет недавние посты с той же ссылкой, normalizedURL - результат NormalizeURL.
+	FindByURL(ctx context.Context, normalizedURL string, since time.Time, limit int) ([]*Post, error)
+	// AnonymizeUser оставляет посты и комментарии пользователя без автора и снимает его голоса.
+	AnonymizeUser(ctx context.Context, userID int64) (AnonymizeResult, error)
+	// UserContent отдаёт посты, в которых пользователь что-то оставил, вместе с удалёнными - для выгрузки данных.
+	UserContent(ctx context.Context, userID int64) ([]*Post, error)
 }
@@ -1,12 +1,21 @@
 package posts
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net"
+	"redditclone/internal/logging"
 	"redditclone/internal/user"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestGetPost(t *testing.T) {
@@ -23,9 +32,8 @@ func TestGetPost(t *testing.T) {
 	}{
 		{
 			name: "Проверка на успешное выполнение запроса для получения поста",
-			mockResponses: []bson.D{{
-				{Key: "ok", Value: 1},
-				{Key: "value", Value: bson.D{
+			mockResponses: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
 					{Key: "_id", Value: postID},
 					{Key: "Score", Value: 0},
 					{Key: "Views", Value: 9},
@@ -35,8 +43,8 @@ func TestGetPost(t *testing.T) {
 					{Key: "Text", Value: "Text of the post 1"},
 					{Key: "Created", Value: "2024-05-07T20:38:17Z"},
 					{Key: "UpvotePercentage", Value: 0},
-				}},
-			}},
+				}),
+			},
 			postID: primitive.NewObjectID(),
 			expectedPost: &Post{
 				ID:               postID,
@@ -46,11 +54,19 @@ func TestGetPost(t *testing.T) {
 				Title:            "Post 1",
 				Category:         "fashion",
 				Text:             "Text of the post 1",
+				HTML:             "<p>Text of the post 1</p>\n",
 				Created:          "2024-05-07T20:38:17Z",
 				UpvotePercentage: 0,
 			},
 			expectError: false,
 		},
+		{
+			name:          "Проверка на отсутствующий пост",
+			mockResponses: []bson.D{mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch)},
+			postID:        postID,
+			expectedPost:  nil,
+			expectError:   true,
+		},
 		{
 			name:          "Проверка на обработку ошибки при запросе",
 			mockResponses: []bson.D{{{Key: "ok", Value: 0}}},
@@ -66,7 +82,7 @@ func TestGetPost(t *testing.T) {
 			mt.ClearMockResponses()
 			mt.AddMockResponses(tc.mockResponses...)
 
-			post, err := repo.GetPost(tc.postID)
+			post, err := repo.GetPost(context.Background(), tc.postID)
 
 			if tc.expectError {
 				assert.Error(t, err)
@@ -94,6 +110,7 @@ func TestGetPosts(t *testing.T) {
 			Title:            "Post 1",
 			Category:         "fashion",
 			Text:             "Text of the post 1",
+			HTML:             "<p>Text of the post 1</p>\n",
 			Created:          "2024-05-07T20:38:17Z",
 			UpvotePercentage: 0,
 			Author: &user.User{
@@ -109,6 +126,7 @@ func TestGetPosts(t *testing.T) {
 			Title:            "Post 2",
 			Category:         "music",
 			Text:             "Text of the post 1",
+			HTML:             "<p>Text of the post 1</p>\n",
 			Created:          "2024-05-07T20:38:17Z",
 			UpvotePercentage: 0,
 			Author: &user.User{
@@ -124,6 +142,7 @@ func TestGetPosts(t *testing.T) {
 			Title:            "Post 3",
 			Category:         "fashion",
 			Text:             "Text of the post 1",
+			HTML:             "<p>Text of the post 1</p>\n",
 			Created:          "2024-05-07T20:38:17Z",
 			UpvotePercentage: 0,
 			Author: &user.User{
@@ -186,7 +205,7 @@ func TestGetPosts(t *testing.T) {
 		filter        func(*Post) bool
 		mockResponses []bson.D
 		expectedPosts []*Post
-		expectedError string
+		expectedError error
 	}{
 		{
 			name:          "Проверка на успешное выполнение запроса для получения всех постов",
@@ -206,12 +225,18 @@ func TestGetPosts(t *testing.T) {
 			mockResponses: []bson.D{first, second, third, killCursors},
 			expectedPosts: []*Post{expectedPosts[2]},
 		},
+		{
+			name:          "Проверка на успешное выполнение запроса для постов нескольких пользователей",
+			filter:        FilterByUsers([]string{"vasya", "ivan"}),
+			mockResponses: []bson.D{first, second, third, killCursors},
+			expectedPosts: []*Post{expectedPosts[0], expectedPosts[2]},
+		},
 		{
 			name:          "Проверка на обработку ошибки при запросе",
 			filter:        FilterAll(),
 			mockResponses: []bson.D{{{Key: "ok", Value: 0}}},
 			expectedPosts: nil,
-			expectedError: ErrPostNotFound,
+			expectedError: ErrStorage,
 		},
 		{
 			name:          "Проверка на обработку ошибки при конвертации всех постов",
@@ -228,10 +253,10 @@ func TestGetPosts(t *testing.T) {
 			mt.ClearMockResponses()
 			mt.AddMockResponses(tc.mockResponses...)
 
-			posts, err := repo.GetPosts(tc.filter)
+			posts, err := repo.GetPosts(context.Background(), tc.filter)
 
 			if err != nil {
-				assert.Equal(t, tc.expectedError, err.Error())
+				assert.ErrorIs(t, err, tc.expectedError)
 				assert.Nil(t, posts)
 			} else {
 				assert.NoError(t, err)
@@ -241,65 +266,206 @@ func TestGetPosts(t *testing.T) {
 	}
 }
 
+func TestListPosts(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postID := primitive.NewObjectID()
+	post := mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: postID},
+		{Key: "type", Value: "text"},
+		{Key: "title", Value: "Post"},
+		{Key: "category", Value: "music"},
+		{Key: "text", Value: "**text**"},
+		{Key: "author", Value: bson.M{"id": 1, "username": "vasya"}},
+	})
+
+	testCases := []struct {
+		name          string
+		scope         Scope
+		mockResponses []bson.D
+		wantQuery     bson.M
+		wantCount     int
+		expectedError error
+	}{
+		{
+			name:          "Все посты",
+			mockResponses: []bson.D{post},
+			wantQuery:     bson.M{"deletedAt": bson.M{"$exists": false}},
+			wantCount:     1,
+		},
+		{
+			name:          "Посты категории",
+			scope:         Scope{Category: "music"},
+			mockResponses: []bson.D{post},
+			wantQuery:     bson.M{"deletedAt": bson.M{"$exists": false}, "category": "music"},
+			wantCount:     1,
+		},
+		{
+			name:          "Посты автора",
+			scope:         Scope{Author: "vasya"},
+			mockResponses: []bson.D{post},
+			wantQuery:     bson.M{"deletedAt": bson.M{"$exists": false}, "author.username": "vasya"},
+			wantCount:     1,
+		},
+		{
+			name:          "Ошибка хранилища",
+			mockResponses: []bson.D{{{Key: "ok", Value: 0}}},
+			expectedError: ErrStorage,
+		},
+	}
+
+	for _, tc := range testCases {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponses...)
+
+			posts, err := repo.ListPosts(context.Background(), tc.scope)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, posts, tc.wantCount)
+			assert.Equal(t, "<p><strong>text</strong></p>\n", posts[0].HTML)
+
+			var query bson.M
+			assert.NoError(t, bson.Unmarshal(mt.GetStartedEvent().Command.Lookup("filter").Document(), &query))
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestGetPostsByIDs(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+
+	mt.Run("Посты ищутся по id в монге", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: first},
+			{Key: "type", Value: "text"},
+			{Key: "text", Value: "**text**"},
+			{Key: "author", Value: bson.M{"id": 1, "username": "vasya"}},
+		}))
+
+		posts, err := repo.GetPostsByIDs(context.Background(), []primitive.ObjectID{first, second})
+		assert.NoError(t, err)
+		if assert.Len(t, posts, 1) {
+			assert.Equal(t, "<p><strong>text</strong></p>\n", posts[0].HTML)
+		}
+
+		var query bson.M
+		assert.NoError(t, bson.Unmarshal(mt.GetStartedEvent().Command.Lookup("filter").Document(), &query))
+		assert.Equal(t, bson.M{
+			"_id":       bson.M{"$in": bson.A{first, second}},
+			"deletedAt": bson.M{"$exists": false},
+		}, query)
+	})
+
+	mt.Run("Пустой список не ходит в базу", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+
+		posts, err := repo.GetPostsByIDs(context.Background(), nil)
+		assert.NoError(t, err)
+		assert.Empty(t, posts)
+		assert.Empty(t, mt.GetAllStartedEvents())
+	})
+
+	mt.Run("Ошибка хранилища", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.GetPostsByIDs(context.Background(), []primitive.ObjectID{first})
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestGetPostsByAuthors(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Посты ищутся по авторам в монге", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "author", Value: bson.M{"id": 1, "username": "vasya"}}},
+			bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "author", Value: bson.M{"id": 2, "username": "ivan"}}},
+		))
+
+		posts, err := repo.GetPostsByAuthors(context.Background(), []string{"vasya", "ivan"})
+		assert.NoError(t, err)
+		assert.Len(t, posts, 2)
+
+		var query bson.M
+		assert.NoError(t, bson.Unmarshal(mt.GetStartedEvent().Command.Lookup("filter").Document(), &query))
+		assert.Equal(t, bson.M{
+			"author.username": bson.M{"$in": bson.A{"vasya", "ivan"}},
+			"deletedAt":       bson.M{"$exists": false},
+		}, query)
+	})
+
+	mt.Run("Пустой список не ходит в базу", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+
+		posts, err := repo.GetPostsByAuthors(context.Background(), nil)
+		assert.NoError(t, err)
+		assert.Empty(t, posts)
+		assert.Empty(t, mt.GetAllStartedEvents())
+	})
+}
+
 func TestVotePost(t *testing.T) {
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	postID := primitive.NewObjectID()
-	post := bson.D{
-		{Key: "ok", Value: 1},
-		{Key: "value", Value: bson.D{
-			{Key: "_id", Value: postID},
-			{Key: "Score", Value: 1},
-			{Key: "Views", Value: 9},
-			{Key: "Type", Value: "text"},
-			{Key: "Title", Value: "Post 3"},
-			{Key: "Category", Value: "fashion"},
-			{Key: "Text", Value: "Text of the post 1"},
-			{Key: "Created", Value: "2024-05-07T20:38:17Z"},
-			{Key: "UpvotePercentage", Value: 100},
-			{Key: "Upvotecount", Value: 2},
-			{Key: "Votecount", Value: 3},
-			{Key: "Author", Value: bson.M{
-				"id":       3,
-				"username": "ivan",
-			}},
-			{Key: "Votes", Value: bson.A{
-				bson.D{{Key: "userID", Value: 1}, {Key: "vote", Value: 1}},
-				bson.D{{Key: "userID", Value: 2}, {Key: "vote", Value: 1}},
-				bson.D{{Key: "userID", Value: 3}, {Key: "vote", Value: -1}},
-			}},
+	post := mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: postID},
+		{Key: "Score", Value: 1},
+		{Key: "Views", Value: 9},
+		{Key: "Type", Value: "text"},
+		{Key: "Title", Value: "Post 3"},
+		{Key: "Category", Value: "fashion"},
+		{Key: "Text", Value: "Text of the post 1"},
+		{Key: "Created", Value: "2024-05-07T20:38:17Z"},
+		{Key: "UpvotePercentage", Value: 66},
+		{Key: "Upvotecount", Value: 2},
+		{Key: "Votecount", Value: 3},
+		{Key: "Author", Value: bson.M{
+			"id":       3,
+			"username": "ivan",
 		}},
-	}
-	badPost := bson.D{
-		{Key: "ok", Value: 1},
-		{Key: "value", Value: bson.D{
-			{Key: "_id", Value: postID},
-			{Key: "Score", Value: 1},
-			{Key: "Views", Value: 3},
-			{Key: "Type", Value: "text"},
-			{Key: "Title", Value: "Post 1"},
-			{Key: "Category", Value: "fashion"},
-			{Key: "Text", Value: "Text of the post 1"},
-			{Key: "Created", Value: "2024-05-07T20:38:17Z"},
-			{Key: "UpvotePercentage", Value: 0},
-			{Key: "Upvotecount", Value: 0},
-			{Key: "Votecount", Value: 0},
-			{Key: "Author", Value: bson.M{
-				"id":       1,
-				"username": "ivan",
-			}},
-			{Key: "Votes", Value: bson.A{
-				bson.D{{Key: "userID", Value: 1}, {Key: "vote", Value: 1}},
-			}},
+		{Key: "Votes", Value: bson.A{
+			bson.D{{Key: "userID", Value: 1}, {Key: "vote", Value: 1}},
+			bson.D{{Key: "userID", Value: 2}, {Key: "vote", Value: 1}},
+			bson.D{{Key: "userID", Value: 3}, {Key: "vote", Value: -1}},
 		}},
-	}
+	})
+	badPost := mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: postID},
+		{Key: "Score", Value: 1},
+		{Key: "Views", Value: 3},
+		{Key: "Type", Value: "text"},
+		{Key: "Title", Value: "Post 1"},
+		{Key: "Category", Value: "fashion"},
+		{Key: "Text", Value: "Text of the post 1"},
+		{Key: "Created", Value: "2024-05-07T20:38:17Z"},
+		{Key: "UpvotePercentage", Value: 0},
+		{Key: "Upvotecount", Value: 0},
+		{Key: "Votecount", Value: 0},
+		{Key: "Author", Value: bson.M{
+			"id":       1,
+			"username": "ivan",
+		}},
+		{Key: "Votes", Value: bson.A{
+			bson.D{{Key: "userID", Value: 1}, {Key: "vote", Value: 1}},
+		}},
+	})
 
 	var tests = []struct {
 		name            string
 		userID          int64
 		voteChange      int
 		mockResponse    []bson.D
-		wantErr         string
+		wantErr         error
 		expectedScore   int
 		expectedPercent int
 	}{
@@ -316,7 +482,7 @@ func TestVotePost(t *testing.T) {
 					{Key: "nModified", Value: 1},
 				},
 			},
-			wantErr:         "",
+			wantErr:         nil,
 			expectedScore:   3,
 			expectedPercent: 100,
 		},
@@ -333,7 +499,7 @@ func TestVotePost(t *testing.T) {
 					{Key: "nModified", Value: 1},
 				},
 			},
-			wantErr:         "",
+			wantErr:         nil,
 			expectedScore:   -1,
 			expectedPercent: 33,
 		},
@@ -350,12 +516,23 @@ func TestVotePost(t *testing.T) {
 					{Key: "nModified", Value: 1},
 				},
 			},
-			wantErr:         "",
+			wantErr:         nil,
 			expectedScore:   2,
 			expectedPercent: 75,
 		},
 		{
-			name:       "Проверка, что post.VoteCount < 0 обрабатывается правильно",
+			name:       "Проверка, что повторный голос не пишет в базу",
+			userID:     1,
+			voteChange: 1,
+			mockResponse: []bson.D{
+				post,
+			},
+			wantErr:         nil,
+			expectedScore:   1,
+			expectedPercent: 66,
+		},
+		{
+			name:       "Проверка, что рассинхронизированные счётчики пересчитываются",
 			userID:     1,
 			voteChange: 1,
 			mockResponse: []bson.D{
@@ -367,9 +544,15 @@ func TestVotePost(t *testing.T) {
 					{Key: "nModified", Value: 1},
 				},
 			},
-			wantErr:         "",
+			wantErr:         nil,
 			expectedScore:   1,
-			expectedPercent: 0,
+			expectedPercent: 100,
+		},
+		{
+			name:       "Проверка на недопустимое значение голоса",
+			userID:     1,
+			voteChange: 2,
+			wantErr:    ErrBadVote,
 		},
 		{
 			name:       "Проверка на обработку ошибки при запросе на получение поста",
@@ -378,7 +561,7 @@ func TestVotePost(t *testing.T) {
 			mockResponse: []bson.D{
 				{{Key: "ok", Value: 0}},
 			},
-			wantErr: `{"message": "post not found"}`,
+			wantErr: ErrStorage,
 		},
 		{
 			name:       "Проверка на обработку ошибки при обновлении поста",
@@ -388,20 +571,35 @@ func TestVotePost(t *testing.T) {
 				post,
 				{{Key: "ok", Value: 0}},
 			},
-			wantErr: `{"message": "bad request"}`,
+			wantErr: ErrStorage,
 		},
 		{
-			name:       "Проверка на обработку ошибки ModifiedCount == 0",
+			name:       "Проверка, что голос пишется заново, если голоса поменялись после чтения",
 			userID:     1,
 			voteChange: -1,
 			mockResponse: []bson.D{
 				post,
-				{
-					{Key: "ok", Value: 1},
-					{Key: "acknowledged", Value: true},
-				},
+				{{Key: "ok", Value: 1}, {Key: "n", Value: 0}},
+				post,
+				{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
 			},
-			wantErr: `{"message": "failed to update field"}`,
+			wantErr:         nil,
+			expectedScore:   -1,
+			expectedPercent: 33,
+		},
+		{
+			name:       "Проверка на обработку ошибки, если голоса меняются при каждой попытке",
+			userID:     1,
+			voteChange: -1,
+			mockResponse: []bson.D{
+				post,
+				{{Key: "ok", Value: 1}, {Key: "n", Value: 0}},
+				post,
+				{{Key: "ok", Value: 1}, {Key: "n", Value: 0}},
+				post,
+				{{Key: "ok", Value: 1}, {Key: "n", Value: 0}},
+			},
+			wantErr: ErrFailedUpdate,
 		},
 	}
 
@@ -410,10 +608,10 @@ func TestVotePost(t *testing.T) {
 			repo := NewMongoRepo(mt.Coll)
 			mt.AddMockResponses(tc.mockResponse...)
 
-			result, err := repo.VotePost(postID, tc.userID, tc.voteChange)
-			if tc.wantErr != "" {
+			result, err := repo.VotePost(context.Background(), postID, tc.userID, tc.voteChange)
+			if tc.wantErr != nil {
 				assert.Error(t, err)
-				assert.EqualError(t, err, tc.wantErr)
+				assert.ErrorIs(t, err, tc.wantErr)
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, result)
@@ -424,64 +622,101 @@ func TestVotePost(t *testing.T) {
 	}
 }
 
+func TestVotePostPartialUpdate(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Смена голоса пишет только голоса и счётчики", func(mt *mtest.T) {
+		postID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: postID},
+				{Key: "votes", Value: bson.A{bson.D{{Key: "userID", Value: 2}, {Key: "vote", Value: 1}}}},
+			}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+		)
+
+		repo := NewMongoRepo(mt.Coll)
+		_, err := repo.VotePost(context.Background(), postID, 2, -1)
+		assert.NoError(t, err)
+
+		started := mt.GetAllStartedEvents()
+		if !assert.Len(t, started, 2) {
+			return
+		}
+		assert.Equal(t, "update", started[1].CommandName)
+		update := started[1].Command.Lookup("updates").Array().Index(0).Value().Document()
+		readVotes, err := update.Lookup("q", "votes").Array().Values()
+		assert.NoError(t, err)
+		if assert.Len(t, readVotes, 1, "filter keeps the votes that were read") {
+			assert.Equal(t, int32(1), readVotes[0].Document().Lookup("vote").Int32(), "filter keeps the vote before the change")
+		}
+		newVotes, err := update.Lookup("u", "$set", "votes").Array().Values()
+		assert.NoError(t, err)
+		if assert.Len(t, newVotes, 1) {
+			assert.Equal(t, int32(-1), newVotes[0].Document().Lookup("vote").Int32())
+		}
+		set, err := update.Lookup("u", "$set").Document().Elements()
+		assert.NoError(t, err)
+		var keys []string
+		for _, el := range set {
+			keys = append(keys, el.Key())
+		}
+		assert.ElementsMatch(t, []string{"votes", "score", "upvotecount", "votecount", "upvotepercentage"}, keys)
+	})
+}
+
 func TestUnVotePost(t *testing.T) {
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	postID := primitive.NewObjectID()
-	post := bson.D{
-		{Key: "ok", Value: 1},
-		{Key: "value", Value: bson.D{
-			{Key: "_id", Value: postID},
-			{Key: "Score", Value: 1},
-			{Key: "Views", Value: 9},
-			{Key: "Type", Value: "text"},
-			{Key: "Title", Value: "Post 3"},
-			{Key: "Category", Value: "fashion"},
-			{Key: "Text", Value: "Text of the post 1"},
-			{Key: "Created", Value: "2024-05-07T20:38:17Z"},
-			{Key: "UpvotePercentage", Value: 100},
-			{Key: "Upvotecount", Value: 2},
-			{Key: "Votecount", Value: 3},
-			{Key: "Author", Value: bson.M{
-				"id":       3,
-				"username": "ivan",
-			}},
-			{Key: "Votes", Value: bson.A{
-				bson.D{{Key: "userID", Value: 1}, {Key: "vote", Value: 1}},
-				bson.D{{Key: "userID", Value: 2}, {Key: "vote", Value: 1}},
-				bson.D{{Key: "userID", Value: 3}, {Key: "vote", Value: -1}},
-			}},
+	post := mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: postID},
+		{Key: "Score", Value: 1},
+		{Key: "Views", Value: 9},
+		{Key: "Type", Value: "text"},
+		{Key: "Title", Value: "Post 3"},
+		{Key: "Category", Value: "fashion"},
+		{Key: "Text", Value: "Text of the post 1"},
+		{Key: "Created", Value: "2024-05-07T20:38:17Z"},
+		{Key: "UpvotePercentage", Value: 66},
+		{Key: "Upvotecount", Value: 2},
+		{Key: "Votecount", Value: 3},
+		{Key: "Author", Value: bson.M{
+			"id":       3,
+			"username": "ivan",
 		}},
-	}
-	badPost := bson.D{
-		{Key: "ok", Value: 1},
-		{Key: "value", Value: bson.D{
-			{Key: "_id", Value: postID},
-			{Key: "Score", Value: 1},
-			{Key: "Views", Value: 3},
-			{Key: "Type", Value: "text"},
-			{Key: "Title", Value: "Post 1"},
-			{Key: "Category", Value: "fashion"},
-			{Key: "Text", Value: "Text of the post 1"},
-			{Key: "Created", Value: "2024-05-07T20:38:17Z"},
-			{Key: "UpvotePercentage", Value: 0},
-			{Key: "Upvotecount", Value: 0},
-			{Key: "Votecount", Value: 0},
-			{Key: "Author", Value: bson.M{
-				"id":       1,
-				"username": "ivan",
-			}},
-			{Key: "Votes", Value: bson.A{
-				bson.D{{Key: "userID", Value: 1}, {Key: "vote", Value: 1}},
-			}},
+		{Key: "Votes", Value: bson.A{
+			bson.D{{Key: "userID", Value: 1}, {Key: "vote", Value: 1}},
+			bson.D{{Key: "userID", Value: 2}, {Key: "vote", Value: 1}},
+			bson.D{{Key: "userID", Value: 3}, {Key: "vote", Value: -1}},
 		}},
-	}
+	})
+	badPost := mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: postID},
+		{Key: "Score", Value: 1},
+		{Key: "Views", Value: 3},
+		{Key: "Type", Value: "text"},
+		{Key: "Title", Value: "Post 1"},
+		{Key: "Category", Value: "fashion"},
+		{Key: "Text", Value: "Text of the post 1"},
+		{Key: "Created", Value: "2024-05-07T20:38:17Z"},
+		{Key: "UpvotePercentage", Value: 0},
+		{Key: "Upvotecount", Value: 0},
+		{Key: "Votecount", Value: 0},
+		{Key: "Author", Value: bson.M{
+			"id":       1,
+			"username": "ivan",
+		}},
+		{Key: "Votes", Value: bson.A{
+			bson.D{{Key: "userID", Value: 1}, {Key: "vote", Value: 1}},
+		}},
+	})
 
 	var tests = []struct {
 		name               string
 		userID             int64
 		mockResponse       []bson.D
-		wantErr            string
+		wantErr            error
 		expectedScore      int
 		expectedPercent    int
 		expectedVotesCount int
@@ -498,13 +733,24 @@ func TestUnVotePost(t *testing.T) {
 					{Key: "nModified", Value: 1},
 				},
 			},
-			wantErr:            "",
+			wantErr:            nil,
 			expectedScore:      2,
 			expectedPercent:    100,
 			expectedVotesCount: 2,
 		},
 		{
-			name:   "Проверка, что post.VoteCount < 0 обрабатывается правильно",
+			name:   "Проверка, что снятие несуществующего голоса ничего не меняет",
+			userID: 5,
+			mockResponse: []bson.D{
+				post,
+			},
+			wantErr:            nil,
+			expectedScore:      1,
+			expectedPercent:    66,
+			expectedVotesCount: 3,
+		},
+		{
+			name:   "Проверка, что рассинхронизированные счётчики пересчитываются",
 			userID: 1,
 			mockResponse: []bson.D{
 				badPost,
@@ -515,10 +761,10 @@ func TestUnVotePost(t *testing.T) {
 					{Key: "nModified", Value: 1},
 				},
 			},
-			wantErr:            "",
+			wantErr:            nil,
 			expectedScore:      0,
 			expectedPercent:    0,
-			expectedVotesCount: 2,
+			expectedVotesCount: 0,
 		},
 		{
 			name:   "Проверка на обработку ошибки при запросе на получение поста",
@@ -526,7 +772,7 @@ func TestUnVotePost(t *testing.T) {
 			mockResponse: []bson.D{
 				{{Key: "ok", Value: 0}},
 			},
-			wantErr: `{"message": "post not found"}`,
+			wantErr: ErrStorage,
 		},
 		{
 			name:   "Проверка на обработку ошибки при удалении комментария",
@@ -535,19 +781,20 @@ func TestUnVotePost(t *testing.T) {
 				post,
 				{{Key: "ok", Value: 0}},
 			},
-			wantErr: `{"message": "failed to update field"}`,
+			wantErr: ErrStorage,
 		},
 		{
-			name:   "Проверка на обработку ошибки ModifiedCount == 0",
+			name:   "Проверка на обработку ошибки, если голоса меняются при каждой попытке",
 			userID: 1,
 			mockResponse: []bson.D{
 				post,
-				{
-					{Key: "ok", Value: 1},
-					{Key: "acknowledged", Value: true},
-				},
+				{{Key: "ok", Value: 1}, {Key: "n", Value: 0}},
+				post,
+				{{Key: "ok", Value: 1}, {Key: "n", Value: 0}},
+				post,
+				{{Key: "ok", Value: 1}, {Key: "n", Value: 0}},
 			},
-			wantErr: `{"message": "failed to update field"}`,
+			wantErr: ErrFailedUpdate,
 		},
 	}
 
@@ -556,15 +803,17 @@ func TestUnVotePost(t *testing.T) {
 			repo := NewMongoRepo(mt.Coll)
 			mt.AddMockResponses(tc.mockResponse...)
 
-			result, err := repo.UnVotePost(postID, tc.userID)
-			if tc.wantErr != "" {
+			result, err := repo.VotePost(context.Background(), postID, tc.userID, 0)
+			if tc.wantErr != nil {
 				assert.Error(t, err)
-				assert.EqualError(t, err, tc.wantErr)
+				assert.ErrorIs(t, err, tc.wantErr)
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, result)
 				assert.Equal(t, tc.expectedScore, result.Score, "expected score %d, got %d", tc.expectedScore, result.Score)
 				assert.Equal(t, tc.expectedPercent, result.UpvotePercentage, "expected upvote percentage %d, got %d", tc.expectedPercent, result.UpvotePercentage)
+				assert.Equal(t, tc.expectedVotesCount, result.VoteCount, "expected vote count %d, got %d", tc.expectedVotesCount, result.VoteCount)
+				assert.Len(t, result.Votes, tc.expectedVotesCount)
 			}
 		})
 	}
@@ -587,12 +836,19 @@ func TestMakePost(t *testing.T) {
 		URL:      "https://www.youtube.com/",
 	}
 
+	newImagePost := PostForm{
+		Type:     TypeImage,
+		Title:    "Post 1",
+		Category: "funny",
+		Image:    &Image{Key: "abc.png", ThumbnailKey: "abc_thumb.png", URL: "/media/abc.png", Width: 40, Height: 20},
+	}
+
 	var tests = []struct {
 		name         string
 		userID       int64
 		userName     string
 		mockResponse []bson.D
-		wantErr      string
+		wantErr      error
 		newPostData  PostForm
 	}{
 		{
@@ -602,7 +858,7 @@ func TestMakePost(t *testing.T) {
 			mockResponse: []bson.D{
 				mtest.CreateSuccessResponse(),
 			},
-			wantErr:     "",
+			wantErr:     nil,
 			newPostData: newTextPost,
 		},
 		{
@@ -612,13 +868,43 @@ func TestMakePost(t *testing.T) {
 			mockResponse: []bson.D{
 				mtest.CreateSuccessResponse(),
 			},
-			wantErr:     "",
+			wantErr:     nil,
 			newPostData: newURLPost,
 		},
+		{
+			name:     "Проверка на успешное создание поста с картинкой",
+			userID:   3,
+			userName: "ivan",
+			mockResponse: []bson.D{
+				mtest.CreateSuccessResponse(),
+			},
+			newPostData: newImagePost,
+		},
+		{
+			name:     "Проверка на успешное создание опроса",
+			userID:   3,
+			userName: "ivan",
+			mockResponse: []bson.D{
+				mtest.CreateSuccessResponse(),
+			},
+			newPostData: PostForm{Type: TypePoll, Title: "Post 1", Category: "funny", Poll: &PollForm{Options: []string{"да", "нет"}}},
+		},
+		{
+			name:        "Проверка на обработку ошибки, когда в опросе один вариант",
+			userID:      3,
+			newPostData: PostForm{Type: TypePoll, Title: "Post 1", Category: "funny", Poll: &PollForm{Options: []string{"да"}}},
+			wantErr:     ErrBadPoll,
+		},
+		{
+			name:        "Проверка на обработку ошибки, когда у поста с картинкой нет картинки",
+			userID:      3,
+			newPostData: PostForm{Type: TypeImage, Title: "Post 1", Category: "funny"},
+			wantErr:     ErrNoImage,
+		},
 		{
 			name:    "Проверка на обработку ошибки, когда данные для поста пустые",
 			userID:  3,
-			wantErr: `{"message": "bad request"}`,
+			wantErr: ErrBadPostType,
 		},
 		{
 			name:     "Проверка на обработку ошибки при запросе на создание поста",
@@ -628,7 +914,7 @@ func TestMakePost(t *testing.T) {
 				{{Key: "ok", Value: 0}},
 			},
 			newPostData: newTextPost,
-			wantErr:     `{"message": "bad request"}`,
+			wantErr:     ErrStorage,
 		},
 	}
 
@@ -637,15 +923,30 @@ func TestMakePost(t *testing.T) {
 			repo := NewMongoRepo(mt.Coll)
 			mt.AddMockResponses(tc.mockResponse...)
 
-			result, err := repo.MakePost(&tc.newPostData, tc.userName, tc.userID)
-			if tc.wantErr != "" {
+			result, err := repo.MakePost(context.Background(), &tc.newPostData, tc.userName, tc.userID)
+			if tc.wantErr != nil {
 				assert.Error(t, err)
-				assert.EqualError(t, err, tc.wantErr)
+				assert.ErrorIs(t, err, tc.wantErr)
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, result)
 				assert.Equal(t, tc.userID, result.Author.ID, "expected userID %d, got %d", tc.userID, result.Author.ID)
 				assert.Equal(t, tc.newPostData.Title, result.Title, "expected title %s, got %s", tc.newPostData.Title, result.Title)
+				if tc.newPostData.Image != nil {
+					doc := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+					assert.Equal(t, "abc.png", doc.Lookup("image", "key").StringValue())
+					assert.Equal(t, "/media/abc.png", doc.Lookup("image", "url").StringValue())
+				}
+				if tc.newPostData.Type == TypeLink {
+					doc := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+					assert.Equal(t, "https://www.youtube.com/", doc.Lookup("url").StringValue())
+					assert.Equal(t, "youtube.com", doc.Lookup("normalizedUrl").StringValue())
+				}
+				if tc.newPostData.Poll != nil {
+					doc := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+					assert.Equal(t, "нет", doc.Lookup("poll", "options").Array().Index(1).Value().Document().Lookup("text").StringValue())
+					assert.Equal(t, bson.TypeArray, doc.Lookup("poll", "voters").Type)
+				}
 			}
 		})
 	}
@@ -657,7 +958,7 @@ func TestDeletePost(t *testing.T) {
 	var tests = []struct {
 		name         string
 		mockResponse []bson.D
-		wantErr      string
+		wantErr      error
 	}{
 		{
 			name: "Проверка на успешное удаление поста",
@@ -668,7 +969,7 @@ func TestDeletePost(t *testing.T) {
 					{Key: "n", Value: 1},
 				},
 			},
-			wantErr: "",
+			wantErr: nil,
 		},
 		{
 			name: "Проверка на обработку ошибки, что пост не удалился в бд",
@@ -677,10 +978,10 @@ func TestDeletePost(t *testing.T) {
 					{Key: "ok", Value: 0},
 				},
 			},
-			wantErr: `{"message": "bad request"}`,
+			wantErr: ErrStorage,
 		},
 		{
-			name: "Проверка на обработку ошибки DeletedCount == 0",
+			name: "Проверка на обработку ошибки MatchedCount == 0",
 			mockResponse: []bson.D{
 				{
 					{Key: "ok", Value: 1},
@@ -688,7 +989,7 @@ func TestDeletePost(t *testing.T) {
 					{Key: "n", Value: 0},
 				},
 			},
-			wantErr: `{"message": "failed to delete"}`,
+			wantErr: ErrPostNotFound,
 		},
 	}
 
@@ -697,13 +998,18 @@ func TestDeletePost(t *testing.T) {
 			repo := NewMongoRepo(mt.Coll)
 			mt.AddMockResponses(tc.mockResponse...)
 
-			result, err := repo.DeletePost(primitive.NewObjectID(), 1)
-			if tc.wantErr != "" {
+			result, err := repo.DeletePost(context.Background(), primitive.NewObjectID(), 1)
+			if tc.wantErr != nil {
 				assert.Error(t, err)
-				assert.EqualError(t, err, tc.wantErr)
+				assert.ErrorIs(t, err, tc.wantErr)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, true, result, "expected to be %v, got %v", true, result)
+
+				// Пост не стирается, а помечается удалённым.
+				update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+				assert.Equal(t, bson.TypeDateTime, update.Lookup("u", "$set", "deletedAt").Type)
+				assert.False(t, update.Lookup("q", "deletedAt", "$exists").Boolean())
 			}
 		})
 	}
@@ -743,13 +1049,19 @@ func TestMakeComment(t *testing.T) {
 		}},
 	}
 
+	notLocked := bson.E{Key: "locked", Value: bson.D{{Key: "$ne", Value: true}}}
+	notRemoved := bson.E{Key: "removed", Value: bson.D{{Key: "$ne", Value: true}}}
+	notDeleted := bson.E{Key: "deletedAt", Value: bson.D{{Key: "$exists", Value: false}}}
+
 	var tests = []struct {
 		name          string
+		parentID      primitive.ObjectID
 		userID        int64
 		userName      string
 		comment       string
 		mockResponse  []bson.D
-		wantErr       string
+		wantErr       error
+		wantFilter    bson.D
 		commentsCount int
 	}{
 		{
@@ -760,9 +1072,70 @@ func TestMakeComment(t *testing.T) {
 			mockResponse: []bson.D{
 				post,
 			},
-			wantErr:       "",
+			wantErr:       nil,
+			wantFilter:    bson.D{{Key: "_id", Value: postID}, notLocked, notRemoved, notDeleted},
 			commentsCount: 1,
 		},
+		{
+			name:     "Ответ на комментарий ищет родителя в том же посте",
+			parentID: commentID,
+			userID:   3,
+			userName: "ivan",
+			comment:  "some reply",
+			mockResponse: []bson.D{
+				post,
+			},
+			wantFilter: bson.D{
+				{Key: "_id", Value: postID}, notLocked, notRemoved, notDeleted,
+				{Key: "comments", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "_id", Value: commentID}, notDeleted}}}},
+			},
+			commentsCount: 1,
+		},
+		{
+			name:     "Ответ на несуществующий комментарий",
+			parentID: primitive.NewObjectID(),
+			userID:   3,
+			userName: "ivan",
+			comment:  "some reply",
+			mockResponse: []bson.D{
+				{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{{Key: "_id", Value: postID}}),
+			},
+			wantErr: ErrCommentNotFound,
+		},
+		{
+			name:     "Комментарий к закрытому посту",
+			userID:   3,
+			userName: "ivan",
+			comment:  "some comment",
+			mockResponse: []bson.D{
+				{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{{Key: "_id", Value: postID}, {Key: "locked", Value: true}}),
+			},
+			wantErr: ErrPostLocked,
+		},
+		{
+			name:     "Комментарий к снятому посту",
+			userID:   3,
+			userName: "ivan",
+			comment:  "some comment",
+			mockResponse: []bson.D{
+				{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{{Key: "_id", Value: postID}, {Key: "removed", Value: true}, {Key: "locked", Value: true}}),
+			},
+			wantErr: ErrPostNotFound,
+		},
+		{
+			name:     "Комментарий к несуществующему посту",
+			userID:   3,
+			userName: "ivan",
+			comment:  "some comment",
+			mockResponse: []bson.D{
+				{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch),
+			},
+			wantErr: ErrPostNotFound,
+		},
 		{
 			name:     "Проверка на обработку ошибок при запросе к бд",
 			userID:   3,
@@ -771,14 +1144,14 @@ func TestMakeComment(t *testing.T) {
 			mockResponse: []bson.D{
 				{{Key: "ok", Value: 0}},
 			},
-			wantErr: `{"message": "bad request"}`,
+			wantErr: ErrStorage,
 		},
 		{
 			name:     "Проверка на обработку сломанного bson",
 			userID:   3,
 			userName: "ivan",
 			comment:  "some comment",
-			wantErr:  `{"message": "bad request"}`,
+			wantErr:  ErrFailedConvert,
 			mockResponse: []bson.D{{
 				{Key: "ok", Value: 1},
 				{Key: "value", Value: bson.D{
@@ -795,14 +1168,20 @@ func TestMakeComment(t *testing.T) {
 			repo := NewMongoRepo(mt.Coll)
 			mt.AddMockResponses(tc.mockResponse...)
 
-			result, err := repo.MakeComment(postID, tc.comment, tc.userName, tc.userID)
-			if tc.wantErr != "" {
+			result, err := repo.MakeComment(context.Background(), postID, tc.parentID, tc.comment, tc.userName, tc.userID)
+			if tc.wantErr != nil {
 				assert.Error(t, err)
-				assert.EqualError(t, err, tc.wantErr)
+				assert.ErrorIs(t, err, tc.wantErr)
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, result)
 				assert.Equal(t, tc.commentsCount, len(result.Comments), "expected %d comment, got %d", tc.commentsCount, len(result.Comments))
+
+				var cmd struct {
+					Query bson.D `bson:"query"`
+				}
+				assert.NoError(t, bson.Unmarshal(mt.GetStartedEvent().Command, &cmd))
+				assert.ElementsMatch(t, tc.wantFilter, cmd.Query)
 			}
 		})
 	}
@@ -815,7 +1194,7 @@ func TestDeleteComment(t *testing.T) {
 		name          string
 		userID        int64
 		mockResponse  []bson.D
-		wantErr       string
+		wantErr       error
 		commentsCount int
 	}{
 		{
@@ -842,7 +1221,7 @@ func TestDeleteComment(t *testing.T) {
 					}},
 				},
 			},
-			wantErr:       "",
+			wantErr:       nil,
 			commentsCount: 0,
 		},
 		{
@@ -853,7 +1232,7 @@ func TestDeleteComment(t *testing.T) {
 					{Key: "ok", Value: 0},
 				},
 			},
-			wantErr: `{"message": "failed to update field"}`,
+			wantErr: ErrStorage,
 		},
 		{
 			name:   "Проверка на обработку сломанного bson",
@@ -867,7 +1246,7 @@ func TestDeleteComment(t *testing.T) {
 					}},
 				},
 			},
-			wantErr: `{"message": "bad request"}`,
+			wantErr: ErrFailedConvert,
 		},
 	}
 
@@ -876,10 +1255,10 @@ func TestDeleteComment(t *testing.T) {
 			repo := NewMongoRepo(mt.Coll)
 			mt.AddMockResponses(tc.mockResponse...)
 
-			result, err := repo.DeleteComment(primitive.NewObjectID(), primitive.NewObjectID(), tc.userID)
-			if tc.wantErr != "" {
+			result, err := repo.DeleteComment(context.Background(), primitive.NewObjectID(), primitive.NewObjectID(), tc.userID)
+			if tc.wantErr != nil {
 				assert.Error(t, err)
-				assert.EqualError(t, err, tc.wantErr)
+				assert.ErrorIs(t, err, tc.wantErr)
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, result)
@@ -888,3 +1267,158 @@ func TestDeleteComment(t *testing.T) {
 		})
 	}
 }
+
+func TestAddViews(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	var tests = []struct {
+		name         string
+		views        map[primitive.ObjectID]int
+		mockResponse []bson.D
+		wantErr      error
+	}{
+		{
+			name:  "Проверка на успешное добавление просмотров",
+			views: map[primitive.ObjectID]int{primitive.NewObjectID(): 3, primitive.NewObjectID(): 1},
+			mockResponse: []bson.D{{
+				{Key: "ok", Value: 1},
+				{Key: "n", Value: 2},
+				{Key: "nModified", Value: 2},
+			}},
+			wantErr: nil,
+		},
+		{
+			name:    "Проверка, что пустая пачка не идёт в базу",
+			views:   map[primitive.ObjectID]int{},
+			wantErr: nil,
+		},
+		{
+			name:         "Проверка на обработку ошибки при записи",
+			views:        map[primitive.ObjectID]int{primitive.NewObjectID(): 1},
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			err := repo.AddViews(context.Background(), tc.views)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStorageErrorLogged(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Ошибка монги пишется в лог запроса", func(mt *mtest.T) {
+		core, logs := observer.New(zap.ErrorLevel)
+		ctx := logging.NewContext(context.Background(), zap.New(core).Sugar().With("request_id", "req-1"))
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		repo := NewMongoRepo(mt.Coll)
+		err := repo.AddViews(ctx, map[primitive.ObjectID]int{primitive.NewObjectID(): 1})
+		assert.ErrorIs(t, err, ErrStorage)
+
+		entries := logs.AllUntimed()
+		if assert.Len(t, entries, 1) {
+			fields := entries[0].ContextMap()
+			assert.Equal(t, "posts.AddViews", fields["op"])
+			assert.Equal(t, "req-1", fields["request_id"])
+		}
+	})
+}
+
+// slowMongo принимает соединения, но никогда не отвечает - драйвер зависает до отмены контекста.
+func slowMongo(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cant listen: %s", err)
+	}
+	var (
+		mu    sync.Mutex
+		conns []net.Conn
+	)
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestContextCancellation(t *testing.T) {
+	addr := slowMongo(t)
+	client, err := mongo.Connect(context.Background(), options.Client().
+		ApplyURI("mongodb://"+addr+"/?connect=direct").
+		SetServerSelectionTimeout(time.Minute))
+	if err != nil {
+		t.Fatalf("cant connect: %s", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	repo := NewMongoRepo(client.Database("golang").Collection("posts"))
+	repo.ReadTimeout = 50 * time.Millisecond
+	repo.WriteTimeout = 50 * time.Millisecond
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{
+			name: "Таймаут на чтение из конфигурации",
+			call: func(ctx context.Context) error {
+				_, err := repo.GetPosts(ctx, FilterAll())
+				return err
+			},
+		},
+		{
+			name: "Таймаут на запись из конфигурации",
+			call: func(ctx context.Context) error {
+				_, err := repo.MakePost(ctx, &PostForm{Type: "text", Title: "t", Category: "music"}, "rvasily", 1)
+				return err
+			},
+		},
+		{
+			name: "Отмена контекста запроса (клиент отключился)",
+			call: func(ctx context.Context) error {
+				ctx, cancel := context.WithCancel(ctx)
+				time.AfterFunc(20*time.Millisecond, cancel)
+				_, err := repo.DeletePost(ctx, primitive.NewObjectID(), 1)
+				return err
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			err := tc.call(context.Background())
+			assert.Error(t, err)
+			assert.Less(t, time.Since(start), 5*time.Second, "operation was not cancelled")
+		})
+	}
+}
@@ -3,30 +3,60 @@ package posts
 import (
 	"context"
 	"errors"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"redditclone/internal/logging"
+	"redditclone/internal/markup"
 	"redditclone/internal/user"
 	"time"
 )
 
-const (
-	ErrBadRequest    = `{"message": "bad request"}`
-	ErrPostNotFound  = `{"message": "post not found"}`
-	ErrFailedUpdate  = `{"message": "failed to update field"}`
-	ErrFailedConvert = `{"message": "failed to convert values"}`
-	ErrFailedDelete  = `{"message": "failed to delete"}`
+var (
+	ErrPostNotFound    = errors.New("post not found")
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrBadPostType     = errors.New("unknown post type")
+	ErrNoImage         = errors.New("image post without image")
+	ErrFailedUpdate    = errors.New("failed to update field")
+	ErrFailedConvert   = errors.New("failed to convert values")
+	// ErrStorage оборачивает ошибки монги, исходная ошибка доступна через errors.Is/As.
+	ErrStorage = errors.New("storage error")
 )
 
+// storageError пишет ошибку монги в лог запроса и оборачивает её в ErrStorage.
+func storageError(ctx context.Context, op string, err error) error {
+	logging.FromContext(ctx, nil).Errorw("storage error", "op", op, "error", err)
+	return fmt.Errorf("%w: %w", ErrStorage, err)
+}
+
+// notFoundOr превращает mongo.ErrNoDocuments в notFound, остальные ошибки считает ошибками хранилища.
+func notFoundOr(ctx context.Context, op string, err, notFound error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound
+	}
+	return storageError(ctx, op, err)
+}
+
 type PostMongoRepository struct {
 	DB *mongo.Collection
+	// Таймауты на одну операцию чтения/записи в монгу. 0 - без таймаута, только контекст запроса.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
 }
 
 func NewMongoRepo(db *mongo.Collection) *PostMongoRepository {
 	return &PostMongoRepository{DB: db}
 }
 
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 // Функции фильтрации (попытался в оптимизацию кода)
 func FilterByCategory(category string) func(*Post) bool {
 	return func(p *Post) bool {
@@ -46,218 +76,310 @@ func FilterAll() func(*Post) bool {
 	}
 }
 
-func (repo *PostMongoRepository) GetPost(postID primitive.ObjectID) (*Post, error) {
-	var post *Post
+func FilterByIDs(ids []primitive.ObjectID) func(*Post) bool {
+	set := make(map[primitive.ObjectID]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return func(p *Post) bool {
+		return set[p.ID]
+	}
+}
 
-	err := repo.DB.FindOneAndUpdate(
-		context.Background(),
-		bson.D{
-			{Key: "_id", Value: postID},
-		},
-		bson.D{{Key: "$inc", Value: bson.M{"views": 1}}},
-		options.FindOneAndUpdate().SetReturnDocument(1),
-	).Decode(&post)
+func FilterByUsers(users []string) func(*Post) bool {
+	set := make(map[string]bool, len(users))
+	for _, u := range users {
+		set[u] = true
+	}
+	return func(p *Post) bool {
+		return set[p.Author.Username]
+	}
+}
+
+// Select оставляет в списке посты, прошедшие filter.
+func Select(list []*Post, filter func(*Post) bool) []*Post {
+	var selected []*Post
+	for _, p := range list {
+		if filter(p) {
+			selected = append(selected, p)
+		}
+	}
+	return selected
+}
+
+// ExcludeIDs дополняет фильтр: посты из exclude не попадают в выдачу.
+func ExcludeIDs(filter func(*Post) bool, exclude map[primitive.ObjectID]bool) func(*Post) bool {
+	if len(exclude) == 0 {
+		return filter
+	}
+	return func(p *Post) bool {
+		return !exclude[p.ID] && filter(p)
+	}
+}
+
+// GetPost только читает пост. Просмотры считает views.Recorder и сбрасывает их через AddViews.
+func (repo *PostMongoRepository) GetPost(ctx context.Context, postID primitive.ObjectID) (*Post, error) {
+	post, err := repo.findPost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err = repo.linkOriginals(ctx, []*Post{post}); err != nil {
+		return nil, err
+	}
+	return readable(post), nil
+}
+
+// findPost читает неудалённый пост вместе с удалёнными комментариями - для тех, кто пишет документ целиком.
+func (repo *PostMongoRepository) findPost(ctx context.Context, postID primitive.ObjectID) (*Post, error) {
+	var post *Post
 
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	err := repo.DB.FindOne(ctx, bson.M{"_id": postID, "deletedAt": notDeleted()}).Decode(&post)
 	if err != nil {
-		return nil, errors.New(ErrPostNotFound)
+		return nil, notFoundOr(ctx, "posts.findPost", err, ErrPostNotFound)
 	}
 
 	return post, nil
 }
 
-func (repo *PostMongoRepository) GetPosts(filter func(*Post) bool) ([]*Post, error) {
+// AddViews одной пачкой прибавляет накопленные просмотры к постам.
+func (repo *PostMongoRepository) AddViews(ctx context.Context, views map[primitive.ObjectID]int) error {
+	if len(views) == 0 {
+		return nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(views))
+	for postID, count := range views {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": postID}).
+			SetUpdate(bson.M{"$inc": bson.M{"views": count}}))
+	}
+
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return storageError(ctx, "posts.AddViews", err)
+	}
+
+	return nil
+}
+
+func (repo *PostMongoRepository) GetPosts(ctx context.Context, filter func(*Post) bool) ([]*Post, error) {
 	var posts, newPosts []*Post
 
-	c, err := repo.DB.Find(context.Background(), bson.M{})
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(ctx, bson.M{"deletedAt": notDeleted()})
 	if err != nil {
-		return nil, errors.New(ErrPostNotFound)
+		return nil, storageError(ctx, "posts.GetPosts", err)
 	}
 
-	err = c.All(context.Background(), &posts)
+	err = c.All(ctx, &posts)
 	if err != nil {
-		return nil, errors.New(ErrFailedConvert)
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
 	}
 
 	for _, v := range posts {
 		if filter(v) {
-			newPosts = append(newPosts, v)
+			newPosts = append(newPosts, readable(v))
 		}
 	}
+	if err = repo.linkOriginals(ctx, newPosts); err != nil {
+		return nil, err
+	}
 
 	return newPosts, nil
 }
 
-func (repo *PostMongoRepository) VotePost(postID primitive.ObjectID, user int64, voteVal int) (*Post, error) {
-	post, err := repo.GetPost(postID)
-	if err != nil {
-		return nil, errors.New(ErrPostNotFound)
-	}
-
-	index := -1
-	for k, v := range post.Votes {
-		if v.UserID == user {
-			if v.Vote == voteVal {
-				return post, nil
-			}
-			index = k
-			post.Score += voteVal - v.Vote
-			post.Votes[k].Vote = voteVal
-			post.UpvoteCount += voteVal
-			continue
-		}
+// scopeQuery - запрос неудалённых постов области.
+func scopeQuery(scope Scope) bson.M {
+	query := bson.M{"deletedAt": notDeleted()}
+	if scope.Category != "" {
+		query["category"] = scope.Category
+	}
+	if scope.Author != "" {
+		query["author.username"] = scope.Author
 	}
+	return query
+}
 
-	if index == -1 {
-		vote := Vote{
-			UserID: user,
-			Vote:   voteVal,
-		}
-		post.UpvoteCount += voteVal
-		post.Score += voteVal
-		post.VoteCount++
-		post.Votes = append(post.Votes, &vote)
+func (repo *PostMongoRepository) ListPosts(ctx context.Context, scope Scope) ([]*Post, error) {
+	return repo.findPosts(ctx, "posts.ListPosts", scopeQuery(scope))
+}
+
+func (repo *PostMongoRepository) GetPostsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*Post, error) {
+	if len(ids) == 0 {
+		return []*Post{}, nil
 	}
+	return repo.findPosts(ctx, "posts.GetPostsByIDs", bson.M{"_id": bson.M{"$in": ids}, "deletedAt": notDeleted()})
+}
 
-	if post.VoteCount > 0 {
-		post.UpvotePercentage = post.UpvoteCount * 100 / post.VoteCount
-	} else {
-		post.UpvotePercentage = 0
+func (repo *PostMongoRepository) GetPostsByAuthors(ctx context.Context, usernames []string) ([]*Post, error) {
+	if len(usernames) == 0 {
+		return []*Post{}, nil
 	}
+	return repo.findPosts(ctx, "posts.GetPostsByAuthors", bson.M{"author.username": bson.M{"$in": usernames}, "deletedAt": notDeleted()})
+}
 
-	// Создание фильтра по ID
-	filter := bson.M{"_id": post.ID}
-	// Замена существующего документа
-	result, err := repo.DB.ReplaceOne(context.Background(), filter, post)
+// findPosts читает посты по запросу и готовит их к показу.
+func (repo *PostMongoRepository) findPosts(ctx context.Context, op string, query bson.M) ([]*Post, error) {
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(ctx, query)
 	if err != nil {
-		return nil, errors.New(ErrBadRequest)
+		return nil, storageError(ctx, op, err)
 	}
-	if result.ModifiedCount == 0 {
-		return nil, errors.New(ErrFailedUpdate)
+	var posts []*Post
+	if err = c.All(ctx, &posts); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
 	}
 
-	return post, nil
+	for _, post := range posts {
+		readable(post)
+	}
+	if err = repo.linkOriginals(ctx, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
 }
 
-func (repo *PostMongoRepository) UnVotePost(postID primitive.ObjectID, user int64) (*Post, error) {
-	post, err := repo.GetPost(postID)
-	if err != nil {
-		return nil, errors.New(ErrPostNotFound)
-	}
-
-	index := -1
-	for k, v := range post.Votes {
-		if v.UserID == user {
-			if v.Vote == 1 {
-				post.UpvoteCount--
-				post.Score--
-			} else {
-				post.Score++
-			}
-			index = k
-			post.VoteCount--
-			post.Votes = append(post.Votes[:k], post.Votes[k+1:]...)
-		}
+// maxVoteAttempts - сколько раз пытаться записать голос в пост, голоса которого меняют параллельно.
+const maxVoteAttempts = 3
+
+// VotePost выставляет голос пользователя: 1, -1 или 0, чтобы снять голос.
+// Повторный такой же голос ничего не меняет и не пишет в базу.
+func (repo *PostMongoRepository) VotePost(ctx context.Context, postID primitive.ObjectID, user int64, voteVal int) (*Post, error) {
+	if !ValidVote(voteVal) {
+		return nil, ErrBadVote
 	}
-	if index == -1 {
-		return nil, errors.New(ErrFailedUpdate)
+
+	for attempt := 1; ; attempt++ {
+		post, err := repo.findPost(ctx, postID)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = repo.writeVote(ctx, post, user, voteVal)
+		if errors.Is(err, ErrFailedUpdate) && attempt < maxVoteAttempts {
+			// Голоса поменялись между чтением и записью - перечитываем пост.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return readable(post), nil
 	}
+}
 
-	if post.VoteCount > 0 {
-		post.UpvotePercentage = post.UpvoteCount * 100 / post.VoteCount
-	} else {
-		post.UpvotePercentage = 0
+// writeVote применяет голос к посту и пишет только голоса и счётчики: комментарии, просмотры, флаги
+// и опрос, изменённые параллельно, не затираются. Запись проходит, только если голоса поста не менялись
+// с чтения, иначе ErrFailedUpdate. false - голос уже такой, писать нечего.
+func (repo *PostMongoRepository) writeVote(ctx context.Context, post *Post, userID int64, voteVal int) (bool, error) {
+	// applyVote меняет голоса по указателю, поэтому для фильтра нужны копии, а не только срез.
+	before := make([]*Vote, 0, len(post.Votes))
+	for _, vote := range post.Votes {
+		read := *vote
+		before = append(before, &read)
+	}
+	if !applyVote(post, userID, voteVal) {
+		return false, nil
 	}
 
-	// Создание фильтра по ID
-	filter := bson.M{"_id": post.ID}
-	// Замена существующего документа
-	result, err := repo.DB.ReplaceOne(context.Background(), filter, post)
+	filter := bson.M{"_id": post.ID, "votes": before}
+	set := countersOf(post).fields()
+	set["votes"] = post.Votes
+	update := bson.M{"$set": set}
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result, err := repo.DB.UpdateOne(ctx, filter, update)
 	if err != nil {
-		return nil, errors.New(ErrFailedUpdate)
+		return false, storageError(ctx, "posts.writeVote", err)
 	}
-	if result.ModifiedCount == 0 {
-		return nil, errors.New(ErrFailedUpdate)
+	if result.MatchedCount == 0 {
+		return false, ErrFailedUpdate
 	}
-
-	return post, nil
+	return true, nil
 }
 
-func (repo *PostMongoRepository) MakePost(newPost *PostForm, username string, userID int64) (*Post, error) {
-	var post *Post
-
+func (repo *PostMongoRepository) MakePost(ctx context.Context, newPost *PostForm, username string, userID int64) (*Post, error) {
+	var poll *Poll
+	var normalizedURL string
 	switch newPost.Type {
-	case "text":
-		post = &Post{
-			ID:       primitive.NewObjectID(),
-			Title:    newPost.Title,
-			Category: newPost.Category,
-			Type:     newPost.Type,
-			Text:     newPost.Text,
-			Created:  time.Now().UTC().Format(time.RFC3339),
-			Author: &user.User{
-				ID:       userID,
-				Username: username,
-			},
-			Votes: []*Vote{
-				{
-					UserID: userID,
-					Vote:   1,
-				},
-			},
-			Score:            1,
-			UpvotePercentage: 100,
-			Comments:         []*Comment{},
+	case TypeText:
+	case TypeLink:
+		// Ссылки, которые не разобрать, публикуем как есть, просто без поиска повторов.
+		normalizedURL, _ = NormalizeURL(newPost.URL)
+	case TypeImage:
+		if newPost.Image == nil {
+			return nil, ErrNoImage
 		}
-	case "link":
-		post = &Post{
-			ID:       primitive.NewObjectID(),
-			Title:    newPost.Title,
-			Category: newPost.Category,
-			Type:     newPost.Type,
-			Text:     newPost.Text,
-			Created:  time.Now().UTC().Format(time.RFC3339),
-			Author: &user.User{
-				ID:       userID,
-				Username: username,
-			},
-			Votes: []*Vote{
-				{
-					UserID: userID,
-					Vote:   1,
-				},
-			},
-			Score:            1,
-			UpvotePercentage: 100,
-			Comments:         []*Comment{},
+	case TypePoll:
+		var err error
+		if poll, err = newPoll(newPost.Poll, time.Now().UTC()); err != nil {
+			return nil, err
 		}
 	default:
-		return nil, errors.New(ErrBadRequest)
+		return nil, ErrBadPostType
+	}
+
+	post := &Post{
+		ID:            primitive.NewObjectID(),
+		Title:         newPost.Title,
+		Category:      newPost.Category,
+		Type:          newPost.Type,
+		Text:          newPost.Text,
+		HTML:          markup.Render(newPost.Text),
+		URL:           newPost.URL,
+		NormalizedURL: normalizedURL,
+		Image:         newPost.Image,
+		Poll:          poll,
+		Created:       time.Now().UTC().Format(time.RFC3339),
+		Author: &user.User{
+			ID:       userID,
+			Username: username,
+		},
+		Votes: []*Vote{
+			{
+				UserID: userID,
+				Vote:   1,
+			},
+		},
+		Score:            1,
+		UpvotePercentage: 100,
+		Comments:         []*Comment{},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
 	defer cancel()
 	_, err := repo.DB.InsertOne(ctx, post)
 	if err != nil {
-		return nil, errors.New(ErrBadRequest)
+		return nil, storageError(ctx, "posts.MakePost", err)
 	}
 
 	return post, nil
 }
 
-func (repo *PostMongoRepository) DeletePost(postID primitive.ObjectID, userID int64) (bool, error) {
-	filter := bson.M{"_id": postID, "author.id": userID}
-	result, err := repo.DB.DeleteOne(context.Background(), filter)
+// DeletePost помечает пост удалённым. Окончательно его стирает Purger после срока хранения.
+func (repo *PostMongoRepository) DeletePost(ctx context.Context, postID primitive.ObjectID, userID int64) (bool, error) {
+	filter := bson.M{"_id": postID, "author.id": userID, "deletedAt": notDeleted()}
+	update := bson.M{"$set": bson.M{"deletedAt": time.Now().UTC()}}
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result, err := repo.DB.UpdateOne(ctx, filter, update)
 	if err != nil {
-		return false, errors.New(ErrBadRequest)
+		return false, storageError(ctx, "posts.DeletePost", err)
 	}
-	if result.DeletedCount == 0 {
-		return false, errors.New(ErrFailedDelete)
+	if result.MatchedCount == 0 {
+		// Пост не найден или принадлежит другому пользователю.
+		return false, ErrPostNotFound
 	}
 
 	return true, nil
 }
 
-func (repo *PostMongoRepository) MakeComment(postID primitive.ObjectID, comment, username string, userID int64) (*Post, error) {
+func (repo *PostMongoRepository) MakeComment(ctx context.Context, postID, parentID primitive.ObjectID, comment, username string, userID int64) (*Post, error) {
 	newComment := &Comment{
 		ID: primitive.NewObjectID(),
 		Author: &user.User{
@@ -265,40 +387,59 @@ func (repo *PostMongoRepository) MakeComment(postID primitive.ObjectID, comment,
 			Username: username,
 		},
 		Body:    comment,
+		HTML:    markup.Render(comment),
 		Created: time.Now().UTC().Format(time.RFC3339),
 	}
 
-	filter := bson.M{"_id": postID}
+	// Закрытость поста и родитель проверяются тем же запросом, что и запись.
+	filter := bson.M{"_id": postID, "locked": bson.M{"$ne": true}, "removed": bson.M{"$ne": true}, "deletedAt": notDeleted()}
+	if !parentID.IsZero() {
+		newComment.ParentID = &parentID
+		filter["comments"] = bson.M{"$elemMatch": bson.D{{Key: "_id", Value: parentID}, {Key: "deletedAt", Value: notDeleted()}}}
+	}
 	update := bson.M{"$push": bson.M{"comments": newComment}}
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	result := repo.DB.FindOneAndUpdate(context.Background(), filter, update, opts)
+	writeCtx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result := repo.DB.FindOneAndUpdate(writeCtx, filter, update, opts)
+	if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+		return nil, repo.commentRejection(ctx, postID, parentID)
+	}
 	if result.Err() != nil {
-		return nil, errors.New(ErrBadRequest)
+		return nil, storageError(ctx, "posts.MakeComment", result.Err())
 	}
 
 	var post Post
 	err := result.Decode(&post)
 	if err != nil {
-		return nil, errors.New(ErrBadRequest)
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
 	}
 
-	return &post, nil
+	return readable(&post), nil
 }
 
-func (repo *PostMongoRepository) DeleteComment(postID primitive.ObjectID, commentID primitive.ObjectID, userID int64) (*Post, error) {
-	filter := bson.M{"_id": postID, "comments": bson.M{"$elemMatch": bson.M{"_id": commentID, "author.id": userID}}}
-	update := bson.M{"$pull": bson.M{"comments": bson.M{"_id": commentID}}}
+// DeleteComment помечает комментарий удалённым. Ответы на него остаются на месте.
+func (repo *PostMongoRepository) DeleteComment(ctx context.Context, postID primitive.ObjectID, commentID primitive.ObjectID, userID int64) (*Post, error) {
+	filter := bson.M{
+		"_id":       postID,
+		"deletedAt": notDeleted(),
+		"comments":  bson.M{"$elemMatch": bson.M{"_id": commentID, "author.id": userID, "deletedAt": notDeleted()}},
+	}
+	update := bson.M{"$set": bson.M{"comments.$.deletedAt": time.Now().UTC()}}
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	result := repo.DB.FindOneAndUpdate(context.Background(), filter, update, opts)
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result := repo.DB.FindOneAndUpdate(ctx, filter, update, opts)
 	if result.Err() != nil {
-		return nil, errors.New(ErrFailedUpdate)
+		// Комментарий не найден или принадлежит другому пользователю.
+		return nil, notFoundOr(ctx, "posts.DeleteComment", result.Err(), ErrCommentNotFound)
 	}
 
 	var post Post
 	err := result.Decode(&post)
 	if err != nil {
-		return nil, errors.New(ErrBadRequest)
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
 	}
 
-	return &post, nil
+	return readable(&post), nil
 }
@@ -5,7 +5,9 @@
 package posts
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	primitive "go.mongodb.org/mongo-driver/bson/primitive"
@@ -34,122 +36,331 @@ func (m *MockPostRepo) EXPECT() *MockPostRepoMockRecorder {
 	return m.recorder
 }
 
+// AddViews mocks base method.
+func (m *MockPostRepo) AddViews(ctx context.Context, views map[primitive.ObjectID]int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddViews", ctx, views)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddViews indicates an expected call of AddViews.
+func (mr *MockPostRepoMockRecorder) AddViews(ctx, views interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddViews", reflect.TypeOf((*MockPostRepo)(nil).AddViews), ctx, views)
+}
+
+// AnonymizeUser mocks base method.
+func (m *MockPostRepo) AnonymizeUser(ctx context.Context, userID int64) (AnonymizeResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeUser", ctx, userID)
+	ret0, _ := ret[0].(AnonymizeResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AnonymizeUser indicates an expected call of AnonymizeUser.
+func (mr *MockPostRepoMockRecorder) AnonymizeUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeUser", reflect.TypeOf((*MockPostRepo)(nil).AnonymizeUser), ctx, userID)
+}
+
+// Crosspost mocks base method.
+func (m *MockPostRepo) Crosspost(ctx context.Context, originalID primitive.ObjectID, form *CrosspostForm, username string, userID int64) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Crosspost", ctx, originalID, form, username, userID)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Crosspost indicates an expected call of Crosspost.
+func (mr *MockPostRepoMockRecorder) Crosspost(ctx, originalID, form, username, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Crosspost", reflect.TypeOf((*MockPostRepo)(nil).Crosspost), ctx, originalID, form, username, userID)
+}
+
 // DeleteComment mocks base method.
-func (m *MockPostRepo) DeleteComment(postID, commentID primitive.ObjectID, userID int64) (*Post, error) {
+func (m *MockPostRepo) DeleteComment(ctx context.Context, postID, commentID primitive.ObjectID, userID int64) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteComment", postID, commentID, userID)
+	ret := m.ctrl.Call(m, "DeleteComment", ctx, postID, commentID, userID)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DeleteComment indicates an expected call of DeleteComment.
-func (mr *MockPostRepoMockRecorder) DeleteComment(postID, commentID, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) DeleteComment(ctx, postID, commentID, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockPostRepo)(nil).DeleteComment), postID, commentID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockPostRepo)(nil).DeleteComment), ctx, postID, commentID, userID)
 }
 
 // DeletePost mocks base method.
-func (m *MockPostRepo) DeletePost(postID primitive.ObjectID, userID int64) (bool, error) {
+func (m *MockPostRepo) DeletePost(ctx context.Context, postID primitive.ObjectID, userID int64) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeletePost", postID, userID)
+	ret := m.ctrl.Call(m, "DeletePost", ctx, postID, userID)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DeletePost indicates an expected call of DeletePost.
-func (mr *MockPostRepoMockRecorder) DeletePost(postID, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) DeletePost(ctx, postID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePost", reflect.TypeOf((*MockPostRepo)(nil).DeletePost), ctx, postID, userID)
+}
+
+// FindByURL mocks base method.
+func (m *MockPostRepo) FindByURL(ctx context.Context, normalizedURL string, since time.Time, limit int) ([]*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByURL", ctx, normalizedURL, since, limit)
+	ret0, _ := ret[0].([]*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByURL indicates an expected call of FindByURL.
+func (mr *MockPostRepoMockRecorder) FindByURL(ctx, normalizedURL, since, limit interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePost", reflect.TypeOf((*MockPostRepo)(nil).DeletePost), postID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByURL", reflect.TypeOf((*MockPostRepo)(nil).FindByURL), ctx, normalizedURL, since, limit)
+}
+
+// GetFeed mocks base method.
+func (m *MockPostRepo) GetFeed(ctx context.Context, q FeedQuery) (*FeedPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFeed", ctx, q)
+	ret0, _ := ret[0].(*FeedPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFeed indicates an expected call of GetFeed.
+func (mr *MockPostRepoMockRecorder) GetFeed(ctx, q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeed", reflect.TypeOf((*MockPostRepo)(nil).GetFeed), ctx, q)
 }
 
 // GetPost mocks base method.
-func (m *MockPostRepo) GetPost(postID primitive.ObjectID) (*Post, error) {
+func (m *MockPostRepo) GetPost(ctx context.Context, postID primitive.ObjectID) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPost", postID)
+	ret := m.ctrl.Call(m, "GetPost", ctx, postID)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPost indicates an expected call of GetPost.
-func (mr *MockPostRepoMockRecorder) GetPost(postID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) GetPost(ctx, postID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPost", reflect.TypeOf((*MockPostRepo)(nil).GetPost), postID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPost", reflect.TypeOf((*MockPostRepo)(nil).GetPost), ctx, postID)
 }
 
 // GetPosts mocks base method.
-func (m *MockPostRepo) GetPosts(filter func(*Post) bool) ([]*Post, error) {
+func (m *MockPostRepo) GetPosts(ctx context.Context, filter func(*Post) bool) ([]*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPosts", filter)
+	ret := m.ctrl.Call(m, "GetPosts", ctx, filter)
 	ret0, _ := ret[0].([]*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPosts indicates an expected call of GetPosts.
-func (mr *MockPostRepoMockRecorder) GetPosts(filter interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) GetPosts(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPosts", reflect.TypeOf((*MockPostRepo)(nil).GetPosts), ctx, filter)
+}
+
+// GetPostsByAuthors mocks base method.
+func (m *MockPostRepo) GetPostsByAuthors(ctx context.Context, usernames []string) ([]*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPostsByAuthors", ctx, usernames)
+	ret0, _ := ret[0].([]*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPostsByAuthors indicates an expected call of GetPostsByAuthors.
+func (mr *MockPostRepoMockRecorder) GetPostsByAuthors(ctx, usernames interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByAuthors", reflect.TypeOf((*MockPostRepo)(nil).GetPostsByAuthors), ctx, usernames)
+}
+
+// GetPostsByIDs mocks base method.
+func (m *MockPostRepo) GetPostsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPostsByIDs", ctx, ids)
+	ret0, _ := ret[0].([]*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPostsByIDs indicates an expected call of GetPostsByIDs.
+func (mr *MockPostRepoMockRecorder) GetPostsByIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByIDs", reflect.TypeOf((*MockPostRepo)(nil).GetPostsByIDs), ctx, ids)
+}
+
+// ListPosts mocks base method.
+func (m *MockPostRepo) ListPosts(ctx context.Context, scope Scope) ([]*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPosts", ctx, scope)
+	ret0, _ := ret[0].([]*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPosts indicates an expected call of ListPosts.
+func (mr *MockPostRepoMockRecorder) ListPosts(ctx, scope interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPosts", reflect.TypeOf((*MockPostRepo)(nil).GetPosts), filter)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPosts", reflect.TypeOf((*MockPostRepo)(nil).ListPosts), ctx, scope)
 }
 
 // MakeComment mocks base method.
-func (m *MockPostRepo) MakeComment(postID primitive.ObjectID, comment, username string, userID int64) (*Post, error) {
+func (m *MockPostRepo) MakeComment(ctx context.Context, postID, parentID primitive.ObjectID, comment, username string, userID int64) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MakeComment", postID, comment, username, userID)
+	ret := m.ctrl.Call(m, "MakeComment", ctx, postID, parentID, comment, username, userID)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // MakeComment indicates an expected call of MakeComment.
-func (mr *MockPostRepoMockRecorder) MakeComment(postID, comment, username, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) MakeComment(ctx, postID, parentID, comment, username, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeComment", reflect.TypeOf((*MockPostRepo)(nil).MakeComment), postID, comment, username, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeComment", reflect.TypeOf((*MockPostRepo)(nil).MakeComment), ctx, postID, parentID, comment, username, userID)
 }
 
 // MakePost mocks base method.
-func (m *MockPostRepo) MakePost(newPost *PostForm, username string, userID int64) (*Post, error) {
+func (m *MockPostRepo) MakePost(ctx context.Context, newPost *PostForm, username string, userID int64) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MakePost", newPost, username, userID)
+	ret := m.ctrl.Call(m, "MakePost", ctx, newPost, username, userID)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // MakePost indicates an expected call of MakePost.
-func (mr *MockPostRepoMockRecorder) MakePost(newPost, username, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) MakePost(ctx, newPost, username, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakePost", reflect.TypeOf((*MockPostRepo)(nil).MakePost), ctx, newPost, username, userID)
+}
+
+// Purge mocks base method.
+func (m *MockPostRepo) Purge(ctx context.Context, before time.Time) (PurgeResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", ctx, before)
+	ret0, _ := ret[0].(PurgeResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockPostRepoMockRecorder) Purge(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockPostRepo)(nil).Purge), ctx, before)
+}
+
+// RestoreComment mocks base method.
+func (m *MockPostRepo) RestoreComment(ctx context.Context, postID, commentID primitive.ObjectID, userID int64) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreComment", ctx, postID, commentID, userID)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreComment indicates an expected call of RestoreComment.
+func (mr *MockPostRepoMockRecorder) RestoreComment(ctx, postID, commentID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreComment", reflect.TypeOf((*MockPostRepo)(nil).RestoreComment), ctx, postID, commentID, userID)
+}
+
+// RestorePost mocks base method.
+func (m *MockPostRepo) RestorePost(ctx context.Context, postID primitive.ObjectID, userID int64) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestorePost", ctx, postID, userID)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestorePost indicates an expected call of RestorePost.
+func (mr *MockPostRepoMockRecorder) RestorePost(ctx, postID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestorePost", reflect.TypeOf((*MockPostRepo)(nil).RestorePost), ctx, postID, userID)
+}
+
+// SetCommentRemoved mocks base method.
+func (m *MockPostRepo) SetCommentRemoved(ctx context.Context, postID, commentID primitive.ObjectID, removed bool) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCommentRemoved", ctx, postID, commentID, removed)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetCommentRemoved indicates an expected call of SetCommentRemoved.
+func (mr *MockPostRepoMockRecorder) SetCommentRemoved(ctx, postID, commentID, removed interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCommentRemoved", reflect.TypeOf((*MockPostRepo)(nil).SetCommentRemoved), ctx, postID, commentID, removed)
+}
+
+// SetPostFlags mocks base method.
+func (m *MockPostRepo) SetPostFlags(ctx context.Context, postID primitive.ObjectID, flags PostFlags) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPostFlags", ctx, postID, flags)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetPostFlags indicates an expected call of SetPostFlags.
+func (mr *MockPostRepoMockRecorder) SetPostFlags(ctx, postID, flags interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPostFlags", reflect.TypeOf((*MockPostRepo)(nil).SetPostFlags), ctx, postID, flags)
+}
+
+// UserContent mocks base method.
+func (m *MockPostRepo) UserContent(ctx context.Context, userID int64) ([]*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserContent", ctx, userID)
+	ret0, _ := ret[0].([]*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserContent indicates an expected call of UserContent.
+func (mr *MockPostRepoMockRecorder) UserContent(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakePost", reflect.TypeOf((*MockPostRepo)(nil).MakePost), newPost, username, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserContent", reflect.TypeOf((*MockPostRepo)(nil).UserContent), ctx, userID)
 }
 
-// UnVotePost mocks base method.
-func (m *MockPostRepo) UnVotePost(postID primitive.ObjectID, user int64) (*Post, error) {
+// VotePoll mocks base method.
+func (m *MockPostRepo) VotePoll(ctx context.Context, postID primitive.ObjectID, userID int64, option int) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UnVotePost", postID, user)
+	ret := m.ctrl.Call(m, "VotePoll", ctx, postID, userID, option)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UnVotePost indicates an expected call of UnVotePost.
-func (mr *MockPostRepoMockRecorder) UnVotePost(postID, user interface{}) *gomock.Call {
+// VotePoll indicates an expected call of VotePoll.
+func (mr *MockPostRepoMockRecorder) VotePoll(ctx, postID, userID, option interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnVotePost", reflect.TypeOf((*MockPostRepo)(nil).UnVotePost), postID, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VotePoll", reflect.TypeOf((*MockPostRepo)(nil).VotePoll), ctx, postID, userID, option)
 }
 
 // VotePost mocks base method.
-func (m *MockPostRepo) VotePost(postID primitive.ObjectID, user int64, voteVal int) (*Post, error) {
+func (m *MockPostRepo) VotePost(ctx context.Context, postID primitive.ObjectID, user int64, voteVal int) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "VotePost", postID, user, voteVal)
+	ret := m.ctrl.Call(m, "VotePost", ctx, postID, user, voteVal)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // VotePost indicates an expected call of VotePost.
-func (mr *MockPostRepoMockRecorder) VotePost(postID, user, voteVal interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) VotePost(ctx, postID, user, voteVal interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VotePost", reflect.TypeOf((*MockPostRepo)(nil).VotePost), postID, user, voteVal)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VotePost", reflect.TypeOf((*MockPostRepo)(nil).VotePost), ctx, postID, user, voteVal)
 }
@@ -0,0 +1,68 @@
+package posts
+
+import "errors"
+
+var ErrBadVote = errors.New("vote must be -1, 0 or 1")
+
+// ValidVote проверяет, что значение голоса допустимо: -1, 0 (снять голос) или 1.
+func ValidVote(vote int) bool {
+	return vote >= -1 && vote <= 1
+}
+
+// applyVote выставляет голос пользователя и пересчитывает счётчики поста.
+// Возвращает false, если пост не изменился: голос уже был таким, а счётчики и так сходились.
+func applyVote(post *Post, userID int64, vote int) bool {
+	changed := setVote(post, userID, vote)
+
+	before := [4]int{post.Score, post.UpvoteCount, post.VoteCount, post.UpvotePercentage}
+	recount(post)
+	after := [4]int{post.Score, post.UpvoteCount, post.VoteCount, post.UpvotePercentage}
+
+	return changed || before != after
+}
+
+// setVote меняет только список голосов, без счётчиков.
+func setVote(post *Post, userID int64, vote int) bool {
+	index := -1
+	for k, v := range post.Votes {
+		if v.UserID == userID {
+			index = k
+			break
+		}
+	}
+
+	switch {
+	case index == -1 && vote == 0:
+		return false
+	case index == -1:
+		post.Votes = append(post.Votes, &Vote{UserID: userID, Vote: vote})
+	case post.Votes[index].Vote == vote:
+		return false
+	case vote == 0:
+		post.Votes = append(post.Votes[:index], post.Votes[index+1:]...)
+	default:
+		post.Votes[index].Vote = vote
+	}
+
+	return true
+}
+
+// recount выводит все счётчики голосов из post.Votes - единственного источника правды.
+func recount(post *Post) {
+	post.Score = 0
+	post.UpvoteCount = 0
+	post.VoteCount = len(post.Votes)
+
+	for _, v := range post.Votes {
+		post.Score += v.Vote
+		if v.Vote > 0 {
+			post.UpvoteCount++
+		}
+	}
+
+	if post.VoteCount > 0 {
+		post.UpvotePercentage = post.UpvoteCount * 100 / post.VoteCount
+	} else {
+		post.UpvotePercentage = 0
+	}
+}
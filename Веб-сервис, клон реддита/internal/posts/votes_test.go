@@ -0,0 +1,118 @@
+package posts
+
+import (
+	"testing"
+	"testing/quick"
+)
+
+// voteOp - один случайный голос. Значения сужаются до 5 пользователей и голосов -1, 0, 1,
+// чтобы в последовательностях чаще встречались повторы и смена голоса.
+type voteOp struct {
+	User uint8
+	Vote int8
+}
+
+func (op voteOp) userID() int64 {
+	return int64(op.User % 5)
+}
+
+func (op voteOp) vote() int {
+	return int(op.Vote % 2)
+}
+
+// checkCounters сверяет счётчики поста с ожидаемыми голосами пользователей.
+func checkCounters(t *testing.T, post *Post, want map[int64]int) bool {
+	seen := make(map[int64]bool, len(post.Votes))
+	score, up := 0, 0
+	for _, v := range post.Votes {
+		if seen[v.UserID] {
+			t.Logf("duplicate vote of user %d", v.UserID)
+			return false
+		}
+		seen[v.UserID] = true
+
+		if v.Vote == 0 || want[v.UserID] != v.Vote {
+			t.Logf("user %d: vote %d, want %d", v.UserID, v.Vote, want[v.UserID])
+			return false
+		}
+		score += v.Vote
+		if v.Vote > 0 {
+			up++
+		}
+	}
+
+	percent := 0
+	if len(want) > 0 {
+		percent = up * 100 / len(want)
+	}
+
+	ok := len(post.Votes) == len(want) &&
+		post.VoteCount == len(want) &&
+		post.Score == score &&
+		post.UpvoteCount == up &&
+		post.UpvotePercentage == percent &&
+		post.UpvoteCount <= post.VoteCount &&
+		post.Score >= -post.VoteCount && post.Score <= post.VoteCount
+	if !ok {
+		t.Logf("counters mismatch: %+v, votes %v", post, want)
+	}
+	return ok
+}
+
+func TestApplyVoteInvariants(t *testing.T) {
+	property := func(ops []voteOp) bool {
+		post := &Post{}
+		want := map[int64]int{}
+
+		for _, op := range ops {
+			user, vote := op.userID(), op.vote()
+
+			prev, voted := want[user]
+			expectChanged := (voted || vote != 0) && prev != vote
+			if vote == 0 {
+				delete(want, user)
+			} else {
+				want[user] = vote
+			}
+
+			if changed := applyVote(post, user, vote); changed != expectChanged {
+				t.Logf("user %d vote %d: changed=%v, want %v", user, vote, changed, expectChanged)
+				return false
+			}
+			if !checkCounters(t, post, want) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecountFixesCorruptedCounters(t *testing.T) {
+	property := func(ops []voteOp, score, up, count, percent int) bool {
+		post := &Post{}
+		want := map[int64]int{}
+		for _, op := range ops {
+			user, vote := op.userID(), op.vote()
+			applyVote(post, user, vote)
+			if vote == 0 {
+				delete(want, user)
+			} else {
+				want[user] = vote
+			}
+		}
+
+		// Портим счётчики, как это могло случиться со старыми документами в базе.
+		post.Score, post.UpvoteCount, post.VoteCount, post.UpvotePercentage = score, up, count, percent
+		recount(post)
+
+		return checkCounters(t, post, want)
+	}
+
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Маршруты, для которых настраиваются бюджеты.
+const (
+	RoutePost    = "post"
+	RouteComment = "comment"
+	RouteVote    = "vote"
+	RouteReport  = "report"
+)
+
+// Budget - лимиты одного маршрута, отдельно на пользователя и на IP.
+type Budget struct {
+	User Limit
+	IP   Limit
+}
+
+type Limiter struct {
+	Store   Store
+	Budgets map[string]Budget
+}
+
+// Allow берёт токен из корзины пользователя и из корзины IP.
+// Возвращается более строгий из двух результатов.
+func (l *Limiter) Allow(ctx context.Context, route string, userID int64, ip string) (Result, error) {
+	budget, ok := l.Budgets[route]
+	if !ok {
+		return Result{Allowed: true}, nil
+	}
+
+	res := Result{Allowed: true}
+	checks := []struct {
+		key   string
+		limit Limit
+	}{
+		{key: route + ":user:" + strconv.FormatInt(userID, 10), limit: budget.User},
+		{key: route + ":ip:" + ip, limit: budget.IP},
+	}
+	for _, c := range checks {
+		if !c.limit.Enabled() {
+			continue
+		}
+		r, err := l.Store.Take(ctx, c.key, c.limit)
+		if err != nil {
+			return Result{}, err
+		}
+		res = stricter(res, r)
+	}
+
+	return res, nil
+}
+
+func stricter(a, b Result) Result {
+	switch {
+	case a.Limit == 0:
+		return b
+	case a.Allowed != b.Allowed:
+		if !a.Allowed {
+			return a
+		}
+		return b
+	case !a.Allowed && b.RetryAfter > a.RetryAfter:
+		return b
+	case a.Allowed && b.Remaining < a.Remaining:
+		return b
+	default:
+		return a
+	}
+}
+
+// SetHeaders выставляет заголовки X-RateLimit-* и Retry-After.
+func SetHeaders(w http.ResponseWriter, res Result) {
+	if res.Limit == 0 {
+		return
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	if !res.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// ClientIP возвращает IP клиента. X-Forwarded-For учитывается только за доверенным прокси.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Counter считает события в фиксированном окне. Реализации: MemoryCounter и RedisCounter.
+type Counter interface {
+	Incr(ctx context.Context, key string, window time.Duration) (int, error)
+}
+
+// DuplicateDetector отсекает один и тот же текст, отправленный пользователем много раз подряд.
+type DuplicateDetector struct {
+	Counter    Counter
+	MaxRepeats int
+	Window     time.Duration
+}
+
+// IsDuplicate учитывает очередную отправку текста и сообщает, превышен ли лимит повторов.
+func (d *DuplicateDetector) IsDuplicate(ctx context.Context, userID int64, body string) (bool, error) {
+	if d.MaxRepeats <= 0 || d.Window <= 0 {
+		return false, nil
+	}
+
+	sum := sha256.Sum256([]byte(normalize(body)))
+	key := strconv.FormatInt(userID, 10) + ":" + hex.EncodeToString(sum[:])
+
+	count, err := d.Counter.Incr(ctx, key, d.Window)
+	if err != nil {
+		return false, err
+	}
+	return count > d.MaxRepeats, nil
+}
+
+// normalize приводит текст к виду, в котором мелкие правки (регистр, пробелы) не обходят проверку.
+func normalize(body string) string {
+	return strings.Join(strings.Fields(strings.ToLower(body)), " ")
+}
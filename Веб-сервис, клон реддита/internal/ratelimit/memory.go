@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery - раз в сколько операций чистить протухшие ключи.
+const sweepEvery = 1024
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// MemoryStore - token bucket в памяти процесса. Подходит для одного инстанса и тестов.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	ops     int
+	// Now можно подменить в тестах.
+	Now func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		Now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Count), last: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = refill(limit, b.tokens, now.Sub(b.last))
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	res := makeResult(limit, b.tokens, allowed)
+	b.full = now.Add(res.Reset)
+
+	return res, nil
+}
+
+// sweep удаляет корзины, которые уже наполнились - они ничем не отличаются от новых.
+func (s *MemoryStore) sweep(now time.Time) {
+	s.ops++
+	if s.ops%sweepEvery != 0 {
+		return
+	}
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+type counter struct {
+	count   int
+	expires time.Time
+}
+
+// MemoryCounter - счётчик с фиксированным окном в памяти процесса.
+type MemoryCounter struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+	ops      int
+	Now      func() time.Time
+}
+
+func NewMemoryCounter() *MemoryCounter {
+	return &MemoryCounter{
+		counters: map[string]*counter{},
+		Now:      time.Now,
+	}
+}
+
+func (c *MemoryCounter) Incr(_ context.Context, key string, window time.Duration) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.Now()
+	c.ops++
+	if c.ops%sweepEvery == 0 {
+		for k, v := range c.counters {
+			if !now.Before(v.expires) {
+				delete(c.counters, k)
+			}
+		}
+	}
+
+	cnt, ok := c.counters[key]
+	if !ok || !now.Before(cnt.expires) {
+		cnt = &counter{expires: now.Add(window)}
+		c.counters[key] = cnt
+	}
+	cnt.count++
+
+	return cnt.count, nil
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit - бюджет token bucket: не больше Count запросов за Period, всплеск до Count.
+type Limit struct {
+	Count  int
+	Period time.Duration
+}
+
+// ParseLimit разбирает бюджет вида "10/1m". Пустая строка или "0" означают отсутствие лимита.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+
+	count, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("bad rate limit %q: want <count>/<period>", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		return Limit{}, fmt.Errorf("bad rate limit count %q", count)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("bad rate limit period %q", period)
+	}
+
+	return Limit{Count: n, Period: d}, nil
+}
+
+// Enabled сообщает, задан ли лимит.
+func (l Limit) Enabled() bool {
+	return l.Count > 0 && l.Period > 0
+}
+
+// perNanosecond - скорость пополнения корзины в токенах за наносекунду.
+func (l Limit) perNanosecond() float64 {
+	return float64(l.Count) / float64(l.Period)
+}
+
+// Result - итог попытки взять токен.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	// Reset - время, за которое корзина наполнится полностью.
+	Reset time.Duration
+}
+
+// Store хранит состояние корзин. Реализации: MemoryStore и RedisStore.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// refill пересчитывает количество токенов с учётом прошедшего времени.
+func refill(limit Limit, tokens float64, elapsed time.Duration) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(limit.Count), tokens+float64(elapsed)*limit.perNanosecond())
+}
+
+// makeResult считает заголовочные значения по оставшимся токенам.
+func makeResult(limit Limit, tokens float64, allowed bool) Result {
+	rate := limit.perNanosecond()
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Count,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration(math.Ceil((float64(limit.Count) - tokens) / rate)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration(math.Ceil((1 - tokens) / rate))
+	}
+	return res
+}
@@ -0,0 +1,209 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newRedisPool(t *testing.T) (*redis.Pool, *miniredis.Miniredis) {
+	srv := miniredis.RunT(t)
+	pool := &redis.Pool{
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			return redis.DialContext(ctx, "tcp", srv.Addr())
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool, srv
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "10/1m", want: Limit{Count: 10, Period: time.Minute}},
+		{in: " 3/500ms ", want: Limit{Count: 3, Period: 500 * time.Millisecond}},
+		{in: "", want: Limit{}},
+		{in: "0", want: Limit{}},
+		{in: "10", wantErr: true},
+		{in: "x/1m", wantErr: true},
+		{in: "10/forever", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseLimit(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestStores(t *testing.T) {
+	limit := Limit{Count: 3, Period: 3 * time.Second}
+
+	stores := map[string]func(t *testing.T, clock *fakeClock) Store{
+		"memory": func(t *testing.T, clock *fakeClock) Store {
+			s := NewMemoryStore()
+			s.Now = clock.Now
+			return s
+		},
+		"redis": func(t *testing.T, clock *fakeClock) Store {
+			pool, _ := newRedisPool(t)
+			s := NewRedisStore(pool)
+			s.Now = clock.Now
+			return s
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(1700000000, 0)}
+			store := newStore(t, clock)
+			ctx := context.Background()
+
+			for i := 2; i >= 0; i-- {
+				res, err := store.Take(ctx, "k", limit)
+				assert.NoError(t, err)
+				assert.True(t, res.Allowed, "request within burst must pass")
+				assert.Equal(t, i, res.Remaining)
+			}
+
+			res, err := store.Take(ctx, "k", limit)
+			assert.NoError(t, err)
+			assert.False(t, res.Allowed, "burst is exhausted")
+			assert.Equal(t, time.Second, res.RetryAfter)
+
+			other, err := store.Take(ctx, "other", limit)
+			assert.NoError(t, err)
+			assert.True(t, other.Allowed, "keys must not share buckets")
+
+			clock.Advance(time.Second)
+			res, err = store.Take(ctx, "k", limit)
+			assert.NoError(t, err)
+			assert.True(t, res.Allowed, "one token is refilled after a second")
+			assert.Equal(t, 0, res.Remaining)
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	store := NewMemoryStore()
+	store.Now = clock.Now
+
+	limiter := &Limiter{
+		Store: store,
+		Budgets: map[string]Budget{
+			RouteComment: {
+				User: Limit{Count: 2, Period: time.Minute},
+				IP:   Limit{Count: 3, Period: time.Minute},
+			},
+		},
+	}
+	ctx := context.Background()
+
+	res, err := limiter.Allow(ctx, RouteComment, 1, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Limit, "the stricter user budget is reported")
+	assert.Equal(t, 1, res.Remaining)
+
+	_, _ = limiter.Allow(ctx, RouteComment, 1, "10.0.0.1")
+	res, _ = limiter.Allow(ctx, RouteComment, 1, "10.0.0.1")
+	assert.False(t, res.Allowed, "user budget is exhausted")
+
+	// Другой пользователь с того же IP упирается уже в бюджет IP.
+	res, _ = limiter.Allow(ctx, RouteComment, 2, "10.0.0.1")
+	assert.False(t, res.Allowed, "ip budget is exhausted")
+
+	res, _ = limiter.Allow(ctx, RouteVote, 1, "10.0.0.1")
+	assert.True(t, res.Allowed, "routes without budget are not limited")
+
+	w := httptest.NewRecorder()
+	SetHeaders(w, Result{Allowed: false, Limit: 2, Remaining: 0, RetryAfter: 1500 * time.Millisecond, Reset: time.Minute})
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("X-RateLimit-Reset"))
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
+
+func TestDuplicateDetector(t *testing.T) {
+	counters := map[string]func(t *testing.T) (Counter, func(time.Duration)){
+		"memory": func(t *testing.T) (Counter, func(time.Duration)) {
+			clock := &fakeClock{now: time.Unix(1700000000, 0)}
+			c := NewMemoryCounter()
+			c.Now = clock.Now
+			return c, clock.Advance
+		},
+		"redis": func(t *testing.T) (Counter, func(time.Duration)) {
+			pool, srv := newRedisPool(t)
+			return NewRedisCounter(pool), srv.FastForward
+		},
+	}
+
+	for name, newCounter := range counters {
+		t.Run(name, func(t *testing.T) {
+			counter, advance := newCounter(t)
+			d := &DuplicateDetector{Counter: counter, MaxRepeats: 2, Window: time.Minute}
+			ctx := context.Background()
+
+			dup, err := d.IsDuplicate(ctx, 1, "Buy cheap watches")
+			assert.NoError(t, err)
+			assert.False(t, dup)
+
+			dup, _ = d.IsDuplicate(ctx, 1, "buy   cheap watches ")
+			assert.False(t, dup)
+
+			dup, _ = d.IsDuplicate(ctx, 1, "BUY CHEAP WATCHES")
+			assert.True(t, dup, "normalised text is repeated too often")
+
+			dup, _ = d.IsDuplicate(ctx, 2, "Buy cheap watches")
+			assert.False(t, dup, "other users are counted separately")
+
+			advance(time.Minute)
+			dup, _ = d.IsDuplicate(ctx, 1, "Buy cheap watches")
+			assert.False(t, dup, "window has passed")
+		})
+	}
+}
+
+func TestRedisCounterWindow(t *testing.T) {
+	pool, srv := newRedisPool(t)
+	c := NewRedisCounter(pool)
+	ctx := context.Background()
+
+	count, err := c.Incr(ctx, "k", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, time.Minute, srv.TTL("dupes:k"), "window is set together with the first increment")
+
+	srv.FastForward(20 * time.Second)
+	count, err = c.Incr(ctx, "k", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 40*time.Second, srv.TTL("dupes:k"), "later increments don't extend the window")
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ConnGetter выдаёт соединения с redis, например *redis.Pool.
+type ConnGetter interface {
+	GetContext(ctx context.Context) (redis.Conn, error)
+}
+
+// takeScript атомарно пополняет корзину и пытается взять из неё токен.
+// Время и период передаются из приложения в миллисекундах, чтобы логика совпадала с MemoryStore.
+var takeScript = redis.NewScript(1, `
+local count = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = count
+	ts = now
+end
+
+local elapsed = math.max(0, now - ts)
+tokens = math.min(count, tokens + elapsed * count / period)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], period)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore - token bucket в redis, общий для всех инстансов сервиса.
+type RedisStore struct {
+	pool   ConnGetter
+	prefix string
+	Now    func() time.Time
+}
+
+func NewRedisStore(pool ConnGetter) *RedisStore {
+	return &RedisStore{
+		pool:   pool,
+		prefix: "ratelimit:",
+		Now:    time.Now,
+	}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	reply, err := redis.Values(takeScript.DoContext(ctx, conn,
+		s.prefix+key, limit.Count, limit.Period.Milliseconds(), s.Now().UnixMilli()))
+	if err != nil {
+		return Result{}, err
+	}
+
+	var (
+		allowed   int
+		tokensStr string
+	)
+	if _, err = redis.Scan(reply, &allowed, &tokensStr); err != nil {
+		return Result{}, err
+	}
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return makeResult(limit, tokens, allowed == 1), nil
+}
+
+// incrScript увеличивает счётчик и ставит срок окна на первом увеличении одной командой,
+// чтобы счётчик не остался без TTL, если соединение оборвётся между INCR и PEXPIRE.
+var incrScript = redis.NewScript(1, `
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// RedisCounter - счётчик с фиксированным окном в redis.
+type RedisCounter struct {
+	pool   ConnGetter
+	prefix string
+}
+
+func NewRedisCounter(pool ConnGetter) *RedisCounter {
+	return &RedisCounter{pool: pool, prefix: "dupes:"}
+}
+
+func (c *RedisCounter) Incr(ctx context.Context, key string, window time.Duration) (int, error) {
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	return redis.Int(incrScript.DoContext(ctx, conn, c.prefix+key, window.Milliseconds()))
+}
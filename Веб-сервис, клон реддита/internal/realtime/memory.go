@@ -0,0 +1,115 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultBuffer - сколько событий может отстать подписчик, прежде чем новые для него начнут теряться.
+const defaultBuffer = 32
+
+// MemoryHub рассылает события подписчикам внутри одного процесса.
+// Медленный подписчик не тормозит публикацию: если его буфер полон, событие для него теряется.
+type MemoryHub struct {
+	Buffer int
+
+	mu      sync.RWMutex
+	topics  map[string]map[*Subscription]struct{}
+	closed  bool
+	dropped atomic.Int64
+}
+
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{
+		Buffer: defaultBuffer,
+		topics: map[string]map[*Subscription]struct{}{},
+	}
+}
+
+func (h *MemoryHub) Publish(ctx context.Context, ev Event) error {
+	h.deliver(ev)
+	return nil
+}
+
+// deliver отдаёт событие каждому подписчику один раз, даже если он подписан на несколько его тем.
+func (h *MemoryHub) deliver(ev Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	sent := map[*Subscription]bool{}
+	for _, topic := range ev.Topics() {
+		for sub := range h.topics[topic] {
+			if sent[sub] {
+				continue
+			}
+			sent[sub] = true
+			select {
+			case sub.ch <- ev:
+			default:
+				h.dropped.Add(1)
+			}
+		}
+	}
+}
+
+func (h *MemoryHub) Subscribe(topics []string) *Subscription {
+	ch := make(chan Event, h.Buffer)
+	sub := &Subscription{C: ch, ch: ch, topics: topics, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		sub.once.Do(func() { close(ch) })
+		return sub
+	}
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = map[*Subscription]struct{}{}
+		}
+		h.topics[topic][sub] = struct{}{}
+	}
+	return sub
+}
+
+// remove закрывает канал под блокировкой записи, поэтому deliver не может писать в закрытый канал.
+func (h *MemoryHub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unsubscribe(sub)
+}
+
+func (h *MemoryHub) unsubscribe(sub *Subscription) {
+	for _, topic := range sub.topics {
+		delete(h.topics[topic], sub)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	sub.once.Do(func() { close(sub.ch) })
+}
+
+// Close закрывает все подписки, после этого новые подписки сразу закрыты.
+// Нужен при остановке сервера: открытые потоки SSE иначе не дадут ему завершиться.
+func (h *MemoryHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, subs := range h.topics {
+		for sub := range subs {
+			h.unsubscribe(sub)
+		}
+	}
+}
+
+// Subscribers возвращает число подписчиков темы.
+func (h *MemoryHub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
+
+// Dropped возвращает число событий, потерянных из-за медленных подписчиков.
+func (h *MemoryHub) Dropped() int64 {
+	return h.dropped.Load()
+}
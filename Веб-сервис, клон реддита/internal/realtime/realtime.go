@@ -0,0 +1,65 @@
+package realtime
+
+import (
+	"context"
+	"redditclone/internal/posts"
+	"sync"
+)
+
+// EventType - что изменилось.
+type EventType string
+
+const (
+	EventPostCreated  EventType = "post_created"
+	EventVoteChanged  EventType = "vote_changed"
+	EventCommentAdded EventType = "comment_added"
+)
+
+// TopicAll получает события обо всех постах, как главная страница.
+const TopicAll = "all"
+
+// Event уходит подписчикам как есть. Post - состояние поста после изменения, чтобы клиенту не нужно было его перезапрашивать.
+type Event struct {
+	Type     EventType   `json:"type"`
+	PostID   string      `json:"postId"`
+	Category string      `json:"category"`
+	Post     *posts.Post `json:"post"`
+}
+
+func NewEvent(typ EventType, post *posts.Post) Event {
+	return Event{Type: typ, PostID: post.ID.Hex(), Category: post.Category, Post: post}
+}
+
+func PostTopic(postID string) string {
+	return "post:" + postID
+}
+
+func CategoryTopic(category string) string {
+	return "category:" + category
+}
+
+// Topics - темы, в которые попадает событие: сам пост, его категория и общая лента.
+func (ev Event) Topics() []string {
+	return []string{PostTopic(ev.PostID), CategoryTopic(ev.Category), TopicAll}
+}
+
+// Hub рассылает события подписчикам тем.
+type Hub interface {
+	Publish(ctx context.Context, ev Event) error
+	Subscribe(topics []string) *Subscription
+}
+
+// Subscription - поток событий по выбранным темам. C закрывается после Close или остановки хаба.
+type Subscription struct {
+	C <-chan Event
+
+	ch     chan Event
+	topics []string
+	hub    *MemoryHub
+	once   sync.Once
+}
+
+// Close отписывает от всех тем. Безопасно вызывать несколько раз.
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
@@ -0,0 +1,158 @@
+package realtime
+
+import (
+	"context"
+	"redditclone/internal/posts"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func newEvent(category string) Event {
+	return NewEvent(EventVoteChanged, &posts.Post{ID: primitive.NewObjectID(), Category: category, Score: 3})
+}
+
+// receive ждёт событие, чтобы тест не зависал, если оно не придёт.
+func receive(t *testing.T, sub *Subscription) (Event, bool) {
+	t.Helper()
+	select {
+	case ev, ok := <-sub.C:
+		return ev, ok
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return Event{}, false
+	}
+}
+
+func assertEmpty(t *testing.T, sub *Subscription) {
+	t.Helper()
+	select {
+	case ev := <-sub.C:
+		t.Fatalf("unexpected event %+v", ev)
+	default:
+	}
+}
+
+func TestMemoryHub(t *testing.T) {
+	t.Run("События по теме поста, категории и общей ленте", func(t *testing.T) {
+		hub := NewMemoryHub()
+		ev := newEvent("music")
+
+		byPost := hub.Subscribe([]string{PostTopic(ev.PostID)})
+		byCategory := hub.Subscribe([]string{CategoryTopic("music")})
+		all := hub.Subscribe([]string{TopicAll})
+		other := hub.Subscribe([]string{CategoryTopic("news"), PostTopic(primitive.NewObjectID().Hex())})
+
+		assert.NoError(t, hub.Publish(context.Background(), ev))
+
+		for _, sub := range []*Subscription{byPost, byCategory, all} {
+			got, ok := receive(t, sub)
+			assert.True(t, ok)
+			assert.Equal(t, ev, got)
+		}
+		assertEmpty(t, other)
+	})
+
+	t.Run("Подписчик на несколько тем события получает его один раз", func(t *testing.T) {
+		hub := NewMemoryHub()
+		ev := newEvent("music")
+		sub := hub.Subscribe([]string{PostTopic(ev.PostID), CategoryTopic("music"), TopicAll})
+
+		assert.NoError(t, hub.Publish(context.Background(), ev))
+		receive(t, sub)
+		assertEmpty(t, sub)
+	})
+
+	t.Run("Медленный подписчик теряет события, а не блокирует публикацию", func(t *testing.T) {
+		hub := NewMemoryHub()
+		hub.Buffer = 2
+		sub := hub.Subscribe([]string{TopicAll})
+
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, hub.Publish(context.Background(), newEvent("music")))
+		}
+		assert.Len(t, sub.C, 2)
+		assert.Equal(t, int64(3), hub.Dropped())
+	})
+
+	t.Run("Отписка освобождает темы и закрывает канал", func(t *testing.T) {
+		hub := NewMemoryHub()
+		sub := hub.Subscribe([]string{TopicAll, CategoryTopic("music")})
+		assert.Equal(t, 1, hub.Subscribers(TopicAll))
+
+		sub.Close()
+		sub.Close()
+		_, ok := <-sub.C
+		assert.False(t, ok)
+		assert.Equal(t, 0, hub.Subscribers(TopicAll))
+		assert.Equal(t, 0, hub.Subscribers(CategoryTopic("music")))
+	})
+
+	t.Run("Close закрывает все подписки, в том числе будущие", func(t *testing.T) {
+		hub := NewMemoryHub()
+		first := hub.Subscribe([]string{TopicAll})
+		second := hub.Subscribe([]string{CategoryTopic("music")})
+
+		hub.Close()
+		for _, sub := range []*Subscription{first, second, hub.Subscribe([]string{TopicAll})} {
+			_, ok := receive(t, sub)
+			assert.False(t, ok)
+			sub.Close()
+		}
+	})
+}
+
+func TestRedisHub(t *testing.T) {
+	srv := miniredis.RunT(t)
+	pool := &redis.Pool{
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			return redis.DialContext(ctx, "tcp", srv.Addr())
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+
+	// Два хаба на одном redis - как два инстанса сервиса.
+	publisher := NewRedisHub(pool, zap.NewNop().Sugar())
+	listener := NewRedisHub(pool, zap.NewNop().Sugar())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		listener.Run(ctx)
+	}()
+
+	sub := listener.Subscribe([]string{CategoryTopic("music")})
+	defer sub.Close()
+
+	// Run подписывается асинхронно, ждём, пока канал появится в redis.
+	require.Eventually(t, func() bool {
+		return len(srv.PubSubChannels("")) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	ev := newEvent("music")
+	require.NoError(t, publisher.Publish(context.Background(), ev))
+
+	got, ok := receive(t, sub)
+	assert.True(t, ok)
+	assert.Equal(t, ev.PostID, got.PostID)
+	assert.Equal(t, ev.Type, got.Type)
+	assert.Equal(t, ev.Post.Score, got.Post.Score)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("redis hub did not stop")
+	}
+
+	listener.Close()
+	_, ok = <-sub.C
+	assert.False(t, ok)
+}
@@ -0,0 +1,112 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"go.uber.org/zap"
+)
+
+// ConnGetter выдаёт соединения с redis, например *redis.Pool.
+type ConnGetter interface {
+	GetContext(ctx context.Context) (redis.Conn, error)
+}
+
+// reconnectDelay - пауза перед повторной подпиской после обрыва соединения с redis.
+const reconnectDelay = time.Second
+
+// RedisHub пересылает события через redis pub/sub, чтобы подписчики любого инстанса получали
+// события со всех остальных. Локальная рассылка - тот же MemoryHub, в него попадают события из redis.
+type RedisHub struct {
+	Logger *zap.SugaredLogger
+
+	pool    ConnGetter
+	channel string
+	local   *MemoryHub
+}
+
+func NewRedisHub(pool ConnGetter, logger *zap.SugaredLogger) *RedisHub {
+	return &RedisHub{
+		Logger:  logger,
+		pool:    pool,
+		channel: "realtime:events",
+		local:   NewMemoryHub(),
+	}
+}
+
+// Publish отправляет событие в redis. Локальные подписчики получат его оттуда же, как и все остальные.
+func (h *RedisHub) Publish(ctx context.Context, ev Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	conn, err := h.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = redis.DoContext(conn, ctx, "PUBLISH", h.channel, data)
+	return err
+}
+
+func (h *RedisHub) Subscribe(topics []string) *Subscription {
+	return h.local.Subscribe(topics)
+}
+
+// Close закрывает все локальные подписки.
+func (h *RedisHub) Close() {
+	h.local.Close()
+}
+
+// Run слушает канал redis, пока не отменят ctx, и переподписывается после обрывов.
+func (h *RedisHub) Run(ctx context.Context) {
+	for {
+		err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		h.Logger.Errorw("realtime subscription to redis lost", "error", err)
+
+		select {
+		case <-time.After(reconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *RedisHub) listen(ctx context.Context) error {
+	conn, err := h.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	psc := redis.PubSubConn{Conn: conn}
+	defer psc.Close()
+
+	if err = psc.Subscribe(h.channel); err != nil {
+		return err
+	}
+
+	for {
+		switch msg := psc.ReceiveContext(ctx).(type) {
+		case redis.Message:
+			var ev Event
+			if err := json.Unmarshal(msg.Data, &ev); err != nil {
+				h.Logger.Warnw("bad realtime event in redis", "error", err)
+				continue
+			}
+			h.local.deliver(ev)
+		case redis.Subscription:
+			if msg.Count == 0 {
+				return errors.New("unsubscribed")
+			}
+		case error:
+			return msg
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package sessions
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,30 +35,45 @@ func (m *MockSessionManagerInterface) EXPECT() *MockSessionManagerInterfaceMockR
 }
 
 // Check mocks base method.
-func (m *MockSessionManagerInterface) Check(in *SessionID) *Session {
+func (m *MockSessionManagerInterface) Check(ctx context.Context, in *SessionID) *Session {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Check", in)
+	ret := m.ctrl.Call(m, "Check", ctx, in)
 	ret0, _ := ret[0].(*Session)
 	return ret0
 }
 
 // Check indicates an expected call of Check.
-func (mr *MockSessionManagerInterfaceMockRecorder) Check(in interface{}) *gomock.Call {
+func (mr *MockSessionManagerInterfaceMockRecorder) Check(ctx, in interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockSessionManagerInterface)(nil).Check), in)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockSessionManagerInterface)(nil).Check), ctx, in)
 }
 
 // Create mocks base method.
-func (m *MockSessionManagerInterface) Create(in *Session) (*SessionID, error) {
+func (m *MockSessionManagerInterface) Create(ctx context.Context, in *Session) (*SessionID, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", in)
+	ret := m.ctrl.Call(m, "Create", ctx, in)
 	ret0, _ := ret[0].(*SessionID)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockSessionManagerInterfaceMockRecorder) Create(in interface{}) *gomock.Call {
+func (mr *MockSessionManagerInterfaceMockRecorder) Create(ctx, in interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSessionManagerInterface)(nil).Create), in)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSessionManagerInterface)(nil).Create), ctx, in)
+}
+
+// RevokeAll mocks base method.
+func (m *MockSessionManagerInterface) RevokeAll(ctx context.Context, userID int64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAll", ctx, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAll indicates an expected call of RevokeAll.
+func (mr *MockSessionManagerInterfaceMockRecorder) RevokeAll(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAll", reflect.TypeOf((*MockSessionManagerInterface)(nil).RevokeAll), ctx, userID)
 }
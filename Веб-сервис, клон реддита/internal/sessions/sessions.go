@@ -1,10 +1,15 @@
 package sessions
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"math/rand"
+	"redditclone/internal/logging"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
@@ -19,56 +24,218 @@ type SessionID struct {
 	ID string
 }
 
-const sessKeyLen = 10
+const (
+	sessKeyLen = 10
+	// sessTTL - сколько живёт сессия, в секундах.
+	sessTTL = 3600
+)
 
 type SessionManagerInterface interface {
-	Create(in *Session) (*SessionID, error)
-	Check(in *SessionID) *Session
+	Create(ctx context.Context, in *Session) (*SessionID, error)
+	Check(ctx context.Context, in *SessionID) *Session
+	// RevokeAll завершает все сессии пользователя и возвращает, сколько их было.
+	RevokeAll(ctx context.Context, userID int64) (int, error)
+}
+
+// ConnGetter выдаёт соединения с redis, например *redis.Pool.
+type ConnGetter interface {
+	GetContext(ctx context.Context) (redis.Conn, error)
 }
 
 type SessionManager struct {
-	redisConn redis.Conn
+	// Соединение берётся из пула на каждый вызов: redigo закрывает соединение,
+	// если контекст команды отменён, и одно общее соединение сломалось бы навсегда.
+	pool ConnGetter
+	// Timeout ограничивает время одного вызова к redis. 0 - без таймаута.
+	Timeout time.Duration
 }
 
-func NewSessionManager(conn redis.Conn) *SessionManager {
+func NewSessionManager(pool ConnGetter) *SessionManager {
 	return &SessionManager{
-		redisConn: conn,
+		pool: pool,
 	}
 }
 
-func (sm *SessionManager) Create(in *Session) (*SessionID, error) {
+func (sm *SessionManager) Create(ctx context.Context, in *Session) (*SessionID, error) {
 	id := SessionID{RandStringRunes(sessKeyLen)}
 	dataSerialized, err := json.Marshal(in)
 	if err != nil {
 		return nil, fmt.Errorf("can't marshal data")
 	}
 	mkey := "sessions:" + id.ID
-	result, err := redis.String(sm.redisConn.Do("SET", mkey, dataSerialized, "EX", 3600))
+	ctx, cancel := sm.withTimeout(ctx)
+	defer cancel()
+	conn, err := sm.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	result, err := redis.String(redis.DoContext(conn, ctx, "SET", mkey, dataSerialized, "EX", sessTTL))
 	if err != nil {
 		return nil, err
 	}
 	if result != "OK" {
 		return nil, fmt.Errorf("result not OK")
 	}
+
+	// Индекс сессий пользователя для RevokeAll. Живёт не меньше самой свежей сессии,
+	// ключи протухших сессий в нём безвредны.
+	ukey := userKey(in.ID)
+	if _, err = redis.DoContext(conn, ctx, "SADD", ukey, id.ID); err != nil {
+		return nil, err
+	}
+	if _, err = redis.DoContext(conn, ctx, "EXPIRE", ukey, sessTTL); err != nil {
+		return nil, err
+	}
 	return &id, nil
 }
 
-func (sm *SessionManager) Check(in *SessionID) *Session {
+// RevokeAll удаляет все сессии пользователя: выданные ему токены перестают проходить Check.
+func (sm *SessionManager) RevokeAll(ctx context.Context, userID int64) (int, error) {
+	ukey := userKey(userID)
+	ctx, cancel := sm.withTimeout(ctx)
+	defer cancel()
+	conn, err := sm.pool.GetContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	ids, err := redis.Strings(redis.DoContext(conn, ctx, "SMEMBERS", ukey))
+	if err != nil {
+		return 0, err
+	}
+
+	keys := make([]interface{}, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, "sessions:"+id)
+	}
+	keys = append(keys, ukey)
+	revoked, err := redis.Int(redis.DoContext(conn, ctx, "DEL", keys...))
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) > 0 {
+		// Сам индекс тоже считается в DEL.
+		revoked--
+	}
+	return revoked, nil
+}
+
+// Info - живая сессия пользователя для администратора.
+type Info struct {
+	ID        string    `json:"id"`
+	UserID    int64     `json:"userId"`
+	Login     string    `json:"login"`
+	Useragent string    `json:"useragent"`
+	Expires   time.Time `json:"expires"`
+}
+
+// List отдаёт живые сессии пользователя. Протухшие попутно убираются из индекса.
+func (sm *SessionManager) List(ctx context.Context, userID int64) ([]*Info, error) {
+	ukey := userKey(userID)
+	ctx, cancel := sm.withTimeout(ctx)
+	defer cancel()
+	conn, err := sm.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ids, err := redis.Strings(redis.DoContext(conn, ctx, "SMEMBERS", ukey))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	list := make([]*Info, 0, len(ids))
+	for _, id := range ids {
+		data, err := redis.Bytes(redis.DoContext(conn, ctx, "GET", "sessions:"+id))
+		if errors.Is(err, redis.ErrNil) {
+			if _, err = redis.DoContext(conn, ctx, "SREM", ukey, id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ttl, err := redis.Int64(redis.DoContext(conn, ctx, "PTTL", "sessions:"+id))
+		if err != nil {
+			return nil, err
+		}
+		sess := &Session{}
+		if err = json.Unmarshal(data, sess); err != nil {
+			return nil, fmt.Errorf("session %s: %w", id, err)
+		}
+		list = append(list, &Info{
+			ID:        id,
+			UserID:    sess.ID,
+			Login:     sess.Login,
+			Useragent: sess.Useragent,
+			Expires:   now.Add(time.Duration(ttl) * time.Millisecond),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Expires.Before(list[j].Expires) })
+	return list, nil
+}
+
+// Revoke завершает одну сессию пользователя. false - такой живой сессии у него нет.
+func (sm *SessionManager) Revoke(ctx context.Context, userID int64, sessionID string) (bool, error) {
+	ctx, cancel := sm.withTimeout(ctx)
+	defer cancel()
+	conn, err := sm.pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	member, err := redis.Bool(redis.DoContext(conn, ctx, "SREM", userKey(userID), sessionID))
+	if err != nil || !member {
+		return false, err
+	}
+	deleted, err := redis.Bool(redis.DoContext(conn, ctx, "DEL", "sessions:"+sessionID))
+	return deleted, err
+}
+
+func userKey(userID int64) string {
+	return "sessions:user:" + strconv.FormatInt(userID, 10)
+}
+
+func (sm *SessionManager) Check(ctx context.Context, in *SessionID) *Session {
 	mkey := "sessions:" + in.ID
-	data, err := redis.Bytes(sm.redisConn.Do("GET", mkey))
+	logger := logging.FromContext(ctx, nil)
+	ctx, cancel := sm.withTimeout(ctx)
+	defer cancel()
+	conn, err := sm.pool.GetContext(ctx)
+	if err != nil {
+		logger.Errorw("cant get redis connection", "error", err)
+		return nil
+	}
+	defer conn.Close()
+	data, err := redis.Bytes(redis.DoContext(conn, ctx, "GET", mkey))
+	if errors.Is(err, redis.ErrNil) {
+		// Сессия истекла или отозвана - обычная ситуация, а не сбой.
+		logger.Debugw("session not found")
+		return nil
+	}
 	if err != nil {
-		log.Println("cant get data:", err)
+		logger.Errorw("cant get session data", "error", err)
 		return nil
 	}
 	sess := &Session{}
 	err = json.Unmarshal(data, sess)
 	if err != nil {
-		log.Println("cant unpack session data:", err)
+		logger.Errorw("cant unpack session data", "error", err)
 		return nil
 	}
 	return sess
 }
 
+func (sm *SessionManager) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if sm.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, sm.Timeout)
+}
+
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func RandStringRunes(n int) string {
@@ -0,0 +1,120 @@
+package sessions
+
+import (
+	"context"
+	"redditclone/internal/logging"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newPool(t *testing.T) (*redis.Pool, *miniredis.Miniredis) {
+	srv := miniredis.RunT(t)
+	addr := srv.Addr()
+	pool := &redis.Pool{
+		MaxIdle: 2,
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			return redis.DialContext(ctx, "tcp", addr)
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool, srv
+}
+
+func TestSessionManagerTimeout(t *testing.T) {
+	pool, srv := newPool(t)
+	sm := NewSessionManager(pool)
+	sm.Timeout = 50 * time.Millisecond
+	core, logs := observer.New(zap.DebugLevel)
+	ctx := logging.NewContext(context.Background(), zap.New(core).Sugar())
+
+	id, err := sm.Create(ctx, &Session{ID: 1, Login: "rvasily"})
+	require.NoError(t, err)
+
+	// Пока сервер заблокирован, команды зависают до таймаута, и redigo закрывает соединение.
+	srv.Lock()
+	start := time.Now()
+	assert.Nil(t, sm.Check(ctx, id), "Check прерывается по таймауту")
+	assert.Equal(t, 1, logs.FilterLevelExact(zap.ErrorLevel).Len(), "сбой redis пишется в лог запроса")
+	_, err = sm.Create(ctx, &Session{ID: 2, Login: "ivan"})
+	assert.Error(t, err, "Create прерывается по таймауту")
+	assert.Less(t, time.Since(start), time.Second)
+	srv.Unlock()
+
+	// Сломанные соединения не возвращаются в пул: следующий вызов работает.
+	sess := sm.Check(ctx, id)
+	require.NotNil(t, sess)
+	assert.Equal(t, "rvasily", sess.Login)
+
+	// Неизвестная сессия - не ошибка.
+	assert.Nil(t, sm.Check(ctx, &SessionID{ID: "unknown"}))
+	assert.Equal(t, 1, logs.FilterLevelExact(zap.ErrorLevel).Len())
+}
+
+func TestRevokeAll(t *testing.T) {
+	pool, srv := newPool(t)
+	sm := NewSessionManager(pool)
+	ctx := context.Background()
+
+	first, err := sm.Create(ctx, &Session{ID: 1, Login: "rvasily"})
+	require.NoError(t, err)
+	second, err := sm.Create(ctx, &Session{ID: 1, Login: "rvasily"})
+	require.NoError(t, err)
+	other, err := sm.Create(ctx, &Session{ID: 2, Login: "ivan"})
+	require.NoError(t, err)
+
+	// Одна из сессий уже протухла сама.
+	srv.Del("sessions:" + second.ID)
+
+	revoked, err := sm.RevokeAll(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, revoked)
+	assert.Nil(t, sm.Check(ctx, first))
+	assert.False(t, srv.Exists("sessions:user:1"), "index is removed")
+	assert.NotNil(t, sm.Check(ctx, other), "other users keep their sessions")
+
+	revoked, err = sm.RevokeAll(ctx, 1)
+	assert.NoError(t, err)
+	assert.Zero(t, revoked, "nothing left to revoke")
+}
+
+func TestListAndRevoke(t *testing.T) {
+	pool, srv := newPool(t)
+	sm := NewSessionManager(pool)
+	ctx := context.Background()
+
+	first, err := sm.Create(ctx, &Session{ID: 1, Login: "rvasily", Useragent: "curl"})
+	require.NoError(t, err)
+	srv.FastForward(time.Minute)
+	second, err := sm.Create(ctx, &Session{ID: 1, Login: "rvasily", Useragent: "firefox"})
+	require.NoError(t, err)
+	expired, err := sm.Create(ctx, &Session{ID: 1, Login: "rvasily"})
+	require.NoError(t, err)
+	srv.Del("sessions:" + expired.ID)
+
+	list, err := sm.List(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, first.ID, list[0].ID, "sorted by expiry")
+	assert.Equal(t, "curl", list[0].Useragent)
+	assert.Equal(t, second.ID, list[1].ID)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), list[1].Expires, 5*time.Second)
+	members, _ := srv.Members("sessions:user:1")
+	assert.Len(t, members, 2, "expired session is pruned from the index")
+
+	revoked, err := sm.Revoke(ctx, 1, first.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	assert.Nil(t, sm.Check(ctx, first))
+
+	revoked, err = sm.Revoke(ctx, 2, second.ID)
+	require.NoError(t, err)
+	assert.False(t, revoked, "session of another user")
+	assert.NotNil(t, sm.Check(ctx, second))
+}
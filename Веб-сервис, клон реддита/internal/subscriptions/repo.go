@@ -0,0 +1,117 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"redditclone/internal/logging"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrFailedConvert = errors.New("failed to convert values")
+	// ErrStorage оборачивает ошибки монги, исходная ошибка доступна через errors.Is/As.
+	ErrStorage = errors.New("storage error")
+)
+
+// storageError пишет ошибку монги в лог запроса и оборачивает её в ErrStorage.
+func storageError(ctx context.Context, op string, err error) error {
+	logging.FromContext(ctx, nil).Errorw("storage error", "op", op, "error", err)
+	return fmt.Errorf("%w: %w", ErrStorage, err)
+}
+
+type SubscriptionMongoRepository struct {
+	DB *mongo.Collection
+	// Таймауты на одну операцию чтения/записи в монгу. 0 - без таймаута, только контекст запроса.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+func NewMongoRepo(db *mongo.Collection) *SubscriptionMongoRepository {
+	return &SubscriptionMongoRepository{DB: db}
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// EnsureIndexes создаёт уникальный индекс, на который опирается идемпотентность Subscribe.
+func (repo *SubscriptionMongoRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "type", Value: 1}, {Key: "target", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return storageError(ctx, "subscriptions.EnsureIndexes", err)
+	}
+	return nil
+}
+
+func subFilter(sub *Subscription) bson.M {
+	return bson.M{"userId": sub.UserID, "type": sub.Type, "target": sub.Target}
+}
+
+func (repo *SubscriptionMongoRepository) Subscribe(ctx context.Context, sub *Subscription) error {
+	created := sub.Created
+	if created.IsZero() {
+		created = time.Now().UTC()
+	}
+
+	update := bson.M{"$setOnInsert": bson.M{"created": created}}
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.UpdateOne(ctx, subFilter(sub), update, options.Update().SetUpsert(true))
+	if err != nil {
+		return storageError(ctx, "subscriptions.Subscribe", err)
+	}
+	return nil
+}
+
+func (repo *SubscriptionMongoRepository) Unsubscribe(ctx context.Context, sub *Subscription) error {
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	_, err := repo.DB.DeleteOne(ctx, subFilter(sub))
+	if err != nil {
+		return storageError(ctx, "subscriptions.Unsubscribe", err)
+	}
+	return nil
+}
+
+func (repo *SubscriptionMongoRepository) List(ctx context.Context, userID int64) ([]*Subscription, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "type", Value: 1}, {Key: "target", Value: 1}})
+	ctx, cancel := withTimeout(ctx, repo.ReadTimeout)
+	defer cancel()
+	c, err := repo.DB.Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, storageError(ctx, "subscriptions.List", err)
+	}
+
+	subs := []*Subscription{}
+	if err = c.All(ctx, &subs); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedConvert, err)
+	}
+	return subs, nil
+}
+
+func (repo *SubscriptionMongoRepository) DeleteUser(ctx context.Context, userID int64, username string) (int64, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"userId": userID},
+		bson.M{"type": TypeUser, "target": username},
+	}}
+	ctx, cancel := withTimeout(ctx, repo.WriteTimeout)
+	defer cancel()
+	result, err := repo.DB.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, storageError(ctx, "subscriptions.DeleteUser", err)
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,93 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: subscriptions.go
+
+// Package subscriptions is a generated GoMock package.
+package subscriptions
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockSubscriptionRepo is a mock of SubscriptionRepo interface.
+type MockSubscriptionRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockSubscriptionRepoMockRecorder
+}
+
+// MockSubscriptionRepoMockRecorder is the mock recorder for MockSubscriptionRepo.
+type MockSubscriptionRepoMockRecorder struct {
+	mock *MockSubscriptionRepo
+}
+
+// NewMockSubscriptionRepo creates a new mock instance.
+func NewMockSubscriptionRepo(ctrl *gomock.Controller) *MockSubscriptionRepo {
+	mock := &MockSubscriptionRepo{ctrl: ctrl}
+	mock.recorder = &MockSubscriptionRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSubscriptionRepo) EXPECT() *MockSubscriptionRepoMockRecorder {
+	return m.recorder
+}
+
+// DeleteUser mocks base method.
+func (m *MockSubscriptionRepo) DeleteUser(ctx context.Context, userID int64, username string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, userID, username)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockSubscriptionRepoMockRecorder) DeleteUser(ctx, userID, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockSubscriptionRepo)(nil).DeleteUser), ctx, userID, username)
+}
+
+// List mocks base method.
+func (m *MockSubscriptionRepo) List(ctx context.Context, userID int64) ([]*Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, userID)
+	ret0, _ := ret[0].([]*Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockSubscriptionRepoMockRecorder) List(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSubscriptionRepo)(nil).List), ctx, userID)
+}
+
+// Subscribe mocks base method.
+func (m *MockSubscriptionRepo) Subscribe(ctx context.Context, sub *Subscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", ctx, sub)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockSubscriptionRepoMockRecorder) Subscribe(ctx, sub interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockSubscriptionRepo)(nil).Subscribe), ctx, sub)
+}
+
+// Unsubscribe mocks base method.
+func (m *MockSubscriptionRepo) Unsubscribe(ctx context.Context, sub *Subscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unsubscribe", ctx, sub)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unsubscribe indicates an expected call of Unsubscribe.
+func (mr *MockSubscriptionRepoMockRecorder) Unsubscribe(ctx, sub interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unsubscribe", reflect.TypeOf((*MockSubscriptionRepo)(nil).Unsubscribe), ctx, sub)
+}
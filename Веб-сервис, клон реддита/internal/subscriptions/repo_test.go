@@ -0,0 +1,164 @@
+package subscriptions
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"redditclone/internal/logging"
+	"testing"
+	"time"
+)
+
+func TestSubscribeUnsubscribe(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	sub := &Subscription{UserID: 1, Type: TypeCategory, Target: "music"}
+
+	var tests = []struct {
+		name         string
+		subscribe    bool
+		mockResponse []bson.D
+		wantErr      error
+	}{
+		{
+			name:         "Проверка на успешную подписку",
+			subscribe:    true,
+			mockResponse: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1})},
+		},
+		{
+			name:         "Проверка на ошибку при подписке",
+			subscribe:    true,
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+		{
+			name:         "Проверка на отписку от отсутствующей подписки",
+			mockResponse: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0})},
+		},
+		{
+			name:         "Проверка на ошибку при отписке",
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			var err error
+			if tc.subscribe {
+				err = repo.Subscribe(context.Background(), sub)
+			} else {
+				err = repo.Unsubscribe(context.Background(), sub)
+			}
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	created := time.Date(2024, 5, 7, 20, 38, 17, 0, time.UTC)
+
+	var tests = []struct {
+		name         string
+		mockResponse []bson.D
+		want         []*Subscription
+		wantErr      error
+	}{
+		{
+			name: "Проверка на успешное получение подписок",
+			mockResponse: []bson.D{
+				mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+					bson.D{{Key: "userId", Value: int64(1)}, {Key: "type", Value: "category"}, {Key: "target", Value: "music"}, {Key: "created", Value: created}},
+					bson.D{{Key: "userId", Value: int64(1)}, {Key: "type", Value: "user"}, {Key: "target", Value: "ivan"}, {Key: "created", Value: created}},
+				),
+			},
+			want: []*Subscription{
+				{UserID: 1, Type: TypeCategory, Target: "music", Created: created},
+				{UserID: 1, Type: TypeUser, Target: "ivan", Created: created},
+			},
+		},
+		{
+			name:         "Проверка на ошибку при запросе",
+			mockResponse: []bson.D{{{Key: "ok", Value: 0}}},
+			wantErr:      ErrStorage,
+		},
+	}
+
+	for _, tc := range tests {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			repo := NewMongoRepo(mt.Coll)
+			mt.AddMockResponses(tc.mockResponse...)
+
+			subs, err := repo.List(context.Background(), 1)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, subs)
+
+			categories, users := Split(subs)
+			assert.Equal(t, []string{"music"}, categories)
+			assert.Equal(t, []string{"ivan"}, users)
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Удаляются свои подписки и подписки на пользователя", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 4}))
+
+		n, err := repo.DeleteUser(context.Background(), 1, "rvasily")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), n)
+
+		del := mt.GetStartedEvent().Command.Lookup("deletes").Array().Index(0).Value().Document()
+		or := del.Lookup("q", "$or").Array()
+		assert.Equal(t, int64(1), or.Index(0).Value().Document().Lookup("userId").Int64())
+		assert.Equal(t, "rvasily", or.Index(1).Value().Document().Lookup("target").StringValue())
+	})
+
+	mt.Run("Проверка на ошибку при удалении", func(mt *mtest.T) {
+		repo := NewMongoRepo(mt.Coll)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := repo.DeleteUser(context.Background(), 1, "rvasily")
+		assert.ErrorIs(t, err, ErrStorage)
+	})
+}
+
+func TestStorageErrorLogged(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Ошибка монги пишется в лог запроса", func(mt *mtest.T) {
+		core, logs := observer.New(zap.ErrorLevel)
+		ctx := logging.NewContext(context.Background(), zap.New(core).Sugar().With("request_id", "req-1"))
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		repo := NewMongoRepo(mt.Coll)
+		_, err := repo.List(ctx, 1)
+		assert.ErrorIs(t, err, ErrStorage)
+
+		entries := logs.AllUntimed()
+		if assert.Len(t, entries, 1) {
+			fields := entries[0].ContextMap()
+			assert.Equal(t, "subscriptions.List", fields["op"])
+			assert.Equal(t, "req-1", fields["request_id"])
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package subscriptions
+
+import (
+	"context"
+	"time"
+)
+
+// Type - на что подписка: на категорию или на автора.
+type Type string
+
+const (
+	TypeCategory Type = "category"
+	TypeUser     Type = "user"
+)
+
+// Subscription - подписка пользователя. Target - название категории или логин автора.
+type Subscription struct {
+	UserID  int64     `json:"-" bson:"userId"`
+	Type    Type      `json:"type" bson:"type"`
+	Target  string    `json:"target" bson:"target"`
+	Created time.Time `json:"created" bson:"created"`
+}
+
+//go:generate mockgen -source=subscriptions.go -destination=repo_mock.go -package=subscriptions SubscriptionRepo
+type SubscriptionRepo interface {
+	// Subscribe и Unsubscribe идемпотентны.
+	Subscribe(ctx context.Context, sub *Subscription) error
+	Unsubscribe(ctx context.Context, sub *Subscription) error
+	List(ctx context.Context, userID int64) ([]*Subscription, error)
+	// DeleteUser удаляет подписки пользователя и подписки других на него: его логин может занять
+	// новый пользователь, и подписчики незаметно начали бы читать другого человека.
+	DeleteUser(ctx context.Context, userID int64, username string) (int64, error)
+}
+
+// Split раскладывает подписки на категории и авторов - в таком виде их принимает posts.FeedQuery.
+func Split(subs []*Subscription) (categories, users []string) {
+	for _, sub := range subs {
+		switch sub.Type {
+		case TypeCategory:
+			categories = append(categories, sub.Target)
+		case TypeUser:
+			users = append(users, sub.Target)
+		}
+	}
+	return categories, users
+}
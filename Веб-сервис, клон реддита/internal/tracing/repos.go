@@ -0,0 +1,565 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"redditclone/internal/collections"
+	"redditclone/internal/media"
+	"redditclone/internal/moderation"
+	"redditclone/internal/notifications"
+	"redditclone/internal/posts"
+	"redditclone/internal/sessions"
+	"redditclone/internal/subscriptions"
+	"redditclone/internal/user"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func postAttr(postID primitive.ObjectID) attribute.KeyValue {
+	return attribute.String("post.id", postID.Hex())
+}
+
+func userAttr(userID int64) attribute.KeyValue {
+	return attribute.Int64("user.id", userID)
+}
+
+// PostRepo оборачивает posts.PostRepo и пишет спан на каждый вызов.
+type PostRepo struct {
+	next posts.PostRepo
+}
+
+func NewPostRepo(next posts.PostRepo) *PostRepo {
+	return &PostRepo{next: next}
+}
+
+func (r *PostRepo) GetPost(ctx context.Context, postID primitive.ObjectID) (*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.GetPost", postAttr(postID))
+	post, err := r.next.GetPost(ctx, postID)
+	endSpan(span, err)
+	return post, err
+}
+
+func (r *PostRepo) GetPosts(ctx context.Context, filter func(*posts.Post) bool) ([]*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.GetPosts")
+	result, err := r.next.GetPosts(ctx, filter)
+	span.SetAttributes(attribute.Int("posts.count", len(result)))
+	endSpan(span, err)
+	return result, err
+}
+
+func (r *PostRepo) ListPosts(ctx context.Context, scope posts.Scope) ([]*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.ListPosts",
+		attribute.String("posts.category", scope.Category), attribute.String("posts.author", scope.Author))
+	result, err := r.next.ListPosts(ctx, scope)
+	span.SetAttributes(attribute.Int("posts.count", len(result)))
+	endSpan(span, err)
+	return result, err
+}
+
+func (r *PostRepo) GetPostsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.GetPostsByIDs", attribute.Int("posts.ids", len(ids)))
+	result, err := r.next.GetPostsByIDs(ctx, ids)
+	span.SetAttributes(attribute.Int("posts.count", len(result)))
+	endSpan(span, err)
+	return result, err
+}
+
+func (r *PostRepo) GetPostsByAuthors(ctx context.Context, usernames []string) ([]*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.GetPostsByAuthors", attribute.Int("posts.authors", len(usernames)))
+	result, err := r.next.GetPostsByAuthors(ctx, usernames)
+	span.SetAttributes(attribute.Int("posts.count", len(result)))
+	endSpan(span, err)
+	return result, err
+}
+
+func (r *PostRepo) VotePost(ctx context.Context, postID primitive.ObjectID, userID int64, voteVal int) (*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.VotePost", postAttr(postID), userAttr(userID), attribute.Int("vote", voteVal))
+	post, err := r.next.VotePost(ctx, postID, userID, voteVal)
+	endSpan(span, err)
+	return post, err
+}
+
+func (r *PostRepo) MakePost(ctx context.Context, newPost *posts.PostForm, username string, userID int64) (*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.MakePost", userAttr(userID), attribute.String("post.type", newPost.Type))
+	post, err := r.next.MakePost(ctx, newPost, username, userID)
+	endSpan(span, err)
+	return post, err
+}
+
+func (r *PostRepo) DeletePost(ctx context.Context, postID primitive.ObjectID, userID int64) (bool, error) {
+	ctx, span := startSpan(ctx, "PostRepo.DeletePost", postAttr(postID), userAttr(userID))
+	ok, err := r.next.DeletePost(ctx, postID, userID)
+	endSpan(span, err)
+	return ok, err
+}
+
+func (r *PostRepo) MakeComment(ctx context.Context, postID, parentID primitive.ObjectID, comment, username string, userID int64) (*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.MakeComment", postAttr(postID), userAttr(userID))
+	post, err := r.next.MakeComment(ctx, postID, parentID, comment, username, userID)
+	endSpan(span, err)
+	return post, err
+}
+
+func (r *PostRepo) DeleteComment(ctx context.Context, postID, commentID primitive.ObjectID, userID int64) (*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.DeleteComment", postAttr(postID), userAttr(userID),
+		attribute.String("comment.id", commentID.Hex()))
+	post, err := r.next.DeleteComment(ctx, postID, commentID, userID)
+	endSpan(span, err)
+	return post, err
+}
+
+func (r *PostRepo) GetFeed(ctx context.Context, q posts.FeedQuery) (*posts.FeedPage, error) {
+	ctx, span := startSpan(ctx, "PostRepo.GetFeed", attribute.String("feed.sort", q.Sort),
+		attribute.Int("feed.sources", len(q.Categories)+len(q.Authors)), attribute.Bool("feed.first_page", q.After == nil))
+	page, err := r.next.GetFeed(ctx, q)
+	endSpan(span, err)
+	return page, err
+}
+
+func (r *PostRepo) AddViews(ctx context.Context, views map[primitive.ObjectID]int) error {
+	ctx, span := startSpan(ctx, "PostRepo.AddViews", attribute.Int("posts.count", len(views)))
+	err := r.next.AddViews(ctx, views)
+	endSpan(span, err)
+	return err
+}
+
+func (r *PostRepo) SetPostFlags(ctx context.Context, postID primitive.ObjectID, flags posts.PostFlags) (*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.SetPostFlags", postAttr(postID))
+	post, err := r.next.SetPostFlags(ctx, postID, flags)
+	endSpan(span, err)
+	return post, err
+}
+
+func (r *PostRepo) SetCommentRemoved(ctx context.Context, postID, commentID primitive.ObjectID, removed bool) (*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.SetCommentRemoved", postAttr(postID),
+		attribute.String("comment.id", commentID.Hex()), attribute.Bool("comment.removed", removed))
+	post, err := r.next.SetCommentRemoved(ctx, postID, commentID, removed)
+	endSpan(span, err)
+	return post, err
+}
+
+func (r *PostRepo) RestorePost(ctx context.Context, postID primitive.ObjectID, userID int64) (*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.RestorePost", postAttr(postID), userAttr(userID))
+	post, err := r.next.RestorePost(ctx, postID, userID)
+	endSpan(span, err)
+	return post, err
+}
+
+func (r *PostRepo) RestoreComment(ctx context.Context, postID, commentID primitive.ObjectID, userID int64) (*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.RestoreComment", postAttr(postID), userAttr(userID),
+		attribute.String("comment.id", commentID.Hex()))
+	post, err := r.next.RestoreComment(ctx, postID, commentID, userID)
+	endSpan(span, err)
+	return post, err
+}
+
+func (r *PostRepo) Purge(ctx context.Context, before time.Time) (posts.PurgeResult, error) {
+	ctx, span := startSpan(ctx, "PostRepo.Purge", attribute.String("purge.before", before.Format(time.RFC3339)))
+	res, err := r.next.Purge(ctx, before)
+	if err == nil {
+		span.SetAttributes(attribute.Int64("purge.posts", res.Posts), attribute.Int64("purge.comments", res.Comments))
+	}
+	endSpan(span, err)
+	return res, err
+}
+
+func (r *PostRepo) VotePoll(ctx context.Context, postID primitive.ObjectID, userID int64, option int) (*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.VotePoll", postAttr(postID), userAttr(userID), attribute.Int("poll.option", option))
+	post, err := r.next.VotePoll(ctx, postID, userID, option)
+	endSpan(span, err)
+	return post, err
+}
+
+func (r *PostRepo) Crosspost(ctx context.Context, originalID primitive.ObjectID, form *posts.CrosspostForm, username string, userID int64) (*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.Crosspost", postAttr(originalID), userAttr(userID), attribute.String("post.category", form.Category))
+	post, err := r.next.Crosspost(ctx, originalID, form, username, userID)
+	endSpan(span, err)
+	return post, err
+}
+
+func (r *PostRepo) FindByURL(ctx context.Context, normalizedURL string, since time.Time, limit int) ([]*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.FindByURL")
+	found, err := r.next.FindByURL(ctx, normalizedURL, since, limit)
+	span.SetAttributes(attribute.Int("posts.count", len(found)))
+	endSpan(span, err)
+	return found, err
+}
+
+func (r *PostRepo) AnonymizeUser(ctx context.Context, userID int64) (posts.AnonymizeResult, error) {
+	ctx, span := startSpan(ctx, "PostRepo.AnonymizeUser", userAttr(userID))
+	res, err := r.next.AnonymizeUser(ctx, userID)
+	span.SetAttributes(
+		attribute.Int64("posts.anonymized", res.Posts),
+		attribute.Int64("posts.comments_anonymized", res.Comments),
+		attribute.Int64("posts.votes_removed", res.Votes),
+		attribute.Int64("posts.poll_votes_anonymized", res.PollVotes),
+	)
+	endSpan(span, err)
+	return res, err
+}
+
+func (r *PostRepo) UserContent(ctx context.Context, userID int64) ([]*posts.Post, error) {
+	ctx, span := startSpan(ctx, "PostRepo.UserContent", userAttr(userID))
+	result, err := r.next.UserContent(ctx, userID)
+	span.SetAttributes(attribute.Int("posts.count", len(result)))
+	endSpan(span, err)
+	return result, err
+}
+
+// UserRepo оборачивает user.UserRepo и пишет спан на каждый вызов.
+type UserRepo struct {
+	next user.UserRepo
+}
+
+func NewUserRepo(next user.UserRepo) *UserRepo {
+	return &UserRepo{next: next}
+}
+
+func (r *UserRepo) Authorize(ctx context.Context, username, pass string) (*user.User, error) {
+	ctx, span := startSpan(ctx, "UserRepo.Authorize", attribute.String("user.name", username))
+	u, err := r.next.Authorize(ctx, username, pass)
+	endSpan(span, err)
+	return u, err
+}
+
+func (r *UserRepo) MakeUser(ctx context.Context, username, pass string) (*user.User, error) {
+	ctx, span := startSpan(ctx, "UserRepo.MakeUser", attribute.String("user.name", username))
+	u, err := r.next.MakeUser(ctx, username, pass)
+	endSpan(span, err)
+	return u, err
+}
+
+func (r *UserRepo) GetUser(ctx context.Context, username string) (*user.User, error) {
+	ctx, span := startSpan(ctx, "UserRepo.GetUser", attribute.String("user.name", username))
+	u, err := r.next.GetUser(ctx, username)
+	endSpan(span, err)
+	return u, err
+}
+
+func (r *UserRepo) GetUsersByIDs(ctx context.Context, ids []int64) ([]*user.User, error) {
+	ctx, span := startSpan(ctx, "UserRepo.GetUsersByIDs", attribute.Int("user.count", len(ids)))
+	users, err := r.next.GetUsersByIDs(ctx, ids)
+	endSpan(span, err)
+	return users, err
+}
+
+func (r *UserRepo) SetPassword(ctx context.Context, username, pass string) error {
+	ctx, span := startSpan(ctx, "UserRepo.SetPassword", attribute.String("user.name", username))
+	err := r.next.SetPassword(ctx, username, pass)
+	endSpan(span, err)
+	return err
+}
+
+func (r *UserRepo) SetBanned(ctx context.Context, username string, banned bool) error {
+	ctx, span := startSpan(ctx, "UserRepo.SetBanned", attribute.String("user.name", username), attribute.Bool("user.banned", banned))
+	err := r.next.SetBanned(ctx, username, banned)
+	endSpan(span, err)
+	return err
+}
+
+func (r *UserRepo) DeleteUser(ctx context.Context, id int64) error {
+	ctx, span := startSpan(ctx, "UserRepo.DeleteUser", userAttr(id))
+	err := r.next.DeleteUser(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+// CollectionRepo оборачивает collections.CollectionRepo и пишет спан на каждый вызов.
+type CollectionRepo struct {
+	next collections.CollectionRepo
+}
+
+func NewCollectionRepo(next collections.CollectionRepo) *CollectionRepo {
+	return &CollectionRepo{next: next}
+}
+
+func itemAttrs(item *collections.Item) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		userAttr(item.UserID),
+		postAttr(item.PostID),
+		attribute.String("collection.kind", string(item.Kind)),
+		attribute.String("collection.type", string(item.Type)),
+	}
+}
+
+func (r *CollectionRepo) Add(ctx context.Context, item *collections.Item) error {
+	ctx, span := startSpan(ctx, "CollectionRepo.Add", itemAttrs(item)...)
+	err := r.next.Add(ctx, item)
+	endSpan(span, err)
+	return err
+}
+
+func (r *CollectionRepo) Remove(ctx context.Context, item *collections.Item) error {
+	ctx, span := startSpan(ctx, "CollectionRepo.Remove", itemAttrs(item)...)
+	err := r.next.Remove(ctx, item)
+	endSpan(span, err)
+	return err
+}
+
+func (r *CollectionRepo) List(ctx context.Context, userID int64, kind collections.Kind, offset, limit int) ([]*collections.Item, error) {
+	ctx, span := startSpan(ctx, "CollectionRepo.List", userAttr(userID), attribute.String("collection.kind", string(kind)))
+	items, err := r.next.List(ctx, userID, kind, offset, limit)
+	endSpan(span, err)
+	return items, err
+}
+
+func (r *CollectionRepo) Hidden(ctx context.Context, userID int64, itemType collections.ItemType) (map[primitive.ObjectID]bool, error) {
+	ctx, span := startSpan(ctx, "CollectionRepo.Hidden", userAttr(userID), attribute.String("collection.type", string(itemType)))
+	hidden, err := r.next.Hidden(ctx, userID, itemType)
+	endSpan(span, err)
+	return hidden, err
+}
+
+func (r *CollectionRepo) DeleteUser(ctx context.Context, userID int64) (int64, error) {
+	ctx, span := startSpan(ctx, "CollectionRepo.DeleteUser", userAttr(userID))
+	deleted, err := r.next.DeleteUser(ctx, userID)
+	endSpan(span, err)
+	return deleted, err
+}
+
+// SubscriptionRepo оборачивает subscriptions.SubscriptionRepo и пишет спан на каждый вызов.
+type SubscriptionRepo struct {
+	next subscriptions.SubscriptionRepo
+}
+
+func NewSubscriptionRepo(next subscriptions.SubscriptionRepo) *SubscriptionRepo {
+	return &SubscriptionRepo{next: next}
+}
+
+func subAttrs(sub *subscriptions.Subscription) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		userAttr(sub.UserID),
+		attribute.String("subscription.type", string(sub.Type)),
+		attribute.String("subscription.target", sub.Target),
+	}
+}
+
+func (r *SubscriptionRepo) Subscribe(ctx context.Context, sub *subscriptions.Subscription) error {
+	ctx, span := startSpan(ctx, "SubscriptionRepo.Subscribe", subAttrs(sub)...)
+	err := r.next.Subscribe(ctx, sub)
+	endSpan(span, err)
+	return err
+}
+
+func (r *SubscriptionRepo) Unsubscribe(ctx context.Context, sub *subscriptions.Subscription) error {
+	ctx, span := startSpan(ctx, "SubscriptionRepo.Unsubscribe", subAttrs(sub)...)
+	err := r.next.Unsubscribe(ctx, sub)
+	endSpan(span, err)
+	return err
+}
+
+func (r *SubscriptionRepo) List(ctx context.Context, userID int64) ([]*subscriptions.Subscription, error) {
+	ctx, span := startSpan(ctx, "SubscriptionRepo.List", userAttr(userID))
+	subs, err := r.next.List(ctx, userID)
+	endSpan(span, err)
+	return subs, err
+}
+
+func (r *SubscriptionRepo) DeleteUser(ctx context.Context, userID int64, username string) (int64, error) {
+	ctx, span := startSpan(ctx, "SubscriptionRepo.DeleteUser", userAttr(userID))
+	deleted, err := r.next.DeleteUser(ctx, userID, username)
+	endSpan(span, err)
+	return deleted, err
+}
+
+// NotificationRepo оборачивает notifications.NotificationRepo и пишет спан на каждый вызов.
+type NotificationRepo struct {
+	next notifications.NotificationRepo
+}
+
+func NewNotificationRepo(next notifications.NotificationRepo) *NotificationRepo {
+	return &NotificationRepo{next: next}
+}
+
+func (r *NotificationRepo) Create(ctx context.Context, items []*notifications.Notification) error {
+	ctx, span := startSpan(ctx, "NotificationRepo.Create", attribute.Int("notifications.count", len(items)))
+	err := r.next.Create(ctx, items)
+	endSpan(span, err)
+	return err
+}
+
+func (r *NotificationRepo) List(ctx context.Context, userID int64, unreadOnly bool, offset, limit int) ([]*notifications.Notification, error) {
+	ctx, span := startSpan(ctx, "NotificationRepo.List", userAttr(userID), attribute.Bool("notifications.unread_only", unreadOnly))
+	items, err := r.next.List(ctx, userID, unreadOnly, offset, limit)
+	span.SetAttributes(attribute.Int("notifications.count", len(items)))
+	endSpan(span, err)
+	return items, err
+}
+
+func (r *NotificationRepo) MarkRead(ctx context.Context, userID int64, ids []primitive.ObjectID) (int64, error) {
+	ctx, span := startSpan(ctx, "NotificationRepo.MarkRead", userAttr(userID), attribute.Int("notifications.ids", len(ids)))
+	updated, err := r.next.MarkRead(ctx, userID, ids)
+	endSpan(span, err)
+	return updated, err
+}
+
+func (r *NotificationRepo) UnreadCount(ctx context.Context, userID int64) (int64, error) {
+	ctx, span := startSpan(ctx, "NotificationRepo.UnreadCount", userAttr(userID))
+	count, err := r.next.UnreadCount(ctx, userID)
+	endSpan(span, err)
+	return count, err
+}
+
+func (r *NotificationRepo) DeleteUser(ctx context.Context, userID int64, username string) (int64, error) {
+	ctx, span := startSpan(ctx, "NotificationRepo.DeleteUser", userAttr(userID))
+	deleted, err := r.next.DeleteUser(ctx, userID, username)
+	endSpan(span, err)
+	return deleted, err
+}
+
+// ReportRepo оборачивает moderation.ReportRepo и пишет спан на каждый вызов.
+type ReportRepo struct {
+	next moderation.ReportRepo
+}
+
+func NewReportRepo(next moderation.ReportRepo) *ReportRepo {
+	return &ReportRepo{next: next}
+}
+
+func targetAttrs(target moderation.Target) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{postAttr(target.PostID)}
+	if target.CommentID != nil {
+		attrs = append(attrs, attribute.String("comment.id", target.CommentID.Hex()))
+	}
+	return attrs
+}
+
+func (r *ReportRepo) Report(ctx context.Context, report *moderation.Report) error {
+	attrs := append(targetAttrs(report.Target), userAttr(report.ReporterID), attribute.String("report.reason", string(report.Reason)))
+	ctx, span := startSpan(ctx, "ReportRepo.Report", attrs...)
+	err := r.next.Report(ctx, report)
+	endSpan(span, err)
+	return err
+}
+
+func (r *ReportRepo) Queue(ctx context.Context, offset, limit int) ([]*moderation.QueueItem, error) {
+	ctx, span := startSpan(ctx, "ReportRepo.Queue")
+	items, err := r.next.Queue(ctx, offset, limit)
+	span.SetAttributes(attribute.Int("queue.count", len(items)))
+	endSpan(span, err)
+	return items, err
+}
+
+func (r *ReportRepo) Resolve(ctx context.Context, target moderation.Target, status moderation.Status) (int64, error) {
+	attrs := append(targetAttrs(target), attribute.String("report.status", string(status)))
+	ctx, span := startSpan(ctx, "ReportRepo.Resolve", attrs...)
+	resolved, err := r.next.Resolve(ctx, target, status)
+	endSpan(span, err)
+	return resolved, err
+}
+
+func (r *ReportRepo) ReportsBy(ctx context.Context, reporterID int64) ([]*moderation.Report, error) {
+	ctx, span := startSpan(ctx, "ReportRepo.ReportsBy", userAttr(reporterID))
+	reports, err := r.next.ReportsBy(ctx, reporterID)
+	endSpan(span, err)
+	return reports, err
+}
+
+func (r *ReportRepo) DeleteReportsBy(ctx context.Context, reporterID int64) (int64, error) {
+	ctx, span := startSpan(ctx, "ReportRepo.DeleteReportsBy", userAttr(reporterID))
+	deleted, err := r.next.DeleteReportsBy(ctx, reporterID)
+	endSpan(span, err)
+	return deleted, err
+}
+
+// AuditLog оборачивает moderation.AuditLog и пишет спан на каждый вызов.
+type AuditLog struct {
+	next moderation.AuditLog
+}
+
+func NewAuditLog(next moderation.AuditLog) *AuditLog {
+	return &AuditLog{next: next}
+}
+
+func (r *AuditLog) Append(ctx context.Context, entry *moderation.AuditEntry) error {
+	attrs := append(targetAttrs(entry.Target), userAttr(entry.ActorID), attribute.String("audit.action", string(entry.Action)))
+	ctx, span := startSpan(ctx, "AuditLog.Append", attrs...)
+	err := r.next.Append(ctx, entry)
+	endSpan(span, err)
+	return err
+}
+
+func (r *AuditLog) List(ctx context.Context, offset, limit int) ([]*moderation.AuditEntry, error) {
+	ctx, span := startSpan(ctx, "AuditLog.List")
+	entries, err := r.next.List(ctx, offset, limit)
+	endSpan(span, err)
+	return entries, err
+}
+
+// BlobStore оборачивает media.BlobStore и пишет спан на каждый вызов.
+type BlobStore struct {
+	next media.BlobStore
+}
+
+func NewBlobStore(next media.BlobStore) *BlobStore {
+	return &BlobStore{next: next}
+}
+
+func (s *BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	ctx, span := startSpan(ctx, "BlobStore.Put", attribute.String("blob.key", key), attribute.Int64("blob.size", size))
+	err := s.next.Put(ctx, key, r, size, contentType)
+	endSpan(span, err)
+	return err
+}
+
+func (s *BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, media.BlobInfo, error) {
+	ctx, span := startSpan(ctx, "BlobStore.Get", attribute.String("blob.key", key))
+	blob, info, err := s.next.Get(ctx, key)
+	endSpan(span, err)
+	return blob, info, err
+}
+
+func (s *BlobStore) Delete(ctx context.Context, key string) error {
+	ctx, span := startSpan(ctx, "BlobStore.Delete", attribute.String("blob.key", key))
+	err := s.next.Delete(ctx, key)
+	endSpan(span, err)
+	return err
+}
+
+// SessionManager оборачивает sessions.SessionManagerInterface и пишет спан на каждый вызов.
+type SessionManager struct {
+	next sessions.SessionManagerInterface
+}
+
+func NewSessionManager(next sessions.SessionManagerInterface) *SessionManager {
+	return &SessionManager{next: next}
+}
+
+func (sm *SessionManager) Create(ctx context.Context, in *sessions.Session) (*sessions.SessionID, error) {
+	ctx, span := startSpan(ctx, "SessionManager.Create", userAttr(in.ID))
+	id, err := sm.next.Create(ctx, in)
+	endSpan(span, err)
+	return id, err
+}
+
+func (sm *SessionManager) RevokeAll(ctx context.Context, userID int64) (int, error) {
+	ctx, span := startSpan(ctx, "SessionManager.RevokeAll", userAttr(userID))
+	revoked, err := sm.next.RevokeAll(ctx, userID)
+	span.SetAttributes(attribute.Int("session.revoked", revoked))
+	endSpan(span, err)
+	return revoked, err
+}
+
+func (sm *SessionManager) Check(ctx context.Context, in *sessions.SessionID) *sessions.Session {
+	ctx, span := startSpan(ctx, "SessionManager.Check")
+	sess := sm.next.Check(ctx, in)
+	span.SetAttributes(attribute.Bool("session.found", sess != nil))
+	endSpan(span, nil)
+	return sess
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/internal/posts"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupTestProvider(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func TestMiddleware(t *testing.T) {
+	exporter := setupTestProvider(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	postID := primitive.NewObjectID()
+	mockRepo := posts.NewMockPostRepo(ctrl)
+	repo := NewPostRepo(mockRepo)
+
+	r := mux.NewRouter()
+	r.Use(Middleware)
+	r.HandleFunc("/api/post/{POST_ID}", func(w http.ResponseWriter, r *http.Request) {
+		_, err := repo.GetPost(r.Context(), postID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	mockRepo.EXPECT().GetPost(gomock.Any(), postID).Return(nil, fmt.Errorf("db is down"))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/api/post/"+postID.Hex(), nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+
+	repoSpan, routeSpan := spans[0], spans[1]
+	assert.Equal(t, "PostRepo.GetPost", repoSpan.Name)
+	assert.Equal(t, codes.Error, repoSpan.Status.Code)
+	assert.Equal(t, routeSpan.SpanContext.SpanID(), repoSpan.Parent.SpanID())
+
+	assert.Equal(t, "GET /api/post/{POST_ID}", routeSpan.Name)
+	assert.Equal(t, traceID, routeSpan.SpanContext.TraceID().String())
+	assert.Equal(t, codes.Error, routeSpan.Status.Code)
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Config{Exporter: "carrier-pigeon"})
+	assert.Error(t, err)
+}
@@ -1,57 +1,99 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel"
 	"golang.org/x/crypto/bcrypt"
+	"redditclone/internal/logging"
+	"strings"
+	"time"
 )
 
+const tracerName = "redditclone/internal/user"
+
 var (
 	ErrNoUser  = errors.New("no user found")
 	ErrBadPass = errors.New("invalid password")
 	ErrExists  = errors.New("already exists")
+	ErrBanned  = errors.New("user is banned")
+	// ErrReserved - логин занят самим сервисом, см. IsReserved.
+	ErrReserved = errors.New("username is reserved")
+	// ErrStorage оборачивает ошибки mysql, исходная ошибка доступна через errors.Is/As.
+	ErrStorage = errors.New("storage error")
 )
 
+// mysqlDuplicateEntry - код ошибки mysql при нарушении уникального ключа.
+const mysqlDuplicateEntry = 1062
+
 type UserMysqlRepository struct {
 	DB *sql.DB
+	// Timeout ограничивает время одного запроса к mysql. 0 - без таймаута.
+	Timeout time.Duration
 }
 
 func NewMysqlRepo(db *sql.DB) *UserMysqlRepository {
 	return &UserMysqlRepository{DB: db}
 }
 
-func (repo *UserMysqlRepository) Authorize(username, pass string) (*User, error) {
+func (repo *UserMysqlRepository) Authorize(ctx context.Context, username, pass string) (*User, error) {
 	user := &User{}
+	var banned bool
 
+	queryCtx, cancel := withTimeout(ctx, repo.Timeout)
+	defer cancel()
 	err := repo.DB.
-		QueryRow("SELECT id, username, password FROM users WHERE username = ?", username).
-		Scan(&user.ID, &user.Username, &user.Password)
-	if err != nil {
+		QueryRowContext(queryCtx, "SELECT id, username, password, banned FROM users WHERE username = ?", username).
+		Scan(&user.ID, &user.Username, &user.Password, &banned)
+	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrNoUser
 	}
+	if err != nil {
+		return nil, storageError(ctx, "user.Authorize", err)
+	}
 
+	// bcrypt намеренно медленный, поэтому выделяем его в отдельный спан.
+	_, span := otel.Tracer(tracerName).Start(ctx, "bcrypt.CompareHashAndPassword")
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(pass))
+	span.End()
 	if err != nil {
 		return nil, ErrBadPass
 	}
+	// О блокировке сообщаем только тому, кто знает пароль.
+	if banned {
+		return nil, ErrBanned
+	}
 
 	return user, nil
 }
 
-func (repo *UserMysqlRepository) MakeUser(username, pass string) (*User, error) {
-	hashedPass, err := hashPassword(pass)
+func (repo *UserMysqlRepository) MakeUser(ctx context.Context, username, pass string) (*User, error) {
+	if IsReserved(username) {
+		return nil, ErrReserved
+	}
+	hashedPass, err := hashPassword(ctx, pass)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := repo.DB.Exec(
+	queryCtx, cancel := withTimeout(ctx, repo.Timeout)
+	defer cancel()
+	result, err := repo.DB.ExecContext(
+		queryCtx,
 		"INSERT INTO users (`username`, `password`) VALUES (?, ?)",
 		username,
 		hashedPass,
 	)
-	if err != nil {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
 		return nil, ErrExists
 	}
+	if err != nil {
+		return nil, storageError(ctx, "user.MakeUser", err)
+	}
 
 	userID, err := result.LastInsertId()
 	if err != nil {
@@ -60,7 +102,124 @@ func (repo *UserMysqlRepository) MakeUser(username, pass string) (*User, error)
 	return &User{ID: userID, Username: username}, nil
 }
 
-func hashPassword(password string) (string, error) {
+func (repo *UserMysqlRepository) GetUser(ctx context.Context, username string) (*User, error) {
+	user := &User{}
+
+	queryCtx, cancel := withTimeout(ctx, repo.Timeout)
+	defer cancel()
+	err := repo.DB.
+		QueryRowContext(queryCtx, "SELECT id, username FROM users WHERE username = ?", username).
+		Scan(&user.ID, &user.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoUser
+	}
+	if err != nil {
+		return nil, storageError(ctx, "user.GetUser", err)
+	}
+	return user, nil
+}
+
+func (repo *UserMysqlRepository) GetUsersByIDs(ctx context.Context, ids []int64) ([]*User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := "SELECT id, username FROM users WHERE id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")"
+
+	queryCtx, cancel := withTimeout(ctx, repo.Timeout)
+	defer cancel()
+	rows, err := repo.DB.QueryContext(queryCtx, query, args...)
+	if err != nil {
+		return nil, storageError(ctx, "user.GetUsersByIDs", err)
+	}
+	defer rows.Close()
+
+	users := make([]*User, 0, len(ids))
+	for rows.Next() {
+		user := &User{}
+		if err = rows.Scan(&user.ID, &user.Username); err != nil {
+			return nil, storageError(ctx, "user.GetUsersByIDs", err)
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storageError(ctx, "user.GetUsersByIDs", err)
+	}
+	return users, nil
+}
+
+func (repo *UserMysqlRepository) DeleteUser(ctx context.Context, id int64) error {
+	queryCtx, cancel := withTimeout(ctx, repo.Timeout)
+	defer cancel()
+	result, err := repo.DB.ExecContext(queryCtx, "DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return storageError(ctx, "user.DeleteUser", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return storageError(ctx, "user.DeleteUser", err)
+	}
+	if deleted == 0 {
+		return ErrNoUser
+	}
+	return nil
+}
+
+// SetPassword заменяет пароль пользователя.
+func (repo *UserMysqlRepository) SetPassword(ctx context.Context, username, pass string) error {
+	hashedPass, err := hashPassword(ctx, pass)
+	if err != nil {
+		return err
+	}
+	return repo.updateUser(ctx, username, "UPDATE users SET password = ? WHERE username = ?", hashedPass, username)
+}
+
+// SetBanned блокирует пользователя или снимает блокировку. Заблокированный не может войти,
+// но уже выданные ему сессии нужно завершать отдельно.
+func (repo *UserMysqlRepository) SetBanned(ctx context.Context, username string, banned bool) error {
+	return repo.updateUser(ctx, username, "UPDATE users SET banned = ? WHERE username = ?", banned, username)
+}
+
+func (repo *UserMysqlRepository) updateUser(ctx context.Context, username, query string, args ...interface{}) error {
+	queryCtx, cancel := withTimeout(ctx, repo.Timeout)
+	defer cancel()
+	result, err := repo.DB.ExecContext(queryCtx, query, args...)
+	if err != nil {
+		return storageError(ctx, "user.updateUser", err)
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return storageError(ctx, "user.updateUser", err)
+	}
+	if changed > 0 {
+		return nil
+	}
+	// mysql не считает строку затронутой, если значение не поменялось, - отличаем это от отсутствующего пользователя.
+	_, err = repo.GetUser(ctx, username)
+	return err
+}
+
+// storageError пишет ошибку mysql в лог запроса и оборачивает её в ErrStorage.
+func storageError(ctx context.Context, op string, err error) error {
+	logging.FromContext(ctx, nil).Errorw("storage error", "op", op, "error", err)
+	return fmt.Errorf("%w: %w", ErrStorage, err)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := otel.Tracer(tracerName).Start(ctx, "bcrypt.GenerateFromPassword")
+	defer span.End()
+
 	cost := bcrypt.DefaultCost
 	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
@@ -5,6 +5,7 @@
 package user
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,31 +35,103 @@ func (m *MockUserRepo) EXPECT() *MockUserRepoMockRecorder {
 }
 
 // Authorize mocks base method.
-func (m *MockUserRepo) Authorize(username, pass string) (*User, error) {
+func (m *MockUserRepo) Authorize(ctx context.Context, username, pass string) (*User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Authorize", username, pass)
+	ret := m.ctrl.Call(m, "Authorize", ctx, username, pass)
 	ret0, _ := ret[0].(*User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Authorize indicates an expected call of Authorize.
-func (mr *MockUserRepoMockRecorder) Authorize(username, pass interface{}) *gomock.Call {
+func (mr *MockUserRepoMockRecorder) Authorize(ctx, username, pass interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authorize", reflect.TypeOf((*MockUserRepo)(nil).Authorize), username, pass)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authorize", reflect.TypeOf((*MockUserRepo)(nil).Authorize), ctx, username, pass)
+}
+
+// DeleteUser mocks base method.
+func (m *MockUserRepo) DeleteUser(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUserRepoMockRecorder) DeleteUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserRepo)(nil).DeleteUser), ctx, id)
+}
+
+// GetUser mocks base method.
+func (m *MockUserRepo) GetUser(ctx context.Context, username string) (*User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, username)
+	ret0, _ := ret[0].(*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockUserRepoMockRecorder) GetUser(ctx, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserRepo)(nil).GetUser), ctx, username)
+}
+
+// GetUsersByIDs mocks base method.
+func (m *MockUserRepo) GetUsersByIDs(ctx context.Context, ids []int64) ([]*User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsersByIDs", ctx, ids)
+	ret0, _ := ret[0].([]*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersByIDs indicates an expected call of GetUsersByIDs.
+func (mr *MockUserRepoMockRecorder) GetUsersByIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByIDs", reflect.TypeOf((*MockUserRepo)(nil).GetUsersByIDs), ctx, ids)
 }
 
 // MakeUser mocks base method.
-func (m *MockUserRepo) MakeUser(username, pass string) (*User, error) {
+func (m *MockUserRepo) MakeUser(ctx context.Context, username, pass string) (*User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MakeUser", username, pass)
+	ret := m.ctrl.Call(m, "MakeUser", ctx, username, pass)
 	ret0, _ := ret[0].(*User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // MakeUser indicates an expected call of MakeUser.
-func (mr *MockUserRepoMockRecorder) MakeUser(username, pass interface{}) *gomock.Call {
+func (mr *MockUserRepoMockRecorder) MakeUser(ctx, username, pass interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeUser", reflect.TypeOf((*MockUserRepo)(nil).MakeUser), ctx, username, pass)
+}
+
+// SetBanned mocks base method.
+func (m *MockUserRepo) SetBanned(ctx context.Context, username string, banned bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBanned", ctx, username, banned)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBanned indicates an expected call of SetBanned.
+func (mr *MockUserRepoMockRecorder) SetBanned(ctx, username, banned interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBanned", reflect.TypeOf((*MockUserRepo)(nil).SetBanned), ctx, username, banned)
+}
+
+// SetPassword mocks base method.
+func (m *MockUserRepo) SetPassword(ctx context.Context, username, pass string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPassword", ctx, username, pass)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPassword indicates an expected call of SetPassword.
+func (mr *MockUserRepoMockRecorder) SetPassword(ctx, username, pass interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeUser", reflect.TypeOf((*MockUserRepo)(nil).MakeUser), username, pass)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPassword", reflect.TypeOf((*MockUserRepo)(nil).SetPassword), ctx, username, pass)
 }
@@ -1,5 +1,19 @@
 package user
 
+import (
+	"context"
+	"strings"
+)
+
+// DeletedUsername - имя, под которым остаются посты и комментарии удалённых пользователей.
+// Зарегистрировать его нельзя: новый владелец получил бы чужие посты в профиле и чужих подписчиков.
+const DeletedUsername = "[deleted]"
+
+// IsReserved сообщает, что логин занят самим сервисом. Регистр не учитывается, как и в mysql.
+func IsReserved(username string) bool {
+	return strings.EqualFold(strings.TrimSpace(username), DeletedUsername)
+}
+
 type User struct {
 	ID       int64  `json:"id"`
 	Username string `json:"username"`
@@ -8,6 +22,15 @@ type User struct {
 
 //go:generate mockgen -source=user.go -destination=repo_mock.go -package=user UserRepo
 type UserRepo interface {
-	Authorize(username, pass string) (*User, error)
-	MakeUser(username, pass string) (*User, error)
+	Authorize(ctx context.Context, username, pass string) (*User, error)
+	MakeUser(ctx context.Context, username, pass string) (*User, error)
+	// GetUser ищет пользователя по логину, без пароля.
+	GetUser(ctx context.Context, username string) (*User, error)
+	// GetUsersByIDs одним запросом находит пользователей по id. Ненайденных в ответе просто нет.
+	GetUsersByIDs(ctx context.Context, ids []int64) ([]*User, error)
+	// SetPassword и SetBanned - для администратора. Заблокированному Authorize отвечает ErrBanned.
+	SetPassword(ctx context.Context, username, pass string) error
+	SetBanned(ctx context.Context, username string, banned bool) error
+	// DeleteUser удаляет учётную запись. ErrNoUser - её уже нет.
+	DeleteUser(ctx context.Context, id int64) error
 }
@@ -1,10 +1,18 @@
 package user
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/crypto/bcrypt"
+	"redditclone/internal/logging"
 	"testing"
+	"time"
 
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
@@ -21,7 +29,7 @@ func TestAuthorize(t *testing.T) {
 		pass     = "love1234"
 	)
 
-	hashPass, err := hashPassword(pass)
+	hashPass, err := hashPassword(context.Background(), pass)
 	if err != nil {
 		t.Errorf("cant create hashPass: %s", err)
 	}
@@ -35,37 +43,59 @@ func TestAuthorize(t *testing.T) {
 		{
 			name: "Проверка на успешную авторизацию",
 			mockSetup: func() {
-				rows := sqlmock.NewRows([]string{"id", "username", "password"}).
-					AddRow(1, username, hashPass)
-				mock.ExpectQuery("SELECT id, username, password FROM users WHERE").
+				rows := sqlmock.NewRows([]string{"id", "username", "password", "banned"}).
+					AddRow(1, username, hashPass, false)
+				mock.ExpectQuery("SELECT id, username, password, banned FROM users WHERE").
 					WithArgs(username).
 					WillReturnRows(rows)
 			},
 			expectedUser:  &User{1, username, hashPass},
 			expectedError: "",
 		},
+		{
+			name: "Проверка на отсутствие юзера",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT id, username, password, banned FROM users WHERE").
+					WithArgs(username).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedUser:  nil,
+			expectedError: "no user found",
+		},
 		{
 			name: "Проверка на ошибку БД",
 			mockSetup: func() {
-				mock.ExpectQuery("SELECT id, username, password FROM users WHERE").
+				mock.ExpectQuery("SELECT id, username, password, banned FROM users WHERE").
 					WithArgs(username).
 					WillReturnError(fmt.Errorf("db_error"))
 			},
 			expectedUser:  nil,
-			expectedError: "no user found",
+			expectedError: "storage error: db_error",
 		},
 		{
 			name: "Проверка на неверный пароль",
 			mockSetup: func() {
-				rows := sqlmock.NewRows([]string{"id", "username", "password"}).
-					AddRow(1, username, "someBadPass")
-				mock.ExpectQuery("SELECT id, username, password FROM users WHERE").
+				rows := sqlmock.NewRows([]string{"id", "username", "password", "banned"}).
+					AddRow(1, username, "someBadPass", false)
+				mock.ExpectQuery("SELECT id, username, password, banned FROM users WHERE").
 					WithArgs(username).
 					WillReturnRows(rows)
 			},
 			expectedUser:  nil,
 			expectedError: "invalid password",
 		},
+		{
+			name: "Проверка на заблокированного юзера",
+			mockSetup: func() {
+				rows := sqlmock.NewRows([]string{"id", "username", "password", "banned"}).
+					AddRow(1, username, hashPass, true)
+				mock.ExpectQuery("SELECT id, username, password, banned FROM users WHERE").
+					WithArgs(username).
+					WillReturnRows(rows)
+			},
+			expectedUser:  nil,
+			expectedError: "user is banned",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -73,7 +103,7 @@ func TestAuthorize(t *testing.T) {
 			tc.mockSetup()
 
 			repo := &UserMysqlRepository{DB: db}
-			user, err := repo.Authorize(username, pass)
+			user, err := repo.Authorize(context.Background(), username, pass)
 
 			if tc.expectedError != "" {
 				if assert.Error(t, err, "expected an error but got none") {
@@ -139,10 +169,28 @@ func TestMakeUser(t *testing.T) {
 			mockSetup: func() {
 				mock.ExpectExec(`INSERT INTO users`).
 					WithArgs(username, sqlmock.AnyArg()).
-					WillReturnError(fmt.Errorf("db_error"))
+					WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
 			},
 			expectError: "already exists",
 		},
+		{
+			name:        "Проверка, что служебный логин не регистрируется",
+			username:    " [Deleted] ",
+			password:    password,
+			mockSetup:   func() {},
+			expectError: "username is reserved",
+		},
+		{
+			name:     "Проверка ошибки БД при создании юзера",
+			username: username,
+			password: password,
+			mockSetup: func() {
+				mock.ExpectExec(`INSERT INTO users`).
+					WithArgs(username, sqlmock.AnyArg()).
+					WillReturnError(fmt.Errorf("db_error"))
+			},
+			expectError: "storage error: db_error",
+		},
 		{
 			name:     "Проверка ошибки, что бд не вернула id юзера",
 			username: username,
@@ -160,7 +208,7 @@ func TestMakeUser(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
 
-			user, err := repo.MakeUser(tc.username, tc.password)
+			user, err := repo.MakeUser(context.Background(), tc.username, tc.password)
 
 			if tc.expectError != "" {
 				if assert.Error(t, err, "expected an error but got none") {
@@ -177,9 +225,356 @@ func TestMakeUser(t *testing.T) {
 	}
 }
 
+func TestGetUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	testCases := []struct {
+		name          string
+		mockSetup     func()
+		expectedUser  *User
+		expectedError string
+	}{
+		{
+			name: "Пользователь найден",
+			mockSetup: func() {
+				rows := sqlmock.NewRows([]string{"id", "username"}).AddRow(1, "rvasily")
+				mock.ExpectQuery("SELECT id, username FROM users WHERE").
+					WithArgs("rvasily").
+					WillReturnRows(rows)
+			},
+			expectedUser: &User{ID: 1, Username: "rvasily"},
+		},
+		{
+			name: "Пользователя нет",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT id, username FROM users WHERE").
+					WithArgs("rvasily").
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedError: "no user found",
+		},
+		{
+			name: "Ошибка БД",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT id, username FROM users WHERE").
+					WithArgs("rvasily").
+					WillReturnError(fmt.Errorf("db_error"))
+			},
+			expectedError: "storage error: db_error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			repo := NewMysqlRepo(db)
+			user, err := repo.GetUser(context.Background(), "rvasily")
+
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedUser, user)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStorageErrorLogged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	core, logs := observer.New(zap.ErrorLevel)
+	ctx := logging.NewContext(context.Background(), zap.New(core).Sugar().With("request_id", "req-1"))
+	mock.ExpectQuery("SELECT id, username FROM users WHERE").
+		WithArgs("rvasily").
+		WillReturnError(fmt.Errorf("db_error"))
+
+	_, err = NewMysqlRepo(db).GetUser(ctx, "rvasily")
+	assert.ErrorIs(t, err, ErrStorage)
+
+	entries := logs.AllUntimed()
+	if assert.Len(t, entries, 1) {
+		fields := entries[0].ContextMap()
+		assert.Equal(t, "user.GetUser", fields["op"])
+		assert.Equal(t, "req-1", fields["request_id"])
+		assert.Equal(t, "db_error", fields["error"])
+	}
+}
+
+func TestGetUsersByIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	testCases := []struct {
+		name          string
+		ids           []int64
+		mockSetup     func()
+		expectedUsers []*User
+		expectedError string
+	}{
+		{
+			name: "Найдены не все",
+			ids:  []int64{1, 2, 3},
+			mockSetup: func() {
+				rows := sqlmock.NewRows([]string{"id", "username"}).
+					AddRow(1, "rvasily").
+					AddRow(3, "romanov")
+				mock.ExpectQuery(`SELECT id, username FROM users WHERE id IN \(\?, \?, \?\)`).
+					WithArgs(1, 2, 3).
+					WillReturnRows(rows)
+			},
+			expectedUsers: []*User{{ID: 1, Username: "rvasily"}, {ID: 3, Username: "romanov"}},
+		},
+		{
+			name:      "Пустой список без запроса",
+			mockSetup: func() {},
+		},
+		{
+			name: "Ошибка БД",
+			ids:  []int64{1},
+			mockSetup: func() {
+				mock.ExpectQuery(`SELECT id, username FROM users WHERE id IN \(\?\)`).
+					WithArgs(1).
+					WillReturnError(fmt.Errorf("db_error"))
+			},
+			expectedError: "storage error: db_error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			repo := NewMysqlRepo(db)
+			users, err := repo.GetUsersByIDs(context.Background(), tc.ids)
+
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedUsers, users)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	testCases := []struct {
+		name        string
+		mockSetup   func()
+		expectedErr error
+	}{
+		{
+			name: "Удаление пользователя",
+			mockSetup: func() {
+				mock.ExpectExec("DELETE FROM users WHERE id").
+					WithArgs(int64(1)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "Пользователя уже нет",
+			mockSetup: func() {
+				mock.ExpectExec("DELETE FROM users WHERE id").
+					WithArgs(int64(1)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedErr: ErrNoUser,
+		},
+		{
+			name: "Ошибка базы",
+			mockSetup: func() {
+				mock.ExpectExec("DELETE FROM users WHERE id").
+					WithArgs(int64(1)).
+					WillReturnError(fmt.Errorf("connection lost"))
+			},
+			expectedErr: ErrStorage,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			repo := NewMysqlRepo(db)
+			err := repo.DeleteUser(context.Background(), 1)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	testCases := []struct {
+		name        string
+		mockSetup   func()
+		update      func(repo *UserMysqlRepository) error
+		expectedErr error
+	}{
+		{
+			name: "Блокировка",
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET banned = \\? WHERE username = \\?").
+					WithArgs(true, "rvasily").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			update: func(repo *UserMysqlRepository) error {
+				return repo.SetBanned(context.Background(), "rvasily", true)
+			},
+		},
+		{
+			name: "Повторная блокировка ничего не меняет",
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET banned").
+					WithArgs(true, "rvasily").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT id, username FROM users WHERE").
+					WithArgs("rvasily").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(1, "rvasily"))
+			},
+			update: func(repo *UserMysqlRepository) error {
+				return repo.SetBanned(context.Background(), "rvasily", true)
+			},
+		},
+		{
+			name: "Нет такого пользователя",
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET password").
+					WithArgs(sqlmock.AnyArg(), "nobody").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT id, username FROM users WHERE").
+					WithArgs("nobody").
+					WillReturnError(sql.ErrNoRows)
+			},
+			update: func(repo *UserMysqlRepository) error {
+				return repo.SetPassword(context.Background(), "nobody", "newPass")
+			},
+			expectedErr: ErrNoUser,
+		},
+		{
+			name: "Ошибка базы",
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET banned").
+					WithArgs(false, "rvasily").
+					WillReturnError(fmt.Errorf("db_error"))
+			},
+			update: func(repo *UserMysqlRepository) error {
+				return repo.SetBanned(context.Background(), "rvasily", false)
+			},
+			expectedErr: ErrStorage,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			err := tc.update(NewMysqlRepo(db))
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// hashOf совпадает с bcrypt-хешем пароля.
+type hashOf string
+
+func (h hashOf) Match(v driver.Value) bool {
+	hash, ok := v.(string)
+	return ok && bcrypt.CompareHashAndPassword([]byte(hash), []byte(h)) == nil
+}
+
+func TestSetPasswordHashes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users SET password").
+		WithArgs(hashOf("newPass"), "rvasily").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, NewMysqlRepo(db).SetPassword(context.Background(), "rvasily", "newPass"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestNewMysqlRepo(t *testing.T) {
 	db := &sql.DB{}
 
 	repo := NewMysqlRepo(db)
 	assert.Equal(t, repo.DB, db, "expected be correct, but not")
 }
+
+func TestQueryTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := &UserMysqlRepository{DB: db, Timeout: 20 * time.Millisecond}
+
+	t.Run("Медленный SELECT прерывается по таймауту", func(t *testing.T) {
+		mock.ExpectQuery("SELECT id, username, password, banned FROM users WHERE").
+			WithArgs("rvasily").
+			WillDelayFor(time.Second).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password", "banned"}))
+
+		start := time.Now()
+		_, err := repo.Authorize(context.Background(), "rvasily", "love1234")
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 500*time.Millisecond, "query was not cancelled")
+	})
+
+	t.Run("Медленный INSERT прерывается отменой контекста", func(t *testing.T) {
+		mock.ExpectExec(`INSERT INTO users`).
+			WithArgs("someUser", sqlmock.AnyArg()).
+			WillDelayFor(time.Second).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := repo.MakeUser(ctx, "someUser", "somePass")
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 500*time.Millisecond, "exec was not cancelled")
+	})
+}
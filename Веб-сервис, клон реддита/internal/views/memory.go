@@ -0,0 +1,58 @@
+package views
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sweepEvery - раз в сколько просмотров чистить протухшие записи.
+const sweepEvery = 1024
+
+// MemoryDeduper помнит зрителей каждого поста в памяти процесса. Подходит для одного инстанса и тестов.
+type MemoryDeduper struct {
+	Window time.Duration
+	// Now можно подменить в тестах.
+	Now func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	ops  int
+}
+
+func NewMemoryDeduper(window time.Duration) *MemoryDeduper {
+	return &MemoryDeduper{
+		Window: window,
+		Now:    time.Now,
+		seen:   map[string]time.Time{},
+	}
+}
+
+func (d *MemoryDeduper) FirstView(_ context.Context, postID primitive.ObjectID, viewer string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.Now()
+	d.sweep(now)
+
+	key := postID.Hex() + ":" + viewer
+	if expires, ok := d.seen[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+	d.seen[key] = now.Add(d.Window)
+	return true, nil
+}
+
+func (d *MemoryDeduper) sweep(now time.Time) {
+	d.ops++
+	if d.ops%sweepEvery != 0 {
+		return
+	}
+	for key, expires := range d.seen {
+		if !now.Before(expires) {
+			delete(d.seen, key)
+		}
+	}
+}
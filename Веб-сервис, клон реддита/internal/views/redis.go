@@ -0,0 +1,65 @@
+package views
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConnGetter выдаёт соединения с redis, например *redis.Pool.
+type ConnGetter interface {
+	GetContext(ctx context.Context) (redis.Conn, error)
+}
+
+// RedisDeduper хранит зрителей поста в HyperLogLog, по одному на пост и окно.
+// PFADD отвечает 1, только если оценка числа зрителей выросла, то есть зритель новый.
+// HyperLogLog вероятностный: изредка новый зритель не будет засчитан, зато ключ весит не больше 12 КБ.
+// Окна фиксированные, поэтому зритель на стыке двух окон может быть засчитан дважды.
+type RedisDeduper struct {
+	Window time.Duration
+	// Now можно подменить в тестах.
+	Now func() time.Time
+
+	pool   ConnGetter
+	prefix string
+}
+
+func NewRedisDeduper(pool ConnGetter, window time.Duration) *RedisDeduper {
+	return &RedisDeduper{
+		Window: window,
+		Now:    time.Now,
+		pool:   pool,
+		prefix: "views:",
+	}
+}
+
+func (d *RedisDeduper) FirstView(ctx context.Context, postID primitive.ObjectID, viewer string) (bool, error) {
+	if d.Window <= 0 {
+		return true, nil
+	}
+
+	conn, err := d.pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	window := d.Now().UnixMilli() / d.Window.Milliseconds()
+	key := d.prefix + postID.Hex() + ":" + strconv.FormatInt(window, 10)
+
+	added, err := redis.Int(redis.DoContext(conn, ctx, "PFADD", key, viewer))
+	if err != nil {
+		return false, err
+	}
+	if added == 1 {
+		// Ключ живёт до конца своего окна, с запасом в одно окно.
+		if _, err = redis.DoContext(conn, ctx, "PEXPIRE", key, 2*d.Window.Milliseconds()); err != nil {
+			return false, err
+		}
+	}
+
+	return added == 1, nil
+}
@@ -0,0 +1,101 @@
+package views
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// Deduper решает, считать ли просмотр: один зритель засчитывается один раз за окно.
+type Deduper interface {
+	FirstView(ctx context.Context, postID primitive.ObjectID, viewer string) (bool, error)
+}
+
+// Sink - куда сбрасываются накопленные просмотры, в проде это posts.PostRepo.
+type Sink interface {
+	AddViews(ctx context.Context, views map[primitive.ObjectID]int) error
+}
+
+// Recorder копит уникальные просмотры в памяти и периодически пачкой сбрасывает их в Sink.
+type Recorder struct {
+	Deduper Deduper
+	Sink    Sink
+	Logger  *zap.SugaredLogger
+
+	mu      sync.Mutex
+	pending map[primitive.ObjectID]int
+}
+
+func NewRecorder(deduper Deduper, sink Sink, logger *zap.SugaredLogger) *Recorder {
+	return &Recorder{
+		Deduper: deduper,
+		Sink:    sink,
+		Logger:  logger,
+		pending: map[primitive.ObjectID]int{},
+	}
+}
+
+// Record засчитывает просмотр, если зритель ещё не смотрел пост в текущем окне.
+func (r *Recorder) Record(ctx context.Context, postID primitive.ObjectID, viewer string) (bool, error) {
+	first, err := r.Deduper.FirstView(ctx, postID, viewer)
+	if err != nil || !first {
+		return false, err
+	}
+
+	r.mu.Lock()
+	r.pending[postID]++
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Pending возвращает просмотры поста, которые ещё не сброшены в базу.
+func (r *Recorder) Pending(postID primitive.ObjectID) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pending[postID]
+}
+
+// Flush сбрасывает накопленные просмотры. При ошибке они возвращаются в очередь до следующей попытки.
+func (r *Recorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	batch := r.pending
+	r.pending = map[primitive.ObjectID]int{}
+	r.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := r.Sink.AddViews(ctx, batch); err != nil {
+		r.mu.Lock()
+		for postID, count := range batch {
+			r.pending[postID] += count
+		}
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run сбрасывает просмотры раз в interval, пока не отменят ctx, и делает последний сброс перед выходом.
+func (r *Recorder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Flush(ctx); err != nil {
+				r.Logger.Errorw("failed to flush views", "error", err)
+			}
+		case <-ctx.Done():
+			if err := r.Flush(context.WithoutCancel(ctx)); err != nil {
+				r.Logger.Errorw("failed to flush views on shutdown", "error", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// fakeSink запоминает все сброшенные пачки и может притвориться недоступной базой.
+type fakeSink struct {
+	mu      sync.Mutex
+	total   map[primitive.ObjectID]int
+	batches int
+	err     error
+}
+
+func (s *fakeSink) AddViews(_ context.Context, views map[primitive.ObjectID]int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.total == nil {
+		s.total = map[primitive.ObjectID]int{}
+	}
+	for postID, count := range views {
+		s.total[postID] += count
+	}
+	s.batches++
+	return nil
+}
+
+func TestDedupers(t *testing.T) {
+	dedupers := map[string]func(t *testing.T, clock *fakeClock) Deduper{
+		"memory": func(t *testing.T, clock *fakeClock) Deduper {
+			d := NewMemoryDeduper(time.Hour)
+			d.Now = clock.Now
+			return d
+		},
+		"redis": func(t *testing.T, clock *fakeClock) Deduper {
+			srv := miniredis.RunT(t)
+			pool := &redis.Pool{
+				DialContext: func(ctx context.Context) (redis.Conn, error) {
+					return redis.DialContext(ctx, "tcp", srv.Addr())
+				},
+			}
+			t.Cleanup(func() { pool.Close() })
+			d := NewRedisDeduper(pool, time.Hour)
+			d.Now = clock.Now
+			return d
+		},
+	}
+
+	for name, newDeduper := range dedupers {
+		t.Run(name, func(t *testing.T) {
+			// Начало окна, чтобы сдвиг на час гарантированно попадал в следующее окно.
+			clock := &fakeClock{now: time.Unix(1700002800, 0)}
+			d := newDeduper(t, clock)
+			ctx := context.Background()
+			post, other := primitive.NewObjectID(), primitive.NewObjectID()
+
+			first, err := d.FirstView(ctx, post, "user:1")
+			assert.NoError(t, err)
+			assert.True(t, first, "first view is counted")
+
+			first, _ = d.FirstView(ctx, post, "user:1")
+			assert.False(t, first, "repeated view is not counted")
+
+			first, _ = d.FirstView(ctx, post, "user:2")
+			assert.True(t, first, "other viewer is counted")
+
+			first, _ = d.FirstView(ctx, other, "user:1")
+			assert.True(t, first, "other post is counted")
+
+			clock.now = clock.now.Add(time.Hour)
+			first, _ = d.FirstView(ctx, post, "user:1")
+			assert.True(t, first, "view is counted again in the next window")
+		})
+	}
+}
+
+func TestRecorderFlush(t *testing.T) {
+	sink := &fakeSink{}
+	rec := NewRecorder(NewMemoryDeduper(time.Hour), sink, zap.NewNop().Sugar())
+	ctx := context.Background()
+	post := primitive.NewObjectID()
+
+	for _, viewer := range []string{"a", "b", "a", "c", "b"} {
+		_, err := rec.Record(ctx, post, viewer)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, rec.Pending(post))
+
+	sink.err = errors.New("mongo is down")
+	assert.Error(t, rec.Flush(ctx))
+	assert.Equal(t, 3, rec.Pending(post), "views are kept after a failed flush")
+
+	_, _ = rec.Record(ctx, post, "d")
+	sink.err = nil
+	assert.NoError(t, rec.Flush(ctx))
+	assert.Equal(t, 0, rec.Pending(post))
+	assert.Equal(t, 4, sink.total[post])
+	assert.Equal(t, 1, sink.batches)
+
+	assert.NoError(t, rec.Flush(ctx))
+	assert.Equal(t, 1, sink.batches, "empty batch is not written")
+}
+
+func TestRecorderRunFlushesOnStop(t *testing.T) {
+	sink := &fakeSink{}
+	rec := NewRecorder(NewMemoryDeduper(time.Hour), sink, zap.NewNop().Sugar())
+	post := primitive.NewObjectID()
+	_, _ = rec.Record(context.Background(), post, "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		rec.Run(ctx, time.Hour)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop")
+	}
+	assert.Equal(t, 1, sink.total[post])
+}
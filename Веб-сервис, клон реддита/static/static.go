@@ -0,0 +1,250 @@
+// Package static - собранный фронтенд. Файлы встроены в бинарник, поэтому сервер
+// не зависит от каталога, из которого его запустили.
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"redditclone/internal/middleware"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+//go:embed html css js
+var embedded embed.FS
+
+// IndexPath - страница SPA, которую получают все клиентские маршруты.
+const IndexPath = "html/index.html"
+
+// immutableCacheControl - для файлов с хешем содержимого в имени: новая сборка даёт новые имена.
+// Поэтому такой файл нельзя править без переименования - клиенты год будут брать старую копию из кеша.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// precompressBrotliLevel - уровень brotli для сжатия при старте.
+const precompressBrotliLevel = 9
+
+// hashed - файлы сборки с хешем в имени, например main.11cbbdb9.chunk.js.
+var hashed = regexp.MustCompile(`\.[0-9a-f]{8,}\.chunk\.(js|css)$`)
+
+// contentTypes задаём сами: mime.TypeByExtension зависит от mime.types системы.
+var contentTypes = map[string]string{
+	".html":  "text/html; charset=utf-8",
+	".js":    "text/javascript; charset=utf-8",
+	".css":   "text/css; charset=utf-8",
+	".json":  "application/json",
+	".map":   "application/json",
+	".svg":   "image/svg+xml",
+	".ico":   "image/x-icon",
+	".png":   "image/png",
+	".woff2": "font/woff2",
+	".txt":   "text/plain; charset=utf-8",
+}
+
+// compressed - расширения, которые стоит сжимать заранее.
+var compressed = map[string]bool{".html": true, ".js": true, ".css": true, ".json": true, ".map": true, ".svg": true, ".txt": true}
+
+// FS - файлы фронтенда: встроенные или, если dir не пуст, каталог на диске - чтобы при разработке
+// правки подхватывались без пересборки.
+func FS(dir string) fs.FS {
+	if dir != "" {
+		return os.DirFS(dir)
+	}
+	return embedded
+}
+
+// asset - файл, прочитанный при старте.
+type asset struct {
+	data []byte
+	etag string
+	// variants - сжатые заранее версии по Content-Encoding. Хранятся, только если вышли меньше исходника.
+	variants map[string][]byte
+}
+
+// Server раздаёт файлы фронтенда.
+type Server struct {
+	fsys fs.FS
+	// maxAge - сколько браузер кеширует файлы без хеша в имени.
+	maxAge time.Duration
+	// assets - файлы, прочитанные и сжатые при старте. nil - читать с диска на каждый запрос.
+	assets map[string]*asset
+}
+
+// NewServer с preload читает и сжимает все файлы сразу: так делается для встроенных файлов,
+// которые не меняются до перезапуска. Без preload файлы читаются на каждый запрос, а сжимает их middleware.Compress.
+func NewServer(fsys fs.FS, maxAge time.Duration, preload bool) (*Server, error) {
+	s := &Server{fsys: fsys, maxAge: maxAge}
+	if _, err := fs.Stat(fsys, IndexPath); err != nil {
+		return nil, err
+	}
+	if !preload {
+		return s, nil
+	}
+
+	s.assets = map[string]*asset{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		s.assets[name], err = newAsset(name, data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newAsset(name string, data []byte) (*asset, error) {
+	sum := sha256.Sum256(data)
+	a := &asset{data: data, etag: hex.EncodeToString(sum[:8]), variants: map[string][]byte{}}
+	if !compressed[path.Ext(name)] {
+		return a, nil
+	}
+
+	// Сжимаем один раз при старте, поэтому уровни выше, чем в middleware.Compress. Уровни 10-11 у brotli
+	// дают несколько процентов, но на чанке в сотни килобайт замедляют старт на секунды.
+	var br, gz bytes.Buffer
+	bw := brotli.NewWriterLevel(&br, precompressBrotliLevel)
+	gw, err := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range []io.WriteCloser{bw, gw} {
+		if _, err = w.Write(data); err != nil {
+			return nil, err
+		}
+		if err = w.Close(); err != nil {
+			return nil, err
+		}
+	}
+	for encoding, buf := range map[string]*bytes.Buffer{"br": &br, "gzip": &gz} {
+		if buf.Len() < len(data) {
+			a.variants[encoding] = buf.Bytes()
+		}
+	}
+	return a, nil
+}
+
+// Assets раздаёт файлы по путям без префикса, например js/main.11cbbdb9.chunk.js: его снимает http.StripPrefix.
+func (s *Server) Assets() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		cacheControl := "public, max-age=" + strconv.Itoa(int(s.maxAge.Seconds()))
+		if hashed.MatchString(name) {
+			cacheControl = immutableCacheControl
+		}
+		s.serve(w, r, name, cacheControl)
+	})
+}
+
+// App отдаёт страницу SPA: на "/" и на любой клиентский маршрут /a/..., дальше маршрутизирует фронтенд.
+// Страницу браузер перепроверяет каждый раз, иначе после выкладки он держал бы ссылки на старые файлы.
+func (s *Server) App() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, IndexPath, "no-cache")
+	})
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request, name, cacheControl string) {
+	// В каталоге на диске рядом со статикой лежит и этот пакет.
+	if path.Ext(name) == ".go" {
+		http.NotFound(w, r)
+		return
+	}
+	h := w.Header()
+	if s.assets == nil {
+		s.serveFile(w, r, name, cacheControl)
+		return
+	}
+	a, ok := s.assets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.Set("Content-Type", contentType(name))
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Cache-Control", cacheControl)
+	body, etag := a.data, a.etag
+	if len(a.variants) > 0 {
+		addVary(h, "Accept-Encoding")
+		if encoding := middleware.NegotiateEncoding(r.Header.Get("Accept-Encoding")); a.variants[encoding] != nil {
+			// У каждого представления свой ETag, иначе кеш отдал бы сжатое клиенту, который его не понимает.
+			body, etag = a.variants[encoding], etag+"-"+encoding
+			h.Set("Content-Encoding", encoding)
+		}
+	}
+	h.Set("ETag", `"`+etag+`"`)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(body))
+}
+
+// serveFile читает файл с диска. Проверку свежести даёт Last-Modified.
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, name, cacheControl string) {
+	f, err := s.fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", contentType(name))
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Cache-Control", cacheControl)
+	http.ServeContent(w, r, name, info.ModTime(), rs)
+}
+
+func contentType(name string) string {
+	ext := path.Ext(name)
+	if ct, ok := contentTypes[ext]; ok {
+		return ct
+	}
+	if ct := mime.TypeByExtension(ext); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// addVary добавляет значение в Vary, если его там ещё нет: middleware.Compress тоже его ставит.
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
@@ -0,0 +1,214 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	indexHTML = "<!doctype html><html><body>" + strings.Repeat("<div id=\"root\"></div>", 100) + "</body></html>"
+	mainJS    = strings.Repeat("console.log('hello');", 200)
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		IndexPath:                     {Data: []byte(indexHTML)},
+		"js/main.32ebaf54.chunk.js":   {Data: []byte(mainJS)},
+		"css/main.74225161.chunk.css": {Data: []byte("body{margin:0}")},
+		"favicon.ico":                 {Data: []byte{0, 0, 1, 0}},
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	s, err := NewServer(FS(""), time.Hour, true)
+	require.NoError(t, err)
+	assert.Contains(t, s.assets, IndexPath)
+	for name := range s.assets {
+		assert.NotEqual(t, ".go", filepath.Ext(name))
+	}
+}
+
+func TestAssets(t *testing.T) {
+	s, err := NewServer(testFS(), time.Hour, true)
+	require.NoError(t, err)
+	handler := http.StripPrefix("/static/", s.Assets())
+
+	testCases := []struct {
+		name             string
+		path             string
+		acceptEncoding   string
+		wantStatus       int
+		wantType         string
+		wantCacheControl string
+		wantEncoding     string
+		wantBody         string
+	}{
+		{
+			name:             "Чанк с хешем кешируется навсегда",
+			path:             "/static/js/main.32ebaf54.chunk.js",
+			wantStatus:       http.StatusOK,
+			wantType:         "text/javascript; charset=utf-8",
+			wantCacheControl: immutableCacheControl,
+			wantBody:         mainJS,
+		},
+		{
+			name:             "brotli",
+			path:             "/static/js/main.32ebaf54.chunk.js",
+			acceptEncoding:   "gzip, br",
+			wantStatus:       http.StatusOK,
+			wantType:         "text/javascript; charset=utf-8",
+			wantCacheControl: immutableCacheControl,
+			wantEncoding:     "br",
+			wantBody:         mainJS,
+		},
+		{
+			name:             "gzip",
+			path:             "/static/js/main.32ebaf54.chunk.js",
+			acceptEncoding:   "gzip",
+			wantStatus:       http.StatusOK,
+			wantType:         "text/javascript; charset=utf-8",
+			wantCacheControl: immutableCacheControl,
+			wantEncoding:     "gzip",
+			wantBody:         mainJS,
+		},
+		{
+			name:             "Маленький файл не сжимается",
+			path:             "/static/css/main.74225161.chunk.css",
+			acceptEncoding:   "br",
+			wantStatus:       http.StatusOK,
+			wantType:         "text/css; charset=utf-8",
+			wantCacheControl: immutableCacheControl,
+			wantBody:         "body{margin:0}",
+		},
+		{
+			name:             "Файл без хеша",
+			path:             "/static/favicon.ico",
+			wantStatus:       http.StatusOK,
+			wantType:         "image/x-icon",
+			wantCacheControl: "public, max-age=3600",
+			wantBody:         "\x00\x00\x01\x00",
+		},
+		{name: "Нет файла", path: "/static/js/missing.js", wantStatus: http.StatusNotFound},
+		{name: "Каталог", path: "/static/js/", wantStatus: http.StatusNotFound},
+		{name: "Выход из каталога", path: "/static/../static.go", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tc.wantType, w.Header().Get("Content-Type"))
+			assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+			assert.Equal(t, tc.wantCacheControl, w.Header().Get("Cache-Control"))
+			assert.Equal(t, tc.wantEncoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, tc.wantBody, decode(t, w.Header().Get("Content-Encoding"), w.Body.Bytes()))
+		})
+	}
+}
+
+func TestAssetsConditional(t *testing.T) {
+	s, err := NewServer(testFS(), time.Hour, true)
+	require.NoError(t, err)
+	handler := s.Assets()
+
+	get := func(acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/js/main.32ebaf54.chunk.js", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	plain := get("", "").Header().Get("ETag")
+	br := get("br", "").Header().Get("ETag")
+	require.NotEmpty(t, plain)
+	assert.NotEqual(t, plain, br, "each encoding has its own ETag")
+	assert.Equal(t, []string{"Accept-Encoding"}, get("br", "").Header().Values("Vary"))
+
+	assert.Equal(t, http.StatusNotModified, get("br", br).Code)
+	assert.Equal(t, http.StatusOK, get("", br).Code, "compressed ETag does not match the plain file")
+}
+
+func TestApp(t *testing.T) {
+	s, err := NewServer(testFS(), time.Hour, true)
+	require.NoError(t, err)
+
+	for _, path := range []string{"/", "/a/music/6502f1e1c1b2a3d4e5f60718", "/a/user/rvasily"} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.App().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+			assert.Equal(t, indexHTML, w.Body.String())
+		})
+	}
+}
+
+func TestDiskOverride(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "html"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, IndexPath), []byte("v1"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "static.go"), []byte("package static"), 0o644))
+
+	s, err := NewServer(FS(dir), time.Hour, false)
+	require.NoError(t, err)
+
+	get := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	assert.Equal(t, "v1", get(s.App(), "/").Body.String())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, IndexPath), []byte("v2"), 0o644))
+	w := get(s.App(), "/a/new")
+	assert.Equal(t, "v2", w.Body.String(), "changes on disk are served without restart")
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+	assert.Equal(t, http.StatusNotFound, get(s.Assets(), "/static.go").Code)
+}
+
+func TestNewServerWithoutIndex(t *testing.T) {
+	_, err := NewServer(fstest.MapFS{"js/app.js": {Data: []byte("x")}}, time.Hour, true)
+	assert.Error(t, err)
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	var r io.Reader = bytes.NewReader(body)
+	switch encoding {
+	case "br":
+		r = brotli.NewReader(r)
+	case "gzip":
+		gr, err := gzip.NewReader(r)
+		require.NoError(t, err)
+		r = gr
+	}
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}